@@ -22,6 +22,7 @@ import (
 	"regexp"
 
 	"github.com/elastic/beats/filebeat/fileset"
+	"github.com/elastic/beats/filebeat/reader/multiline"
 	"github.com/elastic/beats/libbeat/autodiscover"
 	"github.com/elastic/beats/libbeat/autodiscover/builder"
 	"github.com/elastic/beats/libbeat/autodiscover/template"
@@ -29,6 +30,7 @@ import (
 	"github.com/elastic/beats/libbeat/common/bus"
 	"github.com/elastic/beats/libbeat/common/cfgwarn"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/processors"
 )
 
 func init() {
@@ -36,10 +38,11 @@ func init() {
 }
 
 const (
-	multiline    = "multiline"
-	includeLines = "include_lines"
-	excludeLines = "exclude_lines"
-	processors   = "processors"
+	multilineKey  = "multiline"
+	includeLines  = "include_lines"
+	excludeLines  = "exclude_lines"
+	processorsKey = "processors"
+	pipelineKey   = "pipeline"
 )
 
 // validModuleNames to sanitize user input
@@ -104,7 +107,7 @@ func (l *logHints) CreateConfig(event bus.Event) []*common.Config {
 	tempCfg := common.MapStr{}
 	mline := l.getMultiline(hints)
 	if len(mline) != 0 {
-		tempCfg.Put(multiline, mline)
+		tempCfg.Put(multilineKey, mline)
 	}
 	if ilines := l.getIncludeLines(hints); len(ilines) != 0 {
 		tempCfg.Put(includeLines, ilines)
@@ -114,7 +117,24 @@ func (l *logHints) CreateConfig(event bus.Event) []*common.Config {
 	}
 
 	if procs := l.getProcessors(hints); len(procs) != 0 {
-		tempCfg.Put(processors, procs)
+		// Processors from the hints are appended to the ones defined in the template
+		var tmplProcs []common.MapStr
+		if config.HasField(processorsKey) {
+			sub, _ := config.Child(processorsKey, -1)
+			if err := sub.Unpack(&tmplProcs); err != nil {
+				logp.Debug("hints.builder", "unable to unpack template processors due to error: %v", err)
+			}
+		}
+		tempCfg.Put(processorsKey, append(tmplProcs, procs...))
+	}
+
+	module := l.getModule(hints)
+	if pipeline := l.getPipeline(hints); pipeline != "" {
+		if module == "" {
+			tempCfg.Put(pipelineKey, pipeline)
+		} else {
+			logp.Debug("hints.builder", "ignoring pipeline hint %s, module %s comes with its own pipelines", pipeline, module)
+		}
 	}
 
 	// Merge config template with the configs from the annotations
@@ -123,7 +143,6 @@ func (l *logHints) CreateConfig(event bus.Event) []*common.Config {
 		return []*common.Config{config}
 	}
 
-	module := l.getModule(hints)
 	if module != "" {
 		moduleConf := map[string]interface{}{
 			"module": module,
@@ -147,8 +166,23 @@ func (l *logHints) CreateConfig(event bus.Event) []*common.Config {
 	return template.ApplyConfigTemplate(event, []*common.Config{config})
 }
 
+// getMultiline returns the multiline settings from the hints, or nil if they
+// are not valid multiline settings
 func (l *logHints) getMultiline(hints common.MapStr) common.MapStr {
-	return builder.GetHintMapStr(hints, l.Key, multiline)
+	mline := builder.GetHintMapStr(hints, l.Key, multilineKey)
+	if len(mline) == 0 {
+		return nil
+	}
+
+	cfg, err := common.NewConfigFrom(mline)
+	if err == nil {
+		err = cfg.Unpack(&multiline.Config{})
+	}
+	if err != nil {
+		logp.Err("Ignoring invalid multiline hint: %v", err)
+		return nil
+	}
+	return mline
 }
 
 func (l *logHints) getIncludeLines(hints common.MapStr) []string {
@@ -169,8 +203,31 @@ func (l *logHints) getInputs(hints common.MapStr) []common.MapStr {
 	return builder.GetHintAsConfigs(hints, l.Key)
 }
 
+func (l *logHints) getPipeline(hints common.MapStr) string {
+	return builder.GetHintString(hints, l.Key, pipelineKey)
+}
+
+// getProcessors returns the processors from the hints, dropping the ones that
+// don't have a single action or refer to unknown processors. The processors
+// are not created here, their settings are validated when the input starts.
 func (l *logHints) getProcessors(hints common.MapStr) []common.MapStr {
-	return builder.GetProcessors(hints, l.Key)
+	var procs []common.MapStr
+	for _, proc := range builder.GetProcessors(hints, l.Key) {
+		pluginConfig := processors.PluginConfig{}
+		cfg, err := common.NewConfigFrom([]common.MapStr{proc})
+		if err == nil {
+			err = cfg.Unpack(&pluginConfig)
+		}
+		if err == nil {
+			err = processors.Validate(pluginConfig)
+		}
+		if err != nil {
+			logp.Err("Ignoring invalid processor hint %v: %v", proc, err)
+			continue
+		}
+		procs = append(procs, proc)
+	}
+	return procs
 }
 
 type filesetConfig struct {
@@ -185,7 +242,7 @@ func (l *logHints) getFilesets(hints common.MapStr, module string) map[string]*f
 
 	moduleFilesets, err := l.Registry.ModuleFilesets(module)
 	if err != nil {
-		logp.Err("Error retrieving module filesets", err)
+		logp.Err("Error retrieving module filesets: %v", err)
 		return nil
 	}
 
@@ -26,6 +26,8 @@ import (
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/common/bus"
 	"github.com/elastic/beats/libbeat/paths"
+	_ "github.com/elastic/beats/libbeat/processors/actions"
+	_ "github.com/elastic/beats/libbeat/processors/dissect"
 )
 
 func TestGenerateHints(t *testing.T) {
@@ -120,6 +122,7 @@ func TestGenerateHints(t *testing.T) {
 						"multiline": common.MapStr{
 							"pattern": "^test",
 							"negate":  "true",
+							"match":   "after",
 						},
 					},
 				},
@@ -133,6 +136,38 @@ func TestGenerateHints(t *testing.T) {
 				"multiline": map[string]interface{}{
 					"pattern": "^test",
 					"negate":  "true",
+					"match":   "after",
+				},
+			},
+		},
+		{
+			msg: "Hint with invalid multiline config must be ignored",
+			event: bus.Event{
+				"host": "1.2.3.4",
+				"kubernetes": common.MapStr{
+					"container": common.MapStr{
+						"name": "foobar",
+						"id":   "abc",
+					},
+				},
+				"container": common.MapStr{
+					"name": "foobar",
+					"id":   "abc",
+				},
+				"hints": common.MapStr{
+					"logs": common.MapStr{
+						"multiline": common.MapStr{
+							"pattern": "^test",
+							"match":   "sideways",
+						},
+					},
+				},
+			},
+			len: 1,
+			result: common.MapStr{
+				"type": "docker",
+				"containers": map[string]interface{}{
+					"ids": []interface{}{"abc"},
 				},
 			},
 		},
@@ -213,6 +248,70 @@ func TestGenerateHints(t *testing.T) {
 				},
 			},
 		},
+		{
+			msg: "Hint with processors as json must drop unknown processors",
+			event: bus.Event{
+				"host": "1.2.3.4",
+				"kubernetes": common.MapStr{
+					"container": common.MapStr{
+						"name": "foobar",
+						"id":   "abc",
+					},
+				},
+				"container": common.MapStr{
+					"name": "foobar",
+					"id":   "abc",
+				},
+				"hints": common.MapStr{
+					"logs": common.MapStr{
+						"processors": `[{"drop_fields": {"fields": ["web"]}}, {"not_a_processor": {}}, {"drop_event": {}, "drop_fields": {"fields": ["web"]}}]`,
+					},
+				},
+			},
+			len: 1,
+			result: common.MapStr{
+				"type": "docker",
+				"containers": map[string]interface{}{
+					"ids": []interface{}{"abc"},
+				},
+				"processors": []interface{}{
+					map[string]interface{}{
+						"drop_fields": map[string]interface{}{
+							"fields": []interface{}{"web"},
+						},
+					},
+				},
+			},
+		},
+		{
+			msg: "Hint with pipeline must set the pipeline in the input config",
+			event: bus.Event{
+				"host": "1.2.3.4",
+				"kubernetes": common.MapStr{
+					"container": common.MapStr{
+						"name": "foobar",
+						"id":   "abc",
+					},
+				},
+				"container": common.MapStr{
+					"name": "foobar",
+					"id":   "abc",
+				},
+				"hints": common.MapStr{
+					"logs": common.MapStr{
+						"pipeline": "my-pipeline",
+					},
+				},
+			},
+			len: 1,
+			result: common.MapStr{
+				"type": "docker",
+				"containers": map[string]interface{}{
+					"ids": []interface{}{"abc"},
+				},
+				"pipeline": "my-pipeline",
+			},
+		},
 		{
 			msg: "Hint with module should attach input to its filesets",
 			event: bus.Event{
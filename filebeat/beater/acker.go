@@ -19,11 +19,13 @@ package beater
 
 import (
 	"github.com/elastic/beats/filebeat/input/file"
+	"github.com/elastic/beats/filebeat/util"
 	"github.com/elastic/beats/libbeat/logp"
 )
 
 // eventAcker handles publisher pipeline ACKs and forwards
-// them to the registrar or directly to the stateless logger.
+// them to the registrar or directly to the stateless logger. Private data
+// implementing util.ACKer is notified about the ACK as well.
 type eventACKer struct {
 	stateful  statefulLogger
 	stateless statelessLogger
@@ -53,6 +55,9 @@ func (a *eventACKer) ackEvents(data []interface{}) {
 
 		st, ok := datum.(file.State)
 		if !ok {
+			if acker, ok := datum.(util.ACKer); ok {
+				acker.ACK()
+			}
 			stateless++
 			continue
 		}
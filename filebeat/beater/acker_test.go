@@ -33,6 +33,14 @@ func (sf *mockStatefulLogger) Published(states []file.State) {
 	sf.states = states
 }
 
+type mockACKer struct {
+	acked int
+}
+
+func (a *mockACKer) ACK() {
+	a.acked++
+}
+
 type mockStatelessLogger struct {
 	count int
 }
@@ -86,3 +94,16 @@ func TestACKer(t *testing.T) {
 		})
 	}
 }
+
+func TestACKerNotifiesPrivateACKer(t *testing.T) {
+	sl := &mockStatelessLogger{}
+	sf := &mockStatefulLogger{}
+	acker := &mockACKer{}
+
+	h := newEventACKer(sl, sf)
+	h.ackEvents([]interface{}{acker, file.State{Source: "-"}, acker})
+
+	assert.Equal(t, 2, acker.acked)
+	assert.Equal(t, 2, sl.count)
+	assert.Len(t, sf.states, 1)
+}
@@ -18,7 +18,10 @@
 package channel
 
 import (
+	"fmt"
+
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/processors"
 )
@@ -28,6 +31,7 @@ type OutletFactory struct {
 
 	eventer  beat.ClientEventer
 	wgEvents eventCounter
+	limiter  *rateLimiter // global rate limit shared by all outlets
 }
 
 type eventCounter interface {
@@ -50,6 +54,9 @@ type inputOutletConfig struct {
 	// implicit event fields
 	Type string `config:"type"` // input.type
 
+	// ID identifies the input in the pipeline metrics
+	ID string `config:"id"`
+
 	// hidden filebeat modules settings
 	Module  string `config:"_module_name"`  // hidden setting
 	Fileset string `config:"_fileset_name"` // hidden setting
@@ -57,17 +64,22 @@ type inputOutletConfig struct {
 	// Output meta data settings
 	Pipeline string `config:"pipeline"` // ES Ingest pipeline name
 
+	// Input specific rate limit
+	RateLimit RateLimitConfig `config:"rate_limit"`
 }
 
 // NewOutletFactory creates a new outlet factory for
-// connecting an input to the publisher pipeline.
+// connecting an input to the publisher pipeline. The rate limit
+// is applied to the events of all inputs.
 func NewOutletFactory(
 	done <-chan struct{},
 	wgEvents eventCounter,
+	rateLimit *RateLimitConfig,
 ) *OutletFactory {
 	o := &OutletFactory{
 		done:     done,
 		wgEvents: wgEvents,
+		limiter:  newRateLimiter(rateLimit, globalThrottledEvents, globalThrottledTime),
 	}
 
 	if wgEvents != nil {
@@ -118,7 +130,14 @@ func (f *OutletFactory) Create(p beat.Pipeline, cfg *common.Config, dynFields *c
 		}
 	}
 
+	name, err := clientName(cfg, &config)
+	if err != nil {
+		processors.Close()
+		return nil, err
+	}
+
 	client, err := p.ConnectWith(beat.ClientConfig{
+		Name:          name,
 		PublishMode:   beat.GuaranteedSend,
 		EventMetadata: config.EventMetadata,
 		DynamicFields: dynFields,
@@ -128,16 +147,38 @@ func (f *OutletFactory) Create(p beat.Pipeline, cfg *common.Config, dynFields *c
 		Events:        f.eventer,
 	})
 	if err != nil {
+		processors.Close()
 		return nil, err
 	}
 
-	outlet := newOutlet(client, f.wgEvents)
+	o := newOutlet(client, f.wgEvents)
+	o.processors = processors
+	var outlet Outleter = o
+	outlet = withRateLimit(outlet,
+		newInputRateLimiter(name, &config.RateLimit),
+		f.limiter)
 	if f.done != nil {
 		return CloseOnSignal(outlet, f.done), nil
 	}
 	return outlet, nil
 }
 
+// clientName returns the name the input is reported with in the pipeline
+// metrics. The configured input id is used if set, otherwise the name is made
+// of the input type and a hash of the input configuration, so it stays the
+// same when an input is restarted.
+func clientName(cfg *common.Config, config *inputOutletConfig) (string, error) {
+	if config.ID != "" {
+		return config.ID, nil
+	}
+
+	hash, err := cfgfile.HashConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v-%x", config.Type, hash), nil
+}
+
 func (*clientEventer) Closing()   {}
 func (*clientEventer) Closed()    {}
 func (*clientEventer) Published() {}
@@ -0,0 +1,51 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package channel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestClientName(t *testing.T) {
+	name := func(c map[string]interface{}) string {
+		cfg, err := common.NewConfigFrom(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := inputOutletConfig{}
+		if err := cfg.Unpack(&config); err != nil {
+			t.Fatal(err)
+		}
+		name, err := clientName(cfg, &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	assert.Equal(t, "nginx-access", name(map[string]interface{}{"type": "log", "id": "nginx-access"}))
+
+	first := name(map[string]interface{}{"type": "log", "paths": []string{"/var/log/a.log"}})
+	assert.Regexp(t, "^log-[0-9a-f]+$", first)
+	assert.Equal(t, first, name(map[string]interface{}{"type": "log", "paths": []string{"/var/log/a.log"}}))
+	assert.NotEqual(t, first, name(map[string]interface{}{"type": "log", "paths": []string{"/var/log/b.log"}}))
+}
@@ -21,12 +21,16 @@ import (
 	"github.com/elastic/beats/filebeat/util"
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common/atomic"
+	"github.com/elastic/beats/libbeat/processors"
 )
 
 type outlet struct {
 	wg     eventCounter
 	client beat.Client
 	isOpen atomic.Bool
+
+	// processors are closed with the outlet, if set
+	processors *processors.Processors
 }
 
 func newOutlet(client beat.Client, wg eventCounter) *outlet {
@@ -41,7 +45,9 @@ func newOutlet(client beat.Client, wg eventCounter) *outlet {
 func (o *outlet) Close() error {
 	isOpen := o.isOpen.Swap(false)
 	if isOpen {
-		return o.client.Close()
+		err := o.client.Close()
+		o.processors.Close()
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,297 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package channel
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/atomic"
+	"github.com/elastic/beats/libbeat/common/cfgtype"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	rateLimitMetrics = monitoring.Default.NewRegistry("filebeat.rate_limit")
+
+	globalThrottledEvents = monitoring.NewInt(rateLimitMetrics, "global.throttled.events")
+	globalThrottledTime   = monitoring.NewInt(rateLimitMetrics, "global.throttled.ms")
+
+	// inputMetrics holds the metrics of the rate limited inputs, by input name
+	inputMetrics      = rateLimitMetrics.NewRegistry("input")
+	inputMetricsMutex sync.Mutex
+)
+
+// RateLimitConfig limits the number of events and bytes published per second.
+// Limits not configured are not enforced.
+type RateLimitConfig struct {
+	Events      float64          `config:"events" validate:"min=0"`
+	EventsBurst int              `config:"events_burst" validate:"min=0"`
+	Bytes       cfgtype.ByteSize `config:"bytes" validate:"min=0"`
+	BytesBurst  cfgtype.ByteSize `config:"bytes_burst" validate:"min=0"`
+}
+
+// IsEnabled returns true if any limit is configured.
+func (c *RateLimitConfig) IsEnabled() bool {
+	return c != nil && (c.Events > 0 || c.Bytes > 0)
+}
+
+// rateLimiter blocks publishing events until the configured rates allow for
+// the event to be sent. The limiter can be shared between multiple outlets.
+type rateLimiter struct {
+	events *tokenBucket
+	bytes  *tokenBucket
+
+	throttledEvents *monitoring.Int
+	throttledTime   *monitoring.Int
+
+	// close removes the metrics of the limiter, if set
+	close func()
+}
+
+// tokenBucket is refilled at a constant rate up to its capacity. Reservations
+// are always granted, but can put the bucket into debt, the caller must wait
+// until the debt would have been refilled.
+type tokenBucket struct {
+	mutex    sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// newRateLimiter creates a limiter for the configured rates. Returns nil if no
+// limit is configured.
+func newRateLimiter(config *RateLimitConfig, throttledEvents, throttledTime *monitoring.Int) *rateLimiter {
+	if !config.IsEnabled() {
+		return nil
+	}
+
+	now := time.Now()
+	l := &rateLimiter{
+		throttledEvents: throttledEvents,
+		throttledTime:   throttledTime,
+	}
+	if config.Events > 0 {
+		l.events = newTokenBucket(config.Events, float64(config.EventsBurst), now)
+	}
+	if config.Bytes > 0 {
+		l.bytes = newTokenBucket(float64(config.Bytes), float64(config.BytesBurst), now)
+	}
+	return l
+}
+
+// newInputRateLimiter creates the limiter of a single input, reporting its
+// metrics in `filebeat.rate_limit.input.<name>`. Returns nil if no limit is
+// configured.
+func newInputRateLimiter(name string, config *RateLimitConfig) *rateLimiter {
+	if !config.IsEnabled() {
+		return nil
+	}
+
+	inputMetricsMutex.Lock()
+	defer inputMetricsMutex.Unlock()
+
+	regName := name
+	for n := 2; inputMetrics.Get(regName) != nil; n++ {
+		regName = name + "_" + strconv.Itoa(n)
+	}
+	reg := inputMetrics.NewRegistry(regName)
+
+	l := newRateLimiter(config,
+		monitoring.NewInt(reg, "throttled.events"),
+		monitoring.NewInt(reg, "throttled.ms"))
+	l.close = func() {
+		inputMetricsMutex.Lock()
+		defer inputMetricsMutex.Unlock()
+		inputMetrics.Remove(regName)
+	}
+	return l
+}
+
+// newTokenBucket creates a full bucket. The capacity defaults to one second
+// worth of tokens.
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	capacity := burst
+	if capacity <= 0 {
+		capacity = math.Max(1, rate)
+	}
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// reserve takes n tokens from the bucket, returning the time to wait until
+// the tokens are available.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until an event of the given size can be published. Returns false
+// if done is closed while waiting.
+func (l *rateLimiter) wait(size int, done <-chan struct{}) bool {
+	now := time.Now()
+
+	var delay time.Duration
+	if l.events != nil {
+		delay = l.events.reserve(1, now)
+	}
+	if l.bytes != nil {
+		if d := l.bytes.reserve(float64(size), now); d > delay {
+			delay = d
+		}
+	}
+
+	if delay <= 0 {
+		return true
+	}
+
+	l.throttledEvents.Inc()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		l.throttledTime.Add(int64(time.Since(now) / time.Millisecond))
+		return false
+	case <-timer.C:
+		l.throttledTime.Add(int64(delay / time.Millisecond))
+		return true
+	}
+}
+
+// rateLimitOutlet delays events until all limiters allow for the event to be
+// published.
+type rateLimitOutlet struct {
+	outlet   Outleter
+	limiters []*rateLimiter
+	isOpen   atomic.Bool
+	done     chan struct{}
+}
+
+// withRateLimit wraps the outlet with the given limiters, ignoring nil
+// limiters.
+func withRateLimit(outlet Outleter, limiters ...*rateLimiter) Outleter {
+	var active []*rateLimiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return outlet
+	}
+
+	return &rateLimitOutlet{
+		outlet:   outlet,
+		limiters: active,
+		isOpen:   atomic.MakeBool(true),
+		done:     make(chan struct{}),
+	}
+}
+
+func (o *rateLimitOutlet) Close() error {
+	if o.isOpen.Swap(false) {
+		close(o.done)
+		for _, l := range o.limiters {
+			if l.close != nil {
+				l.close()
+			}
+		}
+	}
+	return o.outlet.Close()
+}
+
+func (o *rateLimitOutlet) OnEvent(d *util.Data) bool {
+	// state only updates are not rate limited
+	if d.HasEvent() {
+		size := -1
+		for _, l := range o.limiters {
+			if size < 0 && l.bytes != nil {
+				size = eventSize(d)
+			}
+			if !l.wait(size, o.done) {
+				return false
+			}
+		}
+	}
+	return o.outlet.OnEvent(d)
+}
+
+// eventSize returns the size of the message of an event. The size of the
+// event fields is estimated if the event has no message.
+func eventSize(d *util.Data) int {
+	if msg, ok := d.Event.Fields["message"].(string); ok {
+		return len(msg)
+	}
+	return estimateSize(d.Event.Fields)
+}
+
+// estimateSize approximates the JSON encoded size of a value, without encoding
+// it. Keys and strings count their length, other values 8 bytes.
+func estimateSize(v interface{}) int {
+	switch v := v.(type) {
+	case common.MapStr:
+		return estimateSize(map[string]interface{}(v))
+	case map[string]interface{}:
+		size := 0
+		for k, elem := range v {
+			size += len(k) + estimateSize(elem)
+		}
+		return size
+	case []interface{}:
+		size := 0
+		for _, elem := range v {
+			size += estimateSize(elem)
+		}
+		return size
+	case []string:
+		size := 0
+		for _, elem := range v {
+			size += len(elem)
+		}
+		return size
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case nil:
+		return 0
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,194 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package channel
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+type recordOutlet struct {
+	sync.Mutex
+	events []*util.Data
+	closed bool
+}
+
+func (o *recordOutlet) OnEvent(d *util.Data) bool {
+	o.Lock()
+	defer o.Unlock()
+	o.events = append(o.events, d)
+	return true
+}
+
+func (o *recordOutlet) Close() error {
+	o.Lock()
+	defer o.Unlock()
+	o.closed = true
+	return nil
+}
+
+func testData(message string) *util.Data {
+	return &util.Data{Event: beat.Event{Fields: common.MapStr{"message": message}}}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10, 20, now)
+
+	// burst is available immediately
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, time.Duration(0), b.reserve(1, now))
+	}
+	assert.Equal(t, 100*time.Millisecond, b.reserve(1, now))
+	assert.Equal(t, 200*time.Millisecond, b.reserve(1, now))
+
+	// refill pays back the debt first
+	assert.Equal(t, 200*time.Millisecond, b.reserve(1, now.Add(100*time.Millisecond)))
+
+	// refill is capped by the capacity
+	later := now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.reserve(20, later))
+	assert.Equal(t, 100*time.Millisecond, b.reserve(1, later))
+}
+
+func TestTokenBucketDefaultCapacity(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, float64(5), newTokenBucket(5, 0, now).capacity)
+	assert.Equal(t, float64(1), newTokenBucket(0.5, 0, now).capacity)
+
+	// reservations larger than the capacity are allowed, but create a debt
+	b := newTokenBucket(100, 0, now)
+	assert.Equal(t, time.Duration(0), b.reserve(50, now))
+	assert.Equal(t, time.Second, b.reserve(150, now))
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	m := monitoring.NewRegistry()
+	events, ms := monitoring.NewInt(m, "events"), monitoring.NewInt(m, "ms")
+
+	assert.Nil(t, newRateLimiter(nil, events, ms))
+	assert.Nil(t, newRateLimiter(&RateLimitConfig{}, events, ms))
+
+	l := newRateLimiter(&RateLimitConfig{Events: 10}, events, ms)
+	if assert.NotNil(t, l) {
+		assert.NotNil(t, l.events)
+		assert.Nil(t, l.bytes)
+	}
+
+	l = newRateLimiter(&RateLimitConfig{Bytes: 1024, BytesBurst: 4096}, events, ms)
+	if assert.NotNil(t, l) {
+		assert.Nil(t, l.events)
+		assert.Equal(t, float64(4096), l.bytes.capacity)
+	}
+}
+
+func TestRateLimitOutletThrottles(t *testing.T) {
+	m := monitoring.NewRegistry()
+	events, ms := monitoring.NewInt(m, "events"), monitoring.NewInt(m, "ms")
+
+	out := &recordOutlet{}
+	limiter := newRateLimiter(&RateLimitConfig{Bytes: 1000, BytesBurst: 10}, events, ms)
+	outlet := withRateLimit(out, limiter, nil)
+
+	start := time.Now()
+	assert.True(t, outlet.OnEvent(testData("0123456789")))
+	assert.True(t, outlet.OnEvent(testData("0123456789")))
+	assert.True(t, outlet.OnEvent(testData("0123456789")))
+
+	assert.True(t, time.Since(start) >= 15*time.Millisecond)
+	assert.Len(t, out.events, 3)
+	assert.Equal(t, int64(2), events.Get())
+	assert.True(t, ms.Get() > 0)
+}
+
+func TestRateLimitOutletIgnoresStateUpdates(t *testing.T) {
+	m := monitoring.NewRegistry()
+	limiter := newRateLimiter(&RateLimitConfig{Events: 0.001},
+		monitoring.NewInt(m, "events"), monitoring.NewInt(m, "ms"))
+
+	out := &recordOutlet{}
+	outlet := withRateLimit(out, limiter)
+	for i := 0; i < 10; i++ {
+		assert.True(t, outlet.OnEvent(&util.Data{}))
+	}
+	assert.Len(t, out.events, 10)
+}
+
+func TestRateLimitOutletClose(t *testing.T) {
+	m := monitoring.NewRegistry()
+	limiter := newRateLimiter(&RateLimitConfig{Events: 0.001},
+		monitoring.NewInt(m, "events"), monitoring.NewInt(m, "ms"))
+
+	out := &recordOutlet{}
+	outlet := withRateLimit(out, limiter)
+	assert.True(t, outlet.OnEvent(testData("a")))
+
+	result := make(chan bool)
+	go func() {
+		result <- outlet.OnEvent(testData("b"))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	outlet.Close()
+
+	select {
+	case ok := <-result:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("outlet blocked after close")
+	}
+	assert.Len(t, out.events, 1)
+	assert.True(t, out.closed)
+}
+
+func TestWithRateLimitWithoutLimiters(t *testing.T) {
+	out := &recordOutlet{}
+	assert.Equal(t, Outleter(out), withRateLimit(out, nil, nil))
+}
+
+func TestEventSize(t *testing.T) {
+	assert.Equal(t, 5, eventSize(testData("hello")))
+
+	d := &util.Data{Event: beat.Event{Fields: common.MapStr{
+		"a": "bc",
+		"d": common.MapStr{"e": 1, "f": []interface{}{"gh", true}},
+	}}}
+	assert.Equal(t, len("abcdefgh")+8+8, eventSize(d))
+}
+
+func TestInputRateLimiterMetrics(t *testing.T) {
+	assert.Nil(t, newInputRateLimiter("test", &RateLimitConfig{}))
+
+	first := newInputRateLimiter("test", &RateLimitConfig{Events: 10})
+	second := newInputRateLimiter("test", &RateLimitConfig{Events: 10})
+	assert.NotNil(t, inputMetrics.Get("test.throttled.events"))
+	assert.NotNil(t, inputMetrics.Get("test_2.throttled.events"))
+
+	out := withRateLimit(&recordOutlet{}, first, second)
+	out.Close()
+	assert.Nil(t, inputMetrics.Get("test"))
+	assert.Nil(t, inputMetrics.Get("test_2"))
+}
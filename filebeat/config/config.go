@@ -25,6 +25,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/elastic/beats/filebeat/channel"
 	"github.com/elastic/beats/libbeat/autodiscover"
 	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common"
@@ -39,25 +40,29 @@ const (
 )
 
 type Config struct {
-	Inputs                  []*common.Config     `config:"inputs"`
-	Prospectors             []*common.Config     `config:"prospectors"`
-	RegistryFile            string               `config:"registry_file"`
-	RegistryFilePermissions os.FileMode          `config:"registry_file_permissions"`
-	RegistryFlush           time.Duration        `config:"registry_flush"`
-	ConfigDir               string               `config:"config_dir"`
-	ShutdownTimeout         time.Duration        `config:"shutdown_timeout"`
-	Modules                 []*common.Config     `config:"modules"`
-	ConfigInput             *common.Config       `config:"config.inputs"`
-	ConfigProspector        *common.Config       `config:"config.prospectors"`
-	ConfigModules           *common.Config       `config:"config.modules"`
-	Autodiscover            *autodiscover.Config `config:"autodiscover"`
-	OverwritePipelines      bool                 `config:"overwrite_pipelines"`
+	Inputs                  []*common.Config        `config:"inputs"`
+	Prospectors             []*common.Config        `config:"prospectors"`
+	RegistryFile            string                  `config:"registry_file"`
+	RegistryFilePermissions os.FileMode             `config:"registry_file_permissions"`
+	RegistryFlush           time.Duration           `config:"registry_flush"`
+	RegistryType            string                  `config:"registry_type"`
+	RegistryTTL             time.Duration           `config:"registry_ttl" validate:"min=0"`
+	ConfigDir               string                  `config:"config_dir"`
+	ShutdownTimeout         time.Duration           `config:"shutdown_timeout"`
+	Modules                 []*common.Config        `config:"modules"`
+	ConfigInput             *common.Config          `config:"config.inputs"`
+	ConfigProspector        *common.Config          `config:"config.prospectors"`
+	ConfigModules           *common.Config          `config:"config.modules"`
+	Autodiscover            *autodiscover.Config    `config:"autodiscover"`
+	OverwritePipelines      bool                    `config:"overwrite_pipelines"`
+	RateLimit               channel.RateLimitConfig `config:"rate_limit"`
 }
 
 var (
 	DefaultConfig = Config{
 		RegistryFile:            "registry",
 		RegistryFilePermissions: 0600,
+		RegistryType:            "json",
 		ShutdownTimeout:         0,
 		OverwritePipelines:      false,
 	}
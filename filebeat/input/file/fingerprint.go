@@ -0,0 +1,51 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrFileTooSmall is returned by Fingerprint if the file does not contain
+// enough bytes yet to compute its fingerprint.
+var ErrFileTooSmall = errors.New("file is too small to be fingerprinted")
+
+// Fingerprint returns the hex encoded sha256 hash of length bytes read from
+// the file at path, starting at offset.
+func Fingerprint(path string, offset, length int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, io.NewSectionReader(f, offset, length))
+	if err != nil {
+		return "", err
+	}
+	if n < length {
+		return "", ErrFileTooSmall
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
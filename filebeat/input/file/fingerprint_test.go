@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	original := write("original.log", "header\nfirst line\nsecond line\n")
+	copied := write("copied.log", "header\nfirst line\nother line\n")
+	other := write("other.log", "header\nother line\n")
+	short := write("short.log", "header\n")
+
+	fp, err := Fingerprint(original, 0, 18)
+	assert.NoError(t, err)
+	assert.Len(t, fp, 64)
+
+	// same content within the fingerprinted range
+	fpCopied, err := Fingerprint(copied, 0, 18)
+	assert.NoError(t, err)
+	assert.Equal(t, fp, fpCopied)
+
+	fpOther, err := Fingerprint(other, 0, 18)
+	assert.NoError(t, err)
+	assert.NotEqual(t, fp, fpOther)
+
+	// the offset skips the common header
+	fpOffset, err := Fingerprint(original, 7, 11)
+	assert.NoError(t, err)
+	fpOtherOffset, err := Fingerprint(other, 7, 11)
+	assert.NoError(t, err)
+	assert.NotEqual(t, fpOffset, fpOtherOffset)
+
+	_, err = Fingerprint(short, 0, 18)
+	assert.Equal(t, ErrFileTooSmall, err)
+
+	_, err = Fingerprint(short, 10, 1)
+	assert.Equal(t, ErrFileTooSmall, err)
+
+	_, err = Fingerprint(filepath.Join(dir, "missing.log"), 0, 18)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStateIDFingerprint(t *testing.T) {
+	first, err := ioutil.TempFile("", "state-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(first.Name())
+	defer first.Close()
+
+	second, err := ioutil.TempFile("", "state-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(second.Name())
+	defer second.Close()
+
+	firstInfo, err := first.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondInfo, err := second.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	native := NewState(firstInfo, first.Name(), "log", nil)
+	fingerprint := native
+	fingerprint.Fingerprint = "abcdef"
+	assert.NotEqual(t, native.ID(), fingerprint.ID())
+
+	// the inode and device are not part of the id of a fingerprinted state
+	renamed := NewState(secondInfo, second.Name(), "log", nil)
+	renamed.Fingerprint = "abcdef"
+	assert.Equal(t, fingerprint.ID(), renamed.ID())
+
+	withMeta := NewState(secondInfo, second.Name(), "log", map[string]string{"stream": "stdout"})
+	withMeta.Fingerprint = "abcdef"
+	assert.NotEqual(t, renamed.ID(), withMeta.ID())
+
+	assert.False(t, (&State{Fingerprint: "abcdef"}).IsEmpty())
+}
@@ -39,6 +39,8 @@ type State struct {
 	TTL         time.Duration     `json:"ttl"`
 	Type        string            `json:"type"`
 	Meta        map[string]string `json:"meta"`
+	Cursor      string            `json:"cursor,omitempty"`      // read position of non file based inputs (e.g. journald)
+	Fingerprint string            `json:"fingerprint,omitempty"` // content based file identity, replaces FileStateOS in the id if set
 	FileStateOS file.StateOS
 }
 
@@ -61,14 +63,14 @@ func (s *State) ID() string {
 	// Generate id on first request. This is needed as id is not set when converting back from json
 	if s.Id == "" {
 		if s.Meta == nil {
-			s.Id = s.FileStateOS.String()
+			s.Id = s.fileID()
 		} else {
 			hashValue, _ := hashstructure.Hash(s.Meta, nil)
 			var hashBuf [17]byte
 			hash := strconv.AppendUint(hashBuf[:0], hashValue, 16)
 			hash = append(hash, '-')
 
-			fileID := s.FileStateOS.String()
+			fileID := s.fileID()
 
 			var b strings.Builder
 			b.Grow(len(hash) + len(fileID))
@@ -82,6 +84,16 @@ func (s *State) ID() string {
 	return s.Id
 }
 
+// fileID returns the part of the id identifying the file. The fingerprint is
+// used if set, as the inode and device of a file are not stable on all
+// file systems and can be reused after a file was deleted.
+func (s *State) fileID() string {
+	if s.Fingerprint != "" {
+		return "fingerprint-" + s.Fingerprint
+	}
+	return s.FileStateOS.String()
+}
+
 // IsEqual compares the state to an other state supporing stringer based on the unique string
 func (s *State) IsEqual(c *State) bool {
 	return s.ID() == c.ID()
@@ -90,6 +102,7 @@ func (s *State) IsEqual(c *State) bool {
 // IsEmpty returns true if the state is empty
 func (s *State) IsEmpty() bool {
 	return s.FileStateOS == file.StateOS{} &&
+		s.Fingerprint == "" &&
 		s.Source == "" &&
 		s.Meta == nil &&
 		s.Timestamp.IsZero()
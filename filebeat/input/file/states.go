@@ -94,6 +94,13 @@ func (s *States) findPrevious(id string) int {
 // The number of states that were cleaned up and number of states that can be
 // cleaned up in the future is returned.
 func (s *States) Cleanup() (int, int) {
+	return s.CleanupWith(nil)
+}
+
+// CleanupWith cleans up the state array like Cleanup. The onRemove callback,
+// if not nil, is called for every state being removed. The callback is run
+// with the lock held and must not access the States.
+func (s *States) CleanupWith(onRemove func(State)) (int, int) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -116,6 +123,9 @@ func (s *States) Cleanup() (int, int) {
 
 			delete(s.idx, state.ID())
 			logp.Debug("state", "State removed for %v because of older: %v", state.Source, state.TTL)
+			if onRemove != nil {
+				onRemove(*state)
+			}
 
 			L--
 			if L != i {
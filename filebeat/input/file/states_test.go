@@ -86,3 +86,19 @@ func TestCleanup(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupWith(t *testing.T) {
+	states := NewStates()
+	states.SetStates([]State{
+		{Source: "removed", TTL: 0, Finished: true, Fingerprint: "a"},
+		{Source: "kept", TTL: -1, Finished: true, Fingerprint: "b"},
+	})
+
+	var removed []string
+	cleanupCount, _ := states.CleanupWith(func(state State) {
+		removed = append(removed, state.Source)
+	})
+	assert.Equal(t, 1, cleanupCount)
+	assert.Equal(t, []string{"removed"}, removed)
+	assert.Equal(t, 1, states.Count())
+}
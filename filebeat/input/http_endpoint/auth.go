@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http_endpoint
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+var hmacHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+var (
+	errMissingAuth      = errors.New("missing authentication")
+	errInvalidAuth      = errors.New("invalid authentication")
+	errMissingSignature = errors.New("missing signature")
+	errInvalidSignature = errors.New("invalid signature")
+)
+
+// authenticate validates the credentials and the HMAC signature of the
+// request, if configured.
+func (c *config) authenticate(r *http.Request, body []byte) error {
+	switch {
+	case c.BasicAuth:
+		user, pass, ok := r.BasicAuth()
+		if !ok {
+			return errMissingAuth
+		}
+		if !secureCompare(user, c.Username) || !secureCompare(pass, c.Password) {
+			return errInvalidAuth
+		}
+
+	case c.BearerToken != "":
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return errMissingAuth
+		}
+		if !secureCompare(strings.TrimPrefix(auth, "Bearer "), c.BearerToken) {
+			return errInvalidAuth
+		}
+	}
+
+	if c.HMAC.Header != "" {
+		return c.HMAC.validate(r, body)
+	}
+	return nil
+}
+
+// validate checks the signature header contains the hex encoded HMAC of the
+// request body, e.g. `X-Hub-Signature-256: sha256=<hmac>` used by GitHub.
+func (c *hmacConfig) validate(r *http.Request, body []byte) error {
+	signature := r.Header.Get(c.Header)
+	if signature == "" {
+		return errMissingSignature
+	}
+	if !strings.HasPrefix(signature, c.Prefix) {
+		return errInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, c.Prefix))
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	mac := hmac.New(hmacHashes[c.Type], []byte(c.Key))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http_endpoint
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/libbeat/common/cfgtype"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	harvester.ForwarderConfig `config:",inline"`
+
+	ListenAddress string                  `config:"listen_address"`
+	ListenPort    int                     `config:"listen_port" validate:"min=1,max=65535"`
+	URL           string                  `config:"url"`
+	TLS           *tlscommon.ServerConfig `config:"ssl"`
+	MaxBodySize   cfgtype.ByteSize        `config:"max_body_size" validate:"nonzero,positive"`
+
+	// authentication
+	BasicAuth   bool       `config:"basic_auth"`
+	Username    string     `config:"username"`
+	Password    string     `config:"password"`
+	BearerToken string     `config:"bearer_token"`
+	HMAC        hmacConfig `config:"hmac"`
+
+	// event mapping
+	Prefix         string   `config:"prefix"`
+	SplitEventsBy  string   `config:"split_events_by"`
+	IncludeHeaders []string `config:"include_headers"`
+
+	// response
+	ResponseCode int    `config:"response_code" validate:"min=100,max=599"`
+	ResponseBody string `config:"response_body"`
+}
+
+type hmacConfig struct {
+	Header string `config:"header"`
+	Key    string `config:"key"`
+	Type   string `config:"type"`
+	Prefix string `config:"prefix"`
+}
+
+var defaultConfig = config{
+	ForwarderConfig: harvester.ForwarderConfig{
+		Type: "http_endpoint",
+	},
+	ListenAddress: "127.0.0.1",
+	ListenPort:    8000,
+	URL:           "/",
+	MaxBodySize:   10 * humanize.MiByte,
+	Prefix:        "json",
+	ResponseCode:  http.StatusOK,
+	ResponseBody:  `{"message": "success"}`,
+	HMAC: hmacConfig{
+		Type: "sha256",
+	},
+}
+
+func (c *config) Validate() error {
+	if !strings.HasPrefix(c.URL, "/") {
+		return errors.New("url must start with /")
+	}
+
+	if c.BasicAuth && (c.Username == "" || c.Password == "") {
+		return errors.New("username and password are required with basic_auth")
+	}
+	if c.BasicAuth && c.BearerToken != "" {
+		return errors.New("basic_auth and bearer_token can not be used together")
+	}
+
+	if c.HMAC.Header != "" || c.HMAC.Key != "" {
+		if c.HMAC.Header == "" || c.HMAC.Key == "" {
+			return errors.New("hmac.header and hmac.key must both be set")
+		}
+		if _, ok := hmacHashes[c.HMAC.Type]; !ok {
+			return fmt.Errorf("invalid hmac.type '%v', must be one of sha1 or sha256", c.HMAC.Type)
+		}
+	}
+	return nil
+}
+
+func (c *config) address() string {
+	return net.JoinHostPort(c.ListenAddress, strconv.Itoa(c.ListenPort))
+}
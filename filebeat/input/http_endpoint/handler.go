@@ -0,0 +1,240 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http_endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/jsontransform"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+var errNotObject = errors.New("JSON objects expected")
+
+// handler accepts JSON and NDJSON encoded POST requests, publishing one event
+// per JSON object.
+type handler struct {
+	config  config
+	publish func(beat.Event) error
+	log     *logp.Logger
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, http.StatusMethodNotAllowed, "only POST requests are allowed")
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(h.config.MaxBodySize)+1))
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	if int64(len(body)) > int64(h.config.MaxBodySize) {
+		h.sendError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	if err := h.config.authenticate(r, body); err != nil {
+		h.log.Debugf("Rejecting request from %v: %v", r.RemoteAddr, err)
+		h.sendError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var objs []common.MapStr
+	switch contentType(r) {
+	case "application/json", "":
+		objs, err = decodeJSON(body)
+	case "application/x-ndjson", "application/ndjson":
+		objs, err = decodeNDJSON(body)
+	default:
+		h.sendError(w, http.StatusUnsupportedMediaType,
+			"content type must be application/json or application/x-ndjson")
+		return
+	}
+	if err != nil {
+		h.sendError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode body: %v", err))
+		return
+	}
+
+	// Events are created before any of them is published. Publishing only fails
+	// when the input is shutting down, the number of events published until
+	// then is reported to the client.
+	headers := h.includedHeaders(r)
+	var events []beat.Event
+	for _, obj := range objs {
+		for _, fields := range splitEvents(obj, h.config.SplitEventsBy) {
+			events = append(events, h.createEvent(fields, headers))
+		}
+	}
+
+	for i, event := range events {
+		if err := h.publish(event); err != nil {
+			h.sendPartial(w, i, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.config.ResponseCode)
+	io.WriteString(w, h.config.ResponseBody)
+}
+
+func (h *handler) createEvent(obj common.MapStr, headers common.MapStr) beat.Event {
+	fields := common.MapStr{}
+	if h.config.Prefix == "" {
+		fields = obj
+	} else {
+		fields.Put(h.config.Prefix, obj)
+	}
+
+	if len(headers) > 0 {
+		fields.Put("http.request.headers", headers)
+	}
+
+	return beat.Event{
+		Timestamp: time.Now(),
+		Fields:    fields,
+	}
+}
+
+func (h *handler) includedHeaders(r *http.Request) common.MapStr {
+	if len(h.config.IncludeHeaders) == 0 {
+		return nil
+	}
+
+	headers := common.MapStr{}
+	for _, name := range h.config.IncludeHeaders {
+		if value := r.Header.Get(name); value != "" {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	return headers
+}
+
+// sendPartial reports the number of events published before publishing
+// failed, so the client only needs to resend the remaining events.
+func (h *handler) sendPartial(w http.ResponseWriter, published int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(common.MapStr{"message": err.Error(), "published": published})
+}
+
+func (h *handler) sendError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(common.MapStr{"message": msg})
+}
+
+func contentType(r *http.Request) string {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return ct
+}
+
+// decodeJSON decodes a single JSON object or an array of JSON objects.
+func decodeJSON(body []byte) ([]common.MapStr, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return toObjects(v)
+}
+
+// decodeNDJSON decodes newline delimited JSON values. Empty lines are ignored.
+func decodeNDJSON(body []byte) ([]common.MapStr, error) {
+	var objs []common.MapStr
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		lineObjs, err := decodeJSON(line)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, lineObjs...)
+	}
+	return objs, scanner.Err()
+}
+
+func toObjects(v interface{}) ([]common.MapStr, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		obj := common.MapStr(v)
+		jsontransform.TransformNumbers(obj)
+		return []common.MapStr{obj}, nil
+	case []interface{}:
+		objs := make([]common.MapStr, 0, len(v))
+		for _, elem := range v {
+			m, ok := elem.(map[string]interface{})
+			if !ok {
+				return nil, errNotObject
+			}
+			obj := common.MapStr(m)
+			jsontransform.TransformNumbers(obj)
+			objs = append(objs, obj)
+		}
+		return objs, nil
+	default:
+		return nil, errNotObject
+	}
+}
+
+// splitEvents returns the objects of the array stored under key, such that
+// each array element is published as separate event. The object itself is
+// returned if key is not set or does not reference an array of objects.
+func splitEvents(obj common.MapStr, key string) []common.MapStr {
+	if key == "" {
+		return []common.MapStr{obj}
+	}
+
+	v, err := obj.GetValue(key)
+	if err != nil {
+		return []common.MapStr{obj}
+	}
+
+	elems, ok := v.([]interface{})
+	if !ok {
+		return []common.MapStr{obj}
+	}
+
+	objs, err := toObjects(elems)
+	if err != nil {
+		return []common.MapStr{obj}
+	}
+	return objs
+}
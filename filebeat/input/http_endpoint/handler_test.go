@@ -0,0 +1,261 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http_endpoint
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+type testHandler struct {
+	*handler
+	events []beat.Event
+}
+
+func newTestHandler(t *testing.T, settings map[string]interface{}) *testHandler {
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &testHandler{}
+	h.handler = &handler{
+		config: config,
+		log:    logp.NewLogger("http_endpoint"),
+		publish: func(event beat.Event) error {
+			h.events = append(h.events, event)
+			return nil
+		},
+	}
+	return h
+}
+
+func (h *testHandler) post(contentType, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJSONObject(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{
+		"include_headers": []string{"x-github-event", "x-missing"},
+	})
+
+	rec := h.post("application/json; charset=utf-8", `{"action": "opened", "number": 1}`,
+		map[string]string{"X-Github-Event": "pull_request"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"message": "success"}`, rec.Body.String())
+
+	if !assert.Len(t, h.events, 1) {
+		return
+	}
+	assert.Equal(t, common.MapStr{
+		"json": common.MapStr{"action": "opened", "number": int64(1)},
+		"http": common.MapStr{
+			"request": common.MapStr{
+				"headers": common.MapStr{"X-Github-Event": "pull_request"},
+			},
+		},
+	}, h.events[0].Fields)
+}
+
+func TestJSONArray(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{"prefix": ""})
+
+	rec := h.post("application/json", `[{"id": 1}, {"id": 2}]`, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if !assert.Len(t, h.events, 2) {
+		return
+	}
+	assert.Equal(t, common.MapStr{"id": int64(1)}, h.events[0].Fields)
+	assert.Equal(t, common.MapStr{"id": int64(2)}, h.events[1].Fields)
+}
+
+func TestNDJSON(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{})
+
+	rec := h.post("application/x-ndjson", "{\"id\": 1}\n\n{\"id\": 2}\n", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, h.events, 2)
+}
+
+func TestSplitEvents(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{"split_events_by": "records"})
+
+	rec := h.post("application/json", `{"records": [{"id": 1}, {"id": 2}, {"id": 3}]}`, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if !assert.Len(t, h.events, 3) {
+		return
+	}
+	assert.Equal(t, common.MapStr{"json": common.MapStr{"id": int64(3)}}, h.events[2].Fields)
+
+	// objects without the array are published as is
+	h.events = nil
+	h.post("application/json", `{"id": 4}`, nil)
+	assert.Len(t, h.events, 1)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	tests := map[string]struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		"method":       {"GET", "application/json", ``, http.StatusMethodNotAllowed},
+		"content type": {"POST", "text/plain", `hello`, http.StatusUnsupportedMediaType},
+		"invalid json": {"POST", "application/json", `{"id":`, http.StatusBadRequest},
+		"no object":    {"POST", "application/json", `[1, 2]`, http.StatusBadRequest},
+		"too large":    {"POST", "application/json", `{"message": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHandler(t, map[string]interface{}{"max_body_size": 64})
+
+			req := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"message"`)
+			assert.Len(t, h.events, 0)
+		})
+	}
+}
+
+func TestPublishFailure(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{})
+	h.publish = func(beat.Event) error { return errors.New("input outlet closed") }
+
+	rec := h.post("application/json", `{"id": 1}`, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"message": "input outlet closed", "published": 0}`, rec.Body.String())
+}
+
+func TestPartialPublishFailure(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{})
+	published := 0
+	h.publish = func(beat.Event) error {
+		if published == 2 {
+			return errors.New("input outlet closed")
+		}
+		published++
+		return nil
+	}
+
+	rec := h.post("application/json", `[{"id": 1}, {"id": 2}, {"id": 3}]`, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"message": "input outlet closed", "published": 2}`, rec.Body.String())
+}
+
+func TestResponse(t *testing.T) {
+	h := newTestHandler(t, map[string]interface{}{
+		"response_code": 202,
+		"response_body": `{"status": "accepted"}`,
+	})
+
+	rec := h.post("application/json", `{"id": 1}`, nil)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, `{"status": "accepted"}`, rec.Body.String())
+}
+
+func TestAuthentication(t *testing.T) {
+	body := `{"id": 1}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	basic := map[string]interface{}{"basic_auth": true, "username": "user", "password": "pass"}
+	bearer := map[string]interface{}{"bearer_token": "token"}
+	signed := map[string]interface{}{
+		"hmac.header": "X-Hub-Signature-256",
+		"hmac.key":    "secret",
+		"hmac.prefix": "sha256=",
+	}
+
+	tests := map[string]struct {
+		settings map[string]interface{}
+		headers  map[string]string
+		status   int
+	}{
+		"basic auth":           {basic, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusOK},
+		"invalid basic auth":   {basic, map[string]string{"Authorization": "Basic dXNlcjp4"}, http.StatusUnauthorized},
+		"missing basic auth":   {basic, nil, http.StatusUnauthorized},
+		"bearer token":         {bearer, map[string]string{"Authorization": "Bearer token"}, http.StatusOK},
+		"invalid bearer token": {bearer, map[string]string{"Authorization": "Bearer other"}, http.StatusUnauthorized},
+		"hmac":                 {signed, map[string]string{"X-Hub-Signature-256": signature}, http.StatusOK},
+		"invalid hmac":         {signed, map[string]string{"X-Hub-Signature-256": "sha256=00"}, http.StatusUnauthorized},
+		"missing hmac":         {signed, nil, http.StatusUnauthorized},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHandler(t, test.settings)
+			rec := h.post("application/json", body, test.headers)
+			assert.Equal(t, test.status, rec.Code)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid url":          {"url": "webhook"},
+		"basic auth no user":   {"basic_auth": true},
+		"basic auth and token": {"basic_auth": true, "username": "u", "password": "p", "bearer_token": "t"},
+		"hmac without key":     {"hmac.header": "X-Signature"},
+		"invalid hmac type":    {"hmac.header": "X-Signature", "hmac.key": "k", "hmac.type": "md5"},
+		"invalid response":     {"response_code": 42},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http_endpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/filebeat/channel"
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+func init() {
+	err := input.Register("http_endpoint", NewInput)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Input starts a HTTP server, publishing events for all JSON objects received
+// in POST requests.
+type Input struct {
+	sync.Mutex
+	started   bool
+	config    config
+	tlsConfig *transport.TLSConfig
+	outlet    channel.Outleter
+	server    *http.Server
+	log       *logp.Logger
+	wg        sync.WaitGroup
+}
+
+// NewInput creates a new http_endpoint input
+func NewInput(
+	cfg *common.Config,
+	outlet channel.Connector,
+	context input.Context,
+) (input.Input, error) {
+	cfgwarn.Experimental("http_endpoint input is used")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlscommon.LoadTLSServerConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := outlet(cfg, context.DynamicFields)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logp.NewLogger("http_endpoint").With("address", config.address())
+	forwarder := harvester.NewForwarder(out)
+	h := &handler{
+		config: config,
+		log:    log,
+		publish: func(event beat.Event) error {
+			data := util.NewData()
+			data.Event = event
+			return forwarder.Send(data)
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(config.URL, h)
+
+	return &Input{
+		config:    config,
+		tlsConfig: tlsConfig,
+		outlet:    out,
+		server:    &http.Server{Addr: config.address(), Handler: mux},
+		log:       log,
+	}, nil
+}
+
+// Run starts the HTTP server.
+func (p *Input) Run() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.started {
+		return
+	}
+
+	l, err := p.listen()
+	if err != nil {
+		p.log.Errorf("Failed to start HTTP server: %v", err)
+		return
+	}
+
+	p.log.Infof("Starting HTTP server on %v", l.Addr())
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := p.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			p.log.Errorf("HTTP server failed: %v", err)
+		}
+	}()
+	p.started = true
+}
+
+func (p *Input) listen() (net.Listener, error) {
+	if p.tlsConfig != nil {
+		t := p.tlsConfig.BuildModuleConfig(p.config.ListenAddress)
+		return tls.Listen("tcp", p.server.Addr, t)
+	}
+	return net.Listen("tcp", p.server.Addr)
+}
+
+// Stop stops the HTTP server, waiting for active requests to finish.
+func (p *Input) Stop() {
+	defer p.outlet.Close()
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.started {
+		return
+	}
+
+	p.log.Info("Stopping HTTP server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := p.server.Shutdown(ctx); err != nil {
+		p.log.Errorf("Failed to stop HTTP server: %v", err)
+	}
+	p.wg.Wait()
+	p.started = false
+}
+
+// Wait stops the http_endpoint input.
+func (p *Input) Wait() {
+	p.Stop()
+}
@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/filebeat/harvester"
+)
+
+// Seek modes supported by the input.
+const (
+	seekCursor = "cursor"
+	seekHead   = "head"
+	seekTail   = "tail"
+)
+
+// localSystemJournalID is used as registry source when reading the local
+// system journal.
+const localSystemJournalID = "LOCAL_SYSTEM_JOURNAL"
+
+type config struct {
+	harvester.ForwarderConfig `config:",inline"`
+
+	// ID is used to identify the input state in the registry. It must be set
+	// if multiple journald inputs read the same journal.
+	ID string `config:"id"`
+
+	// Paths lists journal directories or files to read from. The local system
+	// journal is read if no path is configured.
+	Paths []string `config:"paths"`
+
+	// Seek selects the position to start reading from, if no cursor has been
+	// stored in the registry or if seek is not set to cursor.
+	Seek string `config:"seek"`
+
+	Units    []string `config:"units"`
+	Priority string   `config:"priority"`
+	Matches  []string `config:"include_matches"`
+
+	// Backoff configures the wait time before journalctl is restarted after
+	// a failure.
+	Backoff backoffConfig `config:"backoff"`
+}
+
+type backoffConfig struct {
+	Init time.Duration `config:"init" validate:"positive,nonzero"`
+	Max  time.Duration `config:"max" validate:"positive,nonzero"`
+}
+
+var defaultConfig = config{
+	ForwarderConfig: harvester.ForwarderConfig{
+		Type: "journald",
+	},
+	Seek: seekCursor,
+	Backoff: backoffConfig{
+		Init: 1 * time.Second,
+		Max:  60 * time.Second,
+	},
+}
+
+var priorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+func (c *config) Validate() error {
+	switch c.Seek {
+	case seekCursor, seekHead, seekTail:
+	default:
+		return fmt.Errorf("invalid seek mode '%v', must be one of cursor, head or tail", c.Seek)
+	}
+
+	if c.Priority != "" && parsePriority(c.Priority) < 0 {
+		return fmt.Errorf("invalid priority '%v'", c.Priority)
+	}
+
+	for _, m := range c.Matches {
+		if m == "+" {
+			continue
+		}
+		if i := strings.IndexByte(m, '='); i <= 0 {
+			return fmt.Errorf("invalid match '%v', must be of the form FIELD=value or +", m)
+		}
+	}
+
+	if c.Backoff.Init > c.Backoff.Max {
+		return fmt.Errorf("backoff.init must not be greater than backoff.max")
+	}
+	return nil
+}
+
+// parsePriority returns the numeric priority for a priority name or number.
+// Returns -1 if the priority is invalid.
+func parsePriority(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n >= len(priorities) {
+			return -1
+		}
+		return n
+	}
+	for i, name := range priorities {
+		if strings.EqualFold(s, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Journal entry fields holding the cursor and the receive timestamp of an
+// entry.
+const (
+	cursorField          = "__CURSOR"
+	realtimeField        = "__REALTIME_TIMESTAMP"
+	sourceRealtimeField  = "_SOURCE_REALTIME_TIMESTAMP"
+	addressFieldsPrefix  = "__"
+	customFieldNamespace = "journald.custom"
+)
+
+type fieldConversion struct {
+	name  string
+	isInt bool
+}
+
+// journaldFields maps well known journal fields to event fields. Journal
+// fields not listed are added to journald.custom.
+var journaldFields = map[string]fieldConversion{
+	"MESSAGE":           {"message", false},
+	"PRIORITY":          {"syslog.priority", true},
+	"SYSLOG_FACILITY":   {"syslog.facility", true},
+	"SYSLOG_IDENTIFIER": {"process.program", false},
+	"SYSLOG_PID":        {"process.pid", true},
+
+	"_PID":     {"process.pid", true},
+	"_COMM":    {"process.name", false},
+	"_EXE":     {"process.executable", false},
+	"_CMDLINE": {"journald.process.command_line", false},
+	"_UID":     {"journald.process.uid", true},
+	"_GID":     {"journald.process.gid", true},
+
+	"_HOSTNAME":   {"host.name", false},
+	"_MACHINE_ID": {"host.id", false},
+	"_BOOT_ID":    {"journald.host.boot_id", false},
+	"_TRANSPORT":  {"journald.transport", false},
+
+	"_SYSTEMD_UNIT":          {"systemd.unit", false},
+	"_SYSTEMD_USER_UNIT":     {"systemd.user_unit", false},
+	"_SYSTEMD_SLICE":         {"systemd.slice", false},
+	"_SYSTEMD_CGROUP":        {"systemd.cgroup", false},
+	"_SYSTEMD_INVOCATION_ID": {"systemd.invocation_id", false},
+
+	"CONTAINER_ID_FULL": {"container.id", false},
+	"CONTAINER_NAME":    {"container.name", false},
+
+	"CODE_FILE": {"journald.code.file", false},
+	"CODE_FUNC": {"journald.code.func", false},
+	"CODE_LINE": {"journald.code.line", true},
+}
+
+// ignoredFields are not added to the event, as the information is available
+// in other fields.
+var ignoredFields = map[string]bool{
+	sourceRealtimeField: true,
+	"CONTAINER_ID":      true, // truncated CONTAINER_ID_FULL
+	"SYSLOG_TIMESTAMP":  true,
+}
+
+// toEvent converts a journal entry, as returned by `journalctl --output=json`,
+// into an event.
+func toEvent(entry map[string]interface{}) beat.Event {
+	fields := common.MapStr{}
+	custom := common.MapStr{}
+
+	// process.pid is set from SYSLOG_PID only, if _PID is not available
+	if _, exists := entry["_PID"]; exists {
+		delete(entry, "SYSLOG_PID")
+	}
+
+	for name, raw := range entry {
+		if strings.HasPrefix(name, addressFieldsPrefix) || ignoredFields[name] {
+			continue
+		}
+
+		value := fieldValue(raw)
+		conv, known := journaldFields[name]
+		if !known {
+			custom[normalizeFieldName(name)] = value
+			continue
+		}
+
+		if s, ok := value.(string); ok && conv.isInt {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				fields.Put(conv.name, n)
+				continue
+			}
+		}
+		fields.Put(conv.name, value)
+	}
+
+	priority, _ := fields.GetValue("syslog.priority")
+	if p, ok := priority.(int64); ok {
+		fields.Put("event.severity", p)
+		if p >= 0 && int(p) < len(priorities) {
+			fields.Put("log.level", priorities[p])
+		}
+	}
+
+	if len(custom) > 0 {
+		fields.Put(customFieldNamespace, custom)
+	}
+
+	return beat.Event{
+		Timestamp: entryTimestamp(entry),
+		Fields:    fields,
+	}
+}
+
+// fieldValue converts a JSON encoded journal field value. Binary values are
+// encoded as array of bytes and fields with multiple values as array of
+// values.
+func fieldValue(raw interface{}) interface{} {
+	values, ok := raw.([]interface{})
+	if !ok {
+		return raw
+	}
+
+	if b, ok := toBytes(values); ok {
+		return string(b)
+	}
+
+	converted := make([]interface{}, len(values))
+	for i, v := range values {
+		converted[i] = fieldValue(v)
+	}
+	return converted
+}
+
+func toBytes(values []interface{}) ([]byte, bool) {
+	b := make([]byte, len(values))
+	for i, v := range values {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n > 255 {
+			return nil, false
+		}
+		b[i] = byte(n)
+	}
+	return b, true
+}
+
+// normalizeFieldName converts a journal field name into a lowercase event
+// field name, removing the leading underscores of trusted fields.
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.TrimLeft(name, "_"))
+}
+
+// entryTimestamp returns the time the entry has been created by the source,
+// falling back to the time the entry has been received by journald.
+func entryTimestamp(entry map[string]interface{}) time.Time {
+	for _, name := range []string{sourceRealtimeField, realtimeField} {
+		s, ok := entry[name].(string)
+		if !ok {
+			continue
+		}
+
+		usec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		return time.Unix(0, usec*int64(time.Microsecond)).UTC()
+	}
+	return time.Now()
+}
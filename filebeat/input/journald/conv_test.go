@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestToEvent(t *testing.T) {
+	var entry map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"__CURSOR": "s=abc;i=1",
+		"__REALTIME_TIMESTAMP": "1528269507000001",
+		"__MONOTONIC_TIMESTAMP": "123",
+		"MESSAGE": "Started Session 1 of user root.",
+		"PRIORITY": "6",
+		"SYSLOG_FACILITY": "3",
+		"SYSLOG_IDENTIFIER": "systemd",
+		"SYSLOG_PID": "12",
+		"_PID": "1",
+		"_COMM": "systemd",
+		"_HOSTNAME": "host-1",
+		"_MACHINE_ID": "c8b0a8a5",
+		"_SYSTEMD_UNIT": "init.scope",
+		"_TRANSPORT": "journal",
+		"CODE_LINE": "42",
+		"USER_ID": "root",
+		"_CAP_EFFECTIVE": "0",
+		"BINARY": [104, 105],
+		"MULTI": ["a", "b"]
+	}`), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := toEvent(entry)
+	assert.Equal(t, time.Unix(1528269507, 1000).UTC(), event.Timestamp)
+	assert.Equal(t, common.MapStr{
+		"message": "Started Session 1 of user root.",
+		"syslog": common.MapStr{
+			"priority": int64(6),
+			"facility": int64(3),
+		},
+		"event": common.MapStr{
+			"severity": int64(6),
+		},
+		"log": common.MapStr{
+			"level": "info",
+		},
+		"process": common.MapStr{
+			"program": "systemd",
+			"pid":     int64(1),
+			"name":    "systemd",
+		},
+		"host": common.MapStr{
+			"name": "host-1",
+			"id":   "c8b0a8a5",
+		},
+		"systemd": common.MapStr{
+			"unit": "init.scope",
+		},
+		"journald": common.MapStr{
+			"transport": "journal",
+			"code": common.MapStr{
+				"line": int64(42),
+			},
+			"custom": common.MapStr{
+				"user_id":       "root",
+				"cap_effective": "0",
+				"binary":        "hi",
+				"multi":         []interface{}{"a", "b"},
+			},
+		},
+	}, event.Fields)
+}
+
+func TestToEventTimestamp(t *testing.T) {
+	event := toEvent(map[string]interface{}{
+		"__REALTIME_TIMESTAMP":       "2000000",
+		"_SOURCE_REALTIME_TIMESTAMP": "1000000",
+		"MESSAGE":                    "test",
+	})
+	assert.Equal(t, time.Unix(1, 0).UTC(), event.Timestamp)
+	assert.Equal(t, common.MapStr{"message": "test"}, event.Fields)
+}
+
+func TestToEventSyslogPID(t *testing.T) {
+	event := toEvent(map[string]interface{}{"SYSLOG_PID": "12"})
+	assert.Equal(t, common.MapStr{"process": common.MapStr{"pid": int64(12)}}, event.Fields)
+}
@@ -0,0 +1,33 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package journald contains the input reading entries from the systemd
+// journal.
+//
+// Journal entries are read using journalctl, which supports all journal file
+// formats written by the installed version of systemd:
+//
+//	journalctl --output=json --follow --after-cursor=<cursor>
+//
+// The cursor of the most recently acknowledged entry is stored in the
+// registry, such that reading continues after the last published entry on
+// restart.
+//
+// Besides the local system journal, journal files written by
+// systemd-journal-remote (by default in /var/log/journal/remote) can be read
+// by configuring the journal directories or files in `paths`.
+package journald
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elastic/beats/filebeat/channel"
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/elastic/beats/filebeat/input/file"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	err := input.Register("journald", NewInput)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Input reads entries from one or more systemd journals.
+type Input struct {
+	sync.Mutex
+	started bool
+	outlet  channel.Outleter
+	readers []*reader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewInput creates a new journald input
+func NewInput(
+	cfg *common.Config,
+	outlet channel.Connector,
+	context input.Context,
+) (input.Input, error) {
+	cfgwarn.Experimental("journald input is used")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	out, err := outlet(cfg, context.DynamicFields)
+	if err != nil {
+		return nil, err
+	}
+
+	forwarder := harvester.NewForwarder(out)
+	paths := config.Paths
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	in := &Input{outlet: out}
+	for _, path := range paths {
+		source := stateSource(config.ID, path)
+
+		cursor := ""
+		if config.Seek == seekCursor {
+			cursor = findCursor(context.States, source)
+		}
+		if cursor != "" {
+			logp.Info("journald: continue reading %v after cursor %v", source, cursor)
+		}
+
+		in.readers = append(in.readers, newReader(source, path, config, forwarder, cursor))
+	}
+	return in, nil
+}
+
+// stateSource returns the registry source identifying the journal.
+func stateSource(id, path string) string {
+	if path == "" {
+		path = localSystemJournalID
+	}
+	if id == "" {
+		return "journald::" + path
+	}
+	return "journald::" + id + "::" + path
+}
+
+// findCursor returns the cursor stored in the registry for source.
+func findCursor(states []file.State, source string) string {
+	for _, state := range states {
+		if state.Source == source && state.Cursor != "" {
+			return state.Cursor
+		}
+	}
+	return ""
+}
+
+// Run starts reading all configured journals.
+func (p *Input) Run() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.started {
+		return
+	}
+
+	logp.Info("Starting journald input")
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	for _, r := range p.readers {
+		p.wg.Add(1)
+		go func(r *reader) {
+			defer p.wg.Done()
+			r.run(p.ctx)
+		}(r)
+	}
+	p.started = true
+}
+
+// Stop stops all journal readers and closes the outlet.
+func (p *Input) Stop() {
+	defer p.outlet.Close()
+	p.Lock()
+	defer p.Unlock()
+
+	logp.Info("Stopping journald input")
+	if p.started {
+		p.cancel()
+		p.wg.Wait()
+	}
+	p.started = false
+}
+
+// Wait stops the journald input.
+func (p *Input) Wait() {
+	p.Stop()
+}
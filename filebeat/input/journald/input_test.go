@@ -0,0 +1,284 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input/file"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// TestJournalctlHelper is not a real test. It is executed as fake journalctl
+// process, printing the entries passed via the environment.
+func TestJournalctlHelper(t *testing.T) {
+	if os.Getenv("JOURNALD_TEST_HELPER") != "1" {
+		return
+	}
+
+	fmt.Print(os.Getenv("JOURNALD_TEST_OUTPUT"))
+	if os.Getenv("JOURNALD_TEST_FOLLOW") == "1" {
+		time.Sleep(time.Hour)
+	}
+	os.Exit(0)
+}
+
+type fakeJournalctl struct {
+	mutex  sync.Mutex
+	calls  [][]string
+	output string
+	follow bool
+}
+
+func (f *fakeJournalctl) install() func() {
+	orig := newCommand
+	newCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		f.calls = append(f.calls, args)
+
+		follow := "0"
+		if f.follow {
+			follow = "1"
+		}
+
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestJournalctlHelper")
+		cmd.Env = append(os.Environ(),
+			"JOURNALD_TEST_HELPER=1",
+			"JOURNALD_TEST_OUTPUT="+f.output,
+			"JOURNALD_TEST_FOLLOW="+follow,
+		)
+		return cmd
+	}
+	return func() { newCommand = orig }
+}
+
+func (f *fakeJournalctl) getCalls() [][]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls
+}
+
+type testOutlet struct {
+	mutex sync.Mutex
+	data  []*util.Data
+}
+
+func (o *testOutlet) OnEvent(d *util.Data) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.data = append(o.data, d)
+	return true
+}
+
+func (o *testOutlet) events() []*util.Data {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data
+}
+
+func testConfig(t *testing.T, settings map[string]interface{}) config {
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timeout waiting for condition")
+		}
+	}
+}
+
+func TestReaderPublishesCursorState(t *testing.T) {
+	journalctl := &fakeJournalctl{
+		output: `{"__CURSOR": "c1", "MESSAGE": "first"}` + "\n" +
+			`not json` + "\n" +
+			`{"__CURSOR": "c2", "MESSAGE": "second"}` + "\n",
+		follow: true,
+	}
+	defer journalctl.install()()
+
+	outlet := &testOutlet{}
+	config := testConfig(t, map[string]interface{}{})
+	r := newReader("journald::LOCAL_SYSTEM_JOURNAL", "", config, harvester.NewForwarder(outlet), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.run(ctx)
+	}()
+
+	waitFor(t, func() bool { return len(outlet.events()) == 2 })
+	cancel()
+	<-done
+
+	data := outlet.events()
+	assert.Equal(t, "first", data[0].Event.Fields["message"])
+	assert.Equal(t, "c1", data[0].GetState().Cursor)
+	assert.Equal(t, "second", data[1].Event.Fields["message"])
+
+	state := data[1].GetState()
+	assert.Equal(t, "c2", state.Cursor)
+	assert.Equal(t, "journald::LOCAL_SYSTEM_JOURNAL", state.Source)
+	assert.Equal(t, "journald", state.Type)
+
+	// the state ID must not change with the cursor
+	first := data[0].GetState()
+	assert.Equal(t, first.ID(), state.ID())
+}
+
+func TestReaderRestartsAfterCursor(t *testing.T) {
+	journalctl := &fakeJournalctl{output: `{"__CURSOR": "c1", "MESSAGE": "first"}` + "\n"}
+	defer journalctl.install()()
+
+	outlet := &testOutlet{}
+	config := testConfig(t, map[string]interface{}{
+		"backoff.init": "1ms",
+		"backoff.max":  "1ms",
+	})
+	r := newReader("journald::LOCAL_SYSTEM_JOURNAL", "", config, harvester.NewForwarder(outlet), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.run(ctx)
+	}()
+
+	waitFor(t, func() bool { return len(journalctl.getCalls()) >= 2 })
+	cancel()
+	<-done
+
+	calls := journalctl.getCalls()
+	assert.Contains(t, calls[0], "--no-tail")
+	assert.NotContains(t, strings.Join(calls[0], " "), "--after-cursor")
+	assert.Contains(t, calls[1], "--after-cursor=c1")
+}
+
+func TestReaderArgs(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		settings map[string]interface{}
+		path     string
+		cursor   string
+		expected []string
+	}{
+		"defaults": {
+			settings: map[string]interface{}{},
+			expected: []string{"--no-tail"},
+		},
+		"cursor": {
+			settings: map[string]interface{}{},
+			cursor:   "c1",
+			expected: []string{"--after-cursor=c1", "--no-tail"},
+		},
+		"tail": {
+			settings: map[string]interface{}{"seek": "tail"},
+			expected: []string{"--lines=0"},
+		},
+		"directory": {
+			settings: map[string]interface{}{},
+			path:     dir,
+			expected: []string{"--directory=" + dir, "--no-tail"},
+		},
+		"file": {
+			settings: map[string]interface{}{},
+			path:     "/var/log/journal/remote/*.journal",
+			expected: []string{"--file=/var/log/journal/remote/*.journal", "--no-tail"},
+		},
+		"filters": {
+			settings: map[string]interface{}{
+				"units":           []string{"sshd.service", "cron.service"},
+				"priority":        "warning",
+				"include_matches": []string{"_UID=0", "+", "_COMM=sudo"},
+			},
+			expected: []string{
+				"--no-tail", "--unit=sshd.service", "--unit=cron.service",
+				"--priority=4", "_UID=0", "+", "_COMM=sudo",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testConfig(t, test.settings)
+			r := newReader("journald::test", test.path, config, nil, test.cursor)
+
+			expected := append([]string{"--output=json", "--all", "--follow", "--no-pager"}, test.expected...)
+			assert.Equal(t, expected, r.args())
+		})
+	}
+}
+
+func TestFindCursor(t *testing.T) {
+	states := []file.State{
+		{Source: "/var/log/messages", Offset: 100},
+		{Source: "journald::other", Cursor: "c1"},
+		{Source: "journald::LOCAL_SYSTEM_JOURNAL", Cursor: "c2"},
+	}
+
+	assert.Equal(t, "c2", findCursor(states, stateSource("", "")))
+	assert.Equal(t, "", findCursor(states, stateSource("id", "")))
+	assert.Equal(t, "journald::id::/var/log/journal", stateSource("id", "/var/log/journal"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid seek":     {"seek": "middle"},
+		"invalid priority": {"priority": "verbose"},
+		"invalid match":    {"include_matches": []string{"_COMM"}},
+		"invalid backoff":  {"backoff.init": "2m", "backoff.max": "1m"},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
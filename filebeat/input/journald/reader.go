@@ -0,0 +1,220 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package journald
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input/file"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// newCommand creates the journalctl command. Overwritten in tests.
+var newCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "journalctl", args...)
+}
+
+// reader reads all entries from a single journal, restarting journalctl with
+// backoff on failure.
+type reader struct {
+	source    string // registry source of the journal
+	path      string // journal directory or file, empty for the local journal
+	config    config
+	forwarder *harvester.Forwarder
+	log       *logp.Logger
+
+	cursor string // cursor of the most recently published entry
+}
+
+func newReader(
+	source, path string,
+	config config,
+	forwarder *harvester.Forwarder,
+	cursor string,
+) *reader {
+	return &reader{
+		source:    source,
+		path:      path,
+		config:    config,
+		forwarder: forwarder,
+		log:       logp.NewLogger("journald").With("journal", source),
+		cursor:    cursor,
+	}
+}
+
+// run reads entries until ctx is cancelled or the outlet has been closed.
+func (r *reader) run(ctx context.Context) {
+	backoff := r.config.Backoff.Init
+	for {
+		published, err := r.read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == errOutletClosed {
+			return
+		}
+
+		if published > 0 {
+			backoff = r.config.Backoff.Init
+		}
+		r.log.Errorf("Reading journal failed, restarting journalctl in %v: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > r.config.Backoff.Max {
+			backoff = r.config.Backoff.Max
+		}
+	}
+}
+
+var errOutletClosed = fmt.Errorf("input outlet closed")
+
+// read runs journalctl until it exits, returning the number of published
+// entries.
+func (r *reader) read(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := newCommand(ctx, r.args()...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	r.log.Debugf("Starting journalctl %v", cmd.Args[1:])
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	published, readErr := r.readEntries(stdout)
+	if readErr != nil {
+		// kill journalctl, as no more entries will be read
+		cancel()
+	}
+
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return published, readErr
+	}
+	if waitErr != nil {
+		return published, fmt.Errorf("journalctl failed: %v", waitErr)
+	}
+	return published, fmt.Errorf("journalctl exited")
+}
+
+func (r *reader) readEntries(in io.Reader) (int, error) {
+	published := 0
+	buf := bufio.NewReader(in)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(line, &entry); err != nil {
+				r.log.Errorf("Failed to decode journal entry: %v", err)
+				continue
+			}
+
+			if err := r.publish(entry); err != nil {
+				return published, err
+			}
+			published++
+		}
+
+		if err == io.EOF {
+			return published, nil
+		}
+		if err != nil {
+			return published, err
+		}
+	}
+}
+
+func (r *reader) publish(entry map[string]interface{}) error {
+	cursor, _ := entry[cursorField].(string)
+
+	data := util.NewData()
+	data.Event = toEvent(entry)
+	if cursor != "" {
+		r.cursor = cursor
+		data.SetState(r.state())
+	}
+
+	if err := r.forwarder.Send(data); err != nil {
+		return errOutletClosed
+	}
+	return nil
+}
+
+// state returns the registry state storing the current cursor.
+func (r *reader) state() file.State {
+	return file.State{
+		Source:    r.source,
+		Cursor:    r.cursor,
+		Timestamp: time.Now(),
+		TTL:       -1,
+		Type:      r.config.Type,
+		Meta:      map[string]string{"journald": r.source},
+	}
+}
+
+// args returns the journalctl arguments for reading the journal after the
+// current cursor. The seek mode is applied only if no cursor is available.
+func (r *reader) args() []string {
+	args := []string{"--output=json", "--all", "--follow", "--no-pager"}
+
+	if r.path != "" {
+		if info, err := os.Stat(r.path); err == nil && info.IsDir() {
+			args = append(args, "--directory="+r.path)
+		} else {
+			args = append(args, "--file="+r.path)
+		}
+	}
+
+	switch {
+	case r.cursor != "":
+		args = append(args, "--after-cursor="+r.cursor, "--no-tail")
+	case r.config.Seek == seekTail:
+		args = append(args, "--lines=0")
+	default:
+		args = append(args, "--no-tail")
+	}
+
+	for _, unit := range r.config.Units {
+		args = append(args, "--unit="+unit)
+	}
+	if r.config.Priority != "" {
+		args = append(args, "--priority="+strconv.Itoa(parsePriority(r.config.Priority)))
+	}
+	return append(args, r.config.Matches...)
+}
@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import "sort"
+
+// groupPlan maps member IDs to the partitions per topic assigned to a member.
+type groupPlan map[string]map[string][]int32
+
+// balanceStrategy computes the partition assignments for all group members.
+// The group leader runs the strategy, with members mapping member IDs to the
+// topics the member subscribed to. The partitions must be sorted.
+type balanceStrategy func(members map[string][]string, partitions map[string][]int32) groupPlan
+
+// balanceStrategies lists the supported rebalance strategies. The names are
+// used as protocol names in the JoinGroup request, and are compatible with
+// the strategies used by other Kafka clients.
+var balanceStrategies = map[string]balanceStrategy{
+	"range":      balanceRange,
+	"roundrobin": balanceRoundRobin,
+}
+
+func (p groupPlan) add(memberID, topic string, partitions ...int32) {
+	topics := p[memberID]
+	if topics == nil {
+		topics = map[string][]int32{}
+		p[memberID] = topics
+	}
+	topics[topic] = append(topics[topic], partitions...)
+}
+
+// balanceRange assigns each member a consecutive range of partitions per
+// topic. If the partitions can not be evenly distributed, the first members
+// (sorted by member ID) get one additional partition.
+func balanceRange(members map[string][]string, partitions map[string][]int32) groupPlan {
+	plan := groupPlan{}
+	for topic, memberIDs := range topicMembers(members) {
+		parts := partitions[topic]
+		n := len(parts) / len(memberIDs)
+		extra := len(parts) % len(memberIDs)
+
+		start := 0
+		for i, memberID := range memberIDs {
+			count := n
+			if i < extra {
+				count++
+			}
+			if count > 0 {
+				plan.add(memberID, topic, parts[start:start+count]...)
+			}
+			start += count
+		}
+	}
+	return plan
+}
+
+// balanceRoundRobin assigns all partitions of all topics one by one to the
+// members subscribed to the partitions topic.
+func balanceRoundRobin(members map[string][]string, partitions map[string][]int32) groupPlan {
+	plan := groupPlan{}
+	subscriptions := topicMembers(members)
+
+	memberIDs := make([]string, 0, len(members))
+	for memberID := range members {
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Strings(memberIDs)
+
+	topics := make([]string, 0, len(subscriptions))
+	for topic := range subscriptions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	i := 0
+	for _, topic := range topics {
+		for _, partition := range partitions[topic] {
+			for tries := 0; tries < len(memberIDs); tries++ {
+				memberID := memberIDs[i%len(memberIDs)]
+				i++
+				if contains(subscriptions[topic], memberID) {
+					plan.add(memberID, topic, partition)
+					break
+				}
+			}
+		}
+	}
+	return plan
+}
+
+// topicMembers returns the sorted list of member IDs per topic.
+func topicMembers(members map[string][]string) map[string][]string {
+	topics := map[string][]string{}
+	for memberID, subscribed := range members {
+		for _, topic := range subscribed {
+			if !contains(topics[topic], memberID) {
+				topics[topic] = append(topics[topic], memberID)
+			}
+		}
+	}
+	for _, memberIDs := range topics {
+		sort.Strings(memberIDs)
+	}
+	return topics
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
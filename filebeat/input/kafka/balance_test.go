@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceRange(t *testing.T) {
+	members := map[string][]string{
+		"a": {"t1", "t2"},
+		"b": {"t1", "t2"},
+		"c": {"t2"},
+	}
+	partitions := map[string][]int32{
+		"t1": {0, 1, 2},
+		"t2": {0, 1, 2, 3},
+	}
+
+	plan := balanceRange(members, partitions)
+	assert.Equal(t, groupPlan{
+		"a": {"t1": {0, 1}, "t2": {0, 1}},
+		"b": {"t1": {2}, "t2": {2}},
+		"c": {"t2": {3}},
+	}, plan)
+}
+
+func TestBalanceRangeMoreMembersThanPartitions(t *testing.T) {
+	members := map[string][]string{
+		"a": {"t1"},
+		"b": {"t1"},
+		"c": {"t1"},
+	}
+	partitions := map[string][]int32{
+		"t1": {0, 1},
+	}
+
+	plan := balanceRange(members, partitions)
+	assert.Equal(t, groupPlan{
+		"a": {"t1": {0}},
+		"b": {"t1": {1}},
+	}, plan)
+}
+
+func TestBalanceRoundRobin(t *testing.T) {
+	members := map[string][]string{
+		"a": {"t1", "t2"},
+		"b": {"t1", "t2"},
+		"c": {"t2"},
+	}
+	partitions := map[string][]int32{
+		"t1": {0, 1, 2},
+		"t2": {0, 1, 2},
+	}
+
+	plan := balanceRoundRobin(members, partitions)
+	assert.Equal(t, groupPlan{
+		"a": {"t1": {0, 2}, "t2": {2}},
+		"b": {"t1": {1}, "t2": {0}},
+		"c": {"t2": {1}},
+	}, plan)
+}
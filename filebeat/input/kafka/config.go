@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/libbeat/common/kafka"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	harvester.ForwarderConfig `config:",inline"`
+
+	Hosts    []string `config:"hosts" validate:"required"`
+	Topics   []string `config:"topics" validate:"required"`
+	GroupID  string   `config:"group_id" validate:"required"`
+	ClientID string   `config:"client_id"`
+	Version  string   `config:"version"`
+
+	// InitialOffset selects where to start consuming partitions without a
+	// committed offset for the consumer group.
+	InitialOffset string          `config:"initial_offset"`
+	Rebalance     rebalanceConfig `config:"rebalance"`
+
+	SessionTimeout    time.Duration `config:"session_timeout" validate:"positive,nonzero"`
+	HeartbeatInterval time.Duration `config:"heartbeat_interval" validate:"positive,nonzero"`
+
+	// CommitInterval configures how often offsets of ACKed events are
+	// committed to the group coordinator.
+	CommitInterval time.Duration `config:"commit_interval" validate:"positive,nonzero"`
+
+	// WaitClose is the maximum time to wait for outstanding ACKs before the
+	// partitions are released on rebalance or shutdown.
+	WaitClose time.Duration `config:"wait_close" validate:"min=0"`
+
+	ConnectBackoff time.Duration `config:"connect_backoff" validate:"positive,nonzero"`
+	ConsumeBackoff time.Duration `config:"consume_backoff" validate:"positive,nonzero"`
+	MaxWaitTime    time.Duration `config:"max_wait_time" validate:"positive,nonzero"`
+	Fetch          fetchConfig   `config:"fetch"`
+
+	TLS      *tlscommon.Config `config:"ssl"`
+	Username string            `config:"username"`
+	Password string            `config:"password"`
+}
+
+type rebalanceConfig struct {
+	Strategy     string        `config:"strategy"`
+	RetryBackoff time.Duration `config:"retry_backoff" validate:"positive,nonzero"`
+}
+
+type fetchConfig struct {
+	Min     int32 `config:"min" validate:"min=1"`
+	Default int32 `config:"default" validate:"min=1"`
+	Max     int32 `config:"max" validate:"min=0"`
+}
+
+const (
+	offsetOldest = "oldest"
+	offsetNewest = "newest"
+)
+
+var defaultConfig = config{
+	ForwarderConfig: harvester.ForwarderConfig{
+		Type: "kafka",
+	},
+	ClientID:      "filebeat",
+	Version:       "1.0.0",
+	InitialOffset: offsetOldest,
+	Rebalance: rebalanceConfig{
+		Strategy:     "range",
+		RetryBackoff: 2 * time.Second,
+	},
+	SessionTimeout:    10 * time.Second,
+	HeartbeatInterval: 3 * time.Second,
+	CommitInterval:    1 * time.Second,
+	WaitClose:         5 * time.Second,
+	ConnectBackoff:    30 * time.Second,
+	ConsumeBackoff:    2 * time.Second,
+	MaxWaitTime:       250 * time.Millisecond,
+	Fetch: fetchConfig{
+		Min:     1,
+		Default: 1024 * 1024,
+		Max:     0,
+	},
+}
+
+func (c *config) Validate() error {
+	version, ok := kafka.ParseVersion(c.Version)
+	if !ok {
+		return fmt.Errorf("unknown/unsupported kafka version '%v'", c.Version)
+	}
+	if !version.IsAtLeast(sarama.V0_9_0_0) {
+		return fmt.Errorf("kafka version '%v' does not support consumer groups, 0.9.0.0 or newer is required", c.Version)
+	}
+
+	switch c.InitialOffset {
+	case offsetOldest, offsetNewest:
+	default:
+		return fmt.Errorf("invalid initial_offset '%v', must be one of oldest or newest", c.InitialOffset)
+	}
+
+	if _, exists := balanceStrategies[c.Rebalance.Strategy]; !exists {
+		return fmt.Errorf("invalid rebalance.strategy '%v', must be one of range or roundrobin", c.Rebalance.Strategy)
+	}
+
+	if c.HeartbeatInterval >= c.SessionTimeout {
+		return errors.New("heartbeat_interval must be less than session_timeout")
+	}
+
+	if c.Username != "" && c.Password == "" {
+		return errors.New("password must be set when username is configured")
+	}
+	return nil
+}
+
+func newSaramaConfig(config config) (*sarama.Config, error) {
+	k := sarama.NewConfig()
+
+	version, _ := kafka.ParseVersion(config.Version)
+	k.Version = version
+	k.ClientID = config.ClientID
+
+	// JoinGroup requests block for up to the session timeout while the group
+	// is rebalancing.
+	if k.Net.ReadTimeout <= config.SessionTimeout {
+		k.Net.ReadTimeout = config.SessionTimeout + 5*time.Second
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if tls != nil {
+		k.Net.TLS.Enable = true
+		k.Net.TLS.Config = tls.BuildModuleConfig("")
+	}
+
+	if config.Username != "" {
+		k.Net.SASL.Enable = true
+		k.Net.SASL.User = config.Username
+		k.Net.SASL.Password = config.Password
+	}
+
+	k.Consumer.Return.Errors = true
+	k.Consumer.MaxWaitTime = config.MaxWaitTime
+	k.Consumer.Retry.Backoff = config.ConsumeBackoff
+	k.Consumer.Fetch.Min = config.Fetch.Min
+	k.Consumer.Fetch.Default = config.Fetch.Default
+	k.Consumer.Fetch.Max = config.Fetch.Max
+	k.Consumer.Offsets.Initial = sarama.OffsetOldest
+	if config.InitialOffset == offsetNewest {
+		k.Consumer.Offsets.Initial = sarama.OffsetNewest
+	}
+
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
@@ -0,0 +1,302 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const groupProtocolType = "consumer"
+
+// groupMember implements the Kafka group membership protocol. On join the
+// member registers with the group coordinator, computes the group wide
+// partition assignment if elected as leader, and receives its own
+// assignment. Heartbeats must be sent regularly to stay in the group, until
+// the coordinator signals a rebalance.
+type groupMember struct {
+	client         sarama.Client
+	groupID        string
+	topics         []string
+	strategy       string
+	sessionTimeout time.Duration
+	log            *logp.Logger
+
+	memberID   string
+	generation int32
+}
+
+func newGroupMember(client sarama.Client, config config, log *logp.Logger) *groupMember {
+	return &groupMember{
+		client:         client,
+		groupID:        config.GroupID,
+		topics:         config.Topics,
+		strategy:       config.Rebalance.Strategy,
+		sessionTimeout: config.SessionTimeout,
+		log:            log,
+	}
+}
+
+// join joins the consumer group and returns the partitions per topic
+// assigned to this member.
+func (m *groupMember) join() (map[string][]int32, error) {
+	broker, err := m.client.Coordinator(m.groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	joinReq := &sarama.JoinGroupRequest{
+		GroupId:        m.groupID,
+		SessionTimeout: int32(m.sessionTimeout / time.Millisecond),
+		MemberId:       m.memberID,
+		ProtocolType:   groupProtocolType,
+	}
+	meta := &sarama.ConsumerGroupMemberMetadata{Topics: m.topics}
+	if err := joinReq.AddGroupProtocolMetadata(m.strategy, meta); err != nil {
+		return nil, err
+	}
+
+	joinResp, err := broker.JoinGroup(joinReq)
+	if err != nil {
+		m.client.RefreshCoordinator(m.groupID)
+		return nil, err
+	}
+	if err := m.handleError(joinResp.Err); err != nil {
+		return nil, fmt.Errorf("failed to join group: %v", err)
+	}
+
+	m.memberID = joinResp.MemberId
+	m.generation = joinResp.GenerationId
+
+	var plan groupPlan
+	if joinResp.LeaderId == joinResp.MemberId {
+		members, err := joinResp.GetMembers()
+		if err != nil {
+			return nil, err
+		}
+
+		plan, err = m.balance(members)
+		if err != nil {
+			return nil, err
+		}
+		m.log.Debugw("Computed group assignment as group leader",
+			"generation", m.generation, "members", len(members))
+	}
+
+	syncReq := &sarama.SyncGroupRequest{
+		GroupId:      m.groupID,
+		GenerationId: m.generation,
+		MemberId:     m.memberID,
+	}
+	for memberID, topics := range plan {
+		assignment := &sarama.ConsumerGroupMemberAssignment{Topics: topics}
+		if err := syncReq.AddGroupAssignmentMember(memberID, assignment); err != nil {
+			return nil, err
+		}
+	}
+
+	syncResp, err := broker.SyncGroup(syncReq)
+	if err != nil {
+		m.client.RefreshCoordinator(m.groupID)
+		return nil, err
+	}
+	if err := m.handleError(syncResp.Err); err != nil {
+		return nil, fmt.Errorf("failed to sync group: %v", err)
+	}
+
+	assignment, err := syncResp.GetMemberAssignment()
+	if err != nil {
+		return nil, err
+	}
+	return assignment.Topics, nil
+}
+
+// balance computes the partition assignment for all members of the group.
+func (m *groupMember) balance(members map[string]sarama.ConsumerGroupMemberMetadata) (groupPlan, error) {
+	subscriptions := make(map[string][]string, len(members))
+	partitions := map[string][]int32{}
+	for memberID, meta := range members {
+		subscriptions[memberID] = meta.Topics
+		for _, topic := range meta.Topics {
+			if _, exists := partitions[topic]; exists {
+				continue
+			}
+
+			ids, err := m.client.Partitions(topic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get partitions for topic %v: %v", topic, err)
+			}
+			ids = append([]int32(nil), ids...)
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			partitions[topic] = ids
+		}
+	}
+
+	return balanceStrategies[m.strategy](subscriptions, partitions), nil
+}
+
+// heartbeat signals the coordinator the member is still alive. An error is
+// returned if the member must rejoin the group.
+func (m *groupMember) heartbeat() error {
+	broker, err := m.client.Coordinator(m.groupID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := broker.Heartbeat(&sarama.HeartbeatRequest{
+		GroupId:      m.groupID,
+		GenerationId: m.generation,
+		MemberId:     m.memberID,
+	})
+	if err != nil {
+		m.client.RefreshCoordinator(m.groupID)
+		return err
+	}
+	return m.handleError(resp.Err)
+}
+
+// leave removes the member from the group, such that the partitions can be
+// reassigned without waiting for the session to time out.
+func (m *groupMember) leave() error {
+	if m.memberID == "" {
+		return nil
+	}
+
+	broker, err := m.client.Coordinator(m.groupID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := broker.LeaveGroup(&sarama.LeaveGroupRequest{
+		GroupId:  m.groupID,
+		MemberId: m.memberID,
+	})
+	m.memberID = ""
+	if err != nil {
+		return err
+	}
+	if resp.Err != sarama.ErrNoError {
+		return resp.Err
+	}
+	return nil
+}
+
+// fetchOffsets returns the offsets committed for the consumer group. The
+// offset is -1 for partitions without committed offset.
+func (m *groupMember) fetchOffsets(assignment map[string][]int32) (map[string]map[int32]int64, error) {
+	broker, err := m.client.Coordinator(m.groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &sarama.OffsetFetchRequest{Version: 1, ConsumerGroup: m.groupID}
+	for topic, partitions := range assignment {
+		for _, partition := range partitions {
+			req.AddPartition(topic, partition)
+		}
+	}
+
+	resp, err := broker.FetchOffset(req)
+	if err != nil {
+		m.client.RefreshCoordinator(m.groupID)
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]int64, len(assignment))
+	for topic, partitions := range assignment {
+		offsets[topic] = make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			offset := int64(-1)
+			if block := resp.GetBlock(topic, partition); block != nil {
+				if block.Err != sarama.ErrNoError {
+					return nil, fmt.Errorf("failed to fetch offset for %v/%v: %v", topic, partition, block.Err)
+				}
+				offset = block.Offset
+			}
+			offsets[topic][partition] = offset
+		}
+	}
+	return offsets, nil
+}
+
+// commit commits the offsets of all partitions with new ACKed messages.
+func (m *groupMember) commit(partitions []*partitionOffsets) error {
+	req := &sarama.OffsetCommitRequest{
+		Version:                 2,
+		ConsumerGroup:           m.groupID,
+		ConsumerGroupGeneration: m.generation,
+		ConsumerID:              m.memberID,
+		RetentionTime:           -1,
+	}
+
+	var committed []*partitionOffsets
+	var offsets []int64
+	for _, p := range partitions {
+		if offset, ok := p.commitOffset(); ok {
+			req.AddBlock(p.topic, p.partition, offset, 0, "")
+			committed = append(committed, p)
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(committed) == 0 {
+		return nil
+	}
+
+	broker, err := m.client.Coordinator(m.groupID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := broker.CommitOffset(req)
+	if err != nil {
+		m.client.RefreshCoordinator(m.groupID)
+		return err
+	}
+
+	var firstErr error
+	for i, p := range committed {
+		kerr := resp.Errors[p.topic][p.partition]
+		if kerr == sarama.ErrNoError {
+			p.markCommitted(offsets[i])
+			continue
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to commit offset for %v/%v: %v", p.topic, p.partition, kerr)
+		}
+	}
+	return firstErr
+}
+
+// handleError updates the member state based on the error code returned by
+// the coordinator.
+func (m *groupMember) handleError(kerr sarama.KError) error {
+	switch kerr {
+	case sarama.ErrNoError:
+		return nil
+	case sarama.ErrUnknownMemberId:
+		m.memberID = ""
+	case sarama.ErrNotCoordinatorForConsumer, sarama.ErrConsumerCoordinatorNotAvailable:
+		m.client.RefreshCoordinator(m.groupID)
+	}
+	return kerr
+}
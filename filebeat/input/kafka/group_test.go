@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const testGroup = "test-group"
+
+func encodeMemberMetadata(t *testing.T, topics ...string) []byte {
+	req := &sarama.JoinGroupRequest{}
+	if err := req.AddGroupProtocolMetadata("range", &sarama.ConsumerGroupMemberMetadata{Topics: topics}); err != nil {
+		t.Fatal(err)
+	}
+	return req.OrderedGroupProtocols[0].Metadata
+}
+
+func encodeMemberAssignment(t *testing.T, topics map[string][]int32) []byte {
+	req := &sarama.SyncGroupRequest{}
+	if err := req.AddGroupAssignmentMember("m", &sarama.ConsumerGroupMemberAssignment{Topics: topics}); err != nil {
+		t.Fatal(err)
+	}
+	return req.GroupAssignments["m"]
+}
+
+func newTestGroupMember(t *testing.T, broker *sarama.MockBroker) *groupMember {
+	kafkaConfig := sarama.NewConfig()
+	kafkaConfig.Version = sarama.V0_10_0_0
+	kafkaConfig.Metadata.Retry.Max = 0
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, kafkaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	config.GroupID = testGroup
+	config.Topics = []string{"test"}
+	return newGroupMember(client, config, logp.NewLogger("kafka"))
+}
+
+func TestGroupMemberJoinAsLeader(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	assignment := map[string][]int32{"test": {0, 1}}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("test", 0, broker.BrokerID()).
+			SetLeader("test", 1, broker.BrokerID()).
+			SetLeader("test", 2, broker.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(t).
+			SetCoordinator(testGroup, broker),
+		"JoinGroupRequest": sarama.NewMockWrapper(&sarama.JoinGroupResponse{
+			GenerationId:  3,
+			GroupProtocol: "range",
+			LeaderId:      "member-a",
+			MemberId:      "member-a",
+			Members: map[string][]byte{
+				"member-a": encodeMemberMetadata(t, "test"),
+				"member-b": encodeMemberMetadata(t, "test"),
+			},
+		}),
+		"SyncGroupRequest": sarama.NewMockWrapper(&sarama.SyncGroupResponse{
+			MemberAssignment: encodeMemberAssignment(t, assignment),
+		}),
+	})
+
+	member := newTestGroupMember(t, broker)
+	defer member.client.Close()
+
+	assigned, err := member.join()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, assignment, assigned)
+	assert.Equal(t, "member-a", member.memberID)
+	assert.Equal(t, int32(3), member.generation)
+
+	var syncReq *sarama.SyncGroupRequest
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.SyncGroupRequest); ok {
+			syncReq = req
+		}
+	}
+	if !assert.NotNil(t, syncReq) {
+		return
+	}
+	assert.Equal(t, int32(3), syncReq.GenerationId)
+	assert.Equal(t, encodeMemberAssignment(t, map[string][]int32{"test": {0, 1}}), syncReq.GroupAssignments["member-a"])
+	assert.Equal(t, encodeMemberAssignment(t, map[string][]int32{"test": {2}}), syncReq.GroupAssignments["member-b"])
+}
+
+func TestGroupMemberJoinUnknownMember(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(t).
+			SetCoordinator(testGroup, broker),
+		"JoinGroupRequest": sarama.NewMockWrapper(&sarama.JoinGroupResponse{
+			Err: sarama.ErrUnknownMemberId,
+		}),
+	})
+
+	member := newTestGroupMember(t, broker)
+	defer member.client.Close()
+	member.memberID = "expired"
+
+	_, err := member.join()
+	assert.Error(t, err)
+	assert.Equal(t, "", member.memberID)
+}
+
+func TestGroupMemberOffsets(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(t).
+			SetCoordinator(testGroup, broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(testGroup, "test", 0, 42, "", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t).
+			SetError(testGroup, "test", 1, sarama.ErrIllegalGeneration),
+	})
+
+	member := newTestGroupMember(t, broker)
+	defer member.client.Close()
+
+	offsets, err := member.fetchOffsets(map[string][]int32{"test": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]map[int32]int64{"test": {0: 42, 1: -1}}, offsets)
+
+	p0 := newPartitionOffsets("test", 0, 42)
+	p0.published(42).ACK()
+	p1 := newPartitionOffsets("test", 1, -1)
+	p1.published(0).ACK()
+	p2 := newPartitionOffsets("test", 2, -1)
+	p2.published(0)
+
+	err = member.commit([]*partitionOffsets{p0, p1, p2})
+	assert.Error(t, err)
+
+	_, ok := p0.commitOffset()
+	assert.False(t, ok, "committed offset must be marked")
+	offset, ok := p1.commitOffset()
+	assert.True(t, ok, "failed commit must be retried")
+	assert.Equal(t, int64(1), offset)
+}
@@ -0,0 +1,385 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/elastic/beats/filebeat/channel"
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	err := input.Register("kafka", NewInput)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Input consumes topics as member of a Kafka consumer group. Offsets are
+// committed only after the events have been ACKed by the output.
+type Input struct {
+	sync.Mutex
+	started      bool
+	config       config
+	saramaConfig *sarama.Config
+	outlet       channel.Outleter
+	log          *logp.Logger
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewInput creates a new kafka input
+func NewInput(
+	cfg *common.Config,
+	outlet channel.Connector,
+	context input.Context,
+) (input.Input, error) {
+	cfgwarn.Experimental("kafka input is used")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	saramaConfig, err := newSaramaConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := outlet(cfg, context.DynamicFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Input{
+		config:       config,
+		saramaConfig: saramaConfig,
+		outlet:       out,
+		log:          logp.NewLogger("kafka").With("group_id", config.GroupID),
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Run starts consuming the configured topics.
+func (p *Input) Run() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.started {
+		return
+	}
+
+	p.log.Infof("Starting kafka input for topics %v", p.config.Topics)
+	p.wg.Add(1)
+	go p.run()
+	p.started = true
+}
+
+// Stop leaves the consumer group after committing the offsets of all
+// events ACKed so far.
+func (p *Input) Stop() {
+	p.stopOnce.Do(func() {
+		p.log.Info("Stopping kafka input")
+		close(p.done)
+
+		// Closing the outlet unblocks consumers waiting for the pipeline. ACKs
+		// for events already published are still received.
+		p.outlet.Close()
+		p.wg.Wait()
+	})
+}
+
+// Wait stops the kafka input.
+func (p *Input) Wait() {
+	p.Stop()
+}
+
+func (p *Input) run() {
+	defer p.wg.Done()
+
+	var client sarama.Client
+	for client == nil {
+		c, err := sarama.NewClient(p.config.Hosts, p.saramaConfig)
+		if err != nil {
+			p.log.Errorf("Failed to connect to kafka: %v", err)
+			if !p.wait(p.config.ConnectBackoff) {
+				return
+			}
+			continue
+		}
+		client = c
+	}
+	defer client.Close()
+
+	member := newGroupMember(client, p.config, p.log)
+	defer func() {
+		if err := member.leave(); err != nil {
+			p.log.Errorf("Failed to leave consumer group: %v", err)
+		}
+	}()
+
+	for {
+		err := p.runSession(client, member)
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			p.log.Errorf("Consumer group session failed: %v", err)
+			if !p.wait(p.config.Rebalance.RetryBackoff) {
+				return
+			}
+		}
+	}
+}
+
+// runSession joins the group and consumes all assigned partitions until the
+// group is rebalanced or the input is stopped.
+func (p *Input) runSession(client sarama.Client, member *groupMember) error {
+	assignment, err := member.join()
+	if err != nil {
+		return err
+	}
+	p.log.Infow("Joined consumer group",
+		"generation", member.generation, "member_id", member.memberID, "assignment", assignment)
+
+	committed, err := member.fetchOffsets(assignment)
+	if err != nil {
+		return err
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	// Every partition publishes through its own sub-outlet, which is closed
+	// when the session ends. Consumers blocked by the pipeline are unblocked,
+	// the message being published is dropped and its offset not committed.
+	var (
+		partitions []*partitionOffsets
+		consumers  []sarama.PartitionConsumer
+		outlets    []channel.Outleter
+		wg         sync.WaitGroup
+		done       = make(chan struct{})
+	)
+	stopConsumers := func() {
+		close(done)
+		for _, out := range outlets {
+			out.Close()
+		}
+		for _, pc := range consumers {
+			pc.Close()
+		}
+		wg.Wait()
+	}
+
+	for topic, ids := range assignment {
+		for _, id := range ids {
+			offsets := newPartitionOffsets(topic, id, committed[topic][id])
+			pc, err := p.consumePartition(consumer, topic, id, committed[topic][id])
+			if err != nil {
+				stopConsumers()
+				return fmt.Errorf("failed to consume partition %v/%v: %v", topic, id, err)
+			}
+
+			out := channel.SubOutlet(p.outlet)
+			partitions = append(partitions, offsets)
+			consumers = append(consumers, pc)
+			outlets = append(outlets, out)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.consume(done, pc, harvester.NewForwarder(out), offsets)
+			}()
+		}
+	}
+
+	err = p.maintain(member, partitions)
+	stopConsumers()
+
+	// Give the outputs some time to ACK the events already published, so the
+	// number of duplicates after a rebalance is reduced.
+	p.waitACKs(partitions)
+	if err := member.commit(partitions); err != nil {
+		p.log.Errorf("Failed to commit offsets: %v", err)
+	}
+
+	if err == sarama.ErrRebalanceInProgress {
+		p.log.Info("Consumer group is rebalancing")
+		return nil
+	}
+	return err
+}
+
+func (p *Input) consumePartition(
+	consumer sarama.Consumer,
+	topic string,
+	partition int32,
+	offset int64,
+) (sarama.PartitionConsumer, error) {
+	initial := p.saramaConfig.Consumer.Offsets.Initial
+	if offset < 0 {
+		return consumer.ConsumePartition(topic, partition, initial)
+	}
+
+	pc, err := consumer.ConsumePartition(topic, partition, offset)
+	if err == sarama.ErrOffsetOutOfRange {
+		p.log.Warnf("Committed offset %v for %v/%v is out of range, using initial offset %v",
+			offset, topic, partition, p.config.InitialOffset)
+		return consumer.ConsumePartition(topic, partition, initial)
+	}
+	return pc, err
+}
+
+// consume publishes all messages of a partition until done is closed or the
+// forwarders outlet is closed.
+func (p *Input) consume(
+	done <-chan struct{},
+	pc sarama.PartitionConsumer,
+	forwarder *harvester.Forwarder,
+	offsets *partitionOffsets,
+) {
+	for {
+		select {
+		case <-done:
+			return
+
+		case err, ok := <-pc.Errors():
+			if ok {
+				p.log.Errorf("Failed to consume %v/%v: %v", err.Topic, err.Partition, err.Err)
+			}
+
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return
+			}
+
+			data := util.NewData()
+			data.Event = newEvent(msg)
+			data.Event.Private = offsets.published(msg.Offset)
+			if err := forwarder.Send(data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// maintain sends heartbeats and commits ACKed offsets regularly. It returns
+// if the input is stopped or the member must rejoin the group.
+func (p *Input) maintain(member *groupMember, partitions []*partitionOffsets) error {
+	heartbeat := time.NewTicker(p.config.HeartbeatInterval)
+	defer heartbeat.Stop()
+	commit := time.NewTicker(p.config.CommitInterval)
+	defer commit.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return nil
+
+		case <-commit.C:
+			if err := member.commit(partitions); err != nil {
+				p.log.Errorf("Failed to commit offsets: %v", err)
+			}
+
+		case <-heartbeat.C:
+			if err := member.heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// waitACKs waits up to wait_close for all published events to be ACKed.
+// Events still pending afterwards are not tracked anymore.
+func (p *Input) waitACKs(partitions []*partitionOffsets) {
+	deadline := time.Now().Add(p.config.WaitClose)
+	defer func() {
+		for _, offsets := range partitions {
+			offsets.acks.Close(0)
+		}
+	}()
+
+	for _, offsets := range partitions {
+		if !offsets.acks.Wait(time.Until(deadline)) {
+			pending := 0
+			for _, offsets := range partitions {
+				pending += offsets.pendingCount()
+			}
+			p.log.Infof("Stopped waiting for %v events to be ACKed", pending)
+			return
+		}
+	}
+}
+
+func (p *Input) wait(d time.Duration) bool {
+	select {
+	case <-p.done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func newEvent(msg *sarama.ConsumerMessage) beat.Event {
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	kafka := common.MapStr{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	}
+	if len(msg.Key) > 0 {
+		kafka["key"] = string(msg.Key)
+	}
+	if len(msg.Headers) > 0 {
+		headers := make([]string, 0, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headers = append(headers, fmt.Sprintf("%s: %s", h.Key, h.Value))
+		}
+		kafka["headers"] = headers
+	}
+
+	return beat.Event{
+		Timestamp: timestamp,
+		Fields: common.MapStr{
+			"message": string(msg.Value),
+			"kafka":   kafka,
+		},
+	}
+}
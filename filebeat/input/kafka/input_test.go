@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/channel"
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// blockingOutlet blocks all events until it is closed, like a full pipeline.
+type blockingOutlet struct {
+	done chan struct{}
+}
+
+func (o *blockingOutlet) OnEvent(*util.Data) bool {
+	<-o.done
+	return false
+}
+
+func (o *blockingOutlet) Close() error {
+	close(o.done)
+	return nil
+}
+
+type testPartitionConsumer struct {
+	sarama.PartitionConsumer
+	messages chan *sarama.ConsumerMessage
+	errors   chan *sarama.ConsumerError
+}
+
+func (pc *testPartitionConsumer) Messages() <-chan *sarama.ConsumerMessage { return pc.messages }
+func (pc *testPartitionConsumer) Errors() <-chan *sarama.ConsumerError     { return pc.errors }
+
+func TestNewEvent(t *testing.T) {
+	ts := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	event := newEvent(&sarama.ConsumerMessage{
+		Topic:     "test",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("key"),
+		Value:     []byte("hello world"),
+		Timestamp: ts,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("trace"), Value: []byte("abc")},
+		},
+	})
+
+	assert.Equal(t, ts, event.Timestamp)
+	assert.Equal(t, common.MapStr{
+		"message": "hello world",
+		"kafka": common.MapStr{
+			"topic":     "test",
+			"partition": int32(2),
+			"offset":    int64(42),
+			"key":       "key",
+			"headers":   []string{"trace: abc"},
+		},
+	}, event.Fields)
+}
+
+func TestNewEventWithoutTimestamp(t *testing.T) {
+	event := newEvent(&sarama.ConsumerMessage{Topic: "test", Value: []byte("hello")})
+
+	assert.False(t, event.Timestamp.IsZero())
+	_, err := event.Fields.GetValue("kafka.key")
+	assert.Error(t, err)
+	_, err = event.Fields.GetValue("kafka.headers")
+	assert.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	newConfig := func(t *testing.T, settings map[string]interface{}) *common.Config {
+		cfg, err := common.NewConfigFrom(map[string]interface{}{
+			"hosts":    []string{"localhost:9092"},
+			"topics":   []string{"test"},
+			"group_id": "filebeat",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Merge(settings); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	tests := map[string]map[string]interface{}{
+		"unknown version":      {"version": "0.1"},
+		"no consumer groups":   {"version": "0.8.2.0"},
+		"invalid offset":       {"initial_offset": "latest"},
+		"invalid strategy":     {"rebalance.strategy": "sticky"},
+		"heartbeat too long":   {"heartbeat_interval": "20s"},
+		"missing password":     {"username": "user"},
+		"zero commit interval": {"commit_interval": 0},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := newConfig(t, settings)
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cfg := newConfig(t, nil)
+		config := defaultConfig
+		if err := cfg.Unpack(&config); err != nil {
+			t.Fatal(err)
+		}
+
+		kafkaConfig, err := newSaramaConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, sarama.OffsetOldest, kafkaConfig.Consumer.Offsets.Initial)
+	})
+}
+
+func TestConsumeReturnsWhenSessionEnds(t *testing.T) {
+	p := &Input{log: logp.NewLogger("kafka")}
+	pipeline := &blockingOutlet{done: make(chan struct{})}
+	defer pipeline.Close()
+
+	pc := &testPartitionConsumer{
+		messages: make(chan *sarama.ConsumerMessage, 1),
+		errors:   make(chan *sarama.ConsumerError),
+	}
+	pc.messages <- &sarama.ConsumerMessage{Topic: "test", Offset: 1, Value: []byte("hello")}
+
+	out := channel.SubOutlet(pipeline)
+	offsets := newPartitionOffsets("test", 0, -1)
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		p.consume(done, pc, harvester.NewForwarder(out), offsets)
+	}()
+
+	// wait for the message to be blocked by the pipeline
+	for len(pc.messages) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(done)
+	out.Close()
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consume is blocked after the session ended")
+	}
+
+	// the dropped message is not committed
+	_, commit := offsets.commitOffset()
+	assert.False(t, commit)
+}
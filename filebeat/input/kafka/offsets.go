@@ -0,0 +1,78 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common/acker"
+)
+
+// partitionOffsets tracks the offsets of all messages published for a
+// partition. An offset becomes committable only after the message and all
+// messages published before it have been ACKed by the output.
+type partitionOffsets struct {
+	topic     string
+	partition int32
+	acks      *acker.Tracker
+
+	mutex     sync.Mutex
+	initial   int64 // offset to restart from if nothing has been ACKed
+	committed int64 // offset last committed to the group coordinator
+}
+
+func newPartitionOffsets(topic string, partition int32, committed int64) *partitionOffsets {
+	return &partitionOffsets{
+		topic:     topic,
+		partition: partition,
+		acks:      acker.NewTracker(acker.Settings{}),
+		initial:   committed,
+		committed: committed,
+	}
+}
+
+// published registers the offset of a message being published, returning
+// the handle to be used for ACKing the message.
+func (o *partitionOffsets) published(offset int64) acker.EventACKer {
+	// the offset committed is the offset of the next message to be consumed
+	return o.acks.AddEvent(offset + 1)
+}
+
+// commitOffset returns the offset to be committed. The boolean is false if
+// no new offset needs to be committed.
+func (o *partitionOffsets) commitOffset() (int64, bool) {
+	next := o.initial
+	if checkpoint := o.acks.Checkpoint(); checkpoint != nil {
+		next = checkpoint.(int64)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return next, next >= 0 && next != o.committed
+}
+
+func (o *partitionOffsets) markCommitted(offset int64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.committed = offset
+}
+
+// pendingCount returns the number of published messages not yet ACKed.
+func (o *partitionOffsets) pendingCount() int {
+	return o.acks.Pending()
+}
@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common/acker"
+)
+
+func TestPartitionOffsetsNothingToCommit(t *testing.T) {
+	offsets := newPartitionOffsets("test", 0, -1)
+	offsets.published(5)
+
+	_, ok := offsets.commitOffset()
+	assert.False(t, ok)
+	assert.Equal(t, 1, offsets.pendingCount())
+}
+
+func TestPartitionOffsetsInOrderACK(t *testing.T) {
+	offsets := newPartitionOffsets("test", 0, 10)
+	acks := []acker.EventACKer{offsets.published(10), offsets.published(11), offsets.published(12)}
+
+	acks[0].ACK()
+	acks[1].ACK()
+
+	offset, ok := offsets.commitOffset()
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), offset)
+	assert.Equal(t, 1, offsets.pendingCount())
+
+	offsets.markCommitted(offset)
+	_, ok = offsets.commitOffset()
+	assert.False(t, ok)
+}
+
+func TestPartitionOffsetsOutOfOrderACK(t *testing.T) {
+	offsets := newPartitionOffsets("test", 0, -1)
+	acks := []acker.EventACKer{offsets.published(3), offsets.published(4), offsets.published(7)}
+
+	acks[2].ACK()
+	_, ok := offsets.commitOffset()
+	assert.False(t, ok, "offset must not be committed before all previous events are ACKed")
+
+	acks[0].ACK()
+	offset, ok := offsets.commitOffset()
+	assert.True(t, ok)
+	assert.Equal(t, int64(4), offset)
+
+	acks[1].ACK()
+	offset, ok = offsets.commitOffset()
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), offset)
+	assert.Equal(t, 0, offsets.pendingCount())
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/elastic/beats/filebeat/input/file"
 	"github.com/elastic/beats/filebeat/reader/json"
 	"github.com/elastic/beats/filebeat/reader/multiline"
+	"github.com/elastic/beats/filebeat/reader/parser"
 	"github.com/elastic/beats/libbeat/common/cfgwarn"
 	"github.com/elastic/beats/libbeat/common/match"
 	"github.com/elastic/beats/libbeat/logp"
@@ -53,6 +54,11 @@ var (
 		ScanSort:       "",
 		ScanOrder:      "asc",
 		RecursiveGlob:  true,
+		FileIdentity:   FileIdentityNative,
+		Fingerprint: fingerprintConfig{
+			Offset: 0,
+			Length: 1024,
+		},
 
 		// Harvester
 		BufferSize: 16 * humanize.KiByte,
@@ -90,6 +96,9 @@ type config struct {
 	TailFiles      bool            `config:"tail_files"`
 	RecursiveGlob  bool            `config:"recursive_glob.enabled"`
 
+	FileIdentity string            `config:"file_identity"`
+	Fingerprint  fingerprintConfig `config:"fingerprint"`
+
 	// Harvester
 	BufferSize int    `config:"harvester_buffer_size"`
 	Encoding   string `config:"encoding"`
@@ -101,6 +110,7 @@ type config struct {
 	MaxBytes     int               `config:"max_bytes" validate:"min=0,nonzero"`
 	Multiline    *multiline.Config `config:"multiline"`
 	JSON         *json.Config      `config:"json"`
+	Parsers      parser.Config     `config:"parsers"`
 
 	// Hidden on purpose, used by the docker input:
 	DockerJSON *struct {
@@ -111,6 +121,11 @@ type config struct {
 	} `config:"docker-json"`
 }
 
+type fingerprintConfig struct {
+	Offset int64 `config:"offset" validate:"min=0"`
+	Length int64 `config:"length" validate:"min=1"`
+}
+
 type LogConfig struct {
 	Backoff       time.Duration `config:"backoff" validate:"min=0,nonzero"`
 	BackoffFactor int           `config:"backoff_factor" validate:"min=1"`
@@ -131,6 +146,18 @@ const (
 	ScanSortFilename = "filename"
 )
 
+// Contains available file identity options
+const (
+	FileIdentityNative      = "native"
+	FileIdentityFingerprint = "fingerprint"
+)
+
+// ValidFileIdentity of valid file identities
+var ValidFileIdentity = map[string]struct{}{
+	FileIdentityNative:      {},
+	FileIdentityFingerprint: {},
+}
+
 // ValidScanOrder of valid scan orders
 var ValidScanOrder = map[string]struct{}{
 	ScanOrderAsc:  {},
@@ -163,6 +190,12 @@ func (c *config) Validate() error {
 		return fmt.Errorf("clean_inactive must be > ignore_older + scan_frequency to make sure only files which are not monitored anymore are removed")
 	}
 
+	if c.FileIdentity != "" {
+		if _, ok := ValidFileIdentity[c.FileIdentity]; !ok {
+			return fmt.Errorf("Invalid file identity: %v", c.FileIdentity)
+		}
+	}
+
 	// Harvester
 	if c.JSON != nil && len(c.JSON.MessageKey) == 0 &&
 		c.Multiline != nil {
@@ -174,6 +207,18 @@ func (c *config) Validate() error {
 		return fmt.Errorf("When using the JSON decoder and line filtering together, you need to specify a message_key value")
 	}
 
+	if len(c.Parsers) > 0 {
+		if err := c.Parsers.Validate(); err != nil {
+			return err
+		}
+		if c.JSON != nil || c.Multiline != nil {
+			return fmt.Errorf("json and multiline can not be used together with parsers, use the ndjson and multiline parsers instead")
+		}
+		if c.DockerJSON != nil && c.Parsers.Has(parser.Container) {
+			return fmt.Errorf("the container parser can not be used with the docker input, containers are parsed by the input")
+		}
+	}
+
 	if c.ScanSort != "" {
 		cfgwarn.Experimental("scan_sort is used.")
 
@@ -26,6 +26,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/reader/json"
+	"github.com/elastic/beats/libbeat/common"
 )
 
 func TestCleanOlderError(t *testing.T) {
@@ -70,3 +72,33 @@ func TestCleanOlderIgnoreOlder(t *testing.T) {
 	err := config.Validate()
 	assert.NoError(t, err)
 }
+
+func TestInvalidFileIdentity(t *testing.T) {
+	config := defaultConfig
+	config.Paths = []string{"hello"}
+	config.Type = "log"
+
+	assert.NoError(t, config.Validate())
+
+	config.FileIdentity = FileIdentityFingerprint
+	assert.NoError(t, config.Validate())
+
+	config.FileIdentity = "path"
+	assert.Error(t, config.Validate())
+}
+
+func TestParsersWithJSON(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"paths":   []string{"hello"},
+		"parsers": []map[string]interface{}{{"ndjson.target": "json"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	assert.NoError(t, cfg.Unpack(&config))
+
+	config.JSON = &json.Config{}
+	assert.Error(t, config.Validate())
+}
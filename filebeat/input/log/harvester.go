@@ -261,9 +261,16 @@ func (h *Harvester) Run() error {
 		if err != nil {
 			switch err {
 			case ErrFileTruncate:
+				filesTruncated.Add(1)
+				if h.config.FileIdentity == FileIdentityFingerprint {
+					// The truncated file gets a new fingerprint once new content is
+					// written. Keep the offset, so a copy of the old content (e.g. created
+					// by copytruncate) is continued where reading stopped.
+					logp.Info("File was truncated. Keeping offset for fingerprinted content: %s", h.state.Source)
+					break
+				}
 				logp.Info("File was truncated. Begin reading file from offset 0: %s", h.state.Source)
 				h.state.Offset = 0
-				filesTruncated.Add(1)
 			case ErrRemoved:
 				logp.Info("File was removed: %s. Closing because close_removed is enabled.", h.state.Source)
 			case ErrRenamed:
@@ -326,7 +333,7 @@ func (h *Harvester) Run() error {
 					// the resulting timestamp
 					data.Event.Timestamp = ts
 				}
-			} else if &text != nil {
+			} else if text != "" || len(h.config.Parsers) == 0 {
 				if fields == nil {
 					fields = common.MapStr{}
 				}
@@ -531,12 +538,12 @@ func (h *Harvester) cleanup() {
 //
 // It creates a chain of readers which looks as following:
 //
-//   limit -> (multiline -> timeout) -> strip_newline -> json -> encode -> line -> log_file
+//   limit -> (multiline -> timeout) -> parsers -> strip_newline -> json -> encode -> line -> log_file
 //
 // Each reader on the left, contains the reader on the right and calls `Next()` to fetch more data.
 // At the base of all readers the the log_file reader. That means in the data is flowing in the opposite direction:
 //
-//   log_file -> line -> encode -> json -> strip_newline -> (timeout -> multiline) -> limit
+//   log_file -> line -> encode -> json -> strip_newline -> parsers -> (timeout -> multiline) -> limit
 //
 // log_file implements io.Reader interface and encode reader is an adapter for io.Reader to
 // reader.Reader also handling file encodings. All other readers implement reader.Reader
@@ -568,6 +575,11 @@ func (h *Harvester) newLogFileReader() (reader.Reader, error) {
 
 	r = strip_newline.New(r)
 
+	r, err = h.config.Parsers.Create(r, h.config.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
 	if h.config.Multiline != nil {
 		r, err = multiline.New(r, "\n", h.config.MaxBytes, h.config.Multiline)
 		if err != nil {
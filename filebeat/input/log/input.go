@@ -205,8 +205,7 @@ func (p *Input) Run() {
 				}
 			} else {
 				// Check if existing source on disk and state are the same. Remove if not the case.
-				newState := file.NewState(stat, state.Source, p.config.Type, p.meta)
-				if !newState.FileStateOS.IsSame(state.FileStateOS) {
+				if !p.isSameFile(stat, state) {
 					p.removeState(state)
 					logp.Debug("input", "Remove state for file as file removed or renamed: %s", state.Source)
 				}
@@ -399,9 +398,32 @@ func getFileState(path string, info os.FileInfo, p *Input) (file.State, error) {
 	logp.Debug("input", "Check file for harvesting: %s", absolutePath)
 	// Create new state for comparison
 	newState := file.NewState(info, absolutePath, p.config.Type, p.meta)
+	if p.config.FileIdentity == FileIdentityFingerprint {
+		newState.Fingerprint, err = p.fingerprint(absolutePath)
+		if err != nil {
+			return file.State{}, err
+		}
+	}
 	return newState, nil
 }
 
+// fingerprint computes the content based identity of the file at path.
+func (p *Input) fingerprint(path string) (string, error) {
+	return file.Fingerprint(path, p.config.Fingerprint.Offset, p.config.Fingerprint.Length)
+}
+
+// isSameFile checks if the file found on disk for the source of the state is
+// still the file the state was created for.
+func (p *Input) isSameFile(info os.FileInfo, state file.State) bool {
+	if p.config.FileIdentity != FileIdentityFingerprint {
+		newState := file.NewState(info, state.Source, p.config.Type, p.meta)
+		return newState.FileStateOS.IsSame(state.FileStateOS)
+	}
+
+	fingerprint, err := p.fingerprint(state.Source)
+	return err == nil && fingerprint == state.Fingerprint
+}
+
 func getKeys(paths map[string]os.FileInfo) []string {
 	files := make([]string, 0)
 	for file := range paths {
@@ -451,8 +473,15 @@ func (p *Input) scan() {
 		}
 
 		newState, err := getFileState(path, info, p)
+		if err == file.ErrFileTooSmall {
+			// The file is picked up again on the next scan, when enough content
+			// for the fingerprint was written.
+			logp.Debug("input", "Skipping file %s until it is large enough to be fingerprinted", path)
+			continue
+		}
 		if err != nil {
 			logp.Err("Skipping file %s due to error %s", path, err)
+			continue
 		}
 
 		// Load last state
@@ -20,7 +20,9 @@
 package log
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -136,6 +138,64 @@ func TestMatchesMeta(t *testing.T) {
 	}
 }
 
+func TestGetFileStateFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input-fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("first line\nsecond line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Input{
+		config: config{
+			FileIdentity: FileIdentityFingerprint,
+			Fingerprint:  fingerprintConfig{Length: 11},
+		},
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := getFileState(path, info, p)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, state.Fingerprint)
+	assert.True(t, p.isSameFile(info, state))
+
+	// copytruncate: the copy keeps the identity, the truncated file is a different file
+	copied := filepath.Join(dir, "app.log.1")
+	if err := os.Rename(path, copied); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("third\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	copiedInfo, err := os.Stat(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copiedState, err := getFileState(copied, copiedInfo, p)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, state.ID(), copiedState.ID())
+
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, p.isSameFile(info, state))
+	_, err = getFileState(path, info, p)
+	assert.Equal(t, file.ErrFileTooSmall, err)
+}
+
 type TestFileInfo struct {
 	time time.Time
 }
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	harvester.ForwarderConfig `config:",inline"`
+
+	// QueueURL is the URL of the SQS queue receiving the S3 notifications.
+	QueueURL string `config:"queue_url" validate:"required"`
+
+	// Region of the SQS queue. If not set, the region is read from the queue
+	// URL.
+	Region string `config:"region"`
+
+	// Endpoint overrides the S3 endpoint, e.g. to read from a S3 compatible
+	// service. Objects are addressed using path style URLs if set.
+	Endpoint string `config:"endpoint"`
+
+	AccessKeyID     string `config:"access_key_id"`
+	SecretAccessKey string `config:"secret_access_key"`
+	SessionToken    string `config:"session_token"`
+
+	// VisibilityTimeout is the time received messages are hidden from other
+	// consumers. The timeout is extended while a message is being processed
+	// or waiting for ACKs.
+	VisibilityTimeout time.Duration `config:"visibility_timeout" validate:"positive,nonzero"`
+
+	// WaitTime is the long polling duration of ReceiveMessage requests.
+	WaitTime time.Duration `config:"wait_time" validate:"min=0"`
+
+	MaxMessages int `config:"max_number_of_messages" validate:"min=1,max=10"`
+
+	// Format selects how objects are parsed, either line by line or as
+	// stream of JSON documents.
+	Format string `config:"format"`
+
+	// ExpandEventListFromField publishes each element of the array stored
+	// under this key of a JSON document as separate event, e.g. Records of
+	// CloudTrail logs.
+	ExpandEventListFromField string `config:"expand_event_list_from_field"`
+
+	APITimeout time.Duration     `config:"api_timeout" validate:"positive,nonzero"`
+	Backoff    time.Duration     `config:"backoff" validate:"positive,nonzero"`
+	TLS        *tlscommon.Config `config:"ssl"`
+}
+
+const (
+	formatLines = "lines"
+	formatJSON  = "json"
+)
+
+var defaultConfig = config{
+	ForwarderConfig: harvester.ForwarderConfig{
+		Type: "s3",
+	},
+	VisibilityTimeout: 300 * time.Second,
+	WaitTime:          20 * time.Second,
+	MaxMessages:       5,
+	Format:            formatLines,
+	APITimeout:        120 * time.Second,
+	Backoff:           10 * time.Second,
+}
+
+func (c *config) Validate() error {
+	if _, err := url.Parse(c.QueueURL); err != nil {
+		return fmt.Errorf("invalid queue_url: %v", err)
+	}
+	if c.Region == "" && regionFromQueueURL(c.QueueURL) == "" {
+		return fmt.Errorf("region must be set, it can not be read from queue_url '%v'", c.QueueURL)
+	}
+
+	if c.Endpoint != "" {
+		if _, err := url.Parse(c.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint: %v", err)
+		}
+	}
+
+	if c.WaitTime > 20*time.Second {
+		return errors.New("wait_time must not be greater than 20s")
+	}
+	if c.VisibilityTimeout > 12*time.Hour {
+		return errors.New("visibility_timeout must not be greater than 12h")
+	}
+
+	switch c.Format {
+	case formatLines:
+		if c.ExpandEventListFromField != "" {
+			return errors.New("expand_event_list_from_field requires format json")
+		}
+	case formatJSON:
+	default:
+		return fmt.Errorf("invalid format '%v', must be one of lines or json", c.Format)
+	}
+	return nil
+}
+
+// regionFromQueueURL parses the region from queue URLs of the form
+// https://sqs.<region>.amazonaws.com/<account>/<queue>.
+func regionFromQueueURL(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) < 4 || parts[0] != "sqs" || parts[2] != "amazonaws" {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,329 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/filebeat/channel"
+	"github.com/elastic/beats/filebeat/harvester"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/acker"
+	"github.com/elastic/beats/libbeat/common/aws"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+)
+
+func init() {
+	err := input.Register("s3", NewInput)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Input reads S3 objects announced via S3 event notifications from a SQS
+// queue. A SQS message is deleted only after all events of the objects
+// referenced by the message have been ACKed.
+type Input struct {
+	sync.Mutex
+	started   bool
+	config    config
+	outlet    channel.Outleter
+	forwarder *harvester.Forwarder
+	sqs       *sqsClient
+	s3        *s3Client
+	log       *logp.Logger
+	acks      *acker.Tracker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewInput creates a new s3 input
+func NewInput(
+	cfg *common.Config,
+	outlet channel.Connector,
+	context input.Context,
+) (input.Input, error) {
+	cfgwarn.Experimental("s3 input is used")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	out, err := outlet(cfg, context.DynamicFields)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newInput(config, out)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func newInput(config config, out channel.Outleter) (*Input, error) {
+	var endpoint *url.URL
+	if config.Endpoint != "" {
+		var err error
+		endpoint, err = url.Parse(config.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	region := config.Region
+	if region == "" {
+		region = regionFromQueueURL(config.QueueURL)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := transport.NetDialer(config.APITimeout)
+	tlsDialer, err := transport.TLSDialer(dialer, tls, config.APITimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	httpTransport := &http.Transport{
+		Dial:                  dialer.Dial,
+		DialTLS:               tlsDialer.Dial,
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: config.APITimeout + config.WaitTime,
+	}
+	creds := aws.NewCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Input{
+		config:    config,
+		outlet:    out,
+		forwarder: harvester.NewForwarder(out),
+		sqs: &sqsClient{
+			http:     &http.Client{Transport: httpTransport, Timeout: config.APITimeout + config.WaitTime},
+			queueURL: config.QueueURL,
+			region:   region,
+			creds:    creds,
+		},
+		// Objects are streamed while events are being published, so no
+		// overall timeout is set for reading the object content.
+		s3: &s3Client{
+			http:     &http.Client{Transport: httpTransport},
+			endpoint: endpoint,
+			creds:    creds,
+		},
+		log:    logp.NewLogger("s3").With("queue_url", config.QueueURL),
+		acks:   acker.NewTracker(acker.Settings{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Run starts polling the SQS queue.
+func (p *Input) Run() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.started {
+		return
+	}
+
+	p.log.Info("Starting s3 input")
+	p.wg.Add(1)
+	go p.run()
+	p.started = true
+}
+
+// Stop stops polling the SQS queue. Messages not yet deleted become visible
+// to other consumers after the visibility timeout.
+func (p *Input) Stop() {
+	p.log.Info("Stopping s3 input")
+	p.cancel()
+
+	// Closing the outlet unblocks the reader waiting for the pipeline.
+	p.outlet.Close()
+	p.wg.Wait()
+
+	// Messages of pending batches are not deleted anymore.
+	p.acks.Close(0)
+}
+
+// Wait stops the s3 input.
+func (p *Input) Wait() {
+	p.Stop()
+}
+
+func (p *Input) run() {
+	defer p.wg.Done()
+
+	for p.ctx.Err() == nil {
+		msgs, err := p.sqs.receive(p.ctx, p.config.MaxMessages, p.config.VisibilityTimeout, p.config.WaitTime)
+		if p.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			p.log.Errorf("Failed to receive SQS messages: %v", err)
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(p.config.Backoff):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			if p.ctx.Err() != nil {
+				return
+			}
+			p.processMessage(msg)
+		}
+	}
+}
+
+// processMessage reads all objects referenced by the message. The message is
+// not deleted if an object can not be read, so it will be received again
+// after the visibility timeout.
+func (p *Input) processMessage(msg sqsMessage) {
+	log := p.log.With("message_id", msg.MessageID)
+
+	objects, err := parseNotification(msg.Body)
+	if err != nil {
+		log.Errorf("Failed to parse SQS message, message is not deleted: %v", err)
+		return
+	}
+
+	ack := p.acks.NewBatch(nil, func() {
+		// ACKs are received from the publisher pipeline, do not block it
+		// while the message is deleted.
+		go p.deleteMessage(log, msg)
+	})
+
+	p.wg.Add(1)
+	go p.keepVisible(log, ack.Done(), msg)
+
+	for _, obj := range objects {
+		if err := p.processObject(obj, ack); err != nil {
+			log.Errorf("Failed to process S3 object: %v", err)
+			ack.Fail(err)
+			return
+		}
+	}
+	ack.Finish()
+}
+
+func (p *Input) processObject(obj s3Object, ack *acker.Batch) error {
+	body, err := p.s3.get(p.ctx, obj)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	r, err := newObjectReader(body)
+	if err == nil {
+		if p.config.Format == formatJSON {
+			err = readJSON(r, p.config.ExpandEventListFromField, func(doc common.MapStr) error {
+				return p.publish(newEvent(obj, common.MapStr{"json": doc}), ack)
+			})
+		} else {
+			err = readLines(r, func(line string, offset int64) error {
+				return p.publish(newEvent(obj, common.MapStr{"message": line, "offset": offset}), ack)
+			})
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read s3://%v/%v: %v", obj.bucket, obj.key, err)
+	}
+	return nil
+}
+
+func (p *Input) publish(event beat.Event, ack *acker.Batch) error {
+	event.Private = ack.Add()
+
+	data := util.NewData()
+	data.Event = event
+	return p.forwarder.Send(data)
+}
+
+func (p *Input) deleteMessage(log *logp.Logger, msg sqsMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.APITimeout)
+	defer cancel()
+
+	if err := p.sqs.delete(ctx, msg.ReceiptHandle); err != nil {
+		log.Errorf("Failed to delete SQS message: %v", err)
+		return
+	}
+	log.Debug("Deleted SQS message")
+}
+
+// keepVisible extends the visibility timeout of the message until done is
+// closed, such that the message is not received by other consumers while the
+// objects are being read or events are waiting to be ACKed.
+func (p *Input) keepVisible(log *logp.Logger, done <-chan struct{}, msg sqsMessage) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.VisibilityTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.sqs.changeVisibility(p.ctx, msg.ReceiptHandle, p.config.VisibilityTimeout)
+			if err != nil {
+				log.Errorf("Failed to extend SQS message visibility: %v", err)
+			}
+		}
+	}
+}
+
+func newEvent(obj s3Object, fields common.MapStr) beat.Event {
+	fields["aws"] = common.MapStr{
+		"s3": common.MapStr{
+			"bucket": common.MapStr{
+				"name": obj.bucket,
+				"arn":  obj.bucketARN,
+			},
+			"object": common.MapStr{
+				"key": obj.key,
+			},
+		},
+	}
+	return beat.Event{
+		Timestamp: time.Now(),
+		Fields:    fields,
+	}
+}
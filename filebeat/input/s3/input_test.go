@@ -0,0 +1,280 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/util"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// fakeAWS serves the SQS query API on /queue and objects from /<bucket>/<key>.
+type fakeAWS struct {
+	mutex    sync.Mutex
+	messages []sqsMessage
+	objects  map[string][]byte
+	deleted  []string
+	actions  []string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if req.URL.Path != "/queue" {
+		content, found := f.objects[req.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+		return
+	}
+
+	req.ParseForm()
+	action := req.Form.Get("Action")
+	f.actions = append(f.actions, action)
+	switch action {
+	case "ReceiveMessage":
+		resp := receiveMessageResponse{Messages: f.messages}
+		f.messages = nil
+		out, _ := xml.Marshal(struct {
+			XMLName xml.Name `xml:"ReceiveMessageResponse"`
+			receiveMessageResponse
+		}{receiveMessageResponse: resp})
+		w.Write(out)
+	case "DeleteMessage":
+		f.deleted = append(f.deleted, req.Form.Get("ReceiptHandle"))
+		fmt.Fprint(w, "<DeleteMessageResponse></DeleteMessageResponse>")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "<ErrorResponse><Error><Code>InvalidAction</Code><Message>unsupported</Message></Error></ErrorResponse>")
+	}
+}
+
+func (f *fakeAWS) getDeleted() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.deleted
+}
+
+type testOutlet struct {
+	mutex sync.Mutex
+	data  []*util.Data
+}
+
+func (o *testOutlet) OnEvent(d *util.Data) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.data = append(o.data, d)
+	return true
+}
+
+func (o *testOutlet) Close() error { return nil }
+
+func (o *testOutlet) events() []*util.Data {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.data
+}
+
+func notification(bucket string, keys ...string) string {
+	records := make([]string, len(keys))
+	for i, key := range keys {
+		records[i] = fmt.Sprintf(`{"eventSource": "aws:s3", "awsRegion": "us-east-1", "eventName": "ObjectCreated:Put",
+			"s3": {"bucket": {"name": %q, "arn": "arn:aws:s3:::%s"}, "object": {"key": %q}}}`, bucket, bucket, key)
+	}
+	return `{"Records": [` + strings.Join(records, ",") + `]}`
+}
+
+func newTestInput(t *testing.T, serverURL string, settings map[string]interface{}) (*Input, *testOutlet) {
+	settings["queue_url"] = serverURL + "/queue"
+	settings["region"] = "us-east-1"
+	settings["endpoint"] = serverURL
+	settings["access_key_id"] = "id"
+	settings["secret_access_key"] = "secret"
+	settings["wait_time"] = 0
+
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	outlet := &testOutlet{}
+	p, err := newInput(config, outlet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, outlet
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timeout waiting for condition")
+		}
+	}
+}
+
+func TestInputDeletesMessageAfterACK(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("line 3\n"))
+	w.Close()
+
+	aws := &fakeAWS{
+		messages: []sqsMessage{
+			{MessageID: "m1", ReceiptHandle: "r1", Body: notification("logs", "a.log", "b.log.gz")},
+		},
+		objects: map[string][]byte{
+			"/logs/a.log":    []byte("line 1\nline 2\n"),
+			"/logs/b.log.gz": gz.Bytes(),
+		},
+	}
+	server := httptest.NewServer(aws)
+	defer server.Close()
+
+	p, outlet := newTestInput(t, server.URL, map[string]interface{}{})
+	p.Run()
+	defer p.Stop()
+
+	waitFor(t, func() bool { return len(outlet.events()) == 3 })
+	events := outlet.events()
+
+	var messages []string
+	for _, data := range events {
+		msg, _ := data.Event.Fields.GetValue("message")
+		messages = append(messages, msg.(string))
+	}
+	assert.Equal(t, []string{"line 1", "line 2", "line 3"}, messages)
+
+	key, _ := events[2].Event.Fields.GetValue("aws.s3.object.key")
+	assert.Equal(t, "b.log.gz", key)
+	offset, _ := events[1].Event.Fields.GetValue("offset")
+	assert.Equal(t, int64(7), offset)
+
+	events[0].Event.Private.(util.ACKer).ACK()
+	events[1].Event.Private.(util.ACKer).ACK()
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, aws.getDeleted(), "message must not be deleted before all events are ACKed")
+
+	events[2].Event.Private.(util.ACKer).ACK()
+	waitFor(t, func() bool { return len(aws.getDeleted()) == 1 })
+	assert.Equal(t, []string{"r1"}, aws.getDeleted())
+}
+
+func TestInputKeepsMessageOnObjectError(t *testing.T) {
+	aws := &fakeAWS{
+		messages: []sqsMessage{
+			{MessageID: "m1", ReceiptHandle: "r1", Body: notification("logs", "missing.log")},
+			{MessageID: "m2", ReceiptHandle: "r2", Body: notification("logs", "a.json")},
+		},
+		objects: map[string][]byte{
+			"/logs/a.json": []byte(`{"Records": [{"id": 1}, {"id": 2}]}`),
+		},
+	}
+	server := httptest.NewServer(aws)
+	defer server.Close()
+
+	p, outlet := newTestInput(t, server.URL, map[string]interface{}{
+		"format":                       "json",
+		"expand_event_list_from_field": "Records",
+	})
+	p.Run()
+	defer p.Stop()
+
+	waitFor(t, func() bool { return len(outlet.events()) == 2 })
+	for _, data := range outlet.events() {
+		_, err := data.Event.Fields.GetValue("json.id")
+		assert.NoError(t, err)
+		data.Event.Private.(util.ACKer).ACK()
+	}
+
+	waitFor(t, func() bool { return len(aws.getDeleted()) == 1 })
+	assert.Equal(t, []string{"r2"}, aws.getDeleted())
+}
+
+func TestSQSErrorResponse(t *testing.T) {
+	server := httptest.NewServer(&fakeAWS{})
+	defer server.Close()
+
+	p, _ := newTestInput(t, server.URL, map[string]interface{}{})
+	err := p.sqs.changeVisibility(p.ctx, "r1", time.Minute)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "InvalidAction")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"missing queue_url": {},
+		"missing region": {
+			"queue_url": "http://localhost/queue",
+		},
+		"invalid format": {
+			"queue_url": "https://sqs.us-east-1.amazonaws.com/123/queue",
+			"format":    "xml",
+		},
+		"expand requires json": {
+			"queue_url":                    "https://sqs.us-east-1.amazonaws.com/123/queue",
+			"expand_event_list_from_field": "Records",
+		},
+		"wait time too long": {
+			"queue_url": "https://sqs.us-east-1.amazonaws.com/123/queue",
+			"wait_time": "30s",
+		},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
+
+func TestRegionFromQueueURL(t *testing.T) {
+	assert.Equal(t, "eu-central-1", regionFromQueueURL("https://sqs.eu-central-1.amazonaws.com/123456789012/queue"))
+	assert.Equal(t, "", regionFromQueueURL("http://localhost:4576/queue"))
+}
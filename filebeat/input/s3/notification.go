@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// s3Object references an object to be read.
+type s3Object struct {
+	region    string
+	bucket    string
+	bucketARN string
+	key       string
+}
+
+// s3Notification is the S3 event notification message format. See
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html.
+type s3Notification struct {
+	Event   string `json:"Event"`
+	Records []struct {
+		EventSource string `json:"eventSource"`
+		AWSRegion   string `json:"awsRegion"`
+		EventName   string `json:"eventName"`
+		S3          struct {
+			Bucket struct {
+				Name string `json:"name"`
+				ARN  string `json:"arn"`
+			} `json:"bucket"`
+			Object struct {
+				Key string `json:"key"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+}
+
+// snsEnvelope wraps notifications delivered to SQS via SNS.
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// parseNotification returns the objects created according to the message
+// body. Notifications published via SNS are unwrapped. S3 test events and
+// events other than ObjectCreated are ignored.
+func parseNotification(body string) ([]s3Object, error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return nil, fmt.Errorf("invalid notification: %v", err)
+	}
+	if envelope.Type == "Notification" && envelope.Message != "" {
+		body = envelope.Message
+	}
+
+	var notification s3Notification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, fmt.Errorf("invalid notification: %v", err)
+	}
+
+	var objects []s3Object
+	for _, record := range notification.Records {
+		if record.EventSource != "aws:s3" || !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+
+		// Object keys are URL encoded, with spaces being replaced by '+'.
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object key '%v': %v", record.S3.Object.Key, err)
+		}
+
+		objects = append(objects, s3Object{
+			region:    record.AWSRegion,
+			bucket:    record.S3.Bucket.Name,
+			bucketARN: record.S3.Bucket.ARN,
+			key:       key,
+		})
+	}
+	return objects, nil
+}
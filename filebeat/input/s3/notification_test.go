@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNotification = `{
+  "Records": [
+    {
+      "eventSource": "aws:s3",
+      "awsRegion": "eu-west-1",
+      "eventName": "ObjectCreated:Put",
+      "s3": {
+        "bucket": {"name": "logs", "arn": "arn:aws:s3:::logs"},
+        "object": {"key": "AWSLogs/elb/my+log%3D1.gz", "size": 1024}
+      }
+    },
+    {
+      "eventSource": "aws:s3",
+      "awsRegion": "eu-west-1",
+      "eventName": "ObjectRemoved:Delete",
+      "s3": {
+        "bucket": {"name": "logs", "arn": "arn:aws:s3:::logs"},
+        "object": {"key": "removed"}
+      }
+    }
+  ]
+}`
+
+func TestParseNotification(t *testing.T) {
+	objects, err := parseNotification(testNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []s3Object{
+		{
+			region:    "eu-west-1",
+			bucket:    "logs",
+			bucketARN: "arn:aws:s3:::logs",
+			key:       "AWSLogs/elb/my log=1.gz",
+		},
+	}, objects)
+}
+
+func TestParseNotificationViaSNS(t *testing.T) {
+	body, err := json.Marshal(map[string]string{
+		"Type":    "Notification",
+		"Message": testNotification,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := parseNotification(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, objects, 1)
+}
+
+func TestParseNotificationTestEvent(t *testing.T) {
+	objects, err := parseNotification(`{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"logs"}`)
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestParseNotificationInvalid(t *testing.T) {
+	_, err := parseNotification("not json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/aws"
+	"github.com/elastic/beats/libbeat/common/jsontransform"
+)
+
+// s3Client downloads objects from S3.
+type s3Client struct {
+	http     *http.Client
+	endpoint *url.URL
+	creds    aws.Credentials
+}
+
+// objectURL returns the object URL. Virtual hosted style URLs are used for
+// AWS, path style URLs for custom endpoints.
+func (c *s3Client) objectURL(obj s3Object) *url.URL {
+	if c.endpoint == nil {
+		return &url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%v.s3.%v.amazonaws.com", obj.bucket, obj.region),
+			Path:   "/" + obj.key,
+		}
+	}
+
+	target := *c.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + obj.bucket + "/" + obj.key
+	return &target
+}
+
+// get opens the object for reading. The caller must close the returned
+// reader.
+func (c *s3Client) get(ctx context.Context, obj s3Object) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.objectURL(obj).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	aws.Sign(req, c.creds, obj.region, "s3", aws.HashHex(nil), time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to get s3://%v/%v: %v: %s", obj.bucket, obj.key, resp.StatusCode, msg)
+	}
+	return resp.Body, nil
+}
+
+// newObjectReader returns a reader for the object content, decompressing gzip
+// encoded objects.
+func newObjectReader(r io.Reader) (io.Reader, error) {
+	buf := bufio.NewReader(r)
+	magic, err := buf.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(buf)
+	}
+	return buf, nil
+}
+
+// readLines calls fn for each line of r, passing the line content and the
+// offset the line starts at. Line endings are removed and empty lines are
+// skipped.
+func readLines(r io.Reader, fn func(line string, offset int64) error) error {
+	buf := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := buf.ReadBytes('\n')
+		if len(line) > 0 {
+			start := offset
+			offset += int64(len(line))
+
+			line = bytes.TrimRight(line, "\r\n")
+			if len(line) > 0 {
+				if err := fn(string(line), start); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readJSON calls fn for each JSON object read from r. The content can be a
+// single document, an array of objects or a stream of documents, e.g. NDJSON.
+// If expandField is set, each element of the array stored under expandField
+// is passed as separate object.
+func readJSON(r io.Reader, expandField string, fn func(obj common.MapStr) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := forEachObject(v, expandField, fn); err != nil {
+			return err
+		}
+	}
+}
+
+func forEachObject(v interface{}, expandField string, fn func(obj common.MapStr) error) error {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			if err := forEachObject(elem, expandField, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case map[string]interface{}:
+		if expandField != "" {
+			if list, ok := v[expandField].([]interface{}); ok {
+				return forEachObject(list, "", fn)
+			}
+		}
+
+		obj := common.MapStr(v)
+		jsontransform.TransformNumbers(obj)
+		return fn(obj)
+
+	default:
+		return fmt.Errorf("JSON object expected, got %T", v)
+	}
+}
@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/aws"
+)
+
+func TestObjectURL(t *testing.T) {
+	obj := s3Object{region: "eu-west-1", bucket: "logs", key: "dir/my log.gz"}
+
+	c := &s3Client{}
+	assert.Equal(t, "https://logs.s3.eu-west-1.amazonaws.com/dir/my%20log.gz", c.objectURL(obj).String())
+
+	endpoint, _ := url.Parse("http://localhost:9000/")
+	c = &s3Client{endpoint: endpoint}
+	assert.Equal(t, "http://localhost:9000/logs/dir/my%20log.gz", c.objectURL(obj).String())
+}
+
+func TestGetObjectSpecialCharacters(t *testing.T) {
+	creds := aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}
+
+	var requestURI string
+	signed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestURI = req.RequestURI
+
+		// sign the request as received, the signature must match
+		now, err := time.Parse("20060102T150405Z", req.Header.Get("X-Amz-Date"))
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		u, _ := url.Parse("http://" + req.Host + req.RequestURI)
+		check := &http.Request{Method: req.Method, URL: u, Host: req.Host, Header: http.Header{}}
+		aws.Sign(check, creds, "us-east-1", "s3", aws.HashHex(nil), now)
+		signed = check.Header.Get("Authorization") == req.Header.Get("Authorization")
+
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	c := &s3Client{http: http.DefaultClient, endpoint: endpoint, creds: creds}
+	obj := s3Object{region: "us-east-1", bucket: "logs", key: "dir/a=b+c:d e.log"}
+
+	r, err := c.get(context.Background(), obj)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+
+	content, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, "/logs/dir/a%3Db%2Bc%3Ad%20e.log", requestURI)
+	assert.True(t, signed)
+}
+
+func TestObjectReaderGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("compressed\n"))
+	w.Close()
+
+	for name, content := range map[string][]byte{
+		"plain":      []byte("compressed\n"),
+		"compressed": buf.Bytes(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, err := newObjectReader(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "compressed\n", string(data))
+		})
+	}
+}
+
+func TestObjectReaderEmpty(t *testing.T) {
+	r, err := newObjectReader(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestReadLines(t *testing.T) {
+	type line struct {
+		text   string
+		offset int64
+	}
+
+	var lines []line
+	err := readLines(strings.NewReader("first\r\n\nsecond\nlast"), func(text string, offset int64) error {
+		lines = append(lines, line{text, offset})
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []line{{"first", 0}, {"second", 8}, {"last", 15}}, lines)
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := map[string]struct {
+		content  string
+		expand   string
+		expected []common.MapStr
+	}{
+		"ndjson": {
+			content:  "{\"a\": 1}\n{\"a\": 2}\n",
+			expected: []common.MapStr{{"a": int64(1)}, {"a": int64(2)}},
+		},
+		"array": {
+			content:  `[{"a": 1.5}, {"a": "b"}]`,
+			expected: []common.MapStr{{"a": 1.5}, {"a": "b"}},
+		},
+		"expand event list": {
+			content:  `{"Records": [{"eventName": "a"}, {"eventName": "b"}]}`,
+			expand:   "Records",
+			expected: []common.MapStr{{"eventName": "a"}, {"eventName": "b"}},
+		},
+		"expand field missing": {
+			content:  `{"eventName": "a"}`,
+			expand:   "Records",
+			expected: []common.MapStr{{"eventName": "a"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var docs []common.MapStr
+			err := readJSON(strings.NewReader(test.content), test.expand, func(doc common.MapStr) error {
+				docs = append(docs, doc)
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, docs)
+		})
+	}
+}
+
+func TestReadJSONInvalid(t *testing.T) {
+	noop := func(common.MapStr) error { return nil }
+	assert.Error(t, readJSON(strings.NewReader(`{"a": `), "", noop))
+	assert.Error(t, readJSON(strings.NewReader(`"string"`), "", noop))
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/aws"
+)
+
+const sqsAPIVersion = "2012-11-05"
+
+// sqsClient implements the ReceiveMessage, DeleteMessage and
+// ChangeMessageVisibility actions of the SQS query API.
+type sqsClient struct {
+	http     *http.Client
+	queueURL string
+	region   string
+	creds    aws.Credentials
+}
+
+type sqsMessage struct {
+	MessageID     string `xml:"MessageId"`
+	ReceiptHandle string `xml:"ReceiptHandle"`
+	Body          string `xml:"Body"`
+}
+
+type receiveMessageResponse struct {
+	Messages []sqsMessage `xml:"ReceiveMessageResult>Message"`
+}
+
+type sqsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// receive waits up to waitTime for at most max messages to become available.
+func (c *sqsClient) receive(ctx context.Context, max int, visibilityTimeout, waitTime time.Duration) ([]sqsMessage, error) {
+	var resp receiveMessageResponse
+	err := c.call(ctx, "ReceiveMessage", url.Values{
+		"MaxNumberOfMessages": {strconv.Itoa(max)},
+		"VisibilityTimeout":   {seconds(visibilityTimeout)},
+		"WaitTimeSeconds":     {seconds(waitTime)},
+	}, &resp)
+	return resp.Messages, err
+}
+
+func (c *sqsClient) delete(ctx context.Context, receiptHandle string) error {
+	return c.call(ctx, "DeleteMessage", url.Values{
+		"ReceiptHandle": {receiptHandle},
+	}, nil)
+}
+
+func (c *sqsClient) changeVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	return c.call(ctx, "ChangeMessageVisibility", url.Values{
+		"ReceiptHandle":     {receiptHandle},
+		"VisibilityTimeout": {seconds(timeout)},
+	}, nil)
+}
+
+func (c *sqsClient) call(ctx context.Context, action string, params url.Values, result interface{}) error {
+	params.Set("Action", action)
+	params.Set("Version", sqsAPIVersion)
+	body := params.Encode()
+
+	req, err := http.NewRequest("POST", c.queueURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	aws.Sign(req, c.creds, c.region, "sqs", aws.HashHex([]byte(body)), time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp sqsErrorResponse
+		if xml.Unmarshal(content, &errResp) == nil && errResp.Code != "" {
+			return fmt.Errorf("%v failed: %v: %v", action, errResp.Code, errResp.Message)
+		}
+		return fmt.Errorf("%v failed with status %v", action, resp.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(content, result)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(d / time.Second))
+}
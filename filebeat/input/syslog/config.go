@@ -33,16 +33,34 @@ import (
 type config struct {
 	harvester.ForwarderConfig `config:",inline"`
 	Protocol                  common.ConfigNamespace `config:"protocol"`
+	Format                    string                 `config:"format"`
 }
 
+const (
+	formatAuto    = "auto"
+	formatRFC3164 = "rfc3164"
+	formatRFC5424 = "rfc5424"
+)
+
 var defaultConfig = config{
 	ForwarderConfig: harvester.ForwarderConfig{
 		Type: "syslog",
 	},
+	Format: formatAuto,
+}
+
+func (c *config) Validate() error {
+	switch c.Format {
+	case formatAuto, formatRFC3164, formatRFC5424:
+		return nil
+	default:
+		return fmt.Errorf("invalid format '%s', must be one of auto, rfc3164 or rfc5424", c.Format)
+	}
 }
 
 var defaultTCP = tcp.Config{
 	LineDelimiter:  "\n",
+	Framing:        tcp.FramingDelimiter,
 	Timeout:        time.Minute * 5,
 	MaxMessageSize: 20 * humanize.MiByte,
 }
@@ -71,6 +71,12 @@ type event struct {
 	nanosecond int
 	year       int
 	loc        *time.Location
+
+	// RFC5424 only
+	version        int
+	procID         string
+	msgID          string
+	structuredData map[string]map[string]string
 }
 
 // newEvent() return a new event.
@@ -253,7 +259,70 @@ func (s *event) Nanosecond() int {
 }
 
 // Timestamp return the timestamp in UTC.
+func (s *event) SetVersion(b []byte) {
+	s.version = bytesToInt(b)
+}
+
+func (s *event) Version() int {
+	return s.version
+}
+
+// SetProcID sets the RFC5424 PROCID. Numeric values are used as the pid.
+func (s *event) SetProcID(b []byte) {
+	s.procID = string(b)
+	if isDigits(b) {
+		s.SetPid(b)
+	}
+}
+
+func (s *event) ProcID() string {
+	return s.procID
+}
+
+func (s *event) SetMsgID(b []byte) {
+	s.msgID = string(b)
+}
+
+func (s *event) MsgID() string {
+	return s.msgID
+}
+
+func (s *event) SetStructuredDataParam(id, name, value string) {
+	if s.structuredData == nil {
+		s.structuredData = map[string]map[string]string{}
+	}
+	params, ok := s.structuredData[id]
+	if !ok {
+		params = map[string]string{}
+		s.structuredData[id] = params
+	}
+	if name != "" {
+		params[name] = value
+	}
+}
+
+func (s *event) StructuredData() map[string]map[string]string {
+	return s.structuredData
+}
+
+// SetTimestamp sets all date fields and the timezone from a complete timestamp.
+func (s *event) SetTimestamp(t time.Time) {
+	s.year = t.Year()
+	s.month = t.Month()
+	s.day = t.Day()
+	s.hour = t.Hour()
+	s.minute = t.Minute()
+	s.second = t.Second()
+	s.nanosecond = t.Nanosecond()
+	s.loc = t.Location()
+}
+
+// Timestamp returns the event timestamp in UTC. The timezone is used if the
+// event timestamp did not include any timezone information.
 func (s *event) Timestamp(timezone *time.Location) time.Time {
+	if s.loc != nil {
+		timezone = s.loc
+	}
 	return time.Date(
 		s.Year(),
 		s.Month(),
@@ -268,7 +337,12 @@ func (s *event) Timestamp(timezone *time.Location) time.Time {
 
 // IsValid returns true if the date and the message are present.
 func (s *event) IsValid() bool {
-	return s.day != -1 && s.hour != -1 && s.minute != -1 && s.second != -1 && s.message != ""
+	hasTimestamp := s.day != -1 && s.hour != -1 && s.minute != -1 && s.second != -1
+	if s.version > 0 {
+		// RFC5424 allows empty messages, e.g. when only structured data is sent.
+		return hasTimestamp
+	}
+	return hasTimestamp && s.message != ""
 }
 
 // BytesToInt takes a variable length of bytes and assume ascii chars and convert it to int, this is
@@ -283,6 +357,18 @@ func bytesToInt(b []byte) int {
 	return i
 }
 
+func isDigits(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, x := range b {
+		if x < '0' || x > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func skipLeadZero(b []byte) []byte {
 	if len(b) > 1 && b[0] == '0' {
 		return b[1:len(b)]
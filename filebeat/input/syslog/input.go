@@ -125,10 +125,9 @@ func NewInput(
 	forwarder := harvester.NewForwarder(out)
 	cb := func(data []byte, metadata inputsource.NetworkMetadata) {
 		ev := newEvent()
-		Parse(data, ev)
 		var d *util.Data
-		if !ev.IsValid() {
-			log.Errorw("can't not parse event as syslog rfc3164", "message", string(data))
+		if !parse(config.Format, data, ev) {
+			log.Errorw("can't parse event as syslog", "format", config.Format, "message", string(data))
 			// On error revert to the raw bytes content, we need a better way to communicate this kind of
 			// error upstream this should be a global effort.
 			d = &util.Data{
@@ -195,6 +194,16 @@ func (p *Input) Wait() {
 	p.Stop()
 }
 
+// parse parses the message using the configured format. With format auto,
+// RFC5424 messages are detected by the version following the priority.
+func parse(format string, data []byte, ev *event) bool {
+	if format == formatRFC5424 || (format == formatAuto && IsRFC5424(data)) {
+		return ParseRFC5424(data, ev) == nil
+	}
+	Parse(data, ev)
+	return ev.IsValid()
+}
+
 func createEvent(ev *event, metadata inputsource.NetworkMetadata, timezone *time.Location, log *logp.Logger) *beat.Event {
 	f := common.MapStr{
 		"message": strings.TrimRight(ev.Message(), "\n"),
@@ -237,6 +246,30 @@ func createEvent(ev *event, metadata inputsource.NetworkMetadata, timezone *time
 		}
 	}
 
+	if ev.Version() > 0 {
+		syslog["version"] = ev.Version()
+	}
+
+	if ev.ProcID() != "" && !ev.HasPid() {
+		syslog["procid"] = ev.ProcID()
+	}
+
+	if ev.MsgID() != "" {
+		syslog["msgid"] = ev.MsgID()
+	}
+
+	if sd := ev.StructuredData(); len(sd) > 0 {
+		data := common.MapStr{}
+		for id, params := range sd {
+			elem := common.MapStr{}
+			for name, value := range params {
+				elem[name] = value
+			}
+			data[id] = elem
+		}
+		syslog["structured_data"] = data
+	}
+
 	f["syslog"] = syslog
 	f["event"] = event
 	f["process"] = process
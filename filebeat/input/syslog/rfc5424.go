@@ -0,0 +1,234 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const nilValue = '-'
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// IsRFC5424 returns true if the message starts with a RFC5424 header, which
+// has a version after the priority.
+// <165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event
+func IsRFC5424(data []byte) bool {
+	if len(data) < 2 || data[0] != '<' {
+		return false
+	}
+	i := bytes.IndexByte(data, '>')
+	if i < 2 || i > 4 || !isDigits(data[1:i]) {
+		return false
+	}
+	rest := data[i+1:]
+	for j, c := range rest {
+		if c == ' ' {
+			return j > 0 && j <= 2
+		}
+		if c < '0' || c > '9' || (j == 0 && c == '0') {
+			return false
+		}
+	}
+	return false
+}
+
+// ParseRFC5424 parses a RFC5424 syslog message. The event must not be used if
+// an error is returned.
+func ParseRFC5424(data []byte, event *event) error {
+	p := rfc5424Parser{data: data}
+	return p.parse(event)
+}
+
+type rfc5424Parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *rfc5424Parser) parse(event *event) error {
+	if !p.consume('<') {
+		return errors.New("missing priority")
+	}
+	pri := p.until('>')
+	if !isDigits(pri) || len(pri) > 3 || !p.consume('>') {
+		return errors.New("invalid priority")
+	}
+	event.SetPriority(pri)
+
+	version := p.field()
+	if !isDigits(version) || version[0] == '0' {
+		return errors.New("invalid version")
+	}
+	event.SetVersion(version)
+
+	ts := p.field()
+	if len(ts) == 0 {
+		return errors.New("missing timestamp")
+	}
+	if isNil(ts) {
+		event.SetTimestamp(time.Now())
+	} else {
+		t, err := time.Parse(time.RFC3339Nano, string(ts))
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %v", err)
+		}
+		event.SetTimestamp(t)
+	}
+
+	header := []struct {
+		name string
+		set  func([]byte)
+	}{
+		{"hostname", event.SetHostname},
+		{"app-name", event.SetProgram},
+		{"procid", event.SetProcID},
+		{"msgid", event.SetMsgID},
+	}
+	for _, h := range header {
+		v := p.field()
+		if len(v) == 0 {
+			return fmt.Errorf("missing %s", h.name)
+		}
+		if !isNil(v) {
+			h.set(v)
+		}
+	}
+
+	if err := p.structuredData(event); err != nil {
+		return err
+	}
+
+	if p.pos < len(p.data) {
+		if !p.consume(' ') {
+			return errors.New("missing space before message")
+		}
+		event.SetMessage(bytes.TrimPrefix(p.data[p.pos:], utf8BOM))
+	}
+	return nil
+}
+
+func (p *rfc5424Parser) structuredData(event *event) error {
+	if p.consume(nilValue) {
+		return nil
+	}
+
+	if p.pos >= len(p.data) || p.data[p.pos] != '[' {
+		return errors.New("missing structured data")
+	}
+
+	for p.consume('[') {
+		id := p.sdName()
+		if len(id) == 0 {
+			return errors.New("missing structured data id")
+		}
+		event.SetStructuredDataParam(string(id), "", "")
+
+		for p.consume(' ') {
+			name := p.sdName()
+			if len(name) == 0 {
+				return errors.New("missing structured data param name")
+			}
+			if !p.consume('=') || !p.consume('"') {
+				return fmt.Errorf("invalid structured data param %s", name)
+			}
+			value, err := p.paramValue()
+			if err != nil {
+				return err
+			}
+			event.SetStructuredDataParam(string(id), string(name), value)
+		}
+
+		if !p.consume(']') {
+			return fmt.Errorf("unterminated structured data element %s", id)
+		}
+	}
+	return nil
+}
+
+// paramValue reads a quoted param value, resolving the escape sequences for
+// '"', '\' and ']'.
+func (p *rfc5424Parser) paramValue() (string, error) {
+	var buf []byte
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+
+		switch c {
+		case '"':
+			return string(buf), nil
+		case '\\':
+			if p.pos < len(p.data) {
+				next := p.data[p.pos]
+				if next == '"' || next == '\\' || next == ']' {
+					c = next
+					p.pos++
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return "", errors.New("unterminated structured data param value")
+}
+
+// sdName reads a SD-NAME, which are printable US-ASCII characters except '=',
+// ' ', ']' and '"'.
+func (p *rfc5424Parser) sdName() []byte {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			break
+		}
+		p.pos++
+	}
+	return p.data[start:p.pos]
+}
+
+// field reads a header field terminated by a space, consuming the space.
+func (p *rfc5424Parser) field() []byte {
+	v := p.until(' ')
+	if !p.consume(' ') {
+		return nil
+	}
+	return v
+}
+
+func (p *rfc5424Parser) until(c byte) []byte {
+	start := p.pos
+	if i := bytes.IndexByte(p.data[p.pos:], c); i >= 0 {
+		p.pos += i
+	} else {
+		p.pos = len(p.data)
+	}
+	return p.data[start:p.pos]
+}
+
+func (p *rfc5424Parser) consume(c byte) bool {
+	if p.pos < len(p.data) && p.data[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func isNil(b []byte) bool {
+	return len(b) == 1 && b[0] == nilValue
+}
@@ -0,0 +1,176 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func TestIsRFC5424(t *testing.T) {
+	tests := map[string]bool{
+		"<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - hello": true,
+		"<34>12 - - - - - -":                                    true,
+		"<34>Oct 11 22:14:15 mymachine su: 'su root' failed":    false,
+		"<38>2018-05-08T10:31:24 localhost prg00000[1234]: seq": false,
+		"<34>0 - - - - - -":                                     false,
+		"<34>1":                                                 false,
+		"1 - - - - - -":                                         false,
+		"":                                                      false,
+	}
+
+	for log, expected := range tests {
+		assert.Equal(t, expected, IsRFC5424([]byte(log)), log)
+	}
+}
+
+func TestParseRFC5424(t *testing.T) {
+	t.Run("structured data and message", func(t *testing.T) {
+		log := `<165>1 2003-10-11T22:14:15.003-07:00 mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="10.0.0.1"] An application event`
+		e := newEvent()
+		if !assert.NoError(t, ParseRFC5424([]byte(log), e)) {
+			return
+		}
+
+		assert.True(t, e.IsValid())
+		assert.Equal(t, 165, e.Priority())
+		assert.Equal(t, 1, e.Version())
+		assert.Equal(t, "mymachine.example.com", e.Hostname())
+		assert.Equal(t, "evntslog", e.Program())
+		assert.Equal(t, 1234, e.Pid())
+		assert.Equal(t, "ID47", e.MsgID())
+		assert.Equal(t, "An application event", e.Message())
+		assert.Equal(t, map[string]map[string]string{
+			"exampleSDID@32473": {"iut": "3", "eventSource": "Application"},
+			"origin":            {"ip": "10.0.0.1"},
+		}, e.StructuredData())
+		assert.Equal(t, time.Date(2003, 10, 12, 5, 14, 15, 3000000, time.UTC), e.Timestamp(time.Local))
+	})
+
+	t.Run("nil values", func(t *testing.T) {
+		e := newEvent()
+		if !assert.NoError(t, ParseRFC5424([]byte("<34>1 - - - - - -"), e)) {
+			return
+		}
+
+		assert.True(t, e.IsValid())
+		assert.Equal(t, "", e.Hostname())
+		assert.Equal(t, "", e.Program())
+		assert.False(t, e.HasPid())
+		assert.Equal(t, "", e.MsgID())
+		assert.Equal(t, "", e.Message())
+		assert.Nil(t, e.StructuredData())
+		assert.WithinDuration(t, time.Now(), e.Timestamp(time.Local), time.Minute)
+	})
+
+	t.Run("escaped param values", func(t *testing.T) {
+		log := `<34>1 2003-10-11T22:14:15Z host app - - [id a="quote \" bracket \] backslash \\ other \n"]`
+		e := newEvent()
+		if !assert.NoError(t, ParseRFC5424([]byte(log), e)) {
+			return
+		}
+		assert.Equal(t, `quote " bracket ] backslash \ other \n`, e.StructuredData()["id"]["a"])
+	})
+
+	t.Run("element without params", func(t *testing.T) {
+		e := newEvent()
+		if !assert.NoError(t, ParseRFC5424([]byte(`<34>1 2003-10-11T22:14:15Z host app - - [meta] msg`), e)) {
+			return
+		}
+		assert.Equal(t, map[string]map[string]string{"meta": {}}, e.StructuredData())
+		assert.Equal(t, "msg", e.Message())
+	})
+
+	t.Run("non numeric procid and BOM", func(t *testing.T) {
+		e := newEvent()
+		if !assert.NoError(t, ParseRFC5424([]byte("<34>1 2003-10-11T22:14:15Z host app worker-1 - - \xEF\xBB\xBFhello"), e)) {
+			return
+		}
+		assert.False(t, e.HasPid())
+		assert.Equal(t, "worker-1", e.ProcID())
+		assert.Equal(t, "hello", e.Message())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		logs := []string{
+			"",
+			"<34>",
+			"<34>1 2003-10-11 host app - - - msg",
+			"<34>1 2003-10-11T22:14:15Z host app - -",
+			"<34>1 2003-10-11T22:14:15Z host app - - msg",
+			`<34>1 2003-10-11T22:14:15Z host app - - [id a="1"`,
+			`<34>1 2003-10-11T22:14:15Z host app - - [id a="1]`,
+			`<34>1 2003-10-11T22:14:15Z host app - - [id a=1] msg`,
+			`<34>1 2003-10-11T22:14:15Z host app - - [id a="1"]msg`,
+		}
+		for _, log := range logs {
+			assert.Error(t, ParseRFC5424([]byte(log), newEvent()), log)
+		}
+	})
+}
+
+func TestCreateEventRFC5424(t *testing.T) {
+	log := `<165>1 2003-10-11T22:14:15.003Z mymachine evntslog worker ID47 [exampleSDID@32473 iut="3"] An application event`
+	e := newEvent()
+	if !assert.True(t, parse(formatAuto, []byte(log), e)) {
+		return
+	}
+
+	event := createEvent(e, dummyMetadata(), time.Local, logp.NewLogger("syslog"))
+	expected := common.MapStr{
+		"source":   "127.0.0.1",
+		"message":  "An application event",
+		"hostname": "mymachine",
+		"process": common.MapStr{
+			"program": "evntslog",
+		},
+		"event": common.MapStr{
+			"severity": 5,
+		},
+		"syslog": common.MapStr{
+			"facility":       20,
+			"severity_label": "Notice",
+			"facility_label": "local4",
+			"priority":       165,
+			"version":        1,
+			"procid":         "worker",
+			"msgid":          "ID47",
+			"structured_data": common.MapStr{
+				"exampleSDID@32473": common.MapStr{"iut": "3"},
+			},
+		},
+	}
+	assert.Equal(t, expected, event.Fields)
+	assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), event.Timestamp)
+}
+
+func TestParseFormat(t *testing.T) {
+	rfc3164 := []byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed")
+	rfc5424 := []byte("<34>1 2003-10-11T22:14:15Z mymachine su - - - 'su root' failed")
+
+	assert.True(t, parse(formatAuto, rfc3164, newEvent()))
+	assert.True(t, parse(formatAuto, rfc5424, newEvent()))
+	assert.True(t, parse(formatRFC3164, rfc3164, newEvent()))
+	assert.True(t, parse(formatRFC5424, rfc5424, newEvent()))
+	assert.False(t, parse(formatRFC5424, rfc3164, newEvent()))
+}
@@ -37,6 +37,7 @@ var defaultConfig = config{
 	},
 	Config: tcp.Config{
 		LineDelimiter:  "\n",
+		Framing:        tcp.FramingDelimiter,
 		Timeout:        time.Minute * 5,
 		MaxMessageSize: 20 * humanize.MiByte,
 	},
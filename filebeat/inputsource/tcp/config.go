@@ -28,12 +28,19 @@ import (
 // Name is the human readable name and identifier.
 const Name = "tcp"
 
+// Supported framing of the messages in a stream.
+const (
+	FramingDelimiter = "delimiter"
+	FramingRFC6587   = "rfc6587"
+)
+
 type size uint64
 
 // Config exposes the tcp configuration.
 type Config struct {
 	Host           string                  `config:"host"`
 	LineDelimiter  string                  `config:"line_delimiter" validate:"nonzero"`
+	Framing        string                  `config:"framing"`
 	Timeout        time.Duration           `config:"timeout" validate:"nonzero,positive"`
 	MaxMessageSize cfgtype.ByteSize        `config:"max_message_size" validate:"nonzero,positive"`
 	TLS            *tlscommon.ServerConfig `config:"ssl"`
@@ -44,5 +51,10 @@ func (c *Config) Validate() error {
 	if len(c.Host) == 0 {
 		return fmt.Errorf("need to specify the host using the `host:port` syntax")
 	}
+	switch c.Framing {
+	case FramingDelimiter, FramingRFC6587:
+	default:
+		return fmt.Errorf("invalid framing '%s', must be one of %s or %s", c.Framing, FramingDelimiter, FramingRFC6587)
+	}
 	return nil
 }
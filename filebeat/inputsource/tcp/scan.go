@@ -20,8 +20,16 @@ package tcp
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"io"
+	"strconv"
 )
 
+// maxOctetCountDigits limits the length of the octet count of a frame.
+const maxOctetCountDigits = 10
+
+var errInvalidOctetCount = errors.New("invalid octet count in frame")
+
 // factoryDelimiter return a function to split line using a custom delimiter supporting multibytes
 // delimiter, the delimiter is stripped from the returned value.
 func factoryDelimiter(delimiter []byte) bufio.SplitFunc {
@@ -46,3 +54,47 @@ func dropDelimiter(data []byte, delimiter []byte) []byte {
 	}
 	return data
 }
+
+// factoryRFC6587 returns a split function supporting the octet counting framing
+// described in RFC6587, where each frame is prefixed with the message length
+// and a space. Frames not starting with a digit use the non-transparent
+// framing and are split by the given delimiter split function.
+func factoryRFC6587(delimiter bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, eof bool) (int, []byte, error) {
+		if len(data) > 0 && (data[0] == '\n' || data[0] == '\r') {
+			// skip trailers some senders add after octet counted frames
+			return 1, nil, nil
+		}
+		if len(data) == 0 || data[0] < '0' || data[0] > '9' {
+			return delimiter(data, eof)
+		}
+
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 {
+			if len(data) > maxOctetCountDigits {
+				return 0, nil, errInvalidOctetCount
+			}
+			if eof {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, nil
+		}
+		if sp > maxOctetCountDigits {
+			return 0, nil, errInvalidOctetCount
+		}
+
+		length, err := strconv.Atoi(string(data[:sp]))
+		if err != nil || length <= 0 {
+			return 0, nil, errInvalidOctetCount
+		}
+
+		end := sp + 1 + length
+		if len(data) < end {
+			if eof {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, nil
+		}
+		return end, data[sp+1 : end], nil
+	}
+}
@@ -106,3 +106,63 @@ func TestCustomDelimiter(t *testing.T) {
 		})
 	}
 }
+
+func TestRFC6587Framing(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "octet counting",
+			text:     "5 hello7 bonjour4 hola",
+			expected: []string{"hello", "bonjour", "hola"},
+		},
+		{
+			name:     "octet counting with delimiter in message",
+			text:     "11 hello\nworld3 hey",
+			expected: []string{"hello\nworld", "hey"},
+		},
+		{
+			name:     "octet counting with trailing newline",
+			text:     "5 hello\n7 bonjour\n",
+			expected: []string{"hello", "bonjour"},
+		},
+		{
+			name:     "non-transparent framing",
+			text:     "<13>hello\n<13>bonjour\n",
+			expected: []string{"<13>hello", "<13>bonjour"},
+		},
+		{
+			name:     "mixed framing",
+			text:     "<13>hello\n8 <13>hola\n<13>hey",
+			expected: []string{"<13>hello", "<13>hola", "<13>hey"},
+		},
+		{
+			name:     "truncated frame",
+			text:     "10 hello",
+			expected: []string(nil),
+			err:      true,
+		},
+		{
+			name:     "invalid octet count",
+			text:     "12345678901 hello",
+			expected: []string(nil),
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(test.text))
+			scanner.Split(factoryRFC6587(bufio.ScanLines))
+			var elements []string
+			for scanner.Scan() {
+				elements = append(elements, scanner.Text())
+			}
+			assert.EqualValues(t, test.expected, elements)
+			assert.Equal(t, test.err, scanner.Err() != nil)
+		})
+	}
+}
@@ -61,6 +61,9 @@ func New(
 	}
 
 	sf := splitFunc([]byte(config.LineDelimiter))
+	if config.Framing == FramingRFC6587 {
+		sf = factoryRFC6587(sf)
+	}
 	return &Server{
 		config:    config,
 		callback:  callback,
@@ -34,6 +34,7 @@ import (
 
 var defaultConfig = Config{
 	LineDelimiter:  "\n",
+	Framing:        FramingDelimiter,
 	Timeout:        time.Minute * 5,
 	MaxMessageSize: 20 * humanize.MiByte,
 }
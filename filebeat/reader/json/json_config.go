@@ -26,6 +26,15 @@ type Config struct {
 	IgnoreDecodingError bool   `config:"ignore_decoding_error"`
 }
 
+// ParserConfig holds the options of the ndjson parser.
+type ParserConfig struct {
+	Config `config:",inline"`
+
+	// Target is the field the decoded keys are written to. The keys are
+	// written to the root of the event if empty.
+	Target string `config:"target"`
+}
+
 // Validate validates the Config option for JSON reader.
 func (c *Config) Validate() error {
 	return nil
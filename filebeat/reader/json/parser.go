@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package json
+
+import (
+	"github.com/elastic/beats/filebeat/reader"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/jsontransform"
+)
+
+// Parser decodes the content of messages as JSON and writes the decoded keys
+// to the fields of the message. Unlike the JSON reader, the fields are
+// merged into the message by the parser itself, so it can be used at any
+// position of a parsers list.
+type Parser struct {
+	JSON
+	target string
+}
+
+// NewParser creates a new ndjson parser.
+func NewParser(r reader.Reader, cfg *ParserConfig) *Parser {
+	return &Parser{
+		JSON:   JSON{reader: r, cfg: &cfg.Config},
+		target: cfg.Target,
+	}
+}
+
+// Next decodes the next message and returns it with the decoded fields.
+// If message_key is configured, the content of the message is replaced by
+// the value of the key. Otherwise the content is empty after decoding.
+func (p *Parser) Next() (reader.Message, error) {
+	message, err := p.reader.Next()
+	if err != nil {
+		return message, err
+	}
+
+	var fields common.MapStr
+	message.Content, fields = p.decode(message.Content)
+	if len(fields) == 0 {
+		return message, nil
+	}
+
+	if message.Fields == nil {
+		message.Fields = common.MapStr{}
+	}
+
+	if p.target != "" {
+		message.Fields.Put(p.target, fields)
+		return message, nil
+	}
+
+	event := &beat.Event{
+		Timestamp: message.Ts,
+		Fields:    message.Fields,
+		Meta:      common.MapStr{},
+	}
+	jsontransform.WriteJSONKeys(event, fields, p.cfg.OverwriteKeys)
+	message.Ts = event.Timestamp
+	return message, nil
+}
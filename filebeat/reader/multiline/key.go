@@ -0,0 +1,249 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package multiline
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/elastic/beats/filebeat/reader"
+	"github.com/elastic/beats/filebeat/reader/timeout"
+	"github.com/elastic/beats/libbeat/common/match"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// keyReader combines lines sharing the same key into one event. The key is
+// extracted from each line using the first capture group of a regular
+// expression. Lines of different keys can be interleaved, e.g. stack traces
+// of concurrent threads or requests. Lines without key are added to the group
+// of the previous line.
+//
+// A group is finished after the timeout passed since its first line was read,
+// or if the flush pattern matches. If maxGroups groups are open when a new
+// group is required, the oldest group is finished. Groups are returned in the order of their
+// first line. The number of bytes reported for a group is the distance the
+// read position advanced up to the first line of the next open group. This
+// way the offset stored in the registry never skips lines of groups not yet
+// returned.
+type keyReader struct {
+	reader       reader.Reader
+	key          *regexp.Regexp
+	flushMatcher *match.Matcher
+	maxBytes     int
+	maxLines     int
+	maxGroups    int
+	separator    []byte
+	timeout      time.Duration
+
+	groups []*keyGroup // open groups, ordered by their first line
+	byKey  map[string]*keyGroup
+	last   *keyGroup // group of the last line read
+	pos    int64     // number of bytes read from the underlying reader
+	mark   int64     // number of bytes reported in returned messages
+	err    error     // last seen error
+}
+
+type keyGroup struct {
+	key      string
+	start    int64 // read position of the first line
+	created  time.Time
+	finished bool
+	message  reader.Message
+	numLines int
+}
+
+func newKeyReader(
+	r reader.Reader,
+	separator string,
+	maxBytes int,
+	config *Config,
+) (*keyReader, error) {
+	key, err := compileKeyPattern(config.Key.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	maxLines := defaultMaxLines
+	if config.MaxLines != nil {
+		maxLines = *config.MaxLines
+	}
+
+	maxGroups := defaultMaxGroups
+	if config.Key.MaxGroups != nil {
+		maxGroups = *config.Key.MaxGroups
+	}
+
+	tout := defaultMultilineTimeout
+	if config.Timeout != nil && *config.Timeout > 0 {
+		tout = *config.Timeout
+	}
+
+	// Wake up regularly to return groups being finished by timeout, even if no
+	// new lines are written.
+	r = timeout.New(r, sigMultilineTimeout, tout/2)
+
+	return &keyReader{
+		reader:       r,
+		key:          key,
+		flushMatcher: config.FlushPattern,
+		maxBytes:     maxBytes,
+		maxLines:     maxLines,
+		maxGroups:    maxGroups,
+		separator:    []byte(separator),
+		timeout:      tout,
+		byKey:        map[string]*keyGroup{},
+	}, nil
+}
+
+// Next returns the next finished group. On error all open groups are
+// returned before the error is passed to the caller.
+func (kr *keyReader) Next() (reader.Message, error) {
+	for {
+		if len(kr.groups) > 0 && (kr.err != nil || kr.isFinished(kr.groups[0], time.Now())) {
+			return kr.pop(), nil
+		}
+
+		if kr.err != nil {
+			err := kr.err
+			kr.err = nil
+			return reader.Message{}, err
+		}
+
+		message, err := kr.reader.Next()
+		if err == sigMultilineTimeout {
+			continue
+		}
+		if message.Bytes > 0 {
+			kr.addLine(message)
+		}
+		if err != nil {
+			if len(kr.groups) > 0 {
+				logp.Debug("multiline", "Returning %v open multiline groups on error: %v", len(kr.groups), err)
+			}
+			kr.err = err
+		}
+	}
+}
+
+func (kr *keyReader) isFinished(g *keyGroup, now time.Time) bool {
+	return g.finished || now.Sub(g.created) >= kr.timeout
+}
+
+// addLine adds the line to the group of its key, creating a new group if
+// required.
+func (kr *keyReader) addLine(m reader.Message) {
+	start := kr.pos
+	kr.pos += int64(m.Bytes)
+
+	var g *keyGroup
+	if match := kr.key.FindSubmatch(m.Content); match != nil {
+		key := string(match[1])
+		if g = kr.byKey[key]; g == nil {
+			g = kr.open(key, start, m)
+			kr.byKey[key] = g
+		}
+	} else if kr.last != nil && !kr.last.finished {
+		g = kr.last
+	} else {
+		// no group to add the line to, return line as is
+		g = kr.open("", start, m)
+		g.finished = true
+	}
+
+	kr.add(g, m)
+	kr.last = g
+
+	if kr.flushMatcher != nil && kr.flushMatcher.Match(m.Content) {
+		kr.finish(g)
+	}
+}
+
+func (kr *keyReader) open(key string, start int64, m reader.Message) *keyGroup {
+	if kr.maxGroups > 0 && len(kr.groups) >= kr.maxGroups {
+		logp.Debug("multiline", "Maximum of %v open multiline groups reached, flushing the oldest group", kr.maxGroups)
+		kr.finish(kr.groups[0])
+	}
+
+	g := &keyGroup{
+		key:     key,
+		start:   start,
+		created: time.Now(),
+	}
+	// Timestamp of first message is taken as overall timestamp
+	g.message.Ts = m.Ts
+	kr.groups = append(kr.groups, g)
+	return g
+}
+
+// finish marks the group as finished. New lines with the same key start a
+// new group.
+func (kr *keyReader) finish(g *keyGroup) {
+	g.finished = true
+	if kr.byKey[g.key] == g {
+		delete(kr.byKey, g.key)
+	}
+}
+
+// pop removes the oldest group and returns its message.
+func (kr *keyReader) pop() reader.Message {
+	g := kr.groups[0]
+	kr.groups[0] = nil
+	kr.groups = kr.groups[1:]
+	kr.finish(g)
+	if kr.last == g {
+		kr.last = nil
+	}
+
+	next := kr.pos
+	if len(kr.groups) > 0 {
+		next = kr.groups[0].start
+	}
+
+	msg := g.message
+	msg.Bytes = int(next - kr.mark)
+	kr.mark = next
+	return msg
+}
+
+// add adds the line content to the group. As with the pattern based reader,
+// lines exceeding maxBytes or maxLines are not added to the content, but are
+// still accounted to the group.
+func (kr *keyReader) add(g *keyGroup, m reader.Message) {
+	sz := len(g.message.Content)
+	addSeparator := sz > 0 && len(kr.separator) > 0
+	if addSeparator {
+		sz += len(kr.separator)
+	}
+
+	space := kr.maxBytes - sz
+	if (kr.maxBytes <= 0 || space > 0) && (kr.maxLines <= 0 || g.numLines < kr.maxLines) {
+		if space < 0 || space > len(m.Content) {
+			space = len(m.Content)
+		}
+
+		tmp := g.message.Content
+		if addSeparator {
+			tmp = append(tmp, kr.separator...)
+		}
+		g.message.Content = append(tmp, m.Content[:space]...)
+		g.numLines++
+	}
+
+	g.message.Bytes += m.Bytes
+	g.message.AddFields(m.Fields)
+}
@@ -0,0 +1,247 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package multiline
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/reader"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/match"
+)
+
+type chanReader chan reader.Message
+
+func (c chanReader) Next() (reader.Message, error) {
+	msg, ok := <-c
+	if !ok {
+		return reader.Message{}, io.EOF
+	}
+	return msg, nil
+}
+
+func line(content string) reader.Message {
+	return reader.Message{Ts: time.Now(), Content: []byte(content), Bytes: len(content) + 1}
+}
+
+func readAllMessages(t *testing.T, r reader.Reader) []reader.Message {
+	var messages []reader.Message
+	for {
+		message, err := r.Next()
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestMultilineKeyInterleaved(t *testing.T) {
+	_, buf := createLineBuffer(
+		"[a] start\n",
+		"[b] start\n",
+		"  at b.1\n",
+		"[a] more\n",
+		"[b] end\n",
+	)
+	r := createMultilineTestReader(t, buf, Config{
+		Type: "key",
+		Key:  KeyConfig{Pattern: `^\[(\w+)\]`},
+	})
+
+	messages := readAllMessages(t, r)
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+
+	assert.Equal(t, "[a] start\n[a] more", string(messages[0].Content))
+	assert.Equal(t, "[b] start\n  at b.1\n[b] end", string(messages[1].Content))
+
+	// bytes are reported up to the first line of the next group only, so the
+	// sum always matches a line boundary in the file
+	assert.Equal(t, 10, messages[0].Bytes)
+	assert.Equal(t, 10+9+9+8, messages[1].Bytes)
+}
+
+func TestMultilineKeyFlushPattern(t *testing.T) {
+	flush := match.MustCompile(`end$`)
+	_, buf := createLineBuffer(
+		"[a] start\n",
+		"[a] end\n",
+		"[a] next\n",
+		"no key\n",
+	)
+	r := createMultilineTestReader(t, buf, Config{
+		Type:         "key",
+		Key:          KeyConfig{Pattern: `^\[(\w+)\]`},
+		FlushPattern: &flush,
+	})
+
+	messages := readAllMessages(t, r)
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+	assert.Equal(t, "[a] start\n[a] end", string(messages[0].Content))
+	assert.Equal(t, 18, messages[0].Bytes)
+	assert.Equal(t, "[a] next\nno key", string(messages[1].Content))
+	assert.Equal(t, 16, messages[1].Bytes)
+}
+
+func TestMultilineKeyLineWithoutGroup(t *testing.T) {
+	_, buf := createLineBuffer(
+		"no key\n",
+		"[a] start\n",
+	)
+	r := createMultilineTestReader(t, buf, Config{
+		Type: "key",
+		Key:  KeyConfig{Pattern: `^\[(\w+)\]`},
+	})
+
+	messages := readAllMessages(t, r)
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+	assert.Equal(t, "no key", string(messages[0].Content))
+	assert.Equal(t, "[a] start", string(messages[1].Content))
+}
+
+func TestMultilineKeyTimeout(t *testing.T) {
+	lines := make(chanReader, 10)
+	tout := 100 * time.Millisecond
+	r, err := New(lines, "\n", 1<<20, &Config{
+		Type:    "key",
+		Key:     KeyConfig{Pattern: `id=(\d+)`},
+		Timeout: &tout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := line("id=1 a")
+	first.Fields = common.MapStr{"stream": "stdout"}
+	lines <- first
+	lines <- line("id=2 a")
+	lines <- line("id=1 b")
+
+	msg, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "id=1 a\nid=1 b", string(msg.Content))
+	assert.Equal(t, 7, msg.Bytes)
+	assert.Equal(t, first.Ts, msg.Ts)
+	assert.Equal(t, common.MapStr{"stream": "stdout"}, msg.Fields)
+
+	msg, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "id=2 a", string(msg.Content))
+	assert.Equal(t, 14, msg.Bytes)
+
+	// new group is started after timeout
+	lines <- line("id=1 c")
+	close(lines)
+	msg, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "id=1 c", string(msg.Content))
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMultilineKeyMaxLines(t *testing.T) {
+	maxLines := 2
+	_, buf := createLineBuffer(
+		"[a] 1\n",
+		"[a] 2\n",
+		"[a] 3\n",
+	)
+	r := createMultilineTestReader(t, buf, Config{
+		Type:     "key",
+		Key:      KeyConfig{Pattern: `^\[(\w+)\]`},
+		MaxLines: &maxLines,
+	})
+
+	messages := readAllMessages(t, r)
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	assert.Equal(t, "[a] 1\n[a] 2", string(messages[0].Content))
+	assert.Equal(t, 18, messages[0].Bytes)
+}
+
+func TestMultilineKeyConfigValidate(t *testing.T) {
+	zero := time.Duration(0)
+	tests := map[string]Config{
+		"missing pattern":    {Type: "key"},
+		"no capture group":   {Type: "key", Key: KeyConfig{Pattern: `^\[\w+\]`}},
+		"invalid pattern":    {Type: "key", Key: KeyConfig{Pattern: `^\[(\w+`}},
+		"zero timeout":       {Type: "key", Key: KeyConfig{Pattern: `(\w+)`}, Timeout: &zero},
+		"unknown type":       {Type: "count"},
+		"pattern without re": {Type: "pattern", Match: "after"},
+	}
+
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, config.Validate())
+		})
+	}
+
+	valid := Config{Type: "key", Key: KeyConfig{Pattern: `^\[(\w+)\]`}}
+	assert.NoError(t, valid.Validate())
+}
+
+func TestMultilineKeyMaxGroups(t *testing.T) {
+	lines := make(chanReader, 10)
+	maxGroups := 2
+	tout := time.Hour
+	r, err := New(lines, "\n", 1<<20, &Config{
+		Type:    "key",
+		Key:     KeyConfig{Pattern: `id=(\d+)`, MaxGroups: &maxGroups},
+		Timeout: &tout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines <- line("id=1 a")
+	lines <- line("id=2 a")
+	lines <- line("id=3 a")
+	lines <- line("id=1 b")
+
+	// the oldest group is flushed when the third group is opened
+	msg, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "id=1 a", string(msg.Content))
+	assert.Equal(t, 7, msg.Bytes)
+
+	// the next line of the flushed key starts a new group, flushing the
+	// oldest open group again
+	msg, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "id=2 a", string(msg.Content))
+
+	close(lines)
+	messages := readAllMessages(t, r)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "id=3 a", string(messages[0].Content))
+		assert.Equal(t, "id=1 b", string(messages[1].Content))
+	}
+}
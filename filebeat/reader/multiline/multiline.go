@@ -59,6 +59,9 @@ const (
 
 	// Default timeout to finish a multi-line event.
 	defaultMultilineTimeout = 5 * time.Second
+
+	// Default maximum number of open groups of the key based reader
+	defaultMaxGroups = 1000
 )
 
 // Matcher represents the predicate comparing any two lines
@@ -76,6 +79,18 @@ func New(
 	separator string,
 	maxBytes int,
 	config *Config,
+) (reader.Reader, error) {
+	if config.Type == typeKey {
+		return newKeyReader(r, separator, maxBytes, config)
+	}
+	return newPatternReader(r, separator, maxBytes, config)
+}
+
+func newPatternReader(
+	r reader.Reader,
+	separator string,
+	maxBytes int,
+	config *Config,
 ) (*Reader, error) {
 	types := map[string]func(match.Matcher) (matcher, error){
 		"before": beforeMatcher,
@@ -86,6 +101,9 @@ func New(
 	if !ok {
 		return nil, fmt.Errorf("unknown matcher type: %s", config.Match)
 	}
+	if config.Pattern == nil {
+		return nil, errors.New("multiline pattern is required")
+	}
 
 	matcher, err := matcherType(*config.Pattern)
 	if err != nil {
@@ -18,26 +18,82 @@
 package multiline
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common/match"
 )
 
+// Multiline reader types.
+const (
+	// typePattern combines consecutive lines based on pattern matching.
+	typePattern = "pattern"
+
+	// typeKey combines lines sharing the same key, even if lines of
+	// different keys are interleaved.
+	typeKey = "key"
+)
+
 // Config holds the options of multiline readers.
 type Config struct {
+	Type         string         `config:"type"`
 	Negate       bool           `config:"negate"`
-	Match        string         `config:"match" validate:"required"`
+	Match        string         `config:"match"`
 	MaxLines     *int           `config:"max_lines"`
-	Pattern      *match.Matcher `config:"pattern" validate:"required"`
+	Pattern      *match.Matcher `config:"pattern"`
 	Timeout      *time.Duration `config:"timeout" validate:"positive"`
 	FlushPattern *match.Matcher `config:"flush_pattern"`
+	Key          KeyConfig      `config:"key"`
+}
+
+// KeyConfig holds the options of the key based multiline reader.
+type KeyConfig struct {
+	// Pattern is a regular expression with one capture group extracting the
+	// key of a line. Lines without key are added to the group of the
+	// previous line.
+	Pattern string `config:"pattern"`
+
+	// MaxGroups is the maximum number of open groups. The oldest group is
+	// finished when a line requires a new group and the limit is reached.
+	MaxGroups *int `config:"max_groups"`
 }
 
 // Validate validates the Config option for multiline reader.
 func (c *Config) Validate() error {
-	if c.Match != "after" && c.Match != "before" {
-		return fmt.Errorf("unknown matcher type: %s", c.Match)
+	switch c.Type {
+	case "", typePattern:
+		if c.Match != "after" && c.Match != "before" {
+			return fmt.Errorf("unknown matcher type: %s", c.Match)
+		}
+		if c.Pattern == nil {
+			return errors.New("multiline.pattern is required")
+		}
+	case typeKey:
+		if _, err := compileKeyPattern(c.Key.Pattern); err != nil {
+			return err
+		}
+		if c.Timeout != nil && *c.Timeout == 0 {
+			return errors.New("multiline.timeout must not be 0 if type is key")
+		}
+	default:
+		return fmt.Errorf("unknown multiline type: %s", c.Type)
 	}
 	return nil
 }
+
+func compileKeyPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("multiline.key.pattern is required")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiline.key.pattern: %v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, errors.New("multiline.key.pattern must contain a capture group")
+	}
+	return re, nil
+}
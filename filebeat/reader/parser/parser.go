@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package parser builds a chain of readers from the ordered list of parsers
+// configured on an input.
+//
+// The supported parsers are:
+//
+//   container: decodes the docker json-file and CRI log formats
+//   multiline: combines multiple lines into a single message
+//   ndjson:    decodes the message as JSON
+//
+// Each parser reads the messages returned by the previous parser, so the
+// parsers are applied in the order they are configured.
+package parser
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/filebeat/reader"
+	"github.com/elastic/beats/filebeat/reader/docker_json"
+	"github.com/elastic/beats/filebeat/reader/json"
+	"github.com/elastic/beats/filebeat/reader/multiline"
+	"github.com/elastic/beats/filebeat/reader/strip_newline"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Names of the supported parsers.
+const (
+	Container = "container"
+	Multiline = "multiline"
+	NDJSON    = "ndjson"
+)
+
+// Config is the ordered list of parsers of an input. Each entry configures
+// one parser by name, e.g.:
+//
+//   parsers:
+//     - container.stream: stdout
+//     - multiline:
+//         pattern: '^\['
+//         negate: true
+//         match: after
+//     - ndjson.target: json
+type Config []common.ConfigNamespace
+
+type containerConfig struct {
+	// Stream can be all, stdout or stderr
+	Stream string `config:"stream"`
+
+	// Partial configures the parser to join partial lines
+	Partial bool `config:"combine_partials"`
+}
+
+var defaultContainerConfig = containerConfig{
+	Stream:  "all",
+	Partial: true,
+}
+
+func (c *containerConfig) Validate() error {
+	switch c.Stream {
+	case "all", "stdout", "stderr":
+		return nil
+	}
+	return fmt.Errorf("invalid value for stream: %s, supported values are: all, stdout, stderr", c.Stream)
+}
+
+// Validate checks all parsers are known and their settings are valid.
+func (c Config) Validate() error {
+	_, err := c.Create(nopReader{}, 1)
+	return err
+}
+
+// Has returns true if a parser with the given name is configured.
+func (c Config) Has(name string) bool {
+	for _, ns := range c {
+		if ns.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Create wraps r with the configured parsers. maxBytes limits the size of
+// messages combined by the multiline parser.
+func (c Config) Create(r reader.Reader, maxBytes int) (reader.Reader, error) {
+	for i, ns := range c {
+		if !ns.IsSet() {
+			return nil, fmt.Errorf("parser %d is not configured", i)
+		}
+
+		var err error
+		r, err = create(r, ns.Name(), ns.Config(), maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error in parser %d (%s): %v", i, ns.Name(), err)
+		}
+	}
+	return r, nil
+}
+
+func create(r reader.Reader, name string, cfg *common.Config, maxBytes int) (reader.Reader, error) {
+	switch name {
+	case Container:
+		config := defaultContainerConfig
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		// The log of a container includes the newline, which is stripped
+		// like for plain lines.
+		return strip_newline.New(docker_json.New(r, config.Stream, config.Partial)), nil
+
+	case Multiline:
+		var config multiline.Config
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+		return multiline.New(r, "\n", maxBytes, &config)
+
+	case NDJSON:
+		var config json.ParserConfig
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+		return json.NewParser(r, &config), nil
+
+	default:
+		return nil, fmt.Errorf("unknown parser type: %s", name)
+	}
+}
+
+// nopReader is used to validate the parsers configuration.
+type nopReader struct{}
+
+func (nopReader) Next() (reader.Message, error) {
+	return reader.Message{}, nil
+}
@@ -0,0 +1,186 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package parser
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/reader"
+	"github.com/elastic/beats/filebeat/reader/encode"
+	"github.com/elastic/beats/filebeat/reader/encode/encoding"
+	"github.com/elastic/beats/filebeat/reader/strip_newline"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func newTestReader(t *testing.T, yaml string, lines ...string) reader.Reader {
+	cfg, err := common.NewConfigWithYAML([]byte(yaml), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Parsers Config `config:"parsers"`
+	}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	in := bytes.NewBufferString("")
+	for _, line := range lines {
+		in.WriteString(line + "\n")
+	}
+
+	encFactory, _ := encoding.FindEncoding("plain")
+	enc, err := encFactory(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := encode.New(in, enc, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsers, err := config.Parsers.Create(strip_newline.New(r), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsers
+}
+
+func readAll(t *testing.T, r reader.Reader) []reader.Message {
+	var messages []reader.Message
+	for {
+		message, err := r.Next()
+		if err == io.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, message)
+	}
+}
+
+func TestContainerMultilineNDJSON(t *testing.T) {
+	r := newTestReader(t, `
+parsers:
+  - container.stream: stdout
+  - multiline:
+      pattern: '^{'
+      negate: true
+      match: after
+  - ndjson.add_error_key: true
+`,
+		`{"log":"{\"level\":\"info\",\n","stream":"stdout","time":"2018-04-12T08:00:00.000000000Z"}`,
+		`{"log":"error on stderr\n","stream":"stderr","time":"2018-04-12T08:00:01.000000000Z"}`,
+		`{"log":" \"msg\":\"hello\"}\n","stream":"stdout","time":"2018-04-12T08:00:02.000000000Z"}`,
+		`{"log":"{\"level\":\"warn\"}\n","stream":"stdout","time":"2018-04-12T08:00:03.000000000Z"}`,
+	)
+
+	// the last message is only returned on EOF if multiline timed out
+	message, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "", string(message.Content))
+	assert.Equal(t, common.MapStr{
+		"stream": "stdout",
+		"level":  "info",
+		"msg":    "hello",
+	}, message.Fields)
+}
+
+func TestNDJSONTarget(t *testing.T) {
+	messages := readAll(t, newTestReader(t, `
+parsers:
+  - ndjson:
+      target: json
+      message_key: msg
+`,
+		`{"msg": "hello", "value": 1}`,
+		`not json`,
+	))
+
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+	assert.Equal(t, "hello", string(messages[0].Content))
+	assert.Equal(t, common.MapStr{
+		"json": common.MapStr{"msg": "hello", "value": int64(1)},
+	}, messages[0].Fields)
+
+	assert.Equal(t, "not json", string(messages[1].Content))
+	assert.Nil(t, messages[1].Fields)
+}
+
+func TestNDJSONOverwriteTimestamp(t *testing.T) {
+	messages := readAll(t, newTestReader(t, `
+parsers:
+  - ndjson.overwrite_keys: true
+`,
+		`{"@timestamp": "2018-04-12T08:00:00Z", "message": "hello"}`,
+	))
+
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	assert.Equal(t, "2018-04-12T08:00:00Z", messages[0].Ts.UTC().Format("2006-01-02T15:04:05Z"))
+	assert.Equal(t, common.MapStr{"message": "hello"}, messages[0].Fields)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := map[string]string{
+		"unknown parser": `
+parsers:
+  - unknown.enabled: true
+`,
+		"invalid multiline": `
+parsers:
+  - multiline.match: after
+`,
+		"invalid container stream": `
+parsers:
+  - container.stream: stdin
+`,
+	}
+
+	for name, yaml := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigWithYAML([]byte(yaml), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var config struct {
+				Parsers Config `config:"parsers"`
+			}
+			if err := cfg.Unpack(&config); err != nil {
+				t.Fatal(err)
+			}
+			assert.Error(t, config.Parsers.Validate())
+		})
+	}
+}
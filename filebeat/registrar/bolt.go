@@ -0,0 +1,205 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/elastic/beats/filebeat/input/file"
+	helper "github.com/elastic/beats/libbeat/common/file"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+var boltStatesBucket = []byte("states")
+
+// boltCompactMinSize is the minimum size of the database file before it is
+// considered for compaction.
+const boltCompactMinSize = 1 << 20
+
+// boltStore persists every state as separate key in a bolt database. Only
+// the states updated or removed since the last write are written.
+type boltStore struct {
+	path string
+	mode os.FileMode
+	db   *bolt.DB
+}
+
+func openBoltStore(path string, mode os.FileMode) (*boltStore, error) {
+	s := &boltStore{path: path, mode: mode}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	if err := s.compact(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("Failed to compact registry database %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *boltStore) open() error {
+	db, err := bolt.Open(s.path, s.mode, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return fmt.Errorf("Failed to open registry database %s: %v", s.path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltStatesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("Failed to initialize registry database %s: %v", s.path, err)
+	}
+
+	s.db = db
+	return nil
+}
+
+// Load returns all states stored in the database.
+func (s *boltStore) Load() ([]file.State, error) {
+	logp.Info("Loading registrar data from %s", s.path)
+
+	states := []file.State{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStatesBucket).ForEach(func(k, v []byte) error {
+			var state file.State
+			if err := json.Unmarshal(v, &state); err != nil {
+				return fmt.Errorf("Error decoding state %s: %v", k, err)
+			}
+			states = append(states, state)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// Write stores the updated states and deletes the removed states in a
+// single transaction.
+func (s *boltStore) Write(_ *file.States, updated []file.State, removed []string) (int, error) {
+	if len(updated) == 0 && len(removed) == 0 {
+		return 0, nil
+	}
+
+	return len(updated), s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltStatesBucket)
+		for _, id := range removed {
+			if err := bucket.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return putStates(bucket, updated)
+	})
+}
+
+// writeAll replaces all states in the database.
+func (s *boltStore) writeAll(states []file.State) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltStatesBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := tx.CreateBucket(boltStatesBucket)
+		if err != nil {
+			return err
+		}
+		return putStates(bucket, states)
+	})
+}
+
+func (s *boltStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// compact rewrites the database to a new file if less than half of the file
+// is in use. Bolt reuses the pages of deleted states, but never shrinks the
+// file, so the file keeps the size of the largest number of states ever
+// stored.
+func (s *boltStore) compact() error {
+	var size, inuse int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats := tx.Bucket(boltStatesBucket).Stats()
+		size = tx.Size()
+		inuse = int64(stats.BranchInuse + stats.LeafInuse + stats.InlineBucketInuse)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if size < boltCompactMinSize || 2*inuse > size {
+		return nil
+	}
+
+	logp.Info("Compacting registry database %s. Size: %d, in use: %d", s.path, size, inuse)
+
+	states, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	tempfile := s.path + ".compact"
+	if err := os.Remove(tempfile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	compacted := &boltStore{path: tempfile, mode: s.mode}
+	if err := compacted.open(); err != nil {
+		return err
+	}
+	err = compacted.writeAll(states)
+	compacted.Close()
+	if err != nil {
+		os.Remove(tempfile)
+		return err
+	}
+
+	if err := s.Close(); err != nil {
+		return err
+	}
+	if err := helper.SafeFileRotate(s.path, tempfile); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func putStates(bucket *bolt.Bucket, states []file.State) error {
+	for i := range states {
+		state := &states[i]
+		data, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("Error encoding state for %s: %v", state.Source, err)
+		}
+		if err := bucket.Put([]byte(state.ID()), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
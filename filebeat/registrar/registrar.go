@@ -18,7 +18,6 @@
 package registrar
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,7 +25,6 @@ import (
 	"time"
 
 	"github.com/elastic/beats/filebeat/input/file"
-	helper "github.com/elastic/beats/libbeat/common/file"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/monitoring"
 	"github.com/elastic/beats/libbeat/paths"
@@ -36,8 +34,11 @@ type Registrar struct {
 	Channel      chan []file.State
 	out          successLogger
 	done         chan struct{}
-	registryFile string      // Path to the Registry File
-	fileMode     os.FileMode // Permissions to apply on the Registry File
+	registryFile string        // Path to the Registry File
+	fileMode     os.FileMode   // Permissions to apply on the Registry File
+	storeType    string        // Type of the store persisting the states
+	ttl          time.Duration // Time after which states not managed by any input are removed
+	store        store
 	wg           sync.WaitGroup
 
 	states               *file.States // Map with all file paths inside and the corresponding state
@@ -45,6 +46,10 @@ type Registrar struct {
 	gcEnabled            bool         // gcEnabled indictes the registry contains some state that can be gc'ed in the future
 	flushTimeout         time.Duration
 	bufferedStateUpdates int
+
+	// IDs of the states updated or removed since the last successful write
+	updated map[string]struct{}
+	removed map[string]struct{}
 }
 
 type successLogger interface {
@@ -62,16 +67,27 @@ var (
 
 // New creates a new Registrar instance, updating the registry file on
 // `file.State` updates. New fails if the file can not be opened or created.
-func New(registryFile string, fileMode os.FileMode, flushTimeout time.Duration, out successLogger) (*Registrar, error) {
+func New(
+	registryFile string,
+	fileMode os.FileMode,
+	flushTimeout time.Duration,
+	storeType string,
+	ttl time.Duration,
+	out successLogger,
+) (*Registrar, error) {
 	r := &Registrar{
 		registryFile: registryFile,
 		fileMode:     fileMode,
+		storeType:    storeType,
+		ttl:          ttl,
 		done:         make(chan struct{}),
 		states:       file.NewStates(),
 		Channel:      make(chan []file.State, 1),
 		flushTimeout: flushTimeout,
 		out:          out,
 		wg:           sync.WaitGroup{},
+		updated:      map[string]struct{}{},
+		removed:      map[string]struct{}{},
 	}
 	err := r.Init()
 
@@ -90,69 +106,141 @@ func (r *Registrar) Init() error {
 		return fmt.Errorf("Failed to created registry file dir %s: %v", registryPath, err)
 	}
 
-	// Check if files exists
-	fileInfo, err := os.Lstat(r.registryFile)
-	if os.IsNotExist(err) {
-		logp.Info("No registry file found under: %s. Creating a new registry file.", r.registryFile)
-		// No registry exists yet, write empty state to check if registry can be written
-		return r.writeRegistry()
-	}
+	r.store, err = r.openStore()
 	if err != nil {
 		return err
 	}
 
-	// Check if regular file, no dir, no symlink
-	if !fileInfo.Mode().IsRegular() {
-		// Special error message for directory
-		if fileInfo.IsDir() {
-			return fmt.Errorf("Registry file path must be a file. %s is a directory.", r.registryFile)
+	logp.Debug("registrar", "Registry file set to: %s", r.registryFile)
+
+	return nil
+}
+
+// openStore opens the configured store. If the store does not exist yet,
+// the states are migrated from the registry of the other store type.
+func (r *Registrar) openStore() (store, error) {
+	jsonFile, boltFile := r.registryFile, r.registryFile+".db"
+
+	switch r.storeType {
+	case "", StoreTypeJSON:
+		if !fileExists(jsonFile) && fileExists(boltFile) {
+			if err := migrateFromBolt(boltFile, jsonFile, r.fileMode); err != nil {
+				return nil, fmt.Errorf("Failed to migrate registry database %s: %v", boltFile, err)
+			}
+		}
+		return openJSONStore(jsonFile, r.fileMode)
+
+	case StoreTypeBolt:
+		migrate := !fileExists(boltFile) && fileExists(jsonFile)
+		s, err := openBoltStore(boltFile, r.fileMode)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("Registry file path is not a regular file: %s", r.registryFile)
+		if migrate {
+			if err := migrateFromJSON(jsonFile, s); err != nil {
+				s.Close()
+				os.Remove(boltFile)
+				return nil, fmt.Errorf("Failed to migrate registry file %s: %v", jsonFile, err)
+			}
+		}
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("Invalid registry type: %v", r.storeType)
 	}
+}
 
-	logp.Debug("registrar", "Registry file set to: %s", r.registryFile)
+// migrateFromJSON imports the states of the JSON registry file into the bolt
+// store. The registry file is renamed afterwards, so it is not used by
+// accident when switching back to the JSON store.
+func migrateFromJSON(jsonFile string, s *boltStore) error {
+	states, err := readJSONStates(jsonFile)
+	if err != nil {
+		return err
+	}
+	if err := s.writeAll(states); err != nil {
+		return err
+	}
+	if err := os.Rename(jsonFile, jsonFile+".migrated"); err != nil {
+		return err
+	}
 
+	logp.Info("Migrated %d states from registry file %s to %s", len(states), jsonFile, s.path)
 	return nil
 }
 
+// migrateFromBolt writes the states of the bolt database to the JSON
+// registry file. The database is renamed afterwards.
+func migrateFromBolt(boltFile, jsonFile string, mode os.FileMode) error {
+	s, err := openBoltStore(boltFile, mode)
+	if err != nil {
+		return err
+	}
+	states, err := s.Load()
+	s.Close()
+	if err != nil {
+		return err
+	}
+
+	js := &jsonStore{path: jsonFile, mode: mode}
+	if err := js.writeAll(states); err != nil {
+		return err
+	}
+	if err := os.Rename(boltFile, boltFile+".migrated"); err != nil {
+		os.Remove(jsonFile)
+		return err
+	}
+
+	logp.Info("Migrated %d states from registry database %s to %s", len(states), boltFile, jsonFile)
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
 // GetStates return the registrar states
 func (r *Registrar) GetStates() []file.State {
 	return r.states.GetStates()
 }
 
-// loadStates fetches the previous reading state from the configure RegistryFile file
+// loadStates fetches the previous reading state from the configured store.
 // The default file is `registry` in the data path.
 func (r *Registrar) loadStates() error {
-	f, err := os.Open(r.registryFile)
+	states, err := r.store.Load()
 	if err != nil {
 		return err
 	}
 
-	defer f.Close()
-
-	logp.Info("Loading registrar data from %s", r.registryFile)
-
-	decoder := json.NewDecoder(f)
-	states := []file.State{}
-	err = decoder.Decode(&states)
-	if err != nil {
-		return fmt.Errorf("Error decoding states: %s", err)
-	}
-
-	states = resetStates(states)
+	states = resetStates(states, r.ttl, time.Now())
 	r.states.SetStates(states)
 	logp.Info("States Loaded from registrar: %+v", len(states))
 
+	// states not taken over by an input expire after the registry ttl
+	if r.ttl > 0 && len(states) > 0 {
+		r.gcEnabled = true
+		r.gcRequired = true
+	}
+
 	return nil
 }
 
 // resetStates sets all states to finished and disable TTL on restart
 // For all states covered by an input, TTL will be overwritten with the input value
-func resetStates(states []file.State) []file.State {
+// If ttl is set, states not covered by any input expire after ttl, counted from
+// the time the states are loaded. This way states are not removed right away
+// after a long downtime, before the inputs had a chance to take them over.
+func resetStates(states []file.State, ttl time.Duration, now time.Time) []file.State {
 	for key, state := range states {
 		state.Finished = true
-		// Set ttl to -2 to easily spot which states are not managed by a input
-		state.TTL = -2
+		if ttl > 0 {
+			state.TTL = ttl
+			state.Timestamp = now
+		} else {
+			// Set ttl to -2 to easily spot which states are not managed by a input
+			state.TTL = -2
+		}
 		states[key] = state
 	}
 	return states
@@ -237,7 +325,11 @@ func (r *Registrar) gcStates() {
 	}
 
 	beforeCount := r.states.Count()
-	cleanedStates, pendingClean := r.states.Cleanup()
+	cleanedStates, pendingClean := r.states.CleanupWith(func(state file.State) {
+		id := state.ID()
+		delete(r.updated, id)
+		r.removed[id] = struct{}{}
+	})
 	statesCleanup.Add(int64(cleanedStates))
 
 	logp.Debug("registrar",
@@ -256,6 +348,10 @@ func (r *Registrar) processEventStates(states []file.State) {
 	for i := range states {
 		r.states.UpdateWithTs(states[i], ts)
 		statesUpdate.Add(1)
+
+		id := states[i].ID()
+		delete(r.removed, id)
+		r.updated[id] = struct{}{}
 	}
 }
 
@@ -264,6 +360,10 @@ func (r *Registrar) Stop() {
 	logp.Info("Stopping Registrar")
 	close(r.done)
 	r.wg.Wait()
+
+	if err := r.store.Close(); err != nil {
+		logp.Err("Failed to close registry: %v", err)
+	}
 }
 
 func (r *Registrar) flushRegistry() {
@@ -277,57 +377,37 @@ func (r *Registrar) flushRegistry() {
 	r.bufferedStateUpdates = 0
 }
 
-// writeRegistry writes the new json registry file to disk.
+// writeRegistry writes the changed states to the registry.
 func (r *Registrar) writeRegistry() error {
 	// First clean up states
 	r.gcStates()
-	states := r.states.GetStates()
-	statesCurrent.Set(int64(len(states)))
+	statesCurrent.Set(int64(r.states.Count()))
 
 	registryWrites.Inc()
 
-	tempfile, err := writeTmpFile(r.registryFile, r.fileMode, states)
-	if err != nil {
-		registryFails.Inc()
-		return err
+	updated := make([]file.State, 0, len(r.updated))
+	for id := range r.updated {
+		state := r.states.FindPrevious(file.State{Id: id})
+		if !state.IsEmpty() {
+			updated = append(updated, state)
+		}
+	}
+	removed := make([]string, 0, len(r.removed))
+	for id := range r.removed {
+		removed = append(removed, id)
 	}
 
-	err = helper.SafeFileRotate(r.registryFile, tempfile)
+	n, err := r.store.Write(r.states, updated, removed)
 	if err != nil {
 		registryFails.Inc()
 		return err
 	}
 
-	logp.Debug("registrar", "Registry file updated. %d states written.", len(states))
+	r.updated = map[string]struct{}{}
+	r.removed = map[string]struct{}{}
+
+	logp.Debug("registrar", "Registry file updated. %d states written.", n)
 	registrySuccess.Inc()
 
 	return nil
 }
-
-func writeTmpFile(baseName string, perm os.FileMode, states []file.State) (string, error) {
-	logp.Debug("registrar", "Write registry file: %s", baseName)
-
-	tempfile := baseName + ".new"
-	f, err := os.OpenFile(tempfile, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_SYNC, perm)
-	if err != nil {
-		logp.Err("Failed to create tempfile (%s) for writing: %s", tempfile, err)
-		return "", err
-	}
-
-	defer f.Close()
-
-	encoder := json.NewEncoder(f)
-
-	if err := encoder.Encode(states); err != nil {
-		logp.Err("Error when encoding the states: %s", err)
-		return "", err
-	}
-
-	// Commit the changes to storage to avoid corrupt registry files
-	if err = f.Sync(); err != nil {
-		logp.Err("Error when syncing new registry file contents: %s", err)
-		return "", err
-	}
-
-	return tempfile, nil
-}
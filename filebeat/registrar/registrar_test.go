@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package registrar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/filebeat/input/file"
+)
+
+func newTestRegistrar(t *testing.T, registryFile, storeType string, ttl time.Duration) *Registrar {
+	r, err := New(registryFile, 0600, 0, storeType, ttl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.loadStates(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testState(source, fingerprint string, offset int64) file.State {
+	return file.State{
+		Source:      source,
+		Offset:      offset,
+		Fingerprint: fingerprint,
+		Finished:    true,
+		TTL:         -1,
+		Type:        "log",
+	}
+}
+
+func offsets(states []file.State) map[string]int64 {
+	m := map[string]int64{}
+	for _, state := range states {
+		m[state.Source] = state.Offset
+	}
+	return m
+}
+
+func TestBoltStoreIncrementalWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	registryFile := filepath.Join(dir, "registry")
+
+	r := newTestRegistrar(t, registryFile, StoreTypeBolt, 0)
+	r.onEvents([]file.State{testState("a.log", "a", 10), testState("b.log", "b", 20)})
+	assert.NoError(t, r.writeRegistry())
+
+	// nothing changed, nothing written
+	n, err := r.store.Write(r.states, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	removed := testState("b.log", "b", 20)
+	removed.TTL = 0
+	r.onEvents([]file.State{testState("a.log", "a", 15), removed})
+	assert.NoError(t, r.writeRegistry())
+	r.Stop()
+
+	assert.False(t, fileExists(registryFile))
+
+	r = newTestRegistrar(t, registryFile, StoreTypeBolt, 0)
+	defer r.Stop()
+	assert.Equal(t, map[string]int64{"a.log": 15}, offsets(r.GetStates()))
+}
+
+func TestMigrateRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	registryFile := filepath.Join(dir, "registry")
+
+	r := newTestRegistrar(t, registryFile, StoreTypeJSON, 0)
+	r.onEvents([]file.State{testState("a.log", "a", 10), testState("b.log", "b", 20)})
+	assert.NoError(t, r.writeRegistry())
+	r.Stop()
+
+	// json -> bolt
+	r = newTestRegistrar(t, registryFile, StoreTypeBolt, 0)
+	assert.Equal(t, map[string]int64{"a.log": 10, "b.log": 20}, offsets(r.GetStates()))
+	assert.False(t, fileExists(registryFile))
+	assert.True(t, fileExists(registryFile+".migrated"))
+
+	r.onEvents([]file.State{testState("a.log", "a", 30)})
+	assert.NoError(t, r.writeRegistry())
+	r.Stop()
+
+	// bolt -> json
+	r = newTestRegistrar(t, registryFile, StoreTypeJSON, 0)
+	defer r.Stop()
+	assert.Equal(t, map[string]int64{"a.log": 30, "b.log": 20}, offsets(r.GetStates()))
+	assert.False(t, fileExists(registryFile+".db"))
+	assert.True(t, fileExists(registryFile+".db.migrated"))
+}
+
+func TestRegistryTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	registryFile := filepath.Join(dir, "registry")
+
+	r := newTestRegistrar(t, registryFile, StoreTypeBolt, 0)
+	r.onEvents([]file.State{testState("stale.log", "a", 10), testState("managed.log", "b", 20)})
+	assert.NoError(t, r.writeRegistry())
+	r.Stop()
+
+	r = newTestRegistrar(t, registryFile, StoreTypeBolt, 10*time.Millisecond)
+	defer r.Stop()
+
+	// the ttl starts when the states are loaded
+	time.Sleep(20 * time.Millisecond)
+
+	// states taken over by an input are not removed
+	r.onEvents([]file.State{testState("managed.log", "b", 25)})
+	assert.NoError(t, r.writeRegistry())
+	assert.Equal(t, map[string]int64{"managed.log": 25}, offsets(r.GetStates()))
+
+	states, err := r.store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"managed.log": 25}, offsets(states))
+}
+
+func TestRegistryTTLAfterDowntime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	registryFile := filepath.Join(dir, "registry")
+
+	// the states were last updated a day before the restart
+	r := newTestRegistrar(t, registryFile, StoreTypeBolt, 0)
+	r.onEvents([]file.State{testState("a.log", "a", 10), testState("b.log", "b", 20)})
+	for _, state := range r.GetStates() {
+		r.states.UpdateWithTs(state, time.Now().Add(-24*time.Hour))
+	}
+	assert.NoError(t, r.writeRegistry())
+	r.Stop()
+
+	// the ttl starts when the states are loaded, giving the inputs time to
+	// take them over
+	r = newTestRegistrar(t, registryFile, StoreTypeBolt, time.Hour)
+	defer r.Stop()
+	assert.NoError(t, r.writeRegistry())
+	assert.Equal(t, map[string]int64{"a.log": 10, "b.log": 20}, offsets(r.GetStates()))
+	for _, state := range r.GetStates() {
+		assert.WithinDuration(t, time.Now(), state.Timestamp, time.Minute)
+	}
+}
+
+func TestBoltStoreCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.db")
+
+	s, err := openBoltStore(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var states []file.State
+	var removed []string
+	for i := 0; i < 20000; i++ {
+		state := testState(fmt.Sprintf("/var/log/pods/%d/0.log", i), fmt.Sprintf("%064d", i), 100)
+		states = append(states, state)
+		if i > 0 {
+			removed = append(removed, state.ID())
+		}
+	}
+	_, err = s.Write(nil, states, nil)
+	assert.NoError(t, err)
+	_, err = s.Write(nil, nil, removed)
+	assert.NoError(t, err)
+	s.Close()
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = openBoltStore(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, after.Size() < before.Size(), "expected %d < %d", after.Size(), before.Size())
+
+	loaded, err := s.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"/var/log/pods/0/0.log": 100}, offsets(loaded))
+}
+
+func TestInvalidRegistryType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = New(filepath.Join(dir, "registry"), 0600, 0, "sqlite", 0, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/elastic/beats/filebeat/input/file"
+	helper "github.com/elastic/beats/libbeat/common/file"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// Contains available registry store types
+const (
+	StoreTypeJSON = "json"
+	StoreTypeBolt = "bolt"
+)
+
+// store persists the registrar states.
+type store interface {
+	// Load returns all states persisted in the store.
+	Load() ([]file.State, error)
+
+	// Write persists the current states. updated and removed contain the
+	// states changed and the IDs of the states removed since the last
+	// successful write, for stores supporting incremental writes. The number
+	// of states written is returned.
+	Write(states *file.States, updated []file.State, removed []string) (int, error)
+
+	// Close releases all resources held by the store.
+	Close() error
+}
+
+// jsonStore writes all states as JSON array to the registry file on every
+// write.
+type jsonStore struct {
+	path string
+	mode os.FileMode
+}
+
+// openJSONStore checks the registry file is a regular file, creating a new
+// empty registry file if none exists.
+func openJSONStore(path string, mode os.FileMode) (*jsonStore, error) {
+	s := &jsonStore{path: path, mode: mode}
+
+	// Check if files exists
+	fileInfo, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		logp.Info("No registry file found under: %s. Creating a new registry file.", path)
+		// No registry exists yet, write empty state to check if registry can be written
+		return s, s.writeAll(nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if regular file, no dir, no symlink
+	if !fileInfo.Mode().IsRegular() {
+		// Special error message for directory
+		if fileInfo.IsDir() {
+			return nil, fmt.Errorf("Registry file path must be a file. %s is a directory.", path)
+		}
+		return nil, fmt.Errorf("Registry file path is not a regular file: %s", path)
+	}
+
+	return s, nil
+}
+
+// Load fetches the previous reading state from the registry file.
+func (s *jsonStore) Load() ([]file.State, error) {
+	return readJSONStates(s.path)
+}
+
+func (s *jsonStore) Write(states *file.States, _ []file.State, _ []string) (int, error) {
+	all := states.GetStates()
+	return len(all), s.writeAll(all)
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
+
+func (s *jsonStore) writeAll(states []file.State) error {
+	if states == nil {
+		states = []file.State{}
+	}
+
+	tempfile, err := writeTmpFile(s.path, s.mode, states)
+	if err != nil {
+		return err
+	}
+
+	return helper.SafeFileRotate(s.path, tempfile)
+}
+
+func readJSONStates(path string) ([]file.State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	logp.Info("Loading registrar data from %s", path)
+
+	decoder := json.NewDecoder(f)
+	states := []file.State{}
+	err = decoder.Decode(&states)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding states: %s", err)
+	}
+	return states, nil
+}
+
+func writeTmpFile(baseName string, perm os.FileMode, states []file.State) (string, error) {
+	logp.Debug("registrar", "Write registry file: %s", baseName)
+
+	tempfile := baseName + ".new"
+	f, err := os.OpenFile(tempfile, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_SYNC, perm)
+	if err != nil {
+		logp.Err("Failed to create tempfile (%s) for writing: %s", tempfile, err)
+		return "", err
+	}
+
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+
+	if err := encoder.Encode(states); err != nil {
+		logp.Err("Error when encoding the states: %s", err)
+		return "", err
+	}
+
+	// Commit the changes to storage to avoid corrupt registry files
+	if err = f.Sync(); err != nil {
+		logp.Err("Error when syncing new registry file contents: %s", err)
+		return "", err
+	}
+
+	return tempfile, nil
+}
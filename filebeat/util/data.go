@@ -20,10 +20,15 @@ package util
 import (
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/acker"
 
 	"github.com/elastic/beats/filebeat/input/file"
 )
 
+// ACKer can be implemented by the events Private field. ACK is called once
+// the event has been ACKed by the output.
+type ACKer = acker.EventACKer
+
 type Data struct {
 	Event beat.Event
 	state file.State
@@ -227,6 +227,7 @@ func (m *monitor) Update(configs []*common.Config) error {
 		})
 		if err != nil {
 			logp.Critical("Fail to connect job '%v' to publisher pipeline: %v", id, err)
+			processors.Close()
 			continue
 		}
 
@@ -234,6 +235,7 @@ func (m *monitor) Update(configs []*common.Config) error {
 		jobCancel := m.manager.jobControl.Add(t.config.Schedule, id, job)
 		t.cancel = func() error {
 			client.Close()
+			processors.Close()
 			return jobCancel()
 		}
 
@@ -58,5 +58,8 @@ func Status(err error) string {
 	if err == nil {
 		return "up"
 	}
+	if _, degraded := err.(reason.DegradedError); degraded {
+		return "degraded"
+	}
 	return "down"
 }
@@ -18,12 +18,16 @@
 package http
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 
+	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/common/match"
+	"github.com/elastic/beats/libbeat/processors"
 )
 
 type RespCheck func(*http.Response) error
@@ -32,7 +36,7 @@ var (
 	errBodyMismatch = errors.New("body mismatch")
 )
 
-func makeValidateResponse(config *responseParameters) RespCheck {
+func makeValidateResponse(config *responseParameters) (RespCheck, error) {
 	var checks []RespCheck
 
 	if config.Status > 0 {
@@ -49,7 +53,15 @@ func makeValidateResponse(config *responseParameters) RespCheck {
 		checks = append(checks, checkBody(config.RecvBody))
 	}
 
-	return checkAll(checks...)
+	if len(config.RecvJSON) > 0 {
+		check, err := checkJSON(config.RecvJSON)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+
+	return checkAll(checks...), nil
 }
 
 func checkOK(_ *http.Response) error { return nil }
@@ -101,9 +113,20 @@ func checkHeaders(headers map[string]string) RespCheck {
 	}
 }
 
+// readBody reads the complete response body, replacing the body so it can be
+// read again by following checks.
+func readBody(r *http.Response) ([]byte, error) {
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(content))
+	return content, nil
+}
+
 func checkBody(body []match.Matcher) RespCheck {
 	return func(r *http.Response) error {
-		content, err := ioutil.ReadAll(r.Body)
+		content, err := readBody(r)
 		if err != nil {
 			return err
 		}
@@ -115,3 +138,38 @@ func checkBody(body []match.Matcher) RespCheck {
 		return errBodyMismatch
 	}
 }
+
+func checkJSON(checks []jsonResponseCheck) (RespCheck, error) {
+	type compiledCheck struct {
+		description string
+		condition   *processors.Condition
+	}
+
+	compiled := make([]compiledCheck, len(checks))
+	for i, check := range checks {
+		cond, err := processors.NewCondition(check.Condition)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = compiledCheck{check.Description, cond}
+	}
+
+	return func(r *http.Response) error {
+		content, err := readBody(r)
+		if err != nil {
+			return err
+		}
+
+		decoded := common.MapStr{}
+		if err := json.Unmarshal(content, &decoded); err != nil {
+			return fmt.Errorf("could not parse JSON body: %v", err)
+		}
+
+		for _, check := range compiled {
+			if !check.condition.Check(decoded) {
+				return fmt.Errorf("JSON body did not match condition '%s'", check.description)
+			}
+		}
+		return nil
+	}, nil
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/elastic/beats/libbeat/common/match"
 	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/processors"
 
 	"github.com/elastic/beats/heartbeat/monitors"
 )
@@ -47,6 +48,28 @@ type Config struct {
 
 	// http(s) ping validation
 	Check checkConfig `config:"check"`
+
+	// sequence of requests to execute instead of the single check request
+	Steps []stepConfig `config:"steps"`
+}
+
+// stepConfig configures one request of a multi-step check. Headers and
+// cookies set by previous steps are sent with the request.
+type stepConfig struct {
+	Name     string             `config:"name"`
+	URL      string             `config:"url"` // absolute or relative to the monitored URL
+	Request  requestParameters  `config:"request"`
+	Response responseParameters `config:"response"`
+	Extract  []extractConfig    `config:"extract"`
+}
+
+// extractConfig sets a request header for all following steps from a value
+// found in the response.
+type extractConfig struct {
+	Header     string `config:"header" validate:"required"`
+	FromHeader string `config:"from_header"`
+	FromJSON   string `config:"from_json"`
+	Prefix     string `config:"prefix"`
 }
 
 type checkConfig struct {
@@ -68,9 +91,15 @@ type requestParameters struct {
 
 type responseParameters struct {
 	// expected HTTP response configuration
-	Status      uint16            `config:"status" verify:"min=0, max=699"`
-	RecvHeaders map[string]string `config:"headers"`
-	RecvBody    []match.Matcher   `config:"body"`
+	Status      uint16              `config:"status" verify:"min=0, max=699"`
+	RecvHeaders map[string]string   `config:"headers"`
+	RecvBody    []match.Matcher     `config:"body"`
+	RecvJSON    []jsonResponseCheck `config:"json"`
+}
+
+type jsonResponseCheck struct {
+	Description string                      `config:"description"`
+	Condition   *processors.ConditionConfig `config:"condition" validate:"required"`
 }
 
 type compressionConfig struct {
@@ -99,7 +128,7 @@ var defaultConfig = Config{
 
 func (r *requestParameters) Validate() error {
 	switch strings.ToUpper(r.Method) {
-	case "HEAD", "GET", "POST":
+	case "", "HEAD", "GET", "POST":
 	default:
 		return fmt.Errorf("HTTP method '%v' not supported", r.Method)
 	}
@@ -123,3 +152,10 @@ func (c *compressionConfig) Validate() error {
 
 	return nil
 }
+
+func (e *extractConfig) Validate() error {
+	if (e.FromHeader == "") == (e.FromJSON == "") {
+		return fmt.Errorf("exactly one of from_header or from_json must be set to extract header '%v'", e.Header)
+	}
+	return nil
+}
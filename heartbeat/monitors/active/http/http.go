@@ -70,11 +70,26 @@ func create(
 		body = buf.Bytes()
 	}
 
-	validator := makeValidateResponse(&config.Check.Response)
+	validator, err := makeValidateResponse(&config.Check.Response)
+	if err != nil {
+		return nil, err
+	}
 
 	jobs := make([]monitors.Job, len(config.URLs))
 
-	if config.ProxyURL != "" {
+	if len(config.Steps) > 0 {
+		transport, err := newRoundTripper(&config, tls)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, url := range config.URLs {
+			jobs[i], err = newHTTPMonitorStepsJob(url, &config, transport)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if config.ProxyURL != "" {
 		transport, err := newRoundTripper(&config, tls)
 		if err != nil {
 			return nil, err
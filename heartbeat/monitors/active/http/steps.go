@@ -0,0 +1,283 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+
+	"github.com/elastic/beats/heartbeat/look"
+	"github.com/elastic/beats/heartbeat/monitors"
+	"github.com/elastic/beats/heartbeat/reason"
+)
+
+// step is a compiled request of a multi-step check.
+type step struct {
+	name      string
+	url       *url.URL
+	method    string
+	headers   map[string]string
+	enc       contentEncoder
+	body      []byte
+	validator RespCheck
+	extract   []extractConfig
+}
+
+func newHTTPMonitorStepsJob(
+	addr string,
+	config *Config,
+	transport *http.Transport,
+) (monitors.Job, error) {
+	typ := config.Name
+	jobName := fmt.Sprintf("%v@%v", typ, addr)
+
+	base, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]step, len(config.Steps))
+	for i, stepConfig := range config.Steps {
+		steps[i], err = compileStep(base, i, &stepConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, port, err := splitHostnamePort(&http.Request{URL: base})
+	if err != nil {
+		return nil, err
+	}
+
+	settings := monitors.MakeJobSetting(jobName).WithFields(common.MapStr{
+		"monitor": common.MapStr{
+			"scheme": base.Scheme,
+			"host":   hostname,
+		},
+		"http": common.MapStr{
+			"url": base.String(),
+		},
+		"tcp": common.MapStr{
+			"port": port,
+		},
+	})
+
+	return monitors.MakeSimpleJob(settings, func() (common.MapStr, error) {
+		// cookies are shared by all steps of a single check only
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{
+			CheckRedirect: makeCheckRedirect(config.MaxRedirects),
+			Transport:     transport,
+			Timeout:       config.Timeout,
+			Jar:           jar,
+		}
+
+		event, err := execSteps(client, config, steps)
+		return event, err
+	}), nil
+}
+
+func compileStep(base *url.URL, i int, config *stepConfig) (step, error) {
+	name := config.Name
+	if name == "" {
+		name = fmt.Sprintf("step %d", i+1)
+	}
+
+	ref, err := url.Parse(config.URL)
+	if err != nil {
+		return step{}, fmt.Errorf("invalid url in %v: %v", name, err)
+	}
+
+	validator, err := makeValidateResponse(&config.Response)
+	if err != nil {
+		return step{}, err
+	}
+
+	s := step{
+		name:      name,
+		url:       base.ResolveReference(ref),
+		method:    strings.ToUpper(config.Request.Method),
+		headers:   config.Request.SendHeaders,
+		validator: validator,
+		extract:   config.Extract,
+	}
+
+	if config.Request.SendBody != "" {
+		compression := config.Request.Compression
+		s.enc, err = getContentEncoder(compression.Type, compression.Level)
+		if err != nil {
+			return step{}, err
+		}
+
+		buf := bytes.NewBuffer(nil)
+		err = s.enc.Encode(buf, bytes.NewBufferString(config.Request.SendBody))
+		if err != nil {
+			return step{}, err
+		}
+		s.body = buf.Bytes()
+	}
+
+	return s, nil
+}
+
+// execSteps executes all steps in order, stopping at the first failed step.
+// The results of all executed steps are reported in http.steps.
+func execSteps(client *http.Client, config *Config, steps []step) (common.MapStr, reason.Reason) {
+	headers := map[string]string{}
+	for k, v := range config.Check.Request.SendHeaders {
+		headers[k] = v
+	}
+
+	var results []common.MapStr
+	var total time.Duration
+	var status int
+	var err reason.Reason
+
+	for _, s := range steps {
+		var result common.MapStr
+		var rtt time.Duration
+		status, rtt, result, err = execStep(client, config, s, headers)
+		total += rtt
+		results = append(results, result)
+		if err != nil {
+			break
+		}
+	}
+
+	event := common.MapStr{"http": common.MapStr{
+		"steps": results,
+		"rtt": common.MapStr{
+			"total": look.RTT(total),
+		},
+	}}
+	if status != 0 {
+		event.Put("http.response.status_code", status)
+	}
+	return event, err
+}
+
+// execStep sends the request of a step and validates the response. Values
+// extracted from the response are added to headers.
+func execStep(
+	client *http.Client,
+	config *Config,
+	s step,
+	headers map[string]string,
+) (int, time.Duration, common.MapStr, reason.Reason) {
+	result := common.MapStr{
+		"name": s.name,
+		"url":  s.url.String(),
+	}
+	fail := func(makeReason func(error) reason.Reason, err error) reason.Reason {
+		result["error"] = reason.Fail(makeReason(err))
+		return makeReason(fmt.Errorf("%v failed: %v", s.name, err))
+	}
+
+	req, err := http.NewRequest(s.method, s.url.String(), nil)
+	if err != nil {
+		return 0, 0, result, fail(reason.IOFailed, err)
+	}
+	if config.Username != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.enc != nil {
+		s.enc.AddHeaders(&req.Header)
+	}
+	if len(s.body) > 0 {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(s.body))
+		req.ContentLength = int64(len(s.body))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		rtt := time.Now().Sub(start)
+		result["rtt"] = common.MapStr{"total": look.RTT(rtt)}
+		return 0, rtt, result, fail(reason.IOFailed, err)
+	}
+	defer resp.Body.Close()
+
+	result["response"] = common.MapStr{"status_code": resp.StatusCode}
+
+	content, err := readBody(resp)
+	if err != nil {
+		rtt := time.Now().Sub(start)
+		result["rtt"] = common.MapStr{"total": look.RTT(rtt)}
+		return resp.StatusCode, rtt, result, fail(reason.IOFailed, err)
+	}
+
+	err = s.validator(resp)
+	rtt := time.Now().Sub(start)
+	result["rtt"] = common.MapStr{"total": look.RTT(rtt)}
+	if err != nil {
+		return resp.StatusCode, rtt, result, fail(reason.ValidateFailed, err)
+	}
+
+	for _, e := range s.extract {
+		value, err := extractValue(e, resp, content)
+		if err != nil {
+			return resp.StatusCode, rtt, result, fail(reason.ValidateFailed, err)
+		}
+		headers[e.Header] = e.Prefix + value
+	}
+
+	return resp.StatusCode, rtt, result, nil
+}
+
+func extractValue(e extractConfig, resp *http.Response, content []byte) (string, error) {
+	if e.FromHeader != "" {
+		value := resp.Header.Get(e.FromHeader)
+		if value == "" {
+			return "", fmt.Errorf("response header %v missing", e.FromHeader)
+		}
+		return value, nil
+	}
+
+	decoded := common.MapStr{}
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return "", fmt.Errorf("could not parse JSON body: %v", err)
+	}
+	value, err := decoded.GetValue(e.FromJSON)
+	if err != nil {
+		return "", fmt.Errorf("JSON field %v missing", e.FromJSON)
+	}
+	return fmt.Sprint(value), nil
+}
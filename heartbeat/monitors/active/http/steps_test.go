@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/heartbeat/monitors"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func newLoginServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		http.Redirect(w, r, "/welcome", http.StatusFound)
+	})
+	mux.HandleFunc("/welcome", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "abc"})
+	})
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "users": 3})
+	})
+	return httptest.NewServer(mux)
+}
+
+func runSteps(t *testing.T, url string, steps []map[string]interface{}) common.MapStr {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"urls":  []string{url},
+		"steps": steps,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := create(monitors.Info{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+
+	event, _, err := jobs[0].Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event.Fields
+}
+
+func loginSteps() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"name":           "login",
+			"url":            "/login",
+			"request.method": "POST",
+			"request.body":   "user=test",
+			"extract": []map[string]interface{}{
+				{"header": "Authorization", "from_json": "token", "prefix": "Bearer "},
+			},
+		},
+		{
+			"url": "/api/status",
+			"response.json": []map[string]interface{}{
+				{
+					"description": "status ok",
+					"condition":   map[string]interface{}{"equals.status": "ok"},
+				},
+			},
+		},
+	}
+}
+
+func TestSteps(t *testing.T) {
+	server := newLoginServer()
+	defer server.Close()
+
+	fields := runSteps(t, server.URL, loginSteps())
+	assert.Equal(t, "up", fields["monitor"].(common.MapStr)["status"])
+
+	status, _ := fields.GetValue("http.response.status_code")
+	assert.Equal(t, 200, status)
+
+	v, _ := fields.GetValue("http.steps")
+	steps, ok := v.([]common.MapStr)
+	if !assert.True(t, ok) || !assert.Len(t, steps, 2) {
+		return
+	}
+	assert.Equal(t, "login", steps[0]["name"])
+	assert.Equal(t, server.URL+"/login", steps[0]["url"])
+	assert.Equal(t, "step 2", steps[1]["name"])
+	assert.Equal(t, common.MapStr{"status_code": 200}, steps[1]["response"])
+	assert.NotContains(t, steps[1], "error")
+}
+
+func TestStepsFailure(t *testing.T) {
+	server := newLoginServer()
+	defer server.Close()
+
+	steps := loginSteps()
+	steps[1]["response.json"] = []map[string]interface{}{
+		{
+			"description": "has admins",
+			"condition":   map[string]interface{}{"range.users.gte": 5},
+		},
+	}
+
+	fields := runSteps(t, server.URL, steps)
+	assert.Equal(t, "down", fields["monitor"].(common.MapStr)["status"])
+	assert.Contains(t, fields, "error")
+
+	v, _ := fields.GetValue("http.steps")
+	results, ok := v.([]common.MapStr)
+	if !assert.True(t, ok) || !assert.Len(t, results, 2) {
+		return
+	}
+	assert.NotContains(t, results[0], "error")
+	failure, ok := results[1]["error"].(common.MapStr)
+	if assert.True(t, ok) {
+		assert.Equal(t, "validate", failure["type"])
+	}
+
+	// without the login the cookie and token are missing
+	fields = runSteps(t, server.URL, steps[1:])
+	assert.Equal(t, "down", fields["monitor"].(common.MapStr)["status"])
+	status, _ := fields.GetValue("http.response.status_code")
+	assert.Equal(t, 401, status)
+}
@@ -0,0 +1,226 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tls
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+
+	"github.com/elastic/beats/heartbeat/look"
+	"github.com/elastic/beats/heartbeat/reason"
+)
+
+// Revocation status of the server certificate.
+const (
+	revocationGood    = "good"
+	revocationRevoked = "revoked"
+	revocationUnknown = "unknown"
+)
+
+type certChecker struct {
+	tls         *transport.TLSConfig
+	timeout     time.Duration
+	warningDays int
+	revocation  revocationConfig
+	client      *http.Client
+
+	// used by tests
+	now func() time.Time
+}
+
+func newCertChecker(tls *transport.TLSConfig, config *Config) *certChecker {
+	return &certChecker{
+		tls:         tls,
+		timeout:     config.Timeout,
+		warningDays: config.Check.ExpiryWarningDays,
+		revocation:  config.Check.Revocation,
+		client:      &http.Client{Timeout: config.Timeout},
+		now:         time.Now,
+	}
+}
+
+// check runs the TLS handshake with the host and reports the certificate
+// chain. Expired or revoked certificates fail the check, certificates expiring
+// within the warning period report the monitor as degraded.
+func (c *certChecker) check(dialer transport.Dialer, addr string) (common.MapStr, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	start := c.now()
+	socket, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		debugf("dial failed with: %v", err)
+		return nil, reason.IOFailed(err)
+	}
+	defer socket.Close()
+
+	conn := cryptotls.Client(socket, c.tls.BuildModuleConfig(host))
+	if err := conn.SetDeadline(start.Add(c.timeout)); err != nil {
+		return nil, reason.IOFailed(err)
+	}
+
+	handshakeStart := time.Now()
+	if err := conn.Handshake(); err != nil {
+		debugf("handshake failed with: %v", err)
+		switch err.(type) {
+		case x509.CertificateInvalidError, x509.UnknownAuthorityError, x509.HostnameError:
+			return nil, reason.ValidateFailed(err)
+		}
+		return nil, reason.IOFailed(err)
+	}
+	handshakeEnd := time.Now()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, reason.ValidateFailed(errors.New("no server certificate received"))
+	}
+
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+
+	now := c.now()
+	certs := make([]common.MapStr, len(chain))
+	expiry := chain[0]
+	for i, cert := range chain {
+		certs[i] = certificateFields(cert)
+		if cert.NotAfter.Before(expiry.NotAfter) {
+			expiry = cert
+		}
+	}
+	days := int(expiry.NotAfter.Sub(now) / (24 * time.Hour))
+
+	event := common.MapStr{
+		"tls": common.MapStr{
+			"rtt": common.MapStr{
+				"handshake": look.RTT(handshakeEnd.Sub(handshakeStart)),
+			},
+			"version":      tlscommon.ResolveTLSVersion(state.Version),
+			"cipher":       tlscommon.ResolveCipherSuite(state.CipherSuite),
+			"certificates": certs,
+			"expiry": common.MapStr{
+				"not_after": look.Timestamp(expiry.NotAfter),
+				"days":      days,
+				"subject":   expiry.Subject.String(),
+			},
+		},
+	}
+
+	if now.Before(expiry.NotBefore) || now.After(expiry.NotAfter) {
+		return event, reason.ValidateFailed(fmt.Errorf("certificate '%v' is not valid at %v, valid from %v until %v",
+			expiry.Subject, now, expiry.NotBefore, expiry.NotAfter))
+	}
+
+	if c.revocation.OCSP || c.revocation.CRL {
+		status, method, err := c.checkRevocation(chain)
+		revocation := common.MapStr{"status": status}
+		if method != "" {
+			revocation["method"] = method
+		}
+		if err != nil {
+			revocation["error"] = err.Error()
+		}
+		event.Put("tls.revocation", revocation)
+
+		if status == revocationRevoked {
+			return event, reason.ValidateFailed(fmt.Errorf("certificate '%v' has been revoked", chain[0].Subject))
+		}
+	}
+
+	if days < c.warningDays {
+		return event, reason.Degraded(fmt.Errorf("certificate '%v' expires in %v days", expiry.Subject, days))
+	}
+	return event, nil
+}
+
+// checkRevocation checks the revocation status of the server certificate,
+// using OCSP first and falling back to CRLs if enabled.
+func (c *certChecker) checkRevocation(chain []*x509.Certificate) (status, method string, err error) {
+	if len(chain) < 2 {
+		return revocationUnknown, "", errors.New("issuer certificate not available")
+	}
+	cert, issuer := chain[0], chain[1]
+
+	if c.revocation.OCSP && len(cert.OCSPServer) > 0 {
+		status, err = c.checkOCSP(cert, issuer)
+		if status != revocationUnknown || !c.revocation.CRL {
+			return status, "ocsp", err
+		}
+	}
+
+	if c.revocation.CRL && len(cert.CRLDistributionPoints) > 0 {
+		status, err = c.checkCRL(cert, issuer)
+		return status, "crl", err
+	}
+
+	if err == nil {
+		err = errors.New("certificate has no revocation information")
+	}
+	return revocationUnknown, "", err
+}
+
+func certificateFields(cert *x509.Certificate) common.MapStr {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	fields := common.MapStr{
+		"subject":              cert.Subject.String(),
+		"issuer":               cert.Issuer.String(),
+		"serial_number":        cert.SerialNumber.String(),
+		"not_before":           look.Timestamp(cert.NotBefore),
+		"not_after":            look.Timestamp(cert.NotAfter),
+		"signature_algorithm":  cert.SignatureAlgorithm.String(),
+		"public_key_algorithm": publicKeyAlgorithm(cert.PublicKeyAlgorithm),
+		"fingerprint": common.MapStr{
+			"sha1":   hex.EncodeToString(sha1Sum[:]),
+			"sha256": hex.EncodeToString(sha256Sum[:]),
+		},
+	}
+	if len(cert.DNSNames) > 0 {
+		fields["dns_names"] = cert.DNSNames
+	}
+	return fields
+}
+
+func publicKeyAlgorithm(algo x509.PublicKeyAlgorithm) string {
+	switch algo {
+	case x509.RSA:
+		return "RSA"
+	case x509.DSA:
+		return "DSA"
+	case x509.ECDSA:
+		return "ECDSA"
+	default:
+		return "unknown"
+	}
+}
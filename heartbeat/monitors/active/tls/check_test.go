@@ -0,0 +1,476 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/heartbeat/monitors"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// OCSP messages used by the test responder, see RFC 6960.
+
+var oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest struct {
+		Version     int `asn1:"explicit,tag:0,default:0,optional"`
+		RequestList []struct {
+			Cert certID
+		}
+	}
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID     certID
+	Good       asn1.Flag   `asn1:"tag:0,optional"`
+	Revoked    revokedInfo `asn1:"tag:1,optional"`
+	ThisUpdate time.Time   `asn1:"generalized"`
+	NextUpdate time.Time   `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	cert := createCert(t, template, template, &key.PublicKey, key)
+
+	f, err := ioutil.TempFile("", "heartbeat-tls-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+
+	return &testCA{cert: cert, key: key, file: f.Name()}
+}
+
+func (ca *testCA) close() {
+	os.Remove(ca.file)
+}
+
+// issue creates a server certificate for 127.0.0.1, valid for the given
+// duration.
+func (ca *testCA) issue(t *testing.T, serial int64, validFor time.Duration, ocsp, crl string) cryptotls.Certificate {
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocsp != "" {
+		template.OCSPServer = []string{ocsp}
+	}
+	if crl != "" {
+		template.CRLDistributionPoints = []string{crl}
+	}
+	cert := createCert(t, template, ca.cert, &key.PublicKey, ca.key)
+
+	return cryptotls.Certificate{
+		Certificate: [][]byte{cert.Raw, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+}
+
+// ocspResponder creates an OCSP responder answering all requests with the
+// given status.
+func (ca *testCA) ocspResponder(t *testing.T, revoked bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil || len(req.TBSRequest.RequestList) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		single := singleResponse{
+			CertID:     req.TBSRequest.RequestList[0].Cert,
+			ThisUpdate: now,
+			NextUpdate: now.Add(time.Hour),
+		}
+		if revoked {
+			single.Revoked = revokedInfo{RevocationTime: now.Add(-time.Minute)}
+		} else {
+			single.Good = true
+		}
+
+		keyHash := sha1.Sum(ca.cert.RawSubjectPublicKeyInfo)
+		responderID, _ := asn1.Marshal(keyHash[:])
+		tbs, err := asn1.Marshal(responseData{
+			RawResponderID: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        2,
+				IsCompound: true,
+				Bytes:      responderID,
+			},
+			ProducedAt: now,
+			Responses:  []singleResponse{single},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		digest := crypto.SHA256.New()
+		digest.Write(tbs)
+		signature, err := ca.key.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		basic, err := asn1.Marshal(basicResponse{
+			TBSResponseData:    responseData{Raw: tbs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := asn1.Marshal(ocspResponse{
+			Response: responseBytes{ResponseType: oidOCSPBasic, Response: basic},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+}
+
+// crlServer serves a CRL listing the given serial numbers as revoked.
+func (ca *testCA) crlServer(t *testing.T, revoked ...int64) *httptest.Server {
+	now := time.Now()
+	var entries []pkix.RevokedCertificate
+	for _, serial := range revoked {
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: now.Add(-time.Minute),
+		})
+	}
+	crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, entries, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func createCert(t *testing.T, template, parent *x509.Certificate, pub, priv interface{}) *x509.Certificate {
+	raw, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newTLSServer(cert cryptotls.Certificate) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &cryptotls.Config{Certificates: []cryptotls.Certificate{cert}}
+	server.StartTLS()
+	return server
+}
+
+func runCheck(t *testing.T, ca *testCA, server *httptest.Server, settings map[string]interface{}) common.MapStr {
+	settings["hosts"] = []string{server.Listener.Addr().String()}
+	settings["ssl.certificate_authorities"] = []string{ca.file}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := create(monitors.Info{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+
+	event, _, err := jobs[0].Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event.Fields
+}
+
+func TestCheckCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+
+	server := newTLSServer(ca.issue(t, 2, 90*24*time.Hour, "", ""))
+	defer server.Close()
+
+	fields := runCheck(t, ca, server, map[string]interface{}{})
+
+	status, _ := fields.GetValue("monitor.status")
+	assert.Equal(t, "up", status)
+
+	days, _ := fields.GetValue("tls.expiry.days")
+	assert.Equal(t, 89, days)
+	subject, _ := fields.GetValue("tls.expiry.subject")
+	assert.Equal(t, "CN=localhost", subject)
+
+	v, _ := fields.GetValue("tls.certificates")
+	certs, ok := v.([]common.MapStr)
+	if !assert.True(t, ok) || !assert.Len(t, certs, 2) {
+		return
+	}
+	assert.Equal(t, "CN=localhost", certs[0]["subject"])
+	assert.Equal(t, "CN=test CA", certs[0]["issuer"])
+	assert.Equal(t, "2", certs[0]["serial_number"])
+	assert.Equal(t, "ECDSA", certs[0]["public_key_algorithm"])
+	assert.Equal(t, []string{"localhost"}, certs[0]["dns_names"])
+	assert.Equal(t, "CN=test CA", certs[1]["subject"])
+
+	_, err := fields.GetValue("tls.revocation")
+	assert.Error(t, err)
+}
+
+func TestCheckCertificateExpiryWarning(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+
+	server := newTLSServer(ca.issue(t, 2, 10*24*time.Hour, "", ""))
+	defer server.Close()
+
+	tests := []struct {
+		warningDays int
+		status      string
+	}{
+		{0, "up"},
+		{5, "up"},
+		{30, "degraded"},
+	}
+
+	for _, test := range tests {
+		fields := runCheck(t, ca, server, map[string]interface{}{
+			"check.expiry.warning_days": test.warningDays,
+		})
+
+		status, _ := fields.GetValue("monitor.status")
+		assert.Equal(t, test.status, status, "warning_days: %v", test.warningDays)
+		if test.status == "degraded" {
+			typ, _ := fields.GetValue("error.type")
+			assert.Equal(t, "degraded", typ)
+		}
+	}
+}
+
+func TestCheckCertificateUnknownAuthority(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+	other := newTestCA(t)
+	defer other.close()
+
+	server := newTLSServer(other.issue(t, 2, 90*24*time.Hour, "", ""))
+	defer server.Close()
+
+	fields := runCheck(t, ca, server, map[string]interface{}{})
+
+	status, _ := fields.GetValue("monitor.status")
+	assert.Equal(t, "down", status)
+	_, err := fields.GetValue("tls.certificates")
+	assert.Error(t, err)
+}
+
+func TestCheckRevocationOCSP(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+
+	for _, revoked := range []bool{false, true} {
+		responder := ca.ocspResponder(t, revoked)
+		server := newTLSServer(ca.issue(t, 2, 90*24*time.Hour, responder.URL, ""))
+
+		fields := runCheck(t, ca, server, map[string]interface{}{
+			"check.revocation.ocsp": true,
+		})
+		server.Close()
+		responder.Close()
+
+		rev, _ := fields.GetValue("tls.revocation")
+		status, _ := fields.GetValue("monitor.status")
+		if revoked {
+			assert.Equal(t, "down", status)
+			assert.Equal(t, common.MapStr{"status": "revoked", "method": "ocsp"}, rev)
+		} else {
+			assert.Equal(t, "up", status)
+			assert.Equal(t, common.MapStr{"status": "good", "method": "ocsp"}, rev)
+		}
+	}
+}
+
+func TestCheckRevocationCRL(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+
+	crl := ca.crlServer(t, 3)
+	defer crl.Close()
+
+	tests := []struct {
+		serial int64
+		status string
+		result string
+	}{
+		{2, "up", "good"},
+		{3, "down", "revoked"},
+	}
+
+	for _, test := range tests {
+		server := newTLSServer(ca.issue(t, test.serial, 90*24*time.Hour, "", crl.URL))
+		fields := runCheck(t, ca, server, map[string]interface{}{
+			"check.revocation.crl": true,
+		})
+		server.Close()
+
+		status, _ := fields.GetValue("monitor.status")
+		assert.Equal(t, test.status, status)
+		rev, _ := fields.GetValue("tls.revocation")
+		assert.Equal(t, common.MapStr{"status": test.result, "method": "crl"}, rev)
+	}
+}
+
+func TestCheckRevocationFallback(t *testing.T) {
+	ca := newTestCA(t)
+	defer ca.close()
+
+	// OCSP responder not available, fall back to the CRL
+	crl := ca.crlServer(t)
+	defer crl.Close()
+
+	server := newTLSServer(ca.issue(t, 2, 90*24*time.Hour, "http://127.0.0.1:1", crl.URL))
+	defer server.Close()
+
+	fields := runCheck(t, ca, server, map[string]interface{}{
+		"check.revocation.ocsp": true,
+		"check.revocation.crl":  true,
+	})
+
+	status, _ := fields.GetValue("monitor.status")
+	assert.Equal(t, "up", status)
+	rev, _ := fields.GetValue("tls.revocation")
+	assert.Equal(t, common.MapStr{"status": "good", "method": "crl"}, rev)
+}
+
+func TestCollectHosts(t *testing.T) {
+	config := DefaultConfig
+	config.Hosts = []string{"example.com", "tls://example.net:8443", "example.org:993"}
+
+	endpoints, err := collectHosts(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []uint16{443}, endpoints[0].Ports)
+	assert.Equal(t, "example.net", endpoints[1].Host)
+	assert.Equal(t, []uint16{8443}, endpoints[1].Ports)
+	assert.Equal(t, "example.org", endpoints[2].Host)
+	assert.Equal(t, []uint16{993}, endpoints[2].Ports)
+
+	config.Hosts = []string{"http://example.com"}
+	_, err = collectHosts(&config)
+	assert.Error(t, err)
+}
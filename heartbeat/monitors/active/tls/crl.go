@@ -0,0 +1,92 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tls
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const maxCRLSize = int64(32 << 20)
+
+// checkCRL downloads the CRLs published by the issuer of the certificate and
+// checks if the certificate serial number is listed. Only http distribution
+// points are supported.
+func (c *certChecker) checkCRL(cert, issuer *x509.Certificate) (string, error) {
+	var lastErr error
+	for _, point := range cert.CRLDistributionPoints {
+		if !strings.HasPrefix(point, "http://") && !strings.HasPrefix(point, "https://") {
+			continue
+		}
+
+		revoked, err := c.fetchCRL(point, cert, issuer)
+		if err != nil {
+			lastErr = fmt.Errorf("invalid CRL from %v: %v", point, err)
+			continue
+		}
+		if revoked {
+			return revocationRevoked, nil
+		}
+		return revocationGood, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no supported CRL distribution point")
+	}
+	return revocationUnknown, lastErr
+}
+
+func (c *certChecker) fetchCRL(url string, cert, issuer *x509.Certificate) (bool, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("server returned %v", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return false, err
+	}
+
+	crl, err := x509.ParseCRL(body)
+	if err != nil {
+		return false, err
+	}
+	if err := issuer.CheckCRLSignature(crl); err != nil {
+		return false, err
+	}
+	if crl.HasExpired(c.now()) {
+		return false, errors.New("CRL expired")
+	}
+
+	for _, entry := range crl.TBSCertList.RevokedCertificates {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
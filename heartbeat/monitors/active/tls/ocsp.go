@@ -0,0 +1,62 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+const maxOCSPResponseSize = int64(1 << 20)
+
+// checkOCSP queries the OCSP responders of the certificate, returning the
+// status of the first valid response.
+func (c *certChecker) checkOCSP(cert, issuer *x509.Certificate) (string, error) {
+	req, err := tlscommon.NewOCSPRequest(cert, issuer)
+	if err != nil {
+		return revocationUnknown, err
+	}
+
+	var lastErr error
+	for _, server := range cert.OCSPServer {
+		resp, err := c.client.Post(server, "application/ocsp-request", bytes.NewReader(req))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		status, err := tlscommon.ParseOCSPResponse(body, cert, issuer, c.now())
+		if err != nil {
+			lastErr = fmt.Errorf("invalid OCSP response from %v: %v", server, err)
+			continue
+		}
+		return status.String(), nil
+	}
+	return revocationUnknown, lastErr
+}
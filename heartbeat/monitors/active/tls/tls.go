@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tls
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+
+	"github.com/elastic/beats/heartbeat/monitors"
+	"github.com/elastic/beats/heartbeat/monitors/active/dialchain"
+)
+
+func init() {
+	monitors.RegisterActive("tls", create)
+}
+
+var debugf = logp.MakeDebug("tls")
+
+const defaultPort = 443
+
+func create(
+	info monitors.Info,
+	cfg *common.Config,
+) ([]monitors.Job, error) {
+	config := DefaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	tls, err := outputs.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := collectHosts(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The TLS handshake is run by the check, to inspect the connection state.
+	db, err := dialchain.NewBuilder(dialchain.BuilderSettings{
+		Timeout: config.Timeout,
+		Socks5:  config.Socks5,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	checker := newCertChecker(tls, &config)
+	return dialchain.MakeDialerJobs(db, config.Name, "tls", endpoints, config.Mode,
+		func(dialer transport.Dialer, addr string) (common.MapStr, error) {
+			return checker.check(dialer, addr)
+		})
+}
+
+func collectHosts(config *Config) ([]dialchain.Endpoint, error) {
+	var endpoints []dialchain.Endpoint
+	for _, h := range config.Hosts {
+		host := h
+		if u, err := url.Parse(h); err == nil && u.Host != "" {
+			if u.Scheme != "tls" && u.Scheme != "ssl" && u.Scheme != "https" {
+				return nil, fmt.Errorf("'%v' is no supported connection scheme in '%v'", u.Scheme, h)
+			}
+			host = u.Host
+		}
+		debugf("Add tls endpoint '%v'.", host)
+
+		ports := config.Ports
+		if hostname, port, err := net.SplitHostPort(host); err == nil {
+			p, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("'%v' is no valid port number in '%v'", port, h)
+			}
+			host = hostname
+			ports = []uint16{uint16(p)}
+		} else if len(ports) == 0 {
+			ports = []uint16{defaultPort}
+		}
+
+		endpoints = append(endpoints, dialchain.Endpoint{
+			Host:  host,
+			Ports: ports,
+		})
+	}
+	return endpoints, nil
+}
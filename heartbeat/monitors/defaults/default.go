@@ -29,4 +29,5 @@ import (
 	_ "github.com/elastic/beats/heartbeat/monitors/active/http"
 	_ "github.com/elastic/beats/heartbeat/monitors/active/icmp"
 	_ "github.com/elastic/beats/heartbeat/monitors/active/tcp"
+	_ "github.com/elastic/beats/heartbeat/monitors/active/tls"
 )
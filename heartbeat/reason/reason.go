@@ -32,6 +32,11 @@ type IOError struct {
 	err error
 }
 
+// DegradedError reports a service being available, but requiring attention.
+type DegradedError struct {
+	err error
+}
+
 func ValidateFailed(err error) Reason {
 	if err == nil {
 		return nil
@@ -46,12 +51,23 @@ func IOFailed(err error) Reason {
 	return IOError{err}
 }
 
+// Degraded creates a Reason for a service being up, but degraded.
+func Degraded(err error) Reason {
+	if err == nil {
+		return nil
+	}
+	return DegradedError{err}
+}
+
 func (e ValidateError) Error() string { return e.err.Error() }
 func (ValidateError) Type() string    { return "validate" }
 
 func (e IOError) Error() string { return e.err.Error() }
 func (IOError) Type() string    { return "io" }
 
+func (e DegradedError) Error() string { return e.err.Error() }
+func (DegradedError) Type() string    { return "degraded" }
+
 func FailError(typ string, err error) common.MapStr {
 	return common.MapStr{
 		"type":    typ,
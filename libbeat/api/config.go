@@ -18,9 +18,15 @@
 package api
 
 type Config struct {
+	Enabled    bool
+	Host       string
+	Port       int
+	Prometheus PrometheusConfig
+}
+
+// PrometheusConfig configures the Prometheus metrics endpoint.
+type PrometheusConfig struct {
 	Enabled bool
-	Host    string
-	Port    int
 }
 
 var (
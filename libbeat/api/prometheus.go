@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// promMetrics collects metric samples grouped by metric name, as required by
+// the Prometheus text exposition format.
+type promMetrics struct {
+	families map[string]*promFamily
+}
+
+type promFamily struct {
+	typ     string
+	samples map[string]float64 // samples indexed by the formatted label set
+}
+
+func prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m := newPromMetrics()
+
+	state := monitoring.CollectStructSnapshot(monitoring.GetNamespace("state").GetRegistry(), monitoring.Full, false)
+	m.addInfo("beat_info", state)
+
+	stats := monitoring.CollectStructSnapshot(monitoring.GetNamespace("stats").GetRegistry(), monitoring.Full, false)
+	m.addRegistry(nil, stats, nil)
+
+	// Registries in the dataset namespace are indexed by the metricset ID. The
+	// ID is not added to the metric name, but is available as label.
+	dataset := monitoring.CollectStructSnapshot(monitoring.GetNamespace("dataset").GetRegistry(), monitoring.Full, false)
+	for _, v := range dataset {
+		if reg, ok := v.(map[string]interface{}); ok {
+			m.addRegistry([]string{"dataset"}, reg, nil)
+		}
+	}
+
+	m.write(w)
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{families: map[string]*promFamily{}}
+}
+
+// addInfo adds an info metric with all string values in data as labels.
+func (m *promMetrics) addInfo(name string, data map[string]interface{}) {
+	labels := map[string]string{}
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			labels[promLabelName(k)] = s
+		}
+	}
+	if len(labels) > 0 {
+		m.add("gauge", name, labels, 1)
+	}
+}
+
+// addRegistry adds all numeric and boolean values in data. String values are
+// not exported as metrics, but are added as labels to all metrics in the same
+// registry and its sub-registries. For example the output type is available
+// as label `type` on all `libbeat_output_*` metrics.
+// Sub-registries with numeric names (list entries) are not added to the metric
+// name, but to the `index` label.
+func (m *promMetrics) addRegistry(path []string, data map[string]interface{}, labels map[string]string) {
+	local := make(map[string]string, len(labels))
+	for k, v := range labels {
+		local[k] = v
+	}
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			local[promLabelName(k)] = s
+		}
+	}
+
+	for k, v := range data {
+		switch v := v.(type) {
+		case int64:
+			m.add("untyped", promMetricName(path, k), local, float64(v))
+		case float64:
+			m.add("untyped", promMetricName(path, k), local, v)
+		case bool:
+			value := 0.0
+			if v {
+				value = 1
+			}
+			m.add("untyped", promMetricName(path, k), local, value)
+		case map[string]interface{}:
+			if isIndex(k) {
+				indexed := make(map[string]string, len(local)+1)
+				for name, value := range local {
+					indexed[name] = value
+				}
+				indexed["index"] = k
+				m.addRegistry(path, v, indexed)
+			} else {
+				m.addRegistry(extendPath(path, k), v, local)
+			}
+		}
+	}
+}
+
+func (m *promMetrics) add(typ, name string, labels map[string]string, value float64) {
+	f := m.families[name]
+	if f == nil {
+		f = &promFamily{typ: typ, samples: map[string]float64{}}
+		m.families[name] = f
+	}
+	f.samples[formatLabels(labels)] = value
+}
+
+func (m *promMetrics) write(w io.Writer) {
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	for _, name := range names {
+		f := m.families[name]
+		out.WriteString("# TYPE " + name + " " + f.typ + "\n")
+
+		labelSets := make([]string, 0, len(f.samples))
+		for labels := range f.samples {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+
+		for _, labels := range labelSets {
+			out.WriteString(name)
+			out.WriteString(labels)
+			out.WriteByte(' ')
+			out.WriteString(strconv.FormatFloat(f.samples[labels], 'g', -1, 64))
+			out.WriteByte('\n')
+		}
+	}
+}
+
+func extendPath(path []string, key string) []string {
+	tmp := make([]string, len(path), len(path)+1)
+	copy(tmp, path)
+	return append(tmp, key)
+}
+
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// promMetricName joins the registry path and key into a valid Prometheus
+// metric name. e.g. `libbeat.output.events.acked` becomes
+// `libbeat_output_events_acked`.
+func promMetricName(path []string, key string) string {
+	name := strings.Join(extendPath(path, key), "_")
+	return sanitizeName(name, true)
+}
+
+func promLabelName(s string) string {
+	return sanitizeName(s, false)
+}
+
+// sanitizeName replaces all characters not allowed in Prometheus metric or
+// label names with `_`. Colons are only allowed in metric names. Names must
+// not start with a digit.
+func sanitizeName(s string, allowColon bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		valid := c == '_' ||
+			(c >= 'a' && c <= 'z') ||
+			(c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') ||
+			(c == ':' && allowColon)
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusFormat(t *testing.T) {
+	m := newPromMetrics()
+	m.addInfo("beat_info", map[string]interface{}{
+		"beat":    "testbeat",
+		"version": "7.0.0",
+	})
+	m.addRegistry(nil, map[string]interface{}{
+		"libbeat": map[string]interface{}{
+			"output": map[string]interface{}{
+				"type": "elasticsearch",
+				"events": map[string]interface{}{
+					"acked": int64(10),
+					"batch": map[string]interface{}{"1": int64(1)},
+				},
+				"write": map[string]interface{}{
+					"bytes": int64(1024),
+				},
+			},
+			"config": map[string]interface{}{
+				"reloads": int64(0),
+				"running": true,
+			},
+		},
+		"system": map[string]interface{}{
+			"load": map[string]interface{}{"1": 0.5, "norm": map[string]interface{}{"1": 0.25}},
+		},
+		"failover": map[string]interface{}{
+			"outputs": map[string]interface{}{
+				"0": map[string]interface{}{"type": "kafka", "active": true},
+				"1": map[string]interface{}{"type": "file", "active": false},
+			},
+		},
+		"quote": map[string]interface{}{
+			"path": "C:\\logs\n\"x\"",
+			"open": int64(2),
+		},
+	}, nil)
+
+	var buf bytes.Buffer
+	m.write(&buf)
+
+	expected := `# TYPE beat_info gauge
+beat_info{beat="testbeat",version="7.0.0"} 1
+# TYPE failover_outputs_active untyped
+failover_outputs_active{index="0",type="kafka"} 1
+failover_outputs_active{index="1",type="file"} 0
+# TYPE libbeat_config_reloads untyped
+libbeat_config_reloads 0
+# TYPE libbeat_config_running untyped
+libbeat_config_running 1
+# TYPE libbeat_output_events_acked untyped
+libbeat_output_events_acked{type="elasticsearch"} 10
+# TYPE libbeat_output_events_batch_1 untyped
+libbeat_output_events_batch_1{type="elasticsearch"} 1
+# TYPE libbeat_output_write_bytes untyped
+libbeat_output_write_bytes{type="elasticsearch"} 1024
+# TYPE quote_open untyped
+quote_open{path="C:\\logs\n\"x\""} 2
+# TYPE system_load_1 untyped
+system_load_1 0.5
+# TYPE system_load_norm_1 untyped
+system_load_norm_1 0.25
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"libbeat_output":    "libbeat_output",
+		"memstats.gc-next":  "memstats_gc_next",
+		"1min":              "_1min",
+		"a:b":               "a:b",
+		"process/cpu.total": "process_cpu_total",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, sanitizeName(in, true), in)
+	}
+	assert.Equal(t, "a_b", sanitizeName("a:b", false))
+}
@@ -43,6 +43,9 @@ func Start(cfg *common.Config) {
 		mux.HandleFunc("/", rootHandler())
 		mux.HandleFunc("/stats", statsHandler)
 		mux.HandleFunc("/dataset", datasetHandler)
+		if config.Prometheus.Enabled {
+			mux.HandleFunc("/metrics", prometheusHandler)
+		}
 
 		url := config.Host + ":" + strconv.Itoa(config.Port)
 		logp.Info("Metrics endpoint listening on: %s", url)
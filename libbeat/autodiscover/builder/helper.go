@@ -71,8 +71,13 @@ func GetHintAsList(hints common.MapStr, key, config string) []string {
 	return nil
 }
 
-// GetProcessors gets processor definitions from the hints and returns a list of configs as a MapStr
+// GetProcessors gets processor definitions from the hints and returns a list of configs as a MapStr.
+// Processors can be given either as numbered/named hints or as a JSON encoded list or object.
 func GetProcessors(hints common.MapStr, key string) []common.MapStr {
+	if str := GetHintString(hints, key, "processors"); str != "" {
+		return getProcessorsFromJSON(str)
+	}
+
 	rawProcs := GetHintMapStr(hints, key, "processors")
 	if rawProcs == nil {
 		return nil
@@ -108,6 +113,25 @@ func GetProcessors(hints common.MapStr, key string) []common.MapStr {
 	return configs
 }
 
+func getProcessorsFromJSON(input string) []common.MapStr {
+	input = strings.TrimSpace(input)
+
+	var configs []common.MapStr
+	if strings.HasPrefix(input, "{") {
+		var config common.MapStr
+		if err := json.Unmarshal([]byte(input), &config); err != nil {
+			logp.Err("Unable to unmarshal processors JSON from hints: %v", err)
+			return nil
+		}
+		configs = append(configs, config)
+	} else if err := json.Unmarshal([]byte(input), &configs); err != nil {
+		logp.Err("Unable to unmarshal processors JSON from hints: %v", err)
+		return nil
+	}
+
+	return configs
+}
+
 func getStringAsList(input string) []string {
 	if input == "" {
 		return []string{}
@@ -72,3 +72,70 @@ func TestGenerateHints(t *testing.T) {
 		assert.Equal(t, GenerateHints(annMap, "foobar", "co.elastic"), test.result)
 	}
 }
+
+func TestGetProcessors(t *testing.T) {
+	tests := []struct {
+		msg    string
+		hints  common.MapStr
+		result []common.MapStr
+	}{
+		{
+			msg:    "No processors hint should return nothing",
+			hints:  common.MapStr{},
+			result: nil,
+		},
+		{
+			msg: "Numbered processors are sorted before named ones",
+			hints: common.MapStr{
+				"logs": common.MapStr{
+					"processors": common.MapStr{
+						"drop_event": common.MapStr{},
+						"1": common.MapStr{
+							"add_tags": common.MapStr{"tags": "web"},
+						},
+					},
+				},
+			},
+			result: []common.MapStr{
+				{"add_tags": common.MapStr{"tags": "web"}},
+				{"drop_event": common.MapStr{}},
+			},
+		},
+		{
+			msg: "Processors can be given as a JSON list",
+			hints: common.MapStr{
+				"logs": common.MapStr{
+					"processors": `[{"add_tags": {"tags": "web"}}, {"drop_event": {}}]`,
+				},
+			},
+			result: []common.MapStr{
+				{"add_tags": map[string]interface{}{"tags": "web"}},
+				{"drop_event": map[string]interface{}{}},
+			},
+		},
+		{
+			msg: "Processors can be given as a JSON object",
+			hints: common.MapStr{
+				"logs": common.MapStr{
+					"processors": `{"drop_event": {}}`,
+				},
+			},
+			result: []common.MapStr{
+				{"drop_event": map[string]interface{}{}},
+			},
+		},
+		{
+			msg: "Invalid JSON processors are ignored",
+			hints: common.MapStr{
+				"logs": common.MapStr{
+					"processors": `[{"drop_event": `,
+				},
+			},
+			result: nil,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.result, GetProcessors(test.hints, "logs"), test.msg)
+	}
+}
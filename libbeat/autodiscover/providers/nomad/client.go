@@ -0,0 +1,285 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+// client is a minimal client of the Nomad HTTP API, supporting blocking
+// queries on allocations.
+type client struct {
+	address   string
+	region    string
+	namespace string
+	token     *tokenSource
+	http      *http.Client
+}
+
+// Nomad API objects, only the fields used by the provider are decoded.
+
+type allocation struct {
+	ID               string
+	Name             string
+	Namespace        string
+	NodeID           string
+	JobID            string
+	TaskGroup        string
+	ClientStatus     string
+	DesiredStatus    string
+	AllocModifyIndex uint64
+
+	Job                *job
+	TaskResources      map[string]*resources
+	AllocatedResources *allocatedResources
+
+	// node the allocation runs on, set by the provider
+	node *node
+}
+
+type job struct {
+	ID          string
+	Name        string
+	Type        string
+	Region      string
+	Namespace   string
+	Datacenters []string
+	Meta        map[string]string
+	TaskGroups  []*taskGroup
+}
+
+type taskGroup struct {
+	Name  string
+	Meta  map[string]string
+	Tasks []*task
+}
+
+type task struct {
+	Name   string
+	Driver string
+	Meta   map[string]string
+}
+
+type resources struct {
+	Networks []*network
+}
+
+type allocatedResources struct {
+	Tasks  map[string]*resources
+	Shared resources
+}
+
+type network struct {
+	IP            string
+	ReservedPorts []port
+	DynamicPorts  []port
+}
+
+type port struct {
+	Label string
+	Value int
+}
+
+type node struct {
+	ID         string
+	Name       string
+	Datacenter string
+	HTTPAddr   string
+}
+
+// tokenSource provides the ACL token sent with requests. Tokens read from a
+// file are reloaded when the file changes, so that rotated tokens are picked
+// up without restarting the Beat.
+type tokenSource struct {
+	mu      sync.Mutex
+	token   string
+	file    string
+	modTime time.Time
+}
+
+func newClient(config *Config) (*client, error) {
+	u, err := url.Parse(config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nomad address '%v': %v", config.Address, err)
+	}
+
+	tlsConfig, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.BuildModuleConfig(u.Hostname())
+	}
+
+	token := &tokenSource{token: config.Token, file: config.TokenFile}
+	if _, err := token.get(false); err != nil {
+		return nil, err
+	}
+
+	return &client{
+		address:   strings.TrimSuffix(u.String(), "/"),
+		region:    config.Region,
+		namespace: config.Namespace,
+		token:     token,
+		http:      &http.Client{Transport: transport},
+	}, nil
+}
+
+// allocations runs a blocking query for the allocations of the cluster, or of
+// a node if set. The query returns once the allocations changed since index
+// or the wait time expired.
+func (c *client) allocations(ctx context.Context, nodeID string, index uint64, wait time.Duration) ([]*allocation, uint64, error) {
+	path := "/v1/allocations"
+	if nodeID != "" {
+		path = "/v1/node/" + url.PathEscape(nodeID) + "/allocations"
+	}
+
+	params := url.Values{}
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%ds", int(wait/time.Second)))
+	}
+
+	var allocs []*allocation
+	index, err := c.get(ctx, path, params, wait+wait/16, &allocs)
+	return allocs, index, err
+}
+
+// allocation returns the details of an allocation, including its job.
+func (c *client) allocation(ctx context.Context, id string) (*allocation, error) {
+	var alloc allocation
+	_, err := c.get(ctx, "/v1/allocation/"+url.PathEscape(id), nil, 0, &alloc)
+	return &alloc, err
+}
+
+func (c *client) node(ctx context.Context, id string) (*node, error) {
+	var n node
+	_, err := c.get(ctx, "/v1/node/"+url.PathEscape(id), nil, 0, &n)
+	return &n, err
+}
+
+// get decodes the response of a GET request into out, returning the index of
+// the response. Requests denied with 403 are retried once after reloading
+// the token.
+func (c *client) get(ctx context.Context, path string, params url.Values, timeout time.Duration, out interface{}) (uint64, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	if c.region != "" {
+		params.Set("region", c.region)
+	}
+	if c.namespace != "" {
+		params.Set("namespace", c.namespace)
+	}
+
+	u := c.address + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		token, err := c.token.get(attempt > 0)
+		if err != nil {
+			return 0, err
+		}
+
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return 0, err
+		}
+		req = req.WithContext(ctx)
+		if token != "" {
+			req.Header.Set("X-Nomad-Token", token)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return 0, err
+		}
+
+		if resp.StatusCode == http.StatusForbidden && attempt == 0 && c.token.file != "" {
+			resp.Body.Close()
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		if err != nil {
+			return 0, fmt.Errorf("nomad request %v failed: %v", path, err)
+		}
+
+		index, _ := strconv.ParseUint(resp.Header.Get("X-Nomad-Index"), 10, 64)
+		return index, nil
+	}
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// get returns the current token. Tokens read from a file are reloaded if the
+// file was modified, or if reload is set.
+func (t *tokenSource) get(reload bool) (string, error) {
+	if t.file == "" {
+		return t.token, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, err := os.Stat(t.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nomad token: %v", err)
+	}
+	if !reload && t.token != "" && info.ModTime().Equal(t.modTime) {
+		return t.token, nil
+	}
+
+	content, err := ioutil.ReadFile(t.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nomad token: %v", err)
+	}
+	t.token = strings.TrimSpace(string(content))
+	t.modTime = info.ModTime()
+	return t.token, nil
+}
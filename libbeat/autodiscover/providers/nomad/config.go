@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nomad
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/libbeat/autodiscover/template"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+// Config for nomad autodiscover provider
+type Config struct {
+	Address   string            `config:"address"`
+	Region    string            `config:"region"`
+	Namespace string            `config:"namespace"`
+	Node      string            `config:"node"`
+	Token     string            `config:"token"`
+	TokenFile string            `config:"token_file"`
+	TLS       *tlscommon.Config `config:"ssl"`
+
+	WaitTime       time.Duration `config:"wait_time" validate:"positive,nonzero"`
+	RetryInterval  time.Duration `config:"retry_interval" validate:"positive,nonzero"`
+	CleanupTimeout time.Duration `config:"cleanup_timeout"`
+
+	Prefix       string                  `config:"prefix"`
+	HintsEnabled bool                    `config:"hints.enabled"`
+	Builders     []*common.Config        `config:"builders"`
+	Appenders    []*common.Config        `config:"appenders"`
+	Templates    template.MapperSettings `config:"templates"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Address:        "http://127.0.0.1:4646",
+		WaitTime:       5 * time.Minute,
+		RetryInterval:  10 * time.Second,
+		CleanupTimeout: 60 * time.Second,
+		Prefix:         "co.elastic",
+	}
+}
+
+// Validate ensures correctness of config
+func (c *Config) Validate() error {
+	if c.Token != "" && c.TokenFile != "" {
+		return errors.New("token and token_file can not be used at the same time")
+	}
+
+	// Make sure that prefix doesn't ends with a '.'
+	if c.Prefix != "" && c.Prefix != "." && c.Prefix[len(c.Prefix)-1] == '.' {
+		c.Prefix = c.Prefix[:len(c.Prefix)-1]
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nomad
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/elastic/beats/libbeat/autodiscover"
+	"github.com/elastic/beats/libbeat/autodiscover/builder"
+	"github.com/elastic/beats/libbeat/autodiscover/template"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/bus"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/safemapstr"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	autodiscover.Registry.AddProvider("nomad", AutodiscoverBuilder)
+}
+
+const (
+	allocRunning = "running"
+	desiredRun   = "run"
+)
+
+var debugf = logp.MakeDebug("nomad")
+
+// Provider implements autodiscover provider for Nomad allocations
+type Provider struct {
+	config    *Config
+	bus       bus.Bus
+	client    *client
+	templates *template.Mapper
+	builders  autodiscover.Builders
+	appenders autodiscover.Appenders
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// running allocations events were emitted for, by ID
+	allocs map[string]*allocation
+	nodes  map[string]*node
+}
+
+// AutodiscoverBuilder builds and returns an autodiscover provider
+func AutodiscoverBuilder(bus bus.Bus, c *common.Config) (autodiscover.Provider, error) {
+	cfgwarn.Experimental("The nomad autodiscover is experimental")
+	config := defaultConfig()
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper, err := template.NewConfigMapper(config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	builders, err := autodiscover.NewBuilders(config.Builders, config.HintsEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	appenders, err := autodiscover.NewAppenders(config.Appenders)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Provider{
+		config:    config,
+		bus:       bus,
+		client:    client,
+		templates: mapper,
+		builders:  builders,
+		appenders: appenders,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		allocs:    map[string]*allocation{},
+		nodes:     map[string]*node{},
+	}, nil
+}
+
+// Start for Runner interface.
+func (p *Provider) Start() {
+	go p.watch()
+}
+
+// watch runs blocking queries on the allocations until the provider is
+// stopped, emitting events for started and stopped allocations.
+func (p *Provider) watch() {
+	defer close(p.done)
+
+	var index uint64
+	for {
+		allocs, newIndex, err := p.client.allocations(p.ctx, p.config.Node, index, p.config.WaitTime)
+		if p.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logp.Err("Error watching nomad allocations: %v", err)
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(p.config.RetryInterval):
+			}
+			continue
+		}
+
+		// reset the index if it goes backwards, e.g. after a leader election
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		p.sync(allocs)
+	}
+}
+
+// sync compares the allocations returned by the API with the allocations
+// events were emitted for.
+func (p *Provider) sync(allocs []*allocation) {
+	seen := map[string]bool{}
+	for _, alloc := range allocs {
+		seen[alloc.ID] = true
+		running := alloc.ClientStatus == allocRunning && alloc.DesiredStatus == desiredRun
+
+		prev, known := p.allocs[alloc.ID]
+		switch {
+		case running && !known:
+			p.start(alloc)
+
+		case running && prev.AllocModifyIndex != alloc.AllocModifyIndex:
+			// allocation updated in place, e.g. changed meta
+			debugf("Allocation updated: %v", alloc.ID)
+			p.stop(prev, 0)
+			p.start(alloc)
+
+		case !running && known:
+			p.stop(prev, p.config.CleanupTimeout)
+		}
+	}
+
+	// allocations garbage collected by nomad
+	for id, prev := range p.allocs {
+		if !seen[id] {
+			p.stop(prev, p.config.CleanupTimeout)
+		}
+	}
+}
+
+func (p *Provider) start(alloc *allocation) {
+	if alloc.Job == nil {
+		// the allocations list only returns stubs without job
+		details, err := p.client.allocation(p.ctx, alloc.ID)
+		if err != nil {
+			logp.Err("Error getting nomad allocation %v: %v", alloc.ID, err)
+			return
+		}
+		alloc = details
+	}
+	alloc.node = p.node(alloc.NodeID)
+
+	debugf("Allocation started: %v", alloc.ID)
+	p.allocs[alloc.ID] = alloc
+	p.emit(alloc, "start")
+}
+
+func (p *Provider) stop(alloc *allocation, delay time.Duration) {
+	debugf("Allocation stopped: %v", alloc.ID)
+	delete(p.allocs, alloc.ID)
+	if delay == 0 {
+		p.emit(alloc, "stop")
+		return
+	}
+	time.AfterFunc(delay, func() { p.emit(alloc, "stop") })
+}
+
+// emit publishes one event per task of the allocation, and for every port
+// of the task.
+func (p *Provider) emit(alloc *allocation, flag string) {
+	group := alloc.Job.group(alloc.TaskGroup)
+	if group == nil {
+		logp.Err("Task group %v not found in job %v", alloc.TaskGroup, alloc.JobID)
+		return
+	}
+
+	n := alloc.node
+	for _, task := range group.Tasks {
+		nomadMeta := common.MapStr{
+			"allocation": common.MapStr{
+				"id":     alloc.ID,
+				"name":   alloc.Name,
+				"status": alloc.ClientStatus,
+			},
+			"job": common.MapStr{
+				"name": alloc.Job.name(),
+				"type": alloc.Job.Type,
+			},
+			"group": common.MapStr{
+				"name": group.Name,
+			},
+			"task": common.MapStr{
+				"name":   task.Name,
+				"driver": task.Driver,
+			},
+		}
+		if alloc.Namespace != "" {
+			nomadMeta["namespace"] = alloc.Namespace
+		}
+		if alloc.Job.Region != "" {
+			nomadMeta["region"] = alloc.Job.Region
+		}
+		if n != nil {
+			nomadMeta["node"] = common.MapStr{
+				"id":   n.ID,
+				"name": n.Name,
+			}
+			nomadMeta["datacenter"] = n.Datacenter
+		}
+
+		// Pass meta of job, group and task to all events, so that it can be
+		// used in templating and by hints builders. Task meta overrides group
+		// meta, which overrides job meta.
+		meta := common.MapStr{}
+		for _, m := range []map[string]string{alloc.Job.Meta, group.Meta, task.Meta} {
+			for k, v := range m {
+				safemapstr.Put(meta, k, v)
+			}
+		}
+
+		nomad := nomadMeta.Clone()
+		nomad["meta"] = meta
+
+		host, ports := alloc.networks(task.Name)
+		if host == "" && n != nil {
+			host = n.host()
+		}
+
+		// Without this check there would be overlapping configurations with and without ports.
+		if len(ports) == 0 {
+			p.publish(bus.Event{
+				flag:    true,
+				"host":  host,
+				"nomad": nomad,
+				"meta": common.MapStr{
+					"nomad": nomadMeta,
+				},
+			})
+		}
+
+		for _, port := range ports {
+			p.publish(bus.Event{
+				flag:    true,
+				"host":  host,
+				"port":  port,
+				"nomad": nomad,
+				"meta": common.MapStr{
+					"nomad": nomadMeta,
+				},
+			})
+		}
+	}
+}
+
+// node returns the node with the given ID, nodes are cached.
+func (p *Provider) node(id string) *node {
+	if n, ok := p.nodes[id]; ok {
+		return n
+	}
+
+	n, err := p.client.node(p.ctx, id)
+	if err != nil {
+		logp.Err("Error getting nomad node %v: %v", id, err)
+		return nil
+	}
+	p.nodes[id] = n
+	return n
+}
+
+func (p *Provider) publish(event bus.Event) {
+	// Try to match a config
+	if config := p.templates.GetConfig(event); config != nil {
+		event["config"] = config
+	} else {
+		// If there isn't a default template then attempt to use builders
+		if config := p.builders.GetConfig(p.generateHints(event)); config != nil {
+			event["config"] = config
+		}
+	}
+
+	// Call all appenders to append any extra configuration
+	p.appenders.Append(event)
+	p.bus.Publish(event)
+}
+
+func (p *Provider) generateHints(event bus.Event) bus.Event {
+	// Try to build a config with enabled builders. Send a provider agnostic payload.
+	// Builders are Beat specific.
+	e := bus.Event{}
+	var meta common.MapStr
+	var task string
+	if rawNomad, ok := event["nomad"]; ok {
+		nomad := rawNomad.(common.MapStr)
+		// The builder base config can configure any of the field values of nomad if need be.
+		e["nomad"] = nomad
+		if rawMeta, ok := nomad["meta"]; ok {
+			meta = rawMeta.(common.MapStr)
+		}
+		if name, err := nomad.GetValue("task.name"); err == nil {
+			task, _ = name.(string)
+		}
+	}
+	if host, ok := event["host"]; ok {
+		e["host"] = host
+	}
+	if port, ok := event["port"]; ok {
+		e["port"] = port
+	}
+
+	hints := builder.GenerateHints(meta, task, p.config.Prefix)
+	if len(hints) != 0 {
+		e["hints"] = hints
+	}
+
+	debugf("Generated builder event %v", e)
+
+	return e
+}
+
+// Stop signals the watch loop to stop.
+func (p *Provider) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+// String returns a description of nomad autodiscover provider.
+func (p *Provider) String() string {
+	return "nomad"
+}
+
+func (j *job) name() string {
+	if j.Name != "" {
+		return j.Name
+	}
+	return j.ID
+}
+
+func (j *job) group(name string) *taskGroup {
+	for _, g := range j.TaskGroups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// networks returns the IP and ports allocated to a task.
+func (a *allocation) networks(task string) (string, []int) {
+	var networks []*network
+	if r, ok := a.TaskResources[task]; ok && r != nil {
+		networks = r.Networks
+	} else if a.AllocatedResources != nil {
+		if r, ok := a.AllocatedResources.Tasks[task]; ok && r != nil {
+			networks = r.Networks
+		}
+		networks = append(networks, a.AllocatedResources.Shared.Networks...)
+	}
+
+	var host string
+	var ports []int
+	for _, n := range networks {
+		if host == "" {
+			host = n.IP
+		}
+		for _, p := range n.ReservedPorts {
+			ports = append(ports, p.Value)
+		}
+		for _, p := range n.DynamicPorts {
+			ports = append(ports, p.Value)
+		}
+	}
+	return host, ports
+}
+
+// host returns the address the node's HTTP API is advertised on.
+func (n *node) host() string {
+	addr := n.HTTPAddr
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,313 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package nomad
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/bus"
+)
+
+// fakeNomad serves the allocations and nodes endpoints of the Nomad API.
+type fakeNomad struct {
+	sync.Mutex
+	token   string
+	index   uint64
+	allocs  []*allocation
+	changed chan struct{}
+}
+
+func newFakeNomad(token string, allocs ...*allocation) *fakeNomad {
+	return &fakeNomad{
+		token:   token,
+		index:   1,
+		allocs:  allocs,
+		changed: make(chan struct{}),
+	}
+}
+
+func (f *fakeNomad) update(fn func()) {
+	f.Lock()
+	defer f.Unlock()
+	fn()
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeNomad) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	if r.Header.Get("X-Nomad-Token") != f.token {
+		f.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Permission denied"))
+		return
+	}
+
+	// blocking query
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index >= f.index {
+		changed := f.changed
+		f.Unlock()
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+		}
+		f.Lock()
+	}
+	defer f.Unlock()
+
+	var body interface{}
+	switch {
+	case r.URL.Path == "/v1/allocations":
+		// list returns stubs without job
+		var stubs []allocation
+		for _, a := range f.allocs {
+			stub := *a
+			stub.Job = nil
+			stubs = append(stubs, stub)
+		}
+		body = stubs
+	case strings.HasPrefix(r.URL.Path, "/v1/allocation/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/allocation/")
+		for _, a := range f.allocs {
+			if a.ID == id {
+				body = a
+			}
+		}
+	case strings.HasPrefix(r.URL.Path, "/v1/node/"):
+		body = node{
+			ID:         strings.TrimPrefix(r.URL.Path, "/v1/node/"),
+			Name:       "node1",
+			Datacenter: "dc1",
+			HTTPAddr:   "10.0.0.1:4646",
+		}
+	}
+	if body == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("X-Nomad-Index", strconv.FormatUint(f.index, 10))
+	json.NewEncoder(w).Encode(body)
+}
+
+func testAllocation() *allocation {
+	return &allocation{
+		ID:               "a1",
+		Name:             "web.frontend[0]",
+		Namespace:        "default",
+		NodeID:           "n1",
+		JobID:            "web",
+		TaskGroup:        "frontend",
+		ClientStatus:     "running",
+		DesiredStatus:    "run",
+		AllocModifyIndex: 1,
+		Job: &job{
+			ID:     "web",
+			Name:   "web",
+			Type:   "service",
+			Region: "global",
+			Meta: map[string]string{
+				"team":                    "web",
+				"co.elastic.logs/disable": "false",
+			},
+			TaskGroups: []*taskGroup{
+				{
+					Name: "frontend",
+					Meta: map[string]string{"team": "frontend"},
+					Tasks: []*task{
+						{
+							Name:   "nginx",
+							Driver: "docker",
+							Meta: map[string]string{
+								"co.elastic.logs/module": "nginx",
+							},
+						},
+						{Name: "sidecar", Driver: "exec"},
+					},
+				},
+			},
+		},
+		TaskResources: map[string]*resources{
+			"nginx": {
+				Networks: []*network{
+					{IP: "10.0.0.2", DynamicPorts: []port{{Label: "http", Value: 23456}}},
+				},
+			},
+		},
+	}
+}
+
+func newTestProvider(t *testing.T, server *httptest.Server, settings map[string]interface{}) (*Provider, bus.Listener) {
+	settings["address"] = server.URL
+	settings["cleanup_timeout"] = 0
+	settings["hints.enabled"] = false
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := bus.New("test")
+	p, err := AutodiscoverBuilder(b, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*Provider), b.Subscribe()
+}
+
+func nextEvent(t *testing.T, listener bus.Listener) bus.Event {
+	select {
+	case event := <-listener.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+		return nil
+	}
+}
+
+func TestEmit(t *testing.T) {
+	nomad := newFakeNomad("", testAllocation())
+	server := httptest.NewServer(nomad)
+	defer server.Close()
+
+	p, listener := newTestProvider(t, server, map[string]interface{}{})
+	p.sync(nomad.allocs)
+
+	event := nextEvent(t, listener)
+	assert.Equal(t, true, event["start"])
+	assert.Equal(t, "10.0.0.2", event["host"])
+	assert.Equal(t, 23456, event["port"])
+
+	meta := common.MapStr{
+		"allocation": common.MapStr{"id": "a1", "name": "web.frontend[0]", "status": "running"},
+		"job":        common.MapStr{"name": "web", "type": "service"},
+		"group":      common.MapStr{"name": "frontend"},
+		"task":       common.MapStr{"name": "nginx", "driver": "docker"},
+		"namespace":  "default",
+		"region":     "global",
+		"datacenter": "dc1",
+		"node":       common.MapStr{"id": "n1", "name": "node1"},
+	}
+	assert.Equal(t, common.MapStr{"nomad": meta}, event["meta"])
+
+	nomadMeta := event["nomad"].(common.MapStr)
+	team, _ := nomadMeta.GetValue("meta.team")
+	assert.Equal(t, "frontend", team)
+
+	hints := p.generateHints(event)
+	assert.Equal(t, common.MapStr{
+		"logs": common.MapStr{"disable": "false", "module": "nginx"},
+	}, hints["hints"])
+
+	// task without network uses the node address
+	event = nextEvent(t, listener)
+	assert.Equal(t, "10.0.0.1", event["host"])
+	assert.Nil(t, event["port"])
+	task, _ := event["nomad"].(common.MapStr).GetValue("task.name")
+	assert.Equal(t, "sidecar", task)
+
+	// stopped allocation
+	alloc := *testAllocation()
+	alloc.ClientStatus = "complete"
+	p.sync([]*allocation{&alloc})
+
+	for _, task := range []string{"nginx", "sidecar"} {
+		event = nextEvent(t, listener)
+		assert.Equal(t, true, event["stop"])
+		name, _ := event["nomad"].(common.MapStr).GetValue("task.name")
+		assert.Equal(t, task, name)
+	}
+	assert.Empty(t, p.allocs)
+}
+
+func TestWatch(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "nomad-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("secret\n")
+	tokenFile.Close()
+
+	nomad := newFakeNomad("secret", testAllocation())
+	server := httptest.NewServer(nomad)
+	defer server.Close()
+
+	p, listener := newTestProvider(t, server, map[string]interface{}{
+		"token_file":     tokenFile.Name(),
+		"wait_time":      "1s",
+		"retry_interval": "100ms",
+	})
+	p.Start()
+	defer p.Stop()
+
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, listener)
+		assert.Equal(t, true, event["start"])
+	}
+
+	// token is rotated, the new token is read on the next request
+	nomad.update(func() {
+		nomad.token = "rotated"
+		ioutil.WriteFile(tokenFile.Name(), []byte("rotated"), 0600)
+		nomad.allocs = nil
+	})
+
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, listener)
+		assert.Equal(t, true, event["stop"])
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"token":      "secret",
+		"token_file": "/tmp/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig()
+	assert.Error(t, cfg.Unpack(&config))
+
+	cfg, err = common.NewConfigFrom(map[string]interface{}{
+		"prefix": "co.elastic.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = defaultConfig()
+	if assert.NoError(t, cfg.Unpack(&config)) {
+		assert.Equal(t, "co.elastic", config.Prefix)
+	}
+}
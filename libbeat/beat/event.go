@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/tracing"
 )
 
 // Event is the common event format shared by all beats.
@@ -33,6 +34,10 @@ type Event struct {
 	Meta      common.MapStr
 	Fields    common.MapStr
 	Private   interface{} // for beats private use
+
+	// Span traces the event through the publishing pipeline. Span is nil if
+	// tracing is disabled or the event has not been sampled.
+	Span *tracing.Span
 }
 
 var (
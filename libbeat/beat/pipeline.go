@@ -41,6 +41,10 @@ type Client interface {
 type ClientConfig struct {
 	PublishMode PublishMode
 
+	// Name identifies the client in the pipeline metrics, if pipeline
+	// instrumentation is enabled. Clients without name are not instrumented.
+	Name string
+
 	// EventMetadata configures additional fields/tags to be added to published events.
 	EventMetadata common.EventMetadata
 
@@ -57,6 +57,7 @@ import (
 	"github.com/elastic/beats/libbeat/publisher/pipeline"
 	svc "github.com/elastic/beats/libbeat/service"
 	"github.com/elastic/beats/libbeat/template"
+	"github.com/elastic/beats/libbeat/tracing"
 	"github.com/elastic/beats/libbeat/version"
 	"github.com/elastic/go-sysinfo"
 	"github.com/elastic/go-sysinfo/types"
@@ -71,15 +72,27 @@ import (
 	_ "github.com/elastic/beats/libbeat/processors/add_host_metadata"
 	_ "github.com/elastic/beats/libbeat/processors/add_kubernetes_metadata"
 	_ "github.com/elastic/beats/libbeat/processors/add_locale"
+	_ "github.com/elastic/beats/libbeat/processors/decode_protobuf_fields"
 	_ "github.com/elastic/beats/libbeat/processors/dissect"
+	_ "github.com/elastic/beats/libbeat/processors/geoip"
+	_ "github.com/elastic/beats/libbeat/processors/grok"
+	_ "github.com/elastic/beats/libbeat/processors/kv"
+	_ "github.com/elastic/beats/libbeat/processors/ratelimit"
+	_ "github.com/elastic/beats/libbeat/processors/translate"
 
 	// Register autodiscover providers
 	_ "github.com/elastic/beats/libbeat/autodiscover/providers/docker"
 	_ "github.com/elastic/beats/libbeat/autodiscover/providers/jolokia"
 	_ "github.com/elastic/beats/libbeat/autodiscover/providers/kubernetes"
+	_ "github.com/elastic/beats/libbeat/autodiscover/providers/nomad"
 
 	// Register default monitoring reporting
 	_ "github.com/elastic/beats/libbeat/monitoring/report/elasticsearch"
+
+	// Register remote keystore providers
+	_ "github.com/elastic/beats/libbeat/keystore/aws"
+	_ "github.com/elastic/beats/libbeat/keystore/gcp"
+	_ "github.com/elastic/beats/libbeat/keystore/vault"
 )
 
 // Beat provides the runnable and configurable instance of a beat.
@@ -107,6 +120,7 @@ type beatConfig struct {
 	Logging       *common.Config `config:"logging"`
 	MetricLogging *common.Config `config:"logging.metrics"`
 	Keystore      *common.Config `config:"keystore"`
+	Tracing       *common.Config `config:"tracing"`
 
 	// output/publishing related configurations
 	Pipeline   pipeline.Config `config:",inline"`
@@ -311,6 +325,20 @@ func (b *Beat) launch(bt beat.Creator) error {
 		return err
 	}
 
+	if b.Config.Tracing.Enabled() {
+		tracer, err := tracing.New(tracing.Resource{
+			ServiceName:    b.Info.Beat,
+			ServiceVersion: b.Info.Version,
+			InstanceID:     b.Info.UUID.String(),
+			HostName:       b.Info.Hostname,
+		}, b.Config.Tracing)
+		if err != nil {
+			return err
+		}
+		tracing.SetGlobal(tracer)
+		defer tracer.Stop()
+	}
+
 	beater, err := b.createBeater(bt)
 	if err != nil {
 		return err
@@ -324,6 +352,17 @@ func (b *Beat) launch(bt beat.Creator) error {
 		defer reporter.Stop()
 	}
 
+	if reload := b.Config.Pipeline.Reload; reload.Enabled {
+		if p, ok := b.Publisher.(*pipeline.Pipeline); ok {
+			reloader, err := newOutputReloader(p, reload, b.RawConfig)
+			if err != nil {
+				return err
+			}
+			reloader.Start()
+			defer reloader.Stop()
+		}
+	}
+
 	if b.Config.MetricLogging == nil || b.Config.MetricLogging.Enabled() {
 		reporter, err := log.MakeReporter(b.Info, b.Config.MetricLogging)
 		if err != nil {
@@ -0,0 +1,195 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package instance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/joeshaw/multierror"
+	"github.com/mitchellh/hashstructure"
+
+	"github.com/elastic/beats/libbeat/cfgfile"
+	"github.com/elastic/beats/libbeat/cloudid"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/processors"
+	"github.com/elastic/beats/libbeat/publisher/pipeline"
+)
+
+const defaultReloadPeriod = 10 * time.Second
+
+var (
+	outputReloads        = monitoring.NewInt(nil, "libbeat.config.output.reloads")
+	outputReloadFailures = monitoring.NewInt(nil, "libbeat.config.output.failures")
+	outputReloadDuration = monitoring.NewInt(nil, "libbeat.config.output.duration.ms")
+)
+
+// outputReloader periodically reads the configuration files of the beat and
+// replaces the output and global processors of the pipeline if their settings
+// have been changed.
+type outputReloader struct {
+	pipeline *pipeline.Pipeline
+	period   time.Duration
+	load     func() (*common.Config, error)
+
+	outputHash     uint64
+	processorsHash uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type reloadableConfig struct {
+	Output     common.ConfigNamespace  `config:"output"`
+	Processors processors.PluginConfig `config:"processors"`
+}
+
+// rawReloadableConfig is used to detect changes in the settings.
+type rawReloadableConfig struct {
+	Output     map[string]interface{}   `config:"output"`
+	Processors []map[string]interface{} `config:"processors"`
+}
+
+func newOutputReloader(
+	p *pipeline.Pipeline,
+	config pipeline.ReloadConfig,
+	current *common.Config,
+) (*outputReloader, error) {
+	r := &outputReloader{
+		pipeline: p,
+		period:   config.Period,
+		load:     loadConfigFiles,
+		done:     make(chan struct{}),
+	}
+	if r.period <= 0 {
+		r.period = defaultReloadPeriod
+	}
+
+	var err error
+	r.outputHash, r.processorsHash, err = hashReloadableConfig(current)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func loadConfigFiles() (*common.Config, error) {
+	cfg, err := cfgfile.Load("")
+	if err != nil {
+		return nil, err
+	}
+	if err := cloudid.OverwriteSettings(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func hashReloadableConfig(cfg *common.Config) (uint64, uint64, error) {
+	var raw rawReloadableConfig
+	if err := cfg.Unpack(&raw); err != nil {
+		return 0, 0, err
+	}
+
+	outputHash, err := hashstructure.Hash(raw.Output, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	processorsHash, err := hashstructure.Hash(raw.Processors, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return outputHash, processorsHash, nil
+}
+
+func (r *outputReloader) Start() {
+	logp.Info("Output and processors reloader started")
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.period)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				logp.Info("Output and processors reloader stopped")
+				return
+			case <-ticker.C:
+				r.reload()
+			}
+		}
+	}()
+}
+
+func (r *outputReloader) Stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// reload applies changes in the output and processors settings. An invalid
+// configuration is reported once, the current output and processors are kept
+// until the configuration is changed again.
+func (r *outputReloader) reload() {
+	cfg, err := r.load()
+	if err != nil {
+		logp.Err("Error reading the configuration for reloading: %v", err)
+		return
+	}
+
+	outputHash, processorsHash, err := hashReloadableConfig(cfg)
+	if err != nil {
+		logp.Err("Error reading the output and processors for reloading: %v", err)
+		return
+	}
+	if outputHash == r.outputHash && processorsHash == r.processorsHash {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		outputReloads.Inc()
+		outputReloadDuration.Set(int64(time.Since(start) / time.Millisecond))
+	}()
+
+	var errs multierror.Errors
+	var config reloadableConfig
+	if err := cfg.Unpack(&config); err != nil {
+		errs = append(errs, err)
+	} else {
+		if outputHash != r.outputHash {
+			if err := r.pipeline.ReloadOutput(config.Output); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if processorsHash != r.processorsHash {
+			if err := r.pipeline.ReloadProcessors(config.Processors); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	r.outputHash, r.processorsHash = outputHash, processorsHash
+
+	if err := errs.Err(); err != nil {
+		outputReloadFailures.Inc()
+		logp.Err("Failed to reload the output and processors: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher/pipeline"
+)
+
+func TestOutputReloader(t *testing.T) {
+	settings := map[string]interface{}{
+		"output.console.pretty": false,
+		"config.reload.enabled": true,
+	}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Pipeline pipeline.Config        `config:",inline"`
+		Output   common.ConfigNamespace `config:"output"`
+	}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pipeline.Load(beat.Info{Beat: "test"}, nil, config.Pipeline, config.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	reloader, err := newOutputReloader(p, config.Pipeline.Reload, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, defaultReloadPeriod, reloader.period)
+
+	reloads, failures := outputReloads.Get(), outputReloadFailures.Get()
+	reloader.load = func() (*common.Config, error) {
+		return common.NewConfigFrom(settings)
+	}
+
+	// unchanged settings are not reloaded
+	reloader.reload()
+	assert.Equal(t, reloads, outputReloads.Get())
+
+	settings["output.console.pretty"] = true
+	settings["processors"] = []map[string]interface{}{
+		{"drop_fields": map[string]interface{}{"fields": []string{"debug"}}},
+	}
+	reloader.reload()
+	assert.Equal(t, reloads+1, outputReloads.Get())
+	assert.Equal(t, failures, outputReloadFailures.Get())
+
+	// invalid settings are reported once
+	settings["processors"] = []map[string]interface{}{
+		{"unknown": map[string]interface{}{}},
+	}
+	reloader.reload()
+	reloader.reload()
+	assert.Equal(t, reloads+2, outputReloads.Get())
+	assert.Equal(t, failures+1, outputReloadFailures.Get())
+}
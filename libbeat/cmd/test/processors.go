@@ -0,0 +1,277 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/cmd/instance"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/jsontransform"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+func GenTestProcessorsCmd(name, beatVersion string) *cobra.Command {
+	var eventFiles []string
+
+	command := &cobra.Command{
+		Use:   "processors",
+		Short: "Test the processors of the current settings against sample events",
+		Long: "Run the sample events from the files given with --event through the\n" +
+			"global processors and print the changes made by each processor and the\n" +
+			"resulting events. Files contain one or more JSON objects or arrays of\n" +
+			"objects, use - to read from stdin. Exits with an error if a processor fails.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(eventFiles) == 0 {
+				fmt.Fprintf(os.Stderr, "At least one sample event file must be given with --event\n")
+				os.Exit(1)
+			}
+
+			b, err := instance.NewBeat(name, "", beatVersion)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing beat: %s\n", err)
+				os.Exit(1)
+			}
+
+			err = b.Init()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing beat: %s\n", err)
+				os.Exit(1)
+			}
+
+			procs, err := processors.New(b.Config.Pipeline.Processors)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing processors: %s\n", err)
+				os.Exit(1)
+			}
+
+			var events []beat.Event
+			for _, file := range eventFiles {
+				fileEvents, err := readEventsFile(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading sample events from %s: %s\n", file, err)
+					os.Exit(1)
+				}
+				events = append(events, fileEvents...)
+			}
+
+			if failed := runProcessors(os.Stdout, procs, events); failed > 0 {
+				fmt.Fprintf(os.Stderr, "%d processor errors\n", failed)
+				os.Exit(1)
+			}
+		},
+	}
+
+	command.Flags().StringArrayVar(&eventFiles, "event", nil, "File with sample events in JSON format, - reads from stdin")
+	return command
+}
+
+func readEventsFile(path string) ([]beat.Event, error) {
+	if path == "-" {
+		return readEvents(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readEvents(f)
+}
+
+// readEvents decodes a stream of JSON objects or arrays of objects into
+// events. The `@timestamp` and `@metadata` keys are used as the timestamp and
+// metadata of the event. Events without timestamp get the current time.
+func readEvents(r io.Reader) ([]beat.Event, error) {
+	var events []beat.Event
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var docs []map[string]interface{}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = unmarshal(raw, &docs)
+		} else {
+			var doc map[string]interface{}
+			err = unmarshal(raw, &doc)
+			docs = append(docs, doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			event, err := newEvent(doc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+	}
+}
+
+func unmarshal(raw []byte, to interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(to)
+}
+
+func newEvent(doc map[string]interface{}) (beat.Event, error) {
+	if doc == nil {
+		return beat.Event{}, fmt.Errorf("sample event must be a JSON object")
+	}
+
+	fields := common.MapStr(doc)
+	jsontransform.TransformNumbers(fields)
+	event := beat.Event{Timestamp: time.Now()}
+
+	if v, exists := fields["@timestamp"]; exists {
+		s, ok := v.(string)
+		if !ok {
+			return event, fmt.Errorf("@timestamp must be a string")
+		}
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return event, fmt.Errorf("failed to parse @timestamp: %v", err)
+		}
+		event.Timestamp = ts
+		delete(fields, "@timestamp")
+	}
+
+	if v, exists := fields["@metadata"]; exists {
+		meta, ok := v.(map[string]interface{})
+		if !ok {
+			return event, fmt.Errorf("@metadata must be an object")
+		}
+		event.Meta = common.MapStr(meta)
+		delete(fields, "@metadata")
+	}
+
+	event.Fields = fields
+	return event, nil
+}
+
+// runProcessors runs all events through the processors, printing the fields
+// changed by each processor and the resulting event. Returns the number of
+// processor errors.
+func runProcessors(w io.Writer, procs *processors.Processors, events []beat.Event) int {
+	failed := 0
+	for i := range events {
+		fmt.Fprintf(w, "event %d:\n", i+1)
+
+		event := &events[i]
+		for _, p := range procs.List {
+			before := event.Fields.Clone().Flatten()
+
+			var err error
+			event, err = p.Run(event)
+			if err != nil {
+				fmt.Fprintf(w, "  %s: error: %v\n", p, err)
+				failed++
+			}
+			if event == nil {
+				fmt.Fprintf(w, "  %s: event dropped\n", p)
+				break
+			}
+
+			changes := diffFields(before, event.Fields.Flatten())
+			if err == nil && len(changes) == 0 {
+				fmt.Fprintf(w, "  %s: no changes\n", p)
+				continue
+			}
+			if len(changes) > 0 {
+				fmt.Fprintf(w, "  %s:\n", p)
+			}
+			for _, change := range changes {
+				fmt.Fprintf(w, "    %s\n", change)
+			}
+		}
+
+		if event != nil {
+			fmt.Fprintf(w, "result:\n%s\n", formatEvent(event))
+		}
+	}
+	return failed
+}
+
+// diffFields returns the added (+), removed (-) and modified (~) fields by
+// comparing the flattened fields of an event.
+func diffFields(before, after common.MapStr) []string {
+	var changes []string
+	for key, value := range after {
+		old, exists := before[key]
+		switch {
+		case !exists:
+			changes = append(changes, fmt.Sprintf("+ %s: %s", key, formatValue(value)))
+		case !reflect.DeepEqual(old, value):
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", key, formatValue(old), formatValue(value)))
+		}
+	}
+	for key, value := range before {
+		if _, exists := after[key]; !exists {
+			changes = append(changes, fmt.Sprintf("- %s: %s", key, formatValue(value)))
+		}
+	}
+
+	// sort by field name, ignoring the change marker
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i][2:] < changes[j][2:]
+	})
+	return changes
+}
+
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+func formatEvent(event *beat.Event) string {
+	doc := event.Fields.Clone()
+	doc["@timestamp"] = common.Time(event.Timestamp)
+	if len(event.Meta) > 0 {
+		doc["@metadata"] = event.Meta
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("failed to format event: %v", err)
+	}
+	return string(b)
+}
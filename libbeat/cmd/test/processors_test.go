@@ -0,0 +1,131 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+	_ "github.com/elastic/beats/libbeat/processors/actions"
+	_ "github.com/elastic/beats/libbeat/processors/dissect"
+)
+
+func newTestProcessors(t *testing.T, yaml string) *processors.Processors {
+	cfg, err := common.NewConfigWithYAML([]byte(yaml), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Processors processors.PluginConfig `config:"processors"`
+	}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	procs, err := processors.New(config.Processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return procs
+}
+
+func TestReadEvents(t *testing.T) {
+	events, err := readEvents(strings.NewReader(`
+{"@timestamp": "2018-04-12T08:00:00Z", "message": "a", "count": 1}
+[{"message": "b", "@metadata": {"pipeline": "p"}}, {"message": "c"}]
+`))
+	if !assert.NoError(t, err) || !assert.Len(t, events, 3) {
+		return
+	}
+
+	assert.Equal(t, time.Date(2018, 4, 12, 8, 0, 0, 0, time.UTC), events[0].Timestamp.UTC())
+	assert.Equal(t, common.MapStr{"message": "a", "count": int64(1)}, events[0].Fields)
+	assert.Equal(t, common.MapStr{"pipeline": "p"}, events[1].Meta)
+	assert.Equal(t, common.MapStr{"message": "c"}, events[2].Fields)
+
+	_, err = readEvents(strings.NewReader(`"message"`))
+	assert.Error(t, err)
+}
+
+func TestRunProcessors(t *testing.T) {
+	procs := newTestProcessors(t, `
+processors:
+  - dissect:
+      tokenizer: "%{verb} %{path}"
+  - drop_fields:
+      fields: [message]
+  - drop_event:
+      when.equals.dissect.verb: DELETE
+`)
+
+	events, err := readEvents(strings.NewReader(`
+{"@timestamp": "2018-04-12T08:00:00Z", "message": "GET /index.html"}
+{"message": "DELETE /index.html"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	failed := runProcessors(&buf, procs, events)
+	assert.Equal(t, 0, failed)
+
+	out := buf.String()
+	assert.Contains(t, out, `    + dissect.verb: "GET"`)
+	assert.Contains(t, out, `    + dissect.path: "/index.html"`)
+	assert.Contains(t, out, `    - message: "GET /index.html"`)
+	assert.Contains(t, out, `"@timestamp": "2018-04-12T08:00:00.000Z"`)
+	assert.Contains(t, out, "event dropped")
+	assert.Equal(t, 1, strings.Count(out, "result:"))
+}
+
+func TestRunProcessorsError(t *testing.T) {
+	procs := newTestProcessors(t, `
+processors:
+  - dissect:
+      tokenizer: "%{verb} %{path}"
+`)
+
+	events, err := readEvents(strings.NewReader(`{"message": "invalid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, 1, runProcessors(&buf, procs, events))
+	assert.Contains(t, buf.String(), "error:")
+}
+
+func TestDiffFields(t *testing.T) {
+	changes := diffFields(
+		common.MapStr{"a": 1, "b": "x", "c": true},
+		common.MapStr{"a": 2, "c": true, "d": "y"},
+	)
+	assert.Equal(t, []string{
+		"~ a: 1 -> 2",
+		"- b: \"x\"",
+		"+ d: \"y\"",
+	}, changes)
+}
@@ -0,0 +1,356 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package acker helps inputs to update checkpoints in the source, like Kafka
+// offsets, SQS message deletes or file offsets, only after the events read
+// from the source have been ACKed by the outputs. This provides at-least-once
+// delivery end-to-end.
+//
+// Events are grouped into batches created by a Tracker. The Private field of
+// every event published must be set to the handle returned by Batch.Add. The
+// beat must call ACK on the handles reported by the pipeline ACK handler. The
+// ACKEvents function can be used as beat.ClientConfig.ACKEvents callback.
+//
+// A batch is finished by the input once all its events have been published,
+// and completes once all events have been ACKed. Batches fail if they are not
+// completed within the configured timeout or if the tracker is closed, for
+// example because the pipeline is shut down.
+package acker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTimeout is reported by batches not ACKed within the configured
+	// timeout.
+	ErrTimeout = errors.New("timeout waiting for events to be ACKed")
+
+	// ErrCanceled is reported by batches still pending when the tracker is
+	// closed.
+	ErrCanceled = errors.New("waiting for events to be ACKed has been canceled")
+)
+
+// EventACKer is implemented by the event handles returned by Batch.Add.
+type EventACKer interface {
+	// ACK marks the event as ACKed by the outputs.
+	ACK()
+}
+
+// ACKEvents calls ACK on all event private data implementing EventACKer.
+func ACKEvents(data []interface{}) {
+	for _, datum := range data {
+		if acker, ok := datum.(EventACKer); ok {
+			acker.ACK()
+		}
+	}
+}
+
+// Settings configures a Tracker.
+type Settings struct {
+	// Timeout is the maximum time to wait for all events of a batch to be
+	// ACKed after the batch has been finished. Zero disables the timeout.
+	Timeout time.Duration
+}
+
+// Tracker tracks the batches of an input.
+type Tracker struct {
+	settings Settings
+
+	mutex   sync.Mutex
+	closed  bool
+	pending map[*Batch]struct{}
+	idle    chan struct{} // closed if no batch is pending
+
+	// ordered contains the batches created with a checkpoint, which have not
+	// been accounted for in checkpoint yet.
+	ordered    []*Batch
+	checkpoint interface{}
+	blocked    bool // a batch with checkpoint failed
+}
+
+// Batch tracks the ACKs of a group of events.
+type Batch struct {
+	tracker    *Tracker
+	checkpoint interface{}
+	onACK      func()
+
+	// fields protected by the tracker mutex
+	events   int
+	finished bool
+	state    batchState
+	err      error
+	timer    *time.Timer
+	done     chan struct{}
+}
+
+type batchState uint8
+
+const (
+	batchPending batchState = iota
+	batchACKed
+	batchFailed
+)
+
+type eventACK struct {
+	batch *Batch
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker(settings Settings) *Tracker {
+	idle := make(chan struct{})
+	close(idle)
+	return &Tracker{
+		settings: settings,
+		pending:  map[*Batch]struct{}{},
+		idle:     idle,
+	}
+}
+
+// NewBatch creates a new batch. The onACK callback is run once all events of
+// the batch have been ACKed. It is not run if the batch fails. If checkpoint
+// is not nil, the checkpoint is reported by Checkpoint once the batch and all
+// batches with checkpoint created before have been ACKed.
+func (t *Tracker) NewBatch(checkpoint interface{}, onACK func()) *Batch {
+	b := &Batch{
+		tracker:    t,
+		checkpoint: checkpoint,
+		onACK:      onACK,
+		done:       make(chan struct{}),
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		b.state = batchFailed
+		b.err = ErrCanceled
+		close(b.done)
+		return b
+	}
+
+	if len(t.pending) == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.pending[b] = struct{}{}
+	if checkpoint != nil && !t.blocked {
+		t.ordered = append(t.ordered, b)
+	}
+	return b
+}
+
+// AddEvent creates a finished batch with a single event, returning the
+// handle to be stored in the events Private field.
+func (t *Tracker) AddEvent(checkpoint interface{}) EventACKer {
+	b := t.NewBatch(checkpoint, nil)
+	e := b.Add()
+	b.Finish()
+	return e
+}
+
+// Checkpoint returns the checkpoint of the latest batch for which the batch
+// and all batches with checkpoint created before it have been ACKed. It
+// returns nil if no such batch exists. Once a batch with checkpoint fails,
+// the checkpoint does not advance anymore, so no events are lost if the input
+// restarts from the checkpoint.
+func (t *Tracker) Checkpoint() interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.checkpoint
+}
+
+// Pending returns the number of batches neither completed nor failed.
+func (t *Tracker) Pending() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.pending)
+}
+
+// Wait waits up to timeout for all pending batches to be completed or failed.
+// It returns false if batches are still pending after the timeout.
+func (t *Tracker) Wait(timeout time.Duration) bool {
+	t.mutex.Lock()
+	idle := t.idle
+	t.mutex.Unlock()
+
+	if timeout <= 0 {
+		select {
+		case <-idle:
+			return true
+		default:
+			return false
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Close waits up to timeout for pending batches, before failing all batches
+// still pending with ErrCanceled. Batches created after Close fail
+// immediately.
+func (t *Tracker) Close(timeout time.Duration) {
+	t.Wait(timeout)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.closed = true
+	for b := range t.pending {
+		t.fail(b, ErrCanceled)
+	}
+}
+
+// Add registers a new event to the batch, returning the handle to be stored
+// in the events Private field.
+func (b *Batch) Add() EventACKer {
+	t := b.tracker
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	b.events++
+	return &eventACK{batch: b}
+}
+
+// ACK marks the event as ACKed.
+func (e *eventACK) ACK() {
+	b := e.batch
+	t := b.tracker
+
+	t.mutex.Lock()
+	b.events--
+	complete := t.complete(b)
+	t.mutex.Unlock()
+
+	if complete && b.onACK != nil {
+		b.onACK()
+	}
+}
+
+// Finish signals that all events of the batch have been published. The
+// timeout starts once the batch is finished.
+func (b *Batch) Finish() {
+	t := b.tracker
+
+	t.mutex.Lock()
+	if b.finished {
+		t.mutex.Unlock()
+		return
+	}
+	b.finished = true
+	complete := t.complete(b)
+	if !complete && b.state == batchPending && t.settings.Timeout > 0 {
+		b.timer = time.AfterFunc(t.settings.Timeout, func() {
+			b.Fail(ErrTimeout)
+		})
+	}
+	t.mutex.Unlock()
+
+	if complete && b.onACK != nil {
+		b.onACK()
+	}
+}
+
+// Fail marks the batch as failed. The onACK callback will not be run and
+// ACKs received later are ignored.
+func (b *Batch) Fail(err error) {
+	t := b.tracker
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.fail(b, err)
+}
+
+// Done returns a channel, which is closed once the batch has been completed or
+// failed.
+func (b *Batch) Done() <-chan struct{} {
+	return b.done
+}
+
+// Err returns the reason the batch failed. It returns nil if the batch is
+// still pending or has been completed.
+func (b *Batch) Err() error {
+	t := b.tracker
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return b.err
+}
+
+// complete must be called with the mutex held. It returns true once, if all
+// events of the batch have been published and ACKed.
+func (t *Tracker) complete(b *Batch) bool {
+	if b.state != batchPending || !b.finished || b.events > 0 {
+		return false
+	}
+
+	b.state = batchACKed
+	t.done(b)
+	t.advance()
+	return true
+}
+
+// fail must be called with the mutex held.
+func (t *Tracker) fail(b *Batch, err error) {
+	if b.state != batchPending {
+		return
+	}
+
+	b.state = batchFailed
+	b.err = err
+	t.done(b)
+	if b.checkpoint != nil {
+		t.advance()
+	}
+}
+
+func (t *Tracker) done(b *Batch) {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	close(b.done)
+
+	delete(t.pending, b)
+	if len(t.pending) == 0 {
+		close(t.idle)
+	}
+}
+
+// advance updates the checkpoint with all ordered batches ACKed.
+func (t *Tracker) advance() {
+	n := 0
+	for ; n < len(t.ordered); n++ {
+		b := t.ordered[n]
+		if b.state == batchFailed {
+			// no further batch can be checkpointed
+			t.blocked = true
+			t.ordered = nil
+			return
+		}
+		if b.state != batchACKed {
+			break
+		}
+		t.checkpoint = b.checkpoint
+	}
+	t.ordered = t.ordered[n:]
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package acker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func isDone(b *Batch) bool {
+	select {
+	case <-b.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBatchACK(t *testing.T) {
+	tracker := NewTracker(Settings{})
+
+	acked := 0
+	b := tracker.NewBatch(nil, func() { acked++ })
+	e1, e2 := b.Add(), b.Add()
+	e1.ACK()
+	e2.ACK()
+	assert.Equal(t, 0, acked, "batch not finished yet")
+
+	e3 := b.Add()
+	b.Finish()
+	assert.Equal(t, 0, acked)
+	assert.Equal(t, 1, tracker.Pending())
+
+	e3.ACK()
+	assert.Equal(t, 1, acked)
+	assert.True(t, isDone(b))
+	assert.NoError(t, b.Err())
+	assert.Equal(t, 0, tracker.Pending())
+}
+
+func TestBatchEmpty(t *testing.T) {
+	acked := 0
+	b := NewTracker(Settings{}).NewBatch(nil, func() { acked++ })
+	b.Finish()
+	b.Finish()
+	assert.Equal(t, 1, acked)
+}
+
+func TestBatchFail(t *testing.T) {
+	acked := 0
+	b := NewTracker(Settings{}).NewBatch(nil, func() { acked++ })
+
+	e := b.Add()
+	b.Fail(ErrCanceled)
+	e.ACK()
+	b.Finish()
+	assert.Equal(t, 0, acked)
+	assert.True(t, isDone(b))
+	assert.Equal(t, ErrCanceled, b.Err())
+}
+
+func TestBatchTimeout(t *testing.T) {
+	tracker := NewTracker(Settings{Timeout: 10 * time.Millisecond})
+
+	acked := false
+	b := tracker.NewBatch(nil, func() { acked = true })
+	e := b.Add()
+	b.Finish()
+
+	select {
+	case <-b.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch did not time out")
+	}
+	e.ACK()
+	assert.False(t, acked)
+	assert.Equal(t, ErrTimeout, b.Err())
+}
+
+func TestCheckpoint(t *testing.T) {
+	tracker := NewTracker(Settings{})
+	assert.Nil(t, tracker.Checkpoint())
+
+	e1 := tracker.AddEvent(int64(1))
+	e2 := tracker.AddEvent(int64(2))
+	b := tracker.NewBatch(nil, nil)
+	e3 := tracker.AddEvent(int64(3))
+
+	e2.ACK()
+	assert.Nil(t, tracker.Checkpoint(), "first event not ACKed yet")
+
+	e1.ACK()
+	assert.Equal(t, int64(2), tracker.Checkpoint())
+
+	// batches without checkpoint do not block the checkpoint
+	e3.ACK()
+	assert.Equal(t, int64(3), tracker.Checkpoint())
+	b.Finish()
+}
+
+func TestCheckpointBlockedByFailure(t *testing.T) {
+	tracker := NewTracker(Settings{})
+
+	e1 := tracker.AddEvent(1)
+	b := tracker.NewBatch(2, nil)
+	e2 := b.Add()
+	b.Finish()
+	e3 := tracker.AddEvent(3)
+
+	e1.ACK()
+	b.Fail(ErrTimeout)
+	e2.ACK()
+	e3.ACK()
+	assert.Equal(t, 1, tracker.Checkpoint())
+
+	tracker.AddEvent(4).ACK()
+	assert.Equal(t, 1, tracker.Checkpoint())
+}
+
+func TestTrackerClose(t *testing.T) {
+	tracker := NewTracker(Settings{})
+
+	b1 := tracker.NewBatch(nil, nil)
+	b1.Add().ACK()
+	b1.Finish()
+
+	b2 := tracker.NewBatch(nil, nil)
+	b2.Add()
+	b2.Finish()
+
+	assert.False(t, tracker.Wait(10*time.Millisecond))
+	tracker.Close(0)
+	assert.True(t, tracker.Wait(0))
+	assert.NoError(t, b1.Err())
+	assert.Equal(t, ErrCanceled, b2.Err())
+
+	b3 := tracker.NewBatch(nil, nil)
+	assert.True(t, isDone(b3))
+	assert.Equal(t, ErrCanceled, b3.Err())
+	assert.Equal(t, 0, tracker.Pending())
+}
+
+func TestTrackerWait(t *testing.T) {
+	tracker := NewTracker(Settings{})
+	assert.True(t, tracker.Wait(0))
+
+	e := tracker.AddEvent(nil)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		e.ACK()
+	}()
+	assert.True(t, tracker.Wait(5*time.Second))
+}
+
+func TestACKEvents(t *testing.T) {
+	tracker := NewTracker(Settings{})
+	e := tracker.AddEvent(1)
+
+	ACKEvents([]interface{}{nil, "state", e})
+	assert.Equal(t, 1, tracker.Checkpoint())
+}
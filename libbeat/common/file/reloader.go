@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LoadFunc reads the file at path and returns the value it contains.
+type LoadFunc func(path string) (interface{}, error)
+
+// Reloader keeps the value loaded from a file and reloads it when the file changes on disk. The
+// file is checked lazily when the value is read, at most once per period. Reading the value
+// between checks doesn't take any lock.
+type Reloader struct {
+	// nextCheck is the time of the next check in nanoseconds, it is accessed atomically and must
+	// stay the first field to be aligned on 32-bit platforms.
+	nextCheck int64
+	checking  int32
+
+	path   string
+	period time.Duration
+	load   LoadFunc
+	value  atomic.Value
+
+	// modTime and size are only accessed by the goroutine checking the file.
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the file at path and returns a Reloader for it. A period lower or equal to
+// zero disables the reloading.
+func NewReloader(path string, period time.Duration, load LoadFunc) (*Reloader, error) {
+	r := &Reloader{path: path, period: period, load: load}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.reload(info); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the path of the file.
+func (r *Reloader) Path() string {
+	return r.path
+}
+
+// Get returns the value loaded from the file. When the period is elapsed and the file has changed
+// it is reloaded first, on failure the previous value is returned together with the error. Only
+// one caller checks the file at a time, concurrent callers get the current value without waiting.
+// The returned boolean is true when the value has been reloaded.
+func (r *Reloader) Get() (interface{}, bool, error) {
+	if r.period <= 0 || time.Now().UnixNano() < atomic.LoadInt64(&r.nextCheck) {
+		return r.value.Load(), false, nil
+	}
+
+	if !atomic.CompareAndSwapInt32(&r.checking, 0, 1) {
+		return r.value.Load(), false, nil
+	}
+	defer atomic.StoreInt32(&r.checking, 0)
+
+	reloaded, err := r.check()
+	return r.value.Load(), reloaded, err
+}
+
+func (r *Reloader) check() (bool, error) {
+	atomic.StoreInt64(&r.nextCheck, time.Now().Add(r.period).UnixNano())
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot check file for changes")
+	}
+
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+
+	if err := r.reload(info); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Reloader) reload(info os.FileInfo) error {
+	v, err := r.load(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.value.Store(v)
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	atomic.StoreInt64(&r.nextCheck, time.Now().Add(r.period).UnixNano())
+	return nil
+}
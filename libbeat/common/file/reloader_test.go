@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reloader")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	write := func(content string, modTime time.Time) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	load := func(path string) (interface{}, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 0 {
+			return nil, os.ErrInvalid
+		}
+		return string(content), nil
+	}
+
+	now := time.Now()
+	write("first", now)
+	r, err := NewReloader(path, time.Nanosecond, load)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	v, reloaded, err := r.Get()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "first", v)
+
+	write("second", now.Add(time.Minute))
+	v, reloaded, err = r.Get()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", v)
+
+	// The previous value is kept when the file cannot be loaded.
+	write("", now.Add(2*time.Minute))
+	v, reloaded, err = r.Get()
+	assert.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, "second", v)
+}
+
+func TestReloaderPeriod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reloader")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte("first"), 0644)) {
+		return
+	}
+
+	loads := 0
+	load := func(path string) (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+
+	r, err := NewReloader(path, time.Hour, load)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	later := time.Now().Add(time.Minute)
+	if !assert.NoError(t, os.Chtimes(path, later, later)) {
+		return
+	}
+
+	v, reloaded, err := r.Get()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, 1, v)
+}
+
+func TestReloaderMissingFile(t *testing.T) {
+	_, err := NewReloader("/does/not/exist", time.Second, func(string) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Error(t, err)
+}
@@ -15,6 +15,8 @@
 // specific language governing permissions and limitations
 // under the License.
 
+// Package kafka contains kafka settings shared by the kafka output and the
+// kafka input.
 package kafka
 
 import "github.com/Shopify/sarama"
@@ -68,6 +70,13 @@ var (
 	}
 )
 
+// ParseVersion returns the sarama protocol version for a kafka version
+// supported by beats. The empty string selects the default version.
+func ParseVersion(s string) (sarama.KafkaVersion, bool) {
+	v, ok := kafkaVersions[s]
+	return v, ok
+}
+
 func parseKafkaVersion(s string) sarama.KafkaVersion {
 	v, err := sarama.ParseKafkaVersion(s)
 	if err != nil {
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package protowire provides a minimal protocol buffers wire format encoder
+// and decoder, sufficient for encoding and decoding messages without generated
+// code.
+// See https://developers.google.com/protocol-buffers/docs/encoding.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Buffer encodes protobuf messages.
+type Buffer struct {
+	buf []byte
+}
+
+// Wire types of encoded fields.
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// ErrInvalid is returned by Reader if the message can not be decoded.
+var ErrInvalid = errors.New("invalid protobuf message")
+
+// Bytes returns the encoded message.
+func (b *Buffer) Bytes() []byte {
+	return b.buf
+}
+
+// Reset clears the buffer, keeping the allocated memory.
+func (b *Buffer) Reset() {
+	b.buf = b.buf[:0]
+}
+
+// Varint appends a varint encoded value.
+func (b *Buffer) Varint(v uint64) {
+	for v >= 0x80 {
+		b.buf = append(b.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	b.buf = append(b.buf, byte(v))
+}
+
+// Tag appends a field key.
+func (b *Buffer) Tag(field, wireType int) {
+	b.Varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *Buffer) VarintField(field int, v uint64) {
+	b.Tag(field, WireVarint)
+	b.Varint(v)
+}
+
+func (b *Buffer) BoolField(field int, v bool) {
+	var i uint64
+	if v {
+		i = 1
+	}
+	b.VarintField(field, i)
+}
+
+// Fixed32 appends a little endian encoded 32 bit value.
+func (b *Buffer) Fixed32(v uint32) {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	b.buf = append(b.buf, tmp[:]...)
+}
+
+// Fixed64 appends a little endian encoded 64 bit value.
+func (b *Buffer) Fixed64(v uint64) {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	b.buf = append(b.buf, tmp[:]...)
+}
+
+func (b *Buffer) Fixed32Field(field int, v uint32) {
+	b.Tag(field, WireFixed32)
+	b.Fixed32(v)
+}
+
+func (b *Buffer) Fixed64Field(field int, v uint64) {
+	b.Tag(field, WireFixed64)
+	b.Fixed64(v)
+}
+
+func (b *Buffer) DoubleField(field int, v float64) {
+	b.Fixed64Field(field, math.Float64bits(v))
+}
+
+func (b *Buffer) BytesField(field int, v []byte) {
+	b.Tag(field, WireBytes)
+	b.Varint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+func (b *Buffer) StringField(field int, v string) {
+	b.Tag(field, WireBytes)
+	b.Varint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// MessageField encodes a nested message. The length prefix is written after
+// encoding the message, moving the message content if required.
+func (b *Buffer) MessageField(field int, fn func(*Buffer)) {
+	b.Tag(field, WireBytes)
+
+	// reserve a single byte for the length, enough for small messages
+	offset := len(b.buf)
+	b.buf = append(b.buf, 0)
+	fn(b)
+
+	size := len(b.buf) - offset - 1
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(size))
+	if n == 1 {
+		b.buf[offset] = tmp[0]
+		return
+	}
+
+	b.buf = append(b.buf, tmp[:n-1]...)
+	copy(b.buf[offset+n:], b.buf[offset+1:offset+1+size])
+	copy(b.buf[offset:], tmp[:n])
+}
+
+// Reader iterates the fields of an encoded protobuf message.
+type Reader struct {
+	buf []byte
+}
+
+// NewReader creates a Reader for the encoded message.
+func NewReader(msg []byte) *Reader {
+	return &Reader{buf: msg}
+}
+
+// Next returns the next field. For varint and fixed fields the value is
+// returned in v, for length delimited fields in data.
+func (r *Reader) Next() (field, wireType int, v uint64, data []byte, err error) {
+	key, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, 0, 0, nil, ErrInvalid
+	}
+	r.buf = r.buf[n:]
+	field, wireType = int(key>>3), int(key&7)
+
+	switch wireType {
+	case WireVarint:
+		v, n = binary.Uvarint(r.buf)
+		if n <= 0 {
+			return 0, 0, 0, nil, ErrInvalid
+		}
+		r.buf = r.buf[n:]
+	case WireFixed64:
+		if len(r.buf) < 8 {
+			return 0, 0, 0, nil, ErrInvalid
+		}
+		v = binary.LittleEndian.Uint64(r.buf)
+		r.buf = r.buf[8:]
+	case WireFixed32:
+		if len(r.buf) < 4 {
+			return 0, 0, 0, nil, ErrInvalid
+		}
+		v = uint64(binary.LittleEndian.Uint32(r.buf))
+		r.buf = r.buf[4:]
+	case WireBytes:
+		size, n := binary.Uvarint(r.buf)
+		if n <= 0 || uint64(len(r.buf)-n) < size {
+			return 0, 0, 0, nil, ErrInvalid
+		}
+		data = r.buf[n : n+int(size)]
+		r.buf = r.buf[n+int(size):]
+	default:
+		return 0, 0, 0, nil, ErrInvalid
+	}
+	return field, wireType, v, data, nil
+}
+
+// Done returns true if all fields have been read.
+func (r *Reader) Done() bool {
+	return len(r.buf) == 0
+}
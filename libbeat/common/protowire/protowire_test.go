@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protowire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	var b Buffer
+	b.VarintField(1, 300)
+	b.BoolField(2, true)
+	b.Fixed32Field(3, 7)
+	b.DoubleField(4, 1.5)
+	b.StringField(5, "hello")
+
+	r := NewReader(b.Bytes())
+	expected := []struct {
+		field, wireType int
+		v               uint64
+		data            string
+	}{
+		{1, WireVarint, 300, ""},
+		{2, WireVarint, 1, ""},
+		{3, WireFixed32, 7, ""},
+		{4, WireFixed64, 0x3ff8000000000000, ""},
+		{5, WireBytes, 0, "hello"},
+	}
+	for _, e := range expected {
+		field, wireType, v, data, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, e.field, field)
+		assert.Equal(t, e.wireType, wireType)
+		assert.Equal(t, e.v, v)
+		assert.Equal(t, e.data, string(data))
+	}
+	assert.True(t, r.Done())
+}
+
+func TestLargeMessage(t *testing.T) {
+	var b Buffer
+	long := string(make([]byte, 300))
+	b.MessageField(1, func(b *Buffer) {
+		b.StringField(1, long)
+		b.VarintField(2, 42)
+	})
+
+	r := NewReader(b.Bytes())
+	field, _, _, msg, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, field)
+	assert.True(t, r.Done())
+
+	inner := NewReader(msg)
+	_, _, _, data, err := inner.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, long, string(data))
+	_, _, v, _, err := inner.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(42), v)
+}
+
+func TestInvalidMessage(t *testing.T) {
+	var b Buffer
+	b.StringField(1, "hello")
+	msg := b.Bytes()
+
+	_, _, _, _, err := NewReader(msg[:len(msg)-1]).Next()
+	assert.Equal(t, ErrInvalid, err)
+}
@@ -33,13 +33,31 @@ type Config struct {
 	Certificate      CertificateConfig       `config:",inline"`
 	CurveTypes       []tlsCurveType          `config:"curve_types"`
 	Renegotiation    tlsRenegotiationSupport `config:"renegotiation"`
+	CRLs             []string                `config:"certificate_revocation_lists"`
+	OCSPStapling     OCSPStaplingMode        `config:"ocsp_stapling"`
+	Reload           ReloadConfig            `config:"reload"`
 }
 
 // LoadTLSConfig will load a certificate from config with all TLS based keys
 // defined. If Certificate and CertificateKey are configured, client authentication
 // will be configured. If no CAs are configured, the host CA will be used by go
-// built-in TLS support.
+// built-in TLS support. If reloading is enabled, the files are reloaded when
+// they are changed.
 func LoadTLSConfig(config *Config) (*TLSConfig, error) {
+	tlsConfig, err := loadTLSConfig(config)
+	if err != nil || tlsConfig == nil || !config.Reload.Enabled {
+		return tlsConfig, err
+	}
+
+	current := *tlsConfig
+	files := reloadFiles(&config.Certificate, config.CAs, config.CRLs)
+	tlsConfig.reloader = newCertReloader(config.Reload, files, &current, func() (*TLSConfig, error) {
+		return loadTLSConfig(config)
+	})
+	return tlsConfig, nil
+}
+
+func loadTLSConfig(config *Config) (*TLSConfig, error) {
 	if !config.IsEnabled() {
 		return nil, nil
 	}
@@ -69,6 +87,9 @@ func LoadTLSConfig(config *Config) (*TLSConfig, error) {
 	cas, errs := LoadCertificateAuthorities(config.CAs)
 	logFail(errs...)
 
+	crls, errs := LoadCRLs(config.CRLs)
+	logFail(errs...)
+
 	// fail, if any error occurred when loading certificate files
 	if err = fail.Err(); err != nil {
 		return nil, err
@@ -88,6 +109,8 @@ func LoadTLSConfig(config *Config) (*TLSConfig, error) {
 		CipherSuites:     cipherSuites,
 		CurvePreferences: curves,
 		Renegotiation:    tls.RenegotiationSupport(config.Renegotiation),
+		CRLs:             crls,
+		OCSPStapling:     config.OCSPStapling,
 	}, nil
 }
 
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tlscommon
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// LoadCRLs reads the PEM or DER encoded certificate revocation lists from the
+// given files.
+func LoadCRLs(paths []string) ([]*pkix.CertificateList, []error) {
+	var crls []*pkix.CertificateList
+	var errors []error
+
+	now := time.Now()
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			logp.Critical("Failed reading CRL: %v", err)
+			errors = append(errors, fmt.Errorf("%v reading %v", err, path))
+			continue
+		}
+
+		crl, err := x509.ParseCRL(data)
+		if err != nil {
+			logp.Critical("Failed parsing CRL %v: %v", path, err)
+			errors = append(errors, fmt.Errorf("%v parsing %v", err, path))
+			continue
+		}
+
+		if crl.HasExpired(now) {
+			logp.Warn("CRL %v expired at %v, revoked certificates are still rejected", path, crl.TBSCertList.NextUpdate)
+		}
+		logp.Debug("tls", "successfully loaded CRL: %v", path)
+		crls = append(crls, crl)
+	}
+
+	return crls, errors
+}
+
+// checkRevoked returns an error if no chain is free of certificates listed as
+// revoked in a CRL published by their issuer.
+func checkRevoked(chains [][]*x509.Certificate, crls []*pkix.CertificateList) error {
+	var err error
+	for _, chain := range chains {
+		if err = checkChainRevoked(chain, crls); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func checkChainRevoked(chain []*x509.Certificate, crls []*pkix.CertificateList) error {
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, crl := range crls {
+			if !isCRLIssuer(crl, issuer) {
+				continue
+			}
+
+			for _, entry := range crl.TBSCertList.RevokedCertificates {
+				if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return fmt.Errorf("certificate '%v' has been revoked", cert.Subject)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isCRLIssuer(crl *pkix.CertificateList, issuer *x509.Certificate) bool {
+	var name pkix.Name
+	name.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+	if name.String() != issuer.Subject.String() {
+		return false
+	}
+	return issuer.CheckCRLSignature(crl) == nil
+}
@@ -0,0 +1,268 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tlscommon
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Minimal OCSP support, see RFC 6960.
+
+// OCSPStatus is the status of a certificate reported by an OCSP responder.
+type OCSPStatus int
+
+// Certificate status reported in OCSP responses.
+const (
+	OCSPUnknown OCSPStatus = iota
+	OCSPGood
+	OCSPRevoked
+)
+
+var (
+	oidSHA1      = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+)
+
+func (s OCSPStatus) String() string {
+	switch s {
+	case OCSPGood:
+		return "good"
+	case OCSPRevoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// signatureAlgorithms maps the signature algorithms supported for verifying
+// OCSP responses.
+var signatureAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	algo x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}, x509.ECDSAWithSHA1},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+}
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []singleRequest
+}
+
+type singleRequest struct {
+	Cert certID
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// NewOCSPRequest creates a DER encoded OCSP request for the status of the
+// certificate.
+func NewOCSPRequest(cert, issuer *x509.Certificate) ([]byte, error) {
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: tbsRequest{RequestList: []singleRequest{{Cert: id}}},
+	})
+}
+
+// ParseOCSPResponse parses a DER encoded OCSP response, verifies it is signed
+// by the issuer or a responder delegated by the issuer, and returns the status
+// of the certificate.
+func ParseOCSPResponse(data []byte, cert, issuer *x509.Certificate, now time.Time) (OCSPStatus, error) {
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return OCSPUnknown, err
+	}
+	return parseOCSPResponse(data, id, issuer, now)
+}
+
+func newCertID(cert, issuer *x509.Certificate) (certID, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return certID{}, err
+	}
+
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(publicKeyInfo.PublicKey.RightAlign())
+	return certID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidSHA1,
+			Parameters: asn1.RawValue{Tag: 5}, // NULL
+		},
+		IssuerNameHash: nameHash[:],
+		IssuerKeyHash:  keyHash[:],
+		SerialNumber:   cert.SerialNumber,
+	}, nil
+}
+
+func parseOCSPResponse(data []byte, id certID, issuer *x509.Certificate, now time.Time) (OCSPStatus, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(data, &resp); err != nil {
+		return OCSPUnknown, err
+	} else if len(rest) > 0 {
+		return OCSPUnknown, errors.New("trailing data")
+	}
+	if resp.Status != 0 {
+		return OCSPUnknown, fmt.Errorf("responder returned status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return OCSPUnknown, errors.New("unsupported response type")
+	}
+
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return OCSPUnknown, err
+	}
+	if err := verifyOCSPSignature(&basic, issuer); err != nil {
+		return OCSPUnknown, err
+	}
+
+	for _, r := range basic.TBSResponseData.Responses {
+		if r.CertID.SerialNumber.Cmp(id.SerialNumber) != 0 ||
+			!bytes.Equal(r.CertID.IssuerNameHash, id.IssuerNameHash) ||
+			!bytes.Equal(r.CertID.IssuerKeyHash, id.IssuerKeyHash) {
+			continue
+		}
+
+		if !r.NextUpdate.IsZero() && now.After(r.NextUpdate) {
+			return OCSPUnknown, errors.New("response expired")
+		}
+
+		switch {
+		case bool(r.Good):
+			return OCSPGood, nil
+		case !r.Revoked.RevocationTime.IsZero():
+			return OCSPRevoked, nil
+		default:
+			return OCSPUnknown, nil
+		}
+	}
+	return OCSPUnknown, errors.New("no response for certificate")
+}
+
+func verifyOCSPSignature(basic *basicResponse, issuer *x509.Certificate) error {
+	algo := x509.UnknownSignatureAlgorithm
+	for _, a := range signatureAlgorithms {
+		if a.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			algo = a.algo
+			break
+		}
+	}
+	if algo == x509.UnknownSignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm %v", basic.SignatureAlgorithm.Algorithm)
+	}
+
+	signed := basic.TBSResponseData.Raw
+	signature := basic.Signature.RightAlign()
+	if issuer.CheckSignature(algo, signed, signature) == nil {
+		return nil
+	}
+
+	// check for a responder certificate delegated by the issuer
+	for _, raw := range basic.Certificates {
+		responder, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			continue
+		}
+		if !hasExtKeyUsage(responder, x509.ExtKeyUsageOCSPSigning) || responder.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if responder.CheckSignature(algo, signed, signature) == nil {
+			return nil
+		}
+	}
+	return errors.New("signature not valid for issuer")
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
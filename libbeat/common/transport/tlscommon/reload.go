@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tlscommon
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const defaultReloadPeriod = 10 * time.Second
+
+// certReloader reloads the TLS settings if the certificate, key, certificate
+// authorities or CRL files are changed. The files are checked at most once per
+// period, when a new connection is established. This way no background
+// routine needs to be stopped when the configuration is not used anymore.
+type certReloader struct {
+	period time.Duration
+	files  []string
+	load   func() (*TLSConfig, error)
+
+	// mu protects config, checked and loading. The files are checked and
+	// loaded without holding mu, stats is only accessed while loading.
+	mu      sync.Mutex
+	config  *TLSConfig
+	checked time.Time
+	loading bool
+	stats   map[string]fileStat
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(
+	config ReloadConfig,
+	files []string,
+	current *TLSConfig,
+	load func() (*TLSConfig, error),
+) *certReloader {
+	period := config.Period
+	if period <= 0 {
+		period = defaultReloadPeriod
+	}
+
+	stats, err := statFiles(files)
+	if err != nil {
+		logp.Warn("Failed to check TLS files for changes: %v", err)
+	}
+
+	return &certReloader{
+		period:  period,
+		files:   files,
+		load:    load,
+		config:  current,
+		stats:   stats,
+		checked: time.Now(),
+	}
+}
+
+// reloadFiles returns the files to be watched for changes.
+func reloadFiles(cert *CertificateConfig, cas, crls []string) []string {
+	var files []string
+	if cert.Certificate != "" {
+		files = append(files, cert.Certificate)
+	}
+	if cert.Key != "" {
+		files = append(files, cert.Key)
+	}
+	files = append(files, cas...)
+	return append(files, crls...)
+}
+
+// current returns the TLS settings loaded from the latest version of the
+// files. If the files changed but can not be loaded, the last valid settings
+// are returned and loading is retried after the next period. All files are
+// loaded at once, so the certificate and key are replaced atomically. While
+// the files are loaded, concurrent callers get the current settings.
+func (r *certReloader) current() *TLSConfig {
+	r.mu.Lock()
+	current := r.config
+	if r.loading || time.Since(r.checked) < r.period {
+		r.mu.Unlock()
+		return current
+	}
+	r.loading = true
+	r.checked = time.Now()
+	r.mu.Unlock()
+
+	config := r.reload()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loading = false
+	if config != nil {
+		r.config = config
+		return config
+	}
+	return current
+}
+
+// reload loads the files if they changed. It returns nil if the files are
+// unchanged or can not be loaded.
+func (r *certReloader) reload() *TLSConfig {
+	stats, err := statFiles(r.files)
+	if err != nil {
+		logp.Err("Failed to check TLS files for changes: %v", err)
+		return nil
+	}
+	if !r.changed(stats) {
+		return nil
+	}
+
+	config, err := r.load()
+	if err != nil {
+		logp.Err("Failed to reload TLS files, keeping the current settings: %v", err)
+		return nil
+	}
+
+	logp.Info("TLS certificates reloaded")
+	r.stats = stats
+	return config
+}
+
+func (r *certReloader) changed(stats map[string]fileStat) bool {
+	if len(stats) != len(r.stats) {
+		return true
+	}
+	for path, st := range stats {
+		if old, exists := r.stats[path]; !exists || old.size != st.size || !old.modTime.Equal(st.modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func statFiles(files []string) (map[string]fileStat, error) {
+	stats := make(map[string]fileStat, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stats[path] = fileStat{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stats, nil
+}
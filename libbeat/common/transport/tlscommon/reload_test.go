@@ -0,0 +1,493 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package tlscommon
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// issue creates a certificate valid for 127.0.0.1.
+func (ca *testCA) issue(t *testing.T, serial int64) tls.Certificate {
+	return ca.issueFor(t, serial, "127.0.0.1")
+}
+
+// issueFor creates a certificate valid for the host name or IP address.
+func (ca *testCA) issueFor(t *testing.T, serial int64, host string) tls.Certificate {
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) crl(t *testing.T, revoked ...int64) []byte {
+	var entries []pkix.RevokedCertificate
+	for _, serial := range revoked {
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := ca.cert.CreateCRL(rand.Reader, ca.key, entries, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// ocspResponse creates an OCSP response for the certificate signed by the CA.
+func (ca *testCA) ocspResponse(t *testing.T, cert tls.Certificate, revoked bool) []byte {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := newCertID(leaf, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	single := singleResponse{
+		CertID:     id,
+		ThisUpdate: now,
+		NextUpdate: now.Add(time.Hour),
+	}
+	if revoked {
+		single.Revoked = revokedInfo{RevocationTime: now.Add(-time.Minute)}
+	} else {
+		single.Good = true
+	}
+
+	keyHash := sha1.Sum(ca.cert.RawSubjectPublicKeyInfo)
+	responderID, _ := asn1.Marshal(keyHash[:])
+	tbs, err := asn1.Marshal(responseData{
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        2,
+			IsCompound: true,
+			Bytes:      responderID,
+		},
+		ProducedAt: now,
+		Responses:  []singleResponse{single},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := crypto.SHA256.New()
+	digest.Write(tbs)
+	signature, err := ca.key.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic, err := asn1.Marshal(basicResponse{
+		TBSResponseData:    responseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := asn1.Marshal(ocspResponse{
+		Response: responseBytes{ResponseType: oidOCSPBasic, Response: basic},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// writeFile writes the file, moving the modification time forward so changes
+// are detected even on file systems with a coarse time resolution.
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Now()
+	if info, err := os.Stat(path); err == nil && !info.ModTime().Before(mtime) {
+		mtime = info.ModTime()
+	}
+	mtime = mtime.Add(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeKeyPair(t *testing.T, dir string, cert tls.Certificate) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}))
+	return certFile, keyFile
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tlscommon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// handshake connects a client to a server, returning the connection state of
+// the client and the first handshake error.
+func handshake(t *testing.T, client, server *tls.Config) (tls.ConnectionState, error) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		<-serverErr
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	if err := <-serverErr; err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return conn.ConnectionState(), nil
+}
+
+func peerSerial(st tls.ConnectionState) int64 {
+	if len(st.PeerCertificates) == 0 {
+		return 0
+	}
+	return st.PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestReloadCertificateAuthorities(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	oldCA, newCA := newTestCA(t, "old CA"), newTestCA(t, "new CA")
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, encodeCertificate(oldCA.cert))
+
+	tlsConfig, err := LoadTLSConfig(&Config{
+		CAs:    []string{caFile},
+		Reload: ReloadConfig{Enabled: true, Period: time.Nanosecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &tls.Config{Certificates: []tls.Certificate{newCA.issue(t, 2)}}
+	client := tlsConfig.BuildModuleConfig("127.0.0.1")
+
+	_, err = handshake(t, client, server)
+	assert.Error(t, err)
+
+	writeFile(t, caFile, encodeCertificate(newCA.cert))
+	st, err := handshake(t, client, server)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), peerSerial(st))
+	}
+
+	// the last valid settings are used if the file is invalid
+	writeFile(t, caFile, []byte("invalid"))
+	_, err = handshake(t, client, server)
+	assert.NoError(t, err)
+}
+
+func TestReloadDoesNotBlock(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "ca.pem")
+	writeFile(t, file, []byte("old"))
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	previous, next := &TLSConfig{}, &TLSConfig{}
+	r := newCertReloader(ReloadConfig{Period: time.Nanosecond}, []string{file}, previous,
+		func() (*TLSConfig, error) {
+			close(loading)
+			<-release
+			return next, nil
+		})
+
+	writeFile(t, file, []byte("new"))
+	done := make(chan *TLSConfig)
+	go func() { done <- r.current() }()
+	<-loading
+
+	// the current settings are returned while the files are loaded
+	time.Sleep(time.Millisecond)
+	assert.True(t, previous == r.current())
+
+	close(release)
+	assert.True(t, next == <-done)
+	assert.True(t, next == r.current())
+}
+
+func TestReloadVerifiesHostname(t *testing.T) {
+	ca := newTestCA(t, "CA")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, encodeCertificate(ca.cert))
+
+	tlsConfig, err := LoadTLSConfig(&Config{
+		CAs:    []string{caFile},
+		Reload: ReloadConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 2)}}
+	_, err = handshake(t, tlsConfig.BuildModuleConfig("example.com"), server)
+	assert.Error(t, err)
+}
+
+func TestReloadVerifiesDialedHostname(t *testing.T) {
+	ca := newTestCA(t, "CA")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, encodeCertificate(ca.cert))
+
+	tlsConfig, err := LoadTLSConfig(&Config{
+		CAs:    []string{caFile},
+		Reload: ReloadConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a host, the certificate is verified against the dialed address
+	client := tlsConfig.BuildModuleConfig("")
+	server := &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 2)}}
+	_, err = handshake(t, client, server)
+	assert.NoError(t, err)
+
+	server = &tls.Config{Certificates: []tls.Certificate{ca.issueFor(t, 3, "example.com")}}
+	_, err = handshake(t, client, server)
+	assert.Error(t, err)
+}
+
+func TestReloadServerCertificate(t *testing.T) {
+	ca := newTestCA(t, "CA")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, 2))
+	tlsConfig, err := LoadTLSServerConfig(&ServerConfig{
+		Certificate: CertificateConfig{Certificate: certFile, Key: keyFile},
+		Reload:      ReloadConfig{Enabled: true, Period: time.Nanosecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+	server := tlsConfig.BuildModuleConfig("")
+
+	st, err := handshake(t, client, server)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), peerSerial(st))
+	}
+
+	writeKeyPair(t, dir, ca.issue(t, 3))
+	st, err = handshake(t, client, server)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), peerSerial(st))
+	}
+}
+
+func TestCRL(t *testing.T) {
+	ca := newTestCA(t, "CA")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	crlFile := filepath.Join(dir, "ca.crl")
+	writeFile(t, caFile, encodeCertificate(ca.cert))
+	writeFile(t, crlFile, ca.crl(t, 3))
+
+	for _, reload := range []bool{false, true} {
+		tlsConfig, err := LoadTLSConfig(&Config{
+			CAs:    []string{caFile},
+			CRLs:   []string{crlFile},
+			Reload: ReloadConfig{Enabled: reload},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := tlsConfig.BuildModuleConfig("127.0.0.1")
+
+		_, err = handshake(t, client, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 2)}})
+		assert.NoError(t, err)
+
+		_, err = handshake(t, client, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, 3)}})
+		assert.Error(t, err)
+	}
+}
+
+func TestLoadInvalidCRL(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	crlFile := filepath.Join(dir, "ca.crl")
+	writeFile(t, crlFile, []byte("invalid"))
+
+	_, err := LoadTLSConfig(&Config{CRLs: []string{crlFile}})
+	assert.Error(t, err)
+}
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	ca := newTestCA(t, "CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	cert := ca.issue(t, 2)
+	tests := map[string]struct {
+		mode    OCSPStaplingMode
+		staple  []byte
+		invalid bool
+	}{
+		"optional without staple": {mode: OCSPStaplingOptional},
+		"required without staple": {mode: OCSPStaplingRequired, invalid: true},
+		"good":                    {mode: OCSPStaplingRequired, staple: ca.ocspResponse(t, cert, false)},
+		"revoked":                 {mode: OCSPStaplingOptional, staple: ca.ocspResponse(t, cert, true), invalid: true},
+		"not signed by issuer": {
+			mode:    OCSPStaplingOptional,
+			staple:  newTestCA(t, "CA").ocspResponse(t, cert, false),
+			invalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stapled := cert
+			stapled.OCSPStaple = test.staple
+			server := &tls.Config{Certificates: []tls.Certificate{stapled}}
+
+			tlsConfig := &TLSConfig{RootCAs: roots, OCSPStapling: test.mode}
+			st, err := handshake(t, tlsConfig.BuildModuleConfig("127.0.0.1"), server)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tlsConfig.VerifyOCSPStaple(st)
+			if test.invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
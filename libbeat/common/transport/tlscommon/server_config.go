@@ -33,11 +33,28 @@ type ServerConfig struct {
 	Certificate      CertificateConfig   `config:",inline"`
 	CurveTypes       []tlsCurveType      `config:"curve_types"`
 	ClientAuth       tlsClientAuth       `config:"client_authentication"` //`none`, `optional` or `required`
+	CRLs             []string            `config:"certificate_revocation_lists"`
+	Reload           ReloadConfig        `config:"reload"`
 }
 
 // LoadTLSServerConfig tranforms a ServerConfig into a `tls.Config` to be used directly with golang
-// network types.
+// network types. If reloading is enabled, the files are reloaded when they are
+// changed.
 func LoadTLSServerConfig(config *ServerConfig) (*TLSConfig, error) {
+	tlsConfig, err := loadTLSServerConfig(config)
+	if err != nil || tlsConfig == nil || !config.Reload.Enabled {
+		return tlsConfig, err
+	}
+
+	current := *tlsConfig
+	files := reloadFiles(&config.Certificate, config.CAs, config.CRLs)
+	tlsConfig.reloader = newCertReloader(config.Reload, files, &current, func() (*TLSConfig, error) {
+		return loadTLSServerConfig(config)
+	})
+	return tlsConfig, nil
+}
+
+func loadTLSServerConfig(config *ServerConfig) (*TLSConfig, error) {
 	if !config.IsEnabled() {
 		return nil, nil
 	}
@@ -67,6 +84,9 @@ func LoadTLSServerConfig(config *ServerConfig) (*TLSConfig, error) {
 	cas, errs := LoadCertificateAuthorities(config.CAs)
 	logFail(errs...)
 
+	crls, errs := LoadCRLs(config.CRLs)
+	logFail(errs...)
+
 	// fail, if any error occurred when loading certificate files
 	if err = fail.Err(); err != nil {
 		return nil, err
@@ -86,6 +106,7 @@ func LoadTLSServerConfig(config *ServerConfig) (*TLSConfig, error) {
 		CipherSuites:     cipherSuites,
 		CurvePreferences: curves,
 		ClientAuth:       tls.ClientAuthType(config.ClientAuth),
+		CRLs:             crls,
 	}, nil
 }
 
@@ -20,6 +20,10 @@ package tlscommon
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/elastic/beats/libbeat/logp"
 )
@@ -64,6 +68,18 @@ type TLSConfig struct {
 	// ClientAuth controls how we want to verify certificate from a client, `none`, `optional` and
 	// `required`, default to required. Do not affect TCP client.
 	ClientAuth tls.ClientAuthType
+
+	// Certificate revocation lists used to check the certificate chain of the
+	// remote host.
+	CRLs []*pkix.CertificateList
+
+	// OCSPStapling configures the verification of the OCSP response stapled
+	// by the server. See VerifyOCSPStaple.
+	OCSPStapling OCSPStaplingMode
+
+	// reloader provides the latest settings if the certificate files are
+	// reloaded when changed.
+	reloader *certReloader
 }
 
 // BuildModuleConfig takes the TLSConfig and tranform it into a `tls.Config`.
@@ -73,22 +89,150 @@ func (c *TLSConfig) BuildModuleConfig(host string) *tls.Config {
 		return &tls.Config{ServerName: host}
 	}
 
-	minVersion, maxVersion := extractMinMaxVersion(c.Versions)
-	insecure := c.Verification != VerifyFull
-	if insecure {
+	if c.Verification != VerifyFull {
 		logp.Warn("SSL/TLS verifications disabled.")
 	}
 
-	return &tls.Config{
+	if c.reloader == nil {
+		return c.buildModuleConfig(host)
+	}
+
+	// Settings depending on the certificate files are resolved for every
+	// connection, so reloaded certificates are used by new connections.
+	config := c.reloader.current().buildModuleConfig(host)
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		current := c.reloader.current()
+		if len(current.Certificates) == 0 {
+			return &tls.Certificate{}, nil
+		}
+		return &current.Certificates[0], nil
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return c.reloader.current().buildModuleConfig(host), nil
+	}
+	if !config.InsecureSkipVerify {
+		if host == "" {
+			// Without a host name, only the tls package can verify the server
+			// certificate against the address being dialed. The certificate
+			// authorities known when building the config are used.
+			logp.Warn("SSL/TLS certificate authorities are not reloaded for connections to multiple hosts.")
+			return config
+		}
+
+		// The tls package can only verify the server certificate against the
+		// certificate authorities known when creating the connection.
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.reloader.current().verifyServerCertificate(host, rawCerts)
+		}
+	}
+	return config
+}
+
+func (c *TLSConfig) buildModuleConfig(host string) *tls.Config {
+	minVersion, maxVersion := extractMinMaxVersion(c.Versions)
+	config := &tls.Config{
 		ServerName:         host,
 		MinVersion:         minVersion,
 		MaxVersion:         maxVersion,
 		Certificates:       c.Certificates,
 		RootCAs:            c.RootCAs,
 		ClientCAs:          c.ClientCAs,
-		InsecureSkipVerify: insecure,
+		InsecureSkipVerify: c.Verification != VerifyFull,
 		CipherSuites:       c.CipherSuites,
 		CurvePreferences:   c.CurvePreferences,
 		ClientAuth:         c.ClientAuth,
 	}
+
+	if crls := c.CRLs; len(crls) > 0 {
+		config.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			return checkRevoked(chains, crls)
+		}
+	}
+	return config
+}
+
+// verifyServerCertificate verifies the certificate chain presented by the
+// server and checks the chain against the CRLs.
+func (c *TLSConfig) verifyServerCertificate(host string, rawCerts [][]byte) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	chains, err := c.verifyChains(host, certs)
+	if err != nil {
+		return err
+	}
+	return checkRevoked(chains, c.CRLs)
+}
+
+func (c *TLSConfig) verifyChains(host string, certs []*x509.Certificate) ([][]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate presented by the server")
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         c.RootCAs,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	return certs[0].Verify(opts)
+}
+
+// VerifyOCSPStaple verifies the OCSP response stapled by the server during the
+// handshake. Connections are rejected if the server certificate has been
+// revoked, or if stapling is required and no valid response is stapled.
+//
+// The stapled response is not available to VerifyPeerCertificate, so the
+// tls.Config returned by BuildModuleConfig does not check it. Clients must
+// call VerifyOCSPStaple once the handshake is done, as done by the TLS dialer
+// of the outputs/transport package.
+func (c *TLSConfig) VerifyOCSPStaple(st tls.ConnectionState) error {
+	if c == nil || c.OCSPStapling == OCSPStaplingNone || c.Verification != VerifyFull {
+		return nil
+	}
+
+	required := c.OCSPStapling == OCSPStaplingRequired
+	if len(st.OCSPResponse) == 0 {
+		if required {
+			return errors.New("no OCSP response stapled by the server")
+		}
+		return nil
+	}
+
+	chains := st.VerifiedChains
+	if len(chains) == 0 && len(st.PeerCertificates) > 0 {
+		// The chain has been verified by verifyServerCertificate, build it
+		// again to find the issuer.
+		current := c
+		if c.reloader != nil {
+			current = c.reloader.current()
+		}
+		chains, _ = current.verifyChains("", st.PeerCertificates)
+	}
+	if len(chains) == 0 || len(chains[0]) < 2 {
+		return errors.New("issuer of the server certificate not available")
+	}
+
+	cert, issuer := chains[0][0], chains[0][1]
+	status, err := ParseOCSPResponse(st.OCSPResponse, cert, issuer, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid OCSP response stapled by the server: %v", err)
+	}
+
+	switch {
+	case status == OCSPRevoked:
+		return fmt.Errorf("certificate '%v' has been revoked", cert.Subject)
+	case status == OCSPUnknown && required:
+		return fmt.Errorf("OCSP status of certificate '%v' is unknown", cert.Subject)
+	}
+	return nil
 }
@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -199,6 +200,35 @@ func (m *TLSVerificationMode) Unpack(in interface{}) error {
 	return nil
 }
 
+// OCSPStaplingMode configures the verification of the OCSP response stapled by
+// the server during the handshake.
+type OCSPStaplingMode uint8
+
+// Constants of the supported OCSP stapling modes.
+const (
+	OCSPStaplingNone OCSPStaplingMode = iota
+	OCSPStaplingOptional
+	OCSPStaplingRequired
+)
+
+var ocspStaplingModes = map[string]OCSPStaplingMode{
+	"":         OCSPStaplingNone,
+	"none":     OCSPStaplingNone,
+	"optional": OCSPStaplingOptional,
+	"required": OCSPStaplingRequired,
+}
+
+// Unpack unpacks the string into contants.
+func (m *OCSPStaplingMode) Unpack(s string) error {
+	mode, found := ocspStaplingModes[s]
+	if !found {
+		return fmt.Errorf("unknown OCSP stapling mode '%v'", s)
+	}
+
+	*m = mode
+	return nil
+}
+
 func (m *tlsClientAuth) Unpack(in interface{}) error {
 	if in == nil {
 		*m = tlsClientAuthRequired
@@ -282,3 +312,10 @@ func (c *CertificateConfig) Validate() error {
 	}
 	return nil
 }
+
+// ReloadConfig configures the reloading of the certificate, key, certificate
+// authorities and CRL files when they are changed on disk.
+type ReloadConfig struct {
+	Enabled bool          `config:"enabled"`
+	Period  time.Duration `config:"period" validate:"min=0"`
+}
@@ -0,0 +1,64 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aws
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	Region          string            `config:"region"`
+	Endpoint        string            `config:"endpoint"`
+	AccessKeyID     string            `config:"access_key_id"`
+	SecretAccessKey string            `config:"secret_access_key"`
+	SessionToken    string            `config:"session_token"`
+	Prefix          string            `config:"prefix"`
+	TLS             *tlscommon.Config `config:"ssl"`
+	Timeout         time.Duration     `config:"timeout" validate:"positive"`
+	CacheTTL        time.Duration     `config:"cache_ttl" validate:"min=0"`
+}
+
+func defaultConfig() config {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	return config{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Timeout:         30 * time.Second,
+		CacheTTL:        5 * time.Minute,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Region == "" {
+		return errors.New("region is required")
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return errors.New("access_key_id and secret_access_key are required")
+	}
+	return nil
+}
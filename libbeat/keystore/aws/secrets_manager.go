@@ -0,0 +1,216 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	awscommon "github.com/elastic/beats/libbeat/common/aws"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/keystore"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	keystore.RegisterProvider("aws_secrets_manager", newKeystore)
+}
+
+var debugf = logp.MakeDebug("keystore")
+
+const service = "secretsmanager"
+
+// secretsManagerKeystore resolves keys from AWS Secrets Manager. A key is the
+// name of a secret, with the configured prefix prepended.
+type secretsManagerKeystore struct {
+	keystore.ReadOnly
+
+	config   config
+	endpoint string
+	creds    awscommon.Credentials
+	http     *http.Client
+	cache    *keystore.Cache
+}
+
+type getSecretValueResponse struct {
+	SecretString *string `json:"SecretString"`
+	SecretBinary []byte  `json:"SecretBinary"`
+}
+
+type listSecretsResponse struct {
+	SecretList []struct {
+		Name string `json:"Name"`
+	} `json:"SecretList"`
+	NextToken string `json:"NextToken"`
+}
+
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func newKeystore(cfg *common.Config) (keystore.Keystore, error) {
+	cfgwarn.Beta("The aws_secrets_manager keystore is beta.")
+
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("could not read aws_secrets_manager keystore configuration, err: %v", err)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, config.Region)
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/") + "/"
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aws_secrets_manager endpoint %v: %v", endpoint, err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tls != nil {
+		transport.TLSClientConfig = tls.BuildModuleConfig(u.Hostname())
+	}
+
+	return &secretsManagerKeystore{
+		config:   config,
+		endpoint: endpoint,
+		creds: awscommon.Credentials{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			SessionToken:    config.SessionToken,
+		},
+		http:  &http.Client{Transport: transport, Timeout: config.Timeout},
+		cache: keystore.NewCache(config.CacheTTL),
+	}, nil
+}
+
+// Retrieve returns the current value of a secret.
+func (k *secretsManagerKeystore) Retrieve(key string) (*keystore.SecureString, error) {
+	if v, ok, err := k.cache.Get(key); ok {
+		if err != nil {
+			return nil, err
+		}
+		return keystore.NewSecureString(v.([]byte)), nil
+	}
+
+	var resp getSecretValueResponse
+	err := k.call("GetSecretValue", map[string]string{"SecretId": k.config.Prefix + key}, &resp)
+	if err == keystore.ErrKeyDoesntExists {
+		k.cache.PutMissing(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	if resp.SecretString != nil {
+		value = []byte(*resp.SecretString)
+	} else {
+		value = resp.SecretBinary
+	}
+
+	k.cache.Put(key, value)
+	return keystore.NewSecureString(value), nil
+}
+
+// List returns the names of all secrets matching the configured prefix, with
+// the prefix removed.
+func (k *secretsManagerKeystore) List() ([]string, error) {
+	keys := []string{}
+	req := map[string]interface{}{"MaxResults": 100}
+	for {
+		var resp listSecretsResponse
+		if err := k.call("ListSecrets", req, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, secret := range resp.SecretList {
+			if strings.HasPrefix(secret.Name, k.config.Prefix) {
+				keys = append(keys, strings.TrimPrefix(secret.Name, k.config.Prefix))
+			}
+		}
+
+		if resp.NextToken == "" {
+			break
+		}
+		req["NextToken"] = resp.NextToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetConfig returns all secrets matching the configured prefix.
+func (k *secretsManagerKeystore) GetConfig() (*common.Config, error) {
+	return keystore.ConfigFromKeys(k)
+}
+
+// call invokes an action of the Secrets Manager JSON API.
+func (k *secretsManagerKeystore) call(action string, params, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	awscommon.Sign(req, k.creds, k.config.Region, service, awscommon.HashHex(body), time.Now())
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("aws secrets manager %s failed: %v", action, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		json.Unmarshal(raw, &apiErr)
+		if apiErr.Type == "ResourceNotFoundException" {
+			return keystore.ErrKeyDoesntExists
+		}
+		debugf("aws secrets manager %s failed with status %d: %s", action, resp.StatusCode, raw)
+		return fmt.Errorf("aws secrets manager %s failed with status %d: %s %s",
+			action, resp.StatusCode, apiErr.Type, apiErr.Message)
+	}
+
+	return json.Unmarshal(raw, result)
+}
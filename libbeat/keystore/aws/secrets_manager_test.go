@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package aws
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/keystore"
+)
+
+type fakeSecretsManager struct {
+	secrets map[string]string
+	calls   int
+}
+
+func (s *fakeSecretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.calls++
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var req map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "secretsmanager.GetSecretValue":
+		value, ok := s.secrets[req["SecretId"].(string)]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"SecretString": value})
+
+	case "secretsmanager.ListSecrets":
+		// return one secret per page to test pagination
+		names := []string{"beats/ES_PWD", "beats/ES_USER", "other"}
+		page := 0
+		if token, ok := req["NextToken"].(string); ok {
+			page, _ = strconv.Atoi(token)
+		}
+		resp := map[string]interface{}{
+			"SecretList": []map[string]string{{"Name": names[page]}},
+		}
+		if page < len(names)-1 {
+			resp["NextToken"] = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestKeystore(t *testing.T, url string) keystore.Keystore {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"endpoint":          url,
+		"region":            "eu-west-1",
+		"access_key_id":     "AKID",
+		"secret_access_key": "secret",
+		"prefix":            "beats/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := newKeystore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestRetrieve(t *testing.T) {
+	sm := &fakeSecretsManager{secrets: map[string]string{"beats/ES_PWD": "changeme"}}
+	server := httptest.NewServer(sm)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL)
+
+	secret, err := k.Retrieve("ES_PWD")
+	if assert.NoError(t, err) {
+		v, _ := secret.Get()
+		assert.Equal(t, "changeme", string(v))
+	}
+
+	// cached
+	_, err = k.Retrieve("ES_PWD")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sm.calls)
+
+	_, err = k.Retrieve("unknown")
+	assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+}
+
+func TestList(t *testing.T) {
+	sm := &fakeSecretsManager{}
+	server := httptest.NewServer(sm)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL)
+	keys, err := k.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ES_PWD", "ES_USER"}, keys)
+	assert.Equal(t, 3, sm.calls)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"missing region": {
+			"region":            "",
+			"access_key_id":     "AKID",
+			"secret_access_key": "secret",
+		},
+		"missing credentials": {
+			"region":            "eu-west-1",
+			"access_key_id":     "",
+			"secret_access_key": "",
+		},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig()
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
+
+// writeCA writes the certificate of the TLS server to a file to be used as
+// certificate authority.
+func writeCA(t *testing.T, server *httptest.Server) string {
+	ca, err := ioutil.TempFile("", "keystore-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ca.Close()
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return ca.Name()
+}
+
+func TestRetrieveWithTLS(t *testing.T) {
+	sm := &fakeSecretsManager{secrets: map[string]string{"beats/ES_PWD": "changeme"}}
+	server := httptest.NewTLSServer(sm)
+	defer server.Close()
+
+	ca := writeCA(t, server)
+	defer os.Remove(ca)
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"endpoint":                    server.URL,
+		"region":                      "eu-west-1",
+		"access_key_id":               "AKID",
+		"secret_access_key":           "secret",
+		"prefix":                      "beats/",
+		"ssl.certificate_authorities": []string{ca},
+		"ssl.verification_mode":       "full",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := newKeystore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server certificate is verified against the host of the endpoint
+	secret, err := k.Retrieve("ES_PWD")
+	if assert.NoError(t, err) {
+		v, _ := secret.Get()
+		assert.Equal(t, "changeme", string(v))
+	}
+}
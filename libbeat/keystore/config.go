@@ -19,9 +19,13 @@ package keystore
 
 // Config Define keystore configurable options
 type Config struct {
+	// Type of the keystore provider, the settings of remote providers are read
+	// from the namespace of the same name.
+	Type string `config:"type"`
 	Path string `config:"path"`
 }
 
 var defaultConfig = Config{
+	Type: "file",
 	Path: "",
 }
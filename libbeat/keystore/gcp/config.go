@@ -0,0 +1,46 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"os"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	ProjectID       string            `config:"project_id" validate:"required"`
+	CredentialsFile string            `config:"credentials_file"`
+	Version         string            `config:"version"`
+	Endpoint        string            `config:"endpoint"`
+	Prefix          string            `config:"prefix"`
+	TLS             *tlscommon.Config `config:"ssl"`
+	Timeout         time.Duration     `config:"timeout" validate:"positive"`
+	CacheTTL        time.Duration     `config:"cache_ttl" validate:"min=0"`
+}
+
+func defaultConfig() config {
+	return config{
+		CredentialsFile: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		Version:         "latest",
+		Endpoint:        "https://secretmanager.googleapis.com",
+		Timeout:         30 * time.Second,
+		CacheTTL:        5 * time.Minute,
+	}
+}
@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/keystore"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	keystore.RegisterProvider("gcp_secret_manager", newKeystore)
+}
+
+var debugf = logp.MakeDebug("keystore")
+
+// secretManagerKeystore resolves keys from GCP Secret Manager. A key is the
+// id of a secret in the configured project, with the configured prefix
+// prepended.
+type secretManagerKeystore struct {
+	keystore.ReadOnly
+
+	config config
+	url    string
+	http   *http.Client
+	tokens *tokenSource
+	cache  *keystore.Cache
+}
+
+type accessResponse struct {
+	Payload struct {
+		Data []byte `json:"data"`
+	} `json:"payload"`
+}
+
+type listResponse struct {
+	Secrets []struct {
+		Name string `json:"name"`
+	} `json:"secrets"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func newKeystore(cfg *common.Config) (keystore.Keystore, error) {
+	cfgwarn.Beta("The gcp_secret_manager keystore is beta.")
+
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("could not read gcp_secret_manager keystore configuration, err: %v", err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gcp_secret_manager endpoint %v: %v", endpoint, err)
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tls != nil {
+		transport.TLSClientConfig = tls.BuildModuleConfig(u.Hostname())
+	}
+	client := &http.Client{Transport: transport, Timeout: config.Timeout}
+
+	tokens, err := newTokenSource(client, config.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretManagerKeystore{
+		config: config,
+		url:    fmt.Sprintf("%s/v1/projects/%s/secrets", endpoint, url.PathEscape(config.ProjectID)),
+		http:   client,
+		tokens: tokens,
+		cache:  keystore.NewCache(config.CacheTTL),
+	}, nil
+}
+
+// Retrieve returns the payload of the configured version of a secret.
+func (k *secretManagerKeystore) Retrieve(key string) (*keystore.SecureString, error) {
+	if v, ok, err := k.cache.Get(key); ok {
+		if err != nil {
+			return nil, err
+		}
+		return keystore.NewSecureString(v.([]byte)), nil
+	}
+
+	path := fmt.Sprintf("/%s/versions/%s:access",
+		url.PathEscape(k.config.Prefix+key), url.PathEscape(k.config.Version))
+
+	var resp accessResponse
+	status, err := k.get(path, &resp)
+	if status == http.StatusNotFound {
+		k.cache.PutMissing(key)
+		return nil, keystore.ErrKeyDoesntExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k.cache.Put(key, resp.Payload.Data)
+	return keystore.NewSecureString(resp.Payload.Data), nil
+}
+
+// List returns the ids of all secrets in the project matching the configured
+// prefix, with the prefix removed.
+func (k *secretManagerKeystore) List() ([]string, error) {
+	keys := []string{}
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var resp listResponse
+		if _, err := k.get("?"+query.Encode(), &resp); err != nil {
+			return nil, err
+		}
+
+		for _, secret := range resp.Secrets {
+			id := secret.Name[strings.LastIndex(secret.Name, "/")+1:]
+			if strings.HasPrefix(id, k.config.Prefix) {
+				keys = append(keys, strings.TrimPrefix(id, k.config.Prefix))
+			}
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetConfig returns all secrets matching the configured prefix.
+func (k *secretManagerKeystore) GetConfig() (*common.Config, error) {
+	return keystore.ConfigFromKeys(k)
+}
+
+func (k *secretManagerKeystore) get(path string, result interface{}) (int, error) {
+	token, err := k.tokens.Token()
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("GET", k.url+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("gcp secret manager request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		debugf("gcp secret manager request %s failed with status %d: %s", path, resp.StatusCode, raw)
+		return resp.StatusCode, fmt.Errorf("gcp secret manager request failed with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, json.Unmarshal(raw, result)
+}
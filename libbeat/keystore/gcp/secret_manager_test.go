@@ -0,0 +1,258 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/keystore"
+)
+
+type fakeSecretManager struct {
+	key      *rsa.PublicKey
+	secrets  map[string]string
+	tokens   int
+	accesses int
+}
+
+func (s *fakeSecretManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/token":
+		r.ParseForm()
+		if r.Form.Get("grant_type") != jwtBearerGrantType || !s.verify(r.Form.Get("assertion")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.tokens++
+		w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		return
+
+	case r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token":
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.tokens++
+		w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/v1/projects/project/secrets"
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	if path == "" {
+		secrets := []map[string]string{}
+		for name := range s.secrets {
+			secrets = append(secrets, map[string]string{"name": "projects/123/secrets/" + name})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets})
+		return
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/versions/latest:access")
+	value, ok := s.secrets[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.accesses++
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"payload": map[string]interface{}{"data": []byte(value)},
+	})
+}
+
+func (s *fakeSecretManager) verify(assertion string) bool {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return rsa.VerifyPKCS1v15(s.key, crypto.SHA256, digest[:], signature) == nil
+}
+
+func writeCredentials(t *testing.T, dir, tokenURI string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "beats@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	if err := ioutil.WriteFile(filepath.Join(dir, "credentials.json"), creds, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestKeystore(t *testing.T, settings map[string]interface{}) keystore.Keystore {
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := newKeystore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestRetrieveWithServiceAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sm := &fakeSecretManager{secrets: map[string]string{
+		"beats-ES_PWD":  "changeme",
+		"beats-ES_USER": "elastic",
+		"other":         "secret",
+	}}
+	server := httptest.NewServer(sm)
+	defer server.Close()
+
+	sm.key = &writeCredentials(t, dir, server.URL+"/token").PublicKey
+
+	k := newTestKeystore(t, map[string]interface{}{
+		"project_id":       "project",
+		"credentials_file": filepath.Join(dir, "credentials.json"),
+		"endpoint":         server.URL,
+		"prefix":           "beats-",
+	})
+
+	secret, err := k.Retrieve("ES_PWD")
+	if assert.NoError(t, err) {
+		v, _ := secret.Get()
+		assert.Equal(t, "changeme", string(v))
+	}
+
+	_, err = k.Retrieve("ES_PWD")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sm.accesses)
+
+	_, err = k.Retrieve("unknown")
+	assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+
+	keys, err := k.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ES_PWD", "ES_USER"}, keys)
+
+	// the token is requested once and reused
+	assert.Equal(t, 1, sm.tokens)
+}
+
+func TestRetrieveWithMetadataServer(t *testing.T) {
+	sm := &fakeSecretManager{secrets: map[string]string{"ES_PWD": "changeme"}}
+	server := httptest.NewServer(sm)
+	defer server.Close()
+
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	defer os.Unsetenv("GCE_METADATA_HOST")
+
+	k := newTestKeystore(t, map[string]interface{}{
+		"project_id":       "project",
+		"credentials_file": "",
+		"endpoint":         server.URL,
+	})
+
+	secret, err := k.Retrieve("ES_PWD")
+	if assert.NoError(t, err) {
+		v, _ := secret.Get()
+		assert.Equal(t, "changeme", string(v))
+	}
+	assert.Equal(t, 1, sm.tokens)
+}
+
+// writeCA writes the certificate of the TLS server to a file to be used as
+// certificate authority.
+func writeCA(t *testing.T, server *httptest.Server) string {
+	ca, err := ioutil.TempFile("", "keystore-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ca.Close()
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return ca.Name()
+}
+
+func TestRetrieveWithTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sm := &fakeSecretManager{secrets: map[string]string{"ES_PWD": "changeme"}}
+	server := httptest.NewTLSServer(sm)
+	defer server.Close()
+
+	sm.key = &writeCredentials(t, dir, server.URL+"/token").PublicKey
+	ca := writeCA(t, server)
+	defer os.Remove(ca)
+
+	// the server certificate is verified against the host of the endpoint
+	k := newTestKeystore(t, map[string]interface{}{
+		"project_id":                  "project",
+		"credentials_file":            filepath.Join(dir, "credentials.json"),
+		"endpoint":                    server.URL,
+		"ssl.certificate_authorities": []string{ca},
+		"ssl.verification_mode":       "full",
+	})
+
+	secret, err := k.Retrieve("ES_PWD")
+	if assert.NoError(t, err) {
+		v, _ := secret.Get()
+		assert.Equal(t, "changeme", string(v))
+	}
+}
@@ -32,6 +32,9 @@ var (
 
 	// ErrKeyDoesntExists is returned when the key doesn't exist in the store
 	ErrKeyDoesntExists = errors.New("cannot retrieve the key")
+
+	// ErrReadOnly is returned when trying to modify a keystore managed outside of the beat.
+	ErrReadOnly = errors.New("the keystore is read only, secrets must be managed in the secret store")
 )
 
 // Keystore implement a way to securely saves and retrieves secrets to be used in the configuration
@@ -77,13 +80,31 @@ func Factory(cfg *common.Config, defaultPath string) (Keystore, error) {
 		return nil, fmt.Errorf("could not read keystore configuration, err: %v", err)
 	}
 
-	if config.Path == "" {
-		config.Path = defaultPath
+	if config.Type == "file" {
+		if config.Path == "" {
+			config.Path = defaultPath
+		}
+
+		logp.Debug("keystore", "Loading file keystore from %s", config.Path)
+		keystore, err := NewFileKeystore(config.Path)
+		return keystore, err
+	}
+
+	factory, err := findProvider(config.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	providerCfg := common.NewConfig()
+	if cfg.HasField(config.Type) {
+		providerCfg, err = cfg.Child(config.Type, -1)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s keystore configuration, err: %v", config.Type, err)
+		}
 	}
 
-	logp.Debug("keystore", "Loading file keystore from %s", config.Path)
-	keystore, err := NewFileKeystore(config.Path)
-	return keystore, err
+	logp.Debug("keystore", "Loading %s keystore", config.Type)
+	return factory(providerCfg)
 }
 
 // ResolverFromConfig create a resolver from a configuration.
@@ -23,6 +23,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/elastic/beats/libbeat/common"
 	ucfg "github.com/elastic/go-ucfg"
 )
 
@@ -48,3 +49,64 @@ func TestResolverWhenTheKeyExist(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, v, "secret")
 }
+
+type testProviderKeystore struct {
+	ReadOnly
+	config *common.Config
+}
+
+func (k *testProviderKeystore) Retrieve(key string) (*SecureString, error) {
+	v, err := k.config.String(key, -1)
+	if err != nil {
+		return nil, ErrKeyDoesntExists
+	}
+	return NewSecureString([]byte(v)), nil
+}
+
+func (k *testProviderKeystore) List() ([]string, error) {
+	return k.config.GetFields(), nil
+}
+
+func (k *testProviderKeystore) GetConfig() (*common.Config, error) {
+	return ConfigFromKeys(k)
+}
+
+func TestFactoryWithProvider(t *testing.T) {
+	RegisterProvider("test", func(cfg *common.Config) (Keystore, error) {
+		return &testProviderKeystore{config: cfg}, nil
+	})
+	defer delete(providers, "test")
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"type":          "test",
+		"test.password": "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keystore, err := Factory(cfg, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	resolver := ResolverWrap(keystore)
+	v, err := resolver("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	_, err = resolver("donotexist")
+	assert.Equal(t, ucfg.ErrMissing, err)
+
+	assert.Equal(t, ErrReadOnly, keystore.Store("key", []byte("value")))
+}
+
+func TestFactoryWithUnknownProvider(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"type": "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Factory(cfg, "")
+	assert.Error(t, err)
+}
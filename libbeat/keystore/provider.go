@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package keystore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// ProviderFactory creates a keystore backed by a remote secret store from the
+// settings in the namespace of the provider.
+type ProviderFactory func(cfg *common.Config) (Keystore, error)
+
+var providers = map[string]ProviderFactory{}
+
+// RegisterProvider registers a new keystore provider. The provider is selected by
+// setting `keystore.type` to its name.
+func RegisterProvider(name string, factory ProviderFactory) {
+	if name == "file" {
+		panic("keystore provider file is reserved for the file keystore")
+	}
+	if _, exists := providers[name]; exists {
+		panic(fmt.Sprintf("keystore provider %s is already registered", name))
+	}
+	providers[name] = factory
+}
+
+func findProvider(name string) (ProviderFactory, error) {
+	factory, exists := providers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown keystore type: %s", name)
+	}
+	return factory, nil
+}
+
+// ReadOnly implements the write operations of the Keystore interface for
+// keystores whose secrets are managed in a remote secret store. All write
+// operations fail with ErrReadOnly.
+type ReadOnly struct{}
+
+// Store fails with ErrReadOnly.
+func (ReadOnly) Store(key string, secret []byte) error { return ErrReadOnly }
+
+// Delete fails with ErrReadOnly.
+func (ReadOnly) Delete(key string) error { return ErrReadOnly }
+
+// Create fails with ErrReadOnly.
+func (ReadOnly) Create(override bool) error { return ErrReadOnly }
+
+// Save fails with ErrReadOnly.
+func (ReadOnly) Save() error { return ErrReadOnly }
+
+// IsPersisted always returns true, the secrets are persisted by the remote store.
+func (ReadOnly) IsPersisted() bool { return true }
+
+// ConfigFromKeys builds the key / secret configuration of a keystore from all
+// keys returned by List.
+func ConfigFromKeys(k Keystore) (*common.Config, error) {
+	keys, err := k.List()
+	if err != nil {
+		return nil, err
+	}
+
+	configHash := make(map[string]interface{})
+	for _, key := range keys {
+		secret, err := k.Retrieve(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := secret.Get()
+		if err != nil {
+			return nil, err
+		}
+		configHash[key] = string(v)
+	}
+
+	return common.NewConfigFrom(configHash)
+}
+
+// Cache keeps secrets retrieved from a remote store for a limited time, so
+// resolving the configuration does not query the store for every reference.
+// Keys missing in the store are cached as well.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	missing bool
+	expires time.Time
+}
+
+// NewCache creates a new cache. Entries are kept for ttl, a ttl of 0 disables caching.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+// Get returns the cached value of key, if it has not expired yet. If the key
+// is cached as missing, ok is true and ErrKeyDoesntExists is returned.
+func (c *Cache) Get(key string) (value interface{}, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	if entry.missing {
+		return nil, true, ErrKeyDoesntExists
+	}
+	return entry.value, true, nil
+}
+
+// Put adds a value to the cache.
+func (c *Cache) Put(key string, value interface{}) {
+	c.put(key, cacheEntry{value: value})
+}
+
+// PutMissing records in the cache that key does not exist in the store.
+func (c *Cache) PutMissing(key string) {
+	c.put(key, cacheEntry{missing: true})
+}
+
+func (c *Cache) put(key string, entry cacheEntry) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.expires = time.Now().Add(c.ttl)
+	c.entries[key] = entry
+}
@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	Address   string            `config:"address"`
+	Namespace string            `config:"namespace"`
+	Mount     string            `config:"mount"`
+	Path      string            `config:"path" validate:"required"`
+	Token     string            `config:"token"`
+	AppRole   *appRoleConfig    `config:"auth.approle"`
+	TLS       *tlscommon.Config `config:"ssl"`
+	Timeout   time.Duration     `config:"timeout" validate:"positive"`
+	CacheTTL  time.Duration     `config:"cache_ttl" validate:"min=0"`
+}
+
+type appRoleConfig struct {
+	Mount        string `config:"mount"`
+	RoleID       string `config:"role_id" validate:"required"`
+	SecretID     string `config:"secret_id"`
+	SecretIDFile string `config:"secret_id_file"`
+}
+
+func defaultConfig() config {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		address = "http://127.0.0.1:8200"
+	}
+
+	return config{
+		Address:   address,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Mount:     "secret",
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Timeout:   30 * time.Second,
+		CacheTTL:  5 * time.Minute,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.AppRole == nil && c.Token == "" {
+		return errors.New("a token or the approle authentication must be configured")
+	}
+	return nil
+}
+
+func (c *appRoleConfig) Validate() error {
+	if c.SecretID != "" && c.SecretIDFile != "" {
+		return errors.New("secret_id and secret_id_file cannot be used at the same time")
+	}
+	return nil
+}
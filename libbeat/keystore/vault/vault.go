@@ -0,0 +1,407 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/keystore"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+func init() {
+	keystore.RegisterProvider("vault", newKeystore)
+}
+
+var debugf = logp.MakeDebug("keystore")
+
+// vaultKeystore resolves keys from a secret stored in the KV version 2
+// secrets engine of HashiCorp Vault. A key is a field of the secret at the
+// configured path, or `subpath/field` for a field of a secret below it.
+type vaultKeystore struct {
+	keystore.ReadOnly
+
+	config config
+	url    string
+	http   *http.Client
+	cache  *keystore.Cache // secret documents by path
+
+	// known holds the fields of each secret read successfully, it is
+	// protected by knownMu
+	knownMu sync.Mutex
+	known   map[string]map[string]bool
+
+	// mu protects the token and its lease
+	mu        sync.Mutex
+	token     string
+	renewable bool
+	lease     time.Duration
+	expires   time.Time // zero if the token does not expire
+}
+
+type secretResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+type tokenLookupResponse struct {
+	Data struct {
+		TTL       int  `json:"ttl"`
+		Renewable bool `json:"renewable"`
+	} `json:"data"`
+}
+
+func newKeystore(cfg *common.Config) (keystore.Keystore, error) {
+	cfgwarn.Beta("The vault keystore is beta.")
+
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("could not read vault keystore configuration, err: %v", err)
+	}
+	if config.AppRole != nil {
+		// the approle login takes precedence over a token from the environment
+		config.Token = ""
+		if config.AppRole.Mount == "" {
+			config.AppRole.Mount = "approle"
+		}
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	address := strings.TrimSuffix(config.Address, "/")
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault address %v: %v", address, err)
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tls != nil {
+		transport.TLSClientConfig = tls.BuildModuleConfig(u.Hostname())
+	}
+
+	k := &vaultKeystore{
+		config: config,
+		url:    address + "/v1/",
+		http:   &http.Client{Transport: transport, Timeout: config.Timeout},
+		cache:  keystore.NewCache(config.CacheTTL),
+		known:  map[string]map[string]bool{},
+		token:  config.Token,
+	}
+
+	if k.token != "" {
+		if err := k.lookupToken(); err != nil {
+			logp.Warn("Failed to lookup the lease of the vault token: %v", err)
+		}
+	}
+	return k, nil
+}
+
+// Retrieve returns the value of the field of a secret. If vault cannot be
+// reached, only keys known to be vault secrets fail, other keys are reported
+// as missing so they can be resolved from other sources.
+func (k *vaultKeystore) Retrieve(key string) (*keystore.SecureString, error) {
+	path, field := k.config.Path, key
+	if idx := strings.LastIndex(key, "/"); idx >= 0 {
+		path, field = path+"/"+key[:idx], key[idx+1:]
+	}
+
+	data, err := k.secret(path)
+	if err != nil && err != keystore.ErrKeyDoesntExists && !k.isSecret(key, path, field) {
+		logp.Warn("Failed to read vault secret, resolving %s from other sources: %v", key, err)
+		return nil, keystore.ErrKeyDoesntExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, exists := data[field]
+	if !exists {
+		return nil, keystore.ErrKeyDoesntExists
+	}
+
+	if s, ok := raw.(string); ok {
+		return keystore.NewSecureString([]byte(s)), nil
+	}
+	value, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.NewSecureString(value), nil
+}
+
+// List returns the fields of the secret at the configured path.
+func (k *vaultKeystore) List() ([]string, error) {
+	data, err := k.secret(k.config.Path)
+	if err == keystore.ErrKeyDoesntExists {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetConfig returns the fields of the secret at the configured path.
+func (k *vaultKeystore) GetConfig() (*common.Config, error) {
+	return keystore.ConfigFromKeys(k)
+}
+
+// secret returns the fields of the secret at path. Secrets and missing paths
+// are cached, so all keys of a secret are resolved with a single request.
+func (k *vaultKeystore) secret(path string) (map[string]interface{}, error) {
+	if v, ok, err := k.cache.Get(path); ok {
+		if err != nil {
+			return nil, err
+		}
+		return v.(map[string]interface{}), nil
+	}
+
+	data, err := k.readSecret(path)
+	if err == keystore.ErrKeyDoesntExists {
+		k.cache.PutMissing(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(data))
+	for field := range data {
+		fields[field] = true
+	}
+	k.knownMu.Lock()
+	k.known[path] = fields
+	k.knownMu.Unlock()
+
+	k.cache.Put(path, data)
+	return data, nil
+}
+
+// isSecret returns true if key refers to a vault secret, because it selects
+// a secret below the configured path or the field was seen in a previous read.
+func (k *vaultKeystore) isSecret(key, path, field string) bool {
+	if strings.Contains(key, "/") {
+		return true
+	}
+
+	k.knownMu.Lock()
+	defer k.knownMu.Unlock()
+	return k.known[path][field]
+}
+
+func (k *vaultKeystore) readSecret(path string) (map[string]interface{}, error) {
+	var resp secretResponse
+	status, err := k.request("GET", k.config.Mount+"/data/"+strings.Trim(path, "/"), nil, &resp)
+	if status == http.StatusNotFound {
+		return nil, keystore.ErrKeyDoesntExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret %s: %v", path, err)
+	}
+	return resp.Data.Data, nil
+}
+
+// request sends an authenticated request to vault. If the token is rejected
+// and approle authentication is configured, a new token is requested and the
+// request is retried once.
+func (k *vaultKeystore) request(method, path string, body, result interface{}) (int, error) {
+	token, err := k.getToken()
+	if err != nil {
+		return 0, err
+	}
+
+	status, err := k.send(method, path, token, body, result)
+	if status == http.StatusForbidden && k.config.AppRole != nil {
+		debugf("vault token rejected, logging in again")
+		if token, err = k.login(); err != nil {
+			return 0, err
+		}
+		status, err = k.send(method, path, token, body, result)
+	}
+	return status, err
+}
+
+func (k *vaultKeystore) send(method, path, token string, body, result interface{}) (int, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, k.url+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if k.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", k.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, raw)
+	}
+	if result != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, result); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// getToken returns the token to authenticate requests with. Tokens are renewed
+// once two thirds of their lease have passed. If the token cannot be renewed
+// a new one is requested when using approle authentication.
+func (k *vaultKeystore) getToken() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.token == "" {
+		return k.doLogin()
+	}
+	if k.expires.IsZero() || time.Until(k.expires) > k.lease/3 {
+		return k.token, nil
+	}
+
+	if k.renewable {
+		err := k.doRenew()
+		if err == nil {
+			return k.token, nil
+		}
+		logp.Warn("Failed to renew the vault token: %v", err)
+	}
+	if k.config.AppRole != nil {
+		return k.doLogin()
+	}
+	return k.token, nil
+}
+
+func (k *vaultKeystore) login() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.doLogin()
+}
+
+func (k *vaultKeystore) doLogin() (string, error) {
+	approle := k.config.AppRole
+	if approle == nil {
+		return "", fmt.Errorf("no vault token available")
+	}
+
+	secretID := approle.SecretID
+	if approle.SecretIDFile != "" {
+		raw, err := ioutil.ReadFile(approle.SecretIDFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the approle secret_id_file: %v", err)
+		}
+		secretID = strings.TrimSpace(string(raw))
+	}
+
+	body := map[string]string{"role_id": approle.RoleID}
+	if secretID != "" {
+		body["secret_id"] = secretID
+	}
+
+	var resp authResponse
+	if _, err := k.send("POST", "auth/"+approle.Mount+"/login", "", body, &resp); err != nil {
+		return "", fmt.Errorf("vault approle login failed: %v", err)
+	}
+
+	debugf("vault approle login succeeded, token lease is %vs", resp.Auth.LeaseDuration)
+	k.token = resp.Auth.ClientToken
+	k.setLease(resp.Auth.LeaseDuration, resp.Auth.Renewable)
+	return k.token, nil
+}
+
+func (k *vaultKeystore) doRenew() error {
+	var resp authResponse
+	if _, err := k.send("POST", "auth/token/renew-self", k.token, map[string]string{}, &resp); err != nil {
+		return err
+	}
+
+	debugf("vault token renewed, lease is %vs", resp.Auth.LeaseDuration)
+	k.setLease(resp.Auth.LeaseDuration, resp.Auth.Renewable)
+	return nil
+}
+
+// lookupToken reads the lease of a configured token, so it can be renewed.
+func (k *vaultKeystore) lookupToken() error {
+	var resp tokenLookupResponse
+	if _, err := k.send("GET", "auth/token/lookup-self", k.token, nil, &resp); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.setLease(resp.Data.TTL, resp.Data.Renewable)
+	return nil
+}
+
+func (k *vaultKeystore) setLease(seconds int, renewable bool) {
+	k.renewable = renewable
+	k.lease = time.Duration(seconds) * time.Second
+	if seconds > 0 {
+		k.expires = time.Now().Add(k.lease)
+	} else {
+		k.expires = time.Time{}
+	}
+}
@@ -0,0 +1,311 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package vault
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/keystore"
+	ucfg "github.com/elastic/go-ucfg"
+)
+
+type fakeVault struct {
+	sync.Mutex
+	tokens  map[string]bool
+	secrets map[string]map[string]interface{}
+	logins  int
+	renews  int
+	reads   int
+}
+
+func newFakeVault() *fakeVault {
+	return &fakeVault{
+		tokens: map[string]bool{"root": true},
+		secrets: map[string]map[string]interface{}{
+			"beats": {
+				"ES_PWD":  "changeme",
+				"ES_PORT": 9200,
+			},
+			"beats/db": {
+				"password": "dbsecret",
+			},
+		},
+	}
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v.logins++
+		v.tokens["approle-token"] = true
+		writeAuth(w, "approle-token", 3600)
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	if !v.tokens[token] {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/auth/token/lookup-self":
+		w.Write([]byte(`{"data":{"ttl":0,"renewable":false}}`))
+	case "/v1/auth/token/renew-self":
+		v.renews++
+		writeAuth(w, token, 3600)
+	default:
+		v.reads++
+		path := r.URL.Path[len("/v1/secret/data/"):]
+		secret, ok := v.secrets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": secret},
+		})
+	}
+}
+
+func writeAuth(w http.ResponseWriter, token string, lease int) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   token,
+			"lease_duration": lease,
+			"renewable":      true,
+		},
+	})
+}
+
+func newTestKeystore(t *testing.T, url string, settings map[string]interface{}) *vaultKeystore {
+	settings["address"] = url
+	settings["path"] = "beats"
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := newKeystore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k.(*vaultKeystore)
+}
+
+func retrieve(t *testing.T, k keystore.Keystore, key string) string {
+	secret, err := k.Retrieve(key)
+	if !assert.NoError(t, err, key) {
+		return ""
+	}
+	v, _ := secret.Get()
+	return string(v)
+}
+
+func TestRetrieveWithToken(t *testing.T) {
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL, map[string]interface{}{"token": "root"})
+
+	assert.Equal(t, "changeme", retrieve(t, k, "ES_PWD"))
+	assert.Equal(t, "9200", retrieve(t, k, "ES_PORT"))
+	assert.Equal(t, "dbsecret", retrieve(t, k, "db/password"))
+
+	_, err := k.Retrieve("unknown")
+	assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+	_, err = k.Retrieve("unknown/password")
+	assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+
+	keys, err := k.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ES_PORT", "ES_PWD"}, keys)
+}
+
+func TestRetrieveIsCached(t *testing.T) {
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL, map[string]interface{}{"token": "root"})
+	retrieve(t, k, "ES_PWD")
+	retrieve(t, k, "ES_PWD")
+	retrieve(t, k, "ES_PORT")
+	assert.Equal(t, 1, vault.reads)
+
+	// missing secrets are cached as well
+	for i := 0; i < 2; i++ {
+		_, err := k.Retrieve("unknown/password")
+		assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+	}
+	assert.Equal(t, 2, vault.reads)
+
+	k = newTestKeystore(t, server.URL, map[string]interface{}{"token": "root", "cache_ttl": 0})
+	retrieve(t, k, "ES_PWD")
+	retrieve(t, k, "ES_PWD")
+	assert.Equal(t, 4, vault.reads)
+}
+
+func TestRetrieveVaultUnavailable(t *testing.T) {
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+
+	k := newTestKeystore(t, server.URL, map[string]interface{}{"token": "root", "cache_ttl": 0})
+	retrieve(t, k, "ES_PWD")
+	server.Close()
+
+	// keys known to be vault secrets fail
+	_, err := k.Retrieve("ES_PWD")
+	assert.Error(t, err)
+	assert.NotEqual(t, keystore.ErrKeyDoesntExists, err)
+	_, err = k.Retrieve("db/password")
+	assert.Error(t, err)
+	assert.NotEqual(t, keystore.ErrKeyDoesntExists, err)
+
+	// other keys are left to the other resolvers
+	_, err = k.Retrieve("HOSTNAME")
+	assert.Equal(t, keystore.ErrKeyDoesntExists, err)
+	_, err = keystore.ResolverWrap(k)("HOSTNAME")
+	assert.Equal(t, ucfg.ErrMissing, err)
+}
+
+func TestAppRoleLoginAndRenewal(t *testing.T) {
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL, map[string]interface{}{
+		"cache_ttl": 0,
+		"auth.approle": map[string]interface{}{
+			"role_id":   "role",
+			"secret_id": "secret",
+		},
+	})
+
+	assert.Equal(t, "changeme", retrieve(t, k, "ES_PWD"))
+	assert.Equal(t, 1, vault.logins)
+	assert.Equal(t, 0, vault.renews)
+
+	// token close to expiry must be renewed
+	k.expires = time.Now().Add(time.Minute)
+	assert.Equal(t, "changeme", retrieve(t, k, "ES_PWD"))
+	assert.Equal(t, 1, vault.renews)
+	assert.True(t, time.Until(k.expires) > 30*time.Minute)
+
+	// revoked token triggers a new login
+	vault.Lock()
+	delete(vault.tokens, "approle-token")
+	vault.Unlock()
+	assert.Equal(t, "changeme", retrieve(t, k, "ES_PWD"))
+	assert.Equal(t, 2, vault.logins)
+}
+
+func TestReadOnly(t *testing.T) {
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	k := newTestKeystore(t, server.URL, map[string]interface{}{"token": "root"})
+	assert.Equal(t, keystore.ErrReadOnly, k.Store("key", []byte("value")))
+	assert.Equal(t, keystore.ErrReadOnly, k.Delete("ES_PWD"))
+	assert.Equal(t, keystore.ErrReadOnly, k.Save())
+	assert.True(t, k.IsPersisted())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"missing path": {
+			"token": "root",
+		},
+		"missing authentication": {
+			"path":  "beats",
+			"token": "",
+		},
+		"secret_id and secret_id_file": {
+			"path": "beats",
+			"auth.approle": map[string]interface{}{
+				"role_id":        "role",
+				"secret_id":      "secret",
+				"secret_id_file": "/tmp/secret",
+			},
+		},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig()
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
+
+// writeCA writes the certificate of the TLS server to a file to be used as
+// certificate authority.
+func writeCA(t *testing.T, server *httptest.Server) string {
+	ca, err := ioutil.TempFile("", "keystore-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ca.Close()
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return ca.Name()
+}
+
+func TestRetrieveWithTLS(t *testing.T) {
+	server := httptest.NewTLSServer(newFakeVault())
+	defer server.Close()
+
+	ca := writeCA(t, server)
+	defer os.Remove(ca)
+
+	// the server certificate is verified against the host of the address
+	k := newTestKeystore(t, server.URL, map[string]interface{}{
+		"token":                       "root",
+		"ssl.certificate_authorities": []string{ca},
+		"ssl.verification_mode":       "full",
+	})
+	assert.Equal(t, "changeme", retrieve(t, k, "ES_PWD"))
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+// Encoder serializes events to Avro binary encoded records. If a schema
+// registry is configured, records are prefixed with the schema ID using the
+// Confluent wire format.
+type Encoder struct {
+	schema     *schema
+	schemaJSON string
+	registry   *registry
+	strategy   subjectStrategy
+	buf        []byte
+}
+
+func init() {
+	codec.RegisterType("avro", func(_ beat.Info, cfg *common.Config) (codec.Codec, error) {
+		cfgwarn.Beta("The avro codec is beta.")
+
+		if cfg == nil {
+			return nil, errors.New("empty avro codec configuration")
+		}
+
+		config := config{}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		schemaJSON := []byte(config.Schema)
+		if config.SchemaFile != "" {
+			var err error
+			if schemaJSON, err = ioutil.ReadFile(config.SchemaFile); err != nil {
+				return nil, err
+			}
+		}
+
+		enc, err := New(schemaJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.SchemaRegistry != nil {
+			registryConfig := defaultRegistryConfig()
+			if err := config.SchemaRegistry.Unpack(&registryConfig); err != nil {
+				return nil, err
+			}
+			if enc.registry, err = newRegistry(registryConfig); err != nil {
+				return nil, err
+			}
+			enc.strategy = registryConfig.Strategy
+		}
+		return enc, nil
+	})
+}
+
+// New creates an Avro Encoder for the schema. The top-level schema must be a
+// record. Record fields are read from the event field with the same name, or
+// the field configured in the custom `field` attribute.
+func New(schemaJSON []byte) (*Encoder, error) {
+	s, err := parseSchema(schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{schema: s, schemaJSON: string(schemaJSON)}, nil
+}
+
+// Encode serializes the event. The topic used for resolving the schema
+// registry subject is read from `@metadata.topic`, falling back to the index.
+func (e *Encoder) Encode(index string, event *beat.Event) ([]byte, error) {
+	e.buf = e.buf[:0]
+
+	if e.registry != nil {
+		topic := index
+		if t, ok := codec.EventValue(event, "@metadata.topic"); ok {
+			if str, ok := t.(string); ok && str != "" {
+				topic = str
+			}
+		}
+
+		id, err := e.registry.schemaID(e.strategy.subject(topic, e.schema.name), e.schemaJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		var header [5]byte // magic byte 0 + schema ID
+		binary.BigEndian.PutUint32(header[1:], uint32(id))
+		e.buf = append(e.buf, header[:]...)
+	}
+
+	buf, err := encodeRecord(e.buf, e.schema, func(path string) (interface{}, bool) {
+		return codec.EventValue(event, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.buf = buf
+	return buf, nil
+}
@@ -0,0 +1,251 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package avro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func encodeEvent(t *testing.T, schema string, event *beat.Event) ([]byte, error) {
+	enc, err := New([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc.Encode("test", event)
+}
+
+func TestEncodePrimitives(t *testing.T) {
+	schema := `{
+		"type": "record",
+		"name": "event",
+		"fields": [
+			{"name": "message", "type": "string"},
+			{"name": "count", "type": "int"},
+			{"name": "offset", "type": "long"},
+			{"name": "ok", "type": "boolean"},
+			{"name": "ratio", "type": "float"},
+			{"name": "raw", "type": "bytes"},
+			{"name": "none", "type": "null"}
+		]
+	}`
+
+	out, err := encodeEvent(t, schema, &beat.Event{Fields: common.MapStr{
+		"message": "ab",
+		"count":   -1,
+		"offset":  uint64(64),
+		"ok":      true,
+		"ratio":   1,
+		"raw":     []byte{0xff},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		0x04, 'a', 'b', // message
+		0x01,       // count
+		0x80, 0x01, // offset
+		0x01,                   // ok
+		0x00, 0x00, 0x80, 0x3f, // ratio
+		0x02, 0xff, // raw
+	}
+	assert.Equal(t, expected, out)
+}
+
+func TestEncodeComplex(t *testing.T) {
+	schema := `{
+		"type": "record",
+		"name": "event",
+		"namespace": "org.test",
+		"fields": [
+			{"name": "ts", "field": "@timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "host", "field": "host.name", "type": ["null", "string"]},
+			{"name": "level", "type": {"type": "enum", "name": "level", "symbols": ["info", "error"]}},
+			{"name": "tags", "type": {"type": "array", "items": "string"}},
+			{"name": "labels", "type": {"type": "map", "values": "long"}},
+			{"name": "source", "type": {
+				"type": "record",
+				"name": "source",
+				"fields": [{"name": "port", "type": "int"}]
+			}},
+			{"name": "destination", "type": ["null", "source"], "default": null},
+			{"name": "topic", "field": "@metadata.topic", "type": "string"},
+			{"name": "id", "type": {"type": "fixed", "name": "id", "size": 2}}
+		]
+	}`
+
+	event := &beat.Event{
+		Timestamp: time.Unix(1, 2*int64(time.Millisecond)),
+		Meta:      common.MapStr{"topic": "t"},
+		Fields: common.MapStr{
+			"host":   common.MapStr{"name": "a"},
+			"level":  "error",
+			"tags":   []string{"x", "y"},
+			"labels": map[string]interface{}{"b": 2, "a": 1},
+			"source": common.MapStr{"port": 1},
+			"id":     "ab",
+		},
+	}
+	out, err := encodeEvent(t, schema, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		0xd4, 0x0f, // ts: 1002
+		0x02, 0x02, 'a', // host: union branch 1
+		0x02,                             // level: index 1
+		0x04, 0x02, 'x', 0x02, 'y', 0x00, // tags
+		0x04, 0x02, 'a', 0x02, 0x02, 'b', 0x04, 0x00, // labels, sorted by key
+		0x02,      // source.port
+		0x00,      // destination: null
+		0x02, 't', // topic
+		'a', 'b', // id
+	}
+	assert.Equal(t, expected, out)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		schema string
+		fields common.MapStr
+	}{
+		"missing field": {
+			schema: `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "string"}]}`,
+			fields: common.MapStr{},
+		},
+		"type mismatch": {
+			schema: `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "int"}]}`,
+			fields: common.MapStr{"a": "text"},
+		},
+		"int overflow": {
+			schema: `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "int"}]}`,
+			fields: common.MapStr{"a": int64(1) << 40},
+		},
+		"unknown enum symbol": {
+			schema: `{"type": "record", "name": "r", "fields": [
+				{"name": "a", "type": {"type": "enum", "name": "e", "symbols": ["x"]}}
+			]}`,
+			fields: common.MapStr{"a": "y"},
+		},
+		"no matching union branch": {
+			schema: `{"type": "record", "name": "r", "fields": [{"name": "a", "type": ["int", "boolean"]}]}`,
+			fields: common.MapStr{"a": "text"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := encodeEvent(t, test.schema, &beat.Event{Fields: test.fields})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFieldDefault(t *testing.T) {
+	schema := `{"type": "record", "name": "r", "fields": [
+		{"name": "a", "type": "string", "default": "x"},
+		{"name": "b", "type": ["null", "int"]}
+	]}`
+
+	out, err := encodeEvent(t, schema, &beat.Event{Fields: common.MapStr{}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte{0x02, 'x', 0x00}, out)
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":       `{`,
+		"no record":          `"string"`,
+		"unknown type":       `{"type": "record", "name": "r", "fields": [{"name": "a", "type": "text"}]}`,
+		"missing name":       `{"type": "record", "fields": []}`,
+		"redefined type":     `{"type": "record", "name": "r", "fields": [{"name": "a", "type": {"type": "record", "name": "r", "fields": []}}]}`,
+		"empty enum":         `{"type": "record", "name": "r", "fields": [{"name": "a", "type": {"type": "enum", "name": "e", "symbols": []}}]}`,
+		"nested union":       `{"type": "record", "name": "r", "fields": [{"name": "a", "type": ["null", ["int"]]}]}`,
+		"array items":        `{"type": "record", "name": "r", "fields": [{"name": "a", "type": {"type": "array"}}]}`,
+		"field without type": `{"type": "record", "name": "r", "fields": [{"name": "a"}]}`,
+	}
+
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSchema([]byte(schema))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseSchemaNames(t *testing.T) {
+	s, err := parseSchema([]byte(`{
+		"type": "record",
+		"name": "event",
+		"namespace": "org.test",
+		"fields": [
+			{"name": "a", "type": {"type": "fixed", "name": "md5", "size": 16}},
+			{"name": "b", "type": "md5"},
+			{"name": "c", "type": "org.test.md5"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "org.test.event", s.name)
+	for _, f := range s.fields {
+		assert.Equal(t, "org.test.md5", f.schema.name)
+	}
+}
+
+func TestLogicalTypes(t *testing.T) {
+	ts := time.Date(1970, 1, 2, 0, 0, 1, 1000, time.UTC)
+
+	tests := []struct {
+		logical  string
+		expected int64
+	}{
+		{"date", 1},
+		{"timestamp-millis", 86401000},
+		{"timestamp-micros", 86401000001},
+		{"", 7},
+	}
+
+	for _, test := range tests {
+		s := &schema{typ: typeLong, logical: test.logical}
+		v := interface{}(ts)
+		if test.logical == "" {
+			v = 7
+		}
+
+		i, ok := toInt(s, v)
+		if assert.True(t, ok, test.logical) {
+			assert.Equal(t, test.expected, i, test.logical)
+		}
+	}
+
+	i, ok := toInt(&schema{typ: typeInt, logical: "date"}, time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), i)
+}
@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	Schema         string         `config:"schema"`
+	SchemaFile     string         `config:"schema_file"`
+	SchemaRegistry *common.Config `config:"schema_registry"`
+}
+
+type registryConfig struct {
+	URL          string            `config:"url" validate:"required"`
+	Username     string            `config:"username"`
+	Password     string            `config:"password"`
+	TLS          *tlscommon.Config `config:"ssl"`
+	Timeout      time.Duration     `config:"timeout"`
+	AutoRegister bool              `config:"auto_register"`
+	Strategy     subjectStrategy   `config:"subject_name_strategy"`
+}
+
+// subjectStrategy configures how the schema registry subject is derived
+// from the topic and the record name.
+type subjectStrategy uint8
+
+const (
+	topicNameStrategy subjectStrategy = iota
+	recordNameStrategy
+	topicRecordNameStrategy
+)
+
+var subjectStrategies = map[string]subjectStrategy{
+	"topic_name":        topicNameStrategy,
+	"record_name":       recordNameStrategy,
+	"topic_record_name": topicRecordNameStrategy,
+}
+
+func defaultRegistryConfig() registryConfig {
+	return registryConfig{
+		Timeout:      30 * time.Second,
+		AutoRegister: true,
+		Strategy:     topicNameStrategy,
+	}
+}
+
+func (c *config) Validate() error {
+	if (c.Schema == "") == (c.SchemaFile == "") {
+		return errors.New("exactly one of schema or schema_file must be configured")
+	}
+	return nil
+}
+
+func (s *subjectStrategy) Unpack(str string) error {
+	v, ok := subjectStrategies[str]
+	if !ok {
+		return fmt.Errorf("unknown subject_name_strategy '%v'", str)
+	}
+	*s = v
+	return nil
+}
+
+// subject returns the schema registry subject for the topic and record.
+func (s subjectStrategy) subject(topic, record string) string {
+	switch s {
+	case recordNameStrategy:
+		return record
+	case topicRecordNameStrategy:
+		return topic + "-" + record
+	default:
+		return topic + "-value"
+	}
+}
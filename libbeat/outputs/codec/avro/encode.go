@@ -0,0 +1,318 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+// lookupFn returns the value of a record field by path.
+type lookupFn func(path string) (interface{}, bool)
+
+// encodeRecord appends the Avro binary encoding of a record to buf. See
+// https://avro.apache.org/docs/1.8.2/spec.html#binary_encoding.
+func encodeRecord(buf []byte, s *schema, lookup lookupFn) ([]byte, error) {
+	var err error
+	for _, f := range s.fields {
+		v, exists := lookup(f.path)
+		if !exists || v == nil {
+			switch {
+			case f.hasDefault:
+				v = f.def
+			case f.schema.nullable():
+				v = nil
+			default:
+				return nil, fmt.Errorf("missing value for field '%v'", f.name)
+			}
+		}
+
+		buf, err = encodeValue(buf, f.schema, v)
+		if err != nil {
+			return nil, fmt.Errorf("field '%v': %v", f.name, err)
+		}
+	}
+	return buf, nil
+}
+
+func encodeValue(buf []byte, s *schema, v interface{}) ([]byte, error) {
+	switch s.typ {
+	case typeNull:
+		if v != nil {
+			return nil, typeError(s, v)
+		}
+		return buf, nil
+
+	case typeBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case typeInt:
+		i, ok := toInt(s, v)
+		if !ok || i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, typeError(s, v)
+		}
+		return appendLong(buf, i), nil
+
+	case typeLong:
+		i, ok := toInt(s, v)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		return appendLong(buf, i), nil
+
+	case typeFloat:
+		f, ok := codec.ToFloat64(v)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(float32(f)))
+		return append(buf, tmp[:]...), nil
+
+	case typeDouble:
+		f, ok := codec.ToFloat64(v)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+		return append(buf, tmp[:]...), nil
+
+	case typeBytes, typeString:
+		var b []byte
+		switch str := v.(type) {
+		case string:
+			b = []byte(str)
+		case []byte:
+			b = str
+		default:
+			return nil, typeError(s, v)
+		}
+		buf = appendLong(buf, int64(len(b)))
+		return append(buf, b...), nil
+
+	case typeFixed:
+		var b []byte
+		switch str := v.(type) {
+		case string:
+			b = []byte(str)
+		case []byte:
+			b = str
+		}
+		if b == nil || len(b) != s.size {
+			return nil, typeError(s, v)
+		}
+		return append(buf, b...), nil
+
+	case typeEnum:
+		str, ok := v.(string)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		for i, sym := range s.symbols {
+			if sym == str {
+				return appendLong(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("'%v' is no symbol of enum '%v'", str, s.name)
+
+	case typeRecord:
+		m, ok := codec.ToMap(v)
+		if !ok {
+			return nil, typeError(s, v)
+		}
+		return encodeRecord(buf, s, func(path string) (interface{}, bool) {
+			v, err := m.GetValue(path)
+			return v, err == nil
+		})
+
+	case typeArray:
+		return encodeArray(buf, s, v)
+
+	case typeMap:
+		return encodeMap(buf, s, v)
+
+	case typeUnion:
+		// Use the first branch the value can be encoded with. On failure the
+		// partially encoded value is overwritten by the next attempt.
+		for i, branch := range s.branches {
+			out, err := encodeValue(appendLong(buf, int64(i)), branch, v)
+			if err == nil {
+				return out, nil
+			}
+		}
+		return nil, typeError(s, v)
+	}
+
+	return nil, fmt.Errorf("unsupported schema type %v", s.typ)
+}
+
+func encodeArray(buf []byte, s *schema, v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, typeError(s, v)
+	}
+	if _, isBytes := v.([]byte); isBytes {
+		return nil, typeError(s, v)
+	}
+
+	var err error
+	if n := rv.Len(); n > 0 {
+		buf = appendLong(buf, int64(n))
+		for i := 0; i < n; i++ {
+			buf, err = encodeValue(buf, s.items, rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return append(buf, 0), nil
+}
+
+func encodeMap(buf []byte, s *schema, v interface{}) ([]byte, error) {
+	m, ok := codec.ToMap(v)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if v == nil || rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return nil, typeError(s, v)
+		}
+		m = common.MapStr{}
+		for _, key := range rv.MapKeys() {
+			m[key.String()] = rv.MapIndex(key).Interface()
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var err error
+	if len(keys) > 0 {
+		buf = appendLong(buf, int64(len(keys)))
+		for _, k := range keys {
+			buf = appendLong(buf, int64(len(k)))
+			buf = append(buf, k...)
+			buf, err = encodeValue(buf, s.items, m[k])
+			if err != nil {
+				return nil, fmt.Errorf("key '%v': %v", k, err)
+			}
+		}
+	}
+	return append(buf, 0), nil
+}
+
+// toInt converts numbers and, for the date and timestamp logical types,
+// timestamps to integers.
+func toInt(s *schema, v interface{}) (int64, bool) {
+	var scale time.Duration
+	switch s.logical {
+	case "date":
+		scale = 24 * time.Hour
+	case "timestamp-millis":
+		scale = time.Millisecond
+	case "timestamp-micros":
+		scale = time.Microsecond
+	default:
+		return codec.ToInt64(v)
+	}
+
+	t, ok := codec.ToTime(v)
+	if !ok {
+		str, isString := v.(string)
+		if !isString {
+			return codec.ToInt64(v)
+		}
+
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, str); err != nil {
+			return 0, false
+		}
+	}
+
+	if scale == 24*time.Hour {
+		days := t.Unix() / 86400
+		if t.Unix() < 0 && t.Unix()%86400 != 0 {
+			days--
+		}
+		return days, true
+	}
+	return t.Unix()*int64(time.Second/scale) + int64(t.Nanosecond())/int64(scale), true
+}
+
+// appendLong appends a zig-zag encoded variable length integer.
+func appendLong(buf []byte, i int64) []byte {
+	u := uint64(i<<1) ^ uint64(i>>63)
+	for u >= 0x80 {
+		buf = append(buf, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(buf, byte(u))
+}
+
+func typeError(s *schema, v interface{}) error {
+	return fmt.Errorf("can not encode %T as %v", v, s)
+}
+
+func (s *schema) String() string {
+	if s.name != "" {
+		return s.name
+	}
+	if s.logical != "" {
+		return s.logical
+	}
+	return s.typ.String()
+}
+
+func (t schemaType) String() string {
+	switch t {
+	case typeRecord:
+		return "record"
+	case typeEnum:
+		return "enum"
+	case typeArray:
+		return "array"
+	case typeMap:
+		return "map"
+	case typeUnion:
+		return "union"
+	case typeFixed:
+		return "fixed"
+	}
+	for name, typ := range primitiveTypes {
+		if typ == t {
+			return name
+		}
+	}
+	return "unknown"
+}
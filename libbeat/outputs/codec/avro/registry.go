@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// registry is a client for the Confluent schema registry REST API. Schema IDs
+// are cached per subject, so the registry is only queried once per subject.
+type registry struct {
+	url                string
+	username, password string
+	autoRegister       bool
+	http               *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int32
+}
+
+type schemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+type schemaResponse struct {
+	ID int32 `json:"id"`
+}
+
+func newRegistry(config registryConfig) (*registry, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry url: %v", err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tls != nil {
+		transport.TLSClientConfig = tls.BuildModuleConfig(u.Hostname())
+	}
+
+	return &registry{
+		url:          strings.TrimSuffix(config.URL, "/"),
+		username:     config.Username,
+		password:     config.Password,
+		autoRegister: config.AutoRegister,
+		http:         &http.Client{Transport: transport, Timeout: config.Timeout},
+		ids:          map[string]int32{},
+	}, nil
+}
+
+// schemaID returns the ID of the schema registered for the subject. If
+// auto registration is enabled, the schema is registered if the subject or
+// schema version does not exist yet. Failures caused by the registry not
+// being available are returned as temporary errors.
+func (r *registry) schemaID(subject, schema string) (int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, exists := r.ids[subject]; exists {
+		return id, nil
+	}
+
+	path := "/subjects/" + url.PathEscape(subject)
+	if r.autoRegister {
+		path += "/versions"
+	}
+
+	id, err := r.post(path, schema)
+	if err != nil {
+		wrapped := fmt.Errorf("failed to get schema id for subject '%v': %v", subject, err)
+		if codec.IsTemporary(err) {
+			return 0, codec.Temporary(wrapped)
+		}
+		return 0, wrapped
+	}
+
+	r.ids[subject] = id
+	return id, nil
+}
+
+func (r *registry) post(path, schema string) (int32, error) {
+	body, err := json.Marshal(schemaRequest{Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	if r.username != "" || r.password != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return 0, codec.Temporary(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, codec.Temporary(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("schema registry returned %v: %s", resp.Status, data)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return 0, codec.Temporary(err)
+		}
+		return 0, err
+	}
+
+	var schemaResp schemaResponse
+	if err := json.Unmarshal(data, &schemaResp); err != nil {
+		return 0, fmt.Errorf("invalid schema registry response: %v", err)
+	}
+	return schemaResp.ID, nil
+}
@@ -0,0 +1,233 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package avro
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+const testSchema = `{"type": "record", "name": "event", "namespace": "org.test", "fields": [{"name": "message", "type": "string"}]}`
+
+type registryHandler struct {
+	sync.Mutex
+	status   int
+	requests []*http.Request
+	schemas  []string
+}
+
+func (h *registryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+
+	var body schemaRequest
+	data, _ := ioutil.ReadAll(req.Body)
+	json.Unmarshal(data, &body)
+
+	h.requests = append(h.requests, req)
+	h.schemas = append(h.schemas, body.Schema)
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+		return
+	}
+	w.Write([]byte(`{"id": 258}`))
+}
+
+func newTestEncoder(t *testing.T, settings map[string]interface{}) *Encoder {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"avro": map[string]interface{}{
+			"schema":          testSchema,
+			"schema_registry": settings,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := codec.Config{}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := codec.CreateEncoder(beat.Info{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc.(*Encoder)
+}
+
+func TestRegistryFraming(t *testing.T) {
+	h := &registryHandler{}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	enc := newTestEncoder(t, map[string]interface{}{
+		"url":      server.URL,
+		"username": "user",
+		"password": "secret",
+	})
+
+	event := &beat.Event{
+		Meta:   common.MapStr{"topic": "logs"},
+		Fields: common.MapStr{"message": "a"},
+	}
+	for i := 0; i < 2; i++ {
+		out, err := enc.Encode("index", event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []byte{0, 0, 0, 1, 2, 0x02, 'a'}, out)
+	}
+
+	// schema IDs are cached
+	if !assert.Len(t, h.requests, 1) {
+		return
+	}
+	req := h.requests[0]
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/subjects/logs-value/versions", req.URL.Path)
+	assert.Equal(t, registryContentType, req.Header.Get("Content-Type"))
+	user, pass, _ := req.BasicAuth()
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "secret", pass)
+	assert.Equal(t, testSchema, h.schemas[0])
+}
+
+func TestRegistrySubjects(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]interface{}
+		meta     common.MapStr
+		expected string
+	}{
+		"topic name": {
+			settings: map[string]interface{}{},
+			meta:     common.MapStr{"topic": "logs"},
+			expected: "/subjects/logs-value/versions",
+		},
+		"index as topic": {
+			settings: map[string]interface{}{},
+			expected: "/subjects/index-value/versions",
+		},
+		"record name": {
+			settings: map[string]interface{}{"subject_name_strategy": "record_name"},
+			meta:     common.MapStr{"topic": "logs"},
+			expected: "/subjects/org.test.event/versions",
+		},
+		"topic record name": {
+			settings: map[string]interface{}{"subject_name_strategy": "topic_record_name"},
+			meta:     common.MapStr{"topic": "logs"},
+			expected: "/subjects/logs-org.test.event/versions",
+		},
+		"lookup only": {
+			settings: map[string]interface{}{"auto_register": false},
+			meta:     common.MapStr{"topic": "logs"},
+			expected: "/subjects/logs-value",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &registryHandler{}
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			test.settings["url"] = server.URL
+			enc := newTestEncoder(t, test.settings)
+			_, err := enc.Encode("index", &beat.Event{
+				Meta:   test.meta,
+				Fields: common.MapStr{"message": "a"},
+			})
+			if assert.NoError(t, err) && assert.Len(t, h.requests, 1) {
+				assert.Equal(t, test.expected, h.requests[0].URL.Path)
+			}
+		})
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		temporary bool
+	}{
+		{http.StatusServiceUnavailable, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusConflict, false},
+		{http.StatusNotFound, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			h := &registryHandler{status: test.status}
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			enc := newTestEncoder(t, map[string]interface{}{"url": server.URL})
+			_, err := enc.Encode("index", &beat.Event{Fields: common.MapStr{"message": "a"}})
+			if assert.Error(t, err) {
+				assert.Equal(t, test.temporary, codec.IsTemporary(err))
+			}
+		})
+	}
+}
+
+func TestRegistryUnavailable(t *testing.T) {
+	server := httptest.NewServer(&registryHandler{})
+	url := server.URL
+	server.Close()
+
+	enc := newTestEncoder(t, map[string]interface{}{"url": url})
+	_, err := enc.Encode("index", &beat.Event{Fields: common.MapStr{"message": "a"}})
+	assert.True(t, codec.IsTemporary(err))
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no schema":        {},
+		"schema and file":  {"schema": testSchema, "schema_file": "schema.avsc"},
+		"unknown strategy": {"schema": testSchema, "schema_registry": map[string]interface{}{"url": "http://localhost", "subject_name_strategy": "x"}},
+		"no registry url":  {"schema": testSchema, "schema_registry": map[string]interface{}{"auto_register": true}},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(map[string]interface{}{"avro": settings})
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := codec.Config{}
+			if err := cfg.Unpack(&config); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = codec.CreateEncoder(beat.Info{}, config)
+			assert.Error(t, err)
+		})
+	}
+}
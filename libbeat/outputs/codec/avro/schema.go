@@ -0,0 +1,320 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// schema is a parsed Avro schema. See
+// https://avro.apache.org/docs/1.8.2/spec.html#schemas.
+type schema struct {
+	typ     schemaType
+	logical string
+
+	// fullname of named types (record, enum, fixed)
+	name string
+
+	fields   []*field  // record fields
+	symbols  []string  // enum symbols
+	items    *schema   // array items or map values
+	branches []*schema // union branches
+	size     int       // fixed size
+}
+
+type field struct {
+	name string
+
+	// path of the event field to read the value from. Defaults to the field
+	// name, but can be configured using the custom `field` attribute.
+	path string
+
+	schema     *schema
+	def        interface{}
+	hasDefault bool
+}
+
+type schemaType uint8
+
+const (
+	typeNull schemaType = iota
+	typeBoolean
+	typeInt
+	typeLong
+	typeFloat
+	typeDouble
+	typeBytes
+	typeString
+	typeRecord
+	typeEnum
+	typeArray
+	typeMap
+	typeUnion
+	typeFixed
+)
+
+var primitiveTypes = map[string]schemaType{
+	"null":    typeNull,
+	"boolean": typeBoolean,
+	"int":     typeInt,
+	"long":    typeLong,
+	"float":   typeFloat,
+	"double":  typeDouble,
+	"bytes":   typeBytes,
+	"string":  typeString,
+}
+
+type schemaParser struct {
+	names map[string]*schema
+}
+
+// parseSchema parses an Avro schema in JSON format. The top-level schema must
+// be a record.
+func parseSchema(data []byte) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+
+	p := &schemaParser{names: map[string]*schema{}}
+	s, err := p.parse(v, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	if s.typ != typeRecord {
+		return nil, errors.New("invalid avro schema: top-level schema must be a record")
+	}
+	return s, nil
+}
+
+func (p *schemaParser) parse(v interface{}, namespace string) (*schema, error) {
+	switch def := v.(type) {
+	case string:
+		if t, ok := primitiveTypes[def]; ok {
+			return &schema{typ: t}, nil
+		}
+		if s := p.lookup(def, namespace); s != nil {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type '%v'", def)
+
+	case []interface{}:
+		s := &schema{typ: typeUnion}
+		for _, branch := range def {
+			b, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if b.typ == typeUnion {
+				return nil, errors.New("unions must not contain unions")
+			}
+			s.branches = append(s.branches, b)
+		}
+		if len(s.branches) == 0 {
+			return nil, errors.New("empty union")
+		}
+		return s, nil
+
+	case map[string]interface{}:
+		return p.parseComplex(def, namespace)
+	}
+
+	return nil, fmt.Errorf("unsupported schema definition: %v", v)
+}
+
+func (p *schemaParser) parseComplex(def map[string]interface{}, namespace string) (*schema, error) {
+	typeName, ok := def["type"].(string)
+	if !ok {
+		// type is a nested schema definition
+		if def["type"] == nil {
+			return nil, errors.New("missing type")
+		}
+		return p.parse(def["type"], namespace)
+	}
+
+	logical, _ := def["logicalType"].(string)
+
+	switch typeName {
+	case "record", "error":
+		s, namespace, err := p.define(typeRecord, def, namespace)
+		if err != nil {
+			return nil, err
+		}
+		fields, ok := def["fields"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record '%v' has no fields", s.name)
+		}
+		for _, f := range fields {
+			field, err := p.parseField(f, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("record '%v': %v", s.name, err)
+			}
+			s.fields = append(s.fields, field)
+		}
+		return s, nil
+
+	case "enum":
+		s, _, err := p.define(typeEnum, def, namespace)
+		if err != nil {
+			return nil, err
+		}
+		symbols, _ := def["symbols"].([]interface{})
+		for _, sym := range symbols {
+			str, ok := sym.(string)
+			if !ok {
+				return nil, fmt.Errorf("enum '%v' has invalid symbol %v", s.name, sym)
+			}
+			s.symbols = append(s.symbols, str)
+		}
+		if len(s.symbols) == 0 {
+			return nil, fmt.Errorf("enum '%v' has no symbols", s.name)
+		}
+		return s, nil
+
+	case "fixed":
+		s, _, err := p.define(typeFixed, def, namespace)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := def["size"].(float64)
+		if !ok || size < 0 {
+			return nil, fmt.Errorf("fixed '%v' has invalid size", s.name)
+		}
+		s.size = int(size)
+		s.logical = logical
+		return s, nil
+
+	case "array", "map":
+		key, typ := "items", typeArray
+		if typeName == "map" {
+			key, typ = "values", typeMap
+		}
+		if def[key] == nil {
+			return nil, fmt.Errorf("%v has no %v", typeName, key)
+		}
+		items, err := p.parse(def[key], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{typ: typ, items: items}, nil
+	}
+
+	s, err := p.parse(typeName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if logical == "" {
+		return s, nil
+	}
+
+	// logical types annotate primitive types only, so we can copy the schema
+	annotated := *s
+	annotated.logical = logical
+	return &annotated, nil
+}
+
+// define creates and registers a new named type, returning the namespace
+// to be used for nested definitions.
+func (p *schemaParser) define(
+	typ schemaType,
+	def map[string]interface{},
+	namespace string,
+) (*schema, string, error) {
+	name, _ := def["name"].(string)
+	if name == "" {
+		return nil, "", errors.New("named type without name")
+	}
+
+	if ns, ok := def["namespace"].(string); ok {
+		namespace = ns
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		namespace = name[:idx]
+	} else if namespace != "" {
+		name = namespace + "." + name
+	}
+
+	if _, exists := p.names[name]; exists {
+		return nil, "", fmt.Errorf("type '%v' redefined", name)
+	}
+
+	s := &schema{typ: typ, name: name}
+	p.names[name] = s
+	return s, namespace, nil
+}
+
+func (p *schemaParser) lookup(name, namespace string) *schema {
+	if !strings.Contains(name, ".") && namespace != "" {
+		if s := p.names[namespace+"."+name]; s != nil {
+			return s
+		}
+	}
+	return p.names[name]
+}
+
+func (p *schemaParser) parseField(v interface{}, namespace string) (*field, error) {
+	def, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid field definition: %v", v)
+	}
+
+	name, _ := def["name"].(string)
+	if name == "" {
+		return nil, errors.New("field without name")
+	}
+	if def["type"] == nil {
+		return nil, fmt.Errorf("field '%v' has no type", name)
+	}
+
+	s, err := p.parse(def["type"], namespace)
+	if err != nil {
+		return nil, fmt.Errorf("field '%v': %v", name, err)
+	}
+
+	path := name
+	if custom, ok := def["field"].(string); ok && custom != "" {
+		path = custom
+	}
+
+	defValue, hasDefault := def["default"]
+	return &field{
+		name:       name,
+		path:       path,
+		schema:     s,
+		def:        defValue,
+		hasDefault: hasDefault,
+	}, nil
+}
+
+// nullable returns true if the schema accepts null values.
+func (s *schema) nullable() bool {
+	switch s.typ {
+	case typeNull:
+		return true
+	case typeUnion:
+		for _, b := range s.branches {
+			if b.typ == typeNull {
+				return true
+			}
+		}
+	}
+	return false
+}
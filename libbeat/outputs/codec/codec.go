@@ -22,3 +22,25 @@ import "github.com/elastic/beats/libbeat/beat"
 type Codec interface {
 	Encode(index string, event *beat.Event) ([]byte, error)
 }
+
+type temporaryError struct {
+	error
+}
+
+// Temporary wraps errors caused by temporary conditions, like a schema
+// registry not being available. Outputs should retry encoding the event later
+// instead of dropping it.
+func Temporary(err error) error {
+	return temporaryError{err}
+}
+
+func (temporaryError) Temporary() bool { return true }
+
+// IsTemporary returns true if encoding failed because of a temporary
+// condition.
+func IsTemporary(err error) bool {
+	t, ok := err.(interface {
+		Temporary() bool
+	})
+	return ok && t.Temporary()
+}
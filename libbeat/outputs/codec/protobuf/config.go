@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import "fmt"
+
+type config struct {
+	Fields []fieldConfig `config:"fields"`
+}
+
+type fieldConfig struct {
+	// Name is the path of the event field to encode.
+	Name     string    `config:"name" validate:"required"`
+	Number   int       `config:"number" validate:"required"`
+	Type     fieldType `config:"type" validate:"required"`
+	Repeated bool      `config:"repeated"`
+}
+
+type fieldType uint8
+
+const (
+	typeString fieldType = iota + 1
+	typeBytes
+	typeBool
+	typeInt32
+	typeInt64
+	typeUint32
+	typeUint64
+	typeSint32
+	typeSint64
+	typeDouble
+	typeFloat
+	typeTimestamp
+	typeJSON
+	typeStruct
+)
+
+var fieldTypes = map[string]fieldType{
+	"string":    typeString,
+	"bytes":     typeBytes,
+	"bool":      typeBool,
+	"int32":     typeInt32,
+	"int64":     typeInt64,
+	"uint32":    typeUint32,
+	"uint64":    typeUint64,
+	"sint32":    typeSint32,
+	"sint64":    typeSint64,
+	"double":    typeDouble,
+	"float":     typeFloat,
+	"timestamp": typeTimestamp,
+	"json":      typeJSON,
+	"struct":    typeStruct,
+}
+
+const (
+	maxFieldNumber      = 1<<29 - 1
+	reservedNumberStart = 19000
+	reservedNumberEnd   = 19999
+)
+
+func (t *fieldType) Unpack(str string) error {
+	v, ok := fieldTypes[str]
+	if !ok {
+		return fmt.Errorf("unknown protobuf field type '%v'", str)
+	}
+	*t = v
+	return nil
+}
+
+// packed returns true for scalar numeric types, which are encoded as packed
+// repeated fields.
+func (t fieldType) packed() bool {
+	switch t {
+	case typeString, typeBytes, typeTimestamp, typeJSON, typeStruct:
+		return false
+	}
+	return true
+}
+
+func (c *config) Validate() error {
+	numbers := map[int]string{}
+	for _, f := range c.Fields {
+		if f.Number < 1 || f.Number > maxFieldNumber {
+			return fmt.Errorf("field '%v' has invalid number %v", f.Name, f.Number)
+		}
+		if f.Number >= reservedNumberStart && f.Number <= reservedNumberEnd {
+			return fmt.Errorf("field '%v' uses reserved number %v", f.Name, f.Number)
+		}
+		if other, exists := numbers[f.Number]; exists {
+			return fmt.Errorf("fields '%v' and '%v' use the same number %v", other, f.Name, f.Number)
+		}
+		numbers[f.Number] = f.Name
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+// Encoder serializes events to protobuf messages. The message layout is
+// given by the configured fields. If no fields are configured, the complete
+// event is encoded as google.protobuf.Struct.
+type Encoder struct {
+	fields []fieldConfig
+	buf    protowire.Buffer
+}
+
+// Field numbers of the well known types google.protobuf.Timestamp,
+// google.protobuf.Struct, google.protobuf.Value and google.protobuf.ListValue.
+const (
+	timestampSeconds = 1
+	timestampNanos   = 2
+
+	structFields = 1
+	entryKey     = 1
+	entryValue   = 2
+
+	valueNull   = 1
+	valueNumber = 2
+	valueString = 3
+	valueBool   = 4
+	valueStruct = 5
+	valueList   = 6
+
+	listValues = 1
+)
+
+func init() {
+	codec.RegisterType("protobuf", func(_ beat.Info, cfg *common.Config) (codec.Codec, error) {
+		cfgwarn.Beta("The protobuf codec is beta.")
+
+		config := config{}
+		if cfg != nil {
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, err
+			}
+		}
+		return newEncoder(config.Fields), nil
+	})
+}
+
+// newEncoder creates a protobuf Encoder for the given message fields.
+func newEncoder(fields []fieldConfig) *Encoder {
+	return &Encoder{fields: fields}
+}
+
+func (e *Encoder) Encode(_ string, event *beat.Event) ([]byte, error) {
+	e.buf.Reset()
+
+	if len(e.fields) == 0 {
+		doc := common.MapStr{}
+		for k, v := range event.Fields {
+			doc[k] = v
+		}
+		doc["@timestamp"] = event.Timestamp
+		if event.Meta != nil {
+			doc["@metadata"] = event.Meta
+		}
+		encodeStruct(&e.buf, doc)
+		return e.buf.Bytes(), nil
+	}
+
+	for _, f := range e.fields {
+		v, exists := codec.EventValue(event, f.Name)
+		if !exists || v == nil {
+			// proto3 fields are optional
+			continue
+		}
+
+		var err error
+		if f.Repeated {
+			err = encodeRepeated(&e.buf, f, v)
+		} else {
+			err = encodeField(&e.buf, f, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field '%v': %v", f.Name, err)
+		}
+	}
+	return e.buf.Bytes(), nil
+}
+
+func encodeField(b *protowire.Buffer, f fieldConfig, v interface{}) error {
+	switch f.Type {
+	case typeString, typeBytes:
+		switch str := v.(type) {
+		case string:
+			b.StringField(f.Number, str)
+		case []byte:
+			b.BytesField(f.Number, str)
+		default:
+			return typeError(f, v)
+		}
+
+	case typeTimestamp:
+		t, ok := codec.ToTime(v)
+		if !ok {
+			return typeError(f, v)
+		}
+		b.MessageField(f.Number, func(b *protowire.Buffer) {
+			encodeTimestamp(b, t)
+		})
+
+	case typeJSON:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.BytesField(f.Number, data)
+
+	case typeStruct:
+		m, ok := codec.ToMap(v)
+		if !ok {
+			return typeError(f, v)
+		}
+		b.MessageField(f.Number, func(b *protowire.Buffer) {
+			encodeStruct(b, m)
+		})
+
+	case typeDouble:
+		d, ok := codec.ToFloat64(v)
+		if !ok {
+			return typeError(f, v)
+		}
+		b.DoubleField(f.Number, d)
+
+	case typeFloat:
+		d, ok := codec.ToFloat64(v)
+		if !ok {
+			return typeError(f, v)
+		}
+		b.Fixed32Field(f.Number, math.Float32bits(float32(d)))
+
+	default:
+		u, ok := toVarint(f.Type, v)
+		if !ok {
+			return typeError(f, v)
+		}
+		b.VarintField(f.Number, u)
+	}
+	return nil
+}
+
+// encodeRepeated encodes all list elements. Scalar numeric types are encoded
+// as a single packed field.
+func encodeRepeated(b *protowire.Buffer, f fieldConfig, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return typeError(f, v)
+	}
+	if rv.Len() == 0 {
+		return nil
+	}
+
+	if !f.Type.packed() {
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeField(b, f, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// convert all values first, so errors can be reported before writing the
+	// length prefixed field
+	values := make([]uint64, rv.Len())
+	for i := range values {
+		elem := rv.Index(i).Interface()
+
+		var ok bool
+		switch f.Type {
+		case typeDouble, typeFloat:
+			var d float64
+			if d, ok = codec.ToFloat64(elem); ok {
+				if f.Type == typeFloat {
+					values[i] = uint64(math.Float32bits(float32(d)))
+				} else {
+					values[i] = math.Float64bits(d)
+				}
+			}
+		default:
+			values[i], ok = toVarint(f.Type, elem)
+		}
+		if !ok {
+			return typeError(f, elem)
+		}
+	}
+
+	b.MessageField(f.Number, func(b *protowire.Buffer) {
+		for _, u := range values {
+			switch f.Type {
+			case typeDouble:
+				b.Fixed64(u)
+			case typeFloat:
+				b.Fixed32(uint32(u))
+			default:
+				b.Varint(u)
+			}
+		}
+	})
+	return nil
+}
+
+// toVarint converts bool and integer values to their varint representation.
+func toVarint(t fieldType, v interface{}) (uint64, bool) {
+	if t == typeBool {
+		b, ok := v.(bool)
+		if b {
+			return 1, ok
+		}
+		return 0, ok
+	}
+
+	if u, ok := v.(uint64); ok && (t == typeUint64 || t == typeInt64 && u <= math.MaxInt64) {
+		return u, true
+	}
+
+	i, ok := codec.ToInt64(v)
+	if !ok {
+		return 0, false
+	}
+
+	switch t {
+	case typeInt32:
+		return uint64(i), i >= math.MinInt32 && i <= math.MaxInt32
+	case typeInt64:
+		return uint64(i), true
+	case typeUint32:
+		return uint64(i), i >= 0 && i <= math.MaxUint32
+	case typeUint64:
+		return uint64(i), i >= 0
+	case typeSint32:
+		return uint64(uint32(i<<1) ^ uint32(i>>31)), i >= math.MinInt32 && i <= math.MaxInt32
+	case typeSint64:
+		return uint64(i<<1) ^ uint64(i>>63), true
+	}
+	return 0, false
+}
+
+func encodeTimestamp(b *protowire.Buffer, t time.Time) {
+	if secs := t.Unix(); secs != 0 {
+		b.VarintField(timestampSeconds, uint64(secs))
+	}
+	if nanos := t.Nanosecond(); nanos != 0 {
+		b.VarintField(timestampNanos, uint64(nanos))
+	}
+}
+
+// encodeStruct encodes a map as google.protobuf.Struct. Keys are sorted, so
+// the encoding is deterministic.
+func encodeStruct(b *protowire.Buffer, m common.MapStr) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := m[k]
+		b.MessageField(structFields, func(b *protowire.Buffer) {
+			b.StringField(entryKey, k)
+			b.MessageField(entryValue, func(b *protowire.Buffer) {
+				encodeValue(b, v)
+			})
+		})
+	}
+}
+
+// encodeValue encodes a value as google.protobuf.Value. Timestamps are
+// encoded as RFC3339 strings, unknown types using their default formatting.
+func encodeValue(b *protowire.Buffer, v interface{}) {
+	if v == nil {
+		b.VarintField(valueNull, 0)
+		return
+	}
+
+	switch val := v.(type) {
+	case string:
+		b.StringField(valueString, val)
+		return
+	case []byte:
+		b.BytesField(valueString, val)
+		return
+	case bool:
+		b.BoolField(valueBool, val)
+		return
+	}
+
+	if d, ok := codec.ToFloat64(v); ok {
+		b.DoubleField(valueNumber, d)
+		return
+	}
+	if t, ok := codec.ToTime(v); ok {
+		b.StringField(valueString, t.UTC().Format(time.RFC3339Nano))
+		return
+	}
+	if m, ok := codec.ToMap(v); ok {
+		b.MessageField(valueStruct, func(b *protowire.Buffer) {
+			encodeStruct(b, m)
+		})
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		b.MessageField(valueList, func(b *protowire.Buffer) {
+			for i := 0; i < rv.Len(); i++ {
+				elem := rv.Index(i).Interface()
+				b.MessageField(listValues, func(b *protowire.Buffer) {
+					encodeValue(b, elem)
+				})
+			}
+		})
+	case reflect.Map:
+		m := common.MapStr{}
+		for _, key := range rv.MapKeys() {
+			m[fmt.Sprint(key.Interface())] = rv.MapIndex(key).Interface()
+		}
+		b.MessageField(valueStruct, func(b *protowire.Buffer) {
+			encodeStruct(b, m)
+		})
+	case reflect.Ptr:
+		if rv.IsNil() {
+			b.VarintField(valueNull, 0)
+		} else {
+			encodeValue(b, rv.Elem().Interface())
+		}
+	default:
+		b.StringField(valueString, fmt.Sprint(v))
+	}
+}
+
+func typeError(f fieldConfig, v interface{}) error {
+	return fmt.Errorf("can not encode %T as protobuf %v", v, f.Type)
+}
+
+func (t fieldType) String() string {
+	for name, typ := range fieldTypes {
+		if typ == t {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,231 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package protobuf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+)
+
+type decodedField struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+func decode(t *testing.T, msg []byte) []decodedField {
+	var fields []decodedField
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		number, _, v, data, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, decodedField{number, v, data})
+	}
+	return fields
+}
+
+func newTestEncoder(t *testing.T, fields ...map[string]interface{}) *Encoder {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"fields": fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := config{}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+	return newEncoder(config.Fields)
+}
+
+func TestEncodeFields(t *testing.T) {
+	enc := newTestEncoder(t,
+		map[string]interface{}{"name": "message", "number": 1, "type": "string"},
+		map[string]interface{}{"name": "count", "number": 2, "type": "int64"},
+		map[string]interface{}{"name": "delta", "number": 3, "type": "sint32"},
+		map[string]interface{}{"name": "ok", "number": 4, "type": "bool"},
+		map[string]interface{}{"name": "ratio", "number": 5, "type": "double"},
+		map[string]interface{}{"name": "@timestamp", "number": 6, "type": "timestamp"},
+		map[string]interface{}{"name": "@metadata.topic", "number": 7, "type": "string"},
+		map[string]interface{}{"name": "missing", "number": 8, "type": "string"},
+		map[string]interface{}{"name": "ports", "number": 9, "type": "uint32", "repeated": true},
+		map[string]interface{}{"name": "tags", "number": 10, "type": "string", "repeated": true},
+		map[string]interface{}{"name": "host", "number": 11, "type": "json"},
+	)
+
+	out, err := enc.Encode("test", &beat.Event{
+		Timestamp: time.Unix(5, 6),
+		Meta:      common.MapStr{"topic": "t"},
+		Fields: common.MapStr{
+			"message": "hello",
+			"count":   -1,
+			"delta":   -2,
+			"ok":      true,
+			"ratio":   0.5,
+			"ports":   []int{80, 443},
+			"tags":    []string{"a", "b"},
+			"host":    common.MapStr{"name": "h"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := decode(t, out)
+	if !assert.Len(t, fields, 11) {
+		return
+	}
+	assert.Equal(t, "hello", string(fields[0].data))
+	assert.Equal(t, uint64(math.MaxUint64), fields[1].value)
+	assert.Equal(t, uint64(3), fields[2].value)
+	assert.Equal(t, uint64(1), fields[3].value)
+	assert.Equal(t, 0.5, math.Float64frombits(fields[4].value))
+
+	ts := decode(t, fields[5].data)
+	assert.Equal(t, []decodedField{{1, 5, nil}, {2, 6, nil}}, ts)
+
+	assert.Equal(t, 7, fields[6].number)
+	assert.Equal(t, "t", string(fields[6].data))
+	assert.Equal(t, 9, fields[7].number)
+	assert.Equal(t, []byte{80, 0xbb, 0x03}, fields[7].data)
+	assert.Equal(t, "a", string(fields[8].data))
+	assert.Equal(t, "b", string(fields[9].data))
+	assert.Equal(t, `{"name":"h"}`, string(fields[10].data))
+}
+
+func TestEncodeStruct(t *testing.T) {
+	enc := newTestEncoder(t)
+	out, err := enc.Encode("test", &beat.Event{
+		Timestamp: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields: common.MapStr{
+			"message": "hello",
+			"tags":    []string{"a"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries are sorted by key
+	entries := decode(t, out)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	expected := []struct {
+		key   string
+		field int
+	}{
+		{"@timestamp", valueString},
+		{"message", valueString},
+		{"tags", valueList},
+	}
+	for i, entry := range entries {
+		kv := decode(t, entry.data)
+		if !assert.Len(t, kv, 2) {
+			continue
+		}
+		assert.Equal(t, expected[i].key, string(kv[0].data))
+
+		value := decode(t, kv[1].data)
+		if assert.Len(t, value, 1) {
+			assert.Equal(t, expected[i].field, value[0].number)
+		}
+	}
+
+	value := decode(t, decode(t, entries[0].data)[1].data)
+	assert.Equal(t, "2018-01-02T03:04:05Z", string(value[0].data))
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		field map[string]interface{}
+		value interface{}
+	}{
+		"string": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "string"},
+			value: 1,
+		},
+		"int32 overflow": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "int32"},
+			value: int64(1) << 40,
+		},
+		"negative uint": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "uint64"},
+			value: -1,
+		},
+		"timestamp": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "timestamp"},
+			value: "now",
+		},
+		"repeated": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "int64", "repeated": true},
+			value: 1,
+		},
+		"repeated element": {
+			field: map[string]interface{}{"name": "a", "number": 1, "type": "int64", "repeated": true},
+			value: []interface{}{1, "x"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			enc := newTestEncoder(t, test.field)
+			_, err := enc.Encode("test", &beat.Event{Fields: common.MapStr{"a": test.value}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string][]map[string]interface{}{
+		"unknown type": {
+			{"name": "a", "number": 1, "type": "int"},
+		},
+		"duplicate number": {
+			{"name": "a", "number": 1, "type": "string"},
+			{"name": "b", "number": 1, "type": "string"},
+		},
+		"reserved number": {
+			{"name": "a", "number": 19000, "type": "string"},
+		},
+		"number out of range": {
+			{"name": "a", "number": 1 << 29, "type": "string"},
+		},
+	}
+
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(map[string]interface{}{"fields": fields})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Error(t, cfg.Unpack(&config{}))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package codec
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// EventValue returns the value of the field at the given path. Besides the
+// event fields, the `@timestamp` field and fields in `@metadata` can be
+// accessed.
+func EventValue(event *beat.Event, path string) (interface{}, bool) {
+	switch {
+	case path == "@metadata":
+		return event.Meta, event.Meta != nil
+	case strings.HasPrefix(path, "@metadata."):
+		v, err := event.Meta.GetValue(path[len("@metadata."):])
+		return v, err == nil
+	}
+
+	v, err := event.GetValue(path)
+	return v, err == nil
+}
+
+// ToInt64 converts numeric values to int64. Floating point values are only
+// converted if they have no fractional part.
+func ToInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), n <= math.MaxInt64
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float32:
+		return int64(n), float32(int64(n)) == n
+	case float64:
+		return int64(n), float64(int64(n)) == n
+	}
+	return 0, false
+}
+
+// ToFloat64 converts numeric values to float64.
+func ToFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	if i, ok := ToInt64(v); ok {
+		return float64(i), true
+	}
+	if n, ok := v.(uint64); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// ToTime converts time.Time and common.Time values to time.Time.
+func ToTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case common.Time:
+		return time.Time(t), true
+	case *time.Time:
+		return *t, t != nil
+	}
+	return time.Time{}, false
+}
+
+// ToMap converts objects to common.MapStr.
+func ToMap(v interface{}) (common.MapStr, bool) {
+	switch m := v.(type) {
+	case common.MapStr:
+		return m, true
+	case map[string]interface{}:
+		return common.MapStr(m), true
+	}
+	return nil, false
+}
@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestEventValue(t *testing.T) {
+	ts := time.Now()
+	event := &beat.Event{
+		Timestamp: ts,
+		Meta:      common.MapStr{"topic": "t"},
+		Fields:    common.MapStr{"host": common.MapStr{"name": "h"}},
+	}
+
+	tests := []struct {
+		path     string
+		expected interface{}
+		exists   bool
+	}{
+		{"@timestamp", ts, true},
+		{"@metadata.topic", "t", true},
+		{"@metadata.missing", nil, false},
+		{"host.name", "h", true},
+		{"missing", nil, false},
+	}
+
+	for _, test := range tests {
+		v, exists := EventValue(event, test.path)
+		assert.Equal(t, test.exists, exists, test.path)
+		assert.Equal(t, test.expected, v, test.path)
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected int64
+		ok       bool
+	}{
+		{int8(-1), -1, true},
+		{uint32(5), 5, true},
+		{uint64(1) << 63, 0, false},
+		{2.0, 2, true},
+		{2.5, 0, false},
+		{"1", 0, false},
+	}
+
+	for _, test := range tests {
+		i, ok := ToInt64(test.value)
+		assert.Equal(t, test.ok, ok, "%v", test.value)
+		if test.ok {
+			assert.Equal(t, test.expected, i, "%v", test.value)
+		}
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	err := errors.New("unavailable")
+	assert.False(t, IsTemporary(err))
+	assert.True(t, IsTemporary(Temporary(err)))
+	assert.Equal(t, "unavailable", Temporary(err).Error())
+	assert.False(t, IsTemporary(fmt.Errorf("wrapped: %v", Temporary(err))))
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/elastic/beats/libbeat/outputs/transport"
 	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/libbeat/testing"
+	"github.com/elastic/beats/libbeat/tracing"
 )
 
 // Client is an elasticsearch client.
@@ -311,6 +312,9 @@ func (client *Client) publishEvents(
 
 	requ := client.bulkRequ
 	requ.Reset(body)
+	if traceParent := eventsTraceParent(data); traceParent != "" {
+		requ.requ.Header.Set("traceparent", traceParent)
+	}
 	status, result, sendErr := client.sendBulkRequest(requ)
 	if sendErr != nil {
 		logp.Err("Failed to perform any bulk index operations: %s", sendErr)
@@ -355,6 +359,20 @@ func (client *Client) publishEvents(
 
 // fillBulkRequest encodes all bulk requests and returns slice of events
 // successfully added to bulk request.
+// eventsTraceParent returns the trace context of the first sampled event, such
+// that the bulk request can be correlated with the event's trace.
+func eventsTraceParent(data []publisher.Event) string {
+	if !tracing.Enabled() {
+		return ""
+	}
+	for i := range data {
+		if span := data[i].Content.Span; span != nil {
+			return span.TraceParent()
+		}
+	}
+	return ""
+}
+
 func bulkEncodePublishRequest(
 	body bulkWriter,
 	index outil.Selector,
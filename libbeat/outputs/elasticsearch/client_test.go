@@ -36,6 +36,7 @@ import (
 	"github.com/elastic/beats/libbeat/outputs/outest"
 	"github.com/elastic/beats/libbeat/outputs/outil"
 	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/tracing"
 )
 
 func readStatusItem(in []byte) (int, string, error) {
@@ -226,6 +227,57 @@ func TestGetIndexOverwrite(t *testing.T) {
 	assert.Equal(t, expected, index)
 }
 
+func TestGetIndexRouted(t *testing.T) {
+	cfg, err := common.NewConfigFrom(common.MapStr{"index": "beatname"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexSel, err := outil.BuildSelectorFromConfig(cfg, outil.Settings{
+		Key:              "index",
+		MultiKey:         "indices",
+		EnableSingleOnly: true,
+		FailEmpty:        true,
+		RouteName:        "index",
+		ValidateRoute:    validateIndex,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		route    string
+		expected string
+	}{
+		"valid route":   {"logs-tenant-a", "logs-tenant-a"},
+		"invalid route": {"Logs,Tenant", "beatname"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event := &beat.Event{
+				Timestamp: time.Now(),
+				Meta: common.MapStr{
+					"output": common.MapStr{"index": test.route},
+				},
+			}
+			index, err := getIndex(event, indexSel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.expected, index)
+		})
+	}
+}
+
+func TestValidateIndex(t *testing.T) {
+	for _, valid := range []string{"logs", "logs-2018.09.01", "a.b_c+d"} {
+		assert.NoError(t, validateIndex(valid), valid)
+	}
+	for _, invalid := range []string{"", ".", "..", "_logs", "-logs", "Logs", "logs/a", "logs a", "logs#1"} {
+		assert.Error(t, validateIndex(invalid), invalid)
+	}
+}
+
 func BenchmarkCollectPublishFailsNone(b *testing.B) {
 	response := []byte(`
     { "items": [
@@ -379,3 +431,25 @@ func TestAddToURL(t *testing.T) {
 		assert.Equal(t, url, test.expected)
 	}
 }
+
+func TestEventsTraceParent(t *testing.T) {
+	events := []publisher.Event{{Content: beat.Event{}}, {Content: beat.Event{}}}
+	assert.Equal(t, "", eventsTraceParent(events))
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"sampling_rate": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer, err := tracing.New(tracing.Resource{ServiceName: "test"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracing.SetGlobal(tracer)
+	defer tracer.Stop()
+
+	assert.Equal(t, "", eventsTraceParent(events))
+
+	span := tracing.StartEvent("event")
+	events[1].Content.Span = span
+	assert.Equal(t, span.TraceParent(), eventsTraceParent(events))
+}
@@ -20,6 +20,7 @@ package elasticsearch
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/elastic/beats/libbeat/beat"
@@ -47,8 +48,14 @@ var (
 
 	// ErrResponseRead indicates error parsing Elasticsearch response
 	ErrResponseRead = errors.New("bulk item status parse failed")
+
+	errIndexNotLowercase = errors.New("index name must be lowercase")
 )
 
+// maxIndexLength is the maximum length in bytes of an index name accepted by
+// Elasticsearch.
+const maxIndexLength = 255
+
 type callbacksRegistry struct {
 	callbacks []connectCallback
 	mutex     sync.Mutex
@@ -94,6 +101,8 @@ func makeES(
 		MultiKey:         "indices",
 		EnableSingleOnly: true,
 		FailEmpty:        true,
+		RouteName:        "index",
+		ValidateRoute:    validateIndex,
 	})
 	if err != nil {
 		return outputs.Fail(err)
@@ -253,3 +262,27 @@ func NewElasticsearchClients(cfg *common.Config) ([]Client, error) {
 	}
 	return clients, nil
 }
+
+// validateIndex checks an index name read from the event metadata can be
+// used with Elasticsearch.
+func validateIndex(index string) error {
+	if index == "" {
+		return errors.New("index name must not be empty")
+	}
+	if len(index) > maxIndexLength {
+		return fmt.Errorf("index name exceeds %v bytes", maxIndexLength)
+	}
+	if index == "." || index == ".." {
+		return fmt.Errorf("index name must not be '%v'", index)
+	}
+	if strings.ContainsAny(index[:1], "-_+") {
+		return fmt.Errorf("index name must not start with '%v'", index[:1])
+	}
+	if strings.ContainsAny(index, `\/*?"<>| ,#:`) {
+		return errors.New(`index name must not contain any of '\/*?"<>| ,#:'`)
+	}
+	if strings.ToLower(index) != index {
+		return errIndexNotLowercase
+	}
+	return nil
+}
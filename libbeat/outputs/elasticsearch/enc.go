@@ -22,8 +22,10 @@ import (
 	"compress/gzip"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/elastic/go-structform"
 	"github.com/elastic/go-structform/gotype"
 	"github.com/elastic/go-structform/json"
 
@@ -50,15 +52,17 @@ type bulkWriter interface {
 	AddRaw(raw interface{}) error
 }
 
+// jsonEncoder streams bulk items directly into the request body buffer.
 type jsonEncoder struct {
-	buf    *bytes.Buffer
-	folder *gotype.Iterator
+	buf *bytes.Buffer
 }
 
+// gzipEncoder encodes each bulk item into a pooled scratch buffer and streams
+// the item into the gzip writer. The scratch buffer guarantees that an item
+// failing to encode never leaves partial content in the compressed body.
 type gzipEncoder struct {
-	buf    *bytes.Buffer
-	gzip   *gzip.Writer
-	folder *gotype.Iterator
+	buf  *bytes.Buffer
+	gzip *gzip.Writer
 }
 
 type event struct {
@@ -66,23 +70,60 @@ type event struct {
 	Fields    common.MapStr `struct:",inline"`
 }
 
-func newJSONEncoder(buf *bytes.Buffer) *jsonEncoder {
-	if buf == nil {
-		buf = bytes.NewBuffer(nil)
-	}
-	e := &jsonEncoder{buf: buf}
-	e.resetState()
+// itemEncoder serializes bulk items into the buffer it is currently attached
+// to. Item encoders are expensive to create, as the folder caches type
+// information. They are pooled and recycled across batches and clients.
+type itemEncoder struct {
+	out     bufferWriter
+	visitor *json.Visitor
+	folder  *gotype.Iterator
+	scratch bytes.Buffer
+
+	// event is reused for folding beat.Event values, avoiding an allocation
+	// per event
+	event event
+}
+
+// bufferWriter forwards writes to the buffer the item encoder is attached to.
+type bufferWriter struct {
+	buf *bytes.Buffer
+}
+
+// maxPooledScratchSize limits the size of scratch buffers returned to the
+// pool, such that single huge events do not keep memory allocated.
+const maxPooledScratchSize = 1 << 20
+
+var itemEncoderPool = sync.Pool{
+	New: func() interface{} {
+		e := &itemEncoder{}
+		e.resetState()
+		return e
+	},
+}
+
+func getItemEncoder(buf *bytes.Buffer) *itemEncoder {
+	e := itemEncoderPool.Get().(*itemEncoder)
+	e.out.buf = buf
 	return e
 }
 
-func (b *jsonEncoder) Reset() {
-	b.buf.Reset()
+func putItemEncoder(e *itemEncoder) {
+	e.out.buf = nil
+	if e.scratch.Cap() > maxPooledScratchSize {
+		return
+	}
+	e.scratch.Reset()
+	itemEncoderPool.Put(e)
 }
 
-func (b *jsonEncoder) resetState() {
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (e *itemEncoder) resetState() {
 	var err error
-	visitor := json.NewVisitor(b.buf)
-	b.folder, err = gotype.NewIterator(visitor,
+	e.visitor = json.NewVisitor(&e.out)
+	e.folder, err = gotype.NewIterator(e.visitor,
 		gotype.Folders(
 			codec.MakeTimestampEncoder(),
 			codec.MakeBCTimestampEncoder()))
@@ -91,52 +132,116 @@ func (b *jsonEncoder) resetState() {
 	}
 }
 
-func (b *jsonEncoder) AddHeader(header *http.Header) {
-	header.Add("Content-Type", "application/json; charset=UTF-8")
-}
-
-func (b *jsonEncoder) Reader() io.Reader {
-	return b.buf
-}
-
-func (b *jsonEncoder) Marshal(obj interface{}) error {
-	b.Reset()
-	return b.AddRaw(obj)
+// add encodes a bulk item (meta data line and document line). On error the
+// buffer is truncated to its original length.
+func (e *itemEncoder) add(meta, obj interface{}) error {
+	pos := e.out.buf.Len()
+	if err := e.addRaw(meta); err != nil {
+		e.out.buf.Truncate(pos)
+		return err
+	}
+	if err := e.addRaw(obj); err != nil {
+		e.out.buf.Truncate(pos)
+		return err
+	}
+	return nil
 }
 
-func (b *jsonEncoder) AddRaw(obj interface{}) error {
+func (e *itemEncoder) addRaw(obj interface{}) error {
 	var err error
 	switch v := obj.(type) {
 	case beat.Event:
-		err = b.folder.Fold(event{Timestamp: v.Timestamp, Fields: v.Fields})
+		err = e.foldEvent(v.Timestamp, v.Fields)
 	case *beat.Event:
-		err = b.folder.Fold(event{Timestamp: v.Timestamp, Fields: v.Fields})
+		err = e.foldEvent(v.Timestamp, v.Fields)
+	case bulkIndexAction:
+		err = e.addAction("index", &v.Index)
+	case bulkCreateAction:
+		err = e.addAction("create", &v.Create)
 	default:
-		err = b.folder.Fold(obj)
+		err = e.folder.Fold(obj)
 	}
 
 	if err != nil {
-		b.resetState()
+		e.resetState()
+		return err
 	}
 
-	b.buf.WriteByte('\n')
+	e.out.buf.WriteByte('\n')
+	return nil
+}
 
+func (e *itemEncoder) foldEvent(ts time.Time, fields common.MapStr) error {
+	e.event = event{Timestamp: ts, Fields: fields}
+	err := e.folder.Fold(&e.event)
+	e.event = event{}
 	return err
 }
 
-func (b *jsonEncoder) Add(meta, obj interface{}) error {
-	pos := b.buf.Len()
-	if err := b.AddRaw(meta); err != nil {
-		b.buf.Truncate(pos)
-		return err
+// addAction encodes the bulk action meta data without reflection.
+func (e *itemEncoder) addAction(action string, meta *bulkEventMeta) error {
+	vs := e.visitor
+	vs.OnObjectStart(-1, structform.AnyType)
+	vs.OnKey(action)
+	vs.OnObjectStart(-1, structform.AnyType)
+	vs.OnKey("_index")
+	vs.OnString(meta.Index)
+	vs.OnKey("_type")
+	vs.OnString(meta.DocType)
+	if meta.Pipeline != "" {
+		vs.OnKey("pipeline")
+		vs.OnString(meta.Pipeline)
+	}
+	if meta.ID != "" {
+		vs.OnKey("_id")
+		vs.OnString(meta.ID)
 	}
-	if err := b.AddRaw(obj); err != nil {
+	vs.OnObjectFinished()
+	return vs.OnObjectFinished()
+}
+
+func newJSONEncoder(buf *bytes.Buffer) *jsonEncoder {
+	if buf == nil {
+		buf = bytes.NewBuffer(nil)
+	}
+	return &jsonEncoder{buf: buf}
+}
+
+func (b *jsonEncoder) Reset() {
+	b.buf.Reset()
+}
+
+func (b *jsonEncoder) AddHeader(header *http.Header) {
+	header.Add("Content-Type", "application/json; charset=UTF-8")
+}
+
+func (b *jsonEncoder) Reader() io.Reader {
+	return b.buf
+}
+
+func (b *jsonEncoder) Marshal(obj interface{}) error {
+	b.Reset()
+	return b.AddRaw(obj)
+}
+
+func (b *jsonEncoder) AddRaw(obj interface{}) error {
+	enc := getItemEncoder(b.buf)
+	defer putItemEncoder(enc)
+
+	pos := b.buf.Len()
+	if err := enc.addRaw(obj); err != nil {
 		b.buf.Truncate(pos)
 		return err
 	}
 	return nil
 }
 
+func (b *jsonEncoder) Add(meta, obj interface{}) error {
+	enc := getItemEncoder(b.buf)
+	defer putItemEncoder(enc)
+	return enc.add(meta, obj)
+}
+
 func newGzipEncoder(level int, buf *bytes.Buffer) (*gzipEncoder, error) {
 	if buf == nil {
 		buf = bytes.NewBuffer(nil)
@@ -146,21 +251,7 @@ func newGzipEncoder(level int, buf *bytes.Buffer) (*gzipEncoder, error) {
 		return nil, err
 	}
 
-	g := &gzipEncoder{buf: buf, gzip: w}
-	g.resetState()
-	return g, nil
-}
-
-func (g *gzipEncoder) resetState() {
-	var err error
-	visitor := json.NewVisitor(g.gzip)
-	g.folder, err = gotype.NewIterator(visitor,
-		gotype.Folders(
-			codec.MakeTimestampEncoder(),
-			codec.MakeBCTimestampEncoder()))
-	if err != nil {
-		panic(err)
-	}
+	return &gzipEncoder{buf: buf, gzip: w}, nil
 }
 
 func (b *gzipEncoder) Reset() {
@@ -183,42 +274,26 @@ func (b *gzipEncoder) Marshal(obj interface{}) error {
 	return b.AddRaw(obj)
 }
 
-var nl = []byte("\n")
-
 func (b *gzipEncoder) AddRaw(obj interface{}) error {
-	var err error
-	switch v := obj.(type) {
-	case beat.Event:
-		err = b.folder.Fold(event{Timestamp: v.Timestamp, Fields: v.Fields})
-	case *beat.Event:
-		err = b.folder.Fold(event{Timestamp: v.Timestamp, Fields: v.Fields})
-	default:
-		err = b.folder.Fold(obj)
-	}
-
-	if err != nil {
-		b.resetState()
-	}
-
-	_, err = b.gzip.Write(nl)
-	if err != nil {
-		b.resetState()
-	}
-
-	return nil
+	return b.write(func(enc *itemEncoder) error {
+		return enc.addRaw(obj)
+	})
 }
 
 func (b *gzipEncoder) Add(meta, obj interface{}) error {
-	pos := b.buf.Len()
-	if err := b.AddRaw(meta); err != nil {
-		b.buf.Truncate(pos)
-		return err
-	}
-	if err := b.AddRaw(obj); err != nil {
-		b.buf.Truncate(pos)
+	return b.write(func(enc *itemEncoder) error {
+		return enc.add(meta, obj)
+	})
+}
+
+func (b *gzipEncoder) write(fn func(*itemEncoder) error) error {
+	enc := getItemEncoder(nil)
+	defer putItemEncoder(enc)
+
+	enc.out.buf = &enc.scratch
+	if err := fn(enc); err != nil {
 		return err
 	}
-
-	b.gzip.Flush()
-	return nil
+	_, err := b.gzip.Write(enc.scratch.Bytes())
+	return err
 }
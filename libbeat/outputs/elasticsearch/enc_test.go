@@ -18,14 +18,23 @@
 package elasticsearch
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"testing"
 	"time"
 
+	"github.com/elastic/go-structform/gotype"
+	"github.com/elastic/go-structform/json"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/monitoring/report"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/publisher"
 )
 
 func TestJSONEncoderMarshalBeatEvent(t *testing.T) {
@@ -61,3 +70,135 @@ func TestJSONEncoderMarshalMonitoringEvent(t *testing.T) {
 	assert.Equal(t, encoder.buf.String(), "{\"timestamp\":\"2017-11-07T12:00:00.000Z\",\"field1\":\"value1\"}\n",
 		"Unexpected marshaled format of report.Event")
 }
+
+func TestEncodeBulkAction(t *testing.T) {
+	actions := []interface{}{
+		bulkIndexAction{bulkEventMeta{Index: "test", DocType: "doc"}},
+		bulkIndexAction{bulkEventMeta{Index: "test", DocType: "doc", Pipeline: "p\"1"}},
+		bulkCreateAction{bulkEventMeta{Index: "test", DocType: "doc", ID: "abc"}},
+	}
+
+	for _, action := range actions {
+		var expected bytes.Buffer
+		if err := gotype.Fold(action, json.NewVisitor(&expected)); err != nil {
+			t.Fatal(err)
+		}
+		expected.WriteByte('\n')
+
+		encoder := newJSONEncoder(nil)
+		if err := encoder.AddRaw(action); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected.String(), encoder.buf.String())
+	}
+}
+
+func TestBulkEncodersDropFailedItems(t *testing.T) {
+	meta := bulkIndexAction{bulkEventMeta{Index: "test", DocType: "doc"}}
+	valid := beat.Event{
+		Timestamp: time.Date(2017, time.November, 7, 12, 0, 0, 0, time.UTC),
+		Fields:    common.MapStr{"field1": "value1"},
+	}
+	invalid := beat.Event{
+		Timestamp: valid.Timestamp,
+		Fields:    common.MapStr{"field1": make(chan int)},
+	}
+	expected := `{"index":{"_index":"test","_type":"doc"}}` + "\n" +
+		`{"@timestamp":"2017-11-07T12:00:00.000Z","field1":"value1"}` + "\n"
+
+	encoders := map[string]func() (bodyEncoder, error){
+		"json": func() (bodyEncoder, error) { return newJSONEncoder(nil), nil },
+		"gzip": func() (bodyEncoder, error) { return newGzipEncoder(5, nil) },
+	}
+	for name, newEncoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			encoder, err := newEncoder()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NoError(t, encoder.Add(meta, valid))
+			assert.Error(t, encoder.Add(meta, invalid))
+			assert.NoError(t, encoder.Add(meta, valid))
+
+			var r io.Reader = encoder.Reader()
+			if name == "gzip" {
+				r, err = gzip.NewReader(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			body, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, expected+expected, string(body))
+		})
+	}
+}
+
+func makeBenchEvents(n int) []publisher.Event {
+	events := make([]publisher.Event, n)
+	for i := range events {
+		events[i] = publisher.Event{Content: beat.Event{
+			Timestamp: time.Date(2018, time.June, 1, 12, 0, 0, 0, time.UTC),
+			Fields: common.MapStr{
+				"beat": common.MapStr{
+					"name":     "host-a",
+					"hostname": "host-a",
+					"version":  "7.0.0",
+				},
+				"source":     "/var/log/nginx/access.log",
+				"offset":     int64(i * 180),
+				"prospector": common.MapStr{"type": "log"},
+				"message": `10.0.0.1 - - [01/Jun/2018:12:00:00 +0000] "GET /index.html HTTP/1.1" ` +
+					`200 612 "-" "Mozilla/5.0 (X11; Linux x86_64)"`,
+				"fields": common.MapStr{"env": "production", "team": "web"},
+				"tags":   []string{"nginx", "access"},
+			},
+		}}
+	}
+	return events
+}
+
+func benchmarkBulkEncode(b *testing.B, batchSize int, newEncoder func() (bodyEncoder, error)) {
+	enc, err := newEncoder()
+	if err != nil {
+		b.Fatal(err)
+	}
+	index := outil.MakeSelector(outil.ConstSelectorExpr("test"))
+	events := makeBenchEvents(batchSize)
+	batch := make([]publisher.Event, batchSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(batch, events)
+		enc.Reset()
+		if n := len(bulkEncodePublishRequest(enc, index, nil, batch)); n != batchSize {
+			b.Fatalf("expected %v encoded events, got %v", batchSize, n)
+		}
+		if _, err := io.Copy(ioutil.Discard, enc.Reader()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBulkEncode encodes a single batch per iteration. With the default
+// bulk_max_size of 50, 1000 batches per second are required to sustain 50k EPS.
+func BenchmarkBulkEncode(b *testing.B) {
+	for _, batchSize := range []int{50, 2048} {
+		b.Run(fmt.Sprintf("json/%v", batchSize), func(b *testing.B) {
+			benchmarkBulkEncode(b, batchSize, func() (bodyEncoder, error) {
+				return newJSONEncoder(nil), nil
+			})
+		})
+		for _, level := range []int{1, 5} {
+			b.Run(fmt.Sprintf("gzip-%v/%v", level, batchSize), func(b *testing.B) {
+				benchmarkBulkEncode(b, batchSize, func() (bodyEncoder, error) {
+					return newGzipEncoder(level, nil)
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/testing"
+)
+
+// client publishes batches to the output with the highest priority being
+// available. One client is run per output worker.
+type client struct {
+	group   *group
+	clients []*sharedClient // one client per output, in priority order
+	active  int
+}
+
+// sharedClient wraps an output client, such that it can be used by multiple
+// failover clients. The client is connected by the first user and closed
+// once it is not used anymore.
+type sharedClient struct {
+	mu        sync.Mutex
+	client    outputs.Client
+	connected bool
+	users     int
+}
+
+// trackedBatch forwards batch signals, updating the health of the output the
+// batch has been published to.
+type trackedBatch struct {
+	publisher.Batch
+	group  *group
+	member *member
+}
+
+var errNoActiveOutput = errors.New("no active failover output")
+
+func (c *client) Connect() error {
+	c.release()
+
+	i := c.group.selectMember(time.Now())
+	if err := c.clients[i].connect(); err != nil {
+		c.group.members[i].failure(time.Now(), c.group.failoverAfter)
+		return err
+	}
+
+	c.active = i
+	c.group.setActive(i)
+	return nil
+}
+
+func (c *client) Close() error {
+	c.release()
+	return nil
+}
+
+func (c *client) release() {
+	if c.active >= 0 {
+		c.clients[c.active].close()
+		c.active = -1
+	}
+}
+
+func (c *client) Publish(batch publisher.Batch) error {
+	if c.active < 0 {
+		batch.Cancelled()
+		return errNoActiveOutput
+	}
+
+	// switch output on failover or failback
+	if next := c.group.selectMember(time.Now()); next != c.active {
+		if err := c.clients[next].connect(); err != nil {
+			c.group.members[next].failure(time.Now(), c.group.failoverAfter)
+			debugf("Failed to connect to output %v: %v", c.group.members[next].name, err)
+		} else {
+			c.clients[c.active].close()
+			c.active = next
+			c.group.setActive(next)
+		}
+	}
+
+	return c.clients[c.active].publish(&trackedBatch{
+		Batch:  batch,
+		group:  c.group,
+		member: c.group.members[c.active],
+	})
+}
+
+func (c *client) Test(d testing.Driver) {
+	for i, m := range c.group.members {
+		sc := c.clients[i]
+		d.Run(fmt.Sprintf("output %v (%v)", i, m.name), func(d testing.Driver) {
+			t, ok := sc.client.(testing.Testable)
+			if !ok {
+				d.Fatal("output", errors.New("output doesn't support testing"))
+			}
+			t.Test(d)
+		})
+	}
+}
+
+func (s *sharedClient) connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.doConnect(); err != nil {
+		return err
+	}
+	s.users++
+	return nil
+}
+
+func (s *sharedClient) doConnect() error {
+	if s.connected {
+		return nil
+	}
+	if nc, ok := s.client.(outputs.NetworkClient); ok {
+		if err := nc.Connect(); err != nil {
+			return err
+		}
+	}
+	s.connected = true
+	return nil
+}
+
+// close closes the network connection once the client is not used anymore.
+// Non network clients stay open, as they can not be reconnected.
+func (s *sharedClient) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users > 0 {
+		s.users--
+	}
+	if s.users == 0 {
+		s.disconnect()
+	}
+}
+
+func (s *sharedClient) disconnect() {
+	if nc, ok := s.client.(outputs.NetworkClient); ok && s.connected {
+		nc.Close()
+		s.connected = false
+	}
+}
+
+func (s *sharedClient) publish(batch publisher.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// reconnect if the connection has been closed on a publish error of
+	// another user
+	if err := s.doConnect(); err != nil {
+		batch.Retry()
+		return err
+	}
+
+	err := s.client.Publish(batch)
+	if err != nil {
+		s.disconnect()
+	}
+	return err
+}
+
+func (b *trackedBatch) ACK() {
+	b.member.success()
+	b.Batch.ACK()
+}
+
+func (b *trackedBatch) Drop() {
+	b.member.success()
+	b.Batch.Drop()
+}
+
+func (b *trackedBatch) Retry() {
+	b.member.failure(time.Now(), b.group.failoverAfter)
+	b.Batch.Retry()
+}
+
+// RetryEvents records a failure if no event has been published successfully.
+func (b *trackedBatch) RetryEvents(events []publisher.Event) {
+	if len(events) < len(b.Batch.Events()) {
+		b.member.success()
+	} else {
+		b.member.failure(time.Now(), b.group.failoverAfter)
+	}
+	b.Batch.RetryEvents(events)
+}
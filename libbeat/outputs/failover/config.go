@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type failoverConfig struct {
+	Outputs          []common.ConfigNamespace `config:"outputs"           validate:"required"`
+	FailoverAfter    time.Duration            `config:"failover_after"    validate:"min=0"`
+	FailbackInterval time.Duration            `config:"failback_interval" validate:"positive"`
+	MaxRetries       int                      `config:"max_retries"       validate:"min=-1"`
+}
+
+var (
+	defaultConfig = failoverConfig{
+		FailoverAfter:    30 * time.Second,
+		FailbackInterval: 30 * time.Second,
+		MaxRetries:       -1,
+	}
+)
+
+func (c *failoverConfig) Validate() error {
+	if len(c.Outputs) < 2 {
+		return errors.New("failover requires at least two outputs")
+	}
+	for _, out := range c.Outputs {
+		if out.Name() == "failover" {
+			return errors.New("failover outputs can not be nested")
+		}
+	}
+	return nil
+}
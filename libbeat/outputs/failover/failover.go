@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+var debugf = logp.MakeDebug("failover")
+
+func init() {
+	outputs.RegisterType("failover", makeFailover)
+}
+
+// group holds the health state of all outputs configured for failover. The
+// outputs are ordered by priority, the first output being the primary one.
+type group struct {
+	members          []*member
+	failoverAfter    time.Duration
+	failbackInterval time.Duration
+
+	mu     sync.Mutex
+	active int
+}
+
+// member is an output participating in failover. Its health is shared by all
+// failover clients.
+type member struct {
+	name    string
+	clients []*sharedClient
+
+	mu           sync.Mutex
+	healthy      bool
+	failingSince time.Time // start of the current sequence of failures
+	lastProbe    time.Time // last time the output was tried while unhealthy
+	failures     uint64
+	failovers    uint64
+}
+
+func makeFailover(
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	cfgwarn.Beta("The failover output is beta.")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	g := &group{
+		failoverAfter:    config.FailoverAfter,
+		failbackInterval: config.FailbackInterval,
+	}
+
+	var batchSize, numClients int
+	for i, out := range config.Outputs {
+		grp, err := outputs.Load(beat, observer, out.Name(), out.Config())
+		if err != nil {
+			return outputs.Fail(fmt.Errorf("failed to load failover output %v (%v): %v",
+				i, out.Name(), err))
+		}
+		if len(grp.Clients) == 0 {
+			return outputs.Fail(fmt.Errorf("failover output %v (%v) has no clients",
+				i, out.Name()))
+		}
+
+		m := &member{name: out.Name(), healthy: true}
+		for _, client := range grp.Clients {
+			m.clients = append(m.clients, &sharedClient{client: client})
+		}
+		g.members = append(g.members, m)
+
+		if i == 0 {
+			batchSize = grp.BatchSize
+		}
+		if len(grp.Clients) > numClients {
+			numClients = len(grp.Clients)
+		}
+	}
+
+	registerMetrics(g)
+
+	// Each failover client uses an own client of each output if available.
+	// Outputs with less clients share their clients between failover clients.
+	clients := make([]outputs.Client, numClients)
+	for i := range clients {
+		c := &client{group: g, active: -1}
+		for _, m := range g.members {
+			c.clients = append(c.clients, m.clients[i%len(m.clients)])
+		}
+		clients[i] = c
+	}
+
+	return outputs.Group{
+		Clients:   clients,
+		BatchSize: batchSize,
+		Retry:     config.MaxRetries,
+	}, nil
+}
+
+// registerMetrics exposes the health of all outputs in the output monitoring
+// registry, if available.
+func registerMetrics(g *group) {
+	reg := monitoring.Default.GetRegistry("libbeat.output")
+	if reg == nil {
+		return
+	}
+
+	reg.Remove("failover")
+	monitoring.NewFunc(reg, "failover", g.reportMetrics, monitoring.Report)
+}
+
+func (g *group) reportMetrics(_ monitoring.Mode, V monitoring.Visitor) {
+	V.OnRegistryStart()
+	defer V.OnRegistryFinished()
+
+	g.mu.Lock()
+	active := g.active
+	g.mu.Unlock()
+	monitoring.ReportString(V, "active", g.members[active].name)
+
+	monitoring.ReportNamespace(V, "outputs", func() {
+		for i, m := range g.members {
+			m.mu.Lock()
+			healthy, failures, failovers := m.healthy, m.failures, m.failovers
+			m.mu.Unlock()
+
+			monitoring.ReportNamespace(V, strconv.Itoa(i), func() {
+				monitoring.ReportString(V, "type", m.name)
+				reportBool(V, "active", i == active)
+				reportBool(V, "healthy", healthy)
+				monitoring.ReportInt(V, "failures", int64(failures))
+				monitoring.ReportInt(V, "failovers", int64(failovers))
+			})
+		}
+	})
+}
+
+func reportBool(V monitoring.Visitor, name string, value bool) {
+	V.OnKey(name)
+	V.OnBool(value)
+}
+
+// selectMember returns the index of the output with the highest priority
+// available for publishing. Unhealthy outputs are tried again once per
+// failback interval, failing back if they have recovered. If no output is
+// available, the currently active output is returned.
+func (g *group) selectMember(now time.Time) int {
+	for i, m := range g.members {
+		if m.available(now, g.failbackInterval) {
+			return i
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.active
+}
+
+func (g *group) setActive(i int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.active != i {
+		logp.Info("Failover: switching from output %v (%v) to output %v (%v)",
+			g.active, g.members[g.active].name, i, g.members[i].name)
+		g.active = i
+	}
+}
+
+func (m *member) available(now time.Time, failbackInterval time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.healthy {
+		return true
+	}
+	if now.Sub(m.lastProbe) >= failbackInterval {
+		m.lastProbe = now
+		debugf("Trying unhealthy output %v for recovery", m.name)
+		return true
+	}
+	return false
+}
+
+// success marks the output as healthy.
+func (m *member) success() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.healthy {
+		logp.Info("Failover: output %v recovered", m.name)
+	}
+	m.healthy = true
+	m.failingSince = time.Time{}
+}
+
+// failure records a failure. The output becomes unhealthy if it has been
+// failing since at least failoverAfter.
+func (m *member) failure(now time.Time, failoverAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures++
+	if m.failingSince.IsZero() {
+		m.failingSince = now
+	}
+	if m.healthy && now.Sub(m.failingSince) >= failoverAfter {
+		logp.Warn("Failover: output %v is unhealthy, failing since %v",
+			m.name, m.failingSince)
+		m.healthy = false
+		m.lastProbe = now
+		m.failovers++
+	}
+}
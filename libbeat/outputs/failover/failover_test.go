@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package failover
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// mockClient is a network client failing to connect and publish while down.
+type mockClient struct {
+	mu        sync.Mutex
+	down      bool
+	connected bool
+	published int
+}
+
+var mockClients = map[string]*mockClient{}
+
+func init() {
+	outputs.RegisterType("failover_test", func(
+		_ beat.Info,
+		_ outputs.Observer,
+		cfg *common.Config,
+	) (outputs.Group, error) {
+		config := struct {
+			ID string `config:"id"`
+		}{}
+		if err := cfg.Unpack(&config); err != nil {
+			return outputs.Fail(err)
+		}
+		return outputs.Success(10, 0, mockClients[config.ID])
+	})
+}
+
+func (c *mockClient) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *mockClient) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return errors.New("connection refused")
+	}
+	c.connected = true
+	return nil
+}
+
+func (c *mockClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+	return nil
+}
+
+func (c *mockClient) Publish(batch publisher.Batch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down || !c.connected {
+		batch.Retry()
+		return errors.New("connection lost")
+	}
+	c.published += len(batch.Events())
+	batch.ACK()
+	return nil
+}
+
+func newTestGroup(t *testing.T, settings map[string]interface{}) (outputs.Group, *mockClient, *mockClient) {
+	primary, fallback := &mockClient{}, &mockClient{}
+	mockClients["primary"] = primary
+	mockClients["fallback"] = fallback
+
+	settings["outputs"] = []map[string]interface{}{
+		{"failover_test": map[string]interface{}{"id": "primary"}},
+		{"failover_test": map[string]interface{}{"id": "fallback"}},
+	}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grp, err := makeFailover(beat.Info{}, outputs.NewNilObserver(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grp.Clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(grp.Clients))
+	}
+	return grp, primary, fallback
+}
+
+func publish(client outputs.NetworkClient) (*outest.Batch, error) {
+	batch := outest.NewBatch(beat.Event{Fields: common.MapStr{"message": "test"}})
+	return batch, client.Publish(batch)
+}
+
+func TestConfigValidation(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"outputs": []map[string]interface{}{
+			{"failover_test": map[string]interface{}{"id": "primary"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = makeFailover(beat.Info{}, outputs.NewNilObserver(), cfg)
+	assert.Error(t, err)
+}
+
+func TestPublishPrimary(t *testing.T) {
+	grp, primary, fallback := newTestGroup(t, map[string]interface{}{})
+	assert.Equal(t, 10, grp.BatchSize)
+	assert.Equal(t, -1, grp.Retry)
+
+	client := grp.Clients[0].(outputs.NetworkClient)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := publish(client)
+	assert.NoError(t, err)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	assert.Equal(t, 1, primary.published)
+	assert.Equal(t, 0, fallback.published)
+}
+
+func TestFailoverAndFailback(t *testing.T) {
+	grp, primary, fallback := newTestGroup(t, map[string]interface{}{
+		"failover_after":    0,
+		"failback_interval": "50ms",
+	})
+	client := grp.Clients[0].(outputs.NetworkClient)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// primary fails, marking it as unhealthy
+	primary.setDown(true)
+	batch, err := publish(client)
+	assert.Error(t, err)
+	assert.Equal(t, outest.BatchRetry, batch.Signals[0].Tag)
+
+	// the pipeline reconnects, selecting the fallback output
+	assert.NoError(t, client.Connect())
+	_, err = publish(client)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fallback.published)
+
+	// primary is still down when retried after the failback interval
+	time.Sleep(60 * time.Millisecond)
+	_, err = publish(client)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fallback.published)
+
+	// primary recovers and is used again after the failback interval
+	primary.setDown(false)
+	time.Sleep(60 * time.Millisecond)
+	_, err = publish(client)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.published)
+
+	_, err = publish(client)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, primary.published)
+	assert.Equal(t, 2, fallback.published)
+	assert.False(t, fallback.connected)
+}
+
+func TestFailoverAfter(t *testing.T) {
+	grp, primary, fallback := newTestGroup(t, map[string]interface{}{
+		"failover_after": "1h",
+	})
+	client := grp.Clients[0].(outputs.NetworkClient)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// failures shorter than failover_after do not trigger failover
+	primary.setDown(true)
+	publish(client)
+	assert.Error(t, client.Connect())
+	assert.Error(t, client.Connect())
+	assert.Equal(t, 0, fallback.published)
+}
+
+func TestReportMetrics(t *testing.T) {
+	grp, primary, _ := newTestGroup(t, map[string]interface{}{"failover_after": 0})
+	client := grp.Clients[0].(*client)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	primary.setDown(true)
+	publish(client)
+	assert.NoError(t, client.Connect())
+
+	reg := monitoring.NewRegistry()
+	monitoring.NewFunc(reg, "failover", client.group.reportMetrics, monitoring.Report)
+	snapshot := monitoring.CollectStructSnapshot(reg, monitoring.Full, false)
+
+	assert.Equal(t, map[string]interface{}{
+		"failover": map[string]interface{}{
+			"active": "failover_test",
+			"outputs": map[string]interface{}{
+				"0": map[string]interface{}{
+					"type":      "failover_test",
+					"active":    false,
+					"healthy":   false,
+					"failures":  int64(1),
+					"failovers": int64(1),
+				},
+				"1": map[string]interface{}{
+					"type":      "failover_test",
+					"active":    true,
+					"healthy":   true,
+					"failures":  int64(0),
+					"failovers": int64(0),
+				},
+			},
+		},
+	}, snapshot)
+}
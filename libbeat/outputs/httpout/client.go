@@ -0,0 +1,432 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/testing"
+)
+
+type client struct {
+	url    string
+	query  string
+	path   outil.Selector
+	format string
+
+	username, password string
+	bearerToken        string
+	headers            map[string]string
+
+	index    string
+	codec    codec.Codec
+	observer outputs.Observer
+
+	http      *http.Client
+	tlsConfig *transport.TLSConfig
+	timeout   time.Duration
+
+	// reusable request buffers
+	body             bytes.Buffer
+	compressed       bytes.Buffer
+	gzip             *gzip.Writer
+	compressionLevel int
+}
+
+type clientSettings struct {
+	URL                string
+	Path               outil.Selector
+	Proxy              *url.URL
+	TLS                *transport.TLSConfig
+	Username, Password string
+	BearerToken        string
+	Parameters         map[string]string
+	Headers            map[string]string
+	Format             string
+	Timeout            time.Duration
+	CompressionLevel   int
+	Index              string
+	Codec              codec.Codec
+	Observer           outputs.Observer
+}
+
+type partition struct {
+	path   string
+	events []publisher.Event
+}
+
+type publishStats struct {
+	acked   int // number of events accepted by the endpoint
+	fails   int // number of events failed with a retryable status (can be retried)
+	dropped int // number of events rejected by the endpoint or not encodable
+}
+
+var errTempFailure = errors.New("temporary http send failure")
+
+func newClient(s clientSettings) (*client, error) {
+	proxy := http.ProxyFromEnvironment
+	if s.Proxy != nil {
+		proxy = http.ProxyURL(s.Proxy)
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse http output URL: %v", err)
+	}
+	if u.User != nil {
+		s.Username = u.User.Username()
+		s.Password, _ = u.User.Password()
+		u.User = nil
+	}
+
+	query := u.Query()
+	for k, v := range s.Parameters {
+		query.Set(k, v)
+	}
+	u.RawQuery = ""
+	s.URL = strings.TrimSuffix(u.String(), "/")
+
+	logp.Info("HTTP output url: %s", s.URL)
+
+	dialer := transport.NetDialer(s.Timeout)
+	tlsDialer, err := transport.TLSDialer(dialer, s.TLS, s.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if st := s.Observer; st != nil {
+		dialer = transport.StatsDialer(dialer, st)
+		tlsDialer = transport.StatsDialer(tlsDialer, st)
+	}
+
+	c := &client{
+		url:         s.URL,
+		query:       query.Encode(),
+		path:        s.Path,
+		format:      s.Format,
+		username:    s.Username,
+		password:    s.Password,
+		bearerToken: s.BearerToken,
+		headers:     s.Headers,
+		index:       s.Index,
+		codec:       s.Codec,
+		observer:    s.Observer,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Dial:    dialer.Dial,
+				DialTLS: tlsDialer.Dial,
+				Proxy:   proxy,
+			},
+			Timeout: s.Timeout,
+		},
+		tlsConfig:        s.TLS,
+		timeout:          s.Timeout,
+		compressionLevel: s.CompressionLevel,
+	}
+
+	if s.CompressionLevel > 0 {
+		c.gzip, err = gzip.NewWriterLevel(&c.compressed, s.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Connect is a no-op. Connections are established on the first request.
+func (c *client) Connect() error {
+	return nil
+}
+
+// Close closes all idle connections to the endpoint.
+func (c *client) Close() error {
+	if t, ok := c.http.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *client) Publish(batch publisher.Batch) error {
+	events := batch.Events()
+	rest, err := c.publishEvents(events)
+	if len(rest) == 0 {
+		batch.ACK()
+	} else {
+		batch.RetryEvents(rest)
+	}
+	return err
+}
+
+// publishEvents sends all events to the configured endpoint. Events are
+// partitioned by the resolved path and one request is sent per partition. On
+// error a slice with all events that must be retried is returned.
+func (c *client) publishEvents(data []publisher.Event) ([]publisher.Event, error) {
+	begin := time.Now()
+	st := c.observer
+
+	if st != nil {
+		st.NewBatch(len(data))
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var stats publishStats
+	var failed []publisher.Event
+	var lastErr error
+
+	parts, dropped := c.partition(data)
+	stats.dropped += dropped
+	for _, part := range parts {
+		rest, partStats, err := c.publishPartition(part)
+		stats.acked += partStats.acked
+		stats.fails += partStats.fails
+		stats.dropped += partStats.dropped
+		if err != nil {
+			lastErr = err
+			failed = append(failed, rest...)
+		}
+	}
+
+	if st != nil {
+		st.Acked(stats.acked)
+		st.Failed(stats.fails)
+		st.Dropped(stats.dropped)
+	}
+
+	debugf("PublishEvents: %d events have been published in %v.",
+		stats.acked, time.Now().Sub(begin))
+
+	if len(failed) > 0 {
+		return failed, lastErr
+	}
+	return nil, nil
+}
+
+// partition groups events by their target path, keeping the order in which
+// paths are first seen. Events for which no path can be resolved are dropped.
+func (c *client) partition(data []publisher.Event) ([]partition, int) {
+	if c.path.IsEmpty() || c.path.IsConst() {
+		path, _ := c.path.Select(nil)
+		return []partition{{path: path, events: data}}, 0
+	}
+
+	var parts []partition
+	index := map[string]int{}
+	dropped := 0
+	for _, event := range data {
+		path, err := c.path.Select(&event.Content)
+		if err != nil {
+			logp.Err("Dropping event: failed to select path: %v", err)
+			dropped++
+			continue
+		}
+
+		i, exists := index[path]
+		if !exists {
+			i = len(parts)
+			index[path] = i
+			parts = append(parts, partition{path: path})
+		}
+		parts[i].events = append(parts[i].events, event)
+	}
+	return parts, dropped
+}
+
+func (c *client) publishPartition(part partition) ([]publisher.Event, publishStats, error) {
+	var stats publishStats
+
+	events := c.encodeEvents(part.events)
+	stats.dropped = len(part.events) - len(events)
+	if len(events) == 0 {
+		return nil, stats, nil
+	}
+
+	status, resp, err := c.send(part.path)
+	switch {
+	case err != nil:
+		logp.Err("Failed to publish events: %v", err)
+		stats.fails = len(events)
+		return events, stats, err
+
+	case status < 300:
+		stats.acked = len(events)
+		return nil, stats, nil
+
+	case status == http.StatusTooManyRequests || status >= 500:
+		logp.Warn("Failed to publish events, will retry: %d: %s", status, resp)
+		stats.fails = len(events)
+		return events, stats, errTempFailure
+
+	default:
+		logp.Err("Dropping %d events rejected by the endpoint: %d: %s",
+			len(events), status, resp)
+		stats.dropped += len(events)
+		return nil, stats, nil
+	}
+}
+
+// encodeEvents encodes events into the request body, returning the events
+// successfully added to the body.
+func (c *client) encodeEvents(data []publisher.Event) []publisher.Event {
+	c.body.Reset()
+
+	if c.format == formatJSONArray {
+		c.body.WriteByte('[')
+	}
+
+	okEvents := data[:0]
+	for i := range data {
+		event := &data[i]
+		serialized, err := c.codec.Encode(c.index, &event.Content)
+		if err != nil {
+			if event.Guaranteed() {
+				logp.Critical("Failed to serialize the event: %v", err)
+			} else {
+				logp.Warn("Failed to serialize the event: %v", err)
+			}
+			continue
+		}
+
+		if c.format == formatJSONArray && len(okEvents) > 0 {
+			c.body.WriteByte(',')
+		}
+		c.body.Write(serialized)
+		if c.format == formatNDJSON {
+			c.body.WriteByte('\n')
+		}
+		okEvents = append(okEvents, *event)
+	}
+
+	if c.format == formatJSONArray {
+		c.body.WriteByte(']')
+	}
+	return okEvents
+}
+
+func (c *client) send(path string) (int, []byte, error) {
+	var body io.Reader = &c.body
+	if c.gzip != nil {
+		c.compressed.Reset()
+		c.gzip.Reset(&c.compressed)
+		if _, err := c.gzip.Write(c.body.Bytes()); err != nil {
+			return 0, nil, err
+		}
+		if err := c.gzip.Close(); err != nil {
+			return 0, nil, err
+		}
+		body = &c.compressed
+	}
+
+	req, err := http.NewRequest("POST", c.requestURL(path), body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if c.format == formatNDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.gzip != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	obj, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, obj, err
+}
+
+func (c *client) requestURL(path string) string {
+	u := c.url
+	if path != "" {
+		u += "/" + strings.TrimPrefix(path, "/")
+	}
+	if c.query != "" {
+		u += "?" + c.query
+	}
+	return u
+}
+
+func (c *client) Test(d testing.Driver) {
+	d.Run("http: "+c.url, func(d testing.Driver) {
+		u, err := url.Parse(c.url)
+		d.Fatal("parse url", err)
+
+		address := u.Hostname()
+		if u.Port() != "" {
+			address += ":" + u.Port()
+		} else if u.Scheme == "https" {
+			address += ":443"
+		} else {
+			address += ":80"
+		}
+		d.Run("connection", func(d testing.Driver) {
+			netDialer := transport.TestNetDialer(d, c.timeout)
+			_, err = netDialer.Dial("tcp", address)
+			d.Fatal("dial up", err)
+		})
+
+		if u.Scheme != "https" {
+			d.Warn("TLS", "secure connection disabled")
+		} else {
+			d.Run("TLS", func(d testing.Driver) {
+				netDialer := transport.NetDialer(c.timeout)
+				tlsDialer, err := transport.TestTLSDialer(d, netDialer, c.tlsConfig, c.timeout)
+				_, err = tlsDialer.Dial("tcp", address)
+				d.Fatal("dial up", err)
+			})
+		}
+	})
+}
@@ -0,0 +1,299 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	_ "github.com/elastic/beats/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+)
+
+type recordedRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   []byte
+}
+
+type recorder struct {
+	sync.Mutex
+	status   int
+	requests []recordedRequest
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err == nil {
+			body, _ = ioutil.ReadAll(zr)
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.requests = append(r.requests, recordedRequest{
+		path:   req.URL.Path,
+		query:  req.URL.RawQuery,
+		header: req.Header,
+		body:   body,
+	})
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func newTestClient(t *testing.T, url string, settings map[string]interface{}) outputs.NetworkClient {
+	settings["hosts"] = []string{url}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grp, err := makeHTTP(beat.Info{Beat: "test"}, outputs.NewNilObserver(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grp.Clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(grp.Clients))
+	}
+
+	// unwrap the backoff client, so tests are not delayed by errors
+	return grp.Clients[0].(interface {
+		Client() outputs.NetworkClient
+	}).Client()
+}
+
+func testEvents(tenants ...string) []beat.Event {
+	events := make([]beat.Event, len(tenants))
+	for i, tenant := range tenants {
+		events[i] = beat.Event{
+			Timestamp: time.Now(),
+			Fields: common.MapStr{
+				"message": "hello",
+				"tenant":  tenant,
+			},
+		}
+	}
+	return events
+}
+
+func TestPublishNDJSON(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, map[string]interface{}{
+		"path":       "/ingest",
+		"parameters": map[string]interface{}{"source": "beats"},
+		"headers":    map[string]interface{}{"X-Test": "yes"},
+	})
+
+	batch := outest.NewBatch(testEvents("a", "b", "c")...)
+	if err := client.Publish(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, batch.Signals, 1) {
+		return
+	}
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	if !assert.Len(t, rec.requests, 1) {
+		return
+	}
+	requ := rec.requests[0]
+	assert.Equal(t, "/ingest", requ.path)
+	assert.Equal(t, "source=beats", requ.query)
+	assert.Equal(t, "yes", requ.header.Get("X-Test"))
+	assert.Equal(t, "application/x-ndjson", requ.header.Get("Content-Type"))
+
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(requ.body))
+	for scanner.Scan() {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "hello", doc["message"])
+		lines++
+	}
+	assert.Equal(t, 3, lines)
+}
+
+func TestPublishJSONArrayCompressed(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, map[string]interface{}{
+		"format":            "json_array",
+		"compression_level": 5,
+	})
+
+	batch := outest.NewBatch(testEvents("a", "b")...)
+	if err := client.Publish(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, rec.requests, 1) {
+		return
+	}
+	requ := rec.requests[0]
+	assert.Equal(t, "gzip", requ.header.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", requ.header.Get("Content-Type"))
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(requ.body, &docs); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, docs, 2)
+}
+
+func TestPublishAuth(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]interface{}
+		expected string
+	}{
+		"basic": {
+			settings: map[string]interface{}{"username": "user", "password": "secret"},
+			expected: "Basic dXNlcjpzZWNyZXQ=",
+		},
+		"bearer": {
+			settings: map[string]interface{}{"bearer_token": "token"},
+			expected: "Bearer token",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			client := newTestClient(t, server.URL, test.settings)
+			if err := client.Publish(outest.NewBatch(testEvents("a")...)); err != nil {
+				t.Fatal(err)
+			}
+
+			if !assert.Len(t, rec.requests, 1) {
+				return
+			}
+			assert.Equal(t, test.expected, rec.requests[0].header.Get("Authorization"))
+		})
+	}
+}
+
+func TestPublishStatusHandling(t *testing.T) {
+	tests := []struct {
+		status int
+		retry  bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusAccepted, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusBadRequest, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			rec := &recorder{status: test.status}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			client := newTestClient(t, server.URL, map[string]interface{}{})
+			batch := outest.NewBatch(testEvents("a", "b")...)
+			err := client.Publish(batch)
+
+			if !assert.Len(t, batch.Signals, 1) {
+				return
+			}
+			if test.retry {
+				assert.Error(t, err)
+				assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+				assert.Len(t, batch.Signals[0].Events, 2)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+			}
+		})
+	}
+}
+
+func TestPublishPartitionedByPath(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, map[string]interface{}{
+		"path": "/tenants/%{[tenant]}",
+	})
+
+	batch := outest.NewBatch(testEvents("a", "b", "a")...)
+	if err := client.Publish(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, rec.requests, 2) {
+		return
+	}
+	assert.Equal(t, "/tenants/a", rec.requests[0].path)
+	assert.Equal(t, 2, bytes.Count(rec.requests[0].body, []byte("\n")))
+	assert.Equal(t, "/tenants/b", rec.requests[1].path)
+	assert.Equal(t, 1, bytes.Count(rec.requests[1].body, []byte("\n")))
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid format": {
+			"format": "xml",
+		},
+		"bearer and basic auth": {
+			"bearer_token": "token",
+			"username":     "user",
+		},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
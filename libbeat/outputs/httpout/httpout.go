@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+)
+
+func init() {
+	outputs.RegisterType("http", makeHTTP)
+}
+
+var debugf = logp.MakeDebug("http")
+
+func makeHTTP(
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	cfgwarn.Beta("The http output is beta.")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	// The optional path is a format string, so events can be partitioned to
+	// different endpoints based on their contents.
+	path, err := outil.BuildSelectorFromConfig(cfg, outil.Settings{
+		Key:              "path",
+		MultiKey:         "paths",
+		EnableSingleOnly: true,
+		FailEmpty:        false,
+	})
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tlsConfig, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	proxyURL, err := parseProxyURL(config.ProxyURL)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	if proxyURL != nil {
+		logp.Info("Using proxy URL: %s", proxyURL)
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		hostURL, err := common.MakeURL(config.Protocol, "", host, defaultPort(config.Protocol, host))
+		if err != nil {
+			logp.Err("Invalid host param set: %s, Error: %v", host, err)
+			return outputs.Fail(err)
+		}
+
+		enc, err := codec.CreateEncoder(beat, config.Codec)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		var client outputs.NetworkClient
+		client, err = newClient(clientSettings{
+			URL:              hostURL,
+			Path:             path,
+			Proxy:            proxyURL,
+			TLS:              tlsConfig,
+			Username:         config.Username,
+			Password:         config.Password,
+			BearerToken:      config.BearerToken,
+			Parameters:       config.Params,
+			Headers:          config.Headers,
+			Format:           config.Format,
+			Timeout:          config.Timeout,
+			CompressionLevel: config.CompressionLevel,
+			Index:            beat.Beat,
+			Codec:            enc,
+			Observer:         observer,
+		})
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		client = outputs.WithBackoff(client, config.Backoff.Init, config.Backoff.Max)
+		clients[i] = client
+	}
+
+	return outputs.SuccessNet(config.LoadBalance, config.BulkMaxSize, config.MaxRetries, clients)
+}
+
+func defaultPort(protocol, host string) int {
+	if protocol == "https" || strings.HasPrefix(host, "https://") {
+		return 443
+	}
+	return 80
+}
@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 
+	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/common/fmtstr"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
@@ -38,12 +40,18 @@ type client struct {
 	hosts    []string
 	topic    outil.Selector
 	key      *fmtstr.EventFormatString
+	headers  []headerConfig
 	index    string
 	codec    codec.Codec
 	config   sarama.Config
 
 	producer sarama.AsyncProducer
 
+	// codecBackoff delays the retry of events failing to encode because of a
+	// temporary codec error, like an unavailable schema registry
+	done         chan struct{}
+	codecBackoff *common.Backoff
+
 	wg sync.WaitGroup
 }
 
@@ -61,11 +69,17 @@ var (
 	errNoTopicsSelected = errors.New("no topic could be selected")
 )
 
+const (
+	codecBackoffInit = 1 * time.Second
+	codecBackoffMax  = 60 * time.Second
+)
+
 func newKafkaClient(
 	observer outputs.Observer,
 	hosts []string,
 	index string,
 	key *fmtstr.EventFormatString,
+	headers []headerConfig,
 	topic outil.Selector,
 	writer codec.Codec,
 	cfg *sarama.Config,
@@ -75,6 +89,7 @@ func newKafkaClient(
 		hosts:    hosts,
 		topic:    topic,
 		key:      key,
+		headers:  headers,
 		index:    index,
 		codec:    writer,
 		config:   *cfg,
@@ -93,6 +108,8 @@ func (c *client) Connect() error {
 	}
 
 	c.producer = producer
+	c.done = make(chan struct{})
+	c.codecBackoff = common.NewBackoff(c.done, codecBackoffInit, codecBackoffMax)
 
 	c.wg.Add(2)
 	go c.successWorker(producer.Successes())
@@ -104,6 +121,7 @@ func (c *client) Connect() error {
 func (c *client) Close() error {
 	debugf("closed kafka client")
 
+	close(c.done)
 	c.producer.AsyncClose()
 	c.wg.Wait()
 	c.producer = nil
@@ -123,9 +141,16 @@ func (c *client) Publish(batch publisher.Batch) error {
 	}
 
 	ch := c.producer.Input()
+	var temporary []*message
+	var temporaryErr error
 	for i := range events {
 		d := &events[i]
 		msg, err := c.getEventMessage(d)
+		if err != nil && codec.IsTemporary(err) {
+			temporary = append(temporary, &message{data: *d})
+			temporaryErr = err
+			continue
+		}
 		if err != nil {
 			logp.Err("Dropping event: %v", err)
 			ref.done()
@@ -138,6 +163,18 @@ func (c *client) Publish(batch publisher.Batch) error {
 		ch <- &msg.msg
 	}
 
+	if len(temporary) == 0 {
+		c.codecBackoff.Reset()
+		return nil
+	}
+
+	// Wait before the events are returned for retry, so they are not encoded
+	// again immediately while the codec is failing.
+	logp.Warn("Failed to encode %d events, will retry: %v", len(temporary), temporaryErr)
+	c.codecBackoff.Wait()
+	for _, msg := range temporary {
+		ref.fail(msg, temporaryErr)
+	}
 	return nil
 }
 
@@ -192,6 +229,21 @@ func (c *client) getEventMessage(data *publisher.Event) (*message, error) {
 		}
 	}
 
+	if len(c.headers) > 0 {
+		msg.headers = make([]sarama.RecordHeader, 0, len(c.headers))
+		for _, h := range c.headers {
+			value, err := h.Value.RunBytes(event)
+			if err != nil {
+				debugf("Skipping kafka header '%v': %v", h.Key, err)
+				continue
+			}
+			msg.headers = append(msg.headers, sarama.RecordHeader{
+				Key:   []byte(h.Key),
+				Value: value,
+			})
+		}
+	}
+
 	return msg, nil
 }
 
@@ -0,0 +1,134 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+	"github.com/elastic/beats/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+type temporaryFailingCodec struct{}
+
+func (temporaryFailingCodec) Encode(string, *beat.Event) ([]byte, error) {
+	return nil, codec.Temporary(errors.New("schema registry unavailable"))
+}
+
+func TestEventMessageHeaders(t *testing.T) {
+	c, err := common.NewConfigFrom(common.MapStr{
+		"hosts": []string{"localhost"},
+		"headers": []common.MapStr{
+			{"key": "tenant", "value": "%{[fields.tenant]}"},
+			{"key": "missing", "value": "%{[fields.missing]}"},
+			{"key": "source", "value": "beats"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	if err := c.Unpack(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	libCfg, err := newSaramaConfig(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := newKafkaClient(outputs.NewNilObserver(), cfg.Hosts, "test",
+		nil, cfg.Headers, outil.MakeSelector(outil.ConstSelectorExpr("topic")),
+		json.New(false, "1.0.0"), libCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := client.getEventMessage(&publisher.Event{Content: beat.Event{
+		Timestamp: time.Now(),
+		Fields: common.MapStr{
+			"fields": common.MapStr{"tenant": "acme"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg.initProducerMessage()
+	assert.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("tenant"), Value: []byte("acme")},
+		{Key: []byte("source"), Value: []byte("beats")},
+	}, msg.msg.Headers)
+}
+
+func TestPublishTemporaryCodecErrorBackoff(t *testing.T) {
+	client, err := newKafkaClient(outputs.NewNilObserver(), []string{"localhost"}, "test",
+		nil, nil, outil.MakeSelector(outil.ConstSelectorExpr("topic")),
+		temporaryFailingCodec{}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer := mocks.NewAsyncProducer(t, nil)
+	defer producer.Close()
+	client.producer = producer
+	client.done = make(chan struct{})
+	client.codecBackoff = common.NewBackoff(client.done, 10*time.Millisecond, 20*time.Millisecond)
+
+	event := beat.Event{Timestamp: time.Now(), Fields: common.MapStr{"message": "hello"}}
+	for _, minWait := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond} {
+		batch := outest.NewBatch(event, event)
+		start := time.Now()
+		assert.NoError(t, client.Publish(batch))
+		assert.True(t, time.Since(start) >= minWait)
+
+		if assert.Len(t, batch.Signals, 1) {
+			assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+			assert.Len(t, batch.Signals[0].Events, 2)
+		}
+	}
+
+	// closing the client stops waiting
+	client.codecBackoff = common.NewBackoff(client.done, time.Hour, time.Hour)
+	close(client.done)
+	start := time.Now()
+	assert.NoError(t, client.Publish(outest.NewBatch(event)))
+	assert.True(t, time.Since(start) < time.Minute)
+}
+
+func TestValidateTopic(t *testing.T) {
+	for _, valid := range []string{"logs", "logs.tenant_a-1", "LOGS"} {
+		assert.NoError(t, validateTopic(valid), valid)
+	}
+	for _, invalid := range []string{".", "..", "logs/a", "logs a", "logs#1", strings.Repeat("a", 250)} {
+		assert.Error(t, validateTopic(invalid), invalid)
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/common/fmtstr"
+	commonkafka "github.com/elastic/beats/libbeat/common/kafka"
 	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/monitoring"
@@ -55,6 +56,12 @@ type kafkaConfig struct {
 	Username        string                    `config:"username"`
 	Password        string                    `config:"password"`
 	Codec           codec.Config              `config:"codec"`
+	Headers         []headerConfig            `config:"headers"`
+}
+
+type headerConfig struct {
+	Key   string                    `config:"key"   validate:"required"`
+	Value *fmtstr.EventFormatString `config:"value" validate:"required"`
 }
 
 type metaConfig struct {
@@ -112,7 +119,8 @@ func (c *kafkaConfig) Validate() error {
 		return fmt.Errorf("compression mode '%v' unknown", c.Compression)
 	}
 
-	if _, ok := kafkaVersions[c.Version]; !ok {
+	version, ok := commonkafka.ParseVersion(c.Version)
+	if !ok {
 		return fmt.Errorf("unknown/unsupported kafka version '%v'", c.Version)
 	}
 
@@ -120,6 +128,11 @@ func (c *kafkaConfig) Validate() error {
 		return fmt.Errorf("password must be set when username is configured")
 	}
 
+	// record headers have been added to kafka with version 0.11.0.0
+	if len(c.Headers) > 0 && !version.IsAtLeast(sarama.V0_11_0_0) {
+		return fmt.Errorf("headers require kafka version 0.11 or newer, but version '%v' is configured", c.Version)
+	}
+
 	return nil
 }
 
@@ -190,7 +203,7 @@ func newSaramaConfig(config *kafkaConfig) (*sarama.Config, error) {
 	// configure client ID
 	k.ClientID = config.ClientID
 
-	version, ok := kafkaVersions[config.Version]
+	version, ok := commonkafka.ParseVersion(config.Version)
 	if !ok {
 		return nil, fmt.Errorf("Unknown/unsupported kafka version: %v", config.Version)
 	}
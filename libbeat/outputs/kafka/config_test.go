@@ -34,6 +34,12 @@ func TestConfigAcceptValid(t *testing.T) {
 			"compression": "lz4",
 			"version":     "1.0.0",
 		},
+		"headers with 0.11": common.MapStr{
+			"version": "0.11",
+			"headers": []common.MapStr{
+				{"key": "tenant", "value": "%{[fields.tenant]}"},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -56,3 +62,35 @@ func TestConfigAcceptValid(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigInvalid(t *testing.T) {
+	tests := map[string]common.MapStr{
+		"headers with 0.10": common.MapStr{
+			"version": "0.10",
+			"headers": []common.MapStr{
+				{"key": "tenant", "value": "%{[fields.tenant]}"},
+			},
+		},
+		"header without value": common.MapStr{
+			"headers": []common.MapStr{
+				{"key": "tenant"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test)
+			if err != nil {
+				t.Fatalf("Can not create test configuration: %v", err)
+			}
+			c.SetString("hosts", 0, "localhost")
+
+			cfg := defaultConfig()
+			if err := c.Unpack(&cfg); err == nil {
+				t.Fatal("Expected unpacking configuration to fail")
+			}
+		})
+	}
+}
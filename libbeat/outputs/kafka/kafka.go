@@ -19,6 +19,7 @@ package kafka
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -56,8 +57,13 @@ var debugf = logp.MakeDebug("kafka")
 var (
 	errNoTopicSet = errors.New("No topic configured")
 	errNoHosts    = errors.New("No hosts configured")
+
+	errInvalidTopic = errors.New("topic name must only contain ASCII alphanumerics, '.', '_' and '-'")
 )
 
+// maxTopicLength is the maximum length of a topic name accepted by kafka.
+const maxTopicLength = 249
+
 func init() {
 	sarama.Logger = kafkaLogger{}
 
@@ -93,6 +99,8 @@ func makeKafka(
 		MultiKey:         "topics",
 		EnableSingleOnly: true,
 		FailEmpty:        true,
+		RouteName:        "topic",
+		ValidateRoute:    validateTopic,
 	})
 	if err != nil {
 		return outputs.Fail(err)
@@ -113,7 +121,7 @@ func makeKafka(
 		return outputs.Fail(err)
 	}
 
-	client, err := newKafkaClient(observer, hosts, beat.Beat, config.Key, topic, codec, libCfg)
+	client, err := newKafkaClient(observer, hosts, beat.Beat, config.Key, config.Headers, topic, codec, libCfg)
 	if err != nil {
 		return outputs.Fail(err)
 	}
@@ -124,3 +132,22 @@ func makeKafka(
 	}
 	return outputs.Success(config.BulkMaxSize, retry, client)
 }
+
+// validateTopic checks a topic name read from the event metadata can be
+// used with kafka.
+func validateTopic(topic string) error {
+	if len(topic) > maxTopicLength {
+		return fmt.Errorf("topic name exceeds %v characters", maxTopicLength)
+	}
+	if topic == "." || topic == ".." {
+		return errInvalidTopic
+	}
+	for _, c := range topic {
+		valid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') || c == '.' || c == '_' || c == '-'
+		if !valid {
+			return errInvalidTopic
+		}
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+type client struct {
+	observer outputs.Observer
+	address  string
+	dialer   transport.Dialer
+	tls      *transport.TLSConfig
+	info     connectInfo
+	timeout  time.Duration
+
+	// publish to JetStream, waiting for an acknowledgement per message
+	jetstream bool
+
+	subject outil.Selector
+	index   string
+	codec   codec.Codec
+
+	conn     *conn
+	inbox    string
+	batchSeq uint64
+}
+
+// pubAck is the response of JetStream to a published message.
+type pubAck struct {
+	Stream    string       `json:"stream"`
+	Seq       uint64       `json:"seq"`
+	Duplicate bool         `json:"duplicate"`
+	Error     *pubAckError `json:"error"`
+}
+
+type pubAckError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+const inboxSID = "1"
+
+var (
+	errNoSubject      = errors.New("no subject could be selected")
+	errInvalidSubject = errors.New("subject must not contain whitespace, wildcards or empty tokens")
+)
+
+func newClient(
+	observer outputs.Observer,
+	address string,
+	dialer transport.Dialer,
+	tls *transport.TLSConfig,
+	info connectInfo,
+	timeout time.Duration,
+	jetstream bool,
+	index string,
+	subject outil.Selector,
+	writer codec.Codec,
+) *client {
+	return &client{
+		observer:  observer,
+		address:   address,
+		dialer:    dialer,
+		tls:       tls,
+		info:      info,
+		timeout:   timeout,
+		jetstream: jetstream,
+		subject:   subject,
+		index:     index,
+		codec:     writer,
+	}
+}
+
+func (c *client) Connect() error {
+	debugf("connect: %v", c.address)
+
+	// close the connection left by a failed publish before reconnecting
+	if c.conn != nil {
+		if err := c.Close(); err != nil {
+			debugf("closing previous connection failed with: %v", err)
+		}
+	}
+
+	conn, err := dialConn(c.dialer, c.address, c.tls, c.info, c.timeout)
+	if err != nil {
+		logp.Err("NATS connect fails with: %v", err)
+		return err
+	}
+
+	if c.jetstream {
+		// acknowledgements of JetStream are received on a private inbox
+		c.inbox, err = newInbox()
+		if err == nil {
+			err = conn.subscribe(c.inbox+".>", inboxSID)
+		}
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	c.conn = conn
+	return nil
+}
+
+func (c *client) Close() error {
+	debugf("closed nats client")
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *client) Publish(batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	rest, err := c.publishEvents(events)
+	if len(rest) == 0 {
+		batch.ACK()
+	} else {
+		batch.RetryEvents(rest)
+	}
+	return err
+}
+
+func (c *client) publishEvents(events []publisher.Event) ([]publisher.Event, error) {
+	if c.conn == nil {
+		c.observer.Failed(len(events))
+		return events, transport.ErrNotConnected
+	}
+
+	c.batchSeq++
+	prefix := c.inbox + "." + strconv.FormatUint(c.batchSeq, 10) + "."
+
+	var pending []publisher.Event
+	dropped := 0
+	for i := range events {
+		subject, payload, err := c.encode(&events[i])
+		if err != nil {
+			logp.Err("Dropping event: %v", err)
+			dropped++
+			continue
+		}
+
+		var reply string
+		if c.jetstream {
+			reply = prefix + strconv.Itoa(len(pending))
+		}
+		c.conn.publish(subject, reply, payload)
+		pending = append(pending, events[i])
+	}
+	c.observer.Dropped(dropped)
+
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if err := c.conn.flush(); err != nil {
+		logp.Err("Failed to publish events to nats: %v", err)
+		c.observer.Failed(len(pending))
+		return pending, err
+	}
+
+	if !c.jetstream {
+		if err := c.conn.ping(); err != nil {
+			logp.Err("Failed to publish events to nats: %v", err)
+			c.observer.Failed(len(pending))
+			return pending, err
+		}
+		c.observer.Acked(len(pending))
+		return nil, nil
+	}
+
+	failed, err := c.awaitAcks(prefix, pending)
+	c.observer.Acked(len(pending) - len(failed))
+	c.observer.Failed(len(failed))
+	if len(failed) > 0 {
+		if err == nil {
+			err = fmt.Errorf("%d events not acknowledged by jetstream", len(failed))
+		}
+		return failed, err
+	}
+	return nil, nil
+}
+
+func (c *client) encode(event *publisher.Event) (string, []byte, error) {
+	subject, err := c.subject.Select(&event.Content)
+	if err != nil {
+		return "", nil, fmt.Errorf("setting nats subject failed with %v", err)
+	}
+	if subject == "" {
+		return "", nil, errNoSubject
+	}
+	if err := validateSubject(subject); err != nil {
+		return "", nil, fmt.Errorf("invalid subject '%v': %v", subject, err)
+	}
+
+	payload, err := c.codec.Encode(c.index, &event.Content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if max := c.conn.info.MaxPayload; max > 0 && len(payload) > max {
+		return "", nil, fmt.Errorf("event size %d exceeds the servers max_payload of %d", len(payload), max)
+	}
+	return subject, payload, nil
+}
+
+// awaitAcks waits for the JetStream acknowledgements of all pending events.
+// Events failed or not acknowledged within the timeout are returned for retry.
+func (c *client) awaitAcks(prefix string, pending []publisher.Event) ([]publisher.Event, error) {
+	done := make([]bool, len(pending))
+	var failed []publisher.Event
+	var err error
+
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	for remaining := len(pending); remaining > 0; {
+		var m *msg
+		m, _, err = c.conn.next()
+		if err != nil {
+			logp.Err("Failed to receive jetstream acknowledgements: %v", err)
+			break
+		}
+
+		// ignore late acknowledgements of earlier batches
+		if m == nil || !strings.HasPrefix(m.subject, prefix) {
+			continue
+		}
+		idx, convErr := strconv.Atoi(m.subject[len(prefix):])
+		if convErr != nil || idx < 0 || idx >= len(pending) || done[idx] {
+			continue
+		}
+		done[idx] = true
+		remaining--
+
+		var ack pubAck
+		if jsonErr := json.Unmarshal(m.data, &ack); jsonErr != nil {
+			logp.Err("Failed to parse jetstream acknowledgement: %v", jsonErr)
+			failed = append(failed, pending[idx])
+			continue
+		}
+		if ack.Error != nil {
+			logp.Err("JetStream rejected event (code=%v): %v", ack.Error.Code, ack.Error.Description)
+			failed = append(failed, pending[idx])
+		}
+	}
+
+	if err != nil {
+		for i, ok := range done {
+			if !ok {
+				failed = append(failed, pending[i])
+			}
+		}
+	}
+	return failed, err
+}
+
+// validateSubject checks a subject can be published to. Wildcards are only
+// allowed in subscriptions.
+func validateSubject(subject string) error {
+	if strings.ContainsAny(subject, " \t\r\n*>") {
+		return errInvalidSubject
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return errInvalidSubject
+		}
+	}
+	return nil
+}
+
+func newInbox() (string, error) {
+	var buf [12]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return "_INBOX." + hex.EncodeToString(buf[:]), nil
+}
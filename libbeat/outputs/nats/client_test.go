@@ -0,0 +1,258 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+)
+
+// mockServer implements the subset of the NATS protocol used by the client.
+// In jetstream mode every message published with a reply subject is
+// acknowledged, unless the subject starts with `fail`.
+type mockServer struct {
+	listener  net.Listener
+	jetstream bool
+
+	mu        sync.Mutex
+	connect   string
+	published map[string]int
+}
+
+func newMockServer(t *testing.T, jetstream bool) *mockServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mockServer{listener: l, jetstream: jetstream, published: map[string]int{}}
+	go s.serve()
+	return s
+}
+
+func (s *mockServer) Close() { s.listener.Close() }
+
+func (s *mockServer) Addr() string { return s.listener.Addr().String() }
+
+func (s *mockServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *mockServer) handle(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	fmt.Fprintf(w, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+	w.Flush()
+
+	var inbox, sid string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			s.mu.Unlock()
+		case "SUB":
+			inbox, sid = fields[1], fields[2]
+		case "PING":
+			fmt.Fprintf(w, "PONG\r\n")
+		case "PUB":
+			subject := fields[1]
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.published[subject]++
+			s.mu.Unlock()
+
+			if s.jetstream && len(fields) == 4 && strings.HasPrefix(fields[2], strings.TrimSuffix(inbox, ">")) {
+				ack := `{"stream":"events","seq":1}`
+				if strings.HasPrefix(subject, "fail") {
+					ack = `{"error":{"code":503,"description":"unavailable"}}`
+				}
+				fmt.Fprintf(w, "MSG %v %v %d\r\n%v\r\n", fields[2], sid, len(ack), ack)
+			}
+		}
+		w.Flush()
+	}
+}
+
+func (s *mockServer) count(subject string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.published[subject]
+}
+
+func (s *mockServer) connectInfo() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connect
+}
+
+func newTestClient(t *testing.T, s *mockServer, jetstream bool) *client {
+	subject := outil.MakeSelector(outil.FmtSelectorExpr(
+		fmtstr.MustCompileEvent("%{[subject]}"), ""))
+	return newClient(outputs.NewNilObserver(), s.Addr(),
+		transport.NetDialer(time.Second), nil,
+		connectInfo{User: "user", Pass: "secret", Name: "test", Lang: "go", Protocol: 1},
+		time.Second, jetstream, "test", subject, json.New(false, "1.0.0"))
+}
+
+func testEvents(subjects ...string) []beat.Event {
+	events := make([]beat.Event, len(subjects))
+	for i, subject := range subjects {
+		events[i] = beat.Event{
+			Timestamp: time.Now(),
+			Fields:    common.MapStr{"subject": subject, "message": "hello"},
+		}
+	}
+	return events
+}
+
+func TestPublishCore(t *testing.T) {
+	server := newMockServer(t, false)
+	defer server.Close()
+
+	client := newTestClient(t, server, false)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	batch := outest.NewBatch(testEvents("logs.a", "logs.b", "logs.a", "invalid subject")...)
+	assert.NoError(t, client.Publish(batch))
+
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	}
+	assert.Equal(t, 2, server.count("logs.a"))
+	assert.Equal(t, 1, server.count("logs.b"))
+	assert.Contains(t, server.connectInfo(), `"user":"user"`)
+	assert.Contains(t, server.connectInfo(), `"pass":"secret"`)
+}
+
+func TestPublishJetStream(t *testing.T) {
+	server := newMockServer(t, true)
+	defer server.Close()
+
+	client := newTestClient(t, server, true)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	batch := outest.NewBatch(testEvents("logs.a", "logs.b")...)
+	assert.NoError(t, client.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	}
+
+	batch = outest.NewBatch(testEvents("logs.a", "fail.a", "logs.b")...)
+	assert.Error(t, client.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+		if assert.Len(t, batch.Signals[0].Events, 1) {
+			assert.Equal(t, "fail.a", batch.Signals[0].Events[0].Content.Fields["subject"])
+		}
+	}
+}
+
+func TestPublishNotConnected(t *testing.T) {
+	server := newMockServer(t, false)
+	defer server.Close()
+
+	client := newTestClient(t, server, false)
+	batch := outest.NewBatch(testEvents("logs.a")...)
+	assert.Error(t, client.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	}
+}
+
+func TestReconnectClosesConnection(t *testing.T) {
+	server := newMockServer(t, false)
+	defer server.Close()
+
+	client := newTestClient(t, server, false)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	previous := client.conn
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, previous, client.conn)
+	assert.Error(t, previous.ping())
+
+	batch := outest.NewBatch(testEvents("logs.a")...)
+	assert.NoError(t, client.Publish(batch))
+	assert.Equal(t, 1, server.count("logs.a"))
+}
+
+func TestValidateSubject(t *testing.T) {
+	for _, valid := range []string{"logs", "logs.tenant-a", "a.b.c"} {
+		assert.NoError(t, validateSubject(valid), valid)
+	}
+	for _, invalid := range []string{"logs a", "logs.*", "logs.>", "logs..a", ".logs", "logs."} {
+		assert.Error(t, validateSubject(invalid), invalid)
+	}
+}
+
+func TestHostAddress(t *testing.T) {
+	assert.Equal(t, "localhost:4222", hostAddress("localhost"))
+	assert.Equal(t, "localhost:4223", hostAddress("nats://localhost:4223"))
+	assert.Equal(t, "10.0.0.1:4222", hostAddress("tls://10.0.0.1"))
+}
@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+type natsConfig struct {
+	Username    string            `config:"username"`
+	Password    string            `config:"password"`
+	Token       string            `config:"token"`
+	Name        string            `config:"client_name"`
+	JetStream   bool              `config:"jetstream"`
+	TLS         *tlscommon.Config `config:"ssl"`
+	Timeout     time.Duration     `config:"timeout"             validate:"min=1"`
+	LoadBalance bool              `config:"loadbalance"`
+	BulkMaxSize int               `config:"bulk_max_size"`
+	MaxRetries  int               `config:"max_retries"         validate:"min=-1"`
+	Backoff     backoff           `config:"backoff"`
+	Codec       codec.Config      `config:"codec"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const defaultPort = 4222
+
+var (
+	defaultConfig = natsConfig{
+		Name:        "beats",
+		Timeout:     30 * time.Second,
+		LoadBalance: true,
+		BulkMaxSize: 256,
+		MaxRetries:  3,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+)
+
+func (c *natsConfig) Validate() error {
+	if c.Token != "" && c.Username != "" {
+		return errors.New("token can not be used together with username and password")
+	}
+	if c.Username != "" && c.Password == "" {
+		return errors.New("password must be set when username is configured")
+	}
+	return nil
+}
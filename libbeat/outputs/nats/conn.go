@@ -0,0 +1,285 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/outputs/transport"
+)
+
+// conn implements the client side of the NATS protocol required for
+// publishing messages. See https://docs.nats.io/reference/reference-protocols/nats-protocol.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+
+	info    serverInfo
+	timeout time.Duration
+}
+
+type serverInfo struct {
+	ServerID     string `json:"server_id"`
+	Version      string `json:"version"`
+	MaxPayload   int    `json:"max_payload"`
+	TLSRequired  bool   `json:"tls_required"`
+	AuthRequired bool   `json:"auth_required"`
+}
+
+type connectInfo struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	TLS      bool   `json:"tls_required"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"`
+}
+
+// msg is a message delivered by the server for a subscription.
+type msg struct {
+	subject string
+	sid     string
+	reply   string
+	data    []byte
+}
+
+var (
+	errTLSRequired   = errors.New("nats server requires TLS, but no ssl settings are configured")
+	errTLSUnexpected = errors.New("ssl is configured, but the nats server does not support TLS")
+	errUnknownOp     = errors.New("unknown nats protocol operation")
+)
+
+const (
+	opInfo = "INFO"
+	opMsg  = "MSG"
+	opPing = "PING"
+	opPong = "PONG"
+	opOK   = "+OK"
+	opErr  = "-ERR"
+)
+
+var crlf = []byte("\r\n")
+
+// dialConn connects to a NATS server, upgrading the connection to TLS if
+// required, and performs the protocol handshake.
+func dialConn(
+	dialer transport.Dialer,
+	address string,
+	tlsConfig *transport.TLSConfig,
+	info connectInfo,
+	timeout time.Duration,
+) (*conn, error) {
+	raw, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{Conn: raw, timeout: timeout}
+	c.r = bufio.NewReader(raw)
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	if err := c.handshake(address, tlsConfig, info); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, c.SetDeadline(time.Time{})
+}
+
+func (c *conn) handshake(address string, tlsConfig *transport.TLSConfig, info connectInfo) error {
+	op, args, err := c.readOp()
+	if err != nil {
+		return err
+	}
+	if op != opInfo {
+		return fmt.Errorf("expected INFO from nats server, got %v", op)
+	}
+	if err := json.Unmarshal([]byte(args), &c.info); err != nil {
+		return fmt.Errorf("failed to parse nats server INFO: %v", err)
+	}
+
+	// NATS servers announce TLS support in INFO. The connection is upgraded
+	// before any other message is sent.
+	switch {
+	case c.info.TLSRequired && tlsConfig == nil:
+		return errTLSRequired
+	case !c.info.TLSRequired && tlsConfig != nil:
+		return errTLSUnexpected
+	case tlsConfig != nil:
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		tlsConn := tls.Client(c.Conn, tlsConfig.BuildModuleConfig(host))
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		c.Conn = tlsConn
+		c.r = bufio.NewReader(tlsConn)
+		info.TLS = true
+	}
+	c.w = bufio.NewWriter(c.Conn)
+
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	c.w.WriteString("CONNECT ")
+	c.w.Write(payload)
+	c.w.Write(crlf)
+	return c.flush()
+}
+
+// publish buffers a PUB message. Call flush to send buffered messages.
+func (c *conn) publish(subject, reply string, data []byte) {
+	c.w.WriteString("PUB ")
+	c.w.WriteString(subject)
+	c.w.WriteByte(' ')
+	if reply != "" {
+		c.w.WriteString(reply)
+		c.w.WriteByte(' ')
+	}
+	c.w.WriteString(strconv.Itoa(len(data)))
+	c.w.Write(crlf)
+	c.w.Write(data)
+	c.w.Write(crlf)
+}
+
+func (c *conn) subscribe(subject, sid string) error {
+	c.w.WriteString("SUB " + subject + " " + sid)
+	c.w.Write(crlf)
+	return c.flush()
+}
+
+func (c *conn) flush() error {
+	return c.w.Flush()
+}
+
+// ping sends a PING and waits for the servers PONG. As the server processes
+// messages in order, receiving the PONG guarantees all messages sent before
+// have been processed.
+func (c *conn) ping() error {
+	c.w.WriteString(opPing)
+	c.w.Write(crlf)
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	c.SetReadDeadline(time.Now().Add(c.timeout))
+	defer c.SetReadDeadline(time.Time{})
+	for {
+		// messages received while waiting for the PONG are ignored
+		_, pong, err := c.next()
+		if err != nil {
+			return err
+		}
+		if pong {
+			return nil
+		}
+	}
+}
+
+// next reads the next message from the server. Server PINGs and +OK are
+// handled transparently. The boolean result is set if a PONG was received.
+func (c *conn) next() (*msg, bool, error) {
+	for {
+		op, args, err := c.readOp()
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch op {
+		case opMsg:
+			m, err := c.readMsg(args)
+			return m, false, err
+		case opPong:
+			return nil, true, nil
+		case opPing:
+			c.w.WriteString(opPong)
+			c.w.Write(crlf)
+			if err := c.flush(); err != nil {
+				return nil, false, err
+			}
+		case opOK, opInfo:
+		case opErr:
+			return nil, false, fmt.Errorf("nats server error: %v", strings.Trim(args, "' "))
+		default:
+			return nil, false, errUnknownOp
+		}
+	}
+}
+
+func (c *conn) readMsg(args string) (*msg, error) {
+	// MSG <subject> <sid> [reply-to] <#bytes>
+	fields := strings.Fields(args)
+	m := &msg{}
+	switch len(fields) {
+	case 3:
+		m.subject, m.sid = fields[0], fields[1]
+	case 4:
+		m.subject, m.sid, m.reply = fields[0], fields[1], fields[2]
+	default:
+		return nil, fmt.Errorf("invalid nats MSG arguments: %v", args)
+	}
+
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid nats MSG size: %v", args)
+	}
+
+	m.data = make([]byte, size+len(crlf))
+	if _, err := io.ReadFull(c.r, m.data); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(m.data, crlf) {
+		return nil, errors.New("nats MSG payload not terminated by CRLF")
+	}
+	m.data = m.data[:size]
+	return m, nil
+}
+
+func (c *conn) readOp() (string, string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	op, args := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		op, args = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	return strings.ToUpper(op), args, nil
+}
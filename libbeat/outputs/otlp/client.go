@@ -0,0 +1,474 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/testing"
+)
+
+const exportLogsPath = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// gRPC status codes returned by the collector.
+// See https://github.com/grpc/grpc/blob/master/doc/statuscodes.md.
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcDeadlineExceeded  = 4
+	grpcResourceExhausted = 8
+	grpcAborted           = 10
+	grpcOutOfRange        = 11
+	grpcUnavailable       = 14
+	grpcDataLoss          = 15
+)
+
+// retryableCodes lists the gRPC status codes for which the OTLP specification
+// requires the client to retry the export.
+var retryableCodes = map[int]bool{
+	grpcCanceled:          true,
+	grpcDeadlineExceeded:  true,
+	grpcResourceExhausted: true,
+	grpcAborted:           true,
+	grpcOutOfRange:        true,
+	grpcUnavailable:       true,
+	grpcDataLoss:          true,
+}
+
+// Field numbers of the ExportLogsServiceResponse message.
+const (
+	exportResponsePartialSuccess = 1
+
+	partialSuccessRejectedLogRecords = 1
+	partialSuccessErrorMessage       = 2
+)
+
+type client struct {
+	host        string
+	url         string
+	info        beat.Info
+	headers     map[string]string
+	compression string
+	observer    outputs.Observer
+
+	timeout   time.Duration
+	keepAlive time.Duration
+	tls       *tlscommon.TLSConfig
+	secure    bool
+	http      *http.Client
+
+	// reusable request buffers
+	proto protowire.Buffer
+	body  bytes.Buffer
+	gzip  *gzip.Writer
+}
+
+type clientSettings struct {
+	Host        string
+	TLS         *tlscommon.TLSConfig
+	Headers     map[string]string
+	Compression string
+	Timeout     time.Duration
+	KeepAlive   time.Duration
+	Observer    outputs.Observer
+}
+
+type exportStatus struct {
+	code     int
+	message  string
+	rejected int
+}
+
+var errTempFailure = errors.New("temporary otlp export failure")
+
+func newClient(info beat.Info, s clientSettings) (*client, error) {
+	host, secure, err := parseHost(s.Host)
+	if err != nil {
+		return nil, err
+	}
+	secure = secure || s.TLS != nil
+
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	c := &client{
+		host:        host,
+		url:         scheme + "://" + host + exportLogsPath,
+		info:        info,
+		headers:     s.Headers,
+		compression: s.Compression,
+		observer:    s.Observer,
+		timeout:     s.Timeout,
+		keepAlive:   s.KeepAlive,
+		tls:         s.TLS,
+		secure:      secure,
+	}
+
+	c.http = &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: !secure,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return c.dial(network, addr)
+			},
+		},
+		Timeout: s.Timeout,
+	}
+
+	if s.Compression == compressionGzip {
+		c.gzip = gzip.NewWriter(&c.body)
+	}
+
+	return c, nil
+}
+
+// parseHost splits an optional http:// or https:// scheme from the host and
+// adds the default port if missing.
+func parseHost(host string) (string, bool, error) {
+	secure := false
+	if u, err := url.Parse(host); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if u.Path != "" && u.Path != "/" {
+			return "", false, fmt.Errorf("otlp host '%v' must not contain a path", host)
+		}
+		host = u.Host
+		secure = u.Scheme == "https"
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, strconv.Itoa(defaultPort))
+	}
+	return host, secure, nil
+}
+
+// dial establishes a new connection to the collector. The HTTP/2 transport
+// uses dial for plain text (h2c) and TLS connections.
+func (c *client) dial(network, addr string) (net.Conn, error) {
+	var dialer transport.Dialer = transport.DialerFunc(func(network, addr string) (net.Conn, error) {
+		d := &net.Dialer{Timeout: c.timeout, KeepAlive: c.keepAlive}
+		return d.Dial(network, addr)
+	})
+	if c.observer != nil {
+		dialer = transport.StatsDialer(dialer, c.observer)
+	}
+
+	conn, err := dialer.Dial(network, addr)
+	if err != nil || !c.secure {
+		return conn, err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConfig := c.tls.BuildModuleConfig(host)
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if c.timeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	if proto := tlsConn.ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+		conn.Close()
+		return nil, fmt.Errorf("otlp endpoint %v does not support HTTP/2 (negotiated protocol: '%v')", addr, proto)
+	}
+	return tlsConn, nil
+}
+
+// Connect is a no-op. Connections are established on the first export.
+func (c *client) Connect() error {
+	return nil
+}
+
+// Close closes all idle connections to the collector.
+func (c *client) Close() error {
+	if t, ok := c.http.Transport.(*http2.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *client) Publish(batch publisher.Batch) error {
+	events := batch.Events()
+	rest, err := c.publishEvents(events)
+	if len(rest) == 0 {
+		batch.ACK()
+	} else {
+		batch.RetryEvents(rest)
+	}
+	return err
+}
+
+// publishEvents exports all events in a single ExportLogsServiceRequest. On
+// error the events to be retried are returned.
+func (c *client) publishEvents(data []publisher.Event) ([]publisher.Event, error) {
+	begin := time.Now()
+	st := c.observer
+
+	if st != nil {
+		st.NewBatch(len(data))
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	c.proto.Reset()
+	encodeLogsRequest(&c.proto, c.info, data, begin)
+
+	status, err := c.export(c.proto.Bytes())
+	switch {
+	case err != nil:
+		logp.Err("Failed to export events: %v", err)
+		if st != nil {
+			st.Failed(len(data))
+		}
+		return data, err
+
+	case status.code == grpcOK:
+		acked := len(data) - status.rejected
+		if status.rejected > 0 {
+			logp.Warn("Collector rejected %d log records: %v", status.rejected, status.message)
+		}
+		if st != nil {
+			st.Acked(acked)
+			st.Dropped(status.rejected)
+		}
+		debugf("PublishEvents: %d events have been published in %v.",
+			acked, time.Now().Sub(begin))
+		return nil, nil
+
+	case retryableCodes[status.code]:
+		logp.Warn("Failed to export events, will retry: grpc-status %d: %v",
+			status.code, status.message)
+		if st != nil {
+			st.Failed(len(data))
+		}
+		return data, errTempFailure
+
+	default:
+		logp.Err("Dropping %d events rejected by the collector: grpc-status %d: %v",
+			len(data), status.code, status.message)
+		if st != nil {
+			st.Dropped(len(data))
+		}
+		return nil, nil
+	}
+}
+
+// export sends the encoded request as a single gRPC message and returns the
+// gRPC status of the call.
+func (c *client) export(msg []byte) (exportStatus, error) {
+	if err := c.encodeFrame(msg); err != nil {
+		return exportStatus{}, err
+	}
+
+	req, err := http.NewRequest("POST", c.url, &c.body)
+	if err != nil {
+		return exportStatus{}, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "grpc-go-beats/"+c.info.Version)
+	if c.gzip != nil {
+		req.Header.Set("Grpc-Encoding", "gzip")
+	}
+	req.Header.Set("Grpc-Accept-Encoding", "gzip")
+	if c.timeout > 0 {
+		req.Header.Set("Grpc-Timeout", strconv.FormatInt(int64(c.timeout/time.Millisecond), 10)+"m")
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return exportStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return exportStatus{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return exportStatus{}, fmt.Errorf("unexpected HTTP status %v", resp.Status)
+	}
+
+	// grpc-status is sent in the trailers, or in the headers for
+	// trailers-only responses.
+	code := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if code == "" {
+		return exportStatus{}, errors.New("missing grpc-status in response")
+	}
+
+	status := exportStatus{message: message}
+	status.code, err = strconv.Atoi(code)
+	if err != nil {
+		return exportStatus{}, fmt.Errorf("invalid grpc-status '%v'", code)
+	}
+	if status.code != grpcOK {
+		return status, nil
+	}
+
+	msg, err = decodeFrame(body, resp.Header.Get("Grpc-Encoding"))
+	if err != nil {
+		return exportStatus{}, err
+	}
+	status.rejected, status.message, err = decodeExportResponse(msg)
+	return status, err
+}
+
+// encodeFrame writes msg to the request body using the gRPC length prefixed
+// message framing.
+func (c *client) encodeFrame(msg []byte) error {
+	c.body.Reset()
+
+	var header [5]byte
+	c.body.Write(header[:])
+	if c.gzip == nil {
+		c.body.Write(msg)
+	} else {
+		header[0] = 1
+		c.gzip.Reset(&c.body)
+		if _, err := c.gzip.Write(msg); err != nil {
+			return err
+		}
+		if err := c.gzip.Close(); err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(header[1:], uint32(c.body.Len()-len(header)))
+	copy(c.body.Bytes(), header[:])
+	return nil
+}
+
+// decodeFrame returns the message of a gRPC length prefixed message. An empty
+// message is returned if body is empty.
+func decodeFrame(body []byte, encoding string) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if len(body) < 5 {
+		return nil, errors.New("truncated grpc message")
+	}
+
+	size := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < size {
+		return nil, errors.New("truncated grpc message")
+	}
+	msg := body[5 : 5+size]
+	if body[0] == 0 {
+		return msg, nil
+	}
+
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported grpc-encoding '%v'", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// decodeExportResponse reads the partial success information from an
+// ExportLogsServiceResponse.
+func decodeExportResponse(msg []byte) (rejected int, message string, err error) {
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, _, _, data, err := r.Next()
+		if err != nil {
+			return 0, "", err
+		}
+		if field != exportResponsePartialSuccess {
+			continue
+		}
+
+		partial := protowire.NewReader(data)
+		for !partial.Done() {
+			field, _, v, data, err := partial.Next()
+			if err != nil {
+				return 0, "", err
+			}
+			switch field {
+			case partialSuccessRejectedLogRecords:
+				rejected = int(v)
+			case partialSuccessErrorMessage:
+				message = string(data)
+			}
+		}
+	}
+	return rejected, message, nil
+}
+
+func (c *client) Test(d testing.Driver) {
+	d.Run("otlp: "+c.host, func(d testing.Driver) {
+		d.Run("connection", func(d testing.Driver) {
+			netDialer := transport.TestNetDialer(d, c.timeout)
+			_, err := netDialer.Dial("tcp", c.host)
+			d.Fatal("dial up", err)
+		})
+
+		if !c.secure {
+			d.Warn("TLS", "secure connection disabled")
+		} else {
+			d.Run("TLS", func(d testing.Driver) {
+				conn, err := c.dial("tcp", c.host)
+				if err == nil {
+					conn.Close()
+				}
+				d.Fatal("dial up", err)
+			})
+		}
+	})
+}
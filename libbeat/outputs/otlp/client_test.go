@@ -0,0 +1,292 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+)
+
+type recordedRequest struct {
+	path   string
+	header http.Header
+	msg    []byte
+}
+
+// collector is a minimal OTLP/gRPC logs collector serving h2c connections.
+type collector struct {
+	sync.Mutex
+	listener net.Listener
+	status   int
+	rejected int
+	requests []recordedRequest
+}
+
+func newCollector(t *testing.T) *collector {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{listener: l}
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{Handler: c})
+		}
+	}()
+	return c
+}
+
+func (c *collector) Close() {
+	c.listener.Close()
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	msg, err := decodeFrame(body, req.Header.Get("Grpc-Encoding"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.requests = append(c.requests, recordedRequest{
+		path:   req.URL.Path,
+		header: req.Header,
+		msg:    msg,
+	})
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	if c.status == grpcOK {
+		var b protowire.Buffer
+		if c.rejected > 0 {
+			b.MessageField(exportResponsePartialSuccess, func(b *protowire.Buffer) {
+				b.VarintField(partialSuccessRejectedLogRecords, uint64(c.rejected))
+				b.StringField(partialSuccessErrorMessage, "invalid records")
+			})
+		}
+		frame := make([]byte, 5, 5+len(b.Bytes()))
+		frame[4] = byte(len(b.Bytes()))
+		w.Write(append(frame, b.Bytes()...))
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(c.status))
+	w.Header().Set("Grpc-Message", "test")
+}
+
+func newTestClient(t *testing.T, host string, settings map[string]interface{}) outputs.NetworkClient {
+	settings["hosts"] = []string{host}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grp, err := makeOTLP(beat.Info{Beat: "test", Version: "7.0.0"}, outputs.NewNilObserver(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grp.Clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(grp.Clients))
+	}
+
+	// unwrap the backoff client, so tests are not delayed by errors
+	return grp.Clients[0].(interface {
+		Client() outputs.NetworkClient
+	}).Client()
+}
+
+func testEvents(n int) []beat.Event {
+	events := make([]beat.Event, n)
+	for i := range events {
+		events[i] = beat.Event{
+			Timestamp: time.Now(),
+			Fields:    common.MapStr{"message": "hello"},
+		}
+	}
+	return events
+}
+
+func countLogRecords(t *testing.T, msg []byte) int {
+	count := 0
+	for _, rl := range decodeFields(t, msg)[exportRequestResourceLogs] {
+		for _, sl := range decodeFields(t, rl.data)[resourceLogsScopeLogs] {
+			count += len(decodeFields(t, sl.data)[scopeLogsLogRecords])
+		}
+	}
+	return count
+}
+
+func TestExport(t *testing.T) {
+	for _, compression := range []string{compressionGzip, compressionNone} {
+		t.Run(compression, func(t *testing.T) {
+			col := newCollector(t)
+			defer col.Close()
+
+			client := newTestClient(t, col.listener.Addr().String(), map[string]interface{}{
+				"compression": compression,
+				"headers":     map[string]interface{}{"authorization": "Bearer token"},
+			})
+			defer client.Close()
+
+			batch := outest.NewBatch(testEvents(3)...)
+			if err := client.Publish(batch); err != nil {
+				t.Fatal(err)
+			}
+
+			if assert.Len(t, batch.Signals, 1) {
+				assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+			}
+
+			if !assert.Len(t, col.requests, 1) {
+				return
+			}
+			requ := col.requests[0]
+			assert.Equal(t, exportLogsPath, requ.path)
+			assert.Equal(t, "application/grpc", requ.header.Get("Content-Type"))
+			assert.Equal(t, "Bearer token", requ.header.Get("Authorization"))
+			if compression == compressionGzip {
+				assert.Equal(t, "gzip", requ.header.Get("Grpc-Encoding"))
+			} else {
+				assert.Equal(t, "", requ.header.Get("Grpc-Encoding"))
+			}
+			assert.Equal(t, 3, countLogRecords(t, requ.msg))
+		})
+	}
+}
+
+func TestExportPartialSuccess(t *testing.T) {
+	col := newCollector(t)
+	defer col.Close()
+	col.rejected = 1
+
+	client := newTestClient(t, col.listener.Addr().String(), map[string]interface{}{})
+	defer client.Close()
+
+	batch := outest.NewBatch(testEvents(2)...)
+	if err := client.Publish(batch); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	}
+}
+
+func TestExportStatus(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		signal outest.BatchSignalTag
+		err    bool
+	}{
+		"unavailable is retried": {
+			status: grpcUnavailable,
+			signal: outest.BatchRetryEvents,
+			err:    true,
+		},
+		"resource exhausted is retried": {
+			status: grpcResourceExhausted,
+			signal: outest.BatchRetryEvents,
+			err:    true,
+		},
+		"invalid argument is dropped": {
+			status: 3,
+			signal: outest.BatchACK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			col := newCollector(t)
+			defer col.Close()
+			col.status = test.status
+
+			client := newTestClient(t, col.listener.Addr().String(), map[string]interface{}{})
+			defer client.Close()
+
+			batch := outest.NewBatch(testEvents(2)...)
+			err := client.Publish(batch)
+			assert.Equal(t, test.err, err != nil)
+
+			if assert.Len(t, batch.Signals, 1) {
+				assert.Equal(t, test.signal, batch.Signals[0].Tag)
+			}
+		})
+	}
+}
+
+func TestExportConnectionError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	client := newTestClient(t, addr, map[string]interface{}{"timeout": "1s"})
+	batch := outest.NewBatch(testEvents(1)...)
+	assert.Error(t, client.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		in     string
+		host   string
+		secure bool
+	}{
+		{"localhost", "localhost:4317", false},
+		{"localhost:1234", "localhost:1234", false},
+		{"http://collector", "collector:4317", false},
+		{"https://collector:443", "collector:443", true},
+	}
+
+	for _, test := range tests {
+		host, secure, err := parseHost(test.in)
+		if assert.NoError(t, err, test.in) {
+			assert.Equal(t, test.host, host, test.in)
+			assert.Equal(t, test.secure, secure, test.in)
+		}
+	}
+
+	_, _, err := parseHost("http://collector/v1/logs")
+	assert.Error(t, err)
+}
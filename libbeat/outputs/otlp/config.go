@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type otlpConfig struct {
+	TLS         *tlscommon.Config `config:"ssl"`
+	Headers     map[string]string `config:"headers"`
+	Compression string            `config:"compression"`
+	Timeout     time.Duration     `config:"timeout"       validate:"min=1"`
+	KeepAlive   time.Duration     `config:"keep_alive"    validate:"min=0"`
+	LoadBalance bool              `config:"loadbalance"`
+	BulkMaxSize int               `config:"bulk_max_size"`
+	MaxRetries  int               `config:"max_retries"   validate:"min=-1"`
+	Backoff     backoff           `config:"backoff"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const (
+	defaultPort = 4317
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+var (
+	defaultConfig = otlpConfig{
+		Compression: compressionGzip,
+		Timeout:     30 * time.Second,
+		KeepAlive:   30 * time.Second,
+		LoadBalance: true,
+		BulkMaxSize: 512,
+		MaxRetries:  3,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+)
+
+func (c *otlpConfig) Validate() error {
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	default:
+		return fmt.Errorf("unsupported compression '%v', expected '%v' or '%v'",
+			c.Compression, compressionGzip, compressionNone)
+	}
+	for k := range c.Headers {
+		if k == "" || k[0] == ':' {
+			return fmt.Errorf("invalid header name '%v'", k)
+		}
+	}
+	return nil
+}
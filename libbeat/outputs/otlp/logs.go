@@ -0,0 +1,353 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// Field numbers of the OTLP logs messages.
+// See https://github.com/open-telemetry/opentelemetry-proto/tree/main/opentelemetry/proto/logs/v1.
+const (
+	exportRequestResourceLogs = 1
+
+	resourceLogsResource  = 1
+	resourceLogsScopeLogs = 2
+
+	resourceAttributes = 1
+
+	scopeLogsScope      = 1
+	scopeLogsLogRecords = 2
+
+	scopeName    = 1
+	scopeVersion = 2
+
+	logRecordTimeUnixNano         = 1
+	logRecordSeverityNumber       = 2
+	logRecordSeverityText         = 3
+	logRecordBody                 = 5
+	logRecordAttributes           = 6
+	logRecordTraceID              = 9
+	logRecordSpanID               = 10
+	logRecordObservedTimeUnixNano = 11
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+	anyValueArray  = 5
+	anyValueKVList = 6
+
+	arrayValueValues  = 1
+	kvListValueValues = 1
+)
+
+const scopeNameBeats = "github.com/elastic/beats"
+
+// severityNumbers maps ECS log.level values to OTel severity numbers.
+var severityNumbers = map[string]uint64{
+	"trace":     1,
+	"debug":     5,
+	"info":      9,
+	"notice":    10,
+	"warn":      13,
+	"warning":   13,
+	"error":     17,
+	"err":       17,
+	"critical":  21,
+	"crit":      21,
+	"fatal":     21,
+	"alert":     22,
+	"emergency": 23,
+}
+
+// semconvNames renames ECS fields to their OpenTelemetry semantic conventions
+// equivalent. Fields not listed keep their ECS name.
+var semconvNames = map[string]string{
+	"source.ip":          "source.address",
+	"destination.ip":     "destination.address",
+	"client.ip":          "client.address",
+	"server.ip":          "server.address",
+	"url.original":       "url.full",
+	"error.message":      "exception.message",
+	"error.type":         "exception.type",
+	"error.stack_trace":  "exception.stacktrace",
+	"host.hostname":      "host.name",
+	"process.executable": "process.executable.path",
+	"user.name":          "enduser.id",
+}
+
+// fields consumed by the log record or resource and not added as attributes
+var consumedFields = map[string]bool{
+	"message":       true,
+	"log.level":     true,
+	"trace.id":      true,
+	"span.id":       true,
+	"service.name":  true,
+	"host.name":     true,
+	"beat.name":     true,
+	"beat.hostname": true,
+	"beat.version":  true,
+}
+
+type resource struct {
+	serviceName    string
+	serviceVersion string
+	hostName       string
+}
+
+type resourceLogs struct {
+	resource resource
+	events   []*beat.Event
+}
+
+// encodeLogsRequest encodes events into an ExportLogsServiceRequest. Events
+// are grouped by resource, keeping the order of events per resource.
+func encodeLogsRequest(b *protowire.Buffer, info beat.Info, events []publisher.Event, now time.Time) {
+	var groups []*resourceLogs
+	index := map[resource]*resourceLogs{}
+	for i := range events {
+		event := &events[i].Content
+		res := eventResource(info, event)
+		group := index[res]
+		if group == nil {
+			group = &resourceLogs{resource: res}
+			index[res] = group
+			groups = append(groups, group)
+		}
+		group.events = append(group.events, event)
+	}
+
+	observed := uint64(now.UnixNano())
+	for _, group := range groups {
+		b.MessageField(exportRequestResourceLogs, func(b *protowire.Buffer) {
+			b.MessageField(resourceLogsResource, func(b *protowire.Buffer) {
+				encodeResource(b, group.resource)
+			})
+			b.MessageField(resourceLogsScopeLogs, func(b *protowire.Buffer) {
+				b.MessageField(scopeLogsScope, func(b *protowire.Buffer) {
+					b.StringField(scopeName, scopeNameBeats)
+					b.StringField(scopeVersion, info.Version)
+				})
+				for _, event := range group.events {
+					b.MessageField(scopeLogsLogRecords, func(b *protowire.Buffer) {
+						encodeLogRecord(b, event, observed)
+					})
+				}
+			})
+		})
+	}
+}
+
+func eventResource(info beat.Info, event *beat.Event) resource {
+	return resource{
+		serviceName:    firstString(event.Fields, info.Beat, "service.name", "beat.name"),
+		serviceVersion: firstString(event.Fields, info.Version, "beat.version"),
+		hostName:       firstString(event.Fields, "", "host.name", "beat.hostname"),
+	}
+}
+
+func encodeResource(b *protowire.Buffer, res resource) {
+	attrs := []struct{ key, value string }{
+		{"service.name", res.serviceName},
+		{"service.version", res.serviceVersion},
+		{"host.name", res.hostName},
+	}
+	for _, attr := range attrs {
+		if attr.value == "" {
+			continue
+		}
+		b.MessageField(resourceAttributes, func(b *protowire.Buffer) {
+			b.StringField(keyValueKey, attr.key)
+			b.MessageField(keyValueValue, func(b *protowire.Buffer) {
+				b.StringField(anyValueString, attr.value)
+			})
+		})
+	}
+}
+
+func encodeLogRecord(b *protowire.Buffer, event *beat.Event, observed uint64) {
+	if !event.Timestamp.IsZero() {
+		b.Fixed64Field(logRecordTimeUnixNano, uint64(event.Timestamp.UnixNano()))
+	}
+
+	if level := firstString(event.Fields, "", "log.level"); level != "" {
+		if num, ok := severityNumbers[strings.ToLower(level)]; ok {
+			b.VarintField(logRecordSeverityNumber, num)
+		}
+		b.StringField(logRecordSeverityText, level)
+	}
+
+	if msg, err := event.Fields.GetValue("message"); err == nil {
+		b.MessageField(logRecordBody, func(b *protowire.Buffer) {
+			encodeAnyValue(b, msg)
+		})
+	}
+
+	flat := event.Fields.Flatten()
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		if !consumedFields[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		if renamed, ok := semconvNames[key]; ok {
+			name = renamed
+		}
+		value := flat[key]
+		b.MessageField(logRecordAttributes, func(b *protowire.Buffer) {
+			b.StringField(keyValueKey, name)
+			b.MessageField(keyValueValue, func(b *protowire.Buffer) {
+				encodeAnyValue(b, value)
+			})
+		})
+	}
+
+	if id := decodeID(event.Fields, "trace.id", 16); id != nil {
+		b.BytesField(logRecordTraceID, id)
+	}
+	if id := decodeID(event.Fields, "span.id", 8); id != nil {
+		b.BytesField(logRecordSpanID, id)
+	}
+
+	b.Fixed64Field(logRecordObservedTimeUnixNano, observed)
+}
+
+// encodeAnyValue encodes a field value as AnyValue.
+func encodeAnyValue(b *protowire.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		// empty AnyValue
+	case string:
+		b.StringField(anyValueString, val)
+	case bool:
+		b.BoolField(anyValueBool, val)
+	case int:
+		b.VarintField(anyValueInt, uint64(val))
+	case int8:
+		b.VarintField(anyValueInt, uint64(val))
+	case int16:
+		b.VarintField(anyValueInt, uint64(val))
+	case int32:
+		b.VarintField(anyValueInt, uint64(val))
+	case int64:
+		b.VarintField(anyValueInt, uint64(val))
+	case uint:
+		b.VarintField(anyValueInt, uint64(val))
+	case uint8:
+		b.VarintField(anyValueInt, uint64(val))
+	case uint16:
+		b.VarintField(anyValueInt, uint64(val))
+	case uint32:
+		b.VarintField(anyValueInt, uint64(val))
+	case uint64:
+		b.VarintField(anyValueInt, val)
+	case float32:
+		b.DoubleField(anyValueDouble, float64(val))
+	case float64:
+		b.DoubleField(anyValueDouble, val)
+	case time.Time:
+		b.StringField(anyValueString, val.UTC().Format(time.RFC3339Nano))
+	case common.Time:
+		b.StringField(anyValueString, time.Time(val).UTC().Format(time.RFC3339Nano))
+	case common.MapStr:
+		encodeKVList(b, val)
+	case map[string]interface{}:
+		encodeKVList(b, common.MapStr(val))
+	case []interface{}:
+		encodeArray(b, len(val), func(i int) interface{} { return val[i] })
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			encodeArray(b, rv.Len(), func(i int) interface{} { return rv.Index(i).Interface() })
+			return
+		}
+		b.StringField(anyValueString, fmt.Sprint(v))
+	}
+}
+
+func encodeArray(b *protowire.Buffer, n int, get func(int) interface{}) {
+	b.MessageField(anyValueArray, func(b *protowire.Buffer) {
+		for i := 0; i < n; i++ {
+			value := get(i)
+			b.MessageField(arrayValueValues, func(b *protowire.Buffer) {
+				encodeAnyValue(b, value)
+			})
+		}
+	})
+}
+
+func encodeKVList(b *protowire.Buffer, m common.MapStr) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.MessageField(anyValueKVList, func(b *protowire.Buffer) {
+		for _, key := range keys {
+			value := m[key]
+			b.MessageField(kvListValueValues, func(b *protowire.Buffer) {
+				b.StringField(keyValueKey, key)
+				b.MessageField(keyValueValue, func(b *protowire.Buffer) {
+					encodeAnyValue(b, value)
+				})
+			})
+		}
+	})
+}
+
+func firstString(fields common.MapStr, fallback string, keys ...string) string {
+	for _, key := range keys {
+		if v, err := fields.GetValue(key); err == nil {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return fallback
+}
+
+// decodeID decodes a hex encoded trace or span ID of the given size.
+func decodeID(fields common.MapStr, key string, size int) []byte {
+	s := firstString(fields, "", key)
+	if len(s) != 2*size {
+		return nil
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+type protoField struct {
+	v    uint64
+	data []byte
+}
+
+// decodeFields decodes a protobuf message into a map of field numbers to
+// field values.
+func decodeFields(t *testing.T, msg []byte) map[int][]protoField {
+	fields := map[int][]protoField{}
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, _, v, data, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields[field] = append(fields[field], protoField{v, data})
+	}
+	return fields
+}
+
+// decodeAnyValue decodes an AnyValue into a go value.
+func decodeAnyValue(t *testing.T, msg []byte) interface{} {
+	for field, values := range decodeFields(t, msg) {
+		v := values[0]
+		switch field {
+		case anyValueString:
+			return string(v.data)
+		case anyValueBool:
+			return v.v != 0
+		case anyValueInt:
+			return int64(v.v)
+		case anyValueDouble:
+			return math.Float64frombits(v.v)
+		case anyValueArray:
+			var arr []interface{}
+			for _, elem := range decodeFields(t, v.data)[arrayValueValues] {
+				arr = append(arr, decodeAnyValue(t, elem.data))
+			}
+			return arr
+		case anyValueKVList:
+			return decodeKeyValues(t, decodeFields(t, v.data)[kvListValueValues])
+		}
+	}
+	return nil
+}
+
+func decodeKeyValues(t *testing.T, kvs []protoField) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, kv := range kvs {
+		fields := decodeFields(t, kv.data)
+		m[string(fields[keyValueKey][0].data)] = decodeAnyValue(t, fields[keyValueValue][0].data)
+	}
+	return m
+}
+
+func TestEncodeLogsRequest(t *testing.T) {
+	ts := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := ts.Add(time.Second)
+	info := beat.Info{Beat: "testbeat", Version: "7.0.0"}
+
+	events := []publisher.Event{
+		{Content: beat.Event{
+			Timestamp: ts,
+			Fields: common.MapStr{
+				"message": "hello",
+				"log":     common.MapStr{"level": "WARN"},
+				"trace":   common.MapStr{"id": "0102030405060708090a0b0c0d0e0f10"},
+				"span":    common.MapStr{"id": "0102030405060708"},
+				"source":  common.MapStr{"ip": "10.0.0.1", "port": 1234},
+				"tags":    []string{"a", "b"},
+				"beat":    common.MapStr{"name": "host-a", "hostname": "host-a"},
+			},
+		}},
+		{Content: beat.Event{
+			Timestamp: ts,
+			Fields: common.MapStr{
+				"message": "other service",
+				"service": common.MapStr{"name": "svc"},
+				"labels":  common.MapStr{"ratio": 0.5, "ok": true},
+			},
+		}},
+	}
+
+	var b protowire.Buffer
+	encodeLogsRequest(&b, info, events, now)
+
+	resourceLogs := decodeFields(t, b.Bytes())[exportRequestResourceLogs]
+	if !assert.Len(t, resourceLogs, 2) {
+		return
+	}
+
+	// first resource
+	rl := decodeFields(t, resourceLogs[0].data)
+	res := decodeKeyValues(t, decodeFields(t, rl[resourceLogsResource][0].data)[resourceAttributes])
+	assert.Equal(t, map[string]interface{}{
+		"service.name":    "host-a",
+		"service.version": "7.0.0",
+		"host.name":       "host-a",
+	}, res)
+
+	sl := decodeFields(t, rl[resourceLogsScopeLogs][0].data)
+	scope := decodeFields(t, sl[scopeLogsScope][0].data)
+	assert.Equal(t, scopeNameBeats, string(scope[scopeName][0].data))
+	assert.Equal(t, "7.0.0", string(scope[scopeVersion][0].data))
+
+	if !assert.Len(t, sl[scopeLogsLogRecords], 1) {
+		return
+	}
+	record := decodeFields(t, sl[scopeLogsLogRecords][0].data)
+	assert.Equal(t, uint64(ts.UnixNano()), record[logRecordTimeUnixNano][0].v)
+	assert.Equal(t, uint64(now.UnixNano()), record[logRecordObservedTimeUnixNano][0].v)
+	assert.Equal(t, uint64(13), record[logRecordSeverityNumber][0].v)
+	assert.Equal(t, "WARN", string(record[logRecordSeverityText][0].data))
+	assert.Equal(t, "hello", decodeAnyValue(t, record[logRecordBody][0].data))
+	assert.Len(t, record[logRecordTraceID][0].data, 16)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, record[logRecordSpanID][0].data)
+	assert.Equal(t, map[string]interface{}{
+		"source.address": "10.0.0.1",
+		"source.port":    int64(1234),
+		"tags":           []interface{}{"a", "b"},
+	}, decodeKeyValues(t, record[logRecordAttributes]))
+
+	// second resource
+	rl = decodeFields(t, resourceLogs[1].data)
+	res = decodeKeyValues(t, decodeFields(t, rl[resourceLogsResource][0].data)[resourceAttributes])
+	assert.Equal(t, "svc", res["service.name"])
+
+	sl = decodeFields(t, rl[resourceLogsScopeLogs][0].data)
+	record = decodeFields(t, sl[scopeLogsLogRecords][0].data)
+	assert.Nil(t, record[logRecordSeverityNumber])
+	assert.Equal(t, map[string]interface{}{
+		"labels.ratio": 0.5,
+		"labels.ok":    true,
+	}, decodeKeyValues(t, record[logRecordAttributes]))
+}
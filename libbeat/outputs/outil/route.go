@@ -0,0 +1,86 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outil
+
+import (
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// RouteMetaPrefix is the event metadata namespace processors write per event
+// routing decisions to. For example the Kafka output reads the topic from
+// `@metadata.output.topic`.
+const RouteMetaPrefix = "output"
+
+type routeSelector struct {
+	key      string
+	validate func(string) error
+	fallback SelectorExpr
+}
+
+var debugRoute = logp.MakeDebug("routing")
+
+// RouteSelectorExpr creates a selector reading the name from the
+// `@metadata.output.<name>` field of an event. If the field is missing or its
+// value does not pass validation, the fallback selector is used.
+func RouteSelectorExpr(
+	name string,
+	validate func(string) error,
+	fallback SelectorExpr,
+) SelectorExpr {
+	return &routeSelector{
+		key:      RouteMetaPrefix + "." + name,
+		validate: validate,
+		fallback: fallback,
+	}
+}
+
+func (s *routeSelector) sel(evt *beat.Event) (string, error) {
+	if route, ok := s.route(evt); ok {
+		return route, nil
+	}
+	return s.fallback.sel(evt)
+}
+
+func (s *routeSelector) route(evt *beat.Event) (string, bool) {
+	if evt == nil || evt.Meta == nil {
+		return "", false
+	}
+
+	v, err := evt.Meta.GetValue(s.key)
+	if err != nil {
+		return "", false
+	}
+
+	route, ok := v.(string)
+	if !ok {
+		debugRoute("Ignoring route @metadata.%v: value %v is no string", s.key, v)
+		return "", false
+	}
+	if route == "" {
+		return "", false
+	}
+
+	if s.validate != nil {
+		if err := s.validate(route); err != nil {
+			debugRoute("Ignoring invalid route @metadata.%v=%v: %v", s.key, route, err)
+			return "", false
+		}
+	}
+	return route, true
+}
@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestRouteSelector(t *testing.T) {
+	validate := func(s string) error {
+		if strings.ToLower(s) != s {
+			return errors.New("must be lowercase")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		title    string
+		meta     common.MapStr
+		expected string
+	}{
+		{
+			"no metadata uses configured selector",
+			nil,
+			"default-value",
+		},
+		{
+			"route from metadata",
+			common.MapStr{"output": common.MapStr{"key": "routed"}},
+			"routed",
+		},
+		{
+			"empty route uses configured selector",
+			common.MapStr{"output": common.MapStr{"key": ""}},
+			"default-value",
+		},
+		{
+			"non string route uses configured selector",
+			common.MapStr{"output": common.MapStr{"key": 1}},
+			"default-value",
+		},
+		{
+			"invalid route uses configured selector",
+			common.MapStr{"output": common.MapStr{"key": "Routed"}},
+			"default-value",
+		},
+		{
+			"other route is ignored",
+			common.MapStr{"output": common.MapStr{"other": "routed"}},
+			"default-value",
+		},
+	}
+
+	cfg, err := common.NewConfigWithYAML([]byte(`key: '%{[key]}-value'`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sel, err := BuildSelectorFromConfig(cfg, Settings{
+		Key:              "key",
+		MultiKey:         "keys",
+		EnableSingleOnly: true,
+		FailEmpty:        true,
+		RouteName:        "key",
+		ValidateRoute:    validate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.title, func(t *testing.T) {
+			event := beat.Event{
+				Timestamp: time.Now(),
+				Meta:      test.meta,
+				Fields:    common.MapStr{"key": "default"},
+			}
+			actual, err := sel.Select(&event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -42,6 +42,15 @@ type Settings struct {
 
 	// Fail building selector if `key` and `multiKey` are missing
 	FailEmpty bool
+
+	// if set, events can overwrite the configured selectors by setting
+	// `@metadata.output.<RouteName>`. The configured selectors are used as
+	// fallback.
+	RouteName string
+
+	// optional check run on routes read from event metadata. Routes failing
+	// validation are ignored.
+	ValidateRoute func(string) error
 }
 
 type SelectorExpr interface {
@@ -180,6 +189,11 @@ func BuildSelectorFromConfig(
 			multiKey, cfg.Path())
 	}
 
+	if settings.RouteName != "" {
+		fallback := MakeSelector(sel...).sel
+		return MakeSelector(RouteSelectorExpr(settings.RouteName, settings.ValidateRoute, fallback)), nil
+	}
+
 	return MakeSelector(sel...), nil
 }
 
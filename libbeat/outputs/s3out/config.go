@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3out
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/cfgtype"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+)
+
+type s3Config struct {
+	Bucket          string                    `config:"bucket"            validate:"required"`
+	Region          string                    `config:"region"            validate:"required"`
+	Endpoint        string                    `config:"endpoint"`
+	AccessKeyID     string                    `config:"access_key_id"`
+	SecretAccessKey string                    `config:"secret_access_key"`
+	SessionToken    string                    `config:"session_token"`
+	KeyPrefix       *fmtstr.EventFormatString `config:"key_prefix"`
+	MaxObjectSize   cfgtype.ByteSize          `config:"max_object_size"   validate:"min=1"`
+	FlushInterval   time.Duration             `config:"flush_interval"    validate:"min=1"`
+	Compression     string                    `config:"compression"`
+	Encoding        string                    `config:"encoding"`
+	SpoolPath       string                    `config:"spool_path"`
+	Timeout         time.Duration             `config:"timeout"           validate:"min=1"`
+	TLS             *tlscommon.Config         `config:"ssl"`
+	Codec           codec.Config              `config:"codec"`
+}
+
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+
+	encodingNDJSON = "ndjson"
+)
+
+var (
+	defaultConfig = s3Config{
+		KeyPrefix:     fmtstr.MustCompileEvent("%{[beat.name]}/%{+yyyy/MM/dd}/"),
+		MaxObjectSize: 64 * 1024 * 1024,
+		FlushInterval: 5 * time.Minute,
+		Compression:   compressionGzip,
+		Encoding:      encodingNDJSON,
+		Timeout:       90 * time.Second,
+	}
+)
+
+func (c *s3Config) Validate() error {
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	case "zstd":
+		return errors.New("zstd compression is not supported, use gzip or none")
+	default:
+		return fmt.Errorf("unknown compression '%v'", c.Compression)
+	}
+
+	switch c.Encoding {
+	case encodingNDJSON:
+	case "parquet":
+		return errors.New("parquet encoding is not supported, use ndjson")
+	default:
+		return fmt.Errorf("unknown encoding '%v'", c.Encoding)
+	}
+
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return errors.New("access_key_id and secret_access_key must be configured together")
+	}
+	return nil
+}
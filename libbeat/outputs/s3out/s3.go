@@ -0,0 +1,325 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3out
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/aws"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec"
+	"github.com/elastic/beats/libbeat/outputs/transport"
+	"github.com/elastic/beats/libbeat/paths"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+func init() {
+	outputs.RegisterType("s3", makeS3)
+}
+
+var debugf = logp.MakeDebug("s3")
+
+type client struct {
+	observer outputs.Observer
+	index    string
+	codec    codec.Codec
+	prefix   *fmtstr.EventFormatString
+
+	uploader      *uploader
+	compression   string
+	maxSize       int64
+	flushInterval time.Duration
+
+	mu    sync.Mutex
+	spool *spool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func makeS3(
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	cfgwarn.Beta("The s3 output is beta.")
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	enc, err := codec.CreateEncoder(beat, config.Codec)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	up, err := newUploader(&config, observer)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	spoolPath := config.SpoolPath
+	if spoolPath == "" {
+		spoolPath = "s3-spool"
+	}
+	s, err := newSpool(paths.Resolve(paths.Data, spoolPath))
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	c := newClient(observer, beat.Beat, enc, config.KeyPrefix, up, s, &config)
+	c.start()
+
+	// disable bulk splitting; batches failing to be written to the local spool
+	// are retried until they succeed
+	return outputs.Success(-1, -1, c)
+}
+
+func newUploader(config *s3Config, observer outputs.Observer) (*uploader, error) {
+	var endpoint *url.URL
+	if config.Endpoint != "" {
+		var err error
+		endpoint, err = url.Parse(config.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	creds := aws.NewCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken)
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := transport.StatsDialer(transport.NetDialer(config.Timeout), observer)
+	tlsDialer, err := transport.TLSDialer(transport.NetDialer(config.Timeout), tls, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	tlsDialer = transport.StatsDialer(tlsDialer, observer)
+
+	return &uploader{
+		http: &http.Client{
+			Transport: &http.Transport{
+				Dial:    dialer.Dial,
+				DialTLS: tlsDialer.Dial,
+				Proxy:   http.ProxyFromEnvironment,
+			},
+			Timeout: config.Timeout,
+		},
+		endpoint:    endpoint,
+		bucket:      config.Bucket,
+		region:      config.Region,
+		creds:       creds,
+		compression: config.Compression,
+	}, nil
+}
+
+func newClient(
+	observer outputs.Observer,
+	index string,
+	enc codec.Codec,
+	prefix *fmtstr.EventFormatString,
+	up *uploader,
+	s *spool,
+	config *s3Config,
+) *client {
+	return &client{
+		observer:      observer,
+		index:         index,
+		codec:         enc,
+		prefix:        prefix,
+		uploader:      up,
+		compression:   config.Compression,
+		maxSize:       int64(config.MaxObjectSize),
+		flushInterval: config.FlushInterval,
+		spool:         s,
+		done:          make(chan struct{}),
+	}
+}
+
+// start runs the background worker uploading objects once their flush
+// interval expired and retrying failed uploads.
+func (c *client) start() {
+	period := time.Second
+	if c.flushInterval < period {
+		period = c.flushInterval
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case now := <-ticker.C:
+				c.rollExpired(now)
+			}
+		}
+	}()
+}
+
+// Close uploads all buffered events. Objects failing to upload are kept in
+// the spool and uploaded on the next start.
+func (c *client) Close() error {
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	for _, f := range c.spool.open {
+		c.roll(f)
+	}
+	c.mu.Unlock()
+
+	c.uploadPending()
+	return nil
+}
+
+// Publish writes events to the spool. The batch is ACKed once the events are
+// synced to disk.
+func (c *client) Publish(batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dropped := 0
+	now := time.Now()
+	var full []*spoolFile
+	for i := range events {
+		event := &events[i]
+
+		prefix, err := c.prefix.Run(&event.Content)
+		if err != nil {
+			logp.Err("Dropping event: failed to build object key prefix: %v", err)
+			dropped++
+			continue
+		}
+
+		serialized, err := c.codec.Encode(c.index, &event.Content)
+		if err != nil {
+			if event.Guaranteed() {
+				logp.Critical("Failed to serialize the event: %v", err)
+			} else {
+				logp.Warn("Failed to serialize the event: %v", err)
+			}
+			dropped++
+			continue
+		}
+
+		f, err := c.spool.write(prefix, append(serialized, '\n'), now)
+		if err != nil {
+			c.observer.WriteError(err)
+			logp.Err("Writing events to the s3 spool failed with: %v", err)
+			c.observer.Failed(len(events) - dropped)
+			batch.Retry()
+			return err
+		}
+		if f.size >= c.maxSize {
+			full = append(full, f)
+		}
+	}
+
+	if err := c.spool.sync(); err != nil {
+		c.observer.WriteError(err)
+		logp.Err("Syncing the s3 spool failed with: %v", err)
+		c.observer.Failed(len(events) - dropped)
+		batch.Retry()
+		return err
+	}
+
+	c.observer.Dropped(dropped)
+	c.observer.Acked(len(events) - dropped)
+	batch.ACK()
+
+	// full objects are uploaded by the background worker
+	for _, f := range full {
+		if c.spool.open[f.prefix] == f {
+			c.roll(f)
+		}
+	}
+	return nil
+}
+
+// rollExpired closes all files older than the flush interval and uploads
+// pending objects.
+func (c *client) rollExpired(now time.Time) {
+	c.mu.Lock()
+	for _, f := range c.spool.open {
+		if now.Sub(f.created) >= c.flushInterval {
+			c.roll(f)
+		}
+	}
+	c.mu.Unlock()
+
+	c.uploadPending()
+}
+
+func (c *client) roll(f *spoolFile) {
+	if err := c.spool.closeFile(f); err != nil {
+		logp.Err("Failed to close s3 spool file %v: %v", f.path, err)
+	}
+}
+
+// uploadPending uploads all closed spool files, keeping those failing to
+// upload for the next attempt. The lock is only held while taking the pending
+// files, so publishing is not blocked by the uploads. Only the background
+// worker, or Close once the worker is stopped, calls uploadPending.
+func (c *client) uploadPending() {
+	c.mu.Lock()
+	pending := c.spool.pending
+	c.spool.pending = nil
+	c.mu.Unlock()
+
+	var failed []*spoolFile
+	for _, f := range pending {
+		key := f.objectKey(c.compression)
+		if err := c.uploader.put(key, f.path); err != nil {
+			logp.Err("Failed to upload s3 object %v: %v", key, err)
+			failed = append(failed, f)
+			continue
+		}
+
+		debugf("Uploaded s3 object %v", key)
+		if err := os.Remove(f.path); err != nil {
+			logp.Err("Failed to remove s3 spool file %v: %v", f.path, err)
+		}
+	}
+
+	// files rolled during the uploads are kept after the failed ones
+	c.mu.Lock()
+	c.spool.pending = append(failed, c.spool.pending...)
+	c.mu.Unlock()
+}
@@ -0,0 +1,282 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3out
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/aws"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/libbeat/outputs/outest"
+)
+
+type mockS3 struct {
+	sync.Mutex
+	fail    bool
+	objects map[string][]byte
+	auth    []string
+}
+
+func (s *mockS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.fail || req.Method != "PUT" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Header.Get("Content-Type") == "application/gzip" {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(r)
+	}
+	s.objects[req.URL.Path] = body
+	s.auth = append(s.auth, req.Header.Get("Authorization"))
+}
+
+func (s *mockS3) keys() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, dir string, maxSize int64) *client {
+	endpoint, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	config.FlushInterval = time.Minute
+
+	up := &uploader{
+		http:        server.Client(),
+		endpoint:    endpoint,
+		bucket:      "bucket",
+		region:      "us-east-1",
+		creds:       aws.Credentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+		compression: compressionGzip,
+	}
+	c := newClient(outputs.NewNilObserver(), "test", json.New(false, "1.0.0"),
+		fmtstr.MustCompileEvent("logs/%{[tenant]}/"), up, s, &config)
+	c.maxSize = maxSize
+	return c
+}
+
+func testEvents(tenants ...string) []beat.Event {
+	events := make([]beat.Event, len(tenants))
+	for i, tenant := range tenants {
+		events[i] = beat.Event{
+			Timestamp: time.Now(),
+			Fields:    common.MapStr{"tenant": tenant, "message": "hello"},
+		}
+	}
+	return events
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "s3out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPublishRollsOnInterval(t *testing.T) {
+	s3 := &mockS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := newTestClient(t, server, dir, 1024*1024)
+	batch := outest.NewBatch(testEvents("a", "b", "a")...)
+	assert.NoError(t, c.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	}
+
+	// nothing is uploaded before the flush interval expires
+	c.rollExpired(time.Now())
+	assert.Empty(t, s3.keys())
+
+	c.rollExpired(time.Now().Add(time.Minute))
+	keys := s3.keys()
+	if !assert.Len(t, keys, 2) {
+		return
+	}
+	for _, key := range keys {
+		assert.True(t, strings.HasPrefix(key, "/bucket/logs/"), key)
+		assert.True(t, strings.HasSuffix(key, ".ndjson.gz"), key)
+
+		lines := bytes.Count(s3.objects[key], []byte("\n"))
+		if strings.HasPrefix(key, "/bucket/logs/a/") {
+			assert.Equal(t, 2, lines)
+		} else {
+			assert.Equal(t, 1, lines)
+		}
+	}
+	for _, auth := range s3.auth {
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/"), auth)
+	}
+
+	// uploaded objects are removed from the spool
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestPublishRollsOnSize(t *testing.T) {
+	s3 := &mockS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := newTestClient(t, server, dir, 1)
+	assert.NoError(t, c.Publish(outest.NewBatch(testEvents("a")...)))
+	assert.NoError(t, c.Publish(outest.NewBatch(testEvents("a")...)))
+
+	c.rollExpired(time.Now())
+	assert.Len(t, s3.keys(), 2)
+}
+
+func TestSpoolRecovery(t *testing.T) {
+	s3 := &mockS3{objects: map[string][]byte{}, fail: true}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := newTestClient(t, server, dir, 1024*1024)
+	assert.NoError(t, c.Publish(outest.NewBatch(testEvents("a")...)))
+	c.rollExpired(time.Now().Add(time.Minute))
+	assert.Empty(t, s3.keys())
+	assert.Len(t, c.spool.pending, 1)
+	expectedKey := "/bucket/" + c.spool.pending[0].objectKey(compressionGzip)
+
+	// a new client picks up the objects left in the spool
+	s3.Lock()
+	s3.fail = false
+	s3.Unlock()
+
+	c = newTestClient(t, server, dir, 1024*1024)
+	c.rollExpired(time.Now())
+	assert.Equal(t, []string{expectedKey}, s3.keys())
+}
+
+func TestPublishDuringUpload(t *testing.T) {
+	uploading := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(uploading)
+		<-release
+	}))
+	defer server.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := newTestClient(t, server, dir, 1024*1024)
+	assert.NoError(t, c.Publish(outest.NewBatch(testEvents("a")...)))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.rollExpired(time.Now().Add(time.Minute))
+	}()
+	<-uploading
+
+	// events are written to the spool while the upload is in progress
+	batch := outest.NewBatch(testEvents("b")...)
+	assert.NoError(t, c.Publish(batch))
+	if assert.Len(t, batch.Signals, 1) {
+		assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	}
+
+	close(release)
+	<-done
+	assert.Empty(t, c.spool.pending)
+	assert.Len(t, c.spool.open, 1)
+}
+
+func TestObjectURL(t *testing.T) {
+	up := &uploader{bucket: "bucket", region: "eu-west-1"}
+	u := up.objectURL("logs/2018/09/01/host name+1.ndjson")
+	assert.Equal(t, "https://bucket.s3.eu-west-1.amazonaws.com/logs/2018/09/01/host%20name%2B1.ndjson", u.String())
+
+	endpoint, _ := url.Parse("http://localhost:9000")
+	up.endpoint = endpoint
+	u = up.objectURL("logs/a.ndjson")
+	assert.Equal(t, "http://localhost:9000/bucket/logs/a.ndjson", u.String())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]common.MapStr{
+		"zstd":                  {"compression": "zstd"},
+		"parquet":               {"encoding": "parquet"},
+		"secret without key id": {"secret_access_key": "secret"},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings["bucket"] = "bucket"
+			settings["region"] = "us-east-1"
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3out
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// spool buffers events on disk until they are uploaded. One file is kept
+// open per object key prefix. Files are named after the key prefix and an
+// object ID, so the object key can be restored after a restart.
+type spool struct {
+	dir  string
+	open map[string]*spoolFile
+
+	// files closed, but not uploaded yet
+	pending []*spoolFile
+}
+
+type spoolFile struct {
+	prefix  string
+	id      string
+	path    string
+	file    *os.File
+	size    int64
+	created time.Time
+	dirty   bool
+}
+
+const spoolFileExt = ".part"
+
+func newSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	s := &spool{dir: dir, open: map[string]*spoolFile{}}
+
+	// files left over by a previous run are uploaded first
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolFileExt) {
+			continue
+		}
+		f, err := parseSpoolFile(dir, entry.Name())
+		if err != nil {
+			debugf("Ignoring spool file %v: %v", entry.Name(), err)
+			continue
+		}
+		s.pending = append(s.pending, f)
+	}
+	return s, nil
+}
+
+func parseSpoolFile(dir, name string) (*spoolFile, error) {
+	parts := strings.Split(strings.TrimSuffix(name, spoolFileExt), ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unexpected file name")
+	}
+	prefix, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	return &spoolFile{
+		prefix: string(prefix),
+		id:     parts[1],
+		path:   filepath.Join(dir, name),
+	}, nil
+}
+
+// write appends data to the file for the given key prefix.
+func (s *spool) write(prefix string, data []byte, now time.Time) (*spoolFile, error) {
+	f := s.open[prefix]
+	if f == nil {
+		id, err := newObjectID(now)
+		if err != nil {
+			return nil, err
+		}
+		name := base64.RawURLEncoding.EncodeToString([]byte(prefix)) + "." + id + spoolFileExt
+		f = &spoolFile{
+			prefix:  prefix,
+			id:      id,
+			path:    filepath.Join(s.dir, name),
+			created: now,
+		}
+		f.file, err = os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, err
+		}
+		s.open[prefix] = f
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	f.dirty = true
+	return f, err
+}
+
+// sync flushes all files written to since the last sync to disk.
+func (s *spool) sync() error {
+	for _, f := range s.open {
+		if !f.dirty {
+			continue
+		}
+		if err := f.file.Sync(); err != nil {
+			return err
+		}
+		f.dirty = false
+	}
+	return nil
+}
+
+// closeFile closes a file and moves it to the list of pending uploads.
+func (s *spool) closeFile(f *spoolFile) error {
+	delete(s.open, f.prefix)
+	s.pending = append(s.pending, f)
+	return f.file.Close()
+}
+
+func (f *spoolFile) objectKey(compression string) string {
+	key := f.prefix + f.id + "." + encodingNDJSON
+	if compression == compressionGzip {
+		key += ".gz"
+	}
+	return key
+}
+
+func newObjectID(now time.Time) (string, error) {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(buf[:]), nil
+}
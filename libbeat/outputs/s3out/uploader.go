@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package s3out
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/aws"
+)
+
+// uploader puts objects into a S3 bucket.
+type uploader struct {
+	http        *http.Client
+	endpoint    *url.URL
+	bucket      string
+	region      string
+	creds       aws.Credentials
+	compression string
+}
+
+// objectURL returns the URL of an object. Virtual hosted style URLs are used
+// for AWS, path style URLs for custom endpoints.
+func (u *uploader) objectURL(key string) *url.URL {
+	target := &url.URL{Scheme: "https"}
+	path := "/" + key
+	if u.endpoint == nil {
+		target.Host = u.bucket + ".s3." + u.region + ".amazonaws.com"
+	} else {
+		target.Scheme = u.endpoint.Scheme
+		target.Host = u.endpoint.Host
+		path = strings.TrimSuffix(u.endpoint.Path, "/") + "/" + u.bucket + path
+	}
+	target.Path = path
+	target.RawPath = escapePath(path)
+	return target
+}
+
+// put uploads the contents of the file at path as an object.
+func (u *uploader) put(key, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	contentType := "application/x-ndjson"
+	if u.compression == compressionGzip {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		content = buf.Bytes()
+		contentType = "application/gzip"
+	}
+
+	req, err := http.NewRequest("PUT", u.objectURL(key).String(), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	aws.Sign(req, u.creds, u.region, "s3", aws.HashHex(content), time.Now())
+
+	resp, err := u.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("uploading object %v failed: %v: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// escapePath URI encodes each path segment as required by S3.
+func escapePath(path string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
@@ -117,7 +117,7 @@ func tlsDialWith(
 		}
 	}
 
-	if tlsConfig.InsecureSkipVerify {
+	if config != nil && config.Verification != VerifyFull {
 		d.Warn("security", "server's certificate chain verification is disabled")
 	} else {
 		d.Info("security", "server's certificate chain verification is enabled")
@@ -173,5 +173,10 @@ func postVerifyTLSConnection(d testing.Driver, conn *tls.Conn, config *TLSConfig
 		return err
 	}
 
+	if err := config.VerifyOCSPStaple(st); err != nil {
+		d.Fatal("OCSP stapling", err)
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,323 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type decodeCSVFields struct {
+	config    decodeCSVFieldsConfig
+	separator rune
+	columns   []string
+
+	// headers contains the column names read from the first record of each source
+	headers *common.Cache
+}
+
+const (
+	// headersTimeout is the time after which the header of a source that has no
+	// new events is forgotten.
+	headersTimeout = time.Hour
+
+	// maxHeaders is the maximum number of sources a header is kept for.
+	maxHeaders = 1024
+)
+
+type decodeCSVFieldsConfig struct {
+	Field            string            `config:"field"`
+	Target           *string           `config:"target"`
+	Separator        string            `config:"separator"`
+	Columns          []string          `config:"columns"`
+	Header           bool              `config:"header"`
+	Convert          map[string]string `config:"convert"`
+	TrimLeadingSpace bool              `config:"trim_leading_space"`
+	IgnoreMissing    bool              `config:"ignore_missing"`
+	OverwriteKeys    bool              `config:"overwrite_keys"`
+}
+
+func init() {
+	processors.RegisterPlugin("decode_csv_fields",
+		configChecked(newDecodeCSVFields,
+			requireFields("field"),
+			allowedFields("field", "target", "separator", "columns", "header", "convert",
+				"trim_leading_space", "ignore_missing", "overwrite_keys", "when")))
+}
+
+func newDecodeCSVFields(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("Beta decode_csv_fields processor is used.")
+	config := decodeCSVFieldsConfig{
+		Separator: ",",
+	}
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, fmt.Errorf("fail to unpack the decode_csv_fields configuration: %s", err)
+	}
+
+	if utf8.RuneCountInString(config.Separator) != 1 {
+		return nil, fmt.Errorf("separator must be a single character, got `%s`", config.Separator)
+	}
+	separator, _ := utf8.DecodeRuneInString(config.Separator)
+
+	if config.Header && len(config.Columns) > 0 {
+		return nil, errors.New("columns and header options are mutually exclusive")
+	}
+
+	named := config.Header || len(config.Columns) > 0
+	if !named && (config.Target == nil || *config.Target == "") {
+		return nil, errors.New("a target is required when the columns are not named")
+	}
+
+	for column, typ := range config.Convert {
+		switch typ {
+		case "integer", "float", "boolean", "string":
+		default:
+			return nil, fmt.Errorf("unsupported type `%s` for column `%s`", typ, column)
+		}
+	}
+
+	f := &decodeCSVFields{
+		config:    config,
+		separator: separator,
+		columns:   config.Columns,
+		headers:   common.NewCache(headersTimeout, 0),
+	}
+	return f, nil
+}
+
+func (f *decodeCSVFields) Run(event *beat.Event) (*beat.Event, error) {
+	data, err := event.GetValue(f.config.Field)
+	if err != nil {
+		if f.config.IgnoreMissing && errors.Cause(err) == common.ErrKeyNotFound {
+			return event, nil
+		}
+		return event, fmt.Errorf("could not fetch value for key: %s, Error: %s", f.config.Field, err)
+	}
+
+	text, ok := data.(string)
+	if !ok {
+		return event, fmt.Errorf("field `%s` is not a string", f.config.Field)
+	}
+
+	record, err := f.decode(text)
+	if err != nil {
+		return event, errors.Wrapf(err, "could not decode the CSV record in field `%s`", f.config.Field)
+	}
+
+	columns, isHeader := f.columnNames(event, record)
+	if isHeader {
+		// The header record only defines the column names, it doesn't need to be published.
+		return nil, nil
+	}
+
+	target := ""
+	if f.config.Target != nil {
+		target = *f.config.Target
+	}
+
+	if columns == nil {
+		if err := f.put(event, target, record); err != nil {
+			return event, err
+		}
+		return event, nil
+	}
+
+	var errs []string
+	for i, value := range record {
+		column := "column" + strconv.Itoa(i+1)
+		if i < len(columns) && columns[i] != "" {
+			column = columns[i]
+		}
+
+		v, err := f.convert(column, value)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		key := column
+		if target != "" {
+			key = target + "." + column
+		}
+		if err := f.put(event, key, v); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return event, errors.New(strings.Join(errs, ", "))
+	}
+	return event, nil
+}
+
+func (f *decodeCSVFields) decode(text string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = f.separator
+	reader.TrimLeadingSpace = f.config.TrimLeadingSpace
+	reader.FieldsPerRecord = -1
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := reader.Read(); err == nil {
+		return nil, errors.New("multiple CSV records found")
+	}
+	return record, nil
+}
+
+// columnNames returns the names of the columns, when the names are read from the first record
+// of the source it returns true if the record is the header.
+//
+// The record read at offset 0 is the header of the source, so a file rotated or truncated
+// at the same path gets its new header. Without offset, the first record seen for the source
+// is the header.
+func (f *decodeCSVFields) columnNames(event *beat.Event, record []string) ([]string, bool) {
+	if !f.config.Header {
+		return f.columns, false
+	}
+
+	source := eventSource(event)
+	offset, hasOffset := eventOffset(event)
+	switch {
+	case hasOffset && offset == 0:
+		f.makeRoom(source)
+		f.headers.Put(source, record)
+		return nil, true
+
+	case hasOffset:
+		if columns, ok := f.headers.Get(source).([]string); ok {
+			return columns, false
+		}
+		// The header of the source was not seen, the values are named by their position.
+		return []string{}, false
+	}
+
+	if columns, ok := f.headers.Get(source).([]string); ok {
+		return columns, false
+	}
+	f.makeRoom(source)
+	if columns, ok := f.headers.PutIfAbsent(source, record).([]string); ok {
+		return columns, false
+	}
+	return nil, true
+}
+
+// makeRoom removes the expired headers, and an arbitrary one if the maximum number of
+// sources is still reached, before the header of source is stored.
+func (f *decodeCSVFields) makeRoom(source string) {
+	if f.headers.Get(source) != nil || f.headers.Size() < maxHeaders {
+		return
+	}
+
+	f.headers.CleanUp()
+	if f.headers.Size() < maxHeaders {
+		return
+	}
+	for k := range f.headers.Entries() {
+		f.headers.Delete(k)
+		return
+	}
+}
+
+// eventSource returns the source the event was read from, so headers of different
+// files are not mixed.
+func eventSource(event *beat.Event) string {
+	for _, key := range []string{"log.file.path", "source"} {
+		if v, err := event.GetValue(key); err == nil {
+			if source, ok := v.(string); ok {
+				return source
+			}
+		}
+	}
+	return ""
+}
+
+// eventOffset returns the offset in the source the event was read at.
+func eventOffset(event *beat.Event) (int64, bool) {
+	for _, key := range []string{"log.offset", "offset"} {
+		v, err := event.GetValue(key)
+		if err != nil {
+			continue
+		}
+		switch offset := v.(type) {
+		case int64:
+			return offset, true
+		case int:
+			return int64(offset), true
+		case uint64:
+			return int64(offset), true
+		}
+	}
+	return 0, false
+}
+
+func (f *decodeCSVFields) convert(column, value string) (interface{}, error) {
+	switch f.config.Convert[column] {
+	case "integer":
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert column `%s` to integer: %v", column, err)
+		}
+		return v, nil
+	case "float":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert column `%s` to float: %v", column, err)
+		}
+		return v, nil
+	case "boolean":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert column `%s` to boolean: %v", column, err)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+func (f *decodeCSVFields) put(event *beat.Event, key string, value interface{}) error {
+	if !f.config.OverwriteKeys {
+		if exists, _ := event.Fields.HasKey(key); exists {
+			return fmt.Errorf("target field %s already exists, drop or rename this field first", key)
+		}
+	}
+
+	if _, err := event.PutValue(key, value); err != nil {
+		return fmt.Errorf("could not put value: %s: %v, %+v", key, value, err)
+	}
+	return nil
+}
+
+func (f *decodeCSVFields) String() string {
+	return fmt.Sprintf("decode_csv_fields=[field=%s, separator=%s, columns=%v]",
+		f.config.Field, f.config.Separator, f.config.Columns)
+}
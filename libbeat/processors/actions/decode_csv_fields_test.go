@@ -0,0 +1,339 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestDecodeCSVFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		input    common.MapStr
+		expected common.MapStr
+	}{
+		{
+			name:     "without columns",
+			config:   map[string]interface{}{"field": "message", "target": "csv"},
+			input:    common.MapStr{"message": `a,"b,c",d`},
+			expected: common.MapStr{"message": `a,"b,c",d`, "csv": []string{"a", "b,c", "d"}},
+		},
+		{
+			name: "named columns at the root",
+			config: map[string]interface{}{
+				"field":   "message",
+				"columns": []string{"time", "elb", "client"},
+			},
+			input: common.MapStr{"message": "2018-05-01T00:00:00Z,my-elb,10.0.0.1:443"},
+			expected: common.MapStr{
+				"message": "2018-05-01T00:00:00Z,my-elb,10.0.0.1:443",
+				"time":    "2018-05-01T00:00:00Z",
+				"elb":     "my-elb",
+				"client":  "10.0.0.1:443",
+			},
+		},
+		{
+			name: "extra values and custom separator",
+			config: map[string]interface{}{
+				"field":              "message",
+				"target":             "csv",
+				"separator":          ";",
+				"trim_leading_space": true,
+				"columns":            []string{"a"},
+			},
+			input: common.MapStr{"message": "1; 2"},
+			expected: common.MapStr{
+				"message": "1; 2",
+				"csv":     common.MapStr{"a": "1", "column2": "2"},
+			},
+		},
+		{
+			name: "type coercion",
+			config: map[string]interface{}{
+				"field":   "message",
+				"target":  "csv",
+				"columns": []string{"bytes", "duration", "cached", "name"},
+				"convert": map[string]string{"bytes": "integer", "duration": "float", "cached": "boolean"},
+			},
+			input: common.MapStr{"message": "1024,0.5,true,index"},
+			expected: common.MapStr{
+				"message": "1024,0.5,true,index",
+				"csv": common.MapStr{
+					"bytes":    int64(1024),
+					"duration": 0.5,
+					"cached":   true,
+					"name":     "index",
+				},
+			},
+		},
+		{
+			name: "ignore missing",
+			config: map[string]interface{}{
+				"field":          "message",
+				"target":         "csv",
+				"ignore_missing": true,
+			},
+			input:    common.MapStr{"other": "a,b"},
+			expected: common.MapStr{"other": "a,b"},
+		},
+		{
+			name: "overwrite keys",
+			config: map[string]interface{}{
+				"field":          "message",
+				"columns":        []string{"message"},
+				"overwrite_keys": true,
+			},
+			input:    common.MapStr{"message": "a"},
+			expected: common.MapStr{"message": "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := common.NewConfigFrom(test.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			p, err := newDecodeCSVFields(config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			actual, err := p.Run(&beat.Event{Fields: test.input})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, test.expected, actual.Fields)
+		})
+	}
+}
+
+func TestDecodeCSVFieldsHeader(t *testing.T) {
+	config, err := common.NewConfigFrom(map[string]interface{}{
+		"field":  "message",
+		"target": "csv",
+		"header": true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newDecodeCSVFields(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	header, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "host,status"}})
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+
+	actual, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "localhost,200"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, common.MapStr{
+		"message": "localhost,200",
+		"csv":     common.MapStr{"host": "localhost", "status": "200"},
+	}, actual.Fields)
+}
+
+func TestDecodeCSVFieldsHeaderPerSource(t *testing.T) {
+	config, err := common.NewConfigFrom(map[string]interface{}{
+		"field":  "message",
+		"target": "csv",
+		"header": true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newDecodeCSVFields(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	event := func(source, message string) *beat.Event {
+		return &beat.Event{Fields: common.MapStr{"source": source, "message": message}}
+	}
+
+	for _, e := range []*beat.Event{event("a.csv", "host,status"), event("b.csv", "user,id")} {
+		header, err := p.Run(e)
+		assert.NoError(t, err)
+		assert.Nil(t, header)
+	}
+
+	actual, err := p.Run(event("b.csv", "john,1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"user": "john", "id": "1"}, actual.Fields["csv"])
+	}
+
+	actual, err = p.Run(event("a.csv", "localhost,200"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"host": "localhost", "status": "200"}, actual.Fields["csv"])
+	}
+}
+
+func TestDecodeCSVFieldsHeaderOffset(t *testing.T) {
+	config, err := common.NewConfigFrom(map[string]interface{}{
+		"field":  "message",
+		"target": "csv",
+		"header": true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newDecodeCSVFields(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	event := func(source string, offset int64, message string) *beat.Event {
+		return &beat.Event{Fields: common.MapStr{"source": source, "offset": offset, "message": message}}
+	}
+
+	header, err := p.Run(event("a.csv", 0, "host,status"))
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+
+	actual, err := p.Run(event("a.csv", 12, "localhost,200"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"host": "localhost", "status": "200"}, actual.Fields["csv"])
+	}
+
+	// the file is rotated and written with a new header
+	header, err = p.Run(event("a.csv", 0, "user,id"))
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+
+	actual, err = p.Run(event("a.csv", 8, "john,1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"user": "john", "id": "1"}, actual.Fields["csv"])
+	}
+
+	// the header of b.csv was not read, the record is not taken as header
+	actual, err = p.Run(event("b.csv", 20, "jane,2"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"column1": "jane", "column2": "2"}, actual.Fields["csv"])
+	}
+}
+
+func TestDecodeCSVFieldsMaxHeaders(t *testing.T) {
+	config, err := common.NewConfigFrom(map[string]interface{}{
+		"field":  "message",
+		"target": "csv",
+		"header": true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newDecodeCSVFields(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < maxHeaders+10; i++ {
+		source := fmt.Sprintf("%d.csv", i)
+		_, err := p.Run(&beat.Event{Fields: common.MapStr{"source": source, "offset": int64(0), "message": "a,b"}})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, maxHeaders, p.(*decodeCSVFields).headers.Size())
+}
+
+func TestDecodeCSVFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		input  common.MapStr
+	}{
+		{
+			name:   "key exists",
+			config: map[string]interface{}{"field": "message", "columns": []string{"message"}},
+			input:  common.MapStr{"message": "a"},
+		},
+		{
+			name:   "invalid record",
+			config: map[string]interface{}{"field": "message", "target": "csv"},
+			input:  common.MapStr{"message": `a,"b`},
+		},
+		{
+			name: "conversion failure",
+			config: map[string]interface{}{
+				"field":   "message",
+				"columns": []string{"bytes"},
+				"convert": map[string]string{"bytes": "integer"},
+			},
+			input: common.MapStr{"message": "many"},
+		},
+		{
+			name:   "missing field",
+			config: map[string]interface{}{"field": "message", "target": "csv"},
+			input:  common.MapStr{"other": "a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := common.NewConfigFrom(test.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			p, err := newDecodeCSVFields(config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = p.Run(&beat.Event{Fields: test.input})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeCSVFieldsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "missing target", config: map[string]interface{}{"field": "message"}},
+		{name: "invalid separator", config: map[string]interface{}{"field": "message", "target": "csv", "separator": ";;"}},
+		{name: "columns and header", config: map[string]interface{}{"field": "message", "columns": []string{"a"}, "header": true}},
+		{name: "unsupported type", config: map[string]interface{}{"field": "message", "header": true, "convert": map[string]string{"a": "date"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := common.NewConfigFrom(test.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = newDecodeCSVFields(config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type expressionFields struct {
+	config expressionFieldsConfig
+	fields []expressionField
+}
+
+type expressionFieldsConfig struct {
+	Fields        []expressionFieldConfig `config:"fields"`
+	IgnoreMissing bool                    `config:"ignore_missing"`
+	FailOnError   bool                    `config:"fail_on_error"`
+}
+
+type expressionFieldConfig struct {
+	Target     string `config:"target"`
+	Expression string `config:"expression"`
+}
+
+type expressionField struct {
+	target string
+	expr   *processors.Expression
+}
+
+func init() {
+	processors.RegisterPlugin("expression",
+		configChecked(newExpressionFields,
+			requireFields("fields"),
+			allowedFields("fields", "ignore_missing", "fail_on_error", "when")))
+}
+
+func newExpressionFields(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("Beta expression processor is used.")
+	config := expressionFieldsConfig{
+		IgnoreMissing: false,
+		FailOnError:   true,
+	}
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack the expression configuration: %s", err)
+	}
+
+	f := &expressionFields{config: config}
+	for _, field := range config.Fields {
+		if field.Target == "" || field.Expression == "" {
+			return nil, fmt.Errorf("target and expression must be set for all fields in %v", c.Path())
+		}
+
+		expr, err := processors.CompileExpression(field.Expression)
+		if err != nil {
+			return nil, err
+		}
+		f.fields = append(f.fields, expressionField{target: field.Target, expr: expr})
+	}
+	return f, nil
+}
+
+// Run evaluates the expressions in order, so expressions can refer to the
+// results of previous expressions.
+func (f *expressionFields) Run(event *beat.Event) (*beat.Event, error) {
+	var backup common.MapStr
+	// Creates a copy of the event to revert in case of failure
+	if f.config.FailOnError {
+		backup = event.Fields.Clone()
+	}
+
+	for _, field := range f.fields {
+		err := f.evalField(event, field)
+		if err != nil && f.config.FailOnError {
+			logp.Debug("expression", "Failed to evaluate expressions, revert to old event: %s", err)
+			event.Fields = backup
+			return event, err
+		}
+	}
+
+	return event, nil
+}
+
+func (f *expressionFields) evalField(event *beat.Event, field expressionField) error {
+	value, ok := field.expr.Eval(event)
+	if !ok {
+		if f.config.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("expression `%s` for %s is undefined", field.expr, field.target)
+	}
+
+	// copy objects, so the target doesn't share the value with the source field
+	if m, ok := value.(common.MapStr); ok {
+		value = m.Clone()
+	}
+
+	if _, err := event.PutValue(field.target, value); err != nil {
+		return fmt.Errorf("could not put value: %s: %v, %+v", field.target, value, err)
+	}
+	return nil
+}
+
+func (f *expressionFields) String() string {
+	exprs := make([]string, len(f.fields))
+	for i, field := range f.fields {
+		exprs[i] = field.target + "=" + field.expr.String()
+	}
+	return "expression=[" + strings.Join(exprs, ", ") + "]"
+}
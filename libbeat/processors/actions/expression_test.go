@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+func newTestExpressionFields(t testing.TB, settings map[string]interface{}) processors.Processor {
+	c, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := newExpressionFields(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExpressionFields(t *testing.T) {
+	p := newTestExpressionFields(t, map[string]interface{}{
+		"fields": []map[string]interface{}{
+			{"target": "http.response.kb", "expression": "http.response.bytes / 1024"},
+			{"target": "event.duration_ms", "expression": "event.duration / 1ms"},
+			{"target": "event.slow", "expression": "event.duration_ms > 100"},
+			{"target": "url.full", "expression": `url.scheme + "://" + url.domain`},
+			{"target": "destination", "expression": "source"},
+		},
+	})
+
+	event := &beat.Event{
+		Timestamp: time.Now(),
+		Fields: common.MapStr{
+			"http":   common.MapStr{"response": common.MapStr{"bytes": 2048}},
+			"event":  common.MapStr{"duration": int64(250 * time.Millisecond)},
+			"url":    common.MapStr{"scheme": "https", "domain": "elastic.co"},
+			"source": common.MapStr{"ip": "10.0.0.1"},
+		},
+	}
+	event, err := p.Run(event)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := common.MapStr{
+		"http":        common.MapStr{"response": common.MapStr{"bytes": 2048, "kb": 2.0}},
+		"event":       common.MapStr{"duration": int64(250 * time.Millisecond), "duration_ms": 250.0, "slow": true},
+		"url":         common.MapStr{"scheme": "https", "domain": "elastic.co", "full": "https://elastic.co"},
+		"source":      common.MapStr{"ip": "10.0.0.1"},
+		"destination": common.MapStr{"ip": "10.0.0.1"},
+	}
+	assert.Equal(t, expected, event.Fields)
+
+	// objects are copied
+	event.Fields.Put("destination.ip", "10.0.0.2")
+	v, _ := event.GetValue("source.ip")
+	assert.Equal(t, "10.0.0.1", v)
+}
+
+func TestExpressionFieldsMissing(t *testing.T) {
+	tests := map[string]struct {
+		ignoreMissing bool
+		failOnError   bool
+		expected      common.MapStr
+		err           bool
+	}{
+		"fail on error": {
+			failOnError: true,
+			expected:    common.MapStr{"a": 1},
+			err:         true,
+		},
+		"continue on error": {
+			failOnError: false,
+			expected:    common.MapStr{"a": 1, "b": int64(2), "d": int64(3)},
+		},
+		"ignore missing": {
+			ignoreMissing: true,
+			failOnError:   true,
+			expected:      common.MapStr{"a": 1, "b": int64(2), "d": int64(3)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestExpressionFields(t, map[string]interface{}{
+				"fields": []map[string]interface{}{
+					{"target": "b", "expression": "a + 1"},
+					{"target": "c", "expression": "missing + 1"},
+					{"target": "d", "expression": "b + 1"},
+				},
+				"ignore_missing": test.ignoreMissing,
+				"fail_on_error":  test.failOnError,
+			})
+
+			event, err := p.Run(&beat.Event{Fields: common.MapStr{"a": 1}})
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, event.Fields)
+		})
+	}
+}
+
+func TestExpressionFieldsConfigErrors(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no fields":          {},
+		"missing target":     {"fields": []map[string]interface{}{{"expression": "a + 1"}}},
+		"invalid expression": {"fields": []map[string]interface{}{{"target": "b", "expression": "a +"}}},
+		"unknown option":     {"fields": []map[string]interface{}{{"target": "b", "expression": "a"}}, "lang": "js"},
+	}
+
+	f := processors.Constructor(configChecked(newExpressionFields,
+		requireFields("fields"),
+		allowedFields("fields", "ignore_missing", "fail_on_error", "when")))
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f(c)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func BenchmarkExpressionFields(b *testing.B) {
+	p := newTestExpressionFields(b, map[string]interface{}{
+		"fields": []map[string]interface{}{
+			{"target": "http.response.kb", "expression": "http.response.bytes / 1024"},
+		},
+		"fail_on_error": false,
+	})
+
+	event := &beat.Event{Fields: common.MapStr{
+		"http": common.MapStr{"response": common.MapStr{"bytes": 2048}},
+	}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Run(event)
+	}
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs/outil"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type route struct {
+	config routeConfig
+	topic  *fmtstr.EventFormatString
+	index  *fmtstr.EventFormatString
+}
+
+type routeConfig struct {
+	Topic string `config:"topic"`
+	Index string `config:"index"`
+}
+
+func init() {
+	processors.RegisterPlugin("route",
+		configChecked(newRoute,
+			allowedFields("topic", "index", "when")))
+}
+
+func newRoute(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("Beta route processor is used.")
+
+	config := routeConfig{}
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack the route configuration: %s", err)
+	}
+	if config.Topic == "" && config.Index == "" {
+		return nil, fmt.Errorf("one of topic or index must be configured in %v", c.Path())
+	}
+
+	r := &route{config: config}
+	var err error
+	if config.Topic != "" {
+		if r.topic, err = fmtstr.CompileEvent(config.Topic); err != nil {
+			return nil, fmt.Errorf("invalid topic format string: %v", err)
+		}
+	}
+	if config.Index != "" {
+		if r.index, err = fmtstr.CompileEvent(config.Index); err != nil {
+			return nil, fmt.Errorf("invalid index format string: %v", err)
+		}
+	}
+	return r, nil
+}
+
+func (r *route) Run(event *beat.Event) (*beat.Event, error) {
+	r.set(event, "topic", r.topic)
+	r.set(event, "index", r.index)
+	return event, nil
+}
+
+// set stores the route in the event metadata. If the format string can not be
+// evaluated a warning is logged and no route is set, so outputs fall back to
+// their configured settings.
+func (r *route) set(event *beat.Event, name string, format *fmtstr.EventFormatString) {
+	if format == nil {
+		return
+	}
+
+	value, err := format.Run(event)
+	if err != nil {
+		logp.Warn("No %v route set for event: %v", name, err)
+		return
+	}
+	if value == "" {
+		logp.Warn("No %v route set for event: the %v evaluates to an empty string", name, name)
+		return
+	}
+
+	if event.Meta == nil {
+		event.Meta = common.MapStr{}
+	}
+	event.Meta.Put(outil.RouteMetaPrefix+"."+name, value)
+}
+
+func (r *route) String() string {
+	return fmt.Sprintf("route=[topic=%v, index=%v]", r.config.Topic, r.config.Index)
+}
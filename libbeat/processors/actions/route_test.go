@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestRoute(t *testing.T) {
+	tests := map[string]struct {
+		config   common.MapStr
+		fields   common.MapStr
+		expected common.MapStr
+	}{
+		"topic and index": {
+			config: common.MapStr{
+				"topic": "logs-%{[tenant]}",
+				"index": "logs-%{[tenant]}-%{+yyyy.MM.dd}",
+			},
+			fields: common.MapStr{"tenant": "a"},
+			expected: common.MapStr{
+				"output": common.MapStr{
+					"topic": "logs-a",
+					"index": "logs-a-2018.09.01",
+				},
+			},
+		},
+		"missing field sets no route": {
+			config:   common.MapStr{"topic": "logs-%{[tenant]}"},
+			fields:   common.MapStr{},
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := common.NewConfigFrom(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := newRoute(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event := &beat.Event{
+				Timestamp: time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+				Fields:    test.fields,
+			}
+			event, err = p.Run(event)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, event.Meta)
+		})
+	}
+}
+
+func TestRouteRequiresTarget(t *testing.T) {
+	config, err := common.NewConfigFrom(common.MapStr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newRoute(config)
+	assert.Error(t, err)
+}
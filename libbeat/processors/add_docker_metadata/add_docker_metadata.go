@@ -91,6 +91,7 @@ func buildDockerMetadataProcessor(cfg *common.Config, watcherConstructor docker.
 		})
 		sourceProcessor, err = actions.NewExtractField(procConf)
 		if err != nil {
+			watcher.Stop()
 			return nil, err
 		}
 	}
@@ -189,6 +190,15 @@ func (d *addDockerMetadata) Run(event *beat.Event) (*beat.Event, error) {
 	return event, nil
 }
 
+// Close stops watching the containers.
+func (d *addDockerMetadata) Close() error {
+	d.watcher.Stop()
+	if d.cgroups != nil {
+		d.cgroups.StopJanitor()
+	}
+	return nil
+}
+
 func (d *addDockerMetadata) String() string {
 	return fmt.Sprintf("%v=[match_fields=[%v] match_pids=[%v]]",
 		processorName, strings.Join(d.fields, ", "), strings.Join(d.pidFields, ", "))
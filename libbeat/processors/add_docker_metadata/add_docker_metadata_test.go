@@ -28,6 +28,7 @@ import (
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/common/bus"
 	"github.com/elastic/beats/libbeat/common/docker"
+	"github.com/elastic/beats/libbeat/processors"
 )
 
 func init() {
@@ -315,6 +316,22 @@ func TestMatchPIDs(t *testing.T) {
 	})
 }
 
+func TestClose(t *testing.T) {
+	var w *mockWatcher
+	factory := func(host string, tls *docker.TLSConfig, shortID bool) (docker.Watcher, error) {
+		w = &mockWatcher{containers: map[string]*docker.Container{}}
+		return w, nil
+	}
+
+	p, err := buildDockerMetadataProcessor(common.NewConfig(), factory)
+	if !assert.NoError(t, err, "initializing add_docker_metadata processor") {
+		return
+	}
+
+	assert.NoError(t, processors.Close(p))
+	assert.True(t, w.stopped)
+}
+
 // Mock container watcher
 
 func MockWatcherFactory(containers map[string]*docker.Container) docker.WatcherConstructor {
@@ -328,13 +345,16 @@ func MockWatcherFactory(containers map[string]*docker.Container) docker.WatcherC
 
 type mockWatcher struct {
 	containers map[string]*docker.Container
+	stopped    bool
 }
 
 func (m *mockWatcher) Start() error {
 	return nil
 }
 
-func (m *mockWatcher) Stop() {}
+func (m *mockWatcher) Stop() {
+	m.stopped = true
+}
 
 func (m *mockWatcher) Container(ID string) *docker.Container {
 	return m.containers[ID]
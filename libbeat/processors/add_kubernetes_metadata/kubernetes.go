@@ -137,6 +137,7 @@ func newKubernetesAnnotator(cfg *common.Config) (processors.Processor, error) {
 	})
 
 	if err := watcher.Start(); err != nil {
+		processor.cache.stop()
 		return nil, err
 	}
 
@@ -182,6 +183,13 @@ func (k *kubernetesAnnotator) removePod(pod *kubernetes.Pod) {
 	}
 }
 
+// Close stops watching the pods.
+func (k *kubernetesAnnotator) Close() error {
+	k.watcher.Stop()
+	k.cache.stop()
+	return nil
+}
+
 func (*kubernetesAnnotator) String() string {
 	return "add_kubernetes_metadata"
 }
@@ -54,6 +54,7 @@ type Condition struct {
 	or        []Condition
 	and       []Condition
 	not       *Condition
+	expr      *Expression
 }
 
 type WhenProcessor struct {
@@ -108,6 +109,8 @@ func NewCondition(config *ConditionConfig) (*Condition, error) {
 		c.and, err = NewConditionList(config.AND)
 	case config.NOT != nil:
 		c.not, err = NewCondition(config.NOT)
+	case config.Expression != "":
+		c.expr, err = CompileExpression(config.Expression)
 	default:
 		err = errors.New("missing condition")
 	}
@@ -244,6 +247,10 @@ func (c *Condition) Check(event ValuesMap) bool {
 		return c.checkNOT(event)
 	}
 
+	if c.expr != nil {
+		return c.expr.check(event)
+	}
+
 	return c.checkEquals(event) &&
 		c.checkMatches(event) &&
 		c.checkRange(event) &&
@@ -460,6 +467,9 @@ func (c Condition) String() string {
 	if c.not != nil {
 		s = s + "not " + c.not.String()
 	}
+	if c.expr != nil {
+		s = s + "expression: " + c.expr.String()
+	}
 
 	return s
 }
@@ -522,6 +532,10 @@ func (r *WhenProcessor) Run(event *beat.Event) (*beat.Event, error) {
 	return r.p.Run(event)
 }
 
+func (r *WhenProcessor) Close() error {
+	return Close(r.p)
+}
+
 func (r *WhenProcessor) String() string {
 	return fmt.Sprintf("%v, condition=%v", r.p.String(), r.condition.String())
 }
@@ -26,14 +26,15 @@ import (
 )
 
 type ConditionConfig struct {
-	Equals    *ConditionFields  `config:"equals"`
-	Contains  *ConditionFields  `config:"contains"`
-	Regexp    *ConditionFields  `config:"regexp"`
-	Range     *ConditionFields  `config:"range"`
-	HasFields []string          `config:"has_fields"`
-	OR        []ConditionConfig `config:"or"`
-	AND       []ConditionConfig `config:"and"`
-	NOT       *ConditionConfig  `config:"not"`
+	Equals     *ConditionFields  `config:"equals"`
+	Contains   *ConditionFields  `config:"contains"`
+	Regexp     *ConditionFields  `config:"regexp"`
+	Range      *ConditionFields  `config:"range"`
+	HasFields  []string          `config:"has_fields"`
+	OR         []ConditionConfig `config:"or"`
+	AND        []ConditionConfig `config:"and"`
+	NOT        *ConditionConfig  `config:"not"`
+	Expression string            `config:"expression"`
 }
 
 type ConditionFields struct {
@@ -0,0 +1,54 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_protobuf_fields
+
+import (
+	"errors"
+
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type config struct {
+	Fields              []string            `config:"fields" validate:"required"`
+	Target              *string             `config:"target"`
+	DescriptorFiles     []string            `config:"descriptor_files" validate:"required"`
+	MessageType         string              `config:"message_type"`
+	MessageTypes        []messageTypeConfig `config:"message_types"`
+	IgnoreUnknownFields bool                `config:"ignore_unknown_fields"`
+	IgnoreMissing       bool                `config:"ignore_missing"`
+	OverwriteKeys       bool                `config:"overwrite_keys"`
+}
+
+// messageTypeConfig selects the message type to decode events matching the
+// condition with. Entries without condition match all events.
+type messageTypeConfig struct {
+	Type string                      `config:"type" validate:"required"`
+	When *processors.ConditionConfig `config:"when"`
+}
+
+var defaultConfig = config{
+	IgnoreUnknownFields: true,
+}
+
+// Validate makes sure that a message type is configured.
+func (c *config) Validate() error {
+	if c.MessageType == "" && len(c.MessageTypes) == 0 {
+		return errors.New("one of message_type or message_types must be defined")
+	}
+	return nil
+}
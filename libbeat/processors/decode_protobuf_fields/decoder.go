@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_protobuf_fields
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+)
+
+const timestampType = "google.protobuf.Timestamp"
+
+// decoder converts encoded protobuf messages into event fields.
+type decoder struct {
+	ignoreUnknown bool
+}
+
+// decode decodes a message. Fields are named after the field names in the
+// descriptor. Repeated fields are decoded into lists, maps into objects and
+// enums into the name of the enum value.
+func (d *decoder) decode(msg *messageDescriptor, data []byte) (common.MapStr, error) {
+	out := common.MapStr{}
+	r := protowire.NewReader(data)
+	for !r.Done() {
+		number, wireType, v, raw, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		f := msg.fields[number]
+		if f == nil {
+			if d.ignoreUnknown {
+				continue
+			}
+			return nil, fmt.Errorf("unknown field %v in message %v", number, msg.name)
+		}
+
+		if f.repeated && wireType == protowire.WireBytes && isPacked(f.typ) {
+			values, err := d.decodePacked(f, raw)
+			if err != nil {
+				return nil, err
+			}
+			list, _ := out[f.name].([]interface{})
+			out[f.name] = append(list, values...)
+			continue
+		}
+
+		value, err := d.decodeValue(f, wireType, v, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case f.message != nil && f.message.mapEntry:
+			m, _ := out[f.name].(common.MapStr)
+			if m == nil {
+				m = common.MapStr{}
+				out[f.name] = m
+			}
+			entry := value.(common.MapStr)
+			m[fmt.Sprint(entry["key"])] = entry["value"]
+		case f.repeated:
+			list, _ := out[f.name].([]interface{})
+			out[f.name] = append(list, value)
+		default:
+			out[f.name] = value
+		}
+	}
+	return out, nil
+}
+
+func (d *decoder) decodeValue(f *fieldDescriptor, wireType int, v uint64, raw []byte) (interface{}, error) {
+	if wireType != wireTypeOf(f.typ) {
+		return nil, fmt.Errorf("field %v has invalid wire type %v", f.name, wireType)
+	}
+
+	switch f.typ {
+	case typeString:
+		return string(raw), nil
+	case typeBytes:
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case typeMessage:
+		m, err := d.decode(f.message, raw)
+		if err != nil {
+			return nil, err
+		}
+		if f.message.name == timestampType {
+			secs, _ := m["seconds"].(int64)
+			nanos, _ := m["nanos"].(int32)
+			return common.Time(time.Unix(secs, int64(nanos)).UTC()), nil
+		}
+		return m, nil
+	case typeEnum:
+		if f.enum != nil {
+			if name, ok := f.enum.values[int32(v)]; ok {
+				return name, nil
+			}
+		}
+		return int32(v), nil
+	}
+	return scalarValue(f.typ, v), nil
+}
+
+// decodePacked decodes a packed repeated field of scalar values, which are
+// encoded without keys.
+func (d *decoder) decodePacked(f *fieldDescriptor, raw []byte) ([]interface{}, error) {
+	var values []interface{}
+	for len(raw) > 0 {
+		var v uint64
+		switch wireTypeOf(f.typ) {
+		case protowire.WireVarint:
+			var n int
+			if v, n = binary.Uvarint(raw); n <= 0 {
+				return nil, protowire.ErrInvalid
+			}
+			raw = raw[n:]
+		case protowire.WireFixed64:
+			if len(raw) < 8 {
+				return nil, protowire.ErrInvalid
+			}
+			v, raw = binary.LittleEndian.Uint64(raw), raw[8:]
+		case protowire.WireFixed32:
+			if len(raw) < 4 {
+				return nil, protowire.ErrInvalid
+			}
+			v, raw = uint64(binary.LittleEndian.Uint32(raw)), raw[4:]
+		}
+
+		value, err := d.decodeValue(f, wireTypeOf(f.typ), v, nil)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// isPacked returns true for scalar types, which can be encoded as packed
+// repeated fields.
+func isPacked(t protoType) bool {
+	switch t {
+	case typeString, typeBytes, typeMessage, typeGroup:
+		return false
+	}
+	return true
+}
+
+func wireTypeOf(t protoType) int {
+	switch t {
+	case typeDouble, typeFixed64, typeSfixed64:
+		return protowire.WireFixed64
+	case typeFloat, typeFixed32, typeSfixed32:
+		return protowire.WireFixed32
+	case typeString, typeBytes, typeMessage:
+		return protowire.WireBytes
+	}
+	return protowire.WireVarint
+}
+
+func scalarValue(t protoType, v uint64) interface{} {
+	switch t {
+	case typeDouble:
+		return math.Float64frombits(v)
+	case typeFloat:
+		return math.Float32frombits(uint32(v))
+	case typeInt64, typeSfixed64:
+		return int64(v)
+	case typeUint64, typeFixed64:
+		return v
+	case typeInt32, typeSfixed32:
+		return int32(v)
+	case typeUint32, typeFixed32:
+		return uint32(v)
+	case typeBool:
+		return v != 0
+	case typeSint32:
+		return int32(uint32(v)>>1) ^ -int32(v&1)
+	case typeSint64:
+		return int64(v>>1) ^ -int64(v&1)
+	}
+	return v
+}
@@ -0,0 +1,304 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_protobuf_fields
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common/protowire"
+)
+
+// Field numbers and enum values of descriptor.proto, read from compiled
+// FileDescriptorSet files as generated by `protoc --descriptor_set_out`.
+// See https://github.com/google/protobuf/blob/master/src/google/protobuf/descriptor.proto.
+const (
+	fileSetFile = 1
+
+	fileName        = 1
+	filePackage     = 2
+	fileMessageType = 4
+	fileEnumType    = 5
+
+	messageName       = 1
+	messageField      = 2
+	messageNestedType = 3
+	messageEnumType   = 4
+	messageOptions    = 7
+
+	messageOptionsMapEntry = 7
+
+	fieldName     = 1
+	fieldNumber   = 3
+	fieldLabel    = 4
+	fieldType     = 5
+	fieldTypeName = 6
+
+	enumName  = 1
+	enumValue = 2
+
+	enumValueName   = 1
+	enumValueNumber = 2
+
+	labelRepeated = 3
+)
+
+// protoType is the type of a message field, as defined in
+// FieldDescriptorProto.Type.
+type protoType int
+
+const (
+	typeDouble   protoType = 1
+	typeFloat    protoType = 2
+	typeInt64    protoType = 3
+	typeUint64   protoType = 4
+	typeInt32    protoType = 5
+	typeFixed64  protoType = 6
+	typeFixed32  protoType = 7
+	typeBool     protoType = 8
+	typeString   protoType = 9
+	typeGroup    protoType = 10
+	typeMessage  protoType = 11
+	typeBytes    protoType = 12
+	typeUint32   protoType = 13
+	typeEnum     protoType = 14
+	typeSfixed32 protoType = 15
+	typeSfixed64 protoType = 16
+	typeSint32   protoType = 17
+	typeSint64   protoType = 18
+)
+
+type messageDescriptor struct {
+	name     string
+	fields   map[int]*fieldDescriptor
+	mapEntry bool
+}
+
+type fieldDescriptor struct {
+	name     string
+	number   int
+	repeated bool
+	typ      protoType
+	typeName string
+
+	// resolved message or enum type
+	message *messageDescriptor
+	enum    *enumDescriptor
+}
+
+type enumDescriptor struct {
+	name   string
+	values map[int32]string
+}
+
+// descriptors holds all message and enum types, indexed by their fully
+// qualified name without the leading dot.
+type descriptors struct {
+	messages map[string]*messageDescriptor
+	enums    map[string]*enumDescriptor
+}
+
+func newDescriptors() *descriptors {
+	return &descriptors{
+		messages: map[string]*messageDescriptor{},
+		enums:    map[string]*enumDescriptor{},
+	}
+}
+
+// add reads all types from an encoded FileDescriptorSet. Types must be
+// resolved once all sets have been added.
+func (d *descriptors) add(set []byte) error {
+	return readFields(set, func(number int, _ uint64, data []byte) error {
+		if number == fileSetFile {
+			return d.addFile(data)
+		}
+		return nil
+	})
+}
+
+func (d *descriptors) addFile(file []byte) error {
+	var name, pkg string
+	var messages, enums [][]byte
+	err := readFields(file, func(number int, _ uint64, data []byte) error {
+		switch number {
+		case fileName:
+			name = string(data)
+		case filePackage:
+			pkg = string(data)
+		case fileMessageType:
+			messages = append(messages, data)
+		case fileEnumType:
+			enums = append(enums, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid file descriptor %v: %v", name, err)
+	}
+
+	for _, msg := range messages {
+		if err := d.addMessage(pkg, msg); err != nil {
+			return fmt.Errorf("invalid file descriptor %v: %v", name, err)
+		}
+	}
+	for _, enum := range enums {
+		if err := d.addEnum(pkg, enum); err != nil {
+			return fmt.Errorf("invalid file descriptor %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (d *descriptors) addMessage(scope string, msg []byte) error {
+	m := &messageDescriptor{fields: map[int]*fieldDescriptor{}}
+	var nested, enums [][]byte
+	err := readFields(msg, func(number int, _ uint64, data []byte) error {
+		switch number {
+		case messageName:
+			m.name = qualify(scope, string(data))
+		case messageField:
+			f, err := parseField(data)
+			if err != nil {
+				return err
+			}
+			m.fields[f.number] = f
+		case messageNestedType:
+			nested = append(nested, data)
+		case messageEnumType:
+			enums = append(enums, data)
+		case messageOptions:
+			return readFields(data, func(number int, v uint64, _ []byte) error {
+				if number == messageOptionsMapEntry {
+					m.mapEntry = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.messages[m.name] = m
+	for _, msg := range nested {
+		if err := d.addMessage(m.name, msg); err != nil {
+			return err
+		}
+	}
+	for _, enum := range enums {
+		if err := d.addEnum(m.name, enum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseField(field []byte) (*fieldDescriptor, error) {
+	f := &fieldDescriptor{}
+	err := readFields(field, func(number int, v uint64, data []byte) error {
+		switch number {
+		case fieldName:
+			f.name = string(data)
+		case fieldNumber:
+			f.number = int(v)
+		case fieldLabel:
+			f.repeated = v == labelRepeated
+		case fieldType:
+			f.typ = protoType(v)
+		case fieldTypeName:
+			f.typeName = strings.TrimPrefix(string(data), ".")
+		}
+		return nil
+	})
+	return f, err
+}
+
+func (d *descriptors) addEnum(scope string, enum []byte) error {
+	e := &enumDescriptor{values: map[int32]string{}}
+	err := readFields(enum, func(number int, _ uint64, data []byte) error {
+		switch number {
+		case enumName:
+			e.name = qualify(scope, string(data))
+		case enumValue:
+			var name string
+			var value int32
+			err := readFields(data, func(number int, v uint64, data []byte) error {
+				switch number {
+				case enumValueName:
+					name = string(data)
+				case enumValueNumber:
+					value = int32(v)
+				}
+				return nil
+			})
+			e.values[value] = name
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.enums[e.name] = e
+	return nil
+}
+
+// resolve links message and enum fields to their types.
+func (d *descriptors) resolve() error {
+	for _, m := range d.messages {
+		for _, f := range m.fields {
+			switch f.typ {
+			case typeMessage:
+				if f.message = d.messages[f.typeName]; f.message == nil {
+					return fmt.Errorf("unknown message type %v of field %v.%v", f.typeName, m.name, f.name)
+				}
+			case typeEnum:
+				// enums from files not included are decoded as numbers
+				f.enum = d.enums[f.typeName]
+			case typeGroup:
+				return fmt.Errorf("field %v.%v: groups are not supported", m.name, f.name)
+			}
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// readFields calls fn for all fields of an encoded message. The value of
+// varint and fixed fields is passed in v, length delimited fields in data.
+func readFields(msg []byte, fn func(number int, v uint64, data []byte) error) error {
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		number, _, v, data, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if err := fn(number, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_protobuf_fields
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/jsontransform"
+	"github.com/elastic/beats/libbeat/paths"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type processor struct {
+	config  config
+	decoder decoder
+
+	// message types are checked in order, the default is used if no
+	// condition matches
+	types       []messageType
+	defaultType *messageDescriptor
+}
+
+type messageType struct {
+	condition *processors.Condition
+	message   *messageDescriptor
+}
+
+func init() {
+	processors.RegisterPlugin("decode_protobuf_fields", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The decode_protobuf_fields processor is beta.")
+
+	config := defaultConfig
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the decode_protobuf_fields configuration: %v", err)
+	}
+
+	descriptors := newDescriptors()
+	for _, file := range config.DescriptorFiles {
+		data, err := ioutil.ReadFile(paths.Resolve(paths.Config, file))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read descriptor file")
+		}
+		if err := descriptors.add(data); err != nil {
+			return nil, errors.Wrapf(err, "cannot load descriptor file %v", file)
+		}
+	}
+	if err := descriptors.resolve(); err != nil {
+		return nil, err
+	}
+
+	lookup := func(name string) (*messageDescriptor, error) {
+		m := descriptors.messages[strings.TrimPrefix(name, ".")]
+		if m == nil {
+			return nil, fmt.Errorf("message type %v not found in descriptor files", name)
+		}
+		return m, nil
+	}
+
+	p := &processor{
+		config:  config,
+		decoder: decoder{ignoreUnknown: config.IgnoreUnknownFields},
+	}
+
+	var err error
+	if config.MessageType != "" {
+		if p.defaultType, err = lookup(config.MessageType); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range config.MessageTypes {
+		typ := messageType{}
+		if typ.message, err = lookup(t.Type); err != nil {
+			return nil, err
+		}
+		if t.When != nil {
+			if typ.condition, err = processors.NewCondition(t.When); err != nil {
+				return nil, err
+			}
+		}
+		p.types = append(p.types, typ)
+	}
+	return p, nil
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []string
+	for _, field := range p.config.Fields {
+		if err := p.decodeField(event, field); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return event, errors.New(strings.Join(errs, ", "))
+	}
+	return event, nil
+}
+
+func (p *processor) decodeField(event *beat.Event, field string) error {
+	data, err := event.GetValue(field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Cause(err) == common.ErrKeyNotFound {
+			return nil
+		}
+		return fmt.Errorf("could not fetch value for key: %s, Error: %s", field, err)
+	}
+
+	var raw []byte
+	switch v := data.(type) {
+	case []byte:
+		raw = v
+	case string:
+		if raw, err = base64.StdEncoding.DecodeString(v); err != nil {
+			return fmt.Errorf("field `%s` is not base64 encoded: %v", field, err)
+		}
+	default:
+		return fmt.Errorf("field `%s` is not a string or bytes", field)
+	}
+
+	msg := p.messageType(event)
+	if msg == nil {
+		return fmt.Errorf("no message type matches field `%s`", field)
+	}
+
+	decoded, err := p.decoder.decode(msg, raw)
+	if err != nil {
+		return fmt.Errorf("could not decode field `%s` as %v: %v", field, msg.name, err)
+	}
+
+	target := field
+	if p.config.Target != nil {
+		target = *p.config.Target
+	}
+	if target == "" {
+		jsontransform.WriteJSONKeys(event, decoded, p.config.OverwriteKeys)
+		return nil
+	}
+
+	if !p.config.OverwriteKeys && target != field {
+		if exists, _ := event.Fields.HasKey(target); exists {
+			return fmt.Errorf("target field %s already exists, drop or rename this field first", target)
+		}
+	}
+	_, err = event.PutValue(target, decoded)
+	return err
+}
+
+// messageType returns the message type of the first matching condition, or
+// the default message type.
+func (p *processor) messageType(event *beat.Event) *messageDescriptor {
+	for _, t := range p.types {
+		if t.condition == nil || t.condition.Check(event) {
+			return t.message
+		}
+	}
+	return p.defaultType
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("decode_protobuf_fields=[fields=%v, message_type=%v]",
+		p.config.Fields, p.config.MessageType)
+}
@@ -0,0 +1,352 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_protobuf_fields
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type testField struct {
+	name     string
+	number   int
+	typ      protoType
+	typeName string
+	repeated bool
+}
+
+func testMessage(b *protowire.Buffer, number int, name string, mapEntry bool, fields []testField, nested ...func(*protowire.Buffer)) {
+	b.MessageField(number, func(b *protowire.Buffer) {
+		b.StringField(messageName, name)
+		for _, f := range fields {
+			b.MessageField(messageField, func(b *protowire.Buffer) {
+				b.StringField(fieldName, f.name)
+				b.VarintField(fieldNumber, uint64(f.number))
+				if f.repeated {
+					b.VarintField(fieldLabel, labelRepeated)
+				}
+				b.VarintField(fieldType, uint64(f.typ))
+				if f.typeName != "" {
+					b.StringField(fieldTypeName, f.typeName)
+				}
+			})
+		}
+		for _, fn := range nested {
+			fn(b)
+		}
+		if mapEntry {
+			b.MessageField(messageOptions, func(b *protowire.Buffer) {
+				b.BoolField(messageOptionsMapEntry, true)
+			})
+		}
+	})
+}
+
+// testDescriptorSet returns a FileDescriptorSet for:
+//
+//   package acme;
+//   enum Level { INFO = 0; ERROR = 1; }
+//   message Event {
+//     message Host { string name = 1; }
+//     string message = 1;
+//     int64 count = 2;
+//     repeated sint32 deltas = 3;
+//     Level level = 4;
+//     Host host = 5;
+//     map<string, int32> labels = 6;
+//     google.protobuf.Timestamp created = 7;
+//     bytes raw = 8;
+//     repeated string tags = 9;
+//   }
+//   message Login { string user = 1; }
+func testDescriptorSet() []byte {
+	b := &protowire.Buffer{}
+	b.MessageField(fileSetFile, func(b *protowire.Buffer) {
+		b.StringField(fileName, "google/protobuf/timestamp.proto")
+		b.StringField(filePackage, "google.protobuf")
+		testMessage(b, fileMessageType, "Timestamp", false, []testField{
+			{"seconds", 1, typeInt64, "", false},
+			{"nanos", 2, typeInt32, "", false},
+		})
+	})
+	b.MessageField(fileSetFile, func(b *protowire.Buffer) {
+		b.StringField(fileName, "acme.proto")
+		b.StringField(filePackage, "acme")
+		b.MessageField(fileEnumType, func(b *protowire.Buffer) {
+			b.StringField(enumName, "Level")
+			for i, name := range []string{"INFO", "ERROR"} {
+				b.MessageField(enumValue, func(b *protowire.Buffer) {
+					b.StringField(enumValueName, name)
+					b.VarintField(enumValueNumber, uint64(i))
+				})
+			}
+		})
+		testMessage(b, fileMessageType, "Event", false, []testField{
+			{"message", 1, typeString, "", false},
+			{"count", 2, typeInt64, "", false},
+			{"deltas", 3, typeSint32, "", true},
+			{"level", 4, typeEnum, ".acme.Level", false},
+			{"host", 5, typeMessage, ".acme.Event.Host", false},
+			{"labels", 6, typeMessage, ".acme.Event.LabelsEntry", true},
+			{"created", 7, typeMessage, ".google.protobuf.Timestamp", false},
+			{"raw", 8, typeBytes, "", false},
+			{"tags", 9, typeString, "", true},
+		}, func(b *protowire.Buffer) {
+			testMessage(b, messageNestedType, "Host", false, []testField{
+				{"name", 1, typeString, "", false},
+			})
+		}, func(b *protowire.Buffer) {
+			testMessage(b, messageNestedType, "LabelsEntry", true, []testField{
+				{"key", 1, typeString, "", false},
+				{"value", 2, typeInt32, "", false},
+			})
+		})
+		testMessage(b, fileMessageType, "Login", false, []testField{
+			{"user", 1, typeString, "", false},
+		})
+	})
+	return b.Bytes()
+}
+
+func testEvent() []byte {
+	b := &protowire.Buffer{}
+	b.StringField(1, "hello")
+	b.VarintField(2, math.MaxUint64) // -1
+	b.MessageField(3, func(b *protowire.Buffer) {
+		b.Varint(3) // -2
+		b.Varint(4) // 2
+	})
+	b.VarintField(4, 1)
+	b.MessageField(5, func(b *protowire.Buffer) {
+		b.StringField(1, "h")
+	})
+	for _, label := range []string{"a", "b"} {
+		b.MessageField(6, func(b *protowire.Buffer) {
+			b.StringField(1, label)
+			b.VarintField(2, 7)
+		})
+	}
+	b.MessageField(7, func(b *protowire.Buffer) {
+		b.VarintField(1, 10)
+		b.VarintField(2, 5)
+	})
+	b.BytesField(8, []byte{0xff})
+	b.StringField(9, "x")
+	b.StringField(9, "y")
+	b.VarintField(100, 1) // unknown field
+	return b.Bytes()
+}
+
+// createTestProcessor writes the test descriptor set to a temporary file,
+// unless descriptor files are configured, and creates the processor.
+func createTestProcessor(t *testing.T, settings map[string]interface{}) (processors.Processor, error) {
+	dir, err := ioutil.TempDir("", "decode_protobuf_fields")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, exists := settings["descriptor_files"]; !exists {
+		path := filepath.Join(dir, "acme.desc")
+		if err := ioutil.WriteFile(path, testDescriptorSet(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		settings["descriptor_files"] = []string{path}
+	}
+
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newProcessor(cfg)
+}
+
+func newTestProcessor(t *testing.T, settings map[string]interface{}) processors.Processor {
+	p, err := createTestProcessor(t, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDecode(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{
+		"fields":       []string{"payload"},
+		"target":       "event",
+		"message_type": "acme.Event",
+	})
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{
+		"payload": base64.StdEncoding.EncodeToString(testEvent()),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := common.MapStr{
+		"message": "hello",
+		"count":   int64(-1),
+		"deltas":  []interface{}{int32(-2), int32(2)},
+		"level":   "ERROR",
+		"host":    common.MapStr{"name": "h"},
+		"labels":  common.MapStr{"a": int32(7), "b": int32(7)},
+		"created": common.Time(time.Unix(10, 5).UTC()),
+		"raw":     "/w==",
+		"tags":    []interface{}{"x", "y"},
+	}
+	v, err := event.GetValue("event")
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, v)
+	}
+}
+
+func TestDecodeTargets(t *testing.T) {
+	msg := &protowire.Buffer{}
+	msg.StringField(1, "alice")
+
+	tests := map[string]struct {
+		settings map[string]interface{}
+		expected common.MapStr
+	}{
+		"replace field": {
+			settings: map[string]interface{}{},
+			expected: common.MapStr{"payload": common.MapStr{"user": "alice"}},
+		},
+		"root": {
+			settings: map[string]interface{}{"target": ""},
+			expected: common.MapStr{"payload": msg.Bytes(), "user": "alice"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.settings["fields"] = []string{"payload"}
+			test.settings["message_type"] = "acme.Login"
+			p := newTestProcessor(t, test.settings)
+
+			event, err := p.Run(&beat.Event{Fields: common.MapStr{"payload": msg.Bytes()}})
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, event.Fields)
+			}
+		})
+	}
+}
+
+func TestMessageTypeConditions(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{
+		"fields": []string{"payload"},
+		"target": "decoded",
+		"message_types": []map[string]interface{}{
+			{"type": "acme.Login", "when.equals.type": "login"},
+			{"type": "acme.Event", "when.equals.type": "event"},
+		},
+	})
+
+	msg := &protowire.Buffer{}
+	msg.StringField(1, "alice")
+
+	tests := map[string]common.MapStr{
+		"login": {"user": "alice"},
+		"event": {"message": "alice"},
+	}
+	for typ, expected := range tests {
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"type": typ, "payload": msg.Bytes()}})
+		if assert.NoError(t, err, typ) {
+			assert.Equal(t, expected, event.Fields["decoded"], typ)
+		}
+	}
+
+	_, err := p.Run(&beat.Event{Fields: common.MapStr{"type": "other", "payload": msg.Bytes()}})
+	assert.Error(t, err)
+}
+
+func TestUnknownFields(t *testing.T) {
+	msg := &protowire.Buffer{}
+	msg.StringField(1, "alice")
+	msg.StringField(2, "unknown")
+
+	for _, ignore := range []bool{true, false} {
+		p := newTestProcessor(t, map[string]interface{}{
+			"fields":                []string{"payload"},
+			"message_type":          "acme.Login",
+			"ignore_unknown_fields": ignore,
+		})
+
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"payload": msg.Bytes()}})
+		if ignore {
+			if assert.NoError(t, err) {
+				assert.Equal(t, common.MapStr{"user": "alice"}, event.Fields["payload"])
+			}
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]common.MapStr{
+		"missing field":   {},
+		"invalid base64":  {"payload": "!"},
+		"invalid type":    {"payload": 1},
+		"invalid message": {"payload": []byte{0x0a, 0x05}},
+	}
+
+	p := newTestProcessor(t, map[string]interface{}{
+		"fields":       []string{"payload"},
+		"message_type": "acme.Login",
+	})
+	for name, fields := range tests {
+		_, err := p.Run(&beat.Event{Fields: fields})
+		assert.Error(t, err, name)
+	}
+
+	p = newTestProcessor(t, map[string]interface{}{
+		"fields":         []string{"payload"},
+		"message_type":   "acme.Login",
+		"ignore_missing": true,
+	})
+	_, err := p.Run(&beat.Event{Fields: common.MapStr{}})
+	assert.NoError(t, err)
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no message type":      {"fields": []string{"payload"}},
+		"unknown message type": {"fields": []string{"payload"}, "message_type": "acme.Unknown"},
+		"missing descriptor":   {"fields": []string{"payload"}, "message_type": "acme.Login", "descriptor_files": []string{"missing.desc"}},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := createTestProcessor(t, settings)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -21,6 +21,7 @@ type config struct {
 	Tokenizer    *tokenizer `config:"tokenizer"`
 	Field        string     `config:"field"`
 	TargetPrefix string     `config:"target_prefix"`
+	TagOnFailure []string   `config:"tag_on_failure"`
 }
 
 var defaultConfig = config{
@@ -29,6 +29,10 @@ type delimiter interface {
 	// the needle is found.
 	IndexOf(haystack string, offset int) int
 
+	// LastIndexOf receives the haystack and a offset position and will return the absolute position
+	// of the last needle found after the offset.
+	LastIndexOf(haystack string, offset int) int
+
 	// Len returns the length of the needle used to calculate boundaries.
 	Len() int
 
@@ -44,25 +48,22 @@ type delimiter interface {
 
 	// MarkGreedy marks this delimiter as greedy.
 	MarkGreedy()
-
-	// Next returns the next delimiter in the chain.
-	Next() delimiter
-
-	//SetNext sets the next delimiter or nil if current delimiter is the last.
-	SetNext(d delimiter)
 }
 
 // zeroByte represents a zero string delimiter its usually start of the line.
 type zeroByte struct {
 	needle string
 	greedy bool
-	next   delimiter
 }
 
 func (z *zeroByte) IndexOf(haystack string, offset int) int {
 	return offset
 }
 
+func (z *zeroByte) LastIndexOf(haystack string, offset int) int {
+	return len(haystack)
+}
+
 func (z *zeroByte) Len() int {
 	return 0
 }
@@ -83,19 +84,10 @@ func (z *zeroByte) MarkGreedy() {
 	z.greedy = true
 }
 
-func (z *zeroByte) Next() delimiter {
-	return z.next
-}
-
-func (z *zeroByte) SetNext(d delimiter) {
-	z.next = d
-}
-
 // multiByte represents a delimiter with at least one byte.
 type multiByte struct {
 	needle string
 	greedy bool
-	next   delimiter
 }
 
 func (m *multiByte) IndexOf(haystack string, offset int) int {
@@ -106,6 +98,14 @@ func (m *multiByte) IndexOf(haystack string, offset int) int {
 	return -1
 }
 
+func (m *multiByte) LastIndexOf(haystack string, offset int) int {
+	i := strings.LastIndex(haystack[offset:], m.needle)
+	if i != -1 {
+		return i + offset
+	}
+	return -1
+}
+
 func (m *multiByte) Len() int {
 	return len(m.needle)
 }
@@ -126,14 +126,6 @@ func (m *multiByte) Delimiter() string {
 	return m.needle
 }
 
-func (m *multiByte) Next() delimiter {
-	return m.next
-}
-
-func (m *multiByte) SetNext(d delimiter) {
-	m.next = d
-}
-
 func newDelimiter(needle string) delimiter {
 	if len(needle) == 0 {
 		return &zeroByte{}
@@ -32,3 +32,14 @@ func TestSingleByte(t *testing.T) {
 	m := newDelimiter("")
 	assert.Equal(t, 5, m.IndexOf("  needle", 5))
 }
+
+func TestMultiByteLastIndexOf(t *testing.T) {
+	m := newDelimiter(" took ")
+	assert.Equal(t, 10, m.LastIndexOf("a took b c took d", 2))
+	assert.Equal(t, -1, m.LastIndexOf("a took b", 2))
+}
+
+func TestSingleByteLastIndexOf(t *testing.T) {
+	m := newDelimiter("")
+	assert.Equal(t, 8, m.LastIndexOf("  needle", 5))
+}
@@ -17,7 +17,10 @@
 
 package dissect
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Map  represents the keys and their values extracted with the defined tokenizer.
 type Map = map[string]string
@@ -68,15 +71,22 @@ func (d *Dissector) Raw() string {
 
 // extract will navigate through the delimiters and will save the ending and starting position
 // of the keys. After we will resolve the positions with the required fields and do the reordering.
+//
+// Keys are matched from left to right, each key ends at the first occurrence of the following
+// delimiter, and repetitions of the delimiter after a greedy key are skipped as padding.
+// If the tokenizer ends with a delimiter, the delimiter is anchored at the end of the string and
+// the keys following a greedy key are matched from right to left, so the greedy key ends at the
+// last occurrence of the following delimiter that still allows the rest of the tokenizer to match.
 func (d *Dissector) extract(s string) (positions, error) {
+	delimiters := d.parser.delimiters
 	positions := make([]position, len(d.parser.fields))
-	var i, start, lookahead, end int
+	n := len(positions)
 
 	// Position on the first delimiter, we assume a hard match on the first delimiter.
 	// Previous version of dissect was doing a lookahead in the string until it can find the delimiter,
 	// LS and Beats now have the same behavior and this is consistent with the principle of least
 	// surprise.
-	dl := d.parser.delimiters[0]
+	dl := delimiters[0]
 	offset := dl.IndexOf(s, 0)
 	if offset == -1 || offset != 0 {
 		return nil, fmt.Errorf(
@@ -86,44 +96,89 @@ func (d *Dissector) extract(s string) (positions, error) {
 	}
 	offset += dl.Len()
 
-	// move through all the other delimiters, until we have consumed all of them.
-	for dl.Next() != nil {
-		start = offset
-		end = dl.Next().IndexOf(s, offset)
-		if end == -1 {
+	// The ending delimiter must be at the end of the string, the last key ends before it.
+	end := len(s)
+	anchored := len(delimiters) > n
+	if anchored {
+		dl = delimiters[n]
+		if !strings.HasSuffix(s[offset:], dl.Delimiter()) {
 			return nil, fmt.Errorf(
 				"could not find delimiter: `%s` in remaining: `%s`, (offset: %d)",
 				dl.Delimiter(), s[offset:], offset,
 			)
 		}
+		end -= dl.Len()
+	}
 
-		offset = end
-
-		// Greedy consumes keys defined with padding.
-		// Keys are defined with `->` suffix.
-		if dl.IsGreedy() {
-			for {
-				lookahead = dl.Next().IndexOf(s, offset+1)
-				if lookahead != offset+1 {
-					break
-				} else {
-					offset = lookahead
-				}
-			}
+	for i := 0; i < n; i++ {
+		if anchored && delimiters[i].IsGreedy() {
+			return positions, d.extractRight(s, positions, i, offset, end)
 		}
 
-		positions[i] = position{start: start, end: end}
-		offset += dl.Next().Len()
-		i++
-		dl = dl.Next()
-	}
+		if i == n-1 {
+			positions[i] = position{start: offset, end: end}
+			break
+		}
+
+		dl = delimiters[i+1]
+		pos := dl.IndexOf(s[:end], offset)
+		if pos == -1 {
+			return nil, fmt.Errorf(
+				"could not find delimiter: `%s` in remaining: `%s`, (offset: %d)",
+				dl.Delimiter(), s[offset:end], offset,
+			)
+		}
+
+		positions[i] = position{start: offset, end: pos}
+		offset = pos + dl.Len()
 
-	if offset < len(s) {
-		positions[i] = position{start: offset, end: len(s)}
+		// Greedy consumes keys defined with padding, keys are defined with `->` suffix.
+		if delimiters[i].IsGreedy() {
+			for dl.Len() > 0 && strings.HasPrefix(s[offset:end], dl.Delimiter()) {
+				offset += dl.Len()
+			}
+		}
 	}
 	return positions, nil
 }
 
+// extractRight matches the keys starting from the greedy key at index first, from right to left
+// between offset and end, the end being anchored by the ending delimiter.
+func (d *Dissector) extractRight(s string, positions positions, first, offset, end int) error {
+	delimiters := d.parser.delimiters
+	n := len(positions)
+
+	for i := n - 1; i >= first; i-- {
+		start := offset
+		if i > first {
+			dl := delimiters[i]
+			pos := dl.LastIndexOf(s[:end], offset)
+			if pos == -1 {
+				return fmt.Errorf(
+					"could not find delimiter: `%s` in remaining: `%s`, (offset: %d)",
+					dl.Delimiter(), s[offset:end], offset,
+				)
+			}
+			start = pos + dl.Len()
+		}
+
+		// Greedy consumes keys defined with padding, keys are defined with `->` suffix.
+		// The padding is made of the repeated delimiter following the key.
+		keyEnd := end
+		if delimiters[i].IsGreedy() && i+1 < len(delimiters) {
+			next := delimiters[i+1]
+			for next.Len() > 0 && keyEnd-next.Len() >= start &&
+				strings.HasSuffix(s[start:keyEnd], next.Delimiter()) {
+				keyEnd -= next.Len()
+			}
+		}
+
+		positions[i] = position{start: start, end: keyEnd}
+		end = start - delimiters[i].Len()
+	}
+	return nil
+}
+
 // resolve takes the raw string and the extracted positions and apply fields syntax.
 func (d *Dissector) resolve(s string, p positions) Map {
 	m := make(Map, len(p))
@@ -22,6 +22,7 @@ import (
 	"flag"
 	"io/ioutil"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -212,6 +213,94 @@ var tests = []dissectTest{
 			"server":   "machine-321",
 		},
 	},
+	{
+		Name: "greedy key before an ending delimiter uses the last occurrence of the delimiter",
+		Tok:  "%{msg->} took %{duration}ms",
+		Msg:  "GET /index.html took too long took 5ms",
+		Expected: Map{
+			"msg":      "GET /index.html took too long",
+			"duration": "5",
+		},
+	},
+	{
+		Name: "greedy key with multiple trailing keys",
+		Tok:  "%{ts} %{msg->} took %{duration} %{unit}.",
+		Msg:  "12:00 request took 2 retries took 5 ms.",
+		Expected: Map{
+			"ts":       "12:00",
+			"msg":      "request took 2 retries",
+			"duration": "5",
+			"unit":     "ms",
+		},
+	},
+	{
+		Name: "greedy key with an ending delimiter",
+		Tok:  "[%{msg->}] (%{duration})",
+		Msg:  "[a] (b) [c] (5ms)",
+		Expected: Map{
+			"msg":      "a] (b) [c",
+			"duration": "5ms",
+		},
+	},
+	{
+		Name: "greedy key with padding and ambiguous delimiter before an ending delimiter",
+		Tok:  "%{id} %{function->} %{server};",
+		Msg:  "00000043 View Receive     machine-321;",
+		Expected: Map{
+			"id":       "00000043",
+			"function": "View Receive",
+			"server":   "machine-321",
+		},
+	},
+	{
+		Name: "greedy key with padding before free text",
+		Tok:  "%{level->} %{msg}",
+		Msg:  "INFO    some message text",
+		Expected: Map{
+			"level": "INFO",
+			"msg":   "some message text",
+		},
+	},
+	{
+		Name: "greedy key with padding before multiple keys",
+		Tok:  "%{level->} %{logger} %{msg}",
+		Msg:  "WARN     main some message text",
+		Expected: Map{
+			"level":  "WARN",
+			"logger": "main",
+			"msg":    "some message text",
+		},
+	},
+	{
+		Name:     "greedy key without ending delimiter uses the first occurrence of the delimiter",
+		Tok:      "%{msg->} took %{duration}",
+		Msg:      "GET took too long took 5ms",
+		Expected: Map{"msg": "GET", "duration": "too long took 5ms"},
+	},
+	{
+		Name:     "non greedy key uses the first occurrence of the delimiter",
+		Tok:      "%{msg} took %{duration}",
+		Msg:      "GET took too long took 5ms",
+		Expected: Map{"msg": "GET", "duration": "too long took 5ms"},
+	},
+	{
+		Name:     "ending delimiter is anchored at the end of the string",
+		Tok:      "/var/%{key}/log",
+		Msg:      "/var/foo/log/bar/log",
+		Expected: Map{"key": "foo/log/bar"},
+	},
+	{
+		Name: "fails when the ending delimiter is not at the end of the string",
+		Tok:  "/var/%{key}/log",
+		Msg:  "/var/foobar/log/extra",
+		Fail: true,
+	},
+	{
+		Name: "fails when the delimiter after a greedy key is not found",
+		Tok:  "%{msg->} took %{duration}",
+		Msg:  "GET /index.html",
+		Fail: true,
+	},
 	{
 		Name: "when the delimiters contains `{` and `}`",
 		Tok:  "{%{a}}{%{b}} %{rest}",
@@ -340,6 +429,29 @@ func BenchmarkDissect(b *testing.B) {
 	})
 }
 
+func BenchmarkDissectGreedy(b *testing.B) {
+	msg := strings.Repeat("step took a while ", 200) + "took 5ms"
+
+	b.Run("greedy key", func(b *testing.B) {
+		d, err := New("%{msg->} took %{duration}ms")
+		if !assert.NoError(b, err) {
+			return
+		}
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			results, _ = d.Dissect(msg)
+		}
+	})
+
+	b.Run("regular expression", func(b *testing.B) {
+		re := regexp.MustCompile("^(.*) took (.*)ms$")
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			o = re.FindAllStringSubmatch(msg, -1)
+		}
+	})
+}
+
 func dumpJSON() {
 	b, err := json.MarshalIndent(&tests, "", "\t")
 	if err != nil {
@@ -22,7 +22,8 @@ import (
 )
 
 // parser extracts the useful information from the raw tokenizer string, fields, delimiters and
-// skip fields.
+// skip fields. The delimiter at index i precedes the field with ID i, if there is one more
+// delimiter than fields, the last delimiter follows the last field.
 type parser struct {
 	delimiters []delimiter
 	fields     []field
@@ -60,14 +61,6 @@ func newParser(tokenizer string) (*parser, error) {
 		delimiters = append(delimiters, d)
 	}
 
-	// Chain delimiters between them to make it easier to match them with the string.
-	// Some delimiters also need information about their surrounding for decision.
-	for i := 0; i < len(delimiters); i++ {
-		if i+1 < len(delimiters) {
-			delimiters[i].SetNext(delimiters[i+1])
-		}
-	}
-
 	// group and order append field at the end so the string join is from left to right.
 	sort.Slice(fields, func(i, j int) bool {
 		return fields[i].Ordinal() < fields[j].Ordinal()
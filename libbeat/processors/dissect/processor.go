@@ -24,11 +24,15 @@ import (
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
 	"github.com/elastic/beats/libbeat/processors"
 )
 
 type processor struct {
 	config config
+
+	metrics                  *monitoring.Registry
+	matched, failed, skipped *monitoring.Int
 }
 
 func init() {
@@ -41,36 +45,58 @@ func newProcessor(c *common.Config) (processors.Processor, error) {
 	if err != nil {
 		return nil, err
 	}
-	p := &processor{config: config}
+	reg := processors.NewMetricsRegistry("dissect")
+	p := &processor{
+		config:  config,
+		metrics: reg,
+		matched: monitoring.NewInt(reg, "matched"),
+		failed:  monitoring.NewInt(reg, "failed"),
+		skipped: monitoring.NewInt(reg, "skipped"),
+	}
 
 	return p, nil
 }
 
+// Close removes the metrics registry of the processor.
+func (p *processor) Close() error {
+	processors.RemoveMetricsRegistry("dissect", p.metrics)
+	return nil
+}
+
 // Run takes the event and will apply the tokenizer on the configured field.
 func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
 	v, err := event.GetValue(p.config.Field)
 	if err != nil {
+		p.skipped.Inc()
 		return event, err
 	}
 
 	s, ok := v.(string)
 	if !ok {
+		p.skipped.Inc()
 		return event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.config.Field)
 	}
 
 	m, err := p.config.Tokenizer.Dissect(s)
 	if err != nil {
-		return event, err
+		p.failed.Inc()
+		return p.onFailure(event, err)
 	}
 
 	event, err = p.mapper(event, mapToMapStr(m))
 	if err != nil {
-		return event, err
+		p.failed.Inc()
+		return p.onFailure(event, err)
 	}
 
+	p.matched.Inc()
 	return event, nil
 }
 
+func (p *processor) onFailure(event *beat.Event, err error) (*beat.Event, error) {
+	return processors.TagOnFailure(event, p.config.TagOnFailure, "dissect", err)
+}
+
 func (p *processor) mapper(event *beat.Event, m common.MapStr) (*beat.Event, error) {
 	copy := event.Fields.Clone()
 
@@ -24,6 +24,8 @@ import (
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/processors"
 )
 
 func TestProcessor(t *testing.T) {
@@ -176,3 +178,119 @@ func TestFieldAlreadyExist(t *testing.T) {
 		})
 	}
 }
+
+func TestTagOnFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       map[string]interface{}
+		fields  common.MapStr
+		message string
+	}{
+		{
+			name: "delimiter not found",
+			c: map[string]interface{}{
+				"tokenizer":      "hello %{key} world",
+				"tag_on_failure": []string{"_dissectfailure"},
+			},
+			fields:  common.MapStr{"message": "hello there"},
+			message: "dissect: could not find delimiter: ` world` in remaining: `there`, (offset: 6)",
+		},
+		{
+			name: "conflicting key",
+			c: map[string]interface{}{
+				"tokenizer":      "hello %{key}",
+				"target_prefix":  "",
+				"tag_on_failure": []string{"_dissectfailure"},
+			},
+			fields:  common.MapStr{"message": "hello world", "key": "exists"},
+			message: "dissect: cannot override existing key with `key`",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			proc, err := newProcessor(c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			failed := proc.(*processor).failed
+			before := failed.Get()
+			e := beat.Event{Fields: test.fields}
+			newEvent, err := proc.Run(&e)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			tags, err := newEvent.GetValue("tags")
+			if assert.NoError(t, err) {
+				assert.Equal(t, []string{"_dissectfailure"}, tags)
+			}
+
+			message, err := newEvent.GetValue("error.message")
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.message, message)
+			}
+
+			assert.Equal(t, before+1, failed.Get())
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	c, err := common.NewConfigFrom(map[string]interface{}{"tokenizer": "hello %{key}"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proc, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	other, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proc.Run(&beat.Event{Fields: common.MapStr{"message": "hello world"}})
+	proc.Run(&beat.Event{Fields: common.MapStr{"message": "bye world"}})
+	proc.Run(&beat.Event{Fields: common.MapStr{"other": "hello world"}})
+	other.Run(&beat.Event{Fields: common.MapStr{"message": "hello world"}})
+
+	p := proc.(*processor)
+	assert.Equal(t, int64(1), p.matched.Get())
+	assert.Equal(t, int64(1), p.failed.Get())
+	assert.Equal(t, int64(1), p.skipped.Get())
+	assert.Equal(t, int64(1), other.(*processor).matched.Get())
+	assert.Equal(t, int64(0), other.(*processor).failed.Get())
+}
+
+func TestCloseRemovesMetrics(t *testing.T) {
+	c, err := common.NewConfigFrom(map[string]interface{}{"tokenizer": "hello %{key}"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parent := processors.NewMetricsRegistry("dissect")
+	defer processors.RemoveMetricsRegistry("dissect", parent)
+	count := func() int {
+		return len(monitoring.CollectStructSnapshot(
+			monitoring.Default.GetRegistry("libbeat.processor.dissect"), monitoring.Full, false))
+	}
+	before := count()
+
+	proc, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, before+1, count())
+
+	assert.NoError(t, processors.Close(proc))
+	assert.Equal(t, before, count())
+}
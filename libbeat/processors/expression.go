@@ -0,0 +1,851 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Expression is an expression evaluated on events, compiled from the `expression` condition or
+// the `expression` processor, for example:
+//
+//	http.response.status_code >= 500 && event.duration > 1s
+//
+// The expression supports the `||`, `&&` and `!` logical operators, the `==`, `!=`, `<`, `<=`,
+// `>`, `>=` comparison operators, the `=~` and `!~` regular expression operators, the `+`, `-`,
+// `*`, `/` and `%` arithmetic operators, parentheses and the functions listed in
+// exprFunctions. Literals are numbers, durations (converted to nanoseconds), quoted strings,
+// `true` and `false`. Any other identifier is a reference to an event field, a comparison with a
+// missing field is false.
+//
+// Arithmetic on integers results in integers, except for `/`, which always results in a float.
+// `+` concatenates strings if one of the operands is a string. Arithmetic with missing fields, or
+// a division by zero, results in an undefined value.
+type Expression struct {
+	raw  string
+	root exprNode
+}
+
+type exprNode interface {
+	// eval returns the value of the node, ok is false when the value is undefined, like a
+	// reference to a missing field.
+	eval(event ValuesMap) (v interface{}, ok bool)
+	String() string
+}
+
+// CompileExpression compiles an expression.
+func CompileExpression(s string) (*Expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression `%s`: %v", s, err)
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected `%s` at position %d", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression `%s`: %v", s, err)
+	}
+
+	return &Expression{raw: s, root: root}, nil
+}
+
+// Eval evaluates the expression, ok is false when the value is undefined, for example because
+// the expression refers to a missing field.
+func (e *Expression) Eval(event ValuesMap) (v interface{}, ok bool) {
+	return e.root.eval(event)
+}
+
+// check returns true when the expression evaluates to a truthy value.
+func (e *Expression) check(event ValuesMap) bool {
+	v, ok := e.root.eval(event)
+	return ok && truthy(v)
+}
+
+func (e *Expression) String() string {
+	return e.raw
+}
+
+// Tokenizer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "+", "-", "*", "/", "%"}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+
+		case c == '"' || c == '\'':
+			str, n, err := readString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at position %d", err, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i : i+n], value: str, pos: i})
+			i += n
+
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])) && !afterOperand(tokens)):
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || unicode.IsLetter(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			v, err := parseNumber(s[i:j])
+			if err != nil {
+				return nil, fmt.Errorf("%v at position %d", err, i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:j], value: v, pos: i})
+			i = j
+
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(s) && isIdentPart(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j], pos: i})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character `%c` at position %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", pos: len(s)}), nil
+}
+
+// afterOperand returns true if the last token ends an operand, a following `-` is a subtraction
+// and not the sign of a number.
+func afterOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokenIdent, tokenNumber, tokenString, tokenRParen:
+		return true
+	}
+	return false
+}
+
+func isIdentStart(c rune) bool {
+	return unicode.IsLetter(c) || c == '_' || c == '@'
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || unicode.IsDigit(c) || c == '.'
+}
+
+// readString reads a quoted string, a backslash escapes the following character.
+func readString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// parseNumber parses integers, floats and durations, durations are converted to nanoseconds.
+func parseNumber(s string) (interface{}, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return int64(d), nil
+	}
+	return nil, fmt.Errorf("invalid number `%s`", s)
+}
+
+// Parser
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(kind tokenKind, what string) error {
+	t := p.next()
+	if t.kind != kind {
+		return fmt.Errorf("expected %s but found `%s` at position %d", what, t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.isOperator("!") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("=~", "!~") {
+		op := p.next().text
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, fmt.Errorf("expected a regular expression string after `%s` at position %d", op, t.pos)
+		}
+		re, err := regexp.Compile(t.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %v", t.pos, err)
+		}
+		return &matchNode{left: left, re: re, negate: op == "!~"}, nil
+	}
+
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		op := p.next().text
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: op, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOperator("-") {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		zero := &literalNode{value: int64(0), text: "0"}
+		return &arithNode{op: "-", left: zero, right: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{value: t.value, text: t.text}, nil
+
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen, "`)`"); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true, text: t.text}, nil
+		case "false":
+			return &literalNode{value: false, text: t.text}, nil
+		}
+
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(t)
+		}
+		return &fieldNode{field: t.text}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected `%s` at position %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	p.next()
+
+	var args []exprNode
+	for p.peek().kind != tokenRParen {
+		if len(args) > 0 {
+			if err := p.expect(tokenComma, "`,`"); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	switch name.text {
+	case "has":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("has() expects 1 argument, got %d", len(args))
+		}
+		f, ok := args[0].(*fieldNode)
+		if !ok {
+			return nil, fmt.Errorf("has() expects a field name")
+		}
+		return &hasNode{field: f.field}, nil
+
+	case "contains":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("contains() expects 2 arguments, got %d", len(args))
+		}
+		return &containsNode{haystack: args[0], needle: args[1]}, nil
+	}
+
+	if fn, exists := exprFunctions[name.text]; exists {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() expects 1 argument, got %d", name.text, len(args))
+		}
+		return &callNode{name: name.text, fn: fn, arg: args[0]}, nil
+	}
+	return nil, fmt.Errorf("unknown function `%s` at position %d", name.text, name.pos)
+}
+
+// exprFunctions are the functions converting a single value, in addition to `has` and
+// `contains`.
+var exprFunctions = map[string]func(interface{}) (interface{}, bool){
+	"int": func(v interface{}) (interface{}, bool) {
+		if s, ok := v.(string); ok {
+			i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			return i, err == nil
+		}
+		if i, ok := toInt(v); ok {
+			return i, true
+		}
+		f, ok := toFloat(v)
+		return int64(f), ok
+	},
+	"float": func(v interface{}) (interface{}, bool) {
+		if s, ok := v.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			return f, err == nil
+		}
+		return toFloat(v)
+	},
+	"string": func(v interface{}) (interface{}, bool) {
+		return fmt.Sprint(v), true
+	},
+	"lower": func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		return strings.ToLower(s), ok
+	},
+	"upper": func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		return strings.ToUpper(s), ok
+	},
+}
+
+// Nodes
+
+type literalNode struct {
+	value interface{}
+	text  string
+}
+
+func (n *literalNode) eval(ValuesMap) (interface{}, bool) { return n.value, true }
+func (n *literalNode) String() string                     { return n.text }
+
+type fieldNode struct {
+	field string
+}
+
+func (n *fieldNode) eval(event ValuesMap) (interface{}, bool) {
+	v, err := event.GetValue(n.field)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func (n *fieldNode) String() string { return n.field }
+
+type callNode struct {
+	name string
+	fn   func(interface{}) (interface{}, bool)
+	arg  exprNode
+}
+
+func (n *callNode) eval(event ValuesMap) (interface{}, bool) {
+	v, ok := n.arg.eval(event)
+	if !ok {
+		return nil, false
+	}
+	return n.fn(v)
+}
+
+func (n *callNode) String() string { return n.name + "(" + n.arg.String() + ")" }
+
+type arithNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithNode) eval(event ValuesMap) (interface{}, bool) {
+	l, ok := n.left.eval(event)
+	if !ok {
+		return nil, false
+	}
+	r, ok := n.right.eval(event)
+	if !ok {
+		return nil, false
+	}
+
+	if n.op == "+" {
+		_, lString := l.(string)
+		_, rString := r.(string)
+		if lString || rString {
+			return fmt.Sprint(l) + fmt.Sprint(r), true
+		}
+	}
+
+	li, lInt := toInt(l)
+	ri, rInt := toInt(r)
+	if lInt && rInt && n.op != "/" {
+		switch n.op {
+		case "+":
+			return li + ri, true
+		case "-":
+			return li - ri, true
+		case "*":
+			return li * ri, true
+		default:
+			if ri == 0 {
+				return nil, false
+			}
+			return li % ri, true
+		}
+	}
+
+	lf, ok := toFloat(l)
+	if !ok {
+		return nil, false
+	}
+	rf, ok := toFloat(r)
+	if !ok {
+		return nil, false
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, true
+	case "-":
+		return lf - rf, true
+	case "*":
+		return lf * rf, true
+	case "/":
+		if rf == 0 {
+			return nil, false
+		}
+		return lf / rf, true
+	default:
+		if rf == 0 {
+			return nil, false
+		}
+		return math.Mod(lf, rf), true
+	}
+}
+
+func (n *arithNode) String() string {
+	return "(" + n.left.String() + " " + n.op + " " + n.right.String() + ")"
+}
+
+type orNode struct{ left, right exprNode }
+
+func (n *orNode) eval(event ValuesMap) (interface{}, bool) {
+	if v, ok := n.left.eval(event); ok && truthy(v) {
+		return true, true
+	}
+	v, ok := n.right.eval(event)
+	return ok && truthy(v), true
+}
+
+func (n *orNode) String() string { return "(" + n.left.String() + " || " + n.right.String() + ")" }
+
+type andNode struct{ left, right exprNode }
+
+func (n *andNode) eval(event ValuesMap) (interface{}, bool) {
+	if v, ok := n.left.eval(event); !ok || !truthy(v) {
+		return false, true
+	}
+	v, ok := n.right.eval(event)
+	return ok && truthy(v), true
+}
+
+func (n *andNode) String() string { return "(" + n.left.String() + " && " + n.right.String() + ")" }
+
+type notNode struct{ n exprNode }
+
+func (n *notNode) eval(event ValuesMap) (interface{}, bool) {
+	v, ok := n.n.eval(event)
+	return !(ok && truthy(v)), true
+}
+
+func (n *notNode) String() string { return "!" + n.n.String() }
+
+type hasNode struct{ field string }
+
+func (n *hasNode) eval(event ValuesMap) (interface{}, bool) {
+	_, err := event.GetValue(n.field)
+	return err == nil, true
+}
+
+func (n *hasNode) String() string { return "has(" + n.field + ")" }
+
+type containsNode struct{ haystack, needle exprNode }
+
+func (n *containsNode) eval(event ValuesMap) (interface{}, bool) {
+	h, ok := n.haystack.eval(event)
+	if !ok {
+		return false, true
+	}
+	nv, ok := n.needle.eval(event)
+	if !ok {
+		return false, true
+	}
+	needle := fmt.Sprint(nv)
+
+	switch h := h.(type) {
+	case string:
+		return strings.Contains(h, needle), true
+	case []string:
+		for _, s := range h {
+			if s == needle {
+				return true, true
+			}
+		}
+	case []interface{}:
+		for _, s := range h {
+			if fmt.Sprint(s) == needle {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+func (n *containsNode) String() string {
+	return "contains(" + n.haystack.String() + ", " + n.needle.String() + ")"
+}
+
+type matchNode struct {
+	left   exprNode
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n *matchNode) eval(event ValuesMap) (interface{}, bool) {
+	v, ok := n.left.eval(event)
+	if !ok {
+		return false, true
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	return n.re.MatchString(s) != n.negate, true
+}
+
+func (n *matchNode) String() string {
+	op := " =~ "
+	if n.negate {
+		op = " !~ "
+	}
+	return n.left.String() + op + strconv.Quote(n.re.String())
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(event ValuesMap) (interface{}, bool) {
+	l, ok := n.left.eval(event)
+	if !ok {
+		return false, true
+	}
+	r, ok := n.right.eval(event)
+	if !ok {
+		return false, true
+	}
+
+	cmp, comparable := compareValues(l, r)
+	if !comparable {
+		// values of different types are only different.
+		return n.op == "!=", true
+	}
+
+	switch n.op {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return cmp < 0, true
+	case "<=":
+		return cmp <= 0, true
+	case ">":
+		return cmp > 0, true
+	default:
+		return cmp >= 0, true
+	}
+}
+
+func (n *compareNode) String() string {
+	return n.left.String() + " " + n.op + " " + n.right.String()
+}
+
+// compareValues compares numbers, strings and booleans, booleans are only equal or different.
+func compareValues(l, r interface{}) (int, bool) {
+	if lf, ok := toFloat(l); ok {
+		rf, ok := toFloat(r)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case lf < rf:
+			return -1, true
+		case lf > rf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if ls, ok := l.(string); ok {
+		rs, ok := r.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(ls, rs), true
+	}
+
+	if lb, ok := l.(bool); ok {
+		rb, ok := r.(bool)
+		if !ok || lb != rb {
+			return 1, ok
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case time.Duration:
+		return float64(v), true
+	case common.Float:
+		return float64(v), true
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(v).Int()), true
+	case uint, uint8, uint16, uint32, uint64:
+		return float64(reflect.ValueOf(v).Uint()), true
+	case float32, float64:
+		return reflect.ValueOf(v).Float(), true
+	}
+	return 0, false
+}
+
+// toInt converts integer values to int64. Unsigned values are only converted if they fit.
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case time.Duration:
+		return int64(v), true
+	case int, int8, int16, int32, int64:
+		return reflect.ValueOf(v).Int(), true
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(v).Uint()
+		return int64(u), u <= math.MaxInt64
+	}
+	return 0, false
+}
+
+// truthy returns the boolean value of an expression result, it allows to use fields directly
+// in the logical operators.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+var expressionEvent = &beat.Event{
+	Timestamp: time.Now(),
+	Fields: common.MapStr{
+		"http": common.MapStr{
+			"request":  common.MapStr{"method": "GET"},
+			"response": common.MapStr{"status_code": 503},
+		},
+		"event": common.MapStr{
+			"duration": int64(2 * time.Second),
+		},
+		"message": "connection refused by upstream",
+		"tags":    []string{"nginx", "proxy"},
+		"cached":  false,
+		"ratio":   0.75,
+	},
+}
+
+func TestExpressionCheck(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`http.response.status_code >= 500 && event.duration > 1s`, true},
+		{`http.response.status_code >= 500 && event.duration > 5s`, false},
+		{`http.response.status_code == 503`, true},
+		{`http.response.status_code != 503`, false},
+		{`http.response.status_code < 500 || http.request.method == "GET"`, true},
+		{`!(http.request.method == 'POST')`, true},
+		{`http.request.method == "POST" || (ratio > 0.5 && ratio <= 0.75)`, true},
+		{`message =~ "refused"`, true},
+		{`message !~ "^connection"`, false},
+		{`contains(message, "upstream")`, true},
+		{`contains(tags, "proxy")`, true},
+		{`contains(tags, "apache")`, false},
+		{`has(http.request.method)`, true},
+		{`has(user.name)`, false},
+		{`!has(user.name)`, true},
+		{`cached`, false},
+		{`!cached`, true},
+		{`message`, true},
+
+		// comparisons with missing fields or values of different types are false.
+		{`user.name == "root"`, false},
+		{`user.name != "root"`, false},
+		{`http.request.method > 3`, false},
+		{`http.request.method != 3`, true},
+		{`missing`, false},
+		{`!missing`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := CompileExpression(test.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, test.expected, e.check(expressionEvent))
+		})
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`http.response.status_code + 1`, int64(504)},
+		{`http.response.status_code -1`, int64(502)},
+		{`http.response.status_code - -1`, int64(504)},
+		{`-http.response.status_code`, int64(-503)},
+		{`1 + 2 * 3`, int64(7)},
+		{`(1 + 2) * 3`, int64(9)},
+		{`7 % 4`, int64(3)},
+		{`event.duration / 1s`, 2.0},
+		{`3 / 2`, 1.5},
+		{`ratio * 100`, 75.0},
+		{`http.request.method + " " + message`, "GET connection refused by upstream"},
+		{`"code " + http.response.status_code`, "code 503"},
+		{`http.response.status_code / 100 == 5.03`, true},
+		{`int("42") + 1`, int64(43)},
+		{`int(ratio * 10)`, int64(7)},
+		{`float("0.5")`, 0.5},
+		{`string(http.response.status_code)`, "503"},
+		{`upper(http.request.method)`, "GET"},
+		{`lower("ABC")`, "abc"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := CompileExpression(test.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			v, ok := e.Eval(expressionEvent)
+			if assert.True(t, ok) {
+				assert.Equal(t, test.expected, v)
+			}
+		})
+	}
+}
+
+func TestExpressionEvalUndefined(t *testing.T) {
+	tests := []string{
+		`missing + 1`,
+		`1 / 0`,
+		`5 % 0`,
+		`cached * 2`,
+		`int("text")`,
+		`upper(ratio)`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			e, err := CompileExpression(test)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, ok := e.Eval(expressionEvent)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestExpressionCompileErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`http.response.status_code >=`,
+		`(a == 1`,
+		`a == 1)`,
+		`a == "unterminated`,
+		`a # 1`,
+		`a =~ 1`,
+		`a =~ "("`,
+		`unknown(a)`,
+		`has("a")`,
+		`contains(a)`,
+		`a == 1x`,
+		`a +`,
+		`a * * 2`,
+		`int(a, b)`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			_, err := CompileExpression(test)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpressionCondition(t *testing.T) {
+	config, err := common.NewConfigFrom(map[string]interface{}{
+		"expression": `http.response.status_code >= 500 && event.duration > 1s`,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	condConfig := ConditionConfig{}
+	if !assert.NoError(t, config.Unpack(&condConfig)) {
+		return
+	}
+
+	cond, err := NewCondition(&condConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, cond.Check(expressionEvent))
+	assert.False(t, cond.Check(&beat.Event{Fields: common.MapStr{}}))
+}
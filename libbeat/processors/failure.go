@@ -0,0 +1,47 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// ErrorMessageKey is the field the reason of a processing failure is written to.
+const ErrorMessageKey = "error.message"
+
+// TagOnFailure adds tags to the event and writes err, prefixed with the
+// processor name, to `error.message`. The error is returned unchanged if no
+// tags are configured, so the caller can decide to log it.
+func TagOnFailure(event *beat.Event, tags []string, name string, err error) (*beat.Event, error) {
+	if len(tags) == 0 {
+		return event, err
+	}
+
+	if tagErr := common.AddTags(event.Fields, tags); tagErr != nil {
+		return event, errors.Wrapf(err, "cannot add tags: %v", tagErr)
+	}
+
+	if _, putErr := event.PutValue(ErrorMessageKey, name+": "+err.Error()); putErr != nil {
+		return event, errors.Wrapf(err, "cannot set `%s`: %v", ErrorMessageKey, putErr)
+	}
+
+	return event, nil
+}
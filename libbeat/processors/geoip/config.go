@@ -0,0 +1,48 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	Database     string        `config:"database"`
+	ASNDatabase  string        `config:"asn_database"`
+	Fields       []string      `config:"fields"`
+	Language     string        `config:"language"`
+	ReloadPeriod time.Duration `config:"reload_period" validate:"min=0"`
+}
+
+var defaultConfig = config{
+	Fields:       []string{"source.ip", "destination.ip"},
+	Language:     "en",
+	ReloadPeriod: time.Minute,
+}
+
+// Validate makes sure that at least one database is configured.
+func (c *config) Validate() error {
+	if c.Database == "" && c.ASNDatabase == "" {
+		return errors.New("at least one of database or asn_database must be defined")
+	}
+	if len(c.Fields) == 0 {
+		return errors.New("fields cannot be empty")
+	}
+	return nil
+}
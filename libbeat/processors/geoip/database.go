@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/common/file"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// database holds a MaxMind DB file in memory and reloads it when the file changes on disk. The
+// file is checked lazily during lookups, at most once per reload period.
+type database struct {
+	file *file.Reloader
+	log  *logp.Logger
+}
+
+func openDatabase(path string, period time.Duration, log *logp.Logger) (*database, error) {
+	f, err := file.NewReloader(path, period, loadDatabase)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open GeoIP database")
+	}
+	return &database{file: f, log: log}, nil
+}
+
+func loadDatabase(path string) (interface{}, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read GeoIP database")
+	}
+
+	r, err := newReader(buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load GeoIP database %s", path)
+	}
+	return r, nil
+}
+
+// lookup reloads the database when the period is elapsed and the file has changed, on failure
+// the previous version of the database is used.
+func (db *database) lookup(ip net.IP) (map[string]interface{}, error) {
+	r, reloaded, err := db.file.Get()
+	if err != nil {
+		db.log.Warnf("Failed to reload GeoIP database %s, keeping the previous version: %v", db.file.Path(), err)
+	} else if reloaded {
+		db.log.Infof("GeoIP database %s reloaded", db.file.Path())
+	}
+	return r.(*reader).lookup(ip)
+}
@@ -0,0 +1,370 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// metadataStart marks the beginning of the metadata section of a MaxMind DB file.
+var metadataStart = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the size of the zeroed block between the search tree and the data.
+const dataSectionSeparator = 16
+
+// maxPointerDepth protects against pointers referencing themselves in corrupted files.
+const maxPointerDepth = 32
+
+var errNotFound = errors.New("address not found")
+
+// metadata is the subset of the MaxMind DB metadata needed to walk the search tree.
+type metadata struct {
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+}
+
+// reader looks up IP addresses in a MaxMind DB file loaded in memory, the format is described at
+// https://maxmind.github.io/MaxMind-DB/.
+type reader struct {
+	buf       []byte
+	data      []byte
+	meta      metadata
+	nodeSize  uint
+	ipv4Start uint
+}
+
+func newReader(buf []byte) (*reader, error) {
+	i := bytes.LastIndex(buf, metadataStart)
+	if i == -1 {
+		return nil, errors.New("invalid MaxMind DB file: metadata section not found")
+	}
+
+	d := decoder{buf: buf[i+len(metadataStart):]}
+	raw, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %v", err)
+	}
+
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	meta := metadata{
+		nodeCount:  toUint(m["node_count"]),
+		recordSize: toUint(m["record_size"]),
+		ipVersion:  toUint(m["ip_version"]),
+	}
+	meta.databaseType, _ = m["database_type"].(string)
+
+	switch meta.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size: %d", meta.recordSize)
+	}
+	if meta.ipVersion != 4 && meta.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB ip version: %d", meta.ipVersion)
+	}
+
+	r := &reader{buf: buf, meta: meta, nodeSize: meta.recordSize / 4}
+	treeSize := meta.nodeCount * r.nodeSize
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, errors.New("invalid MaxMind DB file: search tree exceeds the file size")
+	}
+	r.data = buf[treeSize+dataSectionSeparator : i]
+
+	// IPv4 addresses are stored in IPv6 trees under the `::/96` network.
+	if meta.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < meta.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// lookup returns the data associated to the network containing the address.
+func (r *reader) lookup(ip net.IP) (map[string]interface{}, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		node = r.ipv4Start
+	} else if r.meta.ipVersion == 4 {
+		return nil, errNotFound
+	} else {
+		ip = ip.To16()
+		if ip == nil {
+			return nil, errNotFound
+		}
+	}
+
+	for i := 0; i < bits && node < r.meta.nodeCount; i++ {
+		bit := (uint(ip[i>>3]) >> (7 - uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+
+	if node <= r.meta.nodeCount {
+		return nil, errNotFound
+	}
+
+	offset := node - r.meta.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, errors.New("invalid MaxMind DB file: data pointer out of range")
+	}
+
+	d := decoder{buf: r.data}
+	v, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB record: not a map")
+	}
+	return m, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node.
+func (r *reader) record(node, bit uint) uint {
+	b := r.buf[node*r.nodeSize : (node+1)*r.nodeSize]
+	switch r.meta.recordSize {
+	case 24:
+		o := bit * 3
+		return uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		o := bit * 4
+		return uint(binary.BigEndian.Uint32(b[o : o+4]))
+	}
+}
+
+// decoder reads values from the data section of a MaxMind DB file.
+type decoder struct {
+	buf []byte
+}
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decode returns the value at offset and the offset following it.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		if depth >= maxPointerDepth {
+			return nil, 0, errors.New("too many nested pointers")
+		}
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(target, depth+1)
+		return v, next, err
+	}
+
+	if typ == typeMap {
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var k, v interface{}
+			k, offset, err = d.decode(offset, depth)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			v, offset, err = d.decode(offset, depth)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	}
+
+	if typ == typeArray {
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			v, offset, err = d.decode(offset, depth)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	}
+
+	if typ == typeBool {
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	b := d.buf[offset:end]
+
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size: %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size: %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size: %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid integer size: %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), end, nil
+	case typeUint128:
+		// Values that can't be represented are kept in their binary form.
+		return append([]byte(nil), b...), end, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type: %d", typ)
+}
+
+// control reads the control byte of a field and returns its type, size and the offset of its
+// payload.
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errors.New("unexpected end of data")
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errors.New("unexpected end of data")
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typ == typePointer {
+		return typ, size, offset, nil
+	}
+
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, errors.New("unexpected end of data")
+		}
+		var v uint
+		for _, c := range d.buf[offset : offset+n] {
+			v = v<<8 | uint(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + v
+		case 2:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+	return typ, size, offset, nil
+}
+
+// pointer resolves the target of a pointer, size holds the 5 low bits of the control byte.
+func (d *decoder) pointer(size, offset uint) (uint, uint, error) {
+	n := ((size >> 3) & 0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+
+	var v uint
+	if n != 4 {
+		v = size & 0x7
+	}
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+func toUint(v interface{}) uint {
+	if u, ok := v.(uint64); ok {
+		return uint(u)
+	}
+	return 0
+}
@@ -0,0 +1,287 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNetwork struct {
+	cidr string
+	data map[string]interface{}
+}
+
+type testNode struct {
+	children [2]*testNode
+	leaf     bool
+	data     int
+	id       int
+}
+
+// buildMMDB writes a MaxMind DB file with a record size of 24 bits containing the networks.
+func buildMMDB(t *testing.T, ipVersion int, networks []testNetwork) []byte {
+	root := &testNode{}
+	for i, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ip := []byte(ipnet.IP)
+		ones, _ := ipnet.Mask.Size()
+		if ipVersion == 6 && len(ip) == net.IPv4len {
+			ip = append(make([]byte, 12), ip...)
+			ones += 96
+		}
+
+		node := root
+		for b := 0; b < ones; b++ {
+			bit := (ip[b/8] >> uint(7-b%8)) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &testNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.data = i
+	}
+
+	var nodes []*testNode
+	var number func(n *testNode)
+	number = func(n *testNode) {
+		if n == nil || n.leaf {
+			return
+		}
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		number(n.children[0])
+		number(n.children[1])
+	}
+	number(root)
+
+	var data bytes.Buffer
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		offsets[i] = data.Len()
+		encode(&data, n.data)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		for _, c := range n.children {
+			v := len(nodes)
+			if c != nil && c.leaf {
+				v = len(nodes) + dataSectionSeparator + offsets[c.data]
+			} else if c != nil {
+				v = c.id
+			}
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data.Bytes())
+	buf.Write(metadataStart)
+	encode(&buf, map[string]interface{}{
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(ipVersion),
+		"database_type":               "Test",
+		"binary_format_major_version": uint16(2),
+	})
+	return buf.Bytes()
+}
+
+func encodeControl(buf *bytes.Buffer, typ int, size int) {
+	ctrl := byte(0)
+	if typ < 8 {
+		ctrl = byte(typ << 5)
+	}
+	if size < 29 {
+		ctrl |= byte(size)
+	} else {
+		ctrl |= 29
+	}
+	buf.WriteByte(ctrl)
+	if typ >= 8 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	if size >= 29 {
+		buf.WriteByte(byte(size - 29))
+	}
+}
+
+func encodeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b []byte
+	for v > 0 {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+	}
+	encodeControl(buf, typ, len(b))
+	buf.Write(b)
+}
+
+func encode(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		encodeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case float64:
+		encodeControl(buf, typeDouble, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		encodeUint(buf, typeUint16, uint64(v))
+	case uint32:
+		encodeUint(buf, typeUint32, uint64(v))
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		encodeControl(buf, typeBool, size)
+	case []interface{}:
+		encodeControl(buf, typeArray, len(v))
+		for _, e := range v {
+			encode(buf, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		encodeControl(buf, typeMap, len(v))
+		for _, k := range keys {
+			encode(buf, k)
+			encode(buf, v[k])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func TestReaderLookup(t *testing.T) {
+	networks := []testNetwork{
+		{cidr: "10.0.0.0/8", data: map[string]interface{}{"name": "ten"}},
+		{cidr: "192.168.1.0/24", data: map[string]interface{}{"name": "home", "private": true}},
+		{cidr: "2001:db8::/32", data: map[string]interface{}{"name": "doc"}},
+	}
+
+	tests := []struct {
+		ip       string
+		expected map[string]interface{}
+	}{
+		{ip: "10.1.2.3", expected: map[string]interface{}{"name": "ten"}},
+		{ip: "192.168.1.254", expected: map[string]interface{}{"name": "home", "private": true}},
+		{ip: "2001:db8::1", expected: map[string]interface{}{"name": "doc"}},
+		{ip: "192.168.2.1"},
+		{ip: "2001:db9::1"},
+	}
+
+	r, err := newReader(buildMMDB(t, 6, networks))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			m, err := r.lookup(net.ParseIP(test.ip))
+			if test.expected == nil {
+				assert.Equal(t, errNotFound, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, m)
+			}
+		})
+	}
+}
+
+func TestReaderIPv4Database(t *testing.T) {
+	r, err := newReader(buildMMDB(t, 4, []testNetwork{
+		{cidr: "10.0.0.0/8", data: map[string]interface{}{"name": "ten"}},
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	m, err := r.lookup(net.ParseIP("10.0.0.1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"name": "ten"}, m)
+	}
+
+	_, err = r.lookup(net.ParseIP("2001:db8::1"))
+	assert.Equal(t, errNotFound, err)
+}
+
+func TestReaderInvalidFile(t *testing.T) {
+	_, err := newReader([]byte("not a database"))
+	assert.Error(t, err)
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("pointer", func(t *testing.T) {
+		var buf bytes.Buffer
+		encode(&buf, "hello")
+		// pointer to offset 0, followed by a second value.
+		buf.Write([]byte{typePointer << 5, 0x00})
+		encode(&buf, uint32(42))
+
+		d := decoder{buf: buf.Bytes()}
+		v, next, err := d.decode(6, 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "hello", v)
+
+		v, _, err = d.decode(next, 0)
+		if assert.NoError(t, err) {
+			assert.Equal(t, uint64(42), v)
+		}
+	})
+
+	t.Run("long string", func(t *testing.T) {
+		s := string(bytes.Repeat([]byte("a"), 100))
+		var buf bytes.Buffer
+		encode(&buf, s)
+
+		d := decoder{buf: buf.Bytes()}
+		v, _, err := d.decode(0, 0)
+		if assert.NoError(t, err) {
+			assert.Equal(t, s, v)
+		}
+	})
+
+	t.Run("pointer loop", func(t *testing.T) {
+		d := decoder{buf: []byte{typePointer << 5, 0x00}}
+		_, _, err := d.decode(0, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		d := decoder{buf: []byte{typeString<<5 | 10, 'a'}}
+		_, _, err := d.decode(0, 0)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/paths"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type processor struct {
+	config config
+	city   *database
+	asn    *database
+}
+
+func init() {
+	processors.RegisterPlugin("geoip", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The geoip processor is beta.")
+
+	config := defaultConfig
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logp.NewLogger("geoip")
+	p := &processor{config: config}
+
+	if config.Database != "" {
+		p.city, err = openDatabase(paths.Resolve(paths.Config, config.Database), config.ReloadPeriod, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ASNDatabase != "" {
+		p.asn, err = openDatabase(paths.Resolve(paths.Config, config.ASNDatabase), config.ReloadPeriod, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Run looks up the configured IP fields and adds the `geo` and `as` objects next to them.
+// Missing fields and addresses not found in the databases are ignored.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []string
+
+	for _, field := range p.config.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+
+		ip := toIP(v)
+		if ip == nil {
+			errs = append(errs, fmt.Sprintf("field `%s` is not a valid IP address: `%v`", field, v))
+			continue
+		}
+
+		prefix := ""
+		if i := strings.LastIndex(field, "."); i != -1 {
+			prefix = field[:i] + "."
+		}
+
+		if p.city != nil {
+			if record, err := p.city.lookup(ip); err == nil {
+				if geo := p.geoFields(record); len(geo) > 0 {
+					event.PutValue(prefix+"geo", geo)
+				}
+			} else if err != errNotFound {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if p.asn != nil {
+			if record, err := p.asn.lookup(ip); err == nil {
+				if as := asFields(record); len(as) > 0 {
+					event.PutValue(prefix+"as", as)
+				}
+			} else if err != errNotFound {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return event, fmt.Errorf("geoip lookup failed: %s", strings.Join(errs, ", "))
+	}
+	return event, nil
+}
+
+// geoFields maps a GeoIP2/GeoLite2 City or Country record to the ECS `geo` fields.
+func (p *processor) geoFields(record map[string]interface{}) common.MapStr {
+	geo := common.MapStr{}
+
+	if name := p.localizedName(record["continent"]); name != "" {
+		geo["continent_name"] = name
+	}
+
+	country, _ := record["country"].(map[string]interface{})
+	countryCode, _ := country["iso_code"].(string)
+	if countryCode != "" {
+		geo["country_iso_code"] = countryCode
+	}
+	if name := p.localizedName(country); name != "" {
+		geo["country_name"] = name
+	}
+
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if region, ok := subdivisions[0].(map[string]interface{}); ok {
+			if name := p.localizedName(region); name != "" {
+				geo["region_name"] = name
+			}
+			if code, _ := region["iso_code"].(string); code != "" && countryCode != "" {
+				geo["region_iso_code"] = countryCode + "-" + code
+			}
+		}
+	}
+
+	if name := p.localizedName(record["city"]); name != "" {
+		geo["city_name"] = name
+	}
+
+	if location, ok := record["location"].(map[string]interface{}); ok {
+		lat, latOK := location["latitude"].(float64)
+		lon, lonOK := location["longitude"].(float64)
+		if latOK && lonOK {
+			geo["location"] = common.MapStr{"lat": lat, "lon": lon}
+		}
+	}
+
+	return geo
+}
+
+// localizedName returns the name of a record object in the configured language.
+func (p *processor) localizedName(v interface{}) string {
+	obj, _ := v.(map[string]interface{})
+	names, _ := obj["names"].(map[string]interface{})
+	name, _ := names[p.config.Language].(string)
+	return name
+}
+
+// asFields maps a GeoLite2 ASN record to the ECS `as` fields.
+func asFields(record map[string]interface{}) common.MapStr {
+	as := common.MapStr{}
+	if number, ok := record["autonomous_system_number"].(uint64); ok {
+		as["number"] = number
+	}
+	if org, ok := record["autonomous_system_organization"].(string); ok && org != "" {
+		as["organization"] = common.MapStr{"name": org}
+	}
+	return as
+}
+
+func toIP(v interface{}) net.IP {
+	switch ip := v.(type) {
+	case net.IP:
+		return ip
+	case string:
+		return net.ParseIP(ip)
+	}
+	return nil
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("geoip=[database=%s, asn_database=%s, fields=%v]",
+		p.config.Database, p.config.ASNDatabase, p.config.Fields)
+}
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+var cityNetworks = []testNetwork{
+	{
+		cidr: "81.2.69.0/24",
+		data: map[string]interface{}{
+			"continent": map[string]interface{}{"code": "EU", "names": map[string]interface{}{"en": "Europe"}},
+			"country":   map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+			"subdivisions": []interface{}{
+				map[string]interface{}{"iso_code": "ENG", "names": map[string]interface{}{"en": "England"}},
+			},
+			"city":     map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+			"location": map[string]interface{}{"latitude": 51.5142, "longitude": -0.0931},
+		},
+	},
+}
+
+var asnNetworks = []testNetwork{
+	{
+		cidr: "81.2.69.0/24",
+		data: map[string]interface{}{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		},
+	},
+}
+
+func writeDatabase(t *testing.T, dir, name string, networks []testNetwork) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, buildMMDB(t, 6, networks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := common.NewConfigFrom(map[string]interface{}{
+		"database":     writeDatabase(t, dir, "city.mmdb", cityNetworks),
+		"asn_database": writeDatabase(t, dir, "asn.mmdb", asnNetworks),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{
+		"source":      common.MapStr{"ip": "81.2.69.142"},
+		"destination": common.MapStr{"ip": "10.0.0.1"},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, common.MapStr{
+		"source": common.MapStr{
+			"ip": "81.2.69.142",
+			"geo": common.MapStr{
+				"continent_name":   "Europe",
+				"country_iso_code": "GB",
+				"country_name":     "United Kingdom",
+				"region_name":      "England",
+				"region_iso_code":  "GB-ENG",
+				"city_name":        "London",
+				"location":         common.MapStr{"lat": 51.5142, "lon": -0.0931},
+			},
+			"as": common.MapStr{
+				"number":       uint64(20712),
+				"organization": common.MapStr{"name": "Andrews & Arnold Ltd"},
+			},
+		},
+		"destination": common.MapStr{"ip": "10.0.0.1"},
+	}, event.Fields)
+}
+
+func TestProcessorInvalidIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := common.NewConfigFrom(map[string]interface{}{
+		"database": writeDatabase(t, dir, "city.mmdb", cityNetworks),
+		"fields":   []string{"client_ip"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = p.Run(&beat.Event{Fields: common.MapStr{"client_ip": "not an ip"}})
+	assert.Error(t, err)
+}
+
+func TestProcessorReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeDatabase(t, dir, "asn.mmdb", asnNetworks)
+	c, err := common.NewConfigFrom(map[string]interface{}{
+		"asn_database":  path,
+		"fields":        []string{"ip"},
+		"reload_period": "1ns",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	writeDatabase(t, dir, "asn.mmdb", []testNetwork{
+		{cidr: "81.2.69.0/24", data: map[string]interface{}{"autonomous_system_number": uint32(1)}},
+	})
+	// Make sure the modification time is different on filesystems with a coarse resolution.
+	later := time.Now().Add(time.Minute)
+	if !assert.NoError(t, os.Chtimes(path, later, later)) {
+		return
+	}
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"ip": "81.2.69.142"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, common.MapStr{"ip": "81.2.69.142", "as": common.MapStr{"number": uint64(1)}}, event.Fields)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		c    map[string]interface{}
+	}{
+		{name: "no database", c: map[string]interface{}{}},
+		{name: "missing database", c: map[string]interface{}{"database": "/does/not/exist.mmdb"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = newProcessor(c)
+			assert.Error(t, err)
+		})
+	}
+}
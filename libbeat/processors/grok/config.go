@@ -0,0 +1,49 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"fmt"
+	"time"
+)
+
+type config struct {
+	Field              string            `config:"field"`
+	Patterns           []string          `config:"patterns" validate:"required"`
+	PatternDefinitions map[string]string `config:"pattern_definitions"`
+	PatternFiles       []string          `config:"pattern_files"`
+	TargetPrefix       string            `config:"target_prefix"`
+	TagOnFailure       []string          `config:"tag_on_failure"`
+	Timeout            time.Duration     `config:"timeout" validate:"min=0"`
+}
+
+var defaultConfig = config{
+	Field:        "message",
+	TagOnFailure: []string{"_grokparsefailure"},
+	Timeout:      30 * time.Second,
+}
+
+// Validate makes sure that empty patterns are not used, they would match any string.
+func (c *config) Validate() error {
+	for i, p := range c.Patterns {
+		if p == "" {
+			return fmt.Errorf("pattern at index %d is empty", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// referenceRE matches a pattern reference with the following syntax:
+	// `%{SYNTAX}`, `%{SYNTAX:SEMANTIC}` or `%{SYNTAX:SEMANTIC:TYPE}`.
+	referenceRE = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(\w+))?\}`)
+
+	// namedGroupRE matches the named capture groups defined directly in a pattern, both the
+	// oniguruma `(?<name>` and the RE2 `(?P<name>` syntax are supported.
+	namedGroupRE = regexp.MustCompile(`\(\?P?<([\w.@\[\]-]+)>`)
+
+	// maxDepth limits the number of nested references, it protects against cyclic definitions.
+	maxDepth = 64
+)
+
+// capture maps a regular expression group to the event field it will populate.
+type capture struct {
+	field string
+	typ   string
+}
+
+// Grok matches a string against a pattern composed of named regular expressions and returns
+// the captured values.
+type Grok struct {
+	raw      string
+	re       *regexp.Regexp
+	captures map[string]capture
+}
+
+// Compile expands all the references of the pattern using the provided definitions and
+// compiles the resulting regular expression.
+func Compile(pattern string, definitions map[string]string) (*Grok, error) {
+	g := &Grok{raw: pattern, captures: map[string]capture{}}
+
+	expanded, err := g.expand(pattern, definitions, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok pattern `%s`: %v", pattern, err)
+	}
+	g.re = re
+	return g, nil
+}
+
+func (g *Grok) expand(pattern string, definitions map[string]string, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("pattern `%s` exceeds the maximum nesting of %d, check for cyclic definitions", g.raw, maxDepth)
+	}
+
+	pattern = namedGroupRE.ReplaceAllStringFunc(pattern, func(s string) string {
+		name := namedGroupRE.FindStringSubmatch(s)[1]
+		return "(?P<" + g.addCapture(name, "") + ">"
+	})
+
+	var err error
+	expanded := referenceRE.ReplaceAllStringFunc(pattern, func(s string) string {
+		if err != nil {
+			return s
+		}
+
+		m := referenceRE.FindStringSubmatch(s)
+		name, semantic, typ := m[1], m[2], m[3]
+
+		definition, found := definitions[name]
+		if !found {
+			err = fmt.Errorf("pattern `%s` is not defined", name)
+			return s
+		}
+
+		if typ != "" && !isSupportedType(typ) {
+			err = fmt.Errorf("unsupported type `%s` for field `%s`", typ, semantic)
+			return s
+		}
+
+		var sub string
+		sub, err = g.expand(definition, definitions, depth+1)
+		if err != nil {
+			return s
+		}
+
+		if semantic == "" {
+			return "(?:" + sub + ")"
+		}
+		return "(?P<" + g.addCapture(semantic, typ) + ">" + sub + ")"
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// addCapture registers a new capture and returns the generated group name, field names can
+// contains characters that are not allowed in group names.
+func (g *Grok) addCapture(field, typ string) string {
+	name := "g" + strconv.Itoa(len(g.captures))
+	g.captures[name] = capture{field: normalizeField(field), typ: typ}
+	return name
+}
+
+// Match returns the captured values when the string matches the pattern, values are converted
+// to the type defined in the pattern. When the same field is captured multiple times the first
+// non empty value is kept.
+func (g *Grok) Match(s string) (map[string]interface{}, bool, error) {
+	values := g.re.FindStringSubmatch(s)
+	if values == nil {
+		return nil, false, nil
+	}
+
+	m := make(map[string]interface{}, len(g.captures))
+	for i, name := range g.re.SubexpNames() {
+		c, found := g.captures[name]
+		if !found || values[i] == "" {
+			continue
+		}
+
+		if _, exists := m[c.field]; exists {
+			continue
+		}
+
+		v, err := convert(values[i], c.typ)
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot convert value `%s` of field `%s` to `%s`: %v", values[i], c.field, c.typ, err)
+		}
+		m[c.field] = v
+	}
+	return m, true, nil
+}
+
+// Raw returns the pattern before expansion.
+func (g *Grok) Raw() string {
+	return g.raw
+}
+
+// normalizeField converts the Logstash field reference syntax `[source][ip]` to `source.ip`.
+func normalizeField(field string) string {
+	if !strings.HasPrefix(field, "[") {
+		return field
+	}
+	return strings.Trim(strings.Replace(field, "][", ".", -1), "[]")
+}
+
+func isSupportedType(typ string) bool {
+	switch typ {
+	case "int", "long", "float", "double", "string":
+		return true
+	}
+	return false
+}
+
+func convert(v, typ string) (interface{}, error) {
+	switch typ {
+	case "int", "long":
+		return strconv.ParseInt(v, 10, 64)
+	case "float", "double":
+		return strconv.ParseFloat(v, 64)
+	}
+	return v, nil
+}
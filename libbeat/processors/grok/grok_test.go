@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasePatternsCompile(t *testing.T) {
+	for name := range basePatterns {
+		_, err := Compile("%{"+name+"}", basePatterns)
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		definitions map[string]string
+		s           string
+		expected    map[string]interface{}
+		match       bool
+	}{
+		{
+			name:     "simple reference",
+			pattern:  "%{WORD:verb} %{GREEDYDATA:rest}",
+			s:        "hello beautiful world",
+			expected: map[string]interface{}{"verb": "hello", "rest": "beautiful world"},
+			match:    true,
+		},
+		{
+			name:     "type conversion",
+			pattern:  "%{INT:count:int} %{NUMBER:duration:float}",
+			s:        "42 1.5",
+			expected: map[string]interface{}{"count": int64(42), "duration": 1.5},
+			match:    true,
+		},
+		{
+			name:     "logstash field reference",
+			pattern:  "%{IP:[source][ip]}",
+			s:        "10.0.0.1",
+			expected: map[string]interface{}{"source.ip": "10.0.0.1"},
+			match:    true,
+		},
+		{
+			name:     "named group",
+			pattern:  "(?<queue_id>[0-9A-F]{10,11}): %{GREEDYDATA:message}",
+			s:        "BEF25A72965: message-id=<20130101142543.5828399CCAF@example.com>",
+			expected: map[string]interface{}{"queue_id": "BEF25A72965", "message": "message-id=<20130101142543.5828399CCAF@example.com>"},
+			match:    true,
+		},
+		{
+			name:        "custom definitions",
+			pattern:     "%{LEVEL:log.level} %{GREEDYDATA:message}",
+			definitions: map[string]string{"LEVEL": "(?:DEBUG|INFO)"},
+			s:           "INFO started",
+			expected:    map[string]interface{}{"log.level": "INFO", "message": "started"},
+			match:       true,
+		},
+		{
+			name:    "ipv6",
+			pattern: "%{IP:client} %{WORD:verb}",
+			s:       "fe80::1ff:fe23:4567:890a GET",
+			expected: map[string]interface{}{
+				"client": "fe80::1ff:fe23:4567:890a",
+				"verb":   "GET",
+			},
+			match: true,
+		},
+		{
+			name:    "no match",
+			pattern: "%{INT:count}",
+			s:       "hello",
+			match:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definitions := map[string]string{}
+			for k, v := range basePatterns {
+				definitions[k] = v
+			}
+			for k, v := range test.definitions {
+				definitions[k] = v
+			}
+
+			g, err := Compile(test.pattern, definitions)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			m, ok, err := g.Match(test.s)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, test.match, ok)
+			if test.match {
+				assert.Equal(t, test.expected, m)
+			}
+		})
+	}
+}
+
+func TestCombinedApacheLog(t *testing.T) {
+	g, err := Compile("%{COMBINEDAPACHELOG}", basePatterns)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	line := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`
+	m, ok, err := g.Match(line)
+	if !assert.NoError(t, err) || !assert.True(t, ok) {
+		return
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"source.address":            "127.0.0.1",
+		"user.name":                 "frank",
+		"timestamp":                 "10/Oct/2000:13:55:36 -0700",
+		"http.request.method":       "GET",
+		"url.original":              "/apache_pb.gif",
+		"http.version":              "1.0",
+		"http.response.status_code": int64(200),
+		"http.response.body.bytes":  int64(2326),
+		"http.request.referrer":     "http://www.example.com/start.html",
+		"user_agent.original":       "Mozilla/4.08 [en] (Win98; I ;Nav)",
+	}, m)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		definitions map[string]string
+	}{
+		{name: "undefined pattern", pattern: "%{UNKNOWN}"},
+		{name: "unsupported type", pattern: "%{WORD:verb:boolean}", definitions: map[string]string{"WORD": `\w+`}},
+		{name: "cyclic definition", pattern: "%{A}", definitions: map[string]string{"A": "%{B}", "B": "%{A}"}},
+		{name: "invalid regexp", pattern: "%{A}", definitions: map[string]string{"A": "(?<!x)y"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Compile(test.pattern, test.definitions)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	definitions := map[string]string{}
+	err := parsePatterns(strings.NewReader(`
+# Postfix patterns
+POSTFIX_QUEUEID [0-9A-F]{10,11}
+POSTFIX_CLIENT %{HOSTNAME}\[%{IP}\]
+`), definitions)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{
+		"POSTFIX_QUEUEID": "[0-9A-F]{10,11}",
+		"POSTFIX_CLIENT":  `%{HOSTNAME}\[%{IP}\]`,
+	}, definitions)
+
+	err = parsePatterns(strings.NewReader("MISSING"), definitions)
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/paths"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+var errTimeout = errors.New("timeout while matching the patterns")
+
+type processor struct {
+	config   config
+	patterns []*Grok
+
+	metrics                            *monitoring.Registry
+	matched, failed, skipped, timeouts *monitoring.Int
+}
+
+func init() {
+	processors.RegisterPlugin("grok", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	config := defaultConfig
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, len(config.PatternFiles))
+	for i, f := range config.PatternFiles {
+		files[i] = paths.Resolve(paths.Config, f)
+	}
+
+	definitions, err := loadDefinitions(files, config.PatternDefinitions)
+	if err != nil {
+		return nil, err
+	}
+
+	reg := processors.NewMetricsRegistry("grok")
+	p := &processor{
+		config:   config,
+		metrics:  reg,
+		matched:  monitoring.NewInt(reg, "matched"),
+		failed:   monitoring.NewInt(reg, "failed"),
+		skipped:  monitoring.NewInt(reg, "skipped"),
+		timeouts: monitoring.NewInt(reg, "timeouts"),
+	}
+	for _, pattern := range config.Patterns {
+		g, err := Compile(pattern, definitions)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.patterns = append(p.patterns, g)
+	}
+
+	return p, nil
+}
+
+// Close removes the metrics registry of the processor.
+func (p *processor) Close() error {
+	processors.RemoveMetricsRegistry("grok", p.metrics)
+	return nil
+}
+
+// Run tries the patterns in order on the configured field, the values captured by the first
+// matching pattern are added to the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.Field)
+	if err != nil {
+		p.skipped.Inc()
+		return event, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		p.skipped.Inc()
+		return event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.config.Field)
+	}
+
+	m, err := p.match(s)
+	if err != nil {
+		p.failed.Inc()
+		return p.onFailure(event, err)
+	}
+
+	prefix := ""
+	if p.config.TargetPrefix != "" {
+		prefix = p.config.TargetPrefix + "."
+	}
+	for k, v := range m {
+		if _, err := event.PutValue(prefix+k, v); err != nil {
+			p.failed.Inc()
+			return p.onFailure(event, errors.Wrapf(err, "cannot set key `%s`", prefix+k))
+		}
+	}
+
+	p.matched.Inc()
+	return event, nil
+}
+
+// match returns the values of the first matching pattern. The Go regexp engine guarantees a
+// matching time linear to the size of the input, the timeout bounds the total time spent trying
+// the configured patterns.
+func (p *processor) match(s string) (map[string]interface{}, error) {
+	var deadline time.Time
+	if p.config.Timeout > 0 {
+		deadline = time.Now().Add(p.config.Timeout)
+	}
+
+	for _, g := range p.patterns {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			p.timeouts.Inc()
+			return nil, errTimeout
+		}
+
+		m, ok, err := g.Match(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return m, nil
+		}
+	}
+	return nil, errors.New("provided patterns do not match")
+}
+
+func (p *processor) onFailure(event *beat.Event, err error) (*beat.Event, error) {
+	return processors.TagOnFailure(event, p.config.TagOnFailure, "grok", err)
+}
+
+func (p *processor) String() string {
+	raw := make([]string, len(p.patterns))
+	for i, g := range p.patterns {
+		raw[i] = g.Raw()
+	}
+	return "grok=[" + strings.Join(raw, ", ") + "]" +
+		",field=" + p.config.Field +
+		",target_prefix=" + p.config.TargetPrefix
+}
@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestProcessor(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      map[string]interface{}
+		fields common.MapStr
+		values map[string]interface{}
+	}{
+		{
+			name:   "default field/target root",
+			c:      map[string]interface{}{"patterns": []string{"%{WORD:verb} %{INT:count:int}"}},
+			fields: common.MapStr{"message": "hello 42"},
+			values: map[string]interface{}{"verb": "hello", "count": int64(42)},
+		},
+		{
+			name: "specific field/specific target",
+			c: map[string]interface{}{
+				"patterns":      []string{"%{WORD:verb}"},
+				"field":         "original",
+				"target_prefix": "grok",
+			},
+			fields: common.MapStr{"original": "hello"},
+			values: map[string]interface{}{"grok.verb": "hello"},
+		},
+		{
+			name: "first matching pattern wins",
+			c: map[string]interface{}{
+				"patterns": []string{"%{INT:count:int}", "%{WORD:verb}"},
+			},
+			fields: common.MapStr{"message": "hello"},
+			values: map[string]interface{}{"verb": "hello"},
+		},
+		{
+			name: "inline definitions",
+			c: map[string]interface{}{
+				"patterns":            []string{"%{GREETING:greeting}"},
+				"pattern_definitions": map[string]string{"GREETING": "hello|hi"},
+			},
+			fields: common.MapStr{"message": "hi"},
+			values: map[string]interface{}{"greeting": "hi"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			processor, err := newProcessor(c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			e := beat.Event{Fields: test.fields}
+			newEvent, err := processor.Run(&e)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			for field, value := range test.values {
+				v, err := newEvent.GetValue(field)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				assert.Equal(t, value, v)
+			}
+		})
+	}
+}
+
+func TestPatternFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grok")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "patterns")
+	err = ioutil.WriteFile(file, []byte("GREETING hello|hi\n"), 0644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c, err := common.NewConfigFrom(map[string]interface{}{
+		"patterns":      []string{"%{GREETING:greeting} %{WORD:name}"},
+		"pattern_files": []string{file},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	processor, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	e := beat.Event{Fields: common.MapStr{"message": "hello world"}}
+	newEvent, err := processor.Run(&e)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, common.MapStr{"message": "hello world", "greeting": "hello", "name": "world"}, newEvent.Fields)
+}
+
+func TestTagOnFailure(t *testing.T) {
+	c, err := common.NewConfigFrom(map[string]interface{}{"patterns": []string{"%{INT:count}"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proc, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	e := beat.Event{Fields: common.MapStr{"message": "hello"}}
+	newEvent, err := proc.Run(&e)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, common.MapStr{
+		"message": "hello",
+		"tags":    []string{"_grokparsefailure"},
+		"error":   common.MapStr{"message": "grok: provided patterns do not match"},
+	}, newEvent.Fields)
+	assert.Equal(t, int64(1), proc.(*processor).failed.Get())
+}
+
+func TestTimeout(t *testing.T) {
+	c, err := common.NewConfigFrom(map[string]interface{}{
+		"patterns":       []string{"%{INT:count}", "%{WORD:verb}"},
+		"timeout":        "1ns",
+		"tag_on_failure": []string{},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proc, err := newProcessor(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	e := beat.Event{Fields: common.MapStr{"message": "hello"}}
+	_, err = proc.Run(&e)
+	assert.Equal(t, errTimeout, err)
+	assert.Equal(t, int64(1), proc.(*processor).timeouts.Get())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		c    map[string]interface{}
+	}{
+		{name: "missing patterns", c: map[string]interface{}{"field": "message"}},
+		{name: "empty pattern", c: map[string]interface{}{"patterns": []string{""}}},
+		{name: "unknown reference", c: map[string]interface{}{"patterns": []string{"%{NOPE}"}}},
+		{name: "missing pattern file", c: map[string]interface{}{
+			"patterns":      []string{"%{WORD}"},
+			"pattern_files": []string{"/does/not/exist"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = newProcessor(c)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"strconv"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// instrumented wraps a processor, recording the number of events processed
+// and the execution time of the processor.
+type instrumented struct {
+	processor Processor
+
+	in, out, dropped, errors *monitoring.Uint
+	time                     metrics.Histogram
+}
+
+// Instrument returns a copy of procs, with every processor reporting metrics
+// into a sub-registry of reg. The sub-registries are named by the processors
+// index in the list and report:
+//
+//   - name: the configured processor name
+//   - events.in: number of events passed to the processor
+//   - events.out: number of events returned by the processor
+//   - events.dropped: number of events dropped by the processor
+//   - errors: number of events the processor failed on
+//   - time.ns: histogram of the processors execution time in nanoseconds
+func (procs *Processors) Instrument(reg *monitoring.Registry) *Processors {
+	if procs == nil {
+		return nil
+	}
+
+	tmp := &Processors{}
+	for i, p := range procs.List {
+		name := p.String()
+		if i < len(procs.names) {
+			name = procs.names[i]
+		}
+		tmp.add(name, newInstrumented(reg.NewRegistry(strconv.Itoa(i)), name, p))
+	}
+	return tmp
+}
+
+func newInstrumented(reg *monitoring.Registry, name string, p Processor) *instrumented {
+	monitoring.NewString(reg, "name").Set(name)
+
+	hist := metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015))
+	monitoring.NewFunc(reg, "time.ns", func(_ monitoring.Mode, V monitoring.Visitor) {
+		reportHistogram(V, hist.Snapshot())
+	}, monitoring.Report)
+
+	return &instrumented{
+		processor: p,
+		in:        monitoring.NewUint(reg, "events.in"),
+		out:       monitoring.NewUint(reg, "events.out"),
+		dropped:   monitoring.NewUint(reg, "events.dropped"),
+		errors:    monitoring.NewUint(reg, "errors"),
+		time:      hist,
+	}
+}
+
+func (p *instrumented) Run(event *beat.Event) (*beat.Event, error) {
+	p.in.Inc()
+
+	start := time.Now()
+	event, err := p.processor.Run(event)
+	p.time.Update(int64(time.Since(start)))
+
+	if err != nil {
+		p.errors.Inc()
+	}
+	if event == nil {
+		p.dropped.Inc()
+	} else {
+		p.out.Inc()
+	}
+	return event, err
+}
+
+func (p *instrumented) Close() error {
+	return Close(p.processor)
+}
+
+func (p *instrumented) String() string {
+	return p.processor.String()
+}
+
+func reportHistogram(V monitoring.Visitor, h metrics.Histogram) {
+	V.OnRegistryStart()
+	defer V.OnRegistryFinished()
+
+	monitoring.ReportInt(V, "count", h.Count())
+	monitoring.ReportInt(V, "min", h.Min())
+	monitoring.ReportInt(V, "max", h.Max())
+	monitoring.ReportFloat(V, "mean", h.Mean())
+	monitoring.ReportFloat(V, "stddev", h.StdDev())
+
+	percentiles := []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	names := []string{"median", "p75", "p95", "p99", "p999"}
+	for i, value := range h.Percentiles(percentiles) {
+		monitoring.ReportFloat(V, names[i], value)
+	}
+}
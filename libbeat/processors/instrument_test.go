@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func TestInstrument(t *testing.T) {
+	yml := []map[string]interface{}{
+		{
+			"drop_fields": map[string]interface{}{
+				"fields": []string{"proc.cpu"},
+			},
+		},
+		{
+			"drop_event": map[string]interface{}{
+				"when": map[string]interface{}{
+					"equals": map[string]interface{}{"type": "debug"},
+				},
+			},
+		},
+	}
+
+	reg := monitoring.NewRegistry()
+	procs := GetProcessors(t, yml).Instrument(reg)
+
+	for _, typ := range []string{"info", "debug", "info"} {
+		procs.Run(&beat.Event{
+			Timestamp: time.Now(),
+			Fields: common.MapStr{
+				"type": typ,
+				"proc": common.MapStr{"cpu": 1},
+			},
+		})
+	}
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, "drop_fields", snapshot.Strings["0.name"])
+	assert.Equal(t, int64(3), snapshot.Ints["0.events.in"])
+	assert.Equal(t, int64(3), snapshot.Ints["0.events.out"])
+	assert.Equal(t, int64(3), snapshot.Ints["0.time.ns.count"])
+
+	assert.Equal(t, "drop_event", snapshot.Strings["1.name"])
+	assert.Equal(t, int64(3), snapshot.Ints["1.events.in"])
+	assert.Equal(t, int64(2), snapshot.Ints["1.events.out"])
+	assert.Equal(t, int64(1), snapshot.Ints["1.events.dropped"])
+	assert.Equal(t, int64(0), snapshot.Ints["1.errors"])
+}
@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import "errors"
+
+type config struct {
+	Field         string   `config:"field"`
+	FieldSplit    string   `config:"field_split"`
+	ValueSplit    string   `config:"value_split"`
+	TargetPrefix  string   `config:"target_prefix"`
+	IncludeKeys   []string `config:"include_keys"`
+	ExcludeKeys   []string `config:"exclude_keys"`
+	TrimValue     string   `config:"trim_value"`
+	TrimKey       string   `config:"trim_key"`
+	IgnoreMissing bool     `config:"ignore_missing"`
+}
+
+var defaultConfig = config{
+	Field:      "message",
+	FieldSplit: " ",
+	ValueSplit: "=",
+}
+
+// Validate checks that the delimiters can be used to split the string.
+func (c *config) Validate() error {
+	if c.FieldSplit == "" {
+		return errors.New("field_split cannot be empty")
+	}
+	if c.ValueSplit == "" {
+		return errors.New("value_split cannot be empty")
+	}
+	if c.FieldSplit == c.ValueSplit {
+		return errors.New("field_split and value_split must be different")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import "strings"
+
+// pair is a key and its value extracted from a string.
+type pair struct {
+	key   string
+	value string
+}
+
+// parser splits strings like `a=1 b="two words" c=3` into key/value pairs. Values can be quoted
+// with double or single quotes to include the field delimiter, a backslash escapes a quote
+// inside a quoted value.
+type parser struct {
+	fieldSplit string
+	valueSplit string
+}
+
+func (p *parser) parse(s string) []pair {
+	var pairs []pair
+
+	offset := 0
+	for offset < len(s) {
+		// skip consecutive field delimiters.
+		if strings.HasPrefix(s[offset:], p.fieldSplit) {
+			offset += len(p.fieldSplit)
+			continue
+		}
+
+		end := p.indexOrEnd(s, offset, p.fieldSplit)
+		sep := strings.Index(s[offset:end], p.valueSplit)
+		if sep == -1 {
+			// token without a value, ignore it.
+			offset = end
+			continue
+		}
+
+		key := s[offset : offset+sep]
+		offset += sep + len(p.valueSplit)
+
+		var value string
+		value, offset = p.readValue(s, offset)
+		if key != "" {
+			pairs = append(pairs, pair{key: key, value: value})
+		}
+	}
+	return pairs
+}
+
+// readValue returns the value starting at offset and the position after the value.
+func (p *parser) readValue(s string, offset int) (string, int) {
+	if offset < len(s) && (s[offset] == '"' || s[offset] == '\'') {
+		quote := s[offset]
+		var b strings.Builder
+		for i := offset + 1; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i++
+				continue
+			}
+			if c == quote {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+		}
+		// unterminated quote, fall back to the unquoted value.
+	}
+
+	end := p.indexOrEnd(s, offset, p.fieldSplit)
+	return s[offset:end], end
+}
+
+func (p *parser) indexOrEnd(s string, offset int, needle string) int {
+	i := strings.Index(s[offset:], needle)
+	if i == -1 {
+		return len(s)
+	}
+	return offset + i
+}
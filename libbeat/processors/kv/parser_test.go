@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name       string
+		fieldSplit string
+		valueSplit string
+		s          string
+		expected   []pair
+	}{
+		{
+			name:     "simple",
+			s:        "a=1 b=2 c=3",
+			expected: []pair{{"a", "1"}, {"b", "2"}, {"c", "3"}},
+		},
+		{
+			name:     "double quotes",
+			s:        `a=1 b="two words" c=3`,
+			expected: []pair{{"a", "1"}, {"b", "two words"}, {"c", "3"}},
+		},
+		{
+			name:     "single quotes",
+			s:        `a='two words'`,
+			expected: []pair{{"a", "two words"}},
+		},
+		{
+			name:     "escaped quote",
+			s:        `a="say \"hi\"" b=2`,
+			expected: []pair{{"a", `say "hi"`}, {"b", "2"}},
+		},
+		{
+			name:     "unterminated quote",
+			s:        `a="two words`,
+			expected: []pair{{"a", `"two`}},
+		},
+		{
+			name:     "empty value and token without value",
+			s:        "a= lonely  b=2",
+			expected: []pair{{"a", ""}, {"b", "2"}},
+		},
+		{
+			name:     "value containing the value delimiter",
+			s:        "url=http://localhost/?q=1",
+			expected: []pair{{"url", "http://localhost/?q=1"}},
+		},
+		{
+			name:       "multi bytes delimiters",
+			fieldSplit: ", ",
+			valueSplit: ": ",
+			s:          "a: 1, b: two words",
+			expected:   []pair{{"a", "1"}, {"b", "two words"}},
+		},
+		{
+			name:     "no pairs",
+			s:        "hello world",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := parser{fieldSplit: " ", valueSplit: "="}
+			if test.fieldSplit != "" {
+				p.fieldSplit = test.fieldSplit
+			}
+			if test.valueSplit != "" {
+				p.valueSplit = test.valueSplit
+			}
+			assert.Equal(t, test.expected, p.parse(test.s))
+		})
+	}
+}
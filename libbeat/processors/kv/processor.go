@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type processor struct {
+	config  config
+	parser  parser
+	include map[string]struct{}
+	exclude map[string]struct{}
+}
+
+func init() {
+	processors.RegisterPlugin("kv", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	config := defaultConfig
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &processor{
+		config:  config,
+		parser:  parser{fieldSplit: config.FieldSplit, valueSplit: config.ValueSplit},
+		include: toSet(config.IncludeKeys),
+		exclude: toSet(config.ExcludeKeys),
+	}
+	return p, nil
+}
+
+// Run splits the configured field into key/value pairs and adds them to the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.Field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Cause(err) == common.ErrKeyNotFound {
+			return event, nil
+		}
+		return event, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.config.Field)
+	}
+
+	prefix := ""
+	if p.config.TargetPrefix != "" {
+		prefix = p.config.TargetPrefix + "."
+	}
+
+	for _, kv := range p.parser.parse(s) {
+		key := kv.key
+		if p.config.TrimKey != "" {
+			key = strings.Trim(key, p.config.TrimKey)
+		}
+		if key == "" || !p.accept(key) {
+			continue
+		}
+
+		value := kv.value
+		if p.config.TrimValue != "" {
+			value = strings.Trim(value, p.config.TrimValue)
+		}
+
+		if _, err := event.PutValue(prefix+key, value); err != nil {
+			return event, errors.Wrapf(err, "cannot set key `%s`", prefix+key)
+		}
+	}
+
+	return event, nil
+}
+
+func (p *processor) accept(key string) bool {
+	if len(p.include) > 0 {
+		if _, found := p.include[key]; !found {
+			return false
+		}
+	}
+	_, excluded := p.exclude[key]
+	return !excluded
+}
+
+func (p *processor) String() string {
+	return "kv=[field=" + p.config.Field +
+		",field_split=" + p.config.FieldSplit +
+		",value_split=" + p.config.ValueSplit +
+		",target_prefix=" + p.config.TargetPrefix + "]"
+}
+
+func toSet(keys []string) map[string]struct{} {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestProcessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        map[string]interface{}
+		fields   common.MapStr
+		expected common.MapStr
+	}{
+		{
+			name:     "default field/target root",
+			c:        map[string]interface{}{},
+			fields:   common.MapStr{"message": `a=1 b="two words"`},
+			expected: common.MapStr{"message": `a=1 b="two words"`, "a": "1", "b": "two words"},
+		},
+		{
+			name:     "specific field/specific target",
+			c:        map[string]interface{}{"field": "query", "target_prefix": "params", "field_split": "&"},
+			fields:   common.MapStr{"query": "a=1&b=2"},
+			expected: common.MapStr{"query": "a=1&b=2", "params": common.MapStr{"a": "1", "b": "2"}},
+		},
+		{
+			name:     "include keys",
+			c:        map[string]interface{}{"include_keys": []string{"a", "c"}, "target_prefix": "kv"},
+			fields:   common.MapStr{"message": "a=1 b=2 c=3"},
+			expected: common.MapStr{"message": "a=1 b=2 c=3", "kv": common.MapStr{"a": "1", "c": "3"}},
+		},
+		{
+			name:     "exclude keys",
+			c:        map[string]interface{}{"exclude_keys": []string{"b"}, "target_prefix": "kv"},
+			fields:   common.MapStr{"message": "a=1 b=2 c=3"},
+			expected: common.MapStr{"message": "a=1 b=2 c=3", "kv": common.MapStr{"a": "1", "c": "3"}},
+		},
+		{
+			name:     "trim key and value",
+			c:        map[string]interface{}{"trim_key": "[]", "trim_value": "<>", "target_prefix": "kv"},
+			fields:   common.MapStr{"message": "[a]=<1>"},
+			expected: common.MapStr{"message": "[a]=<1>", "kv": common.MapStr{"a": "1"}},
+		},
+		{
+			name:     "ignore missing",
+			c:        map[string]interface{}{"ignore_missing": true},
+			fields:   common.MapStr{"other": "a=1"},
+			expected: common.MapStr{"other": "a=1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			processor, err := newProcessor(c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			e := beat.Event{Fields: test.fields}
+			newEvent, err := processor.Run(&e)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, test.expected, newEvent.Fields)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("missing field", func(t *testing.T) {
+		processor, err := newProcessor(common.NewConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = processor.Run(&beat.Event{Fields: common.MapStr{"other": "a=1"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("not a string", func(t *testing.T) {
+		processor, err := newProcessor(common.NewConfig())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = processor.Run(&beat.Event{Fields: common.MapStr{"message": 1}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid delimiters", func(t *testing.T) {
+		c, err := common.NewConfigFrom(map[string]interface{}{"field_split": "=", "value_split": "="})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = newProcessor(c)
+		assert.Error(t, err)
+	})
+}
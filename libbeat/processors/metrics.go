@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	metricsMu  sync.Mutex
+	metricsIDs = map[string]map[int]*monitoring.Registry{}
+)
+
+// NewMetricsRegistry returns a new registry for the metrics of one processor
+// instance. The registry is named `libbeat.processor.<name>.<id>`, the id
+// being the lowest one not in use by another processor of the same name.
+// The registry must be released with RemoveMetricsRegistry once the processor
+// is closed.
+func NewMetricsRegistry(name string) *monitoring.Registry {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	ids := metricsIDs[name]
+	if ids == nil {
+		ids = map[int]*monitoring.Registry{}
+		metricsIDs[name] = ids
+	}
+
+	id := 0
+	for ids[id] != nil {
+		id++
+	}
+
+	reg := metricsParent(name).NewRegistry(strconv.Itoa(id))
+	ids[id] = reg
+	return reg
+}
+
+// RemoveMetricsRegistry unregisters a registry created by NewMetricsRegistry,
+// making its id available to new processors of the same name. Removing a
+// registry more than once has no effect.
+func RemoveMetricsRegistry(name string, reg *monitoring.Registry) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	ids := metricsIDs[name]
+	for id, r := range ids {
+		if r == reg {
+			metricsParent(name).Remove(strconv.Itoa(id))
+			delete(ids, id)
+			return
+		}
+	}
+}
+
+func metricsParent(name string) *monitoring.Registry {
+	reg := monitoring.Default
+	for _, part := range []string{"libbeat", "processor", name} {
+		sub := reg.GetRegistry(part)
+		if sub == nil {
+			sub = reg.NewRegistry(part)
+		}
+		reg = sub
+	}
+	return reg
+}
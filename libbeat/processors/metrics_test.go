@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func TestNewMetricsRegistry(t *testing.T) {
+	first := NewMetricsRegistry("metrics_test")
+	second := NewMetricsRegistry("metrics_test")
+	monitoring.NewInt(first, "count").Inc()
+
+	assert.NotEqual(t, first, second)
+	assert.NotNil(t, monitoring.Default.Get("libbeat.processor.metrics_test.0.count"))
+	assert.Nil(t, monitoring.Default.Get("libbeat.processor.metrics_test.1.count"))
+}
+
+func TestRemoveMetricsRegistry(t *testing.T) {
+	first := NewMetricsRegistry("metrics_remove_test")
+	second := NewMetricsRegistry("metrics_remove_test")
+	monitoring.NewInt(second, "count").Inc()
+
+	RemoveMetricsRegistry("metrics_remove_test", first)
+	RemoveMetricsRegistry("metrics_remove_test", first)
+	assert.Nil(t, monitoring.Default.GetRegistry("libbeat.processor.metrics_remove_test.0"))
+	assert.NotNil(t, monitoring.Default.Get("libbeat.processor.metrics_remove_test.1.count"))
+
+	// the id of the removed registry is reused
+	third := NewMetricsRegistry("metrics_remove_test")
+	monitoring.NewInt(third, "count").Inc()
+	assert.NotNil(t, monitoring.Default.Get("libbeat.processor.metrics_remove_test.0.count"))
+}
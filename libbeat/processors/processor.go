@@ -28,6 +28,9 @@ import (
 
 type Processors struct {
 	List []Processor
+
+	// names holds the configured processor name for each entry in List
+	names []string
 }
 
 type Processor interface {
@@ -35,12 +38,28 @@ type Processor interface {
 	String() string
 }
 
+// Closer is implemented by processors holding resources, like monitoring
+// registries or watchers, that must be released when the processor is no
+// longer used.
+type Closer interface {
+	Close() error
+}
+
+// Close closes p if it implements Closer.
+func Close(p Processor) error {
+	if c, ok := p.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 func New(config PluginConfig) (*Processors, error) {
 	procs := Processors{}
 
 	for _, processor := range config {
 
 		if len(processor) != 1 {
+			procs.Close()
 			return nil, fmt.Errorf("each processor needs to have exactly one action, but found %d actions",
 				len(processor))
 		}
@@ -49,6 +68,7 @@ func New(config PluginConfig) (*Processors, error) {
 
 			gen, exists := registry.reg[processorName]
 			if !exists {
+				procs.Close()
 				return nil, fmt.Errorf("the processor %s doesn't exist", processorName)
 			}
 
@@ -56,10 +76,11 @@ func New(config PluginConfig) (*Processors, error) {
 			constructor := gen.Plugin()
 			plugin, err := constructor(cfg)
 			if err != nil {
+				procs.Close()
 				return nil, err
 			}
 
-			procs.add(plugin)
+			procs.add(processorName, plugin)
 		}
 	}
 
@@ -67,8 +88,27 @@ func New(config PluginConfig) (*Processors, error) {
 	return &procs, nil
 }
 
-func (procs *Processors) add(p Processor) {
+// Validate checks that each processor in the configuration has exactly one
+// action and that the action is a registered processor, without creating the
+// processors.
+func Validate(config PluginConfig) error {
+	for _, processor := range config {
+		if len(processor) != 1 {
+			return fmt.Errorf("each processor needs to have exactly one action, but found %d actions",
+				len(processor))
+		}
+		for processorName := range processor {
+			if _, exists := registry.reg[processorName]; !exists {
+				return fmt.Errorf("the processor %s doesn't exist", processorName)
+			}
+		}
+	}
+	return nil
+}
+
+func (procs *Processors) add(name string, p Processor) {
 	procs.List = append(procs.List, p)
+	procs.names = append(procs.names, name)
 }
 
 // RunBC (run backwards-compatible) applies the processors, by providing the
@@ -85,6 +125,22 @@ func (procs *Processors) RunBC(event common.MapStr) common.MapStr {
 	return ret.Fields
 }
 
+// Close closes all processors in the list. The first error is returned, but
+// all processors are closed.
+func (procs *Processors) Close() error {
+	if procs == nil {
+		return nil
+	}
+
+	var firstErr error
+	for _, p := range procs.List {
+		if err := Close(p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func (procs *Processors) All() []beat.Processor {
 	if procs == nil || len(procs.List) == 0 {
 		return nil
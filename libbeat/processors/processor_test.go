@@ -618,3 +618,28 @@ func TestDropMissingFields(t *testing.T) {
 
 	assert.Equal(t, expectedEvent, processedEvent.Fields)
 }
+
+func TestValidate(t *testing.T) {
+	config := func(action map[string]interface{}) processors.PluginConfig {
+		c := map[string]*common.Config{}
+		for name, actionYml := range action {
+			actionConfig, err := common.NewConfigFrom(actionYml)
+			assert.Nil(t, err)
+			c[name] = actionConfig
+		}
+		return processors.PluginConfig{c}
+	}
+
+	// settings of the processor are not checked
+	assert.NoError(t, processors.Validate(config(map[string]interface{}{
+		"drop_fields": map[string]interface{}{"when": "invalid"},
+	})))
+
+	assert.Error(t, processors.Validate(config(map[string]interface{}{
+		"not_a_processor": map[string]interface{}{},
+	})))
+	assert.Error(t, processors.Validate(config(map[string]interface{}{
+		"drop_event":  map[string]interface{}{},
+		"drop_fields": map[string]interface{}{"fields": []string{"a"}},
+	})))
+}
@@ -0,0 +1,103 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket, it is refilled at a constant rate up to its capacity and each
+// allowed event consumes one token.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// buckets holds one bucket per key, buckets that are full again are removed periodically since
+// they are equivalent to new buckets.
+type buckets struct {
+	rate     float64
+	capacity float64
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newBuckets(rate float64, burst int, now time.Time) *buckets {
+	capacity := float64(burst)
+	if capacity < 1 {
+		capacity = math.Max(1, math.Ceil(rate))
+	}
+
+	return &buckets{
+		rate:      rate,
+		capacity:  capacity,
+		buckets:   map[string]*bucket{},
+		lastSweep: now,
+	}
+}
+
+// allow returns true if the event identified by key can be published.
+func (b *buckets) allow(key string, now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sweep(now)
+
+	bk, found := b.buckets[key]
+	if !found {
+		bk = &bucket{tokens: b.capacity, last: now}
+		b.buckets[key] = bk
+	}
+
+	if elapsed := now.Sub(bk.last).Seconds(); elapsed > 0 {
+		bk.tokens = math.Min(b.capacity, bk.tokens+elapsed*b.rate)
+	}
+	bk.last = now
+
+	if bk.tokens < 1 {
+		return false
+	}
+	bk.tokens--
+	return true
+}
+
+// sweep removes the buckets that were refilled, it runs at most once per time needed to refill an
+// empty bucket.
+func (b *buckets) sweep(now time.Time) {
+	refill := time.Duration(b.capacity / b.rate * float64(time.Second))
+	if now.Sub(b.lastSweep) < refill {
+		return
+	}
+	b.lastSweep = now
+
+	for key, bk := range b.buckets {
+		if now.Sub(bk.last) >= refill {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+func (b *buckets) len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.buckets)
+}
@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type config struct {
+	Limit  rate     `config:"limit" validate:"required"`
+	Burst  int      `config:"burst" validate:"min=0"`
+	Fields []string `config:"fields"`
+	Action string   `config:"action"`
+	Tags   []string `config:"tags"`
+}
+
+var defaultConfig = config{
+	Action: actionDrop,
+	Tags:   []string{"_rate_limited"},
+}
+
+const (
+	actionDrop = "drop"
+	actionTag  = "tag"
+)
+
+// Validate checks that the configured action is supported.
+func (c *config) Validate() error {
+	switch c.Action {
+	case actionDrop:
+	case actionTag:
+		if len(c.Tags) == 0 {
+			return fmt.Errorf("tags are required with action `%s`", actionTag)
+		}
+	default:
+		return fmt.Errorf("unsupported action `%s`, supported actions are `%s` and `%s`", c.Action, actionDrop, actionTag)
+	}
+	return nil
+}
+
+// rate is the number of events allowed per second, it is configured with the `<number>/<unit>`
+// syntax where unit is one of `s`, `m` or `h`. For example `100/s` or `6000/m`.
+type rate float64
+
+// Unpack parses a rate definition.
+func (r *rate) Unpack(v string) error {
+	parts := strings.Split(v, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid rate `%s`, expected format is `<number>/<unit>`", v)
+	}
+
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid rate `%s`, the number of events must be positive", v)
+	}
+
+	var unit time.Duration
+	switch parts[1] {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	default:
+		return fmt.Errorf("invalid rate `%s`, unit must be one of `s`, `m` or `h`", v)
+	}
+
+	*r = rate(n / unit.Seconds())
+	return nil
+}
@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type processor struct {
+	config  config
+	buckets *buckets
+	now     func() time.Time
+
+	metrics         *monitoring.Registry
+	dropped, tagged *monitoring.Int
+}
+
+func init() {
+	processors.RegisterPlugin("rate_limit", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The rate_limit processor is beta.")
+
+	config := defaultConfig
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	reg := processors.NewMetricsRegistry("rate_limit")
+	p := &processor{
+		config:  config,
+		buckets: newBuckets(float64(config.Limit), config.Burst, time.Now()),
+		now:     time.Now,
+		metrics: reg,
+		dropped: monitoring.NewInt(reg, "dropped"),
+		tagged:  monitoring.NewInt(reg, "tagged"),
+	}
+	return p, nil
+}
+
+// Close removes the metrics registry of the processor.
+func (p *processor) Close() error {
+	processors.RemoveMetricsRegistry("rate_limit", p.metrics)
+	return nil
+}
+
+// Run drops or tags the event when the rate of the events sharing the same values for the
+// configured fields exceeds the limit.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	if p.buckets.allow(p.key(event), p.now()) {
+		return event, nil
+	}
+
+	if p.config.Action == actionDrop {
+		p.dropped.Inc()
+		return nil, nil
+	}
+
+	p.tagged.Inc()
+	if err := common.AddTags(event.Fields, p.config.Tags); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// key builds the bucket key from the values of the configured fields, missing fields are
+// considered empty.
+func (p *processor) key(event *beat.Event) string {
+	if len(p.config.Fields) == 0 {
+		return ""
+	}
+
+	values := make([]string, len(p.config.Fields))
+	for i, field := range p.config.Fields {
+		if v, err := event.GetValue(field); err == nil {
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("rate_limit=[limit=%v/s, burst=%v, fields=%v, action=%s]",
+		float64(p.config.Limit), p.buckets.capacity, p.config.Fields, p.config.Action)
+}
@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time           { return c.now }
+func (c *fakeClock) Advance(d time.Duration)  { c.now = c.now.Add(d) }
+func newFakeClock(start time.Time) *fakeClock { return &fakeClock{now: start} }
+
+func newTestProcessor(t *testing.T, c map[string]interface{}, clock *fakeClock) *processor {
+	cfg, err := common.NewConfigFrom(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := newProcessor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl := p.(*processor)
+	rl.now = clock.Now
+	rl.buckets = newBuckets(float64(rl.config.Limit), rl.config.Burst, clock.Now())
+	return rl
+}
+
+func TestRateLimitDrop(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	p := newTestProcessor(t, map[string]interface{}{"limit": "2/s"}, clock)
+
+	var published int
+	for i := 0; i < 5; i++ {
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "hello"}})
+		assert.NoError(t, err)
+		if event != nil {
+			published++
+		}
+	}
+	assert.Equal(t, 2, published)
+	assert.Equal(t, int64(3), p.dropped.Get())
+
+	// Half a second refills one token.
+	clock.Advance(500 * time.Millisecond)
+	event, _ := p.Run(&beat.Event{Fields: common.MapStr{"message": "hello"}})
+	assert.NotNil(t, event)
+	event, _ = p.Run(&beat.Event{Fields: common.MapStr{"message": "hello"}})
+	assert.Nil(t, event)
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	p := newTestProcessor(t, map[string]interface{}{"limit": "60/m", "burst": 3}, clock)
+
+	var published int
+	for i := 0; i < 10; i++ {
+		if event, _ := p.Run(&beat.Event{Fields: common.MapStr{}}); event != nil {
+			published++
+		}
+	}
+	assert.Equal(t, 3, published)
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	p := newTestProcessor(t, map[string]interface{}{
+		"limit":  "1/s",
+		"fields": []string{"host.name", "log.file.path"},
+	}, clock)
+
+	events := []common.MapStr{
+		{"host": common.MapStr{"name": "a"}, "log": common.MapStr{"file": common.MapStr{"path": "/var/log/1"}}},
+		{"host": common.MapStr{"name": "a"}, "log": common.MapStr{"file": common.MapStr{"path": "/var/log/2"}}},
+		{"host": common.MapStr{"name": "b"}, "log": common.MapStr{"file": common.MapStr{"path": "/var/log/1"}}},
+		{"host": common.MapStr{"name": "a"}, "log": common.MapStr{"file": common.MapStr{"path": "/var/log/1"}}},
+	}
+
+	var published int
+	for _, fields := range events {
+		if event, _ := p.Run(&beat.Event{Fields: fields}); event != nil {
+			published++
+		}
+	}
+	assert.Equal(t, 3, published)
+	assert.Equal(t, 3, p.buckets.len())
+
+	// Refilled buckets are removed.
+	clock.Advance(2 * time.Second)
+	p.Run(&beat.Event{Fields: events[0]})
+	assert.Equal(t, 1, p.buckets.len())
+}
+
+func TestRateLimitTag(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	p := newTestProcessor(t, map[string]interface{}{"limit": "1/h", "action": "tag"}, clock)
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{}})
+	assert.NoError(t, err)
+	assert.Equal(t, common.MapStr{}, event.Fields)
+
+	event, err = p.Run(&beat.Event{Fields: common.MapStr{}})
+	assert.NoError(t, err)
+	assert.Equal(t, common.MapStr{"tags": []string{"_rate_limited"}}, event.Fields)
+	assert.Equal(t, int64(1), p.tagged.Get())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		c    map[string]interface{}
+	}{
+		{name: "missing limit", c: map[string]interface{}{}},
+		{name: "invalid format", c: map[string]interface{}{"limit": "100"}},
+		{name: "invalid unit", c: map[string]interface{}{"limit": "100/d"}},
+		{name: "negative rate", c: map[string]interface{}{"limit": "-1/s"}},
+		{name: "invalid action", c: map[string]interface{}{"limit": "1/s", "action": "delay"}},
+		{name: "tag without tags", c: map[string]interface{}{"limit": "1/s", "action": "tag", "tags": []string{}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = newProcessor(c)
+			assert.Error(t, err)
+		})
+	}
+}
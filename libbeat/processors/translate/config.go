@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package translate
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	Field           string        `config:"field" validate:"required"`
+	Target          string        `config:"target"`
+	Dictionary      []translation `config:"dictionary"`
+	DictionaryPath  string        `config:"dictionary_path"`
+	Regex           bool          `config:"regex"`
+	Fallback        *string       `config:"fallback"`
+	Override        bool          `config:"override"`
+	IgnoreMissing   bool          `config:"ignore_missing"`
+	RefreshInterval time.Duration `config:"refresh_interval" validate:"min=0"`
+}
+
+// translation is an entry of an inline dictionary, a list is used instead of a map to keep the
+// order of the regular expressions and to allow keys that are numbers or contain dots.
+type translation struct {
+	Key   string `config:"key"`
+	Value string `config:"value"`
+}
+
+var defaultConfig = config{
+	Target:          "translation",
+	RefreshInterval: 5 * time.Minute,
+}
+
+// Validate makes sure that exactly one dictionary source is defined.
+func (c *config) Validate() error {
+	if len(c.Dictionary) == 0 && c.DictionaryPath == "" {
+		return errors.New("one of dictionary or dictionary_path must be defined")
+	}
+	if len(c.Dictionary) > 0 && c.DictionaryPath != "" {
+		return errors.New("dictionary and dictionary_path are mutually exclusive")
+	}
+	if c.Target == "" {
+		return errors.New("target cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package translate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// entry is a translation, the regular expression is only set when the keys are patterns.
+type entry struct {
+	key   string
+	re    *regexp.Regexp
+	value interface{}
+}
+
+// dictionary maps a value to its translation, with exact keys or with regular expressions tried
+// in order.
+type dictionary struct {
+	exact   map[string]interface{}
+	entries []entry
+}
+
+func newDictionary(entries []entry, regex bool) (*dictionary, error) {
+	d := &dictionary{}
+	if !regex {
+		d.exact = make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			d.exact[e.key] = e.value
+		}
+		return d, nil
+	}
+
+	for _, e := range entries {
+		re, err := regexp.Compile(e.key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression `%s`: %v", e.key, err)
+		}
+		e.re = re
+		d.entries = append(d.entries, e)
+	}
+	return d, nil
+}
+
+func (d *dictionary) lookup(s string) (interface{}, bool) {
+	if d.exact != nil {
+		v, found := d.exact[s]
+		return v, found
+	}
+
+	for _, e := range d.entries {
+		if e.re.MatchString(s) {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// loadDictionaryFile reads the entries of a dictionary file, the format is selected using the
+// extension of the file: `.csv`, `.json`, `.yml` or `.yaml`.
+func loadDictionaryFile(path string) ([]entry, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dictionary: %v", err)
+	}
+
+	var entries []entry
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		entries, err = parseCSV(content)
+	case ".json":
+		entries, err = parseJSON(content)
+	case ".yml", ".yaml":
+		entries, err = parseYAML(content)
+	default:
+		return nil, fmt.Errorf("unsupported dictionary format `%s`, supported formats are csv, json and yaml", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid dictionary %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// parseCSV reads `key,value` records, the order of the file is preserved.
+func parseCSV(content []byte) ([]entry, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	var entries []entry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: record[0], value: record[1]})
+	}
+}
+
+func parseJSON(content []byte) ([]entry, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return sortedEntries(m)
+}
+
+func parseYAML(content []byte) ([]entry, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		m[fmt.Sprint(k)] = v
+	}
+	return sortedEntries(m)
+}
+
+// sortedEntries orders the keys of a map so regular expressions are always tried in the same
+// order, only scalar values are allowed.
+func sortedEntries(m map[string]interface{}) ([]entry, error) {
+	entries := make([]entry, 0, len(m))
+	for k, v := range m {
+		switch v.(type) {
+		case string, bool, int, int64, uint64, float64:
+		default:
+			return nil, fmt.Errorf("value of key `%s` must be a string, a number or a boolean", k)
+		}
+		entries = append(entries, entry{key: k, value: v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries, nil
+}
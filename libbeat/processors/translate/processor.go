@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package translate
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/common/file"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/paths"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+type processor struct {
+	config config
+	log    *logp.Logger
+
+	// dictionary is used when the entries are set in the configuration, otherwise the dictionary
+	// file is checked lazily during the lookups, at most once per refresh interval.
+	dictionary *dictionary
+	file       *file.Reloader
+}
+
+func init() {
+	processors.RegisterPlugin("translate", newProcessor)
+}
+
+func newProcessor(c *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The translate processor is beta.")
+
+	config := defaultConfig
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &processor{config: config, log: logp.NewLogger("translate")}
+
+	if config.DictionaryPath == "" {
+		entries := make([]entry, len(config.Dictionary))
+		for i, t := range config.Dictionary {
+			entries[i] = entry{key: t.Key, value: t.Value}
+		}
+		p.dictionary, err = newDictionary(entries, config.Regex)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	path := paths.Resolve(paths.Config, config.DictionaryPath)
+	p.file, err = file.NewReloader(path, config.RefreshInterval, p.load)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open dictionary")
+	}
+	return p, nil
+}
+
+func (p *processor) load(path string) (interface{}, error) {
+	entries, err := loadDictionaryFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newDictionary(entries, p.config.Regex)
+}
+
+// currentDictionary returns the dictionary to use for the lookups. When the dictionary is read
+// from a file that has changed it is refreshed first, on failure the previous version is used.
+func (p *processor) currentDictionary() *dictionary {
+	if p.file == nil {
+		return p.dictionary
+	}
+
+	d, refreshed, err := p.file.Get()
+	if err != nil {
+		p.log.Warnf("Failed to refresh dictionary %s, keeping the previous version: %v", p.file.Path(), err)
+	} else if refreshed {
+		p.log.Infof("Dictionary %s refreshed", p.file.Path())
+	}
+	return d.(*dictionary)
+}
+
+// Run translates the value of the configured field and writes the translation to the target.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.Field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Cause(err) == common.ErrKeyNotFound {
+			return event, nil
+		}
+		return event, err
+	}
+
+	if !p.config.Override {
+		if exists, _ := event.Fields.HasKey(p.config.Target); exists {
+			return event, fmt.Errorf("target field %s already exists, set override to replace it", p.config.Target)
+		}
+	}
+
+	translation, found := p.currentDictionary().lookup(fmt.Sprint(v))
+	if !found {
+		if p.config.Fallback == nil {
+			return event, nil
+		}
+		translation = *p.config.Fallback
+	}
+
+	if _, err := event.PutValue(p.config.Target, translation); err != nil {
+		return event, errors.Wrapf(err, "cannot set key `%s`", p.config.Target)
+	}
+	return event, nil
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("translate=[field=%s, target=%s, dictionary_path=%s, regex=%v]",
+		p.config.Field, p.config.Target, p.config.DictionaryPath, p.config.Regex)
+}
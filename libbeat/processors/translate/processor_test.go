@@ -0,0 +1,251 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package translate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTranslate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "translate")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"codes.csv":  "# code,name\n1,eth0\n2,\"eth1, uplink\"\n",
+		"codes.json": `{"1": "eth0", "2": "eth1, uplink"}`,
+		"codes.yml":  "1: eth0\n2: 'eth1, uplink'\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644)) {
+			return
+		}
+
+		t.Run(name, func(t *testing.T) {
+			p := newTestProcessor(t, map[string]interface{}{
+				"field":           "interface.id",
+				"target":          "interface.name",
+				"dictionary_path": path,
+			})
+
+			for id, expected := range map[interface{}]string{"1": "eth0", 2: "eth1, uplink"} {
+				event, err := p.Run(&beat.Event{Fields: common.MapStr{"interface": common.MapStr{"id": id}}})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				v, err := event.GetValue("interface.name")
+				if assert.NoError(t, err) {
+					assert.Equal(t, expected, v)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateInline(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        map[string]interface{}
+		fields   common.MapStr
+		expected common.MapStr
+	}{
+		{
+			name:     "exact match",
+			c:        map[string]interface{}{"field": "code", "dictionary": []map[string]string{{"key": "404", "value": "not found"}}},
+			fields:   common.MapStr{"code": 404},
+			expected: common.MapStr{"code": 404, "translation": "not found"},
+		},
+		{
+			name:     "no match",
+			c:        map[string]interface{}{"field": "code", "dictionary": []map[string]string{{"key": "404", "value": "not found"}}},
+			fields:   common.MapStr{"code": 200},
+			expected: common.MapStr{"code": 200},
+		},
+		{
+			name: "fallback",
+			c: map[string]interface{}{
+				"field":      "code",
+				"dictionary": []map[string]string{{"key": "404", "value": "not found"}},
+				"fallback":   "unknown",
+			},
+			fields:   common.MapStr{"code": 200},
+			expected: common.MapStr{"code": 200, "translation": "unknown"},
+		},
+		{
+			name: "regex",
+			c: map[string]interface{}{
+				"field": "code",
+				"regex": true,
+				"dictionary": []map[string]string{
+					{"key": "^4[0-9]{2}$", "value": "client error"},
+					{"key": "^[45][0-9]{2}$", "value": "error"},
+					{"key": "^5[0-9]{2}$", "value": "server error"},
+				},
+			},
+			fields:   common.MapStr{"code": 503},
+			expected: common.MapStr{"code": 503, "translation": "error"},
+		},
+		{
+			name: "override",
+			c: map[string]interface{}{
+				"field":      "code",
+				"dictionary": []map[string]string{{"key": "404", "value": "not found"}},
+				"override":   true,
+			},
+			fields:   common.MapStr{"code": 404, "translation": "old"},
+			expected: common.MapStr{"code": 404, "translation": "not found"},
+		},
+		{
+			name: "ignore missing",
+			c: map[string]interface{}{
+				"field":          "code",
+				"dictionary":     []map[string]string{{"key": "404", "value": "not found"}},
+				"ignore_missing": true,
+			},
+			fields:   common.MapStr{},
+			expected: common.MapStr{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProcessor(t, test.c)
+			event, err := p.Run(&beat.Event{Fields: test.fields})
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expected, event.Fields)
+			}
+		})
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	p := newTestProcessor(t, map[string]interface{}{"field": "code", "dictionary": []map[string]string{{"key": "404", "value": "not found"}}})
+
+	_, err := p.Run(&beat.Event{Fields: common.MapStr{}})
+	assert.Error(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: common.MapStr{"code": 404, "translation": "exists"}})
+	assert.Error(t, err)
+}
+
+func TestTranslateRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "translate")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "codes.csv")
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte("1,eth0\n"), 0644)) {
+		return
+	}
+
+	p := newTestProcessor(t, map[string]interface{}{
+		"field":            "id",
+		"dictionary_path":  path,
+		"refresh_interval": "1ns",
+	})
+
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte("1,bond0\n"), 0644)) {
+		return
+	}
+	// Make sure the modification time is different on filesystems with a coarse resolution.
+	later := time.Now().Add(time.Minute)
+	if !assert.NoError(t, os.Chtimes(path, later, later)) {
+		return
+	}
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"id": "1"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"id": "1", "translation": "bond0"}, event.Fields)
+	}
+
+	// An invalid file keeps the previous dictionary.
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte("1,bond0,extra\n"), 0644)) {
+		return
+	}
+	later = later.Add(time.Minute)
+	if !assert.NoError(t, os.Chtimes(path, later, later)) {
+		return
+	}
+
+	event, err = p.Run(&beat.Event{Fields: common.MapStr{"id": "1"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.MapStr{"id": "1", "translation": "bond0"}, event.Fields)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		c    map[string]interface{}
+	}{
+		{name: "missing field", c: map[string]interface{}{"dictionary": []map[string]string{{"key": "a", "value": "b"}}}},
+		{name: "missing dictionary", c: map[string]interface{}{"field": "code"}},
+		{name: "both dictionaries", c: map[string]interface{}{
+			"field":           "code",
+			"dictionary":      []map[string]string{{"key": "a", "value": "b"}},
+			"dictionary_path": "codes.csv",
+		}},
+		{name: "invalid regex", c: map[string]interface{}{
+			"field":      "code",
+			"dictionary": []map[string]string{{"key": "(", "value": "b"}},
+			"regex":      true,
+		}},
+		{name: "unsupported format", c: map[string]interface{}{"field": "code", "dictionary_path": "/tmp/codes.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := common.NewConfigFrom(test.c)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = newProcessor(c)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func newTestProcessor(t *testing.T, c map[string]interface{}) *processor {
+	cfg, err := common.NewConfigFrom(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := newProcessor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*processor)
+}
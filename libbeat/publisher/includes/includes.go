@@ -20,17 +20,25 @@ package includes
 import (
 	// import queue types
 	_ "github.com/elastic/beats/libbeat/publisher/queue/memqueue"
+	_ "github.com/elastic/beats/libbeat/publisher/queue/priority"
 	_ "github.com/elastic/beats/libbeat/publisher/queue/spool"
 
 	// load supported output plugins
 	_ "github.com/elastic/beats/libbeat/outputs/console"
 	_ "github.com/elastic/beats/libbeat/outputs/elasticsearch"
+	_ "github.com/elastic/beats/libbeat/outputs/failover"
 	_ "github.com/elastic/beats/libbeat/outputs/fileout"
+	_ "github.com/elastic/beats/libbeat/outputs/httpout"
 	_ "github.com/elastic/beats/libbeat/outputs/kafka"
 	_ "github.com/elastic/beats/libbeat/outputs/logstash"
+	_ "github.com/elastic/beats/libbeat/outputs/nats"
+	_ "github.com/elastic/beats/libbeat/outputs/otlp"
 	_ "github.com/elastic/beats/libbeat/outputs/redis"
+	_ "github.com/elastic/beats/libbeat/outputs/s3out"
 
 	// load support output codec
+	_ "github.com/elastic/beats/libbeat/outputs/codec/avro"
 	_ "github.com/elastic/beats/libbeat/outputs/codec/format"
 	_ "github.com/elastic/beats/libbeat/outputs/codec/json"
+	_ "github.com/elastic/beats/libbeat/outputs/codec/protobuf"
 )
@@ -18,10 +18,12 @@
 package pipeline
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/tracing"
 )
 
 type Batch struct {
@@ -36,6 +38,11 @@ type batchContext struct {
 	retryer  *retryer
 }
 
+var (
+	errBatchDropped = errors.New("event dropped after too many retries")
+	errBatchRetry   = errors.New("event failed to be published, will retry")
+)
+
 var batchPool = sync.Pool{
 	New: func() interface{} {
 		return &Batch{}
@@ -68,11 +75,13 @@ func (b *Batch) Events() []publisher.Event {
 
 func (b *Batch) ACK() {
 	b.ctx.observer.outBatchACKed(len(b.events))
+	b.finishSpans(b.events, nil)
 	b.original.ACK()
 	releaseBatch(b)
 }
 
 func (b *Batch) Drop() {
+	b.finishSpans(b.events, errBatchDropped)
 	b.original.ACK()
 	releaseBatch(b)
 }
@@ -87,6 +96,11 @@ func (b *Batch) Cancelled() {
 
 func (b *Batch) RetryEvents(events []publisher.Event) {
 	b.updEvents(events)
+	if tracing.Enabled() {
+		for i := range b.events {
+			b.events[i].Content.Span.EndStage(errBatchRetry)
+		}
+	}
 	b.Retry()
 }
 
@@ -101,7 +115,48 @@ func (b *Batch) updEvents(events []publisher.Event) {
 	if l1 > l2 {
 		// report subset of events not to be retried as ACKed
 		b.ctx.observer.outBatchACKed(l1 - l2)
+		b.finishPendingSpans(events)
 	}
 
 	b.events = events
 }
+
+// stage starts a new tracing stage for all sampled events in the batch.
+func (b *Batch) stage(name string) {
+	if !tracing.Enabled() {
+		return
+	}
+	for i := range b.events {
+		b.events[i].Content.Span.Stage(name)
+	}
+}
+
+func (b *Batch) finishSpans(events []publisher.Event, err error) {
+	if !tracing.Enabled() {
+		return
+	}
+	for i := range events {
+		events[i].Content.Span.Finish(err)
+	}
+}
+
+// finishPendingSpans finishes the spans of all events in the batch, that are
+// not part of events anymore.
+func (b *Batch) finishPendingSpans(events []publisher.Event) {
+	if !tracing.Enabled() {
+		return
+	}
+
+	remaining := map[*tracing.Span]struct{}{}
+	for i := range events {
+		if span := events[i].Content.Span; span != nil {
+			remaining[span] = struct{}{}
+		}
+	}
+	for i := range b.events {
+		span := b.events[i].Content.Span
+		if _, exists := remaining[span]; span != nil && !exists {
+			span.Finish(nil)
+		}
+	}
+}
@@ -18,14 +18,18 @@
 package pipeline
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common/atomic"
 	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/tracing"
 )
 
+var errDroppedOnPublish = errors.New("event dropped on publish")
+
 // client connects a beat with the processors and pipeline queue.
 //
 // TODO: All ackers currently drop any late incoming ACK. Some beats still might
@@ -33,6 +37,7 @@ import (
 //       -> add support for not dropping pending ACKs
 type client struct {
 	pipeline   *Pipeline
+	name       string
 	processors beat.Processor
 	producer   queue.Producer
 	mutex      sync.Mutex
@@ -45,6 +50,9 @@ type client struct {
 	isOpen atomic.Bool
 
 	eventer beat.ClientEventer
+
+	// metrics is set if pipeline instrumentation is enabled
+	metrics *clientMetrics
 }
 
 func (c *client) PublishAll(events []beat.Event) {
@@ -78,7 +86,16 @@ func (c *client) publish(e beat.Event) {
 		return
 	}
 
+	if e.Span == nil && tracing.Enabled() {
+		e.Span = tracing.StartEvent("event")
+		if c.name != "" {
+			e.Span.SetAttribute("input", c.name)
+		}
+	}
+
 	if c.processors != nil {
+		e.Span.Stage("processors")
+
 		var err error
 
 		event, err = c.processors.Run(event)
@@ -97,16 +114,20 @@ func (c *client) publish(e beat.Event) {
 	open := c.acker.addEvent(e, publish)
 	if !open {
 		// client is closing down -> report event as dropped and return
+		e.Span.Finish(errDroppedOnPublish)
 		c.onDroppedOnPublish(e)
 		return
 	}
 
 	if !publish {
+		e.Span.AddEvent("filtered")
+		e.Span.Finish(nil)
 		c.onFilteredOut(e)
 		return
 	}
 
 	e = *event
+	e.Span.Stage("queue")
 	pubEvent := publisher.Event{
 		Content: e,
 		Flags:   c.eventFlags,
@@ -126,6 +147,7 @@ func (c *client) publish(e beat.Event) {
 	if published {
 		c.onPublished()
 	} else {
+		e.Span.Finish(errDroppedOnPublish)
 		c.onDroppedOnPublish(e)
 		if c.reportEvents {
 			c.pipeline.waitCloser.dec(1)
@@ -173,6 +195,9 @@ func (c *client) onClosing() {
 
 func (c *client) onClosed() {
 	c.pipeline.observer.clientClosed()
+	if c.metrics != nil {
+		c.pipeline.instrumentation.disconnect(c.metrics)
+	}
 	if c.eventer != nil {
 		c.eventer.Closed()
 	}
@@ -180,10 +205,16 @@ func (c *client) onClosed() {
 
 func (c *client) onNewEvent() {
 	c.pipeline.observer.newEvent()
+	if c.metrics != nil {
+		c.metrics.total.Inc()
+	}
 }
 
 func (c *client) onPublished() {
 	c.pipeline.observer.publishedEvent()
+	if c.metrics != nil {
+		c.metrics.published.Inc()
+	}
 	if c.eventer != nil {
 		c.eventer.Published()
 	}
@@ -191,6 +222,9 @@ func (c *client) onPublished() {
 
 func (c *client) onFilteredOut(e beat.Event) {
 	c.pipeline.observer.filteredEvent()
+	if c.metrics != nil {
+		c.metrics.filtered.Inc()
+	}
 	if c.eventer != nil {
 		c.eventer.FilteredOut(e)
 	}
@@ -198,6 +232,9 @@ func (c *client) onFilteredOut(e beat.Event) {
 
 func (c *client) onDroppedOnPublish(e beat.Event) {
 	c.pipeline.observer.failedPublishEvent()
+	if c.metrics != nil {
+		c.metrics.dropped.Inc()
+	}
 	if c.eventer != nil {
 		c.eventer.DroppedOnPublish(e)
 	}
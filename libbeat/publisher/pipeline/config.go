@@ -20,6 +20,7 @@ package pipeline
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
@@ -34,6 +35,29 @@ type Config struct {
 
 	// Event queue
 	Queue common.ConfigNamespace `config:"queue"`
+
+	// Instrumentation enables additional per client and per processor metrics.
+	Instrumentation InstrumentationConfig `config:"instrumentation"`
+
+	// Reload enables reloading the output and global processors from the
+	// configuration files while the beat is running.
+	Reload ReloadConfig `config:"config.reload"`
+
+	// Validation validates events against the fields schema after all
+	// processors have been applied.
+	Validation *common.Config `config:"validation"`
+}
+
+// InstrumentationConfig configures the collection of additional pipeline
+// metrics.
+type InstrumentationConfig struct {
+	Enabled bool `config:"enabled"`
+}
+
+// ReloadConfig configures reloading the output and global processors.
+type ReloadConfig struct {
+	Enabled bool          `config:"enabled"`
+	Period  time.Duration `config:"period" validate:"min=0"`
 }
 
 // validateClientConfig checks a ClientConfig can be used with (*Pipeline).ConnectWith.
@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+// instrumentation collects additional per client and per processor metrics.
+// Instrumentation is opt-in, as measuring the processors execution time adds
+// some overhead to every single event.
+//
+// Metrics are reported in the pipeline registry:
+//
+//   - processors.<i>: metrics of the i-th global processor
+//   - inputs.<name>.events: number of events published by the inputs clients
+//   - inputs.<name>.processors.<i>: metrics of the i-th processor configured
+//     with the client
+type instrumentation struct {
+	mutex  sync.Mutex
+	reg    *monitoring.Registry
+	inputs *monitoring.Registry
+}
+
+// clientMetrics reports the events published by a single client.
+type clientMetrics struct {
+	name string
+
+	total, filtered, published, dropped *monitoring.Uint
+}
+
+func newInstrumentation(metrics *monitoring.Registry) *instrumentation {
+	reg := metrics.GetRegistry("pipeline")
+	if reg == nil {
+		reg = metrics.NewRegistry("pipeline")
+	}
+
+	return &instrumentation{
+		reg:    reg,
+		inputs: reg.NewRegistry("inputs"),
+	}
+}
+
+func (i *instrumentation) instrumentGlobal(procs *processors.Processors) *processors.Processors {
+	// remove the metrics of the processors being replaced on reload
+	i.reg.Remove("processors")
+
+	if procs == nil || len(procs.List) == 0 {
+		return procs
+	}
+	return procs.Instrument(i.reg.NewRegistry("processors"))
+}
+
+// connect creates the metrics for a new client and instruments the clients
+// processors. Clients connecting with the same name get a unique registry
+// with an index appended to the name.
+func (i *instrumentation) connect(cfg *beat.ClientConfig) *clientMetrics {
+	if cfg.Name == "" {
+		return nil
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	name := cfg.Name
+	for n := 2; i.inputs.Get(name) != nil; n++ {
+		name = cfg.Name + "_" + strconv.Itoa(n)
+	}
+
+	reg := i.inputs.NewRegistry(name)
+	if procs, ok := cfg.Processor.(*processors.Processors); ok && procs != nil && len(procs.List) > 0 {
+		cfg.Processor = procs.Instrument(reg.NewRegistry("processors"))
+	}
+
+	return &clientMetrics{
+		name:      name,
+		total:     monitoring.NewUint(reg, "events.total"),
+		filtered:  monitoring.NewUint(reg, "events.filtered"),
+		published: monitoring.NewUint(reg, "events.published"),
+		dropped:   monitoring.NewUint(reg, "events.dropped"),
+	}
+}
+
+func (i *instrumentation) disconnect(m *clientMetrics) {
+	if m == nil {
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.inputs.Remove(m.name)
+}
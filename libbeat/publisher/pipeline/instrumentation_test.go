@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/processors"
+	_ "github.com/elastic/beats/libbeat/processors/actions"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/publisher/queue/memqueue"
+)
+
+func makeTestProcessors(t *testing.T, name string, settings map[string]interface{}) *processors.Processors {
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	procs, err := processors.New(processors.PluginConfig{{name: cfg}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return procs
+}
+
+func TestInstrumentation(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	queueFactory := func(e queue.Eventer) (queue.Queue, error) {
+		return memqueue.NewBroker(memqueue.Settings{Eventer: e, Events: 16}), nil
+	}
+
+	global := makeTestProcessors(t, "drop_fields", map[string]interface{}{
+		"fields": []string{"debug"},
+	})
+	p, err := New(beat.Info{}, reg, queueFactory, outputs.Group{}, Settings{
+		Processors:      global,
+		Instrumentation: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	local := makeTestProcessors(t, "drop_event", map[string]interface{}{
+		"when.equals.type": "debug",
+	})
+	clients := make([]beat.Client, 2)
+	for i := range clients {
+		clients[i], err = p.ConnectWith(beat.ClientConfig{Name: "test", Processor: local})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, typ := range []string{"info", "debug", "info"} {
+		clients[0].Publish(beat.Event{
+			Timestamp: time.Now(),
+			Fields:    common.MapStr{"type": typ, "debug": true},
+		})
+	}
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(3), snapshot.Ints["pipeline.inputs.test.events.total"])
+	assert.Equal(t, int64(1), snapshot.Ints["pipeline.inputs.test.events.filtered"])
+	assert.Equal(t, int64(2), snapshot.Ints["pipeline.inputs.test.events.published"])
+	assert.Equal(t, "drop_event", snapshot.Strings["pipeline.inputs.test.processors.0.name"])
+	assert.Equal(t, int64(1), snapshot.Ints["pipeline.inputs.test.processors.0.events.dropped"])
+	assert.Equal(t, int64(0), snapshot.Ints["pipeline.inputs.test_2.events.total"])
+
+	assert.Equal(t, "drop_fields", snapshot.Strings["pipeline.processors.0.name"])
+	assert.Equal(t, int64(2), snapshot.Ints["pipeline.processors.0.events.in"])
+	assert.Equal(t, int64(2), snapshot.Ints["pipeline.processors.0.time.ns.count"])
+
+	// metrics are removed once the client is closed
+	clients[1].Close()
+	assert.Nil(t, reg.Get("pipeline.inputs.test_2"))
+	assert.NotNil(t, reg.Get("pipeline.inputs.test"))
+	clients[0].Close()
+}
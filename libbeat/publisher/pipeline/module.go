@@ -29,6 +29,7 @@ import (
 	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/elastic/beats/libbeat/processors"
 	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/publisher/validation"
 )
 
 // Global pipeline module for loading the main pipeline from a configuration object
@@ -59,12 +60,30 @@ func Load(
 		return nil, fmt.Errorf("error initializing processors: %v", err)
 	}
 
+	var validationReg *monitoring.Registry
+	if reg != nil && config.Validation.Enabled() {
+		pipelineReg := reg.GetRegistry("pipeline")
+		if pipelineReg == nil {
+			pipelineReg = reg.NewRegistry("pipeline")
+		}
+		validationReg = pipelineReg.NewRegistry("validation")
+	}
+	validator, err := validation.New(config.Validation, beatInfo.Beat, validationReg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing event validation: %v", err)
+	}
+
 	name := beatInfo.Name
 	settings := Settings{
 		WaitClose:     0,
 		WaitCloseMode: NoWaitOnClose,
 		Disabled:      publishDisabled,
 		Processors:    processors,
+		Validator:     validator,
+
+		ReloadableProcessors: config.Reload.Enabled,
+
+		Instrumentation: config.Instrumentation.Enabled,
 		Annotations: Annotations{
 			Event: config.EventMetadata,
 			Builtin: common.MapStr{
@@ -85,7 +104,8 @@ func Load(
 		return nil, err
 	}
 
-	out, err := loadOutput(beatInfo, reg, outcfg)
+	loader := newOutputLoader(beatInfo, reg)
+	out, err := loader.load(outcfg)
 	if err != nil {
 		return nil, err
 	}
@@ -94,16 +114,32 @@ func Load(
 	if err != nil {
 		return nil, err
 	}
+	p.outputLoader = loader
 
 	logp.Info("Beat name: %s", name)
 	return p, err
 }
 
-func loadOutput(
-	beatInfo beat.Info,
-	reg *monitoring.Registry,
-	outcfg common.ConfigNamespace,
-) (outputs.Group, error) {
+// outputLoader creates the output group from the output configuration. The
+// output metrics are shared by all outputs created, so the metrics are kept
+// when the output is reloaded.
+type outputLoader struct {
+	beatInfo beat.Info
+	stats    outputs.Observer
+	typ      *monitoring.String
+}
+
+func newOutputLoader(beatInfo beat.Info, reg *monitoring.Registry) *outputLoader {
+	l := &outputLoader{beatInfo: beatInfo}
+	if reg != nil {
+		outReg := reg.NewRegistry("output")
+		l.stats = outputs.NewStats(outReg)
+		l.typ = monitoring.NewString(outReg, "type")
+	}
+	return l
+}
+
+func (l *outputLoader) load(outcfg common.ConfigNamespace) (outputs.Group, error) {
 	if publishDisabled {
 		return outputs.Group{}, nil
 	}
@@ -114,24 +150,13 @@ func loadOutput(
 		return outputs.Fail(errors.New(msg))
 	}
 
-	// TODO: add support to unload/reassign outStats on output reloading
-
-	var (
-		outReg   *monitoring.Registry
-		outStats outputs.Observer
-	)
-	if reg != nil {
-		outReg = reg.NewRegistry("output")
-		outStats = outputs.NewStats(outReg)
-	}
-
-	out, err := outputs.Load(beatInfo, outStats, outcfg.Name(), outcfg.Config())
+	out, err := outputs.Load(l.beatInfo, l.stats, outcfg.Name(), outcfg.Config())
 	if err != nil {
 		return outputs.Fail(err)
 	}
 
-	if outReg != nil {
-		monitoring.NewString(outReg, "type").Set(outcfg.Name())
+	if l.typ != nil {
+		l.typ.Set(outcfg.Name())
 	}
 
 	return out, nil
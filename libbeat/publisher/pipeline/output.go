@@ -62,6 +62,7 @@ func (w *clientWorker) run() {
 	for !w.closed.Load() {
 		for batch := range w.qu {
 			w.observer.outBatchSend(len(batch.events))
+			batch.stage("output")
 
 			if err := w.client.Publish(batch); err != nil {
 				return
@@ -104,6 +105,7 @@ func (w *netClientWorker) run() {
 				return
 			}
 
+			batch.stage("output")
 			err := w.client.Publish(batch)
 			if err != nil {
 				logp.Err("Failed to publish events: %v", err)
@@ -72,7 +72,12 @@ type Pipeline struct {
 	ackBuilder ackBuilder
 	eventSema  *sema
 
-	processors pipelineProcessors
+	processors      pipelineProcessors
+	instrumentation *instrumentation
+
+	// reload support
+	reloadMutex  sync.Mutex
+	outputLoader *outputLoader
 }
 
 type pipelineProcessors struct {
@@ -84,6 +89,11 @@ type pipelineProcessors struct {
 	tags        []string
 
 	processors beat.Processor
+	validator  beat.Processor
+
+	// reloadable is set if the global processors can be replaced, it is used as
+	// the global processor by all clients.
+	reloadable *reloadableProcessor
 
 	disabled   bool // disabled is set if outputs have been disabled via CLI
 	alwaysCopy bool
@@ -100,7 +110,18 @@ type Settings struct {
 	Annotations Annotations
 	Processors  *processors.Processors
 
+	// Validator, if set, is run on every event after all processors.
+	Validator beat.Processor
+
 	Disabled bool
+
+	// ReloadableProcessors allows the global processors to be replaced using
+	// ReloadProcessors while the pipeline is running.
+	ReloadableProcessors bool
+
+	// Instrumentation enables the collection of per client and per processor
+	// metrics. Instrumentation requires a metrics registry to be passed to New.
+	Instrumentation bool
 }
 
 // Annotations configures additional metadata to be adde to every single event
@@ -168,14 +189,19 @@ func New(
 		observer:         nilObserver,
 		waitCloseMode:    settings.WaitCloseMode,
 		waitCloseTimeout: settings.WaitClose,
-		processors:       makePipelineProcessors(annotations, processors, disabledOutput),
 	}
 	p.ackBuilder = &pipelineEmptyACK{p}
 	p.ackActive = atomic.MakeBool(true)
 
 	if metrics != nil {
 		p.observer = newMetricsObserver(metrics)
+		if settings.Instrumentation {
+			p.instrumentation = newInstrumentation(metrics)
+			processors = p.instrumentation.instrumentGlobal(processors)
+		}
 	}
+	p.processors = makePipelineProcessors(annotations, processors, disabledOutput, settings.ReloadableProcessors)
+	p.processors.validator = settings.Validator
 	p.eventer.observer = p.observer
 	p.eventer.modifyable = true
 
@@ -333,6 +359,11 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 		}
 	}
 
+	var metrics *clientMetrics
+	if p.instrumentation != nil {
+		metrics = p.instrumentation.connect(&cfg)
+	}
+
 	processors := newProcessorPipeline(p.beatInfo, p.processors, cfg)
 	acker := p.makeACKer(processors != nil, &cfg, waitClose)
 	producerCfg := queue.ProducerConfig{
@@ -361,6 +392,7 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 	producer := p.queue.Producer(producerCfg)
 	client := &client{
 		pipeline:     p,
+		name:         cfg.Name,
 		isOpen:       atomic.MakeBool(true),
 		eventer:      cfg.Events,
 		processors:   processors,
@@ -369,6 +401,7 @@ func (p *Pipeline) ConnectWith(cfg beat.ClientConfig) (beat.Client, error) {
 		eventFlags:   eventFlags,
 		canDrop:      canDrop,
 		reportEvents: reportEvents,
+		metrics:      metrics,
 	}
 
 	p.observer.clientConnected()
@@ -404,18 +437,18 @@ func makePipelineProcessors(
 	annotations Annotations,
 	processors *processors.Processors,
 	disabled bool,
+	reloadable bool,
 ) pipelineProcessors {
 	p := pipelineProcessors{
 		disabled: disabled,
 	}
 
-	hasProcessors := processors != nil && len(processors.List) > 0
-	if hasProcessors {
-		tmp := &program{title: "global"}
-		for _, p := range processors.List {
-			tmp.add(p)
-		}
-		p.processors = tmp
+	global := makeGlobalProcessors(processors)
+	if reloadable {
+		p.reloadable = &reloadableProcessor{processor: global, procs: processors}
+		p.processors = p.reloadable
+	} else if global != nil {
+		p.processors = global
 	}
 
 	if meta := annotations.Builtin; meta != nil {
@@ -434,3 +467,15 @@ func makePipelineProcessors(
 
 	return p
 }
+
+func makeGlobalProcessors(processors *processors.Processors) beat.Processor {
+	if processors == nil || len(processors.List) == 0 {
+		return nil
+	}
+
+	tmp := &program{title: "global"}
+	for _, p := range processors.List {
+		tmp.add(p)
+	}
+	return tmp
+}
@@ -54,8 +54,9 @@ type processorFn struct {
 //  6. (C) client processors list
 //  7. (P) add beats metadata
 //  8. (P) pipeline processors list
-//  9. (P) (if publish/debug enabled) log event
-// 10. (P) (if output disabled) dropEvent
+//  9. (P) (if validation enabled) validate event against the fields schema
+// 10. (P) (if publish/debug enabled) log event
+// 11. (P) (if output disabled) dropEvent
 func newProcessorPipeline(
 	info beat.Info,
 	global pipelineProcessors,
@@ -124,6 +125,9 @@ func newProcessorPipeline(
 	// setup 7: pipeline processors list
 	processors.add(global.processors)
 
+	// setup 8: validate event against the fields schema (P)
+	processors.add(global.validator)
+
 	// setup 9: debug print final event (P)
 	if logp.IsDebug("publish") {
 		processors.add(debugPrintProcessor(info))
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+// reloadableProcessor forwards events to the current global processors. The
+// processors can be replaced while clients are publishing events.
+type reloadableProcessor struct {
+	mutex     sync.RWMutex
+	processor beat.Processor
+
+	// procs are the processors used by processor, closed when replaced
+	procs *processors.Processors
+}
+
+// ReloadOutput replaces the output of the pipeline with a new output created
+// from outcfg. Publishing is paused while the output is replaced. Events not
+// yet ACKed by the old output are retried with the new output. If the new
+// output can not be created, the current output is kept.
+func (p *Pipeline) ReloadOutput(outcfg common.ConfigNamespace) error {
+	if p.outputLoader == nil {
+		return errors.New("the pipeline does not support reloading the output")
+	}
+
+	p.reloadMutex.Lock()
+	defer p.reloadMutex.Unlock()
+
+	out, err := p.outputLoader.load(outcfg)
+	if err != nil {
+		return fmt.Errorf("error initializing output: %v", err)
+	}
+
+	p.logger.Infof("Reloading output %v", outcfg.Name())
+	p.output.Set(out)
+	return nil
+}
+
+// ReloadProcessors replaces the global processors of the pipeline, for all
+// connected and future clients. The replaced processors are closed once no
+// event is processed by them anymore. If the processors can not be created,
+// the current processors are kept.
+func (p *Pipeline) ReloadProcessors(config processors.PluginConfig) error {
+	if p.processors.reloadable == nil {
+		return errors.New("reloading the processors is not enabled")
+	}
+
+	p.reloadMutex.Lock()
+	defer p.reloadMutex.Unlock()
+
+	procs, err := processors.New(config)
+	if err != nil {
+		return fmt.Errorf("error initializing processors: %v", err)
+	}
+	if p.instrumentation != nil {
+		procs = p.instrumentation.instrumentGlobal(procs)
+	}
+
+	p.logger.Infof("Reloading processors: %v", procs)
+	old := p.processors.reloadable.set(makeGlobalProcessors(procs), procs)
+	if err := old.Close(); err != nil {
+		p.logger.Errorf("Error closing the replaced processors: %v", err)
+	}
+	return nil
+}
+
+// set replaces the processor, returning the processors being replaced. It
+// waits for the events being processed by the current processor.
+func (r *reloadableProcessor) set(processor beat.Processor, procs *processors.Processors) *processors.Processors {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	old := r.procs
+	r.processor = processor
+	r.procs = procs
+	return old
+}
+
+func (r *reloadableProcessor) get() beat.Processor {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.processor
+}
+
+func (r *reloadableProcessor) Run(event *beat.Event) (*beat.Event, error) {
+	// hold the lock while processing, so the processor is not closed while
+	// in use
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.processor == nil {
+		return event, nil
+	}
+	return r.processor.Run(event)
+}
+
+func (r *reloadableProcessor) String() string {
+	processor := r.get()
+	if processor == nil {
+		return "global{}"
+	}
+	return processor.String()
+}
@@ -0,0 +1,260 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/processors"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/publisher/queue/memqueue"
+)
+
+// recordedEvents collects the events published by the reloadtest outputs by
+// output name.
+var recordedEvents = struct {
+	sync.Mutex
+	events map[string][]common.MapStr
+}{events: map[string][]common.MapStr{}}
+
+type recordingClient struct {
+	name string
+}
+
+func init() {
+	outputs.RegisterType("reloadtest", func(
+		_ beat.Info,
+		_ outputs.Observer,
+		cfg *common.Config,
+	) (outputs.Group, error) {
+		name, err := cfg.String("name", -1)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+		return outputs.Success(0, 0, &recordingClient{name: name})
+	})
+
+	processors.RegisterPlugin("reloadtest_closer", newClosingProcessor)
+}
+
+// closingProcessor records if it has been closed.
+type closingProcessor struct {
+	closed bool
+}
+
+var closingProcessors []*closingProcessor
+
+func newClosingProcessor(_ *common.Config) (processors.Processor, error) {
+	p := &closingProcessor{}
+	closingProcessors = append(closingProcessors, p)
+	return p, nil
+}
+
+func (p *closingProcessor) Run(event *beat.Event) (*beat.Event, error) { return event, nil }
+func (p *closingProcessor) Close() error                               { p.closed = true; return nil }
+func (p *closingProcessor) String() string                             { return "reloadtest_closer" }
+
+func (c *recordingClient) Publish(batch publisher.Batch) error {
+	recordedEvents.Lock()
+	for _, event := range batch.Events() {
+		recordedEvents.events[c.name] = append(recordedEvents.events[c.name], event.Content.Fields)
+	}
+	recordedEvents.Unlock()
+
+	batch.ACK()
+	return nil
+}
+
+func (c *recordingClient) Close() error   { return nil }
+func (c *recordingClient) String() string { return "reloadtest" }
+
+func recorded(name string) []common.MapStr {
+	recordedEvents.Lock()
+	defer recordedEvents.Unlock()
+	return recordedEvents.events[name]
+}
+
+func waitRecorded(t *testing.T, name string, n int) []common.MapStr {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if events := recorded(name); len(events) >= n {
+			return events
+		}
+	}
+	t.Fatalf("output %s did not receive %d events", name, n)
+	return nil
+}
+
+func makeOutputConfig(t *testing.T, settings map[string]interface{}) common.ConfigNamespace {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"output": settings})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Output common.ConfigNamespace `config:"output"`
+	}
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+	return config.Output
+}
+
+func TestReload(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	queueFactory := func(e queue.Eventer) (queue.Queue, error) {
+		return memqueue.NewBroker(memqueue.Settings{Eventer: e, Events: 16}), nil
+	}
+
+	loader := newOutputLoader(beat.Info{}, reg)
+	out, err := loader.load(makeOutputConfig(t, map[string]interface{}{
+		"reloadtest.name": "first",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(beat.Info{}, reg, queueFactory, out, Settings{
+		ReloadableProcessors: true,
+		Instrumentation:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.outputLoader = loader
+	defer p.Close()
+
+	client, err := p.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	publish := func() {
+		client.Publish(beat.Event{
+			Timestamp: time.Now(),
+			Fields:    common.MapStr{"message": "test", "debug": true},
+		})
+	}
+
+	publish()
+	waitRecorded(t, "first", 1)
+
+	// replace the output
+	err = p.ReloadOutput(makeOutputConfig(t, map[string]interface{}{
+		"reloadtest.name": "second",
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	publish()
+	events := waitRecorded(t, "second", 1)
+	assert.Equal(t, true, events[0]["debug"])
+	assert.Len(t, recorded("first"), 1)
+
+	// invalid outputs keep the current output
+	err = p.ReloadOutput(makeOutputConfig(t, map[string]interface{}{
+		"unknown.hosts": []string{"localhost"},
+	}))
+	assert.Error(t, err)
+
+	// replace the processors of the connected client
+	cfg, _ := common.NewConfigFrom(map[string]interface{}{"fields": []string{"debug"}})
+	err = p.ReloadProcessors(processors.PluginConfig{{"drop_fields": cfg}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	publish()
+	events = waitRecorded(t, "second", 2)
+	assert.NotContains(t, events[1], "debug")
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, "reloadtest", snapshot.Strings["output.type"])
+	assert.Equal(t, "drop_fields", snapshot.Strings["pipeline.processors.0.name"])
+
+	// invalid processors keep the current processors
+	err = p.ReloadProcessors(processors.PluginConfig{{"unknown": cfg}})
+	assert.Error(t, err)
+}
+
+func TestReloadProcessorsNotEnabled(t *testing.T) {
+	queueFactory := func(e queue.Eventer) (queue.Queue, error) {
+		return memqueue.NewBroker(memqueue.Settings{Eventer: e, Events: 16}), nil
+	}
+
+	p, err := New(beat.Info{}, nil, queueFactory, outputs.Group{}, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	assert.Error(t, p.ReloadProcessors(processors.PluginConfig{}))
+	assert.Error(t, p.ReloadOutput(common.ConfigNamespace{}))
+}
+
+func TestReloadClosesReplacedProcessors(t *testing.T) {
+	queueFactory := func(e queue.Eventer) (queue.Queue, error) {
+		return memqueue.NewBroker(memqueue.Settings{Eventer: e, Events: 16}), nil
+	}
+
+	closingProcessors = nil
+	initial, err := processors.New(processors.PluginConfig{{"reloadtest_closer": common.NewConfig()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(beat.Info{}, nil, queueFactory, outputs.Group{}, Settings{
+		Processors:           initial,
+		ReloadableProcessors: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	reload := func() {
+		err := p.ReloadProcessors(processors.PluginConfig{{"reloadtest_closer": common.NewConfig()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reload()
+	if assert.Len(t, closingProcessors, 2) {
+		assert.True(t, closingProcessors[0].closed)
+		assert.False(t, closingProcessors[1].closed)
+	}
+
+	reload()
+	if assert.Len(t, closingProcessors, 3) {
+		assert.True(t, closingProcessors[1].closed)
+		assert.False(t, closingProcessors[2].closed)
+	}
+
+	// processors failing to be created don't close the current processors
+	assert.Error(t, p.ReloadProcessors(processors.PluginConfig{{"unknown": common.NewConfig()}}))
+	assert.False(t, closingProcessors[2].closed)
+}
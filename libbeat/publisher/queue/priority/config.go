@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package priority
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type config struct {
+	Field   string       `config:"field"`
+	Default string       `config:"default"`
+	Lanes   []laneConfig `config:"lanes"`
+}
+
+type laneConfig struct {
+	Name   string                 `config:"name"   validate:"required"`
+	Weight int                    `config:"weight" validate:"min=1"`
+	Queue  common.ConfigNamespace `config:"queue"`
+}
+
+const metadataPrefix = "@metadata."
+
+var defaultConfig = config{
+	Field:   "@metadata.priority",
+	Default: "normal",
+	Lanes: []laneConfig{
+		{Name: "high", Weight: 8},
+		{Name: "normal", Weight: 4},
+		{Name: "low", Weight: 1},
+	},
+}
+
+func (c *config) Validate() error {
+	if len(c.Lanes) == 0 {
+		return errors.New("at least one lane must be configured")
+	}
+	if c.Field == "" || c.Field == strings.TrimSuffix(metadataPrefix, ".") {
+		return errors.New("field must be set")
+	}
+
+	names := map[string]bool{}
+	for _, lane := range c.Lanes {
+		if names[lane.Name] {
+			return fmt.Errorf("duplicate lane name '%v'", lane.Name)
+		}
+		if lane.Queue.Name() == "priority" {
+			return errors.New("priority queues can not be nested")
+		}
+		names[lane.Name] = true
+	}
+	if !names[c.Default] {
+		return fmt.Errorf("default lane '%v' is not configured", c.Default)
+	}
+	return nil
+}
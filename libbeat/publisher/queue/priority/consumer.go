@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package priority
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/common/atomic"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+)
+
+// consumer prefetches batches from all lanes and returns them to the output
+// in weighted round-robin order.
+type consumer struct {
+	lanes  []*laneConsumer
+	notify chan struct{}
+	done   chan struct{}
+
+	startOnce sync.Once
+	batchSize atomic.Int
+	closed    atomic.Bool
+	wg        sync.WaitGroup
+}
+
+type laneConsumer struct {
+	consumer queue.Consumer
+	batches  chan fetchResult
+	weight   int
+	current  int
+}
+
+type fetchResult struct {
+	batch queue.Batch
+	err   error
+}
+
+func newConsumer(q *Queue) *consumer {
+	c := &consumer{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for _, l := range q.lanes {
+		c.lanes = append(c.lanes, &laneConsumer{
+			consumer: l.queue.Consumer(),
+			batches:  make(chan fetchResult, 1),
+			weight:   l.weight,
+		})
+	}
+	return c
+}
+
+func (c *consumer) Get(sz int) (queue.Batch, error) {
+	if c.closed.Load() {
+		return nil, io.EOF
+	}
+
+	c.batchSize.Store(sz)
+	c.startOnce.Do(func() {
+		for _, l := range c.lanes {
+			c.wg.Add(1)
+			go c.fetch(l)
+		}
+	})
+
+	for {
+		if l := c.next(); l != nil {
+			res := <-l.batches
+			return res.batch, res.err
+		}
+
+		select {
+		case <-c.done:
+			return nil, io.EOF
+		case <-c.notify:
+		}
+	}
+}
+
+// next selects the lane to return the next batch from, using smooth weighted
+// round-robin on all lanes with a batch being available. Returns nil if no
+// batch is available.
+func (c *consumer) next() *laneConsumer {
+	var selected *laneConsumer
+	total := 0
+	for _, l := range c.lanes {
+		if len(l.batches) == 0 {
+			continue
+		}
+
+		l.current += l.weight
+		total += l.weight
+		if selected == nil || l.current > selected.current {
+			selected = l
+		}
+	}
+
+	if selected != nil {
+		selected.current -= total
+	}
+	return selected
+}
+
+// fetch reads batches from a lane until the lane consumer is closed.
+func (c *consumer) fetch(l *laneConsumer) {
+	defer c.wg.Done()
+
+	for {
+		batch, err := l.consumer.Get(c.batchSize.Load())
+		select {
+		case <-c.done:
+			return
+		case l.batches <- fetchResult{batch, err}:
+		}
+
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *consumer) Close() error {
+	if c.closed.Swap(true) {
+		return errors.New("already closed")
+	}
+
+	close(c.done)
+	var firstErr error
+	for _, l := range c.lanes {
+		if err := l.consumer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package priority
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+)
+
+type producer struct {
+	queue     *Queue
+	producers []queue.Producer
+	acks      *ackMerger
+}
+
+// ackMerger merges the ACKs of all lanes, reporting ACKs in the order events
+// have been published. Lanes ACK independently of each other, but the
+// pipeline requires ACKs to be reported in publishing order.
+type ackMerger struct {
+	mu  sync.Mutex
+	ack func(int)
+
+	// state of published events not yet reported, starting with the oldest
+	// event
+	states []eventState
+
+	// sequence number of states[0]
+	base uint64
+
+	// next sequence number
+	seq uint64
+
+	// sequence numbers of events waiting for an ACK per lane
+	lanes [][]uint64
+}
+
+type eventState uint8
+
+const (
+	eventPending eventState = iota
+	eventACKed
+	eventDropped
+)
+
+func newProducer(q *Queue, cfg queue.ProducerConfig) *producer {
+	p := &producer{queue: q}
+	if cfg.ACK != nil {
+		p.acks = &ackMerger{
+			ack:   cfg.ACK,
+			lanes: make([][]uint64, len(q.lanes)),
+		}
+	}
+
+	for i, l := range q.lanes {
+		laneCfg := cfg
+		if p.acks != nil {
+			lane := i
+			laneCfg.ACK = func(n int) { p.acks.onACK(lane, n) }
+		}
+		p.producers = append(p.producers, l.queue.Producer(laneCfg))
+	}
+	return p
+}
+
+func (p *producer) Publish(event publisher.Event) bool {
+	return p.publish(event, queue.Producer.Publish)
+}
+
+func (p *producer) TryPublish(event publisher.Event) bool {
+	return p.publish(event, queue.Producer.TryPublish)
+}
+
+func (p *producer) publish(
+	event publisher.Event,
+	fn func(queue.Producer, publisher.Event) bool,
+) bool {
+	lane := p.queue.classify(&event)
+	if p.acks == nil {
+		return fn(p.producers[lane], event)
+	}
+
+	// the sequence number must be registered before publishing, as the lane
+	// might ACK the event before fn returns
+	seq := p.acks.add(lane)
+	published := fn(p.producers[lane], event)
+	if !published {
+		p.acks.drop(lane, seq)
+	}
+	return published
+}
+
+func (p *producer) Cancel() int {
+	n := 0
+	for _, prod := range p.producers {
+		n += prod.Cancel()
+	}
+	return n
+}
+
+func (m *ackMerger) add(lane int) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seq := m.seq
+	m.seq++
+	m.states = append(m.states, eventPending)
+	m.lanes[lane] = append(m.lanes[lane], seq)
+	return seq
+}
+
+// drop marks an event not accepted by the lane. Dropped events are not
+// reported, but do not block ACKs of later events.
+func (m *ackMerger) drop(lane int, seq uint64) {
+	m.mu.Lock()
+	pending := m.lanes[lane]
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i] == seq {
+			m.lanes[lane] = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+	m.states[seq-m.base] = eventDropped
+	acked := m.flush()
+	m.mu.Unlock()
+
+	m.report(acked)
+}
+
+func (m *ackMerger) onACK(lane, n int) {
+	m.mu.Lock()
+	pending := m.lanes[lane]
+	if n > len(pending) {
+		n = len(pending)
+	}
+	for _, seq := range pending[:n] {
+		m.states[seq-m.base] = eventACKed
+	}
+	m.lanes[lane] = pending[n:]
+	if len(m.lanes[lane]) == 0 {
+		m.lanes[lane] = nil
+	}
+	acked := m.flush()
+	m.mu.Unlock()
+
+	m.report(acked)
+}
+
+// report forwards ACKs to the pipeline. It is called without holding the
+// lock, as the callback might block. ACKs are counts only, so the order of
+// concurrent reports does not matter.
+func (m *ackMerger) report(n int) {
+	if n > 0 {
+		m.ack(n)
+	}
+}
+
+// flush removes the completed events at the start of the published events,
+// returning the number of ACKed events to report.
+func (m *ackMerger) flush() int {
+	acked, done := 0, 0
+	for _, st := range m.states {
+		if st == eventPending {
+			break
+		}
+		if st == eventACKed {
+			acked++
+		}
+		done++
+	}
+	if done == 0 {
+		return 0
+	}
+
+	m.states = m.states[done:]
+	m.base += uint64(done)
+	if len(m.states) == 0 {
+		// release the consumed backing array
+		m.states = nil
+	}
+	return acked
+}
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package priority provides a queue classifying events into priority lanes.
+// Each lane is backed by its own queue. The consumer schedules batches from
+// the lanes using smooth weighted round-robin, such that low volume, high
+// priority events are not starved by bulk events.
+package priority
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/feature"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+)
+
+// Feature exposes the priority lanes queue.
+var Feature = queue.Feature("priority", create, feature.Beta)
+
+// Queue dispatches events to its lanes.
+type Queue struct {
+	lanes       []lane
+	laneIndex   map[string]int
+	defaultLane int
+	field       string
+	fromMeta    bool
+}
+
+type lane struct {
+	name   string
+	weight int
+	queue  queue.Queue
+}
+
+// Lane configures a priority lane.
+type Lane struct {
+	Name   string
+	Weight int
+	Queue  queue.Queue
+}
+
+// Settings configures a priority queue.
+type Settings struct {
+	// Field holds the event field the lane name is read from. Fields prefixed
+	// with `@metadata.` are read from the event meta data.
+	Field string
+
+	// Default is the name of the lane unclassified events are published to.
+	Default string
+
+	Lanes []Lane
+}
+
+func init() {
+	queue.RegisterType("priority", create)
+}
+
+func create(eventer queue.Eventer, cfg *common.Config) (queue.Queue, error) {
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	var lanes []Lane
+	closeLanes := func() {
+		for _, l := range lanes {
+			l.Queue.Close()
+		}
+	}
+
+	for _, lc := range config.Lanes {
+		laneQueue, err := loadLaneQueue(eventer, lc.Queue)
+		if err != nil {
+			closeLanes()
+			return nil, fmt.Errorf("failed to create queue for lane '%v': %v", lc.Name, err)
+		}
+		lanes = append(lanes, Lane{Name: lc.Name, Weight: lc.Weight, Queue: laneQueue})
+	}
+
+	return NewQueue(Settings{
+		Field:   config.Field,
+		Default: config.Default,
+		Lanes:   lanes,
+	}), nil
+}
+
+func loadLaneQueue(eventer queue.Eventer, ns common.ConfigNamespace) (queue.Queue, error) {
+	name := ns.Name()
+	if name == "" {
+		name = "mem"
+	}
+
+	factory := queue.FindFactory(name)
+	if factory == nil {
+		return nil, fmt.Errorf("'%v' is no valid queue type", name)
+	}
+
+	cfg := ns.Config()
+	if cfg == nil {
+		cfg = common.NewConfig()
+	}
+	return factory(eventer, cfg)
+}
+
+// NewQueue creates a new priority queue from a set of lanes. The lanes are
+// closed when the priority queue is closed.
+func NewQueue(settings Settings) *Queue {
+	q := &Queue{
+		laneIndex: map[string]int{},
+		field:     settings.Field,
+	}
+	if strings.HasPrefix(q.field, metadataPrefix) {
+		q.field = strings.TrimPrefix(q.field, metadataPrefix)
+		q.fromMeta = true
+	}
+
+	for i, l := range settings.Lanes {
+		q.lanes = append(q.lanes, lane{name: l.Name, weight: l.Weight, queue: l.Queue})
+		q.laneIndex[l.Name] = i
+		if l.Name == settings.Default {
+			q.defaultLane = i
+		}
+	}
+	return q
+}
+
+// Close closes all lanes.
+func (q *Queue) Close() error {
+	var firstErr error
+	for _, l := range q.lanes {
+		if err := l.queue.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// BufferConfig reports the combined buffer size of all lanes. If the size of
+// any lane is unknown, the combined size is unknown as well.
+func (q *Queue) BufferConfig() queue.BufferConfig {
+	total := 0
+	for _, l := range q.lanes {
+		events := l.queue.BufferConfig().Events
+		if events <= 0 {
+			return queue.BufferConfig{Events: 0}
+		}
+		total += events
+	}
+	return queue.BufferConfig{Events: total}
+}
+
+func (q *Queue) Producer(cfg queue.ProducerConfig) queue.Producer {
+	return newProducer(q, cfg)
+}
+
+func (q *Queue) Consumer() queue.Consumer {
+	return newConsumer(q)
+}
+
+// classify returns the index of the lane the event is published to.
+func (q *Queue) classify(event *publisher.Event) int {
+	var fields common.MapStr
+	if q.fromMeta {
+		fields = event.Content.Meta
+	} else {
+		fields = event.Content.Fields
+	}
+	if fields == nil {
+		return q.defaultLane
+	}
+
+	v, err := fields.GetValue(q.field)
+	if err != nil {
+		return q.defaultLane
+	}
+	name, ok := v.(string)
+	if !ok {
+		return q.defaultLane
+	}
+	if i, exists := q.laneIndex[name]; exists {
+		return i
+	}
+	return q.defaultLane
+}
@@ -0,0 +1,240 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package priority
+
+import (
+	"flag"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/libbeat/publisher/queue"
+	"github.com/elastic/beats/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/libbeat/publisher/queue/queuetest"
+)
+
+var seed int64
+
+func init() {
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "test random seed")
+}
+
+func makeTestQueue(sz int) *Queue {
+	var lanes []Lane
+	for _, l := range []struct {
+		name   string
+		weight int
+	}{{"high", 4}, {"normal", 1}} {
+		lanes = append(lanes, Lane{
+			Name:   l.name,
+			Weight: l.weight,
+			Queue:  memqueue.NewBroker(memqueue.Settings{Events: sz, WaitOnClose: true}),
+		})
+	}
+	return NewQueue(Settings{
+		Field:   "@metadata.priority",
+		Default: "normal",
+		Lanes:   lanes,
+	})
+}
+
+func makeEvent(priority string) publisher.Event {
+	event := publisher.Event{Content: beat.Event{
+		Timestamp: time.Now(),
+		Fields:    common.MapStr{"message": "test"},
+	}}
+	if priority != "" {
+		event.Content.Meta = common.MapStr{"priority": priority}
+	}
+	return event
+}
+
+func TestProduceConsumer(t *testing.T) {
+	maxEvents := 1024
+	minEvents := 32
+
+	rand.Seed(seed)
+	events := rand.Intn(maxEvents-minEvents) + minEvents
+	batchSize := rand.Intn(events-8) + 4
+	bufferSize := rand.Intn(batchSize*2) + 4
+
+	t.Log("seed: ", seed)
+	t.Log("events: ", events)
+	t.Log("batchSize: ", batchSize)
+	t.Log("bufferSize: ", bufferSize)
+
+	factory := func(_ *testing.T) queue.Queue {
+		return makeTestQueue(bufferSize)
+	}
+	t.Run("single", func(t *testing.T) {
+		queuetest.TestSingleProducerConsumer(t, events, batchSize, factory)
+	})
+	t.Run("multi", func(t *testing.T) {
+		queuetest.TestMultiProducerConsumer(t, events, batchSize, factory)
+	})
+}
+
+func TestClassify(t *testing.T) {
+	q := makeTestQueue(16)
+	defer q.Close()
+
+	tests := map[string]int{
+		"high":    0,
+		"normal":  1,
+		"unknown": 1,
+		"":        1,
+	}
+	for priority, expected := range tests {
+		event := makeEvent(priority)
+		assert.Equal(t, expected, q.classify(&event), priority)
+	}
+
+	q.field, q.fromMeta = "fields.priority", false
+	event := makeEvent("")
+	event.Content.Fields.Put("fields.priority", "high")
+	assert.Equal(t, 0, q.classify(&event))
+}
+
+func TestWeightedScheduling(t *testing.T) {
+	q := makeTestQueue(256)
+	defer q.Close()
+
+	producer := q.Producer(queue.ProducerConfig{})
+	for i := 0; i < 100; i++ {
+		producer.Publish(makeEvent("normal"))
+	}
+	for i := 0; i < 10; i++ {
+		producer.Publish(makeEvent("high"))
+	}
+
+	consumer := q.Consumer()
+	defer consumer.Close()
+
+	high := 0
+	for i := 0; i < 10; i++ {
+		batch, err := consumer.Get(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch.Events()[0].Content.Meta["priority"] == "high" {
+			high++
+		}
+		batch.ACK()
+
+		// give the lanes the chance to prefetch the next batch
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// high priority events are not stuck behind the normal events published
+	// first. The first batch might be taken from whichever lane is ready first.
+	assert.True(t, high >= 7, "high priority batches: %v", high)
+}
+
+func TestNextLane(t *testing.T) {
+	c := &consumer{}
+	for _, weight := range []int{4, 1} {
+		l := &laneConsumer{batches: make(chan fetchResult, 1), weight: weight}
+		l.batches <- fetchResult{}
+		c.lanes = append(c.lanes, l)
+	}
+
+	var order []int
+	for i := 0; i < 10; i++ {
+		l := c.next()
+		for idx := range c.lanes {
+			if c.lanes[idx] == l {
+				order = append(order, idx)
+			}
+		}
+	}
+	assert.Equal(t, []int{0, 0, 1, 0, 0, 0, 0, 1, 0, 0}, order)
+
+	// lanes without batches are skipped
+	<-c.lanes[0].batches
+	assert.Equal(t, c.lanes[1], c.next())
+	<-c.lanes[1].batches
+	assert.Nil(t, c.next())
+}
+
+func TestACKOrder(t *testing.T) {
+	var acked []int
+	m := &ackMerger{
+		ack:   func(n int) { acked = append(acked, n) },
+		lanes: make([][]uint64, 2),
+	}
+
+	m.add(0)
+	m.add(1)
+	seq := m.add(0)
+	m.add(1)
+
+	// ACKs of lane 1 wait for the first event of lane 0
+	m.onACK(1, 1)
+	assert.Empty(t, acked)
+
+	m.onACK(0, 1)
+	assert.Equal(t, []int{2}, acked)
+
+	// dropped events are not reported, but do not block later ACKs
+	m.drop(0, seq)
+	assert.Equal(t, []int{2}, acked)
+
+	m.onACK(1, 1)
+	assert.Equal(t, []int{2, 1}, acked)
+	assert.Empty(t, m.states)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"no lanes": {
+			"lanes": []interface{}{},
+		},
+		"duplicate lane": {
+			"lanes": []map[string]interface{}{
+				{"name": "normal", "weight": 1},
+				{"name": "normal", "weight": 2},
+			},
+		},
+		"missing default lane": {
+			"default": "debug",
+		},
+		"nested priority queue": {
+			"default": "a",
+			"lanes": []map[string]interface{}{
+				{"name": "a", "weight": 1, "queue.priority": map[string]interface{}{}},
+			},
+		},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
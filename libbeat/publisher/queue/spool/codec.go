@@ -37,10 +37,12 @@ type encoder struct {
 	buf    bytes.Buffer
 	folder *gotype.Iterator
 	codec  codecID
+	env    *envelope
 }
 
 type decoder struct {
 	buf []byte
+	env *envelope
 
 	json     *json.Parser
 	cborl    *cborl.Parser
@@ -68,7 +70,7 @@ const (
 	flagGuaranteed uint8 = 1 << 0
 )
 
-func newEncoder(codec codecID) (*encoder, error) {
+func newEncoder(codec codecID, env *envelope) (*encoder, error) {
 	switch codec {
 	case codecJSON, codecCBORL, codecUBJSON:
 		break
@@ -76,7 +78,7 @@ func newEncoder(codec codecID) (*encoder, error) {
 		return nil, fmt.Errorf("unknown codec type '%v'", codec)
 	}
 
-	e := &encoder{codec: codec}
+	e := &encoder{codec: codec, env: env}
 	e.reset()
 	return e, nil
 }
@@ -124,11 +126,14 @@ func (e *encoder) encode(event *publisher.Event) ([]byte, error) {
 		return nil, err
 	}
 
-	return e.buf.Bytes(), nil
+	if e.env == nil {
+		return e.buf.Bytes(), nil
+	}
+	return e.env.seal(e.buf.Bytes())
 }
 
-func newDecoder() *decoder {
-	d := &decoder{}
+func newDecoder(env *envelope) *decoder {
+	d := &decoder{env: env}
 	d.reset()
 	return d
 }
@@ -156,11 +161,19 @@ func (d *decoder) Buffer(n int) []byte {
 }
 
 func (d *decoder) Decode() (publisher.Event, error) {
+	record := d.buf
+	if d.env != nil {
+		var err error
+		if record, err = d.env.open(record); err != nil {
+			return publisher.Event{}, err
+		}
+	}
+
 	var (
 		to       entry
 		err      error
-		codec    = codecID(d.buf[0])
-		contents = d.buf[1:]
+		codec    = codecID(record[0])
+		contents = record[1:]
 	)
 
 	d.unfolder.SetTarget(&to)
@@ -31,9 +31,10 @@ import (
 )
 
 type config struct {
-	File  pathConfig  `config:"file"`
-	Write writeConfig `config:"write"`
-	Read  readConfig  `config:"read"`
+	File       pathConfig       `config:"file"`
+	Write      writeConfig      `config:"write"`
+	Read       readConfig       `config:"read"`
+	Encryption encryptionConfig `config:"encryption"`
 }
 
 type pathConfig struct {
@@ -49,12 +50,21 @@ type writeConfig struct {
 	FlushEvents  time.Duration    `config:"flush.events"`
 	FlushTimeout time.Duration    `config:"flush.timeout"`
 	Codec        codecID          `config:"codec"`
+	Compression  compressionID    `config:"compression"`
 }
 
 type readConfig struct {
 	FlushTimeout time.Duration `config:"flush.timeout"`
 }
 
+type encryptionConfig struct {
+	Key string `config:"key"`
+}
+
+// minEncryptionKeySize is the minimum length of the secret the encryption key
+// is derived from.
+const minEncryptionKeySize = 16
+
 func defaultConfig() config {
 	return config{
 		File: pathConfig{
@@ -112,6 +122,13 @@ func (c *readConfig) Validate() error {
 	return nil
 }
 
+func (c *encryptionConfig) Validate() error {
+	if c.Key != "" && len(c.Key) < minEncryptionKeySize {
+		return fmt.Errorf("encryption key must have at least %v characters", minEncryptionKeySize)
+	}
+	return nil
+}
+
 func (c *codecID) Unpack(value string) error {
 	ids := map[string]codecID{
 		"json":   codecJSON,
@@ -127,3 +144,17 @@ func (c *codecID) Unpack(value string) error {
 	*c = id
 	return nil
 }
+
+func (c *compressionID) Unpack(value string) error {
+	switch strings.ToLower(value) {
+	case "none":
+		*c = compressionNone
+	case "lz4":
+		*c = compressionLZ4
+	case "zstd":
+		return errors.New("zstd compression is not supported, use lz4")
+	default:
+		return fmt.Errorf("compression '%v' not available", value)
+	}
+	return nil
+}
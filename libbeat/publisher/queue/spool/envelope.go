@@ -0,0 +1,272 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spool
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/go-txfile"
+	"github.com/elastic/go-txfile/pq"
+	"github.com/pierrec/lz4"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// envelope optionally compresses and encrypts serialized events before they
+// are written to the spool file.
+//
+// The first byte of a record holds the codec ID and the flags set by the
+// envelope. The remaining bytes are layed out as:
+//
+//	encrypted:  nonce | AES-GCM(payload), with the first byte being
+//	            authenticated as additional data
+//	payload:    uvarint(uncompressed size) | LZ4 block, if compressed
+//	            serialized event, otherwise
+//
+// Records without flags are plain serialized events, such that spool files
+// written without compression or encryption stay readable.
+type envelope struct {
+	compression compressionID
+	aead        cipher.AEAD
+
+	// reusable buffers
+	record     []byte
+	compressed []byte
+	plain      []byte
+}
+
+type compressionID uint8
+
+const (
+	compressionNone compressionID = iota
+	compressionLZ4
+)
+
+const (
+	recordCompressed byte = 1 << 6
+	recordEncrypted  byte = 1 << 7
+	recordCodecMask       = ^(recordCompressed | recordEncrypted)
+)
+
+// maxCompressionRatio is the upper bound of the LZ4 block compression ratio,
+// limiting the uncompressed size to allocate for a compressed record.
+const maxCompressionRatio = 255
+
+var (
+	errEncryptedRecord = errors.New("spool record is encrypted, but no encryption key is configured")
+	errCorruptedRecord = errors.New("corrupted compressed spool record")
+)
+
+func newEnvelope(compression compressionID, key []byte) (*envelope, error) {
+	e := &envelope{compression: compression}
+	if len(key) == 0 {
+		return e, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	e.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// The salt used to derive the encryption key is stored in the root page of
+// the spool file, following the queue root:
+//
+//	magic | salt
+const (
+	keyLength       = 32
+	saltLength      = 32
+	iterationsCount = 10000
+)
+
+var saltMagic = []byte("spool-salt-v1")
+
+// deriveKey creates the AES-256 key from the configured secret and the salt
+// of the spool file.
+func deriveKey(secret string, salt []byte) []byte {
+	if secret == "" {
+		return nil
+	}
+	return pbkdf2.Key([]byte(secret), salt, iterationsCount, keyLength, sha512.New)
+}
+
+// fileSalt returns the salt stored in the spool file. A random salt is created
+// and stored if the file has none yet.
+func fileSalt(f *txfile.File) ([]byte, error) {
+	offset := pq.SzRoot
+	end := offset + len(saltMagic) + saltLength
+	if end > f.PageSize() {
+		return nil, fmt.Errorf("spool file page size %v is too small to store the encryption salt", f.PageSize())
+	}
+
+	tx := f.Begin()
+	defer tx.Close()
+
+	page, err := tx.RootPage()
+	if err != nil {
+		return nil, err
+	}
+	if err := page.Load(); err != nil {
+		return nil, err
+	}
+	buf, err := page.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	header := buf[offset:end]
+	if bytes.HasPrefix(header, saltMagic) {
+		return append([]byte(nil), header[len(saltMagic):]...), nil
+	}
+
+	salt, err := common.RandomBytes(saltLength)
+	if err != nil {
+		return nil, err
+	}
+	copy(header, saltMagic)
+	copy(header[len(saltMagic):], salt)
+	if err := page.MarkDirty(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func (e *envelope) active() bool {
+	return e.compression != compressionNone || e.aead != nil
+}
+
+// seal compresses and encrypts a record. The returned buffer is only valid
+// until the next call to seal.
+func (e *envelope) seal(record []byte) ([]byte, error) {
+	if !e.active() {
+		return record, nil
+	}
+
+	header := record[0]
+	payload := record[1:]
+
+	if e.compression == compressionLZ4 {
+		if compressed := e.compress(payload); compressed != nil {
+			header |= recordCompressed
+			payload = compressed
+		}
+	}
+
+	if e.aead == nil {
+		e.record = append(append(e.record[:0], header), payload...)
+		return e.record, nil
+	}
+
+	header |= recordEncrypted
+	nonceSize := e.aead.NonceSize()
+	e.record = append(e.record[:0], header)
+	e.record = append(e.record, make([]byte, nonceSize)...)
+	nonce := e.record[1 : 1+nonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	e.record = e.aead.Seal(e.record, nonce, payload, e.record[:1])
+	return e.record, nil
+}
+
+// compress returns the LZ4 compressed payload, or nil if the payload is not
+// compressible.
+func (e *envelope) compress(payload []byte) []byte {
+	bound := binary.MaxVarintLen64 + lz4.CompressBlockBound(len(payload))
+	if cap(e.compressed) < bound {
+		e.compressed = make([]byte, bound)
+	}
+	buf := e.compressed[:bound]
+
+	n := binary.PutUvarint(buf, uint64(len(payload)))
+	sz, err := lz4.CompressBlock(payload, buf[n:], 0)
+	if err != nil || sz == 0 || n+sz >= len(payload) {
+		return nil
+	}
+	return buf[:n+sz]
+}
+
+// open decrypts and decompresses a record. The returned record starts with
+// the codec ID, followed by the serialized event.
+func (e *envelope) open(record []byte) ([]byte, error) {
+	header := record[0]
+	if header&(recordCompressed|recordEncrypted) == 0 {
+		return record, nil
+	}
+
+	payload := record[1:]
+	if header&recordEncrypted != 0 {
+		if e.aead == nil {
+			return nil, errEncryptedRecord
+		}
+
+		nonceSize := e.aead.NonceSize()
+		if len(payload) < nonceSize {
+			return nil, errors.New("spool record too short")
+		}
+
+		var err error
+		e.plain, err = e.aead.Open(e.plain[:0], payload[:nonceSize], payload[nonceSize:], record[:1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt spool record: %v", err)
+		}
+		payload = e.plain
+	}
+
+	codec := header & recordCodecMask
+	if header&recordCompressed == 0 {
+		e.record = append(append(e.record[:0], codec), payload...)
+		return e.record, nil
+	}
+
+	size, n := binary.Uvarint(payload)
+	if n <= 0 || size > uint64(len(payload)-n)*maxCompressionRatio {
+		return nil, errCorruptedRecord
+	}
+	if cap(e.record) < int(size)+1 {
+		e.record = make([]byte, int(size)+1)
+	}
+	e.record = e.record[:int(size)+1]
+	e.record[0] = codec
+
+	sz, err := lz4.UncompressBlock(payload[n:], e.record[1:], 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress spool record: %v", err)
+	}
+	if uint64(sz) != size {
+		return nil, errCorruptedRecord
+	}
+	return e.record, nil
+}
@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastic/go-txfile"
+	"github.com/elastic/go-txfile/pq"
+	"github.com/elastic/go-txfile/txfiletest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeRoundtrip(t *testing.T) {
+	record := append([]byte{byte(codecJSON)}, bytes.Repeat([]byte(`{"message":"hello world"}`), 10)...)
+	key := deriveKey("0123456789abcdef", []byte("salt"))
+
+	tests := map[string]struct {
+		compression compressionID
+		key         []byte
+	}{
+		"none":          {compressionNone, nil},
+		"lz4":           {compressionLZ4, nil},
+		"encrypted":     {compressionNone, key},
+		"lz4+encrypted": {compressionLZ4, key},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			enc, err := newEnvelope(test.compression, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := newEnvelope(test.compression, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sealed, err := enc.seal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.compression == compressionLZ4, sealed[0]&recordCompressed != 0)
+			assert.Equal(t, test.key != nil, sealed[0]&recordEncrypted != 0)
+			if test.key != nil {
+				assert.False(t, bytes.Contains(sealed, []byte("hello")))
+			}
+
+			opened, err := dec.open(sealed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, record, opened)
+		})
+	}
+}
+
+func TestEnvelopeIncompressible(t *testing.T) {
+	env, err := newEnvelope(compressionLZ4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := []byte{byte(codecJSON), '{', '}'}
+	sealed, err := env.seal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, record, sealed)
+}
+
+func TestEnvelopeOpenFails(t *testing.T) {
+	key := deriveKey("0123456789abcdef", []byte("salt"))
+	enc, err := newEnvelope(compressionNone, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := enc.seal([]byte{byte(codecJSON), '{', '}'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed = append([]byte{}, sealed...)
+
+	noKey, _ := newEnvelope(compressionNone, nil)
+	_, err = noKey.open(sealed)
+	assert.Equal(t, errEncryptedRecord, err)
+
+	otherKey, _ := newEnvelope(compressionNone, deriveKey("fedcba9876543210", []byte("salt")))
+	_, err = otherKey.open(sealed)
+	assert.Error(t, err)
+
+	sealed[len(sealed)-1] ^= 0xff
+	dec, _ := newEnvelope(compressionNone, key)
+	_, err = dec.open(sealed)
+	assert.Error(t, err)
+}
+
+func TestEnvelopeOpenCorrupted(t *testing.T) {
+	dec, _ := newEnvelope(compressionLZ4, nil)
+
+	tests := map[string][]byte{
+		"invalid size": {byte(codecJSON) | recordCompressed, 0xff},
+		"huge size":    {byte(codecJSON) | recordCompressed, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x00},
+		"wrong size":   {byte(codecJSON) | recordCompressed, 0x04, 0x10, 'a'},
+	}
+	for name, record := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := dec.open(record)
+			assert.Equal(t, errCorruptedRecord, err)
+		})
+	}
+}
+
+func TestFileSalt(t *testing.T) {
+	open := func(path string) (*txfile.File, []byte) {
+		f, err := txfile.Open(path, 0600, txfile.Options{MaxSize: 1 << 20, PageSize: 4096})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pq.NewStandaloneDelegate(f); err != nil {
+			t.Fatal(err)
+		}
+		salt, err := fileSalt(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f, salt
+	}
+
+	path, cleanPath := txfiletest.SetupPath(t, "")
+	defer cleanPath()
+	f, salt := open(path)
+	f.Close()
+	assert.Len(t, salt, saltLength)
+
+	// the salt is kept in the file
+	f, reopened := open(path)
+	f.Close()
+	assert.Equal(t, salt, reopened)
+
+	otherPath, cleanOther := txfiletest.SetupPath(t, "")
+	defer cleanOther()
+	f, other := open(otherPath)
+	f.Close()
+	assert.NotEqual(t, salt, other)
+	assert.NotEqual(t, deriveKey("0123456789abcdef", salt), deriveKey("0123456789abcdef", other))
+}
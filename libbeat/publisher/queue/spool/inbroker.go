@@ -75,10 +75,11 @@ func newInBroker(
 	eventer queue.Eventer,
 	qu *pq.Queue,
 	codec codecID,
+	env *envelope,
 	flushTimeout time.Duration,
 	flushEvents uint,
 ) (*inBroker, error) {
-	enc, err := newEncoder(codec)
+	enc, err := newEncoder(codec, env)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +59,8 @@ func create(eventer queue.Eventer, cfg *common.Config) (queue.Queue, error) {
 		WriteFlushEvents:  flushEvents,
 		ReadFlushTimeout:  config.Read.FlushTimeout,
 		Codec:             config.Write.Codec,
+		Compression:       config.Write.Compression,
+		EncryptionSecret:  config.Encryption.Key,
 		File: txfile.Options{
 			MaxSize:  uint64(config.File.MaxSize),
 			PageSize: uint32(config.File.PageSize),
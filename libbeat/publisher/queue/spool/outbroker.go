@@ -80,7 +80,12 @@ var ackChanPool = sync.Pool{
 
 var errRetry = errors.New("retry")
 
-func newOutBroker(ctx *spoolCtx, qu *pq.Queue, flushTimeout time.Duration) (*outBroker, error) {
+func newOutBroker(
+	ctx *spoolCtx,
+	qu *pq.Queue,
+	env *envelope,
+	flushTimeout time.Duration,
+) (*outBroker, error) {
 	b := &outBroker{
 		ctx:   ctx,
 		state: nil,
@@ -104,7 +109,7 @@ func newOutBroker(ctx *spoolCtx, qu *pq.Queue, flushTimeout time.Duration) (*out
 
 		// internal
 		timer: newTimer(flushTimeout),
-		dec:   newDecoder(),
+		dec:   newDecoder(env),
 	}
 
 	b.initState()
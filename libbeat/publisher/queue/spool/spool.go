@@ -70,6 +70,13 @@ type Settings struct {
 	ReadFlushTimeout  time.Duration
 
 	Codec codecID
+
+	// Compression and EncryptionSecret configure the transformation of
+	// serialized events. If EncryptionSecret is set, events are encrypted
+	// using AES-GCM, with the key derived from the secret and a random salt
+	// stored in the spool file.
+	Compression      compressionID
+	EncryptionSecret string
 }
 
 const minInFlushTimeout = 100 * time.Millisecond
@@ -114,6 +121,15 @@ func NewSpool(logger logger, path string, settings Settings) (*Spool, error) {
 		return nil, err
 	}
 
+	var key []byte
+	if settings.EncryptionSecret != "" {
+		salt, err := fileSalt(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "spool queue: failed to read the encryption salt")
+		}
+		key = deriveKey(settings.EncryptionSecret, salt)
+	}
+
 	spool := &Spool{
 		inCtx:  inCtx,
 		outCtx: outCtx,
@@ -133,8 +149,12 @@ func NewSpool(logger logger, path string, settings Settings) (*Spool, error) {
 	if inFlushTimeout < minInFlushTimeout {
 		inFlushTimeout = minInFlushTimeout
 	}
+	inEnv, err := newEnvelope(settings.Compression, key)
+	if err != nil {
+		return nil, err
+	}
 	inBroker, err := newInBroker(inCtx, settings.Eventer, queue, settings.Codec,
-		inFlushTimeout, settings.WriteFlushEvents)
+		inEnv, inFlushTimeout, settings.WriteFlushEvents)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +163,11 @@ func NewSpool(logger logger, path string, settings Settings) (*Spool, error) {
 	if outFlushTimeout < minOutFlushTimeout {
 		outFlushTimeout = minOutFlushTimeout
 	}
-	outBroker, err := newOutBroker(outCtx, queue, outFlushTimeout)
+	outEnv, err := newEnvelope(settings.Compression, key)
+	if err != nil {
+		return nil, err
+	}
+	outBroker, err := newOutBroker(outCtx, queue, outEnv, outFlushTimeout)
 	if err != nil {
 		return nil, err
 	}
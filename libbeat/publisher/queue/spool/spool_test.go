@@ -74,13 +74,25 @@ func TestProduceConsumer(t *testing.T) {
 
 	testWith(makeTestQueue(
 		128*humanize.KiByte, 4*humanize.KiByte, 16*humanize.KiByte,
-		100*time.Millisecond,
+		100*time.Millisecond, compressionNone, "",
 	))(t)
+
+	t.Run("lz4", testWith(makeTestQueue(
+		128*humanize.KiByte, 4*humanize.KiByte, 16*humanize.KiByte,
+		100*time.Millisecond, compressionLZ4, "",
+	)))
+
+	t.Run("encrypted", testWith(makeTestQueue(
+		128*humanize.KiByte, 4*humanize.KiByte, 16*humanize.KiByte,
+		100*time.Millisecond, compressionLZ4, "0123456789abcdef",
+	)))
 }
 
 func makeTestQueue(
 	maxSize, pageSize, writeBuffer uint,
 	flushTimeout time.Duration,
+	compression compressionID,
+	secret string,
 ) func(*testing.T) queue.Queue {
 	return func(t *testing.T) queue.Queue {
 		if debug {
@@ -99,6 +111,8 @@ func makeTestQueue(
 			WriteBuffer:       writeBuffer,
 			WriteFlushTimeout: flushTimeout,
 			Codec:             codecCBORL,
+			Compression:       compression,
+			EncryptionSecret:  secret,
 			File: txfile.Options{
 				MaxSize:  uint64(maxSize),
 				PageSize: uint32(pageSize),
@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+type config struct {
+	SchemaFile     string   `config:"schema_file"`
+	IgnoreFields   []string `config:"ignore_fields"`
+	UnknownFields  policy   `config:"unknown_fields"`
+	TypeMismatch   policy   `config:"type_mismatch"`
+	Oversize       policy   `config:"oversize"`
+	MaxValueLength int      `config:"max_value_length" validate:"min=1"`
+	Tag            string   `config:"tag"`
+}
+
+// policy defines how an event violating the schema is handled.
+type policy uint8
+
+const (
+	policyIgnore policy = iota
+	policyTag
+	policyCoerce
+	policyTruncate
+	policyRemove
+	policyDrop
+)
+
+var policyNames = map[string]policy{
+	"ignore":   policyIgnore,
+	"tag":      policyTag,
+	"coerce":   policyCoerce,
+	"truncate": policyTruncate,
+	"remove":   policyRemove,
+	"drop":     policyDrop,
+}
+
+var defaultConfig = config{
+	UnknownFields: policyTag,
+	TypeMismatch:  policyTag,
+	Oversize:      policyTruncate,
+
+	// maximum length of a keyword term accepted by Elasticsearch
+	MaxValueLength: 32766,
+	Tag:            "_schema_violation",
+}
+
+func (p *policy) Unpack(s string) error {
+	v, exists := policyNames[strings.ToLower(s)]
+	if !exists {
+		return fmt.Errorf("unknown validation policy '%v'", s)
+	}
+	*p = v
+	return nil
+}
+
+func (c *config) Validate() error {
+	switch {
+	case c.UnknownFields == policyCoerce || c.UnknownFields == policyTruncate:
+		return fmt.Errorf("unknown_fields must be one of ignore, tag, remove or drop")
+	case c.TypeMismatch == policyTruncate:
+		return fmt.Errorf("type_mismatch must be one of ignore, tag, coerce, remove or drop")
+	case c.Oversize == policyCoerce:
+		return fmt.Errorf("oversize must be one of ignore, tag, truncate, remove or drop")
+	}
+	return nil
+}
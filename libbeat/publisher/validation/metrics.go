@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package validation
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// maxTrackedFields limits the number of fields violation counters are
+// reported for, as unknown fields can have an unbounded number of names.
+const maxTrackedFields = 1000
+
+// metrics reports the validation results in the registry:
+//
+//   - events.invalid: number of events with at least one violation
+//   - events.tagged, events.dropped: number of invalid events tagged or dropped
+//   - violations.<kind>: total number of violations per kind
+//   - fields.<field>.<kind>: number of violations per field (full mode only)
+type metrics struct {
+	invalid, tagged, dropped *monitoring.Uint
+	violations               [numViolationKinds]*monitoring.Uint
+	fields                   *fieldMetrics
+}
+
+type fieldMetrics struct {
+	mutex  sync.Mutex
+	counts map[string]*[numViolationKinds]uint64
+}
+
+func newMetrics(reg *monitoring.Registry) *metrics {
+	if reg == nil {
+		reg = monitoring.NewRegistry()
+	}
+
+	events := reg.NewRegistry("events")
+	violations := reg.NewRegistry("violations")
+	m := &metrics{
+		invalid: monitoring.NewUint(events, "invalid"),
+		tagged:  monitoring.NewUint(events, "tagged"),
+		dropped: monitoring.NewUint(events, "dropped"),
+		fields:  &fieldMetrics{counts: map[string]*[numViolationKinds]uint64{}},
+	}
+	for kind, name := range violationKindNames {
+		m.violations[kind] = monitoring.NewUint(violations, name)
+	}
+	reg.Add("fields", m.fields, monitoring.Full)
+	return m
+}
+
+func (m *metrics) add(v violation) {
+	m.violations[v.kind].Inc()
+	m.fields.add(v.path, v.kind)
+}
+
+func (f *fieldMetrics) add(path string, kind violationKind) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	counts := f.counts[path]
+	if counts == nil {
+		if len(f.counts) >= maxTrackedFields {
+			return
+		}
+		counts = &[numViolationKinds]uint64{}
+		f.counts[path] = counts
+	}
+	counts[kind]++
+}
+
+// Visit reports the counters of all fields with violations, sorted by the
+// field name.
+func (f *fieldMetrics) Visit(_ monitoring.Mode, vs monitoring.Visitor) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	paths := make([]string, 0, len(f.counts))
+	for path := range f.counts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	vs.OnRegistryStart()
+	defer vs.OnRegistryFinished()
+
+	for _, path := range paths {
+		counts := f.counts[path]
+		monitoring.ReportNamespace(vs, path, func() {
+			for kind, count := range counts {
+				if count > 0 {
+					vs.OnKey(violationKindNames[kind])
+					vs.OnInt(int64(count))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package validation
+
+import (
+	"strings"
+
+	"github.com/elastic/go-ucfg/yaml"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// schema is the tree of fields defined in a fields.yml file.
+type schema struct {
+	typ      string
+	children map[string]*schema
+
+	// dynamic is set if fields not defined in the schema are allowed below
+	// this node. If objectType is set, these fields must be of this type.
+	dynamic    bool
+	objectType string
+}
+
+func newNode(typ string) *schema {
+	return &schema{typ: typ, children: map[string]*schema{}}
+}
+
+// loadSchema parses a fields.yml file, with the fields being defined in
+// one or multiple top level keys.
+func loadSchema(data []byte) (*schema, error) {
+	var keys []common.Field
+
+	cfg, err := yaml.NewConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Unpack(&keys); err != nil {
+		return nil, err
+	}
+
+	root := newNode("group")
+	for _, key := range keys {
+		root.addFields(key.Fields)
+	}
+	return root, nil
+}
+
+func (s *schema) addFields(fields common.Fields) {
+	for _, field := range fields {
+		s.addField(field)
+	}
+}
+
+func (s *schema) addField(field common.Field) {
+	// names can contain dots, creating intermediate groups
+	names := strings.Split(field.Name, ".")
+	parent := s
+	for _, name := range names[:len(names)-1] {
+		parent = parent.child(name, "group")
+	}
+
+	typ := field.Type
+	if typ == "" {
+		typ = "keyword"
+	}
+
+	node := parent.child(names[len(names)-1], typ)
+	node.typ = typ
+	switch typ {
+	case "group", "nested":
+		if dynamic, ok := field.Dynamic.Value.(bool); ok && dynamic {
+			node.dynamic = true
+		}
+		node.addFields(field.Fields)
+	case "object":
+		node.dynamic = true
+		node.objectType = field.ObjectType
+	}
+}
+
+func (s *schema) child(name, typ string) *schema {
+	node, exists := s.children[name]
+	if !exists {
+		node = newNode(typ)
+		s.children[name] = node
+	}
+	return node
+}
+
+// lookup returns the node for the possibly dotted key below s. If the key is
+// not defined, but is below a dynamic node, the dynamic node is returned with
+// defined being false. Nil is returned for unknown keys.
+func (s *schema) lookup(key string) (node *schema, defined bool) {
+	node = s
+	for _, name := range strings.Split(key, ".") {
+		child := node.children[name]
+		if child == nil {
+			if node.dynamic {
+				return node, false
+			}
+			return nil, false
+		}
+		node = child
+	}
+	return node, true
+}
+
+// isObject returns true if the values of the field must be objects.
+func (s *schema) isObject() bool {
+	switch s.typ {
+	case "group", "object", "nested":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,425 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package validation validates events against the fields defined in a
+// fields.yml schema before the events are published. Events with unknown
+// fields, values of the wrong type or oversize values can be tagged, fixed or
+// dropped, to prevent mapping explosions and indexing errors.
+package validation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/libbeat/asset"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+type violationKind uint8
+
+const (
+	violationUnknown violationKind = iota
+	violationType
+	violationOversize
+	numViolationKinds
+)
+
+var violationKindNames = [numViolationKinds]string{"unknown", "type", "oversize"}
+
+// violation of the schema by the value of key in parent.
+type violation struct {
+	kind   violationKind
+	path   string
+	parent common.MapStr
+	key    string
+
+	// fixed is set if value is the coerced or truncated value, that can
+	// replace the original value.
+	fixed bool
+	value interface{}
+}
+
+type validator struct {
+	config  config
+	schema  *schema
+	ignore  map[string]bool
+	metrics *metrics
+}
+
+// New creates the validation processor from the validation settings. If no
+// schema_file is configured, the events are validated against the fields.yml
+// bundled with the beat. New returns nil if validation is not enabled.
+func New(cfg *common.Config, beatName string, reg *monitoring.Registry) (beat.Processor, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var err error
+	if config.SchemaFile != "" {
+		data, err = ioutil.ReadFile(config.SchemaFile)
+	} else {
+		data, err = asset.GetFields(beatName + "/fields.yml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation schema: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no fields.yml available for validation, configure schema_file")
+	}
+
+	schema, err := loadSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse validation schema: %v", err)
+	}
+
+	return newValidator(config, schema, reg), nil
+}
+
+func newValidator(config config, schema *schema, reg *monitoring.Registry) *validator {
+	ignore := map[string]bool{}
+	for _, field := range config.IgnoreFields {
+		ignore[field] = true
+	}
+
+	return &validator{
+		config:  config,
+		schema:  schema,
+		ignore:  ignore,
+		metrics: newMetrics(reg),
+	}
+}
+
+func (v *validator) String() string {
+	return "validation"
+}
+
+func (v *validator) Run(event *beat.Event) (*beat.Event, error) {
+	var violations []violation
+	v.validateMap("", event.Fields, v.schema, &violations)
+	if len(violations) == 0 {
+		return event, nil
+	}
+
+	v.metrics.invalid.Inc()
+	for _, violation := range violations {
+		v.metrics.add(violation)
+		if v.policy(violation.kind) == policyDrop {
+			v.metrics.dropped.Inc()
+			return nil, nil
+		}
+	}
+
+	tag := false
+	for _, violation := range violations {
+		switch v.policy(violation.kind) {
+		case policyTag:
+			tag = true
+		case policyCoerce, policyTruncate:
+			if violation.fixed {
+				violation.parent[violation.key] = violation.value
+			} else {
+				tag = true
+			}
+		case policyRemove:
+			delete(violation.parent, violation.key)
+		}
+	}
+
+	if tag {
+		v.metrics.tagged.Inc()
+		common.AddTags(event.Fields, []string{v.config.Tag})
+	}
+	return event, nil
+}
+
+func (v *validator) policy(kind violationKind) policy {
+	switch kind {
+	case violationUnknown:
+		return v.config.UnknownFields
+	case violationType:
+		return v.config.TypeMismatch
+	default:
+		return v.config.Oversize
+	}
+}
+
+func (v *validator) validateMap(path string, m common.MapStr, node *schema, out *[]violation) {
+	for key, value := range m {
+		fullPath := key
+		if path != "" {
+			fullPath = path + "." + key
+		}
+		if v.ignore[fullPath] {
+			continue
+		}
+
+		child, defined := node.lookup(key)
+		switch {
+		case child == nil:
+			*out = append(*out, violation{kind: violationUnknown, path: fullPath, parent: m, key: key})
+
+			// unknown fields are kept, check them for oversize values
+			if p := v.config.UnknownFields; p == policyIgnore || p == policyTag {
+				v.validateDynamic(fullPath, m, key, value, "", out)
+			}
+		case !defined:
+			v.validateDynamic(fullPath, m, key, value, child.objectType, out)
+		default:
+			v.validateField(fullPath, m, key, value, child, out)
+		}
+	}
+}
+
+func (v *validator) validateField(path string, parent common.MapStr, key string, value interface{}, node *schema, out *[]violation) {
+	if !node.isObject() {
+		if _, isMap := toMapStr(value); isMap && node.typ != "geo_point" {
+			*out = append(*out, violation{kind: violationType, path: path, parent: parent, key: key})
+			return
+		}
+		v.validateValue(path, parent, key, value, node.typ, out)
+		return
+	}
+
+	// objects or arrays of objects are required
+	values := []interface{}{value}
+	if isArray(value) {
+		values = toArray(value)
+	}
+	for _, elem := range values {
+		m, isMap := toMapStr(elem)
+		if !isMap {
+			*out = append(*out, violation{kind: violationType, path: path, parent: parent, key: key})
+			return
+		}
+		v.validateMap(path, m, node, out)
+	}
+}
+
+// validateDynamic validates a value below a dynamic object. Leaf values must
+// be of objectType, if set.
+func (v *validator) validateDynamic(path string, parent common.MapStr, key string, value interface{}, objectType string, out *[]violation) {
+	if m, isMap := toMapStr(value); isMap {
+		v.validateMap(path, m, &schema{dynamic: true, objectType: objectType}, out)
+		return
+	}
+	v.validateValue(path, parent, key, value, objectType, out)
+}
+
+func (v *validator) validateValue(path string, parent common.MapStr, key string, value interface{}, typ string, out *[]violation) {
+	if ok, converted, fixed := checkType(typ, value); !ok {
+		*out = append(*out, violation{
+			kind:   violationType,
+			path:   path,
+			parent: parent,
+			key:    key,
+			fixed:  fixed,
+			value:  converted,
+		})
+		if !fixed {
+			return
+		}
+		if v.config.TypeMismatch == policyCoerce {
+			value = converted
+		}
+	}
+
+	if truncated, oversize := truncate(value, v.config.MaxValueLength); oversize {
+		*out = append(*out, violation{
+			kind:   violationOversize,
+			path:   path,
+			parent: parent,
+			key:    key,
+			fixed:  true,
+			value:  truncated,
+		})
+	}
+}
+
+// checkType reports if value matches the field type. If not, the value
+// converted to the field type is returned, if the value can be converted.
+func checkType(typ string, value interface{}) (ok bool, converted interface{}, fixed bool) {
+	if isArray(value) {
+		values := toArray(value)
+		ok, fixed = true, true
+		convertedValues := make([]interface{}, len(values))
+		for i, elem := range values {
+			elemOK, elemConverted, elemFixed := checkType(typ, elem)
+			if elemOK {
+				convertedValues[i] = elem
+				continue
+			}
+			ok = false
+			fixed = fixed && elemFixed
+			convertedValues[i] = elemConverted
+		}
+		if ok || !fixed {
+			return ok, nil, false
+		}
+		return false, convertedValues, true
+	}
+
+	switch typ {
+	case "long", "integer", "short", "byte":
+		if isNumber(value) {
+			return true, nil, false
+		}
+		if s, isString := value.(string); isString {
+			if i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				return false, i, true
+			}
+		}
+		return false, nil, false
+
+	case "float", "double", "half_float", "scaled_float":
+		if isNumber(value) {
+			return true, nil, false
+		}
+		if s, isString := value.(string); isString {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return false, f, true
+			}
+		}
+		return false, nil, false
+
+	case "boolean":
+		if _, isBool := value.(bool); isBool {
+			return true, nil, false
+		}
+		if s, isString := value.(string); isString {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return false, b, true
+			}
+		}
+		return false, nil, false
+
+	case "date":
+		switch value.(type) {
+		case string, time.Time, common.Time:
+			return true, nil, false
+		}
+		return isNumber(value), nil, false
+
+	case "ip":
+		switch ip := value.(type) {
+		case net.IP:
+			return true, nil, false
+		case string:
+			return net.ParseIP(ip) != nil, nil, false
+		}
+		return false, nil, false
+
+	case "keyword", "text":
+		switch value.(type) {
+		case string, bool, time.Time, common.Time:
+			return true, nil, false
+		}
+		if isNumber(value) {
+			return true, nil, false
+		}
+		return false, fmt.Sprint(value), true
+	}
+
+	// other types are not validated
+	return true, nil, false
+}
+
+// truncate shortens strings longer than max bytes, keeping valid UTF-8.
+func truncate(value interface{}, max int) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= max {
+			return v, false
+		}
+		end := max
+		for end > 0 && !utf8.RuneStart(v[end]) {
+			end--
+		}
+		return v[:end], true
+	}
+
+	if !isArray(value) {
+		return value, false
+	}
+
+	values := toArray(value)
+	truncated := make([]interface{}, len(values))
+	oversize := false
+	for i, elem := range values {
+		var elemOversize bool
+		truncated[i], elemOversize = truncate(elem, max)
+		oversize = oversize || elemOversize
+	}
+	return truncated, oversize
+}
+
+func toMapStr(value interface{}) (common.MapStr, bool) {
+	switch m := value.(type) {
+	case common.MapStr:
+		return m, true
+	case map[string]interface{}:
+		return common.MapStr(m), true
+	}
+	return nil, false
+}
+
+// isArray returns true for slices and arrays, except for byte slices like
+// net.IP.
+func isArray(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+func toArray(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+
+	rv := reflect.ValueOf(value)
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+func isNumber(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,300 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package validation
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const testSchema = `
+- key: test
+  fields:
+    - name: message
+      type: text
+    - name: tags
+      type: keyword
+    - name: http
+      type: group
+      fields:
+        - name: status_code
+          type: long
+        - name: duration
+          type: float
+        - name: secure
+          type: boolean
+    - name: source.ip
+      type: ip
+    - name: labels
+      type: object
+      object_type: keyword
+    - name: process.args
+      type: keyword
+    - name: docker
+      type: group
+      dynamic: true
+`
+
+func newTestValidator(t *testing.T, settings map[string]interface{}) (*validator, *monitoring.Registry) {
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := loadSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := monitoring.NewRegistry()
+	return newValidator(config, schema, reg), reg
+}
+
+func TestLoadBeatFields(t *testing.T) {
+	data, err := ioutil.ReadFile("../../_meta/fields.common.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := loadSchema(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := newValidator(defaultConfig, schema, nil)
+	event, err := v.Run(&beat.Event{Fields: common.MapStr{
+		"beat": common.MapStr{"name": "host", "version": "7.0.0"},
+		"tags": []string{"a"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, event.Fields["tags"])
+}
+
+func TestValidEvents(t *testing.T) {
+	v, reg := newTestValidator(t, map[string]interface{}{})
+
+	events := []common.MapStr{
+		{"message": "hello"},
+		{"http": common.MapStr{"status_code": 200, "duration": 1.5, "secure": true}},
+		{"http.status_code": int64(404)},
+		{"source": common.MapStr{"ip": "10.0.0.1"}},
+		{"source": map[string]interface{}{"ip": net.ParseIP("::1")}},
+		{"labels": common.MapStr{"app": "web", "tier": common.MapStr{"name": "front"}}},
+		{"process": common.MapStr{"args": []string{"-v", "--debug"}}},
+		{"docker": common.MapStr{"container": common.MapStr{"id": "abc", "restarts": 3}}},
+	}
+
+	for _, fields := range events {
+		event, err := v.Run(&beat.Event{Fields: fields})
+		assert.NoError(t, err)
+		if assert.NotNil(t, event) {
+			assert.Nil(t, event.Fields["tags"], "event: %v", fields)
+		}
+	}
+
+	snapshot := monitoring.CollectStructSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(0), snapshot["events"].(map[string]interface{})["invalid"])
+}
+
+func TestViolationPolicies(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]interface{}
+		fields   common.MapStr
+		expected common.MapStr
+	}{
+		"tag unknown field": {
+			fields:   common.MapStr{"unknown": "value"},
+			expected: common.MapStr{"unknown": "value", "tags": []string{"_schema_violation"}},
+		},
+		"remove unknown field": {
+			settings: map[string]interface{}{"unknown_fields": "remove"},
+			fields:   common.MapStr{"message": "hello", "http": common.MapStr{"method": "GET"}},
+			expected: common.MapStr{"message": "hello", "http": common.MapStr{}},
+		},
+		"ignore unknown field": {
+			settings: map[string]interface{}{"unknown_fields": "ignore"},
+			fields:   common.MapStr{"unknown": "value"},
+			expected: common.MapStr{"unknown": "value"},
+		},
+		"ignored fields": {
+			settings: map[string]interface{}{"ignore_fields": []string{"fields"}},
+			fields:   common.MapStr{"fields": common.MapStr{"env": "prod"}},
+			expected: common.MapStr{"fields": common.MapStr{"env": "prod"}},
+		},
+		"tag type mismatch": {
+			fields:   common.MapStr{"http": common.MapStr{"status_code": "200"}},
+			expected: common.MapStr{"http": common.MapStr{"status_code": "200"}, "tags": []string{"_schema_violation"}},
+		},
+		"coerce type mismatch": {
+			settings: map[string]interface{}{"type_mismatch": "coerce"},
+			fields: common.MapStr{
+				"http":   common.MapStr{"status_code": "200", "duration": " 1.5", "secure": "true"},
+				"labels": common.MapStr{"count": 3},
+			},
+			expected: common.MapStr{
+				"http":   common.MapStr{"status_code": int64(200), "duration": 1.5, "secure": true},
+				"labels": common.MapStr{"count": 3},
+			},
+		},
+		"coerce not possible": {
+			settings: map[string]interface{}{"type_mismatch": "coerce"},
+			fields:   common.MapStr{"http": common.MapStr{"status_code": "OK"}},
+			expected: common.MapStr{"http": common.MapStr{"status_code": "OK"}, "tags": []string{"_schema_violation"}},
+		},
+		"object expected": {
+			settings: map[string]interface{}{"type_mismatch": "remove"},
+			fields:   common.MapStr{"http": "GET /", "message": common.MapStr{"text": "hello"}},
+			expected: common.MapStr{},
+		},
+		"invalid ip": {
+			settings: map[string]interface{}{"type_mismatch": "remove"},
+			fields:   common.MapStr{"source": common.MapStr{"ip": "localhost"}},
+			expected: common.MapStr{"source": common.MapStr{}},
+		},
+		"coerce array": {
+			settings: map[string]interface{}{"type_mismatch": "coerce"},
+			fields:   common.MapStr{"process": common.MapStr{"args": []interface{}{"-n", 1}}},
+			expected: common.MapStr{"process": common.MapStr{"args": []interface{}{"-n", 1}}},
+		},
+		"truncate oversize value": {
+			settings: map[string]interface{}{"max_value_length": 5},
+			fields:   common.MapStr{"message": "hello world", "labels": common.MapStr{"app": "käse"}},
+			expected: common.MapStr{"message": "hello", "labels": common.MapStr{"app": "käse"}},
+		},
+		"truncate at rune boundary": {
+			settings: map[string]interface{}{"max_value_length": 2},
+			fields:   common.MapStr{"message": "äb"},
+			expected: common.MapStr{"message": "ä"},
+		},
+		"truncate array values": {
+			settings: map[string]interface{}{"max_value_length": 3},
+			fields:   common.MapStr{"process": common.MapStr{"args": []string{"-v", "--debug"}}},
+			expected: common.MapStr{"process": common.MapStr{"args": []interface{}{"-v", "--d"}}},
+		},
+		"oversize unknown field": {
+			settings: map[string]interface{}{"max_value_length": 3, "unknown_fields": "ignore"},
+			fields:   common.MapStr{"unknown": common.MapStr{"key": "value"}},
+			expected: common.MapStr{"unknown": common.MapStr{"key": "val"}},
+		},
+		"tag oversize value": {
+			settings: map[string]interface{}{"max_value_length": 3, "oversize": "tag"},
+			fields:   common.MapStr{"message": "hello"},
+			expected: common.MapStr{"message": "hello", "tags": []string{"_schema_violation"}},
+		},
+		"custom tag": {
+			settings: map[string]interface{}{"tag": "invalid"},
+			fields:   common.MapStr{"unknown": "value", "tags": []string{"existing"}},
+			expected: common.MapStr{"unknown": "value", "tags": []string{"existing", "invalid"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := test.settings
+			if settings == nil {
+				settings = map[string]interface{}{}
+			}
+			v, _ := newTestValidator(t, settings)
+
+			event, err := v.Run(&beat.Event{Fields: test.fields})
+			assert.NoError(t, err)
+			if assert.NotNil(t, event) {
+				assert.Equal(t, test.expected, event.Fields)
+			}
+		})
+	}
+}
+
+func TestDropPolicy(t *testing.T) {
+	v, reg := newTestValidator(t, map[string]interface{}{"unknown_fields": "drop"})
+
+	event, err := v.Run(&beat.Event{Fields: common.MapStr{"message": "hello"}})
+	assert.NoError(t, err)
+	assert.NotNil(t, event)
+
+	event, err = v.Run(&beat.Event{Fields: common.MapStr{"message": "hello", "unknown": 1}})
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+
+	snapshot := monitoring.CollectStructSnapshot(reg, monitoring.Full, false)
+	events := snapshot["events"].(map[string]interface{})
+	assert.Equal(t, int64(1), events["invalid"])
+	assert.Equal(t, int64(1), events["dropped"])
+}
+
+func TestFieldMetrics(t *testing.T) {
+	v, reg := newTestValidator(t, map[string]interface{}{"max_value_length": 3})
+
+	v.Run(&beat.Event{Fields: common.MapStr{"unknown": 1, "http": common.MapStr{"status_code": "200"}}})
+	v.Run(&beat.Event{Fields: common.MapStr{"unknown": 1, "message": "hello"}})
+
+	snapshot := monitoring.CollectStructSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, map[string]interface{}{
+		"unknown":  int64(2),
+		"type":     int64(1),
+		"oversize": int64(1),
+	}, snapshot["violations"])
+	assert.Equal(t, map[string]interface{}{
+		"unknown":          map[string]interface{}{"unknown": int64(2)},
+		"http.status_code": map[string]interface{}{"type": int64(1)},
+		"message":          map[string]interface{}{"oversize": int64(1)},
+	}, snapshot["fields"])
+
+	// per field metrics are not reported in reported mode
+	snapshot = monitoring.CollectStructSnapshot(reg, monitoring.Reported, false)
+	assert.Nil(t, snapshot["fields"])
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"unknown_fields": "coerce"},
+		{"type_mismatch": "truncate"},
+		{"oversize": "coerce"},
+		{"oversize": "fix"},
+	}
+
+	for _, settings := range tests {
+		cfg, err := common.NewConfigFrom(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := defaultConfig
+		assert.Error(t, cfg.Unpack(&config), "settings: %v", settings)
+	}
+}
+
+func TestLongValues(t *testing.T) {
+	v, _ := newTestValidator(t, map[string]interface{}{})
+
+	event, _ := v.Run(&beat.Event{Fields: common.MapStr{"message": strings.Repeat("a", 40000)}})
+	assert.Len(t, event.Fields["message"], 32766)
+}
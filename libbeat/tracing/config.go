@@ -0,0 +1,68 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+)
+
+type config struct {
+	SamplingRate float64    `config:"sampling_rate"`
+	OTLP         otlpConfig `config:"otlp"`
+
+	QueueSize     int           `config:"queue.size"     validate:"min=1"`
+	BatchSize     int           `config:"batch.size"     validate:"min=1"`
+	FlushInterval time.Duration `config:"flush.interval" validate:"positive,nonzero"`
+}
+
+type otlpConfig struct {
+	Endpoint string            `config:"endpoint"`
+	Headers  map[string]string `config:"headers"`
+	TLS      *tlscommon.Config `config:"ssl"`
+	Timeout  time.Duration     `config:"timeout" validate:"positive,nonzero"`
+}
+
+var defaultConfig = config{
+	SamplingRate: 0.01,
+	OTLP: otlpConfig{
+		Endpoint: "http://localhost:4318",
+		Timeout:  10 * time.Second,
+	},
+	QueueSize:     2048,
+	BatchSize:     512,
+	FlushInterval: 5 * time.Second,
+}
+
+func (c *config) Validate() error {
+	if c.SamplingRate < 0 || c.SamplingRate > 1 {
+		return errors.New("sampling_rate must be between 0 and 1")
+	}
+
+	u, err := url.Parse(c.OTLP.Endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("otlp.endpoint must be a http or https URL")
+	}
+	return nil
+}
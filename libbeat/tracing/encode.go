@@ -0,0 +1,147 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import "github.com/elastic/beats/libbeat/common/protowire"
+
+// Field numbers of the OTLP trace messages.
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+const (
+	exportRequestResourceSpans = 1
+
+	resourceSpansResource   = 1
+	resourceSpansScopeSpans = 2
+
+	resourceAttributes = 1
+
+	scopeSpansScope = 1
+	scopeSpansSpans = 2
+
+	scopeName    = 1
+	scopeVersion = 2
+
+	spanTraceID      = 1
+	spanSpanID       = 2
+	spanParentSpanID = 4
+	spanName         = 5
+	spanKind         = 6
+	spanStartTime    = 7
+	spanEndTime      = 8
+	spanAttributes   = 9
+	spanEvents       = 11
+	spanStatus       = 15
+
+	eventTime = 1
+	eventName = 2
+
+	statusMessage = 2
+	statusCode    = 3
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+)
+
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+
+	scopeNameBeats = "github.com/elastic/beats/libbeat/tracing"
+)
+
+func encodeTracesRequest(b *protowire.Buffer, res Resource, spans []*Span) {
+	b.MessageField(exportRequestResourceSpans, func(b *protowire.Buffer) {
+		b.MessageField(resourceSpansResource, func(b *protowire.Buffer) {
+			encodeAttributes(b, resourceAttributes, []attribute{
+				{"service.name", res.ServiceName},
+				{"service.version", res.ServiceVersion},
+				{"service.instance.id", res.InstanceID},
+				{"host.name", res.HostName},
+			})
+		})
+		b.MessageField(resourceSpansScopeSpans, func(b *protowire.Buffer) {
+			b.MessageField(scopeSpansScope, func(b *protowire.Buffer) {
+				b.StringField(scopeName, scopeNameBeats)
+				b.StringField(scopeVersion, res.ServiceVersion)
+			})
+			for _, s := range spans {
+				b.MessageField(scopeSpansSpans, func(b *protowire.Buffer) {
+					encodeSpan(b, s)
+				})
+			}
+		})
+	})
+}
+
+func encodeSpan(b *protowire.Buffer, s *Span) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b.BytesField(spanTraceID, s.traceID[:])
+	b.BytesField(spanSpanID, s.spanID[:])
+	if !s.parentID.IsZero() {
+		b.BytesField(spanParentSpanID, s.parentID[:])
+	}
+	b.StringField(spanName, s.name)
+	b.VarintField(spanKind, spanKindInternal)
+	b.Fixed64Field(spanStartTime, uint64(s.start.UnixNano()))
+	b.Fixed64Field(spanEndTime, uint64(s.end.UnixNano()))
+	encodeAttributes(b, spanAttributes, s.attrs)
+
+	for _, event := range s.events {
+		b.MessageField(spanEvents, func(b *protowire.Buffer) {
+			b.Fixed64Field(eventTime, uint64(event.time.UnixNano()))
+			b.StringField(eventName, event.name)
+		})
+	}
+
+	if s.err != "" {
+		b.MessageField(spanStatus, func(b *protowire.Buffer) {
+			b.StringField(statusMessage, s.err)
+			b.VarintField(statusCode, statusCodeError)
+		})
+	}
+}
+
+func encodeAttributes(b *protowire.Buffer, field int, attrs []attribute) {
+	for _, attr := range attrs {
+		if s, ok := attr.value.(string); ok && s == "" {
+			continue
+		}
+
+		b.MessageField(field, func(b *protowire.Buffer) {
+			b.StringField(keyValueKey, attr.key)
+			b.MessageField(keyValueValue, func(b *protowire.Buffer) {
+				switch v := attr.value.(type) {
+				case string:
+					b.StringField(anyValueString, v)
+				case bool:
+					b.BoolField(anyValueBool, v)
+				case int64:
+					b.VarintField(anyValueInt, uint64(v))
+				case float64:
+					b.DoubleField(anyValueDouble, v)
+				}
+			})
+		})
+	}
+}
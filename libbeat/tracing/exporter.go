@@ -0,0 +1,190 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// exporter batches ended spans and exports them to an OTLP/HTTP endpoint.
+// Spans are dropped if the exporter queue is full, so tracing never blocks
+// event processing.
+type exporter struct {
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	resource Resource
+
+	batchSize     int
+	flushInterval time.Duration
+
+	spans chan *Span
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	buf protowire.Buffer
+
+	exported, dropped, failed *monitoring.Uint
+}
+
+func newExporter(res Resource, config config) (*exporter, error) {
+	tls, err := tlscommon.LoadTLSConfig(config.OTLP.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(config.OTLP.Endpoint, "/")
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint '%v': %v", endpoint, err)
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if tls != nil {
+		transport.TLSClientConfig = tls.BuildModuleConfig(u.Hostname())
+	}
+
+	e := &exporter{
+		url:           endpoint + "/v1/traces",
+		headers:       config.OTLP.Headers,
+		client:        &http.Client{Transport: transport, Timeout: config.OTLP.Timeout},
+		resource:      res,
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		spans:         make(chan *Span, config.QueueSize),
+		done:          make(chan struct{}),
+	}
+
+	reg := monitoring.Default.GetRegistry("libbeat.tracing")
+	if reg == nil {
+		reg = monitoring.Default.NewRegistry("libbeat.tracing")
+		e.exported = monitoring.NewUint(reg, "spans.exported")
+		e.dropped = monitoring.NewUint(reg, "spans.dropped")
+		e.failed = monitoring.NewUint(reg, "spans.failed")
+	} else {
+		e.exported = reg.Get("spans.exported").(*monitoring.Uint)
+		e.dropped = reg.Get("spans.dropped").(*monitoring.Uint)
+		e.failed = reg.Get("spans.failed").(*monitoring.Uint)
+	}
+
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		e.dropped.Inc()
+	}
+}
+
+func (e *exporter) stop() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+func (e *exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			logp.Err("Failed to export %v spans: %v", len(batch), err)
+			e.failed.Add(uint64(len(batch)))
+		} else {
+			e.exported.Add(uint64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-e.done:
+			// drain the queue and send all pending spans before returning
+			for {
+				select {
+				case s := <-e.spans:
+					batch = append(batch, s)
+					if len(batch) == e.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) == e.batchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	e.buf.Reset()
+	encodeTracesRequest(&e.buf, e.resource, spans)
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(e.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector responded with %v: %s", resp.Status, msg)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,184 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+type collector struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+	spans    []map[int][]byte
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, req)
+
+	// ExportTraceServiceRequest -> ResourceSpans -> ScopeSpans -> Span
+	for _, resourceSpans := range fields(body)[exportRequestResourceSpans] {
+		for _, scopeSpans := range fields(resourceSpans)[resourceSpansScopeSpans] {
+			for _, span := range fields(scopeSpans)[scopeSpansSpans] {
+				m := map[int][]byte{}
+				for field, values := range fields(span) {
+					m[field] = values[0]
+				}
+				c.spans = append(c.spans, m)
+			}
+		}
+	}
+}
+
+// fields returns the length delimited fields of a message
+func fields(msg []byte) map[int][][]byte {
+	m := map[int][][]byte{}
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, _, _, data, err := r.Next()
+		if err != nil {
+			return m
+		}
+		m[field] = append(m[field], data)
+	}
+	return m
+}
+
+func TestExporter(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"sampling_rate":  1,
+		"otlp.endpoint":  server.URL,
+		"otlp.headers":   map[string]string{"Authorization": "Bearer secret"},
+		"flush.interval": "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer, err := New(Resource{ServiceName: "testbeat", ServiceVersion: "7.0.0"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := tracer.StartEvent("publish")
+	event.Stage("processors")
+	event.Finish(nil)
+
+	// spans are flushed on stop
+	tracer.Stop()
+
+	if !assert.Len(t, c.requests, 1) {
+		return
+	}
+	req := c.requests[0]
+	assert.Equal(t, "/v1/traces", req.URL.Path)
+	assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+	if !assert.Len(t, c.spans, 2) {
+		return
+	}
+	assert.Equal(t, "processors", string(c.spans[0][spanName]))
+	assert.Equal(t, "publish", string(c.spans[1][spanName]))
+	assert.Equal(t, c.spans[1][spanSpanID], c.spans[0][spanParentSpanID])
+	assert.Equal(t, c.spans[1][spanTraceID], c.spans[0][spanTraceID])
+	assert.Nil(t, c.spans[1][spanParentSpanID])
+}
+
+func TestExporterTLS(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewTLSServer(c)
+	defer server.Close()
+
+	ca, err := ioutil.TempFile("", "tracing-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(ca.Name())
+	pem.Encode(ca, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	ca.Close()
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"sampling_rate":                    1,
+		"otlp.endpoint":                    server.URL,
+		"otlp.ssl.certificate_authorities": []string{ca.Name()},
+		"otlp.ssl.verification_mode":       "full",
+		"flush.interval":                   "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer, err := New(Resource{ServiceName: "testbeat"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer.StartEvent("publish").Finish(nil)
+	tracer.Stop()
+
+	// the server certificate is verified against the endpoint host
+	assert.Len(t, c.requests, 1)
+}
+
+func TestExporterDropsIfQueueFull(t *testing.T) {
+	e := &exporter{spans: make(chan *Span, 1), dropped: &monitoring.Uint{}}
+
+	e.enqueue(&Span{})
+	e.enqueue(&Span{})
+	assert.Equal(t, uint64(1), e.dropped.Get())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"invalid sampling rate": {"sampling_rate": 2},
+		"invalid endpoint":      {"otlp.endpoint": "localhost:4318"},
+		"invalid flush":         {"flush.interval": 0},
+	}
+
+	for name, settings := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigFrom(settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config := defaultConfig
+			assert.Error(t, cfg.Unpack(&config))
+		})
+	}
+}
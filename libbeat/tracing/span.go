@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoded trace ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// String returns the hex encoded span ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsZero returns true if the span ID is not set.
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+// Span records a single operation of a trace. All methods are safe to be
+// called on a nil Span, such that callers do not need to check if an event has
+// been sampled.
+//
+// Event spans, created via StartEvent, track the path of a single event
+// through the beat. The processing stages of the event (e.g. processors,
+// queue, output) are reported as child spans of the event span. At most one
+// stage is active at a time; starting a new stage ends the current one.
+type Span struct {
+	tracer *Tracer
+
+	traceID  TraceID
+	spanID   SpanID
+	parentID SpanID
+	name     string
+	start    time.Time
+
+	mutex  sync.Mutex
+	end    time.Time
+	attrs  []attribute
+	events []spanEvent
+	err    string
+	stage  *Span
+	ended  bool
+}
+
+type attribute struct {
+	key   string
+	value interface{} // one of string, bool, int64 or float64
+}
+
+type spanEvent struct {
+	name string
+	time time.Time
+}
+
+// TraceID returns the trace ID of the span.
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.traceID
+}
+
+// SpanID returns the span ID.
+func (s *Span) SpanID() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.spanID
+}
+
+// SetAttribute adds an attribute to the span. Supported value types are
+// string, bool, int, int64 and float64.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case string, bool, int64, float64:
+	default:
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.attrs {
+		if s.attrs[i].key == key {
+			s.attrs[i].value = value
+			return
+		}
+	}
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// AddEvent records a named event at the current time.
+func (s *Span) AddEvent(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, spanEvent{name: name, time: time.Now()})
+}
+
+// StartChild starts a new span, having s as parent.
+func (s *Span) StartChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(name, s.traceID, s.spanID)
+}
+
+// Stage ends the currently active stage and starts a new stage as child of
+// the event span.
+func (s *Span) Stage(name string) *Span {
+	if s == nil {
+		return nil
+	}
+
+	child := s.StartChild(name)
+
+	s.mutex.Lock()
+	prev := s.stage
+	s.stage = child
+	s.mutex.Unlock()
+
+	prev.End()
+	return child
+}
+
+// EndStage ends the currently active stage, marking the stage as failed if
+// err is not nil.
+func (s *Span) EndStage(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	stage := s.stage
+	s.stage = nil
+	s.mutex.Unlock()
+
+	stage.EndWithError(err)
+}
+
+// Finish ends the active stage and the event span itself.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+
+	s.EndStage(err)
+	s.EndWithError(err)
+}
+
+// End ends the span and passes it to the exporter. Calling End multiple
+// times has no effect.
+func (s *Span) End() {
+	s.EndWithError(nil)
+}
+
+// EndWithError ends the span, marking the span as failed if err is not nil.
+func (s *Span) EndWithError(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.mutex.Unlock()
+
+	s.tracer.export(s)
+}
+
+// TraceParent returns the W3C trace context `traceparent` header value
+// referencing the active stage, or the span itself if no stage is active.
+// See https://www.w3.org/TR/trace-context/#traceparent-header.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+
+	s.mutex.Lock()
+	id := s.spanID
+	if s.stage != nil {
+		id = s.stage.spanID
+	}
+	s.mutex.Unlock()
+
+	return "00-" + s.traceID.String() + "-" + id.String() + "-01"
+}
@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package tracing traces events through the publishing path of a beat and
+// exports the spans to an OpenTelemetry collector using OTLP/HTTP.
+//
+// Each sampled event gets an event span, which ends once the event has been
+// acknowledged by the output. The processing stages of the event are reported
+// as child spans of the event span.
+package tracing
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// Resource describes the beat reporting spans.
+type Resource struct {
+	ServiceName    string
+	ServiceVersion string
+	InstanceID     string
+	HostName       string
+}
+
+// Tracer creates and exports spans.
+type Tracer struct {
+	samplingRate float64
+
+	randMutex sync.Mutex
+	rand      *rand.Rand
+
+	exporter spanExporter
+}
+
+type spanExporter interface {
+	enqueue(s *Span)
+	stop()
+}
+
+// global holds the globally installed *Tracer
+var global atomic.Value
+
+func init() {
+	global.Store((*Tracer)(nil))
+}
+
+func getGlobal() *Tracer {
+	return global.Load().(*Tracer)
+}
+
+// New creates a new tracer, exporting spans to the configured OTLP endpoint.
+func New(res Resource, cfg *common.Config) (*Tracer, error) {
+	config := defaultConfig
+	if cfg != nil {
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	exporter, err := newExporter(res, config)
+	if err != nil {
+		return nil, err
+	}
+
+	logp.Info("Tracing enabled with sampling rate %v, exporting spans to %v",
+		config.SamplingRate, exporter.url)
+	return newTracer(config.SamplingRate, exporter), nil
+}
+
+func newTracer(samplingRate float64, exporter spanExporter) *Tracer {
+	return &Tracer{
+		samplingRate: samplingRate,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		exporter:     exporter,
+	}
+}
+
+// SetGlobal installs t as the global tracer used by StartEvent. SetGlobal must
+// be called before the beat starts publishing events.
+func SetGlobal(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled returns true if a global tracer is installed.
+func Enabled() bool {
+	return getGlobal() != nil
+}
+
+// StartEvent starts a new event span using the global tracer. Returns nil if
+// tracing is disabled or the event is not sampled.
+func StartEvent(name string) *Span {
+	return getGlobal().StartEvent(name)
+}
+
+// StartEvent starts a new trace for a single event. Returns nil if the event
+// is not sampled.
+func (t *Tracer) StartEvent(name string) *Span {
+	if t == nil || !t.sample() {
+		return nil
+	}
+
+	var traceID TraceID
+	t.randMutex.Lock()
+	t.rand.Read(traceID[:])
+	t.randMutex.Unlock()
+	return t.newSpan(name, traceID, SpanID{})
+}
+
+// Stop flushes all pending spans and stops the exporter.
+func (t *Tracer) Stop() {
+	if getGlobal() == t {
+		SetGlobal(nil)
+	}
+	t.exporter.stop()
+}
+
+func (t *Tracer) sample() bool {
+	switch {
+	case t.samplingRate <= 0:
+		return false
+	case t.samplingRate >= 1:
+		return true
+	}
+
+	t.randMutex.Lock()
+	defer t.randMutex.Unlock()
+	return t.rand.Float64() < t.samplingRate
+}
+
+func (t *Tracer) newSpan(name string, traceID TraceID, parent SpanID) *Span {
+	s := &Span{
+		tracer:   t,
+		traceID:  traceID,
+		parentID: parent,
+		name:     name,
+		start:    time.Now(),
+	}
+
+	t.randMutex.Lock()
+	t.rand.Read(s.spanID[:])
+	t.randMutex.Unlock()
+	return s
+}
+
+func (t *Tracer) export(s *Span) {
+	t.exporter.enqueue(s)
+}
@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tracing
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) enqueue(s *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) stop() {}
+
+func (e *recordingExporter) names() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var names []string
+	for _, s := range e.spans {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestEventStages(t *testing.T) {
+	exp := &recordingExporter{}
+	tracer := newTracer(1, exp)
+
+	event := tracer.StartEvent("publish")
+	if event == nil {
+		t.Fatal("event not sampled")
+	}
+	event.SetAttribute("input", "log")
+	event.Stage("processors")
+	queue := event.Stage("queue")
+	assert.Equal(t, []string{"processors"}, exp.names())
+
+	event.Stage("output")
+	event.Finish(errors.New("dropped"))
+	assert.Equal(t, []string{"processors", "queue", "output", "publish"}, exp.names())
+
+	// all stages belong to the event trace
+	for _, s := range exp.spans[:3] {
+		assert.Equal(t, event.TraceID(), s.traceID)
+		assert.Equal(t, event.SpanID(), s.parentID)
+	}
+	assert.True(t, event.parentID.IsZero())
+	assert.Equal(t, "dropped", exp.spans[2].err)
+	assert.Equal(t, "dropped", event.err)
+	assert.Equal(t, "", queue.err)
+
+	// ending a span again has no effect
+	event.End()
+	assert.Len(t, exp.spans, 4)
+}
+
+func TestTraceParent(t *testing.T) {
+	tracer := newTracer(1, &recordingExporter{})
+	event := tracer.StartEvent("publish")
+
+	parts := strings.Split(event.TraceParent(), "-")
+	if !assert.Len(t, parts, 4) {
+		return
+	}
+	assert.Equal(t, "00", parts[0])
+	assert.Equal(t, event.TraceID().String(), parts[1])
+	assert.Equal(t, event.SpanID().String(), parts[2])
+	assert.Equal(t, "01", parts[3])
+
+	stage := event.Stage("output")
+	assert.Contains(t, event.TraceParent(), stage.SpanID().String())
+}
+
+func TestSampling(t *testing.T) {
+	exp := &recordingExporter{}
+	assert.Nil(t, newTracer(0, exp).StartEvent("publish"))
+
+	tracer := newTracer(0.5, exp)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if tracer.StartEvent("publish") != nil {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 5000, sampled, 500)
+}
+
+func TestNilSpan(t *testing.T) {
+	var s *Span
+	s.SetAttribute("key", "value")
+	s.AddEvent("retry")
+	assert.Nil(t, s.Stage("queue"))
+	s.EndStage(nil)
+	s.Finish(nil)
+	assert.Equal(t, "", s.TraceParent())
+}
+
+func TestGlobalTracer(t *testing.T) {
+	assert.False(t, Enabled())
+	assert.Nil(t, StartEvent("publish"))
+
+	tracer := newTracer(1, &recordingExporter{})
+	SetGlobal(tracer)
+	assert.True(t, Enabled())
+	assert.NotNil(t, StartEvent("publish"))
+
+	tracer.Stop()
+	assert.False(t, Enabled())
+}
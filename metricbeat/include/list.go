@@ -74,6 +74,7 @@ import (
 	_ "github.com/elastic/beats/metricbeat/module/haproxy/stat"
 	_ "github.com/elastic/beats/metricbeat/module/http"
 	_ "github.com/elastic/beats/metricbeat/module/http/json"
+	_ "github.com/elastic/beats/metricbeat/module/http/jsonpath"
 	_ "github.com/elastic/beats/metricbeat/module/http/server"
 	_ "github.com/elastic/beats/metricbeat/module/jolokia"
 	_ "github.com/elastic/beats/metricbeat/module/jolokia/jmx"
@@ -125,6 +126,7 @@ import (
 	_ "github.com/elastic/beats/metricbeat/module/postgresql/statement"
 	_ "github.com/elastic/beats/metricbeat/module/prometheus"
 	_ "github.com/elastic/beats/metricbeat/module/prometheus/collector"
+	_ "github.com/elastic/beats/metricbeat/module/prometheus/remote_write"
 	_ "github.com/elastic/beats/metricbeat/module/prometheus/stats"
 	_ "github.com/elastic/beats/metricbeat/module/rabbitmq"
 	_ "github.com/elastic/beats/metricbeat/module/rabbitmq/connection"
@@ -134,6 +136,8 @@ import (
 	_ "github.com/elastic/beats/metricbeat/module/redis"
 	_ "github.com/elastic/beats/metricbeat/module/redis/info"
 	_ "github.com/elastic/beats/metricbeat/module/redis/keyspace"
+	_ "github.com/elastic/beats/metricbeat/module/statsd"
+	_ "github.com/elastic/beats/metricbeat/module/statsd/server"
 	_ "github.com/elastic/beats/metricbeat/module/system"
 	_ "github.com/elastic/beats/metricbeat/module/system/core"
 	_ "github.com/elastic/beats/metricbeat/module/system/cpu"
@@ -18,7 +18,10 @@
 package module
 
 import (
+	"fmt"
+
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/processors"
 )
@@ -27,12 +30,14 @@ import (
 // to the publisher pipeline.
 type Connector struct {
 	pipeline      beat.Pipeline
+	name          string
 	processors    *processors.Processors
 	eventMeta     common.EventMetadata
 	dynamicFields *common.MapStrPointer
 }
 
 type connectorConfig struct {
+	Module               string                  `config:"module"`
 	Processors           processors.PluginConfig `config:"processors"`
 	common.EventMetadata `config:",inline"`      // Fields and tags to add to events.
 }
@@ -43,6 +48,13 @@ func NewConnector(pipeline beat.Pipeline, c *common.Config, dynFields *common.Ma
 		return nil, err
 	}
 
+	// The module is reported in the pipeline metrics by its name and a hash of
+	// its configuration, so the name stays the same when the module is restarted.
+	hash, err := cfgfile.HashConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
 	processors, err := processors.New(config.Processors)
 	if err != nil {
 		return nil, err
@@ -50,6 +62,7 @@ func NewConnector(pipeline beat.Pipeline, c *common.Config, dynFields *common.Ma
 
 	return &Connector{
 		pipeline:      pipeline,
+		name:          fmt.Sprintf("%v-%x", config.Module, hash),
 		processors:    processors,
 		eventMeta:     config.EventMetadata,
 		dynamicFields: dynFields,
@@ -58,8 +71,15 @@ func NewConnector(pipeline beat.Pipeline, c *common.Config, dynFields *common.Ma
 
 func (c *Connector) Connect() (beat.Client, error) {
 	return c.pipeline.ConnectWith(beat.ClientConfig{
+		Name:          c.name,
 		EventMetadata: c.eventMeta,
 		Processor:     c.processors,
 		DynamicFields: c.dynamicFields,
 	})
 }
+
+// Close closes the processors of the connector. Clients connected with the
+// connector must not be used anymore afterwards.
+func (c *Connector) Close() error {
+	return c.processors.Close()
+}
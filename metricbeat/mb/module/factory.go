@@ -58,14 +58,31 @@ func (r *Factory) Create(p beat.Pipeline, c *common.Config, meta *common.MapStrP
 	}
 
 	if err := errs.Err(); err != nil {
+		if connector != nil {
+			connector.Close()
+		}
 		return nil, err
 	}
 
 	client, err := connector.Connect()
 	if err != nil {
+		connector.Close()
 		return nil, err
 	}
 
-	mr := NewRunner(client, w)
+	mr := NewRunner(&connectorClient{client, connector}, w)
 	return mr, nil
 }
+
+// connectorClient closes the connector together with the client, so the
+// processors of a runner are released when the runner is stopped.
+type connectorClient struct {
+	beat.Client
+	connector *Connector
+}
+
+func (c *connectorClient) Close() error {
+	err := c.Client.Close()
+	c.connector.Close()
+	return err
+}
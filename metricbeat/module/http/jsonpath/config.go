@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	typeAuto    = ""
+	typeLong    = "long"
+	typeDouble  = "double"
+	typeBoolean = "boolean"
+	typeKeyword = "keyword"
+)
+
+type config struct {
+	Namespace string        `config:"namespace" validate:"required"`
+	Method    string        `config:"method"`
+	Body      string        `config:"body"`
+	Root      string        `config:"root"`
+	Fields    []fieldConfig `config:"fields" validate:"required"`
+	Labels    []fieldConfig `config:"labels"`
+}
+
+// fieldConfig maps the values found at Path to the field Name, converting
+// them to the given type.
+type fieldConfig struct {
+	Name string `config:"name" validate:"required"`
+	Path string `config:"path" validate:"required"`
+	Type string `config:"type"`
+}
+
+var defaultConfig = config{
+	Method: "GET",
+	Root:   "$",
+}
+
+func (c *fieldConfig) Validate() error {
+	if _, err := compilePath(c.Path); err != nil {
+		return err
+	}
+	switch c.Type {
+	case typeAuto, typeLong, typeDouble, typeBoolean, typeKeyword:
+		return nil
+	default:
+		return fmt.Errorf("invalid type '%s' for field '%s', must be one of %s, %s, %s or %s",
+			c.Type, c.Name, typeLong, typeDouble, typeBoolean, typeKeyword)
+	}
+}
+
+func (c *config) Validate() error {
+	_, err := compilePath(c.Root)
+	return err
+}
+
+// convert converts a value decoded with json.Number support to the given type.
+func convert(v interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case typeLong:
+		return toLong(v)
+	case typeDouble:
+		return toDouble(v)
+	case typeBoolean:
+		return toBoolean(v)
+	case typeKeyword:
+		return toKeyword(v)
+	default:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			return n.Float64()
+		}
+		return v, nil
+	}
+}
+
+func toLong(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		return int64(f), err
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", v, typeLong)
+}
+
+func toDouble(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", v, typeDouble)
+}
+
+func toBoolean(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	case json.Number:
+		f, err := v.Float64()
+		return f != 0, err
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", v, typeBoolean)
+}
+
+func toKeyword(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", v, typeKeyword)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/joeshaw/multierror"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/metricbeat/helper"
+	"github.com/elastic/beats/metricbeat/mb"
+	"github.com/elastic/beats/metricbeat/mb/parse"
+)
+
+// init registers the MetricSet with the central registry.
+// The New method will be called after the setup of the module and before starting to fetch data
+func init() {
+	mb.Registry.MustAddMetricSet("http", "jsonpath", New,
+		mb.WithHostParser(hostParser),
+	)
+}
+
+var (
+	hostParser = parse.URLHostParserBuilder{
+		DefaultScheme: "http",
+		PathConfigKey: "path",
+	}.Build()
+)
+
+// MetricSet fetches a JSON document and maps the values selected by path
+// expressions to the event fields.
+type MetricSet struct {
+	mb.BaseMetricSet
+	http      *helper.HTTP
+	namespace string
+	root      path
+	fields    []field
+	labels    []field
+}
+
+type field struct {
+	name string
+	path path
+	typ  string
+}
+
+// New create a new instance of the MetricSet
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The http jsonpath metricset is beta")
+
+	config := defaultConfig
+	if err := base.Module().UnpackConfig(&config); err != nil {
+		return nil, err
+	}
+
+	root, err := compilePath(config.Root)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := compileFields(config.Fields)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := compileFields(config.Labels)
+	if err != nil {
+		return nil, err
+	}
+	for i := range labels {
+		labels[i].typ = typeKeyword
+	}
+
+	http, err := helper.NewHTTP(base)
+	if err != nil {
+		return nil, err
+	}
+	http.SetMethod(config.Method)
+	http.SetBody([]byte(config.Body))
+
+	return &MetricSet{
+		BaseMetricSet: base,
+		http:          http,
+		namespace:     config.Namespace,
+		root:          root,
+		fields:        fields,
+		labels:        labels,
+	}, nil
+}
+
+func compileFields(configs []fieldConfig) ([]field, error) {
+	fields := make([]field, len(configs))
+	for i, c := range configs {
+		p, err := compilePath(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field{name: c.Name, path: p, typ: c.Type}
+	}
+	return fields, nil
+}
+
+// Fetch fetches the JSON document and reports one event per value selected by
+// the root path.
+func (m *MetricSet) Fetch(r mb.ReporterV2) {
+	content, err := m.http.FetchContent()
+	if err != nil {
+		r.Error(err)
+		return
+	}
+
+	events, err := m.eventsMapping(content)
+	if err != nil {
+		r.Error(err)
+	}
+	for _, event := range events {
+		r.Event(event)
+	}
+}
+
+func (m *MetricSet) eventsMapping(content []byte) ([]mb.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response: %v", err)
+	}
+
+	var errs multierror.Errors
+	var events []mb.Event
+	for _, obj := range m.root.find(doc) {
+		metrics, err := mapFields(obj, m.fields)
+		errs = append(errs, err...)
+		if len(metrics) == 0 {
+			continue
+		}
+
+		event := mb.Event{
+			Namespace:       "http." + m.namespace,
+			MetricSetFields: metrics,
+		}
+		labels, err := mapFields(obj, m.labels)
+		errs = append(errs, err...)
+		if len(labels) > 0 {
+			event.ModuleFields = common.MapStr{"labels": labels}
+		}
+		events = append(events, event)
+	}
+	return events, errs.Err()
+}
+
+// mapFields returns the converted values of all fields found in the object.
+// Paths with wildcards are mapped to a list of values.
+func mapFields(obj interface{}, fields []field) (common.MapStr, []error) {
+	var errs []error
+	out := common.MapStr{}
+	for _, f := range fields {
+		found := f.path.find(obj)
+		if len(found) == 0 {
+			continue
+		}
+
+		values := make([]interface{}, 0, len(found))
+		for _, v := range found {
+			converted, err := convert(v, f.typ)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("field '%s': %v", f.name, err))
+				continue
+			}
+			values = append(values, converted)
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		if f.path.isMulti() {
+			out.Put(f.name, values)
+		} else {
+			out.Put(f.name, values[0])
+		}
+	}
+	return out, errs
+}
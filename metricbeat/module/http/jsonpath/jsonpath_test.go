@@ -0,0 +1,115 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package jsonpath
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	mbtest "github.com/elastic/beats/metricbeat/mb/testing"
+)
+
+const testResponse = `{
+	"service": "api",
+	"queues": [
+		{"name": "jobs", "depth": 12, "consumers": "3", "paused": false},
+		{"name": "mails", "depth": 0, "consumers": "1", "paused": true}
+	]
+}`
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testResponse))
+	}))
+}
+
+func TestFetch(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	config := map[string]interface{}{
+		"module":     "http",
+		"metricsets": []string{"jsonpath"},
+		"hosts":      []string{server.URL},
+		"namespace":  "queues",
+		"root":       "$.queues[*]",
+		"fields": []map[string]interface{}{
+			{"name": "depth", "path": "$.depth"},
+			{"name": "consumers.count", "path": "$.consumers", "type": "long"},
+			{"name": "paused", "path": "paused"},
+			{"name": "missing", "path": "$.missing"},
+		},
+		"labels": []map[string]interface{}{
+			{"name": "queue", "path": "$.name"},
+		},
+	}
+
+	f := mbtest.NewReportingMetricSetV2(t, config)
+	events, errs := mbtest.ReportingFetchV2(f)
+	assert.Empty(t, errs)
+	if !assert.Len(t, events, 2) {
+		return
+	}
+
+	event := events[0]
+	assert.Equal(t, "http.queues", event.Namespace)
+	assert.Equal(t, common.MapStr{
+		"depth":     int64(12),
+		"consumers": common.MapStr{"count": int64(3)},
+		"paused":    false,
+	}, event.MetricSetFields)
+	assert.Equal(t, common.MapStr{"labels": common.MapStr{"queue": "jobs"}}, event.ModuleFields)
+
+	assert.Equal(t, int64(0), events[1].MetricSetFields["depth"])
+	assert.Equal(t, true, events[1].MetricSetFields["paused"])
+}
+
+func TestFetchConversionError(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	config := map[string]interface{}{
+		"module":     "http",
+		"metricsets": []string{"jsonpath"},
+		"hosts":      []string{server.URL},
+		"namespace":  "service",
+		"fields": []map[string]interface{}{
+			{"name": "name", "path": "$.service"},
+			{"name": "depth", "path": "$.queues[*].depth"},
+			{"name": "invalid", "path": "$.service", "type": "long"},
+		},
+	}
+
+	f := mbtest.NewReportingMetricSetV2(t, config)
+	events, errs := mbtest.ReportingFetchV2(f)
+	assert.Len(t, errs, 1)
+	if !assert.Len(t, events, 1) {
+		return
+	}
+	assert.Equal(t, common.MapStr{
+		"name":  "api",
+		"depth": []interface{}{int64(12), int64(0)},
+	}, events[0].MetricSetFields)
+}
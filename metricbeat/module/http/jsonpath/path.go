@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a compiled JSONPath or dot-path expression.
+//
+// The supported JSONPath subset is the root `$`, child members `.name` and
+// `['name']`, array indices `[0]`, and the wildcards `.*` and `[*]`. Paths not
+// starting with `$` are dot-paths like `stats.requests.0`, where each segment
+// is an object key or an array index.
+type path []segment
+
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func compilePath(s string) (path, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	if !strings.HasPrefix(s, "$") {
+		return compileDotPath(s)
+	}
+
+	var p path
+	rest := s[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path '%s': empty member name", s)
+			}
+			if name == "*" {
+				p = append(p, segment{wildcard: true})
+			} else {
+				p = append(p, segment{key: name})
+			}
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path '%s': missing ']'", s)
+			}
+			seg, err := compileBracket(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path '%s': %v", s, err)
+			}
+			p = append(p, seg)
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid path '%s': unexpected '%c'", s, rest[0])
+		}
+	}
+	return p, nil
+}
+
+func compileBracket(s string) (segment, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "*":
+		return segment{wildcard: true}, nil
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return segment{key: s[1 : len(s)-1]}, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return segment{}, fmt.Errorf("invalid index '%s'", s)
+	}
+	return segment{index: i, isIndex: true}, nil
+}
+
+func compileDotPath(s string) (path, error) {
+	var p path
+	for _, name := range strings.Split(s, ".") {
+		if name == "" {
+			return nil, fmt.Errorf("invalid path '%s': empty segment", s)
+		}
+		seg := segment{key: name}
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 {
+			seg.index = i
+			seg.isIndex = true
+		}
+		p = append(p, seg)
+	}
+	return p, nil
+}
+
+// find returns all values in the document matching the path.
+func (p path) find(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, seg := range p {
+		var next []interface{}
+		for _, v := range current {
+			next = seg.apply(v, next)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// isMulti returns true if the path can match multiple values.
+func (p path) isMulti() bool {
+	for _, seg := range p {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+func (s segment) apply(v interface{}, out []interface{}) []interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			for _, k := range sortedKeys(v) {
+				out = append(out, v[k])
+			}
+		} else if child, found := v[s.key]; found {
+			out = append(out, child)
+		}
+	case []interface{}:
+		if s.wildcard {
+			out = append(out, v...)
+		} else if s.isIndex && s.index < len(v) {
+			out = append(out, v[s.index])
+		}
+	}
+	return out
+}
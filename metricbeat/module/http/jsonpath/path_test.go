@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDocument = `{
+	"version": "1.2.3",
+	"uptime": 3600,
+	"ratio": 0.5,
+	"stats": {
+		"requests": [10, 20, 30],
+		"pools": {"b": {"active": 2}, "a": {"active": 1}}
+	},
+	"dotted.key": "value"
+}`
+
+func decodeTestDocument(t *testing.T) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(testDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestPathFind(t *testing.T) {
+	doc := decodeTestDocument(t)
+
+	tests := []struct {
+		path     string
+		expected []interface{}
+	}{
+		{"$", []interface{}{doc}},
+		{"$.version", []interface{}{"1.2.3"}},
+		{"version", []interface{}{"1.2.3"}},
+		{"$.stats.requests[1]", []interface{}{float64(20)}},
+		{"stats.requests.2", []interface{}{float64(30)}},
+		{"$.stats.requests[*]", []interface{}{float64(10), float64(20), float64(30)}},
+		{"$.stats.pools.*.active", []interface{}{float64(1), float64(2)}},
+		{"$['dotted.key']", []interface{}{"value"}},
+		{"$.stats.requests[5]", nil},
+		{"$.missing.key", nil},
+	}
+
+	for _, test := range tests {
+		p, err := compilePath(test.path)
+		if !assert.NoError(t, err, test.path) {
+			continue
+		}
+		assert.Equal(t, test.expected, p.find(doc), test.path)
+	}
+}
+
+func TestCompilePathErrors(t *testing.T) {
+	for _, s := range []string{"", "$..a", "$[", "$[-1]", "$[a]", "$x", "a..b"} {
+		_, err := compilePath(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typ      string
+		expected interface{}
+	}{
+		{json.Number("10"), typeAuto, int64(10)},
+		{json.Number("1.5"), typeAuto, float64(1.5)},
+		{"text", typeAuto, "text"},
+		{json.Number("1.5"), typeLong, int64(1)},
+		{"42", typeLong, int64(42)},
+		{json.Number("2"), typeDouble, float64(2)},
+		{"true", typeBoolean, true},
+		{json.Number("0"), typeBoolean, false},
+		{json.Number("42"), typeKeyword, "42"},
+	}
+
+	for _, test := range tests {
+		v, err := convert(test.value, test.typ)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.expected, v)
+		}
+	}
+
+	_, err := convert("text", typeLong)
+	assert.Error(t, err)
+}
@@ -0,0 +1,320 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote_write
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+	"github.com/elastic/beats/metricbeat/mb"
+)
+
+const (
+	nameLabel     = "__name__"
+	bucketLabel   = "le"
+	quantileLabel = "quantile"
+
+	// staleNaN is the value Prometheus uses to mark a series as stale.
+	staleNaN uint64 = 0x7ff0000000000002
+
+	// maxDecodedSize limits the memory allocated to decompress a write request.
+	maxDecodedSize = 32 * 1024 * 1024
+)
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+type timeSeries struct {
+	labels  []label
+	samples []sample
+}
+
+// decodeWriteRequest decodes the snappy compressed WriteRequest protobuf
+// message sent by Prometheus.
+// See https://github.com/prometheus/prometheus/blob/master/prompb/remote.proto.
+func decodeWriteRequest(body []byte) ([]timeSeries, error) {
+	if len(body) == 0 {
+		return nil, fmt.Errorf("write request has no data")
+	}
+
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress write request: %v", err)
+	}
+	if size > maxDecodedSize {
+		return nil, fmt.Errorf("decompressed write request size %d exceeds the maximum of %d bytes", size, maxDecodedSize)
+	}
+
+	msg, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress write request: %v", err)
+	}
+
+	var series []timeSeries
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, wireType, _, data, err := r.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode write request: %v", err)
+		}
+		if field != 1 || wireType != protowire.WireBytes {
+			continue
+		}
+
+		ts, err := decodeTimeSeries(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode time series: %v", err)
+		}
+		series = append(series, ts)
+	}
+	return series, nil
+}
+
+func decodeTimeSeries(msg []byte) (timeSeries, error) {
+	var ts timeSeries
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, wireType, _, data, err := r.Next()
+		if err != nil {
+			return ts, err
+		}
+		if wireType != protowire.WireBytes {
+			continue
+		}
+
+		switch field {
+		case 1:
+			l, err := decodeLabel(data)
+			if err != nil {
+				return ts, err
+			}
+			ts.labels = append(ts.labels, l)
+		case 2:
+			s, err := decodeSample(data)
+			if err != nil {
+				return ts, err
+			}
+			ts.samples = append(ts.samples, s)
+		}
+	}
+	return ts, nil
+}
+
+func decodeLabel(msg []byte) (label, error) {
+	var l label
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, wireType, _, data, err := r.Next()
+		if err != nil {
+			return l, err
+		}
+		if wireType != protowire.WireBytes {
+			continue
+		}
+
+		switch field {
+		case 1:
+			l.name = string(data)
+		case 2:
+			l.value = string(data)
+		}
+	}
+	return l, nil
+}
+
+func decodeSample(msg []byte) (sample, error) {
+	var s sample
+	r := protowire.NewReader(msg)
+	for !r.Done() {
+		field, wireType, v, _, err := r.Next()
+		if err != nil {
+			return s, err
+		}
+
+		switch {
+		case field == 1 && wireType == protowire.WireFixed64:
+			s.value = math.Float64frombits(v)
+		case field == 2 && wireType == protowire.WireVarint:
+			s.timestamp = int64(v)
+		}
+	}
+	return s, nil
+}
+
+// eventsFromTimeSeries converts the samples into events. Samples sharing the
+// same timestamp and labels are reported in one event. Histogram buckets and
+// summary quantiles are grouped with their sum and count under the metric
+// name, like the collector metricset does. Stale markers and other NaN values
+// are dropped.
+func eventsFromTimeSeries(series []timeSeries) []mb.Event {
+	// find histograms and summaries first, to group their _sum and _count series
+	grouped := map[string]bool{}
+	for _, ts := range series {
+		name, labels := splitLabels(ts.labels)
+		if _, ok := labels[bucketLabel]; ok && strings.HasSuffix(name, "_bucket") {
+			grouped[strings.TrimSuffix(name, "_bucket")] = true
+		} else if _, ok := labels[quantileLabel]; ok {
+			grouped[name] = true
+		}
+	}
+
+	type groupKey struct {
+		timestamp int64
+		labels    string
+	}
+	var keys []groupKey
+	groups := map[groupKey]common.MapStr{}
+
+	for _, ts := range series {
+		name, labels := splitLabels(ts.labels)
+		if name == "" {
+			continue
+		}
+		key, field, convert := metricPath(name, labels, grouped)
+
+		for _, s := range ts.samples {
+			if math.Float64bits(s.value) == staleNaN {
+				// the series ended, there is no value to report
+				continue
+			}
+			if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+				continue
+			}
+
+			k := groupKey{timestamp: s.timestamp, labels: labels.String()}
+			fields, exists := groups[k]
+			if !exists {
+				fields = common.MapStr{}
+				if len(labels) > 0 {
+					fields["label"] = labels
+				}
+				groups[k] = fields
+				keys = append(keys, k)
+			}
+			setField(fields, key, field, convert(s.value))
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].timestamp < keys[j].timestamp
+	})
+
+	events := make([]mb.Event, 0, len(keys))
+	for _, k := range keys {
+		events = append(events, mb.Event{
+			Timestamp:       time.Unix(0, k.timestamp*int64(time.Millisecond)),
+			MetricSetFields: groups[k],
+		})
+	}
+	return events
+}
+
+// splitLabels returns the metric name and the remaining labels of a series.
+func splitLabels(labels []label) (string, common.MapStr) {
+	name := ""
+	m := common.MapStr{}
+	for _, l := range labels {
+		if l.name == nameLabel {
+			name = l.value
+		} else if l.name != "" && l.value != "" {
+			m[l.name] = l.value
+		}
+	}
+	return name, m
+}
+
+// metricPath returns the metric key, the field of the value within the metric
+// and the converted value for a sample of a series. Bucket and quantile labels
+// are removed from labels, as they are part of the field.
+func metricPath(
+	name string,
+	labels common.MapStr,
+	grouped map[string]bool,
+) (key string, field []string, convert func(float64) interface{}) {
+	asFloat := func(v float64) interface{} { return v }
+	asCount := func(v float64) interface{} { return uint64(v) }
+
+	if le, ok := labels[bucketLabel].(string); ok && strings.HasSuffix(name, "_bucket") {
+		base := strings.TrimSuffix(name, "_bucket")
+		if grouped[base] {
+			delete(labels, bucketLabel)
+			return base, []string{"bucket", formatBound(le)}, asCount
+		}
+	}
+
+	if q, ok := labels[quantileLabel].(string); ok && grouped[name] {
+		delete(labels, quantileLabel)
+		return name, []string{"percentile", formatPercentile(q)}, asFloat
+	}
+
+	if base := strings.TrimSuffix(name, "_sum"); base != name && grouped[base] {
+		return base, []string{"sum"}, asFloat
+	}
+	if base := strings.TrimSuffix(name, "_count"); base != name && grouped[base] {
+		return base, []string{"count"}, asCount
+	}
+
+	return name, []string{"value"}, asFloat
+}
+
+// setField sets the value in fields, without expanding dots in the keys.
+func setField(fields common.MapStr, key string, field []string, value interface{}) {
+	m := fields
+	for _, k := range append([]string{key}, field[:len(field)-1]...) {
+		child, ok := m[k].(common.MapStr)
+		if !ok {
+			child = common.MapStr{}
+			m[k] = child
+		}
+		m = child
+	}
+	m[field[len(field)-1]] = value
+}
+
+// formatBound formats bucket upper bounds like the collector metricset.
+func formatBound(le string) string {
+	f, err := strconv.ParseFloat(le, 64)
+	if err != nil {
+		return le
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatPercentile converts a quantile to a percentile, like the collector
+// metricset does.
+func formatPercentile(q string) string {
+	f, err := strconv.ParseFloat(q, 64)
+	if err != nil {
+		return q
+	}
+	return strconv.FormatFloat(100*f, 'f', -1, 64)
+}
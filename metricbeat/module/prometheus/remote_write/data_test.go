@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package remote_write
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/protowire"
+)
+
+func encodeWriteRequest(series []timeSeries) []byte {
+	var b protowire.Buffer
+	for _, ts := range series {
+		b.MessageField(1, func(b *protowire.Buffer) {
+			for _, l := range ts.labels {
+				b.MessageField(1, func(b *protowire.Buffer) {
+					b.StringField(1, l.name)
+					b.StringField(2, l.value)
+				})
+			}
+			for _, s := range ts.samples {
+				b.MessageField(2, func(b *protowire.Buffer) {
+					b.DoubleField(1, s.value)
+					b.VarintField(2, uint64(s.timestamp))
+				})
+			}
+		})
+	}
+	return snappy.Encode(nil, b.Bytes())
+}
+
+func series(name string, value float64, timestamp int64, labels ...string) timeSeries {
+	ts := timeSeries{
+		labels:  []label{{nameLabel, name}},
+		samples: []sample{{value, timestamp}},
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ts.labels = append(ts.labels, label{labels[i], labels[i+1]})
+	}
+	return ts
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	expected := []timeSeries{
+		series("up", 1, 1000, "job", "node"),
+		series("go_goroutines", 42, 2000),
+	}
+
+	decoded, err := decodeWriteRequest(encodeWriteRequest(expected))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, decoded)
+	}
+
+	_, err = decodeWriteRequest([]byte("not snappy"))
+	assert.Error(t, err)
+
+	_, err = decodeWriteRequest(snappy.Encode(nil, []byte{0x0a, 0x05}))
+	assert.Error(t, err)
+
+	// the header announces a decoded length of 1 GiB
+	_, err = decodeWriteRequest([]byte{0x80, 0x80, 0x80, 0x80, 0x04})
+	assert.Error(t, err)
+}
+
+func TestEventsFromTimeSeries(t *testing.T) {
+	events := eventsFromTimeSeries([]timeSeries{
+		series("up", 1, 1000, "job", "node"),
+		series("scrape_duration_seconds", 0.5, 1000, "job", "node"),
+		series("up", math.Float64frombits(staleNaN), 2000, "job", "node"),
+		series("http_request_duration_seconds_bucket", 3, 1000, "job", "api", "le", "0.1"),
+		series("http_request_duration_seconds_bucket", 5, 1000, "job", "api", "le", "+Inf"),
+		series("http_request_duration_seconds_sum", 1.5, 1000, "job", "api"),
+		series("http_request_duration_seconds_count", 5, 1000, "job", "api"),
+		series("rpc_duration_seconds", 0.2, 1000, "quantile", "0.99"),
+		series("rpc_duration_seconds_count", 10, 1000),
+		series("requests_count", 10, 1000),
+	})
+
+	if !assert.Len(t, events, 3) {
+		return
+	}
+
+	assert.Equal(t, time.Unix(1, 0), events[0].Timestamp)
+	assert.Equal(t, common.MapStr{
+		"label":                   common.MapStr{"job": "node"},
+		"up":                      common.MapStr{"value": float64(1)},
+		"scrape_duration_seconds": common.MapStr{"value": 0.5},
+	}, events[0].MetricSetFields)
+
+	assert.Equal(t, common.MapStr{
+		"label": common.MapStr{"job": "api"},
+		"http_request_duration_seconds": common.MapStr{
+			"bucket": common.MapStr{"0.1": uint64(3), "+Inf": uint64(5)},
+			"sum":    1.5,
+			"count":  uint64(5),
+		},
+	}, events[1].MetricSetFields)
+
+	assert.Equal(t, common.MapStr{
+		"rpc_duration_seconds": common.MapStr{
+			"percentile": common.MapStr{"99": 0.2},
+			"count":      uint64(10),
+		},
+		"requests_count": common.MapStr{"value": float64(10)},
+	}, events[2].MetricSetFields)
+}
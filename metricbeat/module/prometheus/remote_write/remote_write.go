@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package remote_write
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/libbeat/logp"
+	serverhelper "github.com/elastic/beats/metricbeat/helper/server"
+	"github.com/elastic/beats/metricbeat/helper/server/http"
+	"github.com/elastic/beats/metricbeat/mb"
+)
+
+func init() {
+	mb.Registry.MustAddMetricSet("prometheus", "remote_write", New)
+}
+
+// MetricSet receives samples pushed by Prometheus servers using the
+// remote_write protocol.
+type MetricSet struct {
+	mb.BaseMetricSet
+	server serverhelper.Server
+}
+
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The prometheus remote_write metricset is beta")
+
+	svc, err := http.NewHttpServer(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetricSet{
+		BaseMetricSet: base,
+		server:        svc,
+	}, nil
+}
+
+// Run starts the HTTP server and reports the samples of all write requests
+// received until the reporter is closed.
+func (m *MetricSet) Run(reporter mb.PushReporterV2) {
+	if err := m.server.Start(); err != nil {
+		err = errors.Wrap(err, "failed to start remote_write server")
+		logp.Err("%v", err)
+		reporter.Error(err)
+		return
+	}
+
+	for {
+		select {
+		case <-reporter.Done():
+			m.server.Stop()
+			return
+		case msg := <-m.server.GetEvents():
+			body, _ := msg.GetEvent()[serverhelper.EventDataKey].([]byte)
+			series, err := decodeWriteRequest(body)
+			if err != nil {
+				reporter.Error(err)
+				continue
+			}
+
+			for _, event := range eventsFromTimeSeries(series) {
+				reporter.Event(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+type StatsdServerConfig struct {
+	Protocol string        `config:"protocol"`
+	GaugeTTL time.Duration `config:"gauge_ttl"`
+}
+
+func defaultStatsdServerConfig() StatsdServerConfig {
+	return StatsdServerConfig{
+		Protocol: "udp",
+		GaugeTTL: 10 * time.Minute,
+	}
+}
+
+func (c StatsdServerConfig) Validate() error {
+	if c.Protocol != "tcp" && c.Protocol != "udp" {
+		return errors.New("`protocol` can only be tcp or udp")
+	}
+	if c.GaugeTTL < 0 {
+		return errors.New("`gauge_ttl` cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metric types of the statsd protocol.
+const (
+	typeCounter      = "c"
+	typeGauge        = "g"
+	typeTimer        = "ms"
+	typeHistogram    = "h"
+	typeDistribution = "d"
+	typeSet          = "s"
+)
+
+// metric is a single parsed statsd line.
+type metric struct {
+	name       string
+	value      string
+	typ        string
+	sampleRate float64
+	tags       map[string]string
+}
+
+// parsePacket parses all newline separated metrics of a packet. Invalid lines
+// are skipped, returning the error for the last invalid line.
+func parsePacket(packet string) ([]metric, error) {
+	var metrics []metric
+	var lastErr error
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		m, err := parseLine(line)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, lastErr
+}
+
+// parseLine parses a line in the statsd format
+// `<name>:<value>|<type>[|@<sample rate>]`, with optional DogStatsD tags in
+// the form `|#<key>:<value>,<tag>`.
+func parseLine(line string) (metric, error) {
+	m := metric{sampleRate: 1}
+
+	colon := strings.LastIndexByte(line[:pipeIndex(line)], ':')
+	if colon <= 0 {
+		return m, fmt.Errorf("invalid statsd line '%s': missing metric name", line)
+	}
+	m.name = line[:colon]
+
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 || parts[0] == "" {
+		return m, fmt.Errorf("invalid statsd line '%s': missing value or type", line)
+	}
+	m.value, m.typ = parts[0], parts[1]
+
+	switch m.typ {
+	case typeCounter, typeGauge, typeTimer, typeHistogram, typeDistribution:
+		if _, err := strconv.ParseFloat(strings.TrimPrefix(m.value, "+"), 64); err != nil {
+			return m, fmt.Errorf("invalid statsd line '%s': invalid value", line)
+		}
+	case typeSet:
+	default:
+		return m, fmt.Errorf("invalid statsd line '%s': unknown type '%s'", line, m.typ)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return m, fmt.Errorf("invalid statsd line '%s': invalid sample rate", line)
+			}
+			m.sampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			m.tags = parseTags(part[1:])
+		}
+	}
+	return m, nil
+}
+
+// pipeIndex returns the index of the first '|' in the line, or the length of
+// the line if there is none.
+func pipeIndex(line string) int {
+	if i := strings.IndexByte(line, '|'); i >= 0 {
+		return i
+	}
+	return len(line)
+}
+
+// parseTags parses comma separated DogStatsD tags. Tags without a value are
+// set to "true".
+func parseTags(s string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		if i := strings.IndexByte(tag, ':'); i > 0 {
+			tags[tag[:i]] = tag[i+1:]
+		} else {
+			tags[tag] = "true"
+		}
+	}
+	return tags
+}
@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected metric
+	}{
+		{
+			line:     "requests:1|c",
+			expected: metric{name: "requests", value: "1", typ: typeCounter, sampleRate: 1},
+		},
+		{
+			line:     "api.requests:2|c|@0.5",
+			expected: metric{name: "api.requests", value: "2", typ: typeCounter, sampleRate: 0.5},
+		},
+		{
+			line:     "queue.depth:-3|g",
+			expected: metric{name: "queue.depth", value: "-3", typ: typeGauge, sampleRate: 1},
+		},
+		{
+			line:     "latency:12.5|ms|#env:prod,canary",
+			expected: metric{name: "latency", value: "12.5", typ: typeTimer, sampleRate: 1, tags: map[string]string{"env": "prod", "canary": "true"}},
+		},
+		{
+			line:     "users:alice|s",
+			expected: metric{name: "users", value: "alice", typ: typeSet, sampleRate: 1},
+		},
+	}
+
+	for _, test := range tests {
+		m, err := parseLine(test.line)
+		if assert.NoError(t, err, test.line) {
+			assert.Equal(t, test.expected, m, test.line)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"requests",
+		":1|c",
+		"requests:1",
+		"requests:1|x",
+		"requests:abc|c",
+		"requests:1|c|@2",
+	} {
+		_, err := parseLine(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	metrics, err := parsePacket("a:1|c\ninvalid\nb:2|g\n")
+	assert.Error(t, err)
+	if assert.Len(t, metrics, 2) {
+		assert.Equal(t, "a", metrics[0].name)
+		assert.Equal(t, "b", metrics[1].name)
+	}
+}
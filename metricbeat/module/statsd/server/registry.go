@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package server
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/metricbeat/mb"
+)
+
+// registry aggregates the received metrics until they are flushed.
+type registry struct {
+	sync.Mutex
+	groups map[string]*group
+
+	// gauges keep their last value between flushes, so relative updates
+	// apply to the previous value. Gauges not updated within gaugeTTL are
+	// evicted on flush.
+	gauges   map[string]*gaugeValue
+	gaugeTTL time.Duration
+}
+
+type gaugeValue struct {
+	value   float64
+	updated time.Time
+}
+
+// group holds the metrics sharing the same tags.
+type group struct {
+	tags     map[string]string
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string]*timer
+	sets     map[string]map[string]struct{}
+}
+
+type timer struct {
+	values []float64
+	count  float64
+}
+
+func newRegistry(gaugeTTL time.Duration) *registry {
+	return &registry{
+		groups:   map[string]*group{},
+		gauges:   map[string]*gaugeValue{},
+		gaugeTTL: gaugeTTL,
+	}
+}
+
+func tagsKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + tags[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r *registry) update(m metric) {
+	r.Lock()
+	defer r.Unlock()
+
+	key := tagsKey(m.tags)
+	g := r.groups[key]
+	if g == nil {
+		g = &group{
+			tags:     m.tags,
+			counters: map[string]float64{},
+			gauges:   map[string]float64{},
+			timers:   map[string]*timer{},
+			sets:     map[string]map[string]struct{}{},
+		}
+		r.groups[key] = g
+	}
+
+	switch m.typ {
+	case typeCounter:
+		v, _ := strconv.ParseFloat(m.value, 64)
+		g.counters[m.name] += v / m.sampleRate
+
+	case typeGauge:
+		v, _ := strconv.ParseFloat(strings.TrimPrefix(m.value, "+"), 64)
+		gaugeKey := key + "|" + m.name
+		last := r.gauges[gaugeKey]
+		if last == nil {
+			last = &gaugeValue{}
+			r.gauges[gaugeKey] = last
+		}
+		if strings.HasPrefix(m.value, "+") || strings.HasPrefix(m.value, "-") {
+			v += last.value
+		}
+		last.value = v
+		last.updated = time.Now()
+		g.gauges[m.name] = v
+
+	case typeTimer, typeHistogram, typeDistribution:
+		v, _ := strconv.ParseFloat(m.value, 64)
+		t := g.timers[m.name]
+		if t == nil {
+			t = &timer{}
+			g.timers[m.name] = t
+		}
+		t.values = append(t.values, v)
+		t.count += 1 / m.sampleRate
+
+	case typeSet:
+		s := g.sets[m.name]
+		if s == nil {
+			s = map[string]struct{}{}
+			g.sets[m.name] = s
+		}
+		s[m.value] = struct{}{}
+	}
+}
+
+// flush returns one event per group of tags with the metrics received since
+// the last flush, and resets the registry. Gauges not updated within the TTL
+// are forgotten.
+func (r *registry) flush() []mb.Event {
+	r.Lock()
+	groups := r.groups
+	r.groups = map[string]*group{}
+	if r.gaugeTTL > 0 {
+		now := time.Now()
+		for k, g := range r.gauges {
+			if now.Sub(g.updated) > r.gaugeTTL {
+				delete(r.gauges, k)
+			}
+		}
+	}
+	r.Unlock()
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	events := make([]mb.Event, 0, len(groups))
+	for _, k := range keys {
+		g := groups[k]
+
+		fields := common.MapStr{}
+		for name, v := range g.counters {
+			fields.Put(name+".count", v)
+		}
+		for name, v := range g.gauges {
+			fields.Put(name+".value", v)
+		}
+		for name, t := range g.timers {
+			fields.Put(name+".stats", t.stats())
+		}
+		for name, s := range g.sets {
+			fields.Put(name+".unique", len(s))
+		}
+
+		event := mb.Event{MetricSetFields: fields}
+		if len(g.tags) > 0 {
+			labels := common.MapStr{}
+			for k, v := range g.tags {
+				labels[k] = v
+			}
+			event.RootFields = common.MapStr{"labels": labels}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func (t *timer) stats() common.MapStr {
+	sort.Float64s(t.values)
+	n := len(t.values)
+
+	sum := 0.0
+	for _, v := range t.values {
+		sum += v
+	}
+
+	return common.MapStr{
+		"count":  t.count,
+		"sum":    sum,
+		"min":    t.values[0],
+		"max":    t.values[n-1],
+		"mean":   sum / float64(n),
+		"median": percentile(t.values, 50),
+		"p95":    percentile(t.values, 95),
+		"p99":    percentile(t.values, 99),
+	}
+}
+
+// percentile returns the nearest rank percentile of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
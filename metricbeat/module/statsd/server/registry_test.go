@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func updateAll(t *testing.T, r *registry, packet string) {
+	metrics, err := parsePacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range metrics {
+		r.update(m)
+	}
+}
+
+func TestRegistryFlush(t *testing.T) {
+	r := newRegistry(0)
+	updateAll(t, r, "requests:1|c\nrequests:2|c|@0.5\n"+
+		"queue.depth:10|g\nqueue.depth:-3|g\n"+
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n"+
+		"latency:10|ms|#env:prod\nlatency:30|ms|#env:prod\nlatency:20|ms|#env:prod")
+
+	events := r.flush()
+	if !assert.Len(t, events, 2) {
+		return
+	}
+
+	assert.Nil(t, events[0].RootFields)
+	assert.Equal(t, common.MapStr{
+		"requests": common.MapStr{"count": float64(5)},
+		"queue":    common.MapStr{"depth": common.MapStr{"value": float64(7)}},
+		"users":    common.MapStr{"unique": 2},
+	}, events[0].MetricSetFields)
+
+	assert.Equal(t, common.MapStr{"labels": common.MapStr{"env": "prod"}}, events[1].RootFields)
+	assert.Equal(t, common.MapStr{
+		"latency": common.MapStr{
+			"stats": common.MapStr{
+				"count":  float64(3),
+				"sum":    float64(60),
+				"min":    float64(10),
+				"max":    float64(30),
+				"mean":   float64(20),
+				"median": float64(20),
+				"p95":    float64(30),
+				"p99":    float64(30),
+			},
+		},
+	}, events[1].MetricSetFields)
+
+	// metrics are reset on flush, gauges keep their value for relative updates
+	assert.Empty(t, r.flush())
+	updateAll(t, r, "queue.depth:+1|g")
+	events = r.flush()
+	if assert.Len(t, events, 1) {
+		v, _ := events[0].MetricSetFields.GetValue("queue.depth.value")
+		assert.Equal(t, float64(8), v)
+	}
+}
+
+func TestRegistrySameNameDifferentTypes(t *testing.T) {
+	r := newRegistry(0)
+	updateAll(t, r, "api:3|c\napi:7|g\napi:10|ms\napi:alice|s")
+
+	events := r.flush()
+	if !assert.Len(t, events, 1) {
+		return
+	}
+	fields := events[0].MetricSetFields
+	for field, expected := range map[string]interface{}{
+		"api.count":       float64(3),
+		"api.value":       float64(7),
+		"api.stats.count": float64(1),
+		"api.unique":      1,
+	} {
+		v, err := fields.GetValue(field)
+		if assert.NoError(t, err, field) {
+			assert.Equal(t, expected, v, field)
+		}
+	}
+}
+
+func TestRegistryGaugeTTL(t *testing.T) {
+	r := newRegistry(time.Minute)
+	updateAll(t, r, "old:5|g\nrecent:5|g")
+	r.gauges["|old"].updated = time.Now().Add(-2 * time.Minute)
+	r.flush()
+
+	assert.Len(t, r.gauges, 1)
+	assert.Contains(t, r.gauges, "|recent")
+
+	// relative updates on an evicted gauge start again from zero
+	updateAll(t, r, "old:+1|g")
+	events := r.flush()
+	if assert.Len(t, events, 1) {
+		v, _ := events[0].MetricSetFields.GetValue("old.value")
+		assert.Equal(t, float64(1), v)
+	}
+}
@@ -136,15 +136,18 @@ func (pgsql *pgsqlPlugin) parseCommand(s *pgsqlStream) (bool, bool) {
 	case 'I':
 		return pgsql.parseEmptyQueryResponse(s)
 	case 'C':
+		if s.statements != nil || s.portals != nil {
+			// only the frontend sends Parse and Bind, on its stream 'C' is
+			// a Close starting an extended query request
+			return pgsql.parseExtReq(s)
+		}
 		return pgsql.parseCommandComplete(s, length)
 	case 'Z':
 		return pgsql.parseReadyForQuery(s, length)
 	case 'E':
 		return pgsql.parseErrorResponse(s, length)
-	case 'P':
-		return pgsql.parseExtReq(s, length)
-	case '1':
-		return pgsql.parseExtResp(s, length)
+	case 'P', 'B':
+		return pgsql.parseExtReq(s)
 	default:
 		if !pgsqlValidType(typ) {
 			detailedf("invalid frame type: '%c'", typ)
@@ -287,53 +290,19 @@ func (pgsql *pgsqlPlugin) parseErrorResponse(s *pgsqlStream, length int) (bool,
 	return true, true
 }
 
-func (pgsql *pgsqlPlugin) parseExtReq(s *pgsqlStream, length int) (bool, bool) {
-	// Ready for query -> Parse for an extended query request
-	detailedf("Parse")
+func (pgsql *pgsqlPlugin) parseExtReq(s *pgsqlStream) (bool, bool) {
+	// Parse, Bind or Close -> start of an extended query request
+	detailedf("Extended query request")
 
 	m := s.message
 	m.start = s.parseOffset
 	m.isRequest = true
+	m.isExtended = true
 
-	s.parseOffset++ //type
-	s.parseOffset += length
-	m.end = s.parseOffset
-	m.size = uint64(m.end - m.start)
-	m.toExport = true
-
-	query, err := common.ReadString(s.data[m.start+6:])
-	if err != nil {
-		detailedf("Invalid extended query request")
-		return false, false
-	}
-	m.query = query
-	detailedf("Parse in an extended query request: %s", m.query)
-
-	// Ignore SET statement
-	if strings.HasPrefix(m.query, "SET ") {
-		m.toExport = false
-	}
 	s.parseState = pgsqlExtendedQueryState
 	return pgsql.parseMessageExtendedQuery(s)
 }
 
-func (pgsql *pgsqlPlugin) parseExtResp(s *pgsqlStream, length int) (bool, bool) {
-	// Sync -> Parse completion for an extended query response
-	detailedf("ParseCompletion")
-
-	m := s.message
-	m.start = s.parseOffset
-	m.isRequest = false
-	m.isOK = true
-	m.toExport = true
-
-	s.parseOffset++ //type
-	s.parseOffset += length
-	detailedf("Parse completion in an extended query response")
-	s.parseState = pgsqlGetDataState
-	return pgsql.parseMessageData(s)
-}
-
 func (pgsql *pgsqlPlugin) parseSkipMessage(s *pgsqlStream, length int) (bool, bool) {
 	// TODO: add info from NoticeResponse in case there are warning messages for a query
 	// ignore command
@@ -504,13 +473,28 @@ func (pgsql *pgsqlPlugin) parseMessageData(s *pgsqlStream) (bool, bool) {
 			detailedf("Rows: %s", m.rows)
 
 			return true, true
-		case '2':
-			// Parse completion -> Bind completion for an extended query response
+		case 's':
+			// PortalSuspended -> Execute reached the row limit
 
-			// skip type
 			s.parseOffset++
 			s.parseOffset += length
+			m.end = s.parseOffset
+			m.size = uint64(m.end - m.start)
 			s.parseState = pgsqlStartState
+
+			detailedf("PortalSuspended, rows: %s", m.rows)
+
+			return true, true
+		case 'Z':
+			// ReadyForQuery -> RowDescription was sent for a Describe
+			// without Execute, no result to report
+
+			m.end = s.parseOffset
+			m.size = uint64(m.end - m.start)
+			m.toExport = false
+			s.parseState = pgsqlStartState
+
+			return true, true
 		case 'T':
 			return pgsql.parseRowDescription(s, length)
 		default:
@@ -587,11 +571,12 @@ func (pgsql *pgsqlPlugin) parseMessageExtendedQuery(s *pgsqlStream) (bool, bool)
 	detailedf("parseMessageExtendedQuery")
 
 	// An extended query request contains:
-	// Parse
+	// Parse (optional, if the statement has been prepared before)
 	// Bind
-	// Describe
+	// Describe (optional)
 	// Execute
 	// Sync
+	// Bind and Execute can repeat for executing a statement multiple times.
 
 	m := s.message
 
@@ -612,28 +597,31 @@ func (pgsql *pgsqlPlugin) parseMessageExtendedQuery(s *pgsqlStream) (bool, bool)
 			return true, false
 		}
 
+		buf := s.data[s.parseOffset+5 : s.parseOffset+length+1]
+
 		switch typ {
+		case 'P':
+			if err := s.parseParse(buf); err != nil {
+				detailedf("Invalid Parse message: %v", err)
+				return false, false
+			}
 		case 'B':
-			// Parse -> Bind
-
-			// skip type
-			s.parseOffset++
-			s.parseOffset += length
-			//TODO: pgsql.parseBind(s)
-		case 'D':
-			// Bind -> Describe
-
-			// skip type
-			s.parseOffset++
-			s.parseOffset += length
-			//TODO: pgsql.parseDescribe(s)
+			if err := s.parseBind(buf); err != nil {
+				detailedf("Invalid Bind message: %v", err)
+				return false, false
+			}
 		case 'E':
-			// Bind(or Describe) -> Execute
-
-			// skip type
-			s.parseOffset++
-			s.parseOffset += length
-			//TODO: pgsql.parseExecute(s)
+			if err := s.parseExecute(buf); err != nil {
+				detailedf("Invalid Execute message: %v", err)
+				return false, false
+			}
+		case 'C':
+			if err := s.parseClose(buf); err != nil {
+				detailedf("Invalid Close message: %v", err)
+				return false, false
+			}
+		case 'D', 'H':
+			// Describe and Flush don't change the statements executed
 		case 'S':
 			// Execute -> Sync
 
@@ -644,6 +632,10 @@ func (pgsql *pgsqlPlugin) parseMessageExtendedQuery(s *pgsqlStream) (bool, bool)
 			m.size = uint64(m.end - m.start)
 			s.parseState = pgsqlStartState
 
+			// requests only preparing statements don't have a result to
+			// report
+			m.toExport = len(m.executed) > 0
+
 			return true, true
 		default:
 			// shouldn't happen -> return error
@@ -651,11 +643,125 @@ func (pgsql *pgsqlPlugin) parseMessageExtendedQuery(s *pgsqlStream) (bool, bool)
 			s.parseState = pgsqlStartState
 			return false, false
 		}
+
+		// skip type
+		s.parseOffset++
+		s.parseOffset += length
 	}
 
 	return true, false
 }
 
+// parseParse records the statement text of a prepared statement.
+func (s *pgsqlStream) parseParse(buf []byte) error {
+	name, off, err := readCString(buf, 0)
+	if err != nil {
+		return err
+	}
+	query, _, err := readCString(buf, off)
+	if err != nil {
+		return err
+	}
+
+	detailedf("Parse statement '%s': %s", name, query)
+	if s.statements == nil {
+		s.statements = map[string]string{}
+	}
+	if _, exists := s.statements[name]; !exists && len(s.statements) >= maxStatements {
+		// the statements are never closed, forget a random one
+		for k := range s.statements {
+			delete(s.statements, k)
+			break
+		}
+	}
+	s.statements[name] = query
+	return nil
+}
+
+// parseBind binds a prepared statement to a portal, recording the number of
+// parameters.
+func (s *pgsqlStream) parseBind(buf []byte) error {
+	portal, off, err := readCString(buf, 0)
+	if err != nil {
+		return err
+	}
+	name, off, err := readCString(buf, off)
+	if err != nil {
+		return err
+	}
+
+	// skip parameter format codes
+	if len(buf[off:]) < 2 {
+		return errInvalidLength
+	}
+	off += 2 + 2*readCount(buf[off:])
+	if len(buf) < off+2 {
+		return errInvalidLength
+	}
+	params := readCount(buf[off:])
+
+	query, found := s.statements[name]
+	if !found {
+		detailedf("Bind of unknown statement '%s'", name)
+	}
+	detailedf("Bind statement '%s' to portal '%s' with %d parameters", name, portal, params)
+
+	if s.portals == nil {
+		s.portals = map[string]pgsqlStatement{}
+	}
+	if _, exists := s.portals[portal]; !exists && len(s.portals) >= maxStatements {
+		for k := range s.portals {
+			delete(s.portals, k)
+			break
+		}
+	}
+	s.portals[portal] = pgsqlStatement{
+		name:      name,
+		query:     query,
+		numParams: params,
+	}
+	return nil
+}
+
+// parseClose forgets the closed prepared statement or portal.
+func (s *pgsqlStream) parseClose(buf []byte) error {
+	if len(buf) < 1 {
+		return errInvalidLength
+	}
+	name, _, err := readCString(buf, 1)
+	if err != nil {
+		return err
+	}
+
+	switch buf[0] {
+	case 'S':
+		detailedf("Close statement '%s'", name)
+		delete(s.statements, name)
+	case 'P':
+		detailedf("Close portal '%s'", name)
+		delete(s.portals, name)
+	}
+	return nil
+}
+
+// parseExecute adds the statement bound to the executed portal to the
+// statements executed by the request.
+func (s *pgsqlStream) parseExecute(buf []byte) error {
+	portal, _, err := readCString(buf, 0)
+	if err != nil {
+		return err
+	}
+
+	stmt, found := s.portals[portal]
+	if !found {
+		detailedf("Execute of unknown portal '%s'", portal)
+	}
+
+	m := s.message
+	m.executed = append(m.executed, stmt)
+	return nil
+}
+
 func isSpecialPgsqlCommand(data []byte) (bool, int, int) {
 	if len(data) < 8 {
 		// 8 bytes required
@@ -694,6 +800,19 @@ func readCount(b []byte) int {
 	return int(common.BytesNtohs(b))
 }
 
+// readCString reads a null terminated string starting at off, returning the
+// string and the offset following the string.
+func readCString(b []byte, off int) (string, int, error) {
+	if off > len(b) {
+		return "", off, errInvalidString
+	}
+	str, err := common.ReadString(b[off:])
+	if err != nil {
+		return "", off, errInvalidString
+	}
+	return str, off + len(str) + 1, nil
+}
+
 func pgsqlString(b []byte, sz int) (string, error) {
 	if sz == 0 {
 		return "", nil
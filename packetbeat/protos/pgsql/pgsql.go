@@ -69,6 +69,8 @@ type pgsqlMessage struct {
 	numberOfFields int
 	isOK           bool
 	isError        bool
+	isExtended     bool
+	executed       []pgsqlStatement
 	errorInfo      string
 	errorCode      string
 	errorSeverity  string
@@ -79,6 +81,14 @@ type pgsqlMessage struct {
 	cmdlineTuple *common.CmdlineTuple
 }
 
+// pgsqlStatement is a prepared statement executed by an extended query
+// request.
+type pgsqlStatement struct {
+	name      string
+	query     string
+	numParams int
+}
+
 type pgsqlTransaction struct {
 	tuple        common.TCPTuple
 	src          common.Endpoint
@@ -95,6 +105,12 @@ type pgsqlTransaction struct {
 
 	requestRaw  string
 	responseRaw string
+
+	// extended query request the transaction is part of, used to drop the
+	// transactions not executed after an error
+	extendedRequest *pgsqlMessage
+	// transaction is not reported
+	ignore bool
 }
 
 type pgsqlStream struct {
@@ -106,6 +122,11 @@ type pgsqlStream struct {
 	expectSSLResponse bool
 
 	message *pgsqlMessage
+
+	// prepared statements and portals seen in extended query requests, by
+	// name
+	statements map[string]string
+	portals    map[string]pgsqlStatement
 }
 
 const (
@@ -120,6 +141,10 @@ const (
 	cancelRequest
 )
 
+// maxStatements limits the number of prepared statements and portals kept per
+// connection.
+const maxStatements = 1000
+
 var (
 	errInvalidLength = errors.New("invalid length")
 )
@@ -373,19 +398,12 @@ var handlePgsql = func(pgsql *pgsqlPlugin, m *pgsqlMessage, tcptuple *common.TCP
 func (pgsql *pgsqlPlugin) receivedPgsqlRequest(msg *pgsqlMessage) {
 	tuple := msg.tcpTuple
 
-	// parse the query, as it might contain a list of pgsql command
-	// separated by ';'
-	queries := pgsqlQueryParser(msg.query)
-
-	logp.Debug("pgsqldetailed", "Queries (%d) :%s", len(queries), queries)
-
 	transList := pgsql.getTransaction(tuple.Hashable())
 	if transList == nil {
 		transList = []*pgsqlTransaction{}
 	}
 
-	for _, query := range queries {
-
+	newTransaction := func(query string) *pgsqlTransaction {
 		trans := &pgsqlTransaction{tuple: tuple}
 
 		trans.ts = msg.ts
@@ -403,8 +421,42 @@ func (pgsql *pgsqlPlugin) receivedPgsqlRequest(msg *pgsqlMessage) {
 		trans.notes = msg.notes
 
 		trans.requestRaw = query
+		return trans
+	}
+
+	if msg.isExtended {
+		// one transaction per executed statement, responses are returned in
+		// the order of execution
+		logp.Debug("pgsqldetailed", "Executed statements (%d) :%v", len(msg.executed), msg.executed)
+
+		for _, stmt := range msg.executed {
+			trans := newTransaction(stmt.query)
+			trans.extendedRequest = msg
+			trans.pgsql["num_params"] = stmt.numParams
+			if stmt.name != "" {
+				trans.pgsql["statement"] = stmt.name
+			}
+			if stmt.query == "" {
+				trans.notes = append(trans.notes, "Statement not seen in the captured traffic")
+			}
 
-		transList = append(transList, trans)
+			// Ignore SET statement
+			trans.ignore = strings.HasPrefix(stmt.query, "SET ")
+
+			transList = append(transList, trans)
+		}
+		pgsql.transactions.Put(tuple.Hashable(), transList)
+		return
+	}
+
+	// parse the query, as it might contain a list of pgsql command
+	// separated by ';'
+	queries := pgsqlQueryParser(msg.query)
+
+	logp.Debug("pgsqldetailed", "Queries (%d) :%s", len(queries), queries)
+
+	for _, query := range queries {
+		transList = append(transList, newTransaction(query))
 	}
 	pgsql.transactions.Put(tuple.Hashable(), transList)
 }
@@ -443,6 +495,15 @@ func (pgsql *pgsqlPlugin) receivedPgsqlResponse(msg *pgsqlMessage) {
 
 	trans.notes = append(trans.notes, msg.notes...)
 
+	if msg.isError && trans.extendedRequest != nil {
+		// statements following an error in an extended query request are
+		// not executed by the server
+		pgsql.removeExtendedRequest(tuple, trans.extendedRequest)
+	}
+
+	if trans.ignore {
+		return
+	}
 	pgsql.publishTransaction(trans)
 
 	debugf("Postgres transaction completed: %s\n%s", trans.pgsql, trans.responseRaw)
@@ -500,3 +561,24 @@ func (pgsql *pgsqlPlugin) removeTransaction(transList []*pgsqlTransaction,
 
 	return trans
 }
+
+// removeExtendedRequest removes all pending transactions of an extended query
+// request.
+func (pgsql *pgsqlPlugin) removeExtendedRequest(tuple common.TCPTuple, request *pgsqlMessage) {
+	transList := pgsql.getTransaction(tuple.Hashable())
+
+	pending := transList[:0]
+	for _, trans := range transList {
+		if trans.extendedRequest == request {
+			debugf("Statement not executed after error: %s", trans.query)
+			continue
+		}
+		pending = append(pending, trans)
+	}
+
+	if len(pending) == 0 {
+		pgsql.transactions.Delete(tuple.Hashable())
+	} else {
+		pgsql.transactions.Put(tuple.Hashable(), pending)
+	}
+}
@@ -20,7 +20,9 @@
 package pgsql
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -383,3 +385,269 @@ func Test_gap_in_response(t *testing.T) {
 	assert.NotNil(t, trans)
 	assert.Equal(t, trans["notes"], []string{"Packet loss while capturing the response"})
 }
+
+// pgsqlMsg encodes a message of the given type, joining the parts.
+func pgsqlMsg(typ byte, parts ...[]byte) []byte {
+	var body []byte
+	for _, p := range parts {
+		body = append(body, p...)
+	}
+	msg := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(body)+4))
+	return append(msg, body...)
+}
+
+func cstr(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func int16b(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func int32b(v int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func parseMsg(stmt, query string, params int) []byte {
+	parts := [][]byte{cstr(stmt), cstr(query), int16b(params)}
+	for i := 0; i < params; i++ {
+		parts = append(parts, int32b(23)) // int4
+	}
+	return pgsqlMsg('P', parts...)
+}
+
+func bindMsg(portal, stmt string, params int) []byte {
+	parts := [][]byte{cstr(portal), cstr(stmt), int16b(0), int16b(params)}
+	for i := 0; i < params; i++ {
+		parts = append(parts, int32b(1), []byte("1"))
+	}
+	parts = append(parts, int16b(0))
+	return pgsqlMsg('B', parts...)
+}
+
+func executeMsg(portal string) []byte {
+	return pgsqlMsg('E', cstr(portal), int32b(0))
+}
+
+func concat(msgs ...[]byte) []byte {
+	var buf []byte
+	for _, m := range msgs {
+		buf = append(buf, m...)
+	}
+	return buf
+}
+
+func selectResponse(prefix ...[]byte) []byte {
+	return concat(
+		concat(prefix...),
+		pgsqlMsg('T', int16b(1), cstr("id"), int32b(0), int16b(1), int32b(23), int16b(4), int32b(-1), int16b(0)),
+		pgsqlMsg('D', int16b(1), int32b(1), []byte("1")),
+		pgsqlMsg('C', cstr("SELECT 1")),
+	)
+}
+
+var (
+	parseComplete = pgsqlMsg('1')
+	bindComplete  = pgsqlMsg('2')
+	readyForQuery = pgsqlMsg('Z', []byte("I"))
+	syncMsg       = pgsqlMsg('S')
+)
+
+func TestPgsqlExtendedQuery(t *testing.T) {
+	store := &eventStore{}
+	pgsql := pgsqlModForTests(store)
+	tcptuple := testTCPTuple()
+
+	var private protos.ProtocolData
+	stream := func(dir uint8, data []byte) {
+		private = pgsql.Parse(&protos.Packet{Payload: data}, tcptuple, dir, private)
+	}
+
+	// prepare and execute a named statement
+	query := "SELECT * FROM test WHERE id = $1"
+	stream(0, concat(
+		parseMsg("s1", query, 1),
+		bindMsg("", "s1", 1),
+		pgsqlMsg('D', []byte("P"), cstr("")),
+		executeMsg(""),
+		syncMsg,
+	))
+	stream(1, selectResponse(parseComplete, bindComplete))
+	stream(1, readyForQuery)
+
+	trans := expectTransaction(t, store)
+	assert.Equal(t, query, trans["query"])
+	assert.Equal(t, "SELECT", trans["method"])
+	assert.Equal(t, common.OK_STATUS, trans["status"])
+	assert.Equal(t, 1, trans["pgsql"].(common.MapStr)["num_rows"])
+	assert.Equal(t, 1, trans["pgsql"].(common.MapStr)["num_params"])
+	assert.Equal(t, "s1", trans["pgsql"].(common.MapStr)["statement"])
+	assert.True(t, store.empty())
+
+	// execute the prepared statement twice, without Parse
+	stream(0, concat(
+		bindMsg("", "s1", 1),
+		executeMsg(""),
+		bindMsg("p1", "s1", 1),
+		executeMsg("p1"),
+		syncMsg,
+	))
+	stream(1, concat(
+		selectResponse(bindComplete),
+		selectResponse(bindComplete),
+		readyForQuery,
+	))
+
+	for i := 0; i < 2; i++ {
+		trans = expectTransaction(t, store)
+		assert.Equal(t, query, trans["query"])
+		assert.Equal(t, 1, trans["pgsql"].(common.MapStr)["num_params"])
+	}
+	assert.True(t, store.empty())
+}
+
+func TestPgsqlExtendedQuery_prepareOnly(t *testing.T) {
+	store := &eventStore{}
+	pgsql := pgsqlModForTests(store)
+	tcptuple := testTCPTuple()
+
+	var private protos.ProtocolData
+	stream := func(dir uint8, data []byte) {
+		private = pgsql.Parse(&protos.Packet{Payload: data}, tcptuple, dir, private)
+	}
+
+	// Describe of a statement, without Execute
+	stream(0, concat(
+		parseMsg("s1", "SELECT id FROM test", 0),
+		pgsqlMsg('D', []byte("S"), cstr("s1")),
+		syncMsg,
+	))
+	stream(1, concat(
+		parseComplete,
+		pgsqlMsg('t', int16b(0)),
+		pgsqlMsg('T', int16b(1), cstr("id"), int32b(0), int16b(1), int32b(23), int16b(4), int32b(-1), int16b(0)),
+		readyForQuery,
+	))
+	assert.True(t, store.empty())
+
+	stream(0, pgsqlMsg('Q', cstr("SELECT 1")))
+	stream(1, concat(selectResponse(), readyForQuery))
+
+	trans := expectTransaction(t, store)
+	assert.Equal(t, "SELECT 1", trans["query"])
+	assert.True(t, store.empty())
+}
+
+func TestPgsqlExtendedQuery_error(t *testing.T) {
+	store := &eventStore{}
+	pgsql := pgsqlModForTests(store)
+	tcptuple := testTCPTuple()
+
+	var private protos.ProtocolData
+	stream := func(dir uint8, data []byte) {
+		private = pgsql.Parse(&protos.Packet{Payload: data}, tcptuple, dir, private)
+	}
+
+	// the second statement is not executed after the first one failed
+	stream(0, concat(
+		parseMsg("", "SET extra_float_digits = 3", 0),
+		bindMsg("", "", 0),
+		executeMsg(""),
+		parseMsg("", "INSERT INTO test VALUES ($1)", 1),
+		bindMsg("", "", 1),
+		executeMsg(""),
+		parseMsg("", "SELECT * FROM missing", 0),
+		bindMsg("", "", 0),
+		executeMsg(""),
+		parseMsg("", "SELECT 2", 0),
+		bindMsg("", "", 0),
+		executeMsg(""),
+		syncMsg,
+	))
+	stream(1, concat(
+		parseComplete, bindComplete, pgsqlMsg('C', cstr("SET")),
+		parseComplete, bindComplete, pgsqlMsg('C', cstr("INSERT 0 1")),
+		pgsqlMsg('E', cstr("SERROR"), cstr("C42P01"), cstr(`Mrelation "missing" does not exist`), []byte{0}),
+		readyForQuery,
+	))
+
+	stream(0, pgsqlMsg('Q', cstr("SELECT 1")))
+	stream(1, concat(selectResponse(), readyForQuery))
+
+	// SET is not reported
+	trans := expectTransaction(t, store)
+	assert.Equal(t, "INSERT INTO test VALUES ($1)", trans["query"])
+	assert.Equal(t, common.OK_STATUS, trans["status"])
+
+	trans = expectTransaction(t, store)
+	assert.Equal(t, "SELECT * FROM missing", trans["query"])
+	assert.Equal(t, common.ERROR_STATUS, trans["status"])
+	assert.Equal(t, "42P01", trans["pgsql"].(common.MapStr)["error_code"])
+
+	trans = expectTransaction(t, store)
+	assert.Equal(t, "SELECT 1", trans["query"])
+	assert.True(t, store.empty())
+}
+
+func TestPgsqlExtendedQuery_close(t *testing.T) {
+	store := &eventStore{}
+	pgsql := pgsqlModForTests(store)
+	tcptuple := testTCPTuple()
+
+	var private protos.ProtocolData
+	stream := func(dir uint8, data []byte) {
+		private = pgsql.Parse(&protos.Packet{Payload: data}, tcptuple, dir, private)
+	}
+
+	closeMsg := func(typ byte, name string) []byte {
+		return pgsqlMsg('C', []byte{typ}, cstr(name))
+	}
+
+	stream(0, concat(
+		parseMsg("s1", "SELECT 1", 0),
+		parseMsg("s2", "SELECT 2", 0),
+		bindMsg("p1", "s1", 0),
+		syncMsg,
+	))
+	stream(1, concat(parseComplete, parseComplete, bindComplete, readyForQuery))
+	assert.True(t, store.empty())
+
+	// Close starting a request
+	stream(0, concat(closeMsg('S', "s1"), closeMsg('P', "p1"), syncMsg))
+	stream(1, concat(pgsqlMsg('3'), pgsqlMsg('3'), readyForQuery))
+	assert.True(t, store.empty())
+
+	priv := private.(pgsqlPrivateData)
+	client := priv.data[0]
+	assert.Equal(t, map[string]string{"s2": "SELECT 2"}, client.statements)
+	assert.Empty(t, client.portals)
+
+	// Close within a request
+	stream(0, concat(
+		bindMsg("", "s2", 0),
+		executeMsg(""),
+		closeMsg('S', "s2"),
+		syncMsg,
+	))
+	stream(1, concat(selectResponse(bindComplete), pgsqlMsg('3'), readyForQuery))
+
+	trans := expectTransaction(t, store)
+	assert.Equal(t, "SELECT 2", trans["query"])
+	assert.Empty(t, client.statements)
+	assert.True(t, store.empty())
+}
+
+func TestPgsqlExtendedQuery_maxStatements(t *testing.T) {
+	s := &pgsqlStream{}
+	for i := 0; i < maxStatements+10; i++ {
+		name := fmt.Sprintf("s%d", i)
+		assert.NoError(t, s.parseParse(concat(cstr(name), cstr("SELECT 1"), int16b(0))))
+		assert.NoError(t, s.parseBind(concat(cstr(name), cstr(name), int16b(0), int16b(0), int16b(0))))
+	}
+	assert.Len(t, s.statements, maxStatements)
+	assert.Len(t, s.portals, maxStatements)
+	assert.Contains(t, s.statements, fmt.Sprintf("s%d", maxStatements+9))
+}
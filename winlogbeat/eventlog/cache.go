@@ -22,6 +22,7 @@ package eventlog
 
 import (
 	"expvar"
+	"sync"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
@@ -55,6 +56,13 @@ type messageFilesCache struct {
 	freer        freeHandleFunc
 	eventLogName string
 
+	// pinned counts the batches using the MessageFiles of a sourceName. The
+	// handles of evicted MessageFiles still pinned are freed once the last
+	// batch using them is released.
+	mutex   sync.Mutex
+	pinned  map[string]int
+	evicted map[string][]sys.MessageFiles
+
 	// Cache metrics.
 	hit  func() // Increments number of cache hits.
 	miss func() // Increments number of cache misses.
@@ -74,6 +82,8 @@ func newMessageFilesCache(eventLogName string, loader messageFileLoaderFunc,
 		loader:       loader,
 		freer:        freer,
 		eventLogName: eventLogName,
+		pinned:       map[string]int{},
+		evicted:      map[string][]sys.MessageFiles{},
 		hit:          func() { cacheStats.Add(eventLogName+"Hits", 1) },
 		miss:         func() { cacheStats.Add(eventLogName+"Misses", 1) },
 	}
@@ -122,6 +132,54 @@ func (hc *messageFilesCache) get(sourceName string) sys.MessageFiles {
 	return messageFiles
 }
 
+// batchLookup returns a lookup function that remembers the MessageFiles
+// returned by the cache, so that a batch of events only accesses the cache
+// once per sourceName. The MessageFiles returned are pinned until the release
+// function is called, so their handles are not freed while the batch is
+// processed even if they are evicted from the cache.
+func (hc *messageFilesCache) batchLookup() (lookup func(sourceName string) sys.MessageFiles, release func()) {
+	batch := map[string]sys.MessageFiles{}
+	lookup = func(sourceName string) sys.MessageFiles {
+		if mf, found := batch[sourceName]; found {
+			return mf
+		}
+
+		hc.mutex.Lock()
+		hc.pinned[sourceName]++
+		hc.mutex.Unlock()
+
+		mf := hc.get(sourceName)
+		batch[sourceName] = mf
+		return mf
+	}
+	release = func() {
+		for sourceName := range batch {
+			hc.unpin(sourceName)
+		}
+		batch = nil
+	}
+	return lookup, release
+}
+
+// unpin releases a batch pin of sourceName, freeing the handles evicted while
+// pinned once no batch is using them anymore.
+func (hc *messageFilesCache) unpin(sourceName string) {
+	hc.mutex.Lock()
+	hc.pinned[sourceName]--
+	if hc.pinned[sourceName] > 0 {
+		hc.mutex.Unlock()
+		return
+	}
+	delete(hc.pinned, sourceName)
+	evicted := hc.evicted[sourceName]
+	delete(hc.evicted, sourceName)
+	hc.mutex.Unlock()
+
+	for _, mf := range evicted {
+		hc.freeHandles(mf)
+	}
+}
+
 // evictionHandler is the callback handler that receives notifications when
 // a key-value pair is evicted from the messageFilesCache.
 func (hc *messageFilesCache) evictionHandler(k common.Key, v common.Value) {
@@ -135,6 +193,15 @@ func (hc *messageFilesCache) evictionHandler(k common.Key, v common.Value) {
 
 	debugf("messageFilesCache[%s] Evicting messageFiles %+v for sourceName %v.",
 		hc.eventLogName, messageFiles, k)
+
+	sourceName, _ := k.(string)
+	hc.mutex.Lock()
+	if hc.pinned[sourceName] > 0 {
+		hc.evicted[sourceName] = append(hc.evicted[sourceName], messageFiles)
+		hc.mutex.Unlock()
+		return
+	}
+	hc.mutex.Unlock()
 	hc.freeHandles(messageFiles)
 }
 
@@ -0,0 +1,78 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package eventlog
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/winlogbeat/sys"
+)
+
+type testHandles struct {
+	mutex sync.Mutex
+	next  uintptr
+	freed []uintptr
+}
+
+func (h *testHandles) load(eventLogName, sourceName string) sys.MessageFiles {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.next++
+	return sys.MessageFiles{SourceName: sourceName, Handles: []sys.FileHandle{{Handle: h.next}}}
+}
+
+func (h *testHandles) free(handle uintptr) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.freed = append(h.freed, handle)
+	return nil
+}
+
+func (h *testHandles) getFreed() []uintptr {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]uintptr(nil), h.freed...)
+}
+
+func TestBatchLookupPinsHandles(t *testing.T) {
+	handles := &testHandles{}
+	cache := newMessageFilesCache("test", handles.load, handles.free)
+	defer cache.cache.StopJanitor()
+
+	lookup, release := cache.batchLookup()
+	mf := lookup("source")
+	assert.Equal(t, mf, lookup("source"))
+
+	// evicting the handles while the batch uses them doesn't free them
+	cache.cache.Delete("source")
+	cache.evictionHandler("source", mf)
+	assert.Empty(t, handles.getFreed())
+
+	release()
+	assert.Equal(t, []uintptr{mf.Handles[0].Handle}, handles.getFreed())
+
+	// unpinned handles are freed on eviction
+	lookup, release = cache.batchLookup()
+	mf = lookup("source")
+	release()
+	cache.evictionHandler("source", mf)
+	assert.Equal(t, mf.Handles[0].Handle, handles.getFreed()[1])
+}
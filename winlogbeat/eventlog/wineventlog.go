@@ -46,14 +46,16 @@ const (
 )
 
 var winEventLogConfigKeys = append(commonConfigKeys, "batch_read_size",
-	"ignore_older", "include_xml", "event_id", "forwarded", "level", "provider")
+	"ignore_older", "include_xml", "event_id", "forwarded", "level", "provider",
+	"xml_query")
 
 type winEventLogConfig struct {
 	ConfigCommon  `config:",inline"`
-	BatchReadSize int   `config:"batch_read_size"` // Maximum number of events that Read will return.
-	IncludeXML    bool  `config:"include_xml"`
-	Forwarded     *bool `config:"forwarded"`
-	SimpleQuery   query `config:",inline"`
+	BatchReadSize int    `config:"batch_read_size"` // Maximum number of events that Read will return.
+	IncludeXML    bool   `config:"include_xml"`
+	Forwarded     *bool  `config:"forwarded"`
+	SimpleQuery   query  `config:",inline"`
+	XMLQuery      string `config:"xml_query"` // Structured XML query, replaces the simple query.
 }
 
 // defaultWinEventLogConfig is the default configuration for new wineventlog readers.
@@ -78,6 +80,17 @@ func (c *winEventLogConfig) Validate() error {
 		errs = append(errs, fmt.Errorf("event log is missing a 'name'"))
 	}
 
+	if c.XMLQuery != "" {
+		q := c.SimpleQuery
+		if q.IgnoreOlder != 0 || q.EventID != "" || q.Level != "" || len(q.Provider) > 0 {
+			errs = append(errs, fmt.Errorf("xml_query cannot be used together "+
+				"with ignore_older, event_id, level or provider"))
+		}
+		if err := win.ValidateXMLQuery(c.XMLQuery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return errs.Err()
 }
 
@@ -94,14 +107,18 @@ type winEventLog struct {
 	maxRead      int                      // Maximum number returned in one Read.
 	lastRead     checkpoint.EventLogState // Record number of the last read event.
 
-	render    func(event win.EvtHandle, out io.Writer) error // Function for rendering the event to XML.
-	renderBuf []byte                                         // Buffer used for rendering event.
-	outputBuf *sys.ByteBuffer                                // Buffer for receiving XML
-	cache     *messageFilesCache                             // Cached mapping of source name to event message file handles.
+	render    renderFunc         // Function for rendering the event to XML.
+	renderBuf []byte             // Buffer used for rendering event.
+	outputBuf *sys.ByteBuffer    // Buffer for receiving XML
+	cache     *messageFilesCache // Cached mapping of source name to event message file handles.
 
 	logPrefix string // String to prefix on log messages.
 }
 
+// renderFunc renders an event to XML, using the given function to lookup the
+// publisher metadata handles.
+type renderFunc func(event win.EvtHandle, publishers func(string) sys.MessageFiles, out io.Writer) error
+
 // Name returns the name of the event log (i.e. Application, Security, etc.).
 func (l *winEventLog) Name() string {
 	return l.channelName
@@ -155,16 +172,21 @@ func (l *winEventLog) Read() ([]Record, error) {
 	}()
 	detailf("%s EventHandles returned %d handles", l.logPrefix, len(handles))
 
+	// The publisher metadata handles used by EvtFormatMessage are looked up
+	// once per provider in the batch.
+	publishers, release := l.cache.batchLookup()
+	defer release()
+
 	var records []Record
 	for _, h := range handles {
 		l.outputBuf.Reset()
-		err := l.render(h, l.outputBuf)
+		err := l.render(h, publishers, l.outputBuf)
 		if bufErr, ok := err.(sys.InsufficientBufferError); ok {
 			detailf("%s Increasing render buffer size to %d", l.logPrefix,
 				bufErr.RequiredSize)
 			l.renderBuf = make([]byte, bufErr.RequiredSize)
 			l.outputBuf.Reset()
-			err = l.render(h, l.outputBuf)
+			err = l.render(h, publishers, l.outputBuf)
 		}
 		if err != nil && l.outputBuf.Len() == 0 {
 			logp.Err("%s Dropping event with rendering error. %v", l.logPrefix, err)
@@ -277,15 +299,19 @@ func newWinEventLog(options *common.Config) (EventLog, error) {
 		return nil, err
 	}
 
-	query, err := win.Query{
-		Log:         c.Name,
-		IgnoreOlder: c.SimpleQuery.IgnoreOlder,
-		Level:       c.SimpleQuery.Level,
-		EventID:     c.SimpleQuery.EventID,
-		Provider:    c.SimpleQuery.Provider,
-	}.Build()
-	if err != nil {
-		return nil, err
+	query := c.XMLQuery
+	if query == "" {
+		var err error
+		query, err = win.Query{
+			Log:         c.Name,
+			IgnoreOlder: c.SimpleQuery.IgnoreOlder,
+			Level:       c.SimpleQuery.Level,
+			EventID:     c.SimpleQuery.EventID,
+			Provider:    c.SimpleQuery.Provider,
+		}.Build()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	eventMetadataHandle := func(providerName, sourceName string) sys.MessageFiles {
@@ -321,12 +347,12 @@ func newWinEventLog(options *common.Config) (EventLog, error) {
 	switch {
 	case c.Forwarded == nil && c.Name == "ForwardedEvents",
 		c.Forwarded != nil && *c.Forwarded == true:
-		l.render = func(event win.EvtHandle, out io.Writer) error {
+		l.render = func(event win.EvtHandle, _ func(string) sys.MessageFiles, out io.Writer) error {
 			return win.RenderEventXML(event, l.renderBuf, out)
 		}
 	default:
-		l.render = func(event win.EvtHandle, out io.Writer) error {
-			return win.RenderEvent(event, 0, l.renderBuf, l.cache.get, out)
+		l.render = func(event win.EvtHandle, publishers func(string) sys.MessageFiles, out io.Writer) error {
+			return win.RenderEvent(event, 0, l.renderBuf, publishers, out)
 		}
 	}
 
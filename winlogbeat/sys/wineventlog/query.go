@@ -19,6 +19,7 @@ package wineventlog
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -219,6 +220,56 @@ func (qp *queryParams) providerSelect(q Query) error {
 	return nil
 }
 
+// queryList is the structure of a XML query, as described in
+// https://msdn.microsoft.com/en-us/library/windows/desktop/aa385760(v=vs.85).aspx
+type queryList struct {
+	XMLName xml.Name   `xml:"QueryList"`
+	Queries []xmlQuery `xml:"Query"`
+}
+
+type xmlQuery struct {
+	Path     string        `xml:"Path,attr"`
+	Select   []xmlSelector `xml:"Select"`
+	Suppress []xmlSelector `xml:"Suppress"`
+}
+
+type xmlSelector struct {
+	Path  string `xml:"Path,attr"`
+	XPath string `xml:",chardata"`
+}
+
+// ValidateXMLQuery checks that a user provided XML query is well formed and
+// that each query has at least one selector with a path and an XPath
+// expression. The XPath expressions are only validated by Windows when
+// subscribing.
+func ValidateXMLQuery(q string) error {
+	var ql queryList
+	if err := xml.Unmarshal([]byte(q), &ql); err != nil {
+		return fmt.Errorf("invalid XML query: %v", err)
+	}
+	if len(ql.Queries) == 0 {
+		return fmt.Errorf("XML query has no Query element")
+	}
+
+	var errs multierror.Errors
+	for i, query := range ql.Queries {
+		if len(query.Select) == 0 {
+			errs = append(errs, fmt.Errorf("query %d has no Select element", i))
+		}
+		for _, selectors := range [][]xmlSelector{query.Select, query.Suppress} {
+			for _, sel := range selectors {
+				if sel.Path == "" && query.Path == "" {
+					errs = append(errs, fmt.Errorf("query %d has a selector without Path", i))
+				}
+				if strings.TrimSpace(sel.XPath) == "" {
+					errs = append(errs, fmt.Errorf("query %d has an empty selector", i))
+				}
+			}
+		}
+	}
+	return errs.Err()
+}
+
 // executeTemplate populates a template with the given data and returns the
 // value as a string.
 func executeTemplate(t *template.Template, data interface{}) (string, error) {
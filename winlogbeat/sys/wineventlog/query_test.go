@@ -128,3 +128,39 @@ func TestQueryNoParams(t *testing.T) {
 		fmt.Println(q)
 	}
 }
+
+func TestValidateXMLQuery(t *testing.T) {
+	valid := []string{
+		`<QueryList>
+  <Query Id="0" Path="Security">
+    <Select>*[System[(EventID=4624)]] and *[EventData[Data[@Name='LogonType']='3']]</Select>
+    <Suppress>*[EventData[Data[@Name='TargetUserName']='ANONYMOUS LOGON']]</Suppress>
+  </Query>
+</QueryList>`,
+		`<QueryList>
+  <Query Id="0">
+    <Select Path="Security">*[System[(EventID=4624)]]</Select>
+    <Select Path="System">*[System[(Level=1)]]</Select>
+  </Query>
+  <Query Id="1">
+    <Select Path="Application">*</Select>
+  </Query>
+</QueryList>`,
+	}
+	for _, q := range valid {
+		assert.NoError(t, ValidateXMLQuery(q), q)
+	}
+
+	invalid := []string{
+		``,
+		`*[System[(EventID=4624)]]`,
+		`<QueryList></QueryList>`,
+		`<QueryList><Query Id="0"></Query></QueryList>`,
+		`<QueryList><Query Id="0"><Select>*</Select></Query></QueryList>`,
+		`<QueryList><Query Id="0"><Select Path="Security"> </Select></Query></QueryList>`,
+		`<QueryList><Query Id="0"><Select Path="Security">*</Select>`,
+	}
+	for _, q := range invalid {
+		assert.Error(t, ValidateXMLQuery(q), q)
+	}
+}